@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	"os"
 	"reflect"
 	"sort"
 	"strings"
@@ -17,6 +16,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/bluenviron/mediamtx/internal/auth"
+	"github.com/bluenviron/mediamtx/internal/auth/policy"
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/conf/jsonwrapper"
 	"github.com/bluenviron/mediamtx/internal/defs"
@@ -76,6 +76,17 @@ func recordingsOfPath(
 	return ret
 }
 
+func markerToAPI(m *recordstore.Marker) *defs.APIRecordingMarker {
+	return &defs.APIRecordingMarker{
+		ID:       m.ID,
+		Created:  m.Created,
+		Start:    m.Start,
+		Duration: m.Duration.Seconds(),
+		Label:    m.Label,
+		Data:     m.Data,
+	}
+}
+
 type apiAuthManager interface {
 	Authenticate(req *auth.Request) *auth.Error
 	RefreshJWTJWKS()
@@ -88,27 +99,31 @@ type apiParent interface {
 
 // API is an API server.
 type API struct {
-	Version        string
-	Started        time.Time
-	Address        string
-	Encryption     bool
-	ServerKey      string
-	ServerCert     string
-	AllowOrigin    string
-	TrustedProxies conf.IPNetworks
-	ReadTimeout    conf.Duration
-	WriteTimeout   conf.Duration
-	Conf           *conf.Conf
-	AuthManager    apiAuthManager
-	PathManager    defs.APIPathManager
-	RTSPServer     defs.APIRTSPServer
-	RTSPSServer    defs.APIRTSPServer
-	RTMPServer     defs.APIRTMPServer
-	RTMPSServer    defs.APIRTMPServer
-	HLSServer      defs.APIHLSServer
-	WebRTCServer   defs.APIWebRTCServer
-	SRTServer      defs.APISRTServer
-	Parent         apiParent
+	Version              string
+	Started              time.Time
+	Address              string
+	Encryption           bool
+	ServerKey            string
+	ServerCert           string
+	ClientCA             string
+	AllowOrigin          string
+	TrustedProxies       conf.IPNetworks
+	ProxyProtocolSources conf.IPNetworks
+	ReadTimeout          conf.Duration
+	WriteTimeout         conf.Duration
+	Conf                 *conf.Conf
+	AuthManager          apiAuthManager
+	PathManager          defs.APIPathManager
+	RTSPServer           defs.APIRTSPServer
+	RTSPSServer          defs.APIRTSPServer
+	RTMPServer           defs.APIRTMPServer
+	RTMPSServer          defs.APIRTMPServer
+	HLSServer            defs.APIHLSServer
+	WebRTCServer         defs.APIWebRTCServer
+	SRTServer            defs.APISRTServer
+	RecordCleaner        defs.APIRecordCleaner
+	RecordRepairer       defs.APIRecordRepairer
+	Parent               apiParent
 
 	httpServer *httpp.Server
 	mutex      sync.RWMutex
@@ -127,6 +142,7 @@ func (a *API) Initialize() error {
 	group.GET("/info", a.onInfo)
 
 	group.POST("/auth/jwks/refresh", a.onAuthJwksRefresh)
+	group.POST("/auth/policy/test", a.onAuthPolicyTest)
 
 	group.GET("/config/global/get", a.onConfigGlobalGet)
 	group.PATCH("/config/global/patch", a.onConfigGlobalPatch)
@@ -192,16 +208,30 @@ func (a *API) Initialize() error {
 	group.GET("/recordings/list", a.onRecordingsList)
 	group.GET("/recordings/get/*name", a.onRecordingsGet)
 	group.DELETE("/recordings/deletesegment", a.onRecordingDeleteSegment)
+	group.POST("/recordings/trigger/*name", a.onRecordingTrigger)
+	group.GET("/recordings/markers/list/*name", a.onRecordingMarkersList)
+	group.POST("/recordings/markers/add/*name", a.onRecordingMarkersAdd)
+	group.DELETE("/recordings/markers/delete/*name", a.onRecordingMarkersDelete)
+
+	if !interfaceIsEmpty(a.RecordCleaner) {
+		group.GET("/recordings/usage", a.onRecordingsUsage)
+	}
+
+	if !interfaceIsEmpty(a.RecordRepairer) {
+		group.GET("/recordings/repairs", a.onRecordingsRepairs)
+	}
 
 	a.httpServer = &httpp.Server{
-		Address:      a.Address,
-		ReadTimeout:  time.Duration(a.ReadTimeout),
-		WriteTimeout: time.Duration(a.WriteTimeout),
-		Encryption:   a.Encryption,
-		ServerCert:   a.ServerCert,
-		ServerKey:    a.ServerKey,
-		Handler:      router,
-		Parent:       a,
+		Address:              a.Address,
+		ReadTimeout:          time.Duration(a.ReadTimeout),
+		WriteTimeout:         time.Duration(a.WriteTimeout),
+		Encryption:           a.Encryption,
+		ServerCert:           a.ServerCert,
+		ServerKey:            a.ServerKey,
+		ClientCA:             a.ClientCA,
+		ProxyProtocolSources: a.ProxyProtocolSources,
+		Handler:              router,
+		Parent:               a,
 	}
 	err := a.httpServer.Initialize()
 	if err != nil {
@@ -556,6 +586,69 @@ func (a *API) onAuthJwksRefresh(ctx *gin.Context) {
 	ctx.Status(http.StatusOK)
 }
 
+func (a *API) onAuthPolicyTest(ctx *gin.Context) {
+	var req defs.APIAuthPolicyTest
+	err := jsonwrapper.Decode(ctx.Request.Body, &req)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var exprs []string
+	if req.Policy != nil {
+		exprs = *req.Policy
+	} else {
+		a.mutex.RLock()
+		exprs = a.Conf.AuthPolicy
+		a.mutex.RUnlock()
+	}
+
+	in := &policy.Input{
+		User:     req.User,
+		Action:   req.Action,
+		Path:     req.Path,
+		Protocol: req.Protocol,
+		Query:    req.Query,
+		Time:     time.Now(),
+		Claims:   req.Claims,
+	}
+
+	if req.IP != "" {
+		in.IP = net.ParseIP(req.IP)
+		if in.IP == nil {
+			a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid IP '%s'", req.IP))
+			return
+		}
+	}
+
+	if req.Time != nil {
+		in.Time = *req.Time
+	}
+
+	p, err := policy.Compile(exprs)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	res := &defs.APIAuthPolicyTestResult{}
+
+	if len(exprs) == 0 {
+		res.Allowed = true
+	} else {
+		i, err := p.Evaluate(in)
+		if i >= 0 {
+			res.Allowed = true
+			res.MatchedPolicy = &i
+		} else if err != nil {
+			str := err.Error()
+			res.EvaluationError = &str
+		}
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
 func (a *API) onPathsList(ctx *gin.Context) {
 	data, err := a.PathManager.APIPathsList()
 	if err != nil {
@@ -1131,12 +1224,205 @@ func (a *API) onRecordingDeleteSegment(ctx *gin.Context) {
 		Start: start,
 	}.Encode(pathFormat)
 
-	err = os.Remove(segmentPath)
+	err = recordstore.DeleteSegment(ctx.Request.Context(), pathConf, pathName, segmentPath)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *API) onRecordingsUsage(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, a.RecordCleaner.APIUsage())
+}
+
+func (a *API) onRecordingsRepairs(ctx *gin.Context) {
+	data, err := a.RecordRepairer.APIRepairsList()
+	if err != nil {
+		a.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	data.ItemCount = len(data.Items)
+	pageCount, err := paginate(&data.Items, ctx.Query("itemsPerPage"), ctx.Query("page"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	data.PageCount = pageCount
+
+	ctx.JSON(http.StatusOK, data)
+}
+
+func (a *API) onRecordingTrigger(ctx *gin.Context) {
+	pathName, ok := paramName(ctx)
+	if !ok {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid name"))
+		return
+	}
+
+	var postRoll time.Duration
+
+	if v := ctx.Query("postRoll"); v != "" {
+		var err error
+		postRoll, err = time.ParseDuration(v)
+		if err != nil || postRoll <= 0 {
+			a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'postRoll' parameter"))
+			return
+		}
+	}
+
+	err := a.PathManager.APIRecordingTrigger(pathName, postRoll)
+	if err != nil {
+		if errors.Is(err, conf.ErrPathNotFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *API) findRecordingPathConf(ctx *gin.Context) (*conf.Path, string, bool) {
+	pathName, ok := paramName(ctx)
+	if !ok {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid name"))
+		return nil, "", false
+	}
+
+	a.mutex.RLock()
+	c := a.Conf
+	a.mutex.RUnlock()
+
+	pathConf, _, err := conf.FindPathConf(c.Paths, pathName)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return nil, "", false
+	}
+
+	return pathConf, pathName, true
+}
+
+func (a *API) onRecordingMarkersList(ctx *gin.Context) {
+	pathConf, pathName, ok := a.findRecordingPathConf(ctx)
+	if !ok {
+		return
+	}
+
+	var start *time.Time
+	if v := ctx.Query("start"); v != "" {
+		tmp, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'start' parameter: %w", err))
+			return
+		}
+		start = &tmp
+	}
+
+	var end *time.Time
+	if v := ctx.Query("end"); v != "" {
+		tmp, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'end' parameter: %w", err))
+			return
+		}
+		end = &tmp
+	}
+
+	markers, err := recordstore.FindMarkers(pathConf.RecordPath, pathName, start, end)
+	if err != nil {
+		a.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	data := defs.APIRecordingMarkerList{
+		Items: make([]*defs.APIRecordingMarker, len(markers)),
+	}
+
+	for i, m := range markers {
+		data.Items[i] = markerToAPI(m)
+	}
+
+	data.ItemCount = len(data.Items)
+	pageCount, err := paginate(&data.Items, ctx.Query("itemsPerPage"), ctx.Query("page"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	data.PageCount = pageCount
+
+	ctx.JSON(http.StatusOK, data)
+}
+
+func (a *API) onRecordingMarkersAdd(ctx *gin.Context) {
+	pathConf, pathName, ok := a.findRecordingPathConf(ctx)
+	if !ok {
+		return
+	}
+
+	var req defs.APIRecordingMarkerAdd
+	err := jsonwrapper.Decode(ctx.Request.Body, &req)
 	if err != nil {
 		a.writeError(ctx, http.StatusBadRequest, err)
 		return
 	}
 
+	if req.Start.IsZero() {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("'start' is missing"))
+		return
+	}
+
+	if req.Duration < 0 {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'duration'"))
+		return
+	}
+
+	if req.Label == "" {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("'label' is missing"))
+		return
+	}
+
+	m := &recordstore.Marker{
+		Start:    req.Start,
+		Duration: time.Duration(req.Duration * float64(time.Second)),
+		Label:    req.Label,
+		Data:     req.Data,
+	}
+
+	err = recordstore.AddMarker(pathConf.RecordPath, pathName, m)
+	if err != nil {
+		a.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, markerToAPI(m))
+}
+
+func (a *API) onRecordingMarkersDelete(ctx *gin.Context) {
+	pathConf, pathName, ok := a.findRecordingPathConf(ctx)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(ctx.Query("id"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'id' parameter: %w", err))
+		return
+	}
+
+	err = recordstore.RemoveMarker(pathConf.RecordPath, pathName, id)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrMarkerNotFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
 	ctx.Status(http.StatusOK)
 }
 
@@ -14,8 +14,10 @@ import (
 
 	"github.com/bluenviron/mediamtx/internal/auth"
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
 
@@ -31,6 +33,38 @@ func (p testParent) Log(l logger.Level, s string, a ...interface{}) {
 
 func (testParent) APIConfigSet(_ *conf.Conf) {}
 
+type testPathManager struct {
+	recordingTrigger func(string, time.Duration) error
+}
+
+func (testPathManager) APIPathsList() (*defs.APIPathList, error) {
+	panic("unused")
+}
+
+func (testPathManager) APIPathsGet(string) (*defs.APIPath, error) {
+	panic("unused")
+}
+
+func (m testPathManager) APIRecordingTrigger(name string, postRoll time.Duration) error {
+	return m.recordingTrigger(name, postRoll)
+}
+
+type testRecordCleaner struct {
+	usage *defs.APIRecordingUsage
+}
+
+func (c *testRecordCleaner) APIUsage() *defs.APIRecordingUsage {
+	return c.usage
+}
+
+type testRecordRepairer struct {
+	repairs []*defs.APIRecordingRepair
+}
+
+func (r *testRecordRepairer) APIRepairsList() (*defs.APIRecordingRepairList, error) {
+	return &defs.APIRecordingRepairList{Items: r.repairs}, nil
+}
+
 func tempConf(t *testing.T, cnt string) *conf.Conf {
 	fi, err := test.CreateTempFile([]byte(cnt))
 	require.NoError(t, err)
@@ -783,6 +817,258 @@ func TestRecordingsDeleteSegment(t *testing.T) {
 	require.Equal(t, http.StatusOK, res.StatusCode)
 }
 
+func TestRecordingsTrigger(t *testing.T) {
+	var triggeredName string
+	var triggeredPostRoll time.Duration
+
+	api := API{
+		Address:      "localhost:9997",
+		ReadTimeout:  conf.Duration(10 * time.Second),
+		WriteTimeout: conf.Duration(10 * time.Second),
+		Conf:         tempConf(t, "paths:\n  all_others:\n"),
+		AuthManager:  test.NilAuthManager,
+		PathManager: testPathManager{
+			recordingTrigger: func(name string, postRoll time.Duration) error {
+				if name != "mypath" {
+					return conf.ErrPathNotFound
+				}
+				triggeredName = name
+				triggeredPostRoll = postRoll
+				return nil
+			},
+		},
+		Parent: &testParent{},
+	}
+	err := api.Initialize()
+	require.NoError(t, err)
+	defer api.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	for _, ca := range []struct {
+		name   string
+		url    string
+		status int
+	}{
+		{"default post-roll", "mypath", http.StatusOK},
+		{"custom post-roll", "mypath?postRoll=30s", http.StatusOK},
+		{"invalid post-roll", "mypath?postRoll=abc", http.StatusBadRequest},
+		{"not found", "otherpath", http.StatusNotFound},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			triggeredName = ""
+			triggeredPostRoll = 0
+
+			res, err2 := hc.Post("http://localhost:9997/v3/recordings/trigger/"+ca.url, "", nil)
+			require.NoError(t, err2)
+			defer res.Body.Close()
+			require.Equal(t, ca.status, res.StatusCode)
+
+			switch ca.name {
+			case "default post-roll":
+				require.Equal(t, "mypath", triggeredName)
+				require.Equal(t, time.Duration(0), triggeredPostRoll)
+
+			case "custom post-roll":
+				require.Equal(t, "mypath", triggeredName)
+				require.Equal(t, 30*time.Second, triggeredPostRoll)
+
+			default:
+				require.Equal(t, "", triggeredName)
+			}
+		})
+	}
+}
+
+func TestRecordingsUsage(t *testing.T) {
+	freeSpace := uint64(789)
+
+	api := API{
+		Address:      "localhost:9997",
+		ReadTimeout:  conf.Duration(10 * time.Second),
+		WriteTimeout: conf.Duration(10 * time.Second),
+		Conf:         tempConf(t, "paths:\n  all_others:\n"),
+		AuthManager:  test.NilAuthManager,
+		RecordCleaner: &testRecordCleaner{
+			usage: &defs.APIRecordingUsage{
+				Bytes:        123,
+				Evictions:    2,
+				EvictedBytes: 456,
+				Items: []*defs.APIRecordingPathUsage{{
+					Name:         "mypath",
+					Bytes:        123,
+					Segments:     3,
+					FreeSpace:    &freeSpace,
+					Evictions:    2,
+					EvictedBytes: 456,
+				}},
+			},
+		},
+		Parent: &testParent{},
+	}
+	err := api.Initialize()
+	require.NoError(t, err)
+	defer api.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	var out interface{}
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/recordings/usage", nil, &out)
+	require.Equal(t, map[string]interface{}{
+		"lastCheck":    nil,
+		"bytes":        float64(123),
+		"evictions":    float64(2),
+		"evictedBytes": float64(456),
+		"items": []interface{}{
+			map[string]interface{}{
+				"name":         "mypath",
+				"bytes":        float64(123),
+				"segments":     float64(3),
+				"freeSpace":    float64(789),
+				"evictions":    float64(2),
+				"evictedBytes": float64(456),
+			},
+		},
+	}, out)
+}
+
+func TestRecordingsRepairs(t *testing.T) {
+	api := API{
+		Address:      "localhost:9997",
+		ReadTimeout:  conf.Duration(10 * time.Second),
+		WriteTimeout: conf.Duration(10 * time.Second),
+		Conf:         tempConf(t, "paths:\n  all_others:\n"),
+		AuthManager:  test.NilAuthManager,
+		RecordRepairer: &testRecordRepairer{
+			repairs: []*defs.APIRecordingRepair{{
+				Time:         time.Date(2009, 5, 20, 22, 15, 25, 0, time.UTC),
+				Path:         "mypath",
+				Start:        time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC),
+				Action:       defs.APIRecordingRepairActionTruncated,
+				RemovedBytes: 123,
+				Duration:     2.5,
+			}},
+		},
+		Parent: &testParent{},
+	}
+	err := api.Initialize()
+	require.NoError(t, err)
+	defer api.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	var out interface{}
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/recordings/repairs", nil, &out)
+	require.Equal(t, map[string]interface{}{
+		"itemCount": float64(1),
+		"pageCount": float64(1),
+		"items": []interface{}{
+			map[string]interface{}{
+				"time":         "2009-05-20T22:15:25Z",
+				"path":         "mypath",
+				"start":        "2008-05-20T22:15:25Z",
+				"action":       "truncated",
+				"removedBytes": float64(123),
+				"duration":     2.5,
+			},
+		},
+	}, out)
+}
+
+func TestRecordingsMarkers(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cnf := tempConf(t, "pathDefaults:\n"+
+		"  recordPath: "+filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")+"\n"+
+		"paths:\n"+
+		"  mypath1:\n")
+
+	api := API{
+		Address:      "localhost:9997",
+		ReadTimeout:  conf.Duration(10 * time.Second),
+		WriteTimeout: conf.Duration(10 * time.Second),
+		Conf:         cnf,
+		AuthManager:  test.NilAuthManager,
+		Parent:       &testParent{},
+	}
+	err = api.Initialize()
+	require.NoError(t, err)
+	defer api.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	var added defs.APIRecordingMarker
+	httpRequest(t, hc, http.MethodPost, "http://localhost:9997/v3/recordings/markers/add/mypath1",
+		map[string]interface{}{
+			"start":    time.Date(2008, 11, 7, 11, 22, 0, 0, time.Local).Format(time.RFC3339Nano),
+			"duration": 2.5,
+			"label":    "goal scored",
+			"data":     map[string]interface{}{"team": "home"},
+		}, &added)
+	require.NotEqual(t, uuid.Nil, added.ID)
+
+	var out interface{}
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/recordings/markers/list/mypath1", nil, &out)
+	require.Equal(t, map[string]interface{}{
+		"itemCount": float64(1),
+		"pageCount": float64(1),
+		"items": []interface{}{
+			map[string]interface{}{
+				"id":       added.ID.String(),
+				"created":  added.Created.Format(time.RFC3339Nano),
+				"start":    time.Date(2008, 11, 7, 11, 22, 0, 0, time.Local).Format(time.RFC3339Nano),
+				"duration": 2.5,
+				"label":    "goal scored",
+				"data":     map[string]interface{}{"team": "home"},
+			},
+		},
+	}, out)
+
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/recordings/markers/list/mypath1?start="+
+		url.QueryEscape(time.Date(2008, 11, 7, 11, 23, 0, 0, time.Local).Format(time.RFC3339)), nil, &out)
+	require.Equal(t, float64(0), out.(map[string]interface{})["itemCount"])
+
+	t.Run("invalid marker", func(t *testing.T) {
+		res, err2 := hc.Post("http://localhost:9997/v3/recordings/markers/add/mypath1", "application/json",
+			bytes.NewReader([]byte(`{"start":"2008-11-07T11:22:00Z"}`)))
+		require.NoError(t, err2)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		checkError(t, "'label' is missing", res.Body)
+	})
+
+	t.Run("path not configured", func(t *testing.T) {
+		res, err2 := hc.Get("http://localhost:9997/v3/recordings/markers/list/otherpath")
+		require.NoError(t, err2)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		checkError(t, "path 'otherpath' is not configured", res.Body)
+	})
+
+	httpRequest(t, hc, http.MethodDelete, "http://localhost:9997/v3/recordings/markers/delete/mypath1?id="+
+		added.ID.String(), nil, nil)
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:9997/v3/recordings/markers/delete/mypath1?id="+
+		added.ID.String(), nil)
+	require.NoError(t, err)
+
+	res, err := hc.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+	checkError(t, "marker not found", res.Body)
+}
+
 func TestAuthJWKSRefresh(t *testing.T) {
 	ok := false
 
@@ -822,6 +1108,91 @@ func TestAuthJWKSRefresh(t *testing.T) {
 	require.True(t, ok)
 }
 
+func TestAuthPolicyTest(t *testing.T) {
+	cnf := tempConf(t, "api: yes\n"+
+		"authPolicy:\n"+
+		"- action == \"read\" && claims.tier == \"gold\" || ip.inCIDR(\"10.0.0.0/8\")\n")
+
+	api := API{
+		Address:      "localhost:9997",
+		ReadTimeout:  conf.Duration(10 * time.Second),
+		WriteTimeout: conf.Duration(10 * time.Second),
+		Conf:         cnf,
+		AuthManager:  test.NilAuthManager,
+		Parent:       &testParent{},
+	}
+	err := api.Initialize()
+	require.NoError(t, err)
+	defer api.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	for _, ca := range []struct {
+		name string
+		in   map[string]interface{}
+		out  map[string]interface{}
+	}{
+		{
+			"claims",
+			map[string]interface{}{
+				"action": "read",
+				"ip":     "192.168.1.1",
+				"claims": map[string]interface{}{"tier": "gold"},
+			},
+			map[string]interface{}{
+				"allowed":         true,
+				"matchedPolicy":   float64(0),
+				"evaluationError": nil,
+			},
+		},
+		{
+			"ip",
+			map[string]interface{}{
+				"action": "publish",
+				"ip":     "10.0.0.5",
+			},
+			map[string]interface{}{
+				"allowed":         true,
+				"matchedPolicy":   float64(0),
+				"evaluationError": nil,
+			},
+		},
+		{
+			"denied",
+			map[string]interface{}{
+				"action": "read",
+				"ip":     "192.168.1.1",
+				"claims": map[string]interface{}{"tier": "silver"},
+			},
+			map[string]interface{}{
+				"allowed":         false,
+				"matchedPolicy":   nil,
+				"evaluationError": nil,
+			},
+		},
+		{
+			"custom policy",
+			map[string]interface{}{
+				"policy": []string{"false", `user == "myuser"`},
+				"user":   "myuser",
+			},
+			map[string]interface{}{
+				"allowed":         true,
+				"matchedPolicy":   float64(1),
+				"evaluationError": nil,
+			},
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			var out map[string]interface{}
+			httpRequest(t, hc, http.MethodPost, "http://localhost:9997/v3/auth/policy/test", ca.in, &out)
+			require.Equal(t, ca.out, out)
+		})
+	}
+}
+
 func TestAuthError(t *testing.T) {
 	cnf := tempConf(t, "api: yes\n")
 	n := 0
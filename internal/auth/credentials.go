@@ -1,8 +1,11 @@
 package auth
 
-// Credentials is a set of credentials (either user+pass or a token).
+// Credentials is a set of credentials (either user+pass, a token or a client certificate).
 type Credentials struct {
 	User  string
 	Pass  string
 	Token string
+
+	// identities of a client certificate that has been verified against a CA
+	ClientCertIdentities []string
 }
@@ -13,6 +13,7 @@ type jwtClaims struct {
 	jwt.RegisteredClaims
 	permissionsKey string
 	permissions    []conf.AuthInternalUserPermission
+	raw            map[string]any
 }
 
 func (c *jwtClaims) UnmarshalJSON(b []byte) error {
@@ -27,6 +28,11 @@ func (c *jwtClaims) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
+	err = json.Unmarshal(b, &c.raw)
+	if err != nil {
+		return err
+	}
+
 	rawPermissions, ok := claimMap[c.permissionsKey]
 	if !ok {
 		return fmt.Errorf("claim '%s' not found inside JWT", c.permissionsKey)
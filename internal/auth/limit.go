@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"strconv"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+// Limit is a usage limit that applies to an authenticated request.
+type Limit struct {
+	Key           string // identifies the user or permission the limit belongs to
+	MaxReaders    int
+	MaxPublishers int
+	MaxBitrate    uint64 // bits per second
+	TimeWindows   conf.AuthTimeWindows
+}
+
+func (l Limit) isEmpty() bool {
+	return l.MaxReaders == 0 && l.MaxPublishers == 0 && l.MaxBitrate == 0 && len(l.TimeWindows) == 0
+}
+
+func permissionLimit(parentKey string, index int, perm *conf.AuthInternalUserPermission) Limit {
+	return Limit{
+		Key:           parentKey + ":" + strconv.FormatInt(int64(index), 10),
+		MaxReaders:    perm.MaxReaders,
+		MaxPublishers: perm.MaxPublishers,
+		MaxBitrate:    perm.MaxBitrate,
+		TimeWindows:   perm.TimeWindows,
+	}
+}
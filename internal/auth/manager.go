@@ -9,11 +9,13 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/MicahParks/keyfunc/v3"
+	"github.com/bluenviron/mediamtx/internal/auth/policy"
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/protocols/tls"
 	"github.com/golang-jwt/jwt/v5"
@@ -27,31 +29,49 @@ const (
 	jwksRefreshPeriod = 60 * 60 * time.Second
 )
 
-func matchesPermission(perms []conf.AuthInternalUserPermission, req *Request) bool {
-	for _, perm := range perms {
-		if perm.Action == req.Action {
+func matchingPermission(perms []conf.AuthInternalUserPermission, req *Request, now time.Time) int {
+	for i, perm := range perms {
+		if perm.Action == req.Action && perm.TimeWindows.Contains(now) {
 			if perm.Action == conf.AuthActionPublish ||
 				perm.Action == conf.AuthActionRead ||
 				perm.Action == conf.AuthActionPlayback {
 				switch {
 				case perm.Path == "":
-					return true
+					return i
 
 				case strings.HasPrefix(perm.Path, "~"):
 					regexp, err := regexp.Compile(perm.Path[1:])
 					if err == nil && regexp.MatchString(req.Path) {
-						return true
+						return i
 					}
 
 				case perm.Path == req.Path:
-					return true
+					return i
 				}
 			} else {
-				return true
+				return i
 			}
 		}
 	}
 
+	return -1
+}
+
+func matchesPermission(perms []conf.AuthInternalUserPermission, req *Request) bool {
+	return matchingPermission(perms, req, time.Now()) >= 0
+}
+
+func matchesClientCert(u *conf.AuthInternalUser, req *Request) bool {
+	if u.ClientCertIdentity == "" {
+		return false
+	}
+
+	for _, identity := range req.Credentials.ClientCertIdentities {
+		if identity == u.ClientCertIdentity {
+			return true
+		}
+	}
+
 	return false
 }
 
@@ -66,11 +86,13 @@ type Manager struct {
 	JWTClaimKey        string
 	JWTExclude         []conf.AuthInternalUserPermission
 	JWTInHTTPQuery     bool
+	Policy             conf.AuthPolicy
 	ReadTimeout        time.Duration
 
 	mutex           sync.RWMutex
 	jwksLastRefresh time.Time
 	jwtKeyFunc      keyfunc.Keyfunc
+	compiledPolicy  *policy.Policy
 }
 
 // ReloadInternalUsers reloads InternalUsers.
@@ -80,69 +102,114 @@ func (m *Manager) ReloadInternalUsers(u []conf.AuthInternalUser) {
 	m.InternalUsers = u
 }
 
+// ReloadPolicy reloads Policy.
+func (m *Manager) ReloadPolicy(p conf.AuthPolicy) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.Policy = p
+	m.compiledPolicy = nil
+}
+
 // Authenticate authenticates a request.
 func (m *Manager) Authenticate(req *Request) *Error {
+	_, err := m.AuthenticateWithLimits(req)
+	return err
+}
+
+// AuthenticateWithLimits authenticates a request and returns the usage limits that apply to it.
+func (m *Manager) AuthenticateWithLimits(req *Request) ([]Limit, *Error) {
+	var limits []Limit
+	var claims *jwtClaims
 	var err error
 
 	switch m.Method {
 	case conf.AuthMethodInternal:
-		err = m.authenticateInternal(req)
+		limits, err = m.authenticateInternal(req)
 
 	case conf.AuthMethodHTTP:
 		err = m.authenticateHTTP(req)
 
 	default:
-		err = m.authenticateJWT(req)
+		limits, claims, err = m.authenticateJWT(req)
+	}
+
+	if err == nil {
+		err = m.authorizePolicy(req, claims)
 	}
 
 	if err != nil {
-		return &Error{
+		return nil, &Error{
 			Wrapped:        err,
 			AskCredentials: (req.Credentials.User == "" && req.Credentials.Pass == "" && req.Credentials.Token == ""),
 		}
 	}
 
-	return nil
+	return limits, nil
 }
 
-func (m *Manager) authenticateInternal(req *Request) error {
+func (m *Manager) authenticateInternal(req *Request) ([]Limit, error) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	for _, u := range m.InternalUsers {
-		if ok := m.authenticateWithUser(req, &u); ok {
-			return nil
+	now := time.Now()
+
+	for i, u := range m.InternalUsers {
+		if permIndex := m.authenticateWithUser(req, &u, now); permIndex >= 0 {
+			userKey := "internal:" + strconv.FormatInt(int64(i), 10) + ":" + string(u.User)
+			var limits []Limit
+
+			if l := (Limit{
+				Key:           userKey,
+				MaxReaders:    u.MaxReaders,
+				MaxPublishers: u.MaxPublishers,
+				MaxBitrate:    u.MaxBitrate,
+				TimeWindows:   u.TimeWindows,
+			}); !l.isEmpty() {
+				limits = append(limits, l)
+			}
+
+			if l := permissionLimit(userKey, permIndex, &u.Permissions[permIndex]); !l.isEmpty() {
+				limits = append(limits, l)
+			}
+
+			return limits, nil
 		}
 	}
 
-	return fmt.Errorf("authentication failed")
+	return nil, fmt.Errorf("authentication failed")
 }
 
 func (m *Manager) authenticateWithUser(
 	req *Request,
 	u *conf.AuthInternalUser,
-) bool {
+	now time.Time,
+) int {
 	if len(u.IPs) != 0 && !u.IPs.Contains(req.IP) {
-		return false
+		return -1
 	}
 
-	if !matchesPermission(u.Permissions, req) {
-		return false
+	if !u.TimeWindows.Contains(now) {
+		return -1
+	}
+
+	permIndex := matchingPermission(u.Permissions, req, now)
+	if permIndex < 0 {
+		return -1
 	}
 
-	if u.User != "any" {
+	if u.User != "any" && !matchesClientCert(u, req) {
 		if req.CustomVerifyFunc != nil {
 			if ok := req.CustomVerifyFunc(string(u.User), string(u.Pass)); !ok {
-				return false
+				return -1
 			}
 		} else {
 			if !u.User.Check(req.Credentials.User) || !u.Pass.Check(req.Credentials.Pass) {
-				return false
+				return -1
 			}
 		}
 	}
 
-	return true
+	return permIndex
 }
 
 func (m *Manager) authenticateHTTP(req *Request) error {
@@ -189,14 +256,14 @@ func (m *Manager) authenticateHTTP(req *Request) error {
 	return nil
 }
 
-func (m *Manager) authenticateJWT(req *Request) error {
+func (m *Manager) authenticateJWT(req *Request) ([]Limit, *jwtClaims, error) {
 	if matchesPermission(m.JWTExclude, req) {
-		return nil
+		return nil, nil, nil
 	}
 
 	keyfunc, err := m.pullJWTJWKS()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	var encodedJWT string
@@ -212,28 +279,113 @@ func (m *Manager) authenticateJWT(req *Request) error {
 		var v url.Values
 		v, err = url.ParseQuery(req.Query)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 
 		if len(v["jwt"]) != 1 || len(v["jwt"][0]) == 0 {
-			return fmt.Errorf("JWT not provided")
+			return nil, nil, fmt.Errorf("JWT not provided")
 		}
 
 		encodedJWT = v["jwt"][0]
 
 	default:
-		return fmt.Errorf("JWT not provided")
+		return nil, nil, fmt.Errorf("JWT not provided")
 	}
 
 	var cc jwtClaims
 	cc.permissionsKey = m.JWTClaimKey
 	_, err = jwt.ParseWithClaims(encodedJWT, &cc, keyfunc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	permIndex := matchingPermission(cc.permissions, req, time.Now())
+	if permIndex < 0 {
+		return nil, nil, fmt.Errorf("user doesn't have permission to perform action")
+	}
+
+	// tokens without a subject are accounted separately
+	subject := cc.Subject
+	if subject == "" {
+		subject = encodedJWT
+	}
+
+	if l := permissionLimit("jwt:"+subject, permIndex, &cc.permissions[permIndex]); !l.isEmpty() {
+		return []Limit{l}, &cc, nil
+	}
+
+	return nil, &cc, nil
+}
+
+func (m *Manager) loadPolicy() (*policy.Policy, error) {
+	m.mutex.RLock()
+	empty := len(m.Policy) == 0
+	p := m.compiledPolicy
+	m.mutex.RUnlock()
+
+	if empty {
+		return nil, nil
+	}
+
+	if p != nil {
+		return p, nil
+	}
+
+	// policy has not been compiled yet or has been reloaded
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(m.Policy) == 0 {
+		return nil, nil
+	}
+
+	if m.compiledPolicy == nil {
+		var err error
+		m.compiledPolicy, err = policy.Compile(m.Policy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m.compiledPolicy, nil
+}
+
+func (m *Manager) authorizePolicy(req *Request, claims *jwtClaims) error {
+	p, err := m.loadPolicy()
 	if err != nil {
 		return err
 	}
 
-	if !matchesPermission(cc.permissions, req) {
-		return fmt.Errorf("user doesn't have permission to perform action")
+	if p == nil {
+		return nil
+	}
+
+	in := &policy.Input{
+		User:     req.Credentials.User,
+		IP:       req.IP,
+		Action:   string(req.Action),
+		Path:     req.Path,
+		Protocol: string(req.Protocol),
+		Query:    req.Query,
+		Time:     time.Now(),
+	}
+
+	if in.User == "" && len(req.Credentials.ClientCertIdentities) != 0 {
+		in.User = req.Credentials.ClientCertIdentities[0]
+	}
+
+	if claims != nil {
+		in.Claims = claims.raw
+		if in.User == "" {
+			in.User = claims.Subject
+		}
+	}
+
+	if i, err := p.Evaluate(in); i < 0 {
+		if err != nil {
+			return fmt.Errorf("request not allowed by authPolicy: %w", err)
+		}
+		return fmt.Errorf("request not allowed by authPolicy")
 	}
 
 	return nil
@@ -205,6 +205,180 @@ func TestAuthInternalCustomVerifyFunc(t *testing.T) {
 	}
 }
 
+func TestAuthInternalLimits(t *testing.T) {
+	now := time.Now()
+
+	m := Manager{
+		Method: conf.AuthMethodInternal,
+		InternalUsers: []conf.AuthInternalUser{
+			{
+				User:       "myuser",
+				Pass:       "mypass",
+				MaxReaders: 10,
+				Permissions: []conf.AuthInternalUserPermission{
+					{
+						Action:     conf.AuthActionRead,
+						Path:       "mypath",
+						MaxBitrate: 1000000,
+					},
+					{
+						Action: conf.AuthActionPublish,
+						Path:   "mypath",
+						TimeWindows: conf.AuthTimeWindows{{
+							Start: now.Add(2 * time.Hour).Format("15:04"),
+							End:   now.Add(3 * time.Hour).Format("15:04"),
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	limits, err := m.AuthenticateWithLimits(&Request{
+		Action: conf.AuthActionRead,
+		Path:   "mypath",
+		Credentials: &Credentials{
+			User: "myuser",
+			Pass: "mypass",
+		},
+		IP: net.ParseIP("127.0.0.1"),
+	})
+	require.Nil(t, err)
+	require.Equal(t, []Limit{
+		{
+			Key:        "internal:0:myuser",
+			MaxReaders: 10,
+		},
+		{
+			Key:        "internal:0:myuser:0",
+			MaxBitrate: 1000000,
+		},
+	}, limits)
+
+	_, err = m.AuthenticateWithLimits(&Request{
+		Action: conf.AuthActionPublish,
+		Path:   "mypath",
+		Credentials: &Credentials{
+			User: "myuser",
+			Pass: "mypass",
+		},
+		IP: net.ParseIP("127.0.0.1"),
+	})
+	require.EqualError(t, err.Wrapped, "authentication failed")
+}
+
+func TestAuthInternalClientCert(t *testing.T) {
+	m := Manager{
+		Method: conf.AuthMethodInternal,
+		InternalUsers: []conf.AuthInternalUser{
+			{
+				User:               "mydevice",
+				Pass:               "mypass",
+				ClientCertIdentity: "mydevice.local",
+				Permissions: []conf.AuthInternalUserPermission{{
+					Action: conf.AuthActionPublish,
+				}},
+			},
+			{
+				User: "admin",
+				Pass: "adminpass",
+				Permissions: []conf.AuthInternalUserPermission{{
+					Action: conf.AuthActionPublish,
+				}},
+			},
+			{
+				User: "~^.*$",
+				Pass: "regexpass",
+				Permissions: []conf.AuthInternalUserPermission{{
+					Action: conf.AuthActionPublish,
+				}},
+			},
+		},
+	}
+
+	err := m.Authenticate(&Request{
+		Action: conf.AuthActionPublish,
+		Path:   "mypath",
+		Credentials: &Credentials{
+			ClientCertIdentities: []string{"mydevice", "mydevice.local"},
+		},
+		IP: net.ParseIP("127.0.0.1"),
+	})
+	require.Nil(t, err)
+
+	// usernames are not compared with certificates
+	err = m.Authenticate(&Request{
+		Action: conf.AuthActionPublish,
+		Path:   "mypath",
+		Credentials: &Credentials{
+			ClientCertIdentities: []string{"otherdevice", "admin"},
+		},
+		IP: net.ParseIP("127.0.0.1"),
+	})
+	require.EqualError(t, err.Wrapped, "authentication failed")
+
+	err = m.Authenticate(&Request{
+		Action: conf.AuthActionPublish,
+		Path:   "mypath",
+		Credentials: &Credentials{
+			ClientCertIdentities: []string{"otherdevice"},
+		},
+		IP: net.ParseIP("127.0.0.1"),
+	})
+	require.EqualError(t, err.Wrapped, "authentication failed")
+}
+
+func TestAuthPolicy(t *testing.T) {
+	m := Manager{
+		Method: conf.AuthMethodInternal,
+		InternalUsers: []conf.AuthInternalUser{
+			{
+				User: "any",
+				Permissions: []conf.AuthInternalUserPermission{{
+					Action: conf.AuthActionRead,
+				}},
+			},
+		},
+		Policy: conf.AuthPolicy{
+			`path.startsWith("public/") || ip.inCIDR("10.0.0.0/8")`,
+		},
+	}
+
+	err := m.Authenticate(&Request{
+		Action:      conf.AuthActionRead,
+		Path:        "public/mypath",
+		Credentials: &Credentials{},
+		IP:          net.ParseIP("127.0.0.1"),
+	})
+	require.Nil(t, err)
+
+	err = m.Authenticate(&Request{
+		Action:      conf.AuthActionRead,
+		Path:        "private/mypath",
+		Credentials: &Credentials{},
+		IP:          net.ParseIP("10.1.1.1"),
+	})
+	require.Nil(t, err)
+
+	err = m.Authenticate(&Request{
+		Action:      conf.AuthActionRead,
+		Path:        "private/mypath",
+		Credentials: &Credentials{},
+		IP:          net.ParseIP("127.0.0.1"),
+	})
+	require.EqualError(t, err.Wrapped, "request not allowed by authPolicy")
+
+	m.ReloadPolicy(conf.AuthPolicy{`user == "myuser"`})
+
+	err = m.Authenticate(&Request{
+		Action:      conf.AuthActionRead,
+		Path:        "private/mypath",
+		Credentials: &Credentials{User: "myuser"},
+		IP:          net.ParseIP("127.0.0.1"),
+	})
+	require.Nil(t, err)
+}
+
 func TestAuthHTTP(t *testing.T) {
 	for _, outcome := range []string{"ok", "fail"} {
 		t.Run(outcome, func(t *testing.T) {
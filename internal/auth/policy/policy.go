@@ -0,0 +1,136 @@
+// Package policy contains authorization policies based on CEL expressions.
+package policy
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// Input is the document a policy is evaluated against.
+type Input struct {
+	User     string
+	IP       net.IP
+	Action   string
+	Path     string
+	Protocol string
+	Query    string
+	Time     time.Time
+	Claims   map[string]any
+}
+
+func (in *Input) activation() map[string]any {
+	ip := ""
+	if in.IP != nil {
+		ip = in.IP.String()
+	}
+
+	claims := in.Claims
+	if claims == nil {
+		claims = map[string]any{}
+	}
+
+	return map[string]any{
+		"user":     in.User,
+		"ip":       ip,
+		"action":   in.Action,
+		"path":     in.Path,
+		"protocol": in.Protocol,
+		"query":    in.Query,
+		"time":     in.Time,
+		"claims":   claims,
+	}
+}
+
+func inCIDR(lhs ref.Val, rhs ref.Val) ref.Val {
+	ip := net.ParseIP(string(lhs.(types.String)))
+	if ip == nil {
+		return types.Bool(false)
+	}
+
+	_, ipnet, err := net.ParseCIDR(string(rhs.(types.String)))
+	if err != nil {
+		return types.NewErr("invalid CIDR '%s'", rhs)
+	}
+
+	return types.Bool(ipnet.Contains(ip))
+}
+
+func newEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("user", cel.StringType),
+		cel.Variable("ip", cel.StringType),
+		cel.Variable("action", cel.StringType),
+		cel.Variable("path", cel.StringType),
+		cel.Variable("protocol", cel.StringType),
+		cel.Variable("query", cel.StringType),
+		cel.Variable("time", cel.TimestampType),
+		cel.Variable("claims", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Function("inCIDR",
+			cel.MemberOverload("string_inCIDR_string",
+				[]*cel.Type{cel.StringType, cel.StringType},
+				cel.BoolType,
+				cel.BinaryBinding(inCIDR))),
+	)
+}
+
+// Policy is a list of compiled expressions.
+type Policy struct {
+	programs []cel.Program
+}
+
+// Compile compiles a list of expressions.
+func Compile(exprs []string) (*Policy, error) {
+	env, err := newEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Policy{}
+
+	for _, expr := range exprs {
+		ast, iss := env.Compile(expr)
+		if iss.Err() != nil {
+			return nil, fmt.Errorf("invalid expression '%s': %w", expr, iss.Err())
+		}
+
+		if ast.OutputType() != cel.BoolType {
+			return nil, fmt.Errorf("invalid expression '%s': result must be a boolean", expr)
+		}
+
+		prg, err := env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression '%s': %w", expr, err)
+		}
+
+		p.programs = append(p.programs, prg)
+	}
+
+	return p, nil
+}
+
+// Evaluate returns the index of the first expression that is satisfied by the input.
+// Expressions that cannot be evaluated, for instance because a claim is missing, are skipped.
+// If no expression is satisfied, it returns -1 and the last evaluation error, if any.
+func (p *Policy) Evaluate(in *Input) (int, error) {
+	act := in.activation()
+	var lastErr error
+
+	for i, prg := range p.programs {
+		out, _, err := prg.Eval(act)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if b, ok := out.Value().(bool); ok && b {
+			return i, nil
+		}
+	}
+
+	return -1, lastErr
+}
@@ -0,0 +1,97 @@
+package policy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileError(t *testing.T) {
+	for _, ca := range []struct {
+		name string
+		expr string
+		err  string
+	}{
+		{
+			"syntax",
+			"action ==",
+			"invalid expression 'action =='",
+		},
+		{
+			"undeclared",
+			"foo == \"bar\"",
+			"invalid expression 'foo == \"bar\"'",
+		},
+		{
+			"non boolean",
+			"path",
+			"invalid expression 'path': result must be a boolean",
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			_, err := Compile([]string{ca.expr})
+			require.ErrorContains(t, err, ca.err)
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	p, err := Compile([]string{
+		`action == "read" && claims.tier == "gold" || ip.inCIDR("10.0.0.0/8")`,
+		`user == "admin" && path.startsWith("private/") && time.getHours("UTC") < 12`,
+	})
+	require.NoError(t, err)
+
+	for _, ca := range []struct {
+		name  string
+		in    Input
+		index int
+	}{
+		{
+			"claim",
+			Input{
+				IP:     net.ParseIP("192.168.0.1"),
+				Action: "read",
+				Claims: map[string]any{"tier": "gold"},
+			},
+			0,
+		},
+		{
+			"cidr without claims",
+			Input{
+				IP:     net.ParseIP("10.1.2.3"),
+				Action: "publish",
+			},
+			0,
+		},
+		{
+			"time",
+			Input{
+				User: "admin",
+				IP:   net.ParseIP("192.168.0.1"),
+				Path: "private/cam1",
+				Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+			},
+			1,
+		},
+		{
+			"denied",
+			Input{
+				User:   "admin",
+				IP:     net.ParseIP("192.168.0.1"),
+				Action: "read",
+				Path:   "private/cam1",
+				Time:   time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC),
+				Claims: map[string]any{"tier": "silver"},
+			},
+			-1,
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			index, _ := p.Evaluate(&ca.in)
+			require.Equal(t, ca.index, index)
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SessionLimiterPeriod is the interval between checks of running sessions.
+const SessionLimiterPeriod = 5 * time.Second
+
+// bytesCounter is implemented by sessions
+// that are able to report the data exchanged with their client.
+type bytesCounter interface {
+	BytesReceived() uint64
+	BytesSent() uint64
+}
+
+type limitedSession struct {
+	limits    []Limit
+	publisher bool
+	path      any
+	created   time.Time
+	counter   bytesCounter
+	lastBytes uint64
+	lastTime  time.Time
+	bitrate   uint64
+}
+
+func (s *limitedSession) bytes() uint64 {
+	if s.publisher {
+		return s.counter.BytesReceived()
+	}
+	return s.counter.BytesSent()
+}
+
+// measure updates the bitrate of the session with the data
+// it exchanged since the previous measurement.
+func (s *limitedSession) measure(cur uint64, now time.Time) {
+	elapsed := now.Sub(s.lastTime)
+	if elapsed < time.Second {
+		return
+	}
+
+	if cur >= s.lastBytes {
+		s.bitrate = uint64(float64((cur-s.lastBytes)*8) / elapsed.Seconds())
+	}
+
+	s.lastBytes = cur
+	s.lastTime = now
+}
+
+func (s *limitedSession) hasLimit(key string) bool {
+	for _, sl := range s.limits {
+		if sl.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// SessionLimitViolation is a session that exceeded one of its limits.
+type SessionLimitViolation struct {
+	Author any
+	Err    error
+}
+
+// SessionLimiter enforces per-user and per-permission limits
+// on readers and publishers.
+type SessionLimiter struct {
+	mutex    sync.Mutex
+	sessions map[any]*limitedSession
+}
+
+// Initialize initializes SessionLimiter.
+func (l *SessionLimiter) Initialize() {
+	l.sessions = make(map[any]*limitedSession)
+}
+
+// Reserve checks limits and, if they are not exceeded, registers the session.
+func (l *SessionLimiter) Reserve(author any, path any, publisher bool, limits []Limit) error {
+	if len(limits) == 0 {
+		return nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.sessions, author)
+
+	for _, limit := range limits {
+		readers := 0
+		publishers := 0
+		var bitrate uint64
+
+		for _, s := range l.sessions {
+			if s.hasLimit(limit.Key) {
+				if s.publisher {
+					publishers++
+				} else {
+					readers++
+				}
+				bitrate += s.bitrate
+			}
+		}
+
+		if publisher {
+			if limit.MaxPublishers != 0 && publishers >= limit.MaxPublishers {
+				return fmt.Errorf("maximum publisher count of user reached")
+			}
+		} else if limit.MaxReaders != 0 && readers >= limit.MaxReaders {
+			return fmt.Errorf("maximum reader count of user reached")
+		}
+
+		if limit.MaxBitrate != 0 && bitrate >= limit.MaxBitrate {
+			return fmt.Errorf("maximum bitrate of user reached")
+		}
+	}
+
+	l.sessions[author] = &limitedSession{
+		limits:    limits,
+		publisher: publisher,
+		path:      path,
+		created:   time.Now(),
+	}
+
+	return nil
+}
+
+// Attach is called once the session has been attached to a stream,
+// in order to start measuring its bitrate.
+// Sessions that are not able to count their bytes are not measured.
+func (l *SessionLimiter) Attach(author any) {
+	counter, ok := author.(bytesCounter)
+	if !ok {
+		return
+	}
+
+	// counters are read outside of the mutex since they lock sessions
+	received := counter.BytesReceived()
+	sent := counter.BytesSent()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if s, ok := l.sessions[author]; ok {
+		s.counter = counter
+		if s.publisher {
+			s.lastBytes = received
+		} else {
+			s.lastBytes = sent
+		}
+		s.lastTime = time.Now()
+	}
+}
+
+// Release unregisters a session.
+func (l *SessionLimiter) Release(author any) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.sessions, author)
+}
+
+// ReleasePath unregisters all sessions of a path.
+func (l *SessionLimiter) ReleasePath(path any) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for author, s := range l.sessions {
+		if s.path == path {
+			delete(l.sessions, author)
+		}
+	}
+}
+
+// Check measures running sessions and returns the ones that are outside
+// their time windows or that make their users exceed the maximum bitrate.
+// When a bitrate is exceeded, the most recent sessions are returned first,
+// until the remaining ones fit into the limit.
+func (l *SessionLimiter) Check(now time.Time) []SessionLimitViolation {
+	// counters are read outside of the mutex since they lock sessions
+	l.mutex.Lock()
+	sessions := make(map[any]*limitedSession, len(l.sessions))
+	for author, s := range l.sessions {
+		if s.counter != nil {
+			sessions[author] = s
+		}
+	}
+	l.mutex.Unlock()
+
+	bytes := make(map[any]uint64, len(sessions))
+	for author, s := range sessions {
+		bytes[author] = s.bytes()
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var out []SessionLimitViolation
+
+	for author, s := range l.sessions {
+		if cur, ok := bytes[author]; ok {
+			s.measure(cur, now)
+		}
+
+		for _, limit := range s.limits {
+			if !limit.TimeWindows.Contains(now) {
+				out = append(out, SessionLimitViolation{
+					Author: author,
+					Err:    fmt.Errorf("time window of user has ended"),
+				})
+				delete(l.sessions, author)
+				break
+			}
+		}
+	}
+
+	for {
+		author := l.findBitrateViolation()
+		if author == nil {
+			break
+		}
+
+		out = append(out, SessionLimitViolation{
+			Author: author,
+			Err:    fmt.Errorf("maximum bitrate of user exceeded"),
+		})
+		delete(l.sessions, author)
+	}
+
+	return out
+}
+
+// findBitrateViolation returns the most recent session that belongs
+// to a user whose bitrate exceeds the limit, or nil.
+func (l *SessionLimiter) findBitrateViolation() any {
+	bitrates := make(map[string]uint64)
+
+	for _, s := range l.sessions {
+		for _, limit := range s.limits {
+			if limit.MaxBitrate != 0 {
+				bitrates[limit.Key] += s.bitrate
+			}
+		}
+	}
+
+	var found any
+	var foundCreated time.Time
+
+	for author, s := range l.sessions {
+		// closing sessions without traffic would not lower the bitrate
+		if s.bitrate == 0 {
+			continue
+		}
+
+		for _, limit := range s.limits {
+			if limit.MaxBitrate != 0 && bitrates[limit.Key] > limit.MaxBitrate &&
+				(found == nil || s.created.After(foundCreated)) {
+				found = author
+				foundCreated = s.created
+				break
+			}
+		}
+	}
+
+	return found
+}
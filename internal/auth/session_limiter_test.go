@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionLimiter(t *testing.T) {
+	var l SessionLimiter
+	l.Initialize()
+
+	pa := "mypath"
+	limits := []Limit{{Key: "user", MaxReaders: 1, MaxPublishers: 1}}
+
+	err := l.Reserve("reader1", pa, false, limits)
+	require.NoError(t, err)
+
+	err = l.Reserve("reader2", pa, false, limits)
+	require.EqualError(t, err, "maximum reader count of user reached")
+
+	err = l.Reserve("publisher1", pa, true, limits)
+	require.NoError(t, err)
+
+	err = l.Reserve("reader2", pa, false, []Limit{{Key: "other", MaxReaders: 1}})
+	require.NoError(t, err)
+
+	l.Release("reader1")
+
+	err = l.Reserve("reader3", pa, false, limits)
+	require.NoError(t, err)
+
+	l.ReleasePath(pa)
+	require.Empty(t, l.sessions)
+}
+
+type testBytesCounter struct {
+	received uint64
+	sent     uint64
+}
+
+func (c *testBytesCounter) BytesReceived() uint64 {
+	return c.received
+}
+
+func (c *testBytesCounter) BytesSent() uint64 {
+	return c.sent
+}
+
+func TestSessionLimiterCheck(t *testing.T) {
+	var l SessionLimiter
+	l.Initialize()
+
+	pa := "mypath"
+	limits := []Limit{{Key: "user", MaxBitrate: 1000}}
+
+	reader1 := &testBytesCounter{}
+	err := l.Reserve(reader1, pa, false, limits)
+	require.NoError(t, err)
+	l.Attach(reader1)
+
+	reader2 := &testBytesCounter{}
+	err = l.Reserve(reader2, pa, false, limits)
+	require.NoError(t, err)
+	l.Attach(reader2)
+
+	// sessions are charged for their own traffic only
+	publisher := &testBytesCounter{}
+	err = l.Reserve(publisher, pa, true, []Limit{{Key: "other", MaxBitrate: 1000}})
+	require.NoError(t, err)
+	l.Attach(publisher)
+
+	l.sessions[reader2].created = l.sessions[reader1].created.Add(time.Second)
+
+	now := time.Now().Add(10 * time.Second)
+
+	reader1.sent = 1000
+	reader2.sent = 500
+	publisher.received = 1000
+	publisher.sent = 100000
+
+	violations := l.Check(now)
+	require.Len(t, violations, 1)
+	require.Equal(t, reader2, violations[0].Author)
+	require.EqualError(t, violations[0].Err, "maximum bitrate of user exceeded")
+	require.Len(t, l.sessions, 2)
+
+	err = l.Reserve("reader3", pa, false, []Limit{{Key: "user", MaxBitrate: 500}})
+	require.EqualError(t, err, "maximum bitrate of user reached")
+}
+
+func TestSessionLimiterCheckTimeWindow(t *testing.T) {
+	var l SessionLimiter
+	l.Initialize()
+
+	pa := "mypath"
+	now := time.Date(2009, 5, 20, 22, 15, 25, 0, time.UTC)
+
+	err := l.Reserve("reader1", pa, false, []Limit{{
+		Key:         "user",
+		TimeWindows: conf.AuthTimeWindows{{Start: "08:00", End: "22:00"}},
+	}})
+	require.NoError(t, err)
+
+	err = l.Reserve("reader2", pa, false, []Limit{{
+		Key:         "other",
+		TimeWindows: conf.AuthTimeWindows{{Start: "22:00", End: "23:00"}},
+	}})
+	require.NoError(t, err)
+
+	violations := l.Check(now)
+	require.Len(t, violations, 1)
+	require.Equal(t, "reader1", violations[0].Author)
+	require.EqualError(t, violations[0].Err, "time window of user has ended")
+	require.Len(t, l.sessions, 1)
+}
@@ -8,16 +8,25 @@ import (
 
 // AuthInternalUserPermission is a permission of a user.
 type AuthInternalUserPermission struct {
-	Action AuthAction `json:"action"`
-	Path   string     `json:"path"`
+	Action        AuthAction      `json:"action"`
+	Path          string          `json:"path"`
+	MaxReaders    int             `json:"maxReaders"`
+	MaxPublishers int             `json:"maxPublishers"`
+	MaxBitrate    uint64          `json:"maxBitrate"`
+	TimeWindows   AuthTimeWindows `json:"timeWindows"`
 }
 
 // AuthInternalUser is an user.
 type AuthInternalUser struct {
-	User        Credential                   `json:"user"`
-	Pass        Credential                   `json:"pass"`
-	IPs         IPNetworks                   `json:"ips"`
-	Permissions []AuthInternalUserPermission `json:"permissions"`
+	User               Credential                   `json:"user"`
+	Pass               Credential                   `json:"pass"`
+	ClientCertIdentity string                       `json:"clientCertIdentity"`
+	IPs                IPNetworks                   `json:"ips"`
+	Permissions        []AuthInternalUserPermission `json:"permissions"`
+	MaxReaders         int                          `json:"maxReaders"`
+	MaxPublishers      int                          `json:"maxPublishers"`
+	MaxBitrate         uint64                       `json:"maxBitrate"`
+	TimeWindows        AuthTimeWindows              `json:"timeWindows"`
 }
 
 // UnmarshalJSON implements json.Unmarshaler.
@@ -36,6 +45,16 @@ func (d *AuthInternalUser) UnmarshalJSON(b []byte) error {
 		return fmt.Errorf("using a password with 'any' user is not supported")
 	}
 
+	if d.MaxReaders < 0 || d.MaxPublishers < 0 {
+		return fmt.Errorf("'maxReaders' and 'maxPublishers' must be positive")
+	}
+
+	for _, perm := range d.Permissions {
+		if perm.MaxReaders < 0 || perm.MaxPublishers < 0 {
+			return fmt.Errorf("'maxReaders' and 'maxPublishers' must be positive")
+		}
+	}
+
 	return nil
 }
 
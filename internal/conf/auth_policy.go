@@ -0,0 +1,23 @@
+package conf
+
+import (
+	"github.com/bluenviron/mediamtx/internal/auth/policy"
+	"github.com/bluenviron/mediamtx/internal/conf/jsonwrapper"
+)
+
+// AuthPolicy is a list of CEL expressions that authorize requests.
+type AuthPolicy []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *AuthPolicy) UnmarshalJSON(b []byte) error {
+	// remove default value before loading new value
+	// https://github.com/golang/go/issues/21092
+	*p = nil
+
+	if err := jsonwrapper.Unmarshal(b, (*[]string)(p)); err != nil {
+		return err
+	}
+
+	_, err := policy.Compile(*p)
+	return err
+}
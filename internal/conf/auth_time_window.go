@@ -0,0 +1,126 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf/jsonwrapper"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func parseTimeOfDay(v string) (time.Duration, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day '%s', expected HH:MM", v)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// AuthTimeWindow is a time window in which a user or permission can be used.
+type AuthTimeWindow struct {
+	Days  []string `json:"days"`
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (w *AuthTimeWindow) UnmarshalJSON(b []byte) error {
+	type alias AuthTimeWindow
+	if err := jsonwrapper.Unmarshal(b, (*alias)(w)); err != nil {
+		return err
+	}
+
+	for _, day := range w.Days {
+		if _, ok := weekdayNames[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid day '%s'", day)
+		}
+	}
+
+	if _, err := parseTimeOfDay(w.Start); err != nil {
+		return err
+	}
+
+	if _, err := parseTimeOfDay(w.End); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Contains checks whether a time is inside the window.
+// Windows whose end precedes their start span midnight.
+func (w AuthTimeWindow) Contains(t time.Time) bool {
+	start, err := parseTimeOfDay(w.Start)
+	if err != nil {
+		return false
+	}
+
+	end, err := parseTimeOfDay(w.End)
+	if err != nil {
+		return false
+	}
+
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	day := t.Weekday()
+
+	if end <= start {
+		if offset < end {
+			// the window started the day before
+			day = (day + 6) % 7
+		} else if offset < start {
+			return false
+		}
+	} else if offset < start || offset >= end {
+		return false
+	}
+
+	if len(w.Days) == 0 {
+		return true
+	}
+
+	for _, d := range w.Days {
+		if weekdayNames[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AuthTimeWindows is a list of AuthTimeWindow.
+type AuthTimeWindows []AuthTimeWindow
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *AuthTimeWindows) UnmarshalJSON(b []byte) error {
+	// remove default value before loading new value
+	// https://github.com/golang/go/issues/21092
+	*s = nil
+	return jsonwrapper.Unmarshal(b, (*[]AuthTimeWindow)(s))
+}
+
+// Contains checks whether a time is inside at least one of the windows.
+// An empty list contains any time.
+func (s AuthTimeWindows) Contains(t time.Time) bool {
+	if len(s) == 0 {
+		return true
+	}
+
+	for _, w := range s {
+		if w.Contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,59 @@
+package conf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthTimeWindow(t *testing.T) {
+	for _, ca := range []struct {
+		name     string
+		window   AuthTimeWindow
+		t        time.Time
+		contains bool
+	}{
+		{
+			"inside",
+			AuthTimeWindow{Start: "08:00", End: "18:00"},
+			time.Date(2025, 6, 2, 12, 0, 0, 0, time.Local),
+			true,
+		},
+		{
+			"outside",
+			AuthTimeWindow{Start: "08:00", End: "18:00"},
+			time.Date(2025, 6, 2, 18, 0, 0, 0, time.Local),
+			false,
+		},
+		{
+			"wrong day",
+			AuthTimeWindow{Days: []string{"tue"}, Start: "08:00", End: "18:00"},
+			time.Date(2025, 6, 2, 12, 0, 0, 0, time.Local),
+			false,
+		},
+		{
+			"across midnight",
+			AuthTimeWindow{Days: []string{"sun"}, Start: "22:00", End: "02:00"},
+			time.Date(2025, 6, 2, 1, 0, 0, 0, time.Local),
+			true,
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			require.Equal(t, ca.contains, ca.window.Contains(ca.t))
+		})
+	}
+}
+
+func TestAuthTimeWindowUnmarshal(t *testing.T) {
+	var w AuthTimeWindow
+	err := w.UnmarshalJSON([]byte(`{"days":["mon"],"start":"08:00","end":"18:00"}`))
+	require.NoError(t, err)
+	require.Equal(t, AuthTimeWindow{Days: []string{"mon"}, Start: "08:00", End: "18:00"}, w)
+
+	err = w.UnmarshalJSON([]byte(`{"days":["mon"],"start":"8","end":"18:00"}`))
+	require.EqualError(t, err, "invalid time of day '8', expected HH:MM")
+
+	err = w.UnmarshalJSON([]byte(`{"days":["xyz"],"start":"08:00","end":"18:00"}`))
+	require.EqualError(t, err, "invalid day 'xyz'")
+}
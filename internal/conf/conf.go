@@ -174,95 +174,110 @@ type Conf struct {
 	AuthJWTClaimKey           string                      `json:"authJWTClaimKey"`
 	AuthJWTExclude            AuthInternalUserPermissions `json:"authJWTExclude"`
 	AuthJWTInHTTPQuery        bool                        `json:"authJWTInHTTPQuery"`
+	AuthPolicy                AuthPolicy                  `json:"authPolicy"`
 
 	// Control API
-	API               bool       `json:"api"`
-	APIAddress        string     `json:"apiAddress"`
-	APIEncryption     bool       `json:"apiEncryption"`
-	APIServerKey      string     `json:"apiServerKey"`
-	APIServerCert     string     `json:"apiServerCert"`
-	APIAllowOrigin    string     `json:"apiAllowOrigin"`
-	APITrustedProxies IPNetworks `json:"apiTrustedProxies"`
+	API                     bool       `json:"api"`
+	APIAddress              string     `json:"apiAddress"`
+	APIEncryption           bool       `json:"apiEncryption"`
+	APIServerKey            string     `json:"apiServerKey"`
+	APIServerCert           string     `json:"apiServerCert"`
+	APIClientCA             string     `json:"apiClientCA"`
+	APIAllowOrigin          string     `json:"apiAllowOrigin"`
+	APITrustedProxies       IPNetworks `json:"apiTrustedProxies"`
+	APIProxyProtocolSources IPNetworks `json:"apiProxyProtocolSources"`
 
 	// Metrics
-	Metrics               bool       `json:"metrics"`
-	MetricsAddress        string     `json:"metricsAddress"`
-	MetricsEncryption     bool       `json:"metricsEncryption"`
-	MetricsServerKey      string     `json:"metricsServerKey"`
-	MetricsServerCert     string     `json:"metricsServerCert"`
-	MetricsAllowOrigin    string     `json:"metricsAllowOrigin"`
-	MetricsTrustedProxies IPNetworks `json:"metricsTrustedProxies"`
+	Metrics                     bool       `json:"metrics"`
+	MetricsAddress              string     `json:"metricsAddress"`
+	MetricsEncryption           bool       `json:"metricsEncryption"`
+	MetricsServerKey            string     `json:"metricsServerKey"`
+	MetricsServerCert           string     `json:"metricsServerCert"`
+	MetricsAllowOrigin          string     `json:"metricsAllowOrigin"`
+	MetricsTrustedProxies       IPNetworks `json:"metricsTrustedProxies"`
+	MetricsProxyProtocolSources IPNetworks `json:"metricsProxyProtocolSources"`
 
 	// PPROF
-	PPROF               bool       `json:"pprof"`
-	PPROFAddress        string     `json:"pprofAddress"`
-	PPROFEncryption     bool       `json:"pprofEncryption"`
-	PPROFServerKey      string     `json:"pprofServerKey"`
-	PPROFServerCert     string     `json:"pprofServerCert"`
-	PPROFAllowOrigin    string     `json:"pprofAllowOrigin"`
-	PPROFTrustedProxies IPNetworks `json:"pprofTrustedProxies"`
+	PPROF                     bool       `json:"pprof"`
+	PPROFAddress              string     `json:"pprofAddress"`
+	PPROFEncryption           bool       `json:"pprofEncryption"`
+	PPROFServerKey            string     `json:"pprofServerKey"`
+	PPROFServerCert           string     `json:"pprofServerCert"`
+	PPROFAllowOrigin          string     `json:"pprofAllowOrigin"`
+	PPROFTrustedProxies       IPNetworks `json:"pprofTrustedProxies"`
+	PPROFProxyProtocolSources IPNetworks `json:"pprofProxyProtocolSources"`
 
 	// Playback
-	Playback               bool       `json:"playback"`
-	PlaybackAddress        string     `json:"playbackAddress"`
-	PlaybackEncryption     bool       `json:"playbackEncryption"`
-	PlaybackServerKey      string     `json:"playbackServerKey"`
-	PlaybackServerCert     string     `json:"playbackServerCert"`
-	PlaybackAllowOrigin    string     `json:"playbackAllowOrigin"`
-	PlaybackTrustedProxies IPNetworks `json:"playbackTrustedProxies"`
+	Playback                     bool       `json:"playback"`
+	PlaybackAddress              string     `json:"playbackAddress"`
+	PlaybackEncryption           bool       `json:"playbackEncryption"`
+	PlaybackServerKey            string     `json:"playbackServerKey"`
+	PlaybackServerCert           string     `json:"playbackServerCert"`
+	PlaybackAllowOrigin          string     `json:"playbackAllowOrigin"`
+	PlaybackTrustedProxies       IPNetworks `json:"playbackTrustedProxies"`
+	PlaybackProxyProtocolSources IPNetworks `json:"playbackProxyProtocolSources"`
+	PlaybackExportDirectory      string     `json:"playbackExportDirectory"`
+	PlaybackExportTTL            Duration   `json:"playbackExportTTL"`
 
 	// RTSP server
-	RTSP                  bool             `json:"rtsp"`
-	RTSPDisable           *bool            `json:"rtspDisable,omitempty"` // deprecated
-	Protocols             *RTSPTransports  `json:"protocols,omitempty"`   // deprecated
-	RTSPTransports        RTSPTransports   `json:"rtspTransports"`
-	Encryption            *Encryption      `json:"encryption,omitempty"` // deprecated
-	RTSPEncryption        Encryption       `json:"rtspEncryption"`
-	RTSPAddress           string           `json:"rtspAddress"`
-	RTSPSAddress          string           `json:"rtspsAddress"`
-	RTPAddress            string           `json:"rtpAddress"`
-	RTCPAddress           string           `json:"rtcpAddress"`
-	MulticastIPRange      string           `json:"multicastIPRange"`
-	MulticastRTPPort      int              `json:"multicastRTPPort"`
-	MulticastRTCPPort     int              `json:"multicastRTCPPort"`
-	SRTPAddress           string           `json:"srtpAddress"`
-	SRTCPAddress          string           `json:"srtcpAddress"`
-	MulticastSRTPPort     int              `json:"multicastSRTPPort"`
-	MulticastSRTCPPort    int              `json:"multicastSRTCPPort"`
-	ServerKey             *string          `json:"serverKey,omitempty"`
-	ServerCert            *string          `json:"serverCert,omitempty"`
-	RTSPServerKey         string           `json:"rtspServerKey"`
-	RTSPServerCert        string           `json:"rtspServerCert"`
-	AuthMethods           *RTSPAuthMethods `json:"authMethods,omitempty"` // deprecated
-	RTSPAuthMethods       RTSPAuthMethods  `json:"rtspAuthMethods"`
-	RTSPUDPReadBufferSize uint             `json:"rtspUDPReadBufferSize"`
+	RTSP                     bool             `json:"rtsp"`
+	RTSPDisable              *bool            `json:"rtspDisable,omitempty"` // deprecated
+	Protocols                *RTSPTransports  `json:"protocols,omitempty"`   // deprecated
+	RTSPTransports           RTSPTransports   `json:"rtspTransports"`
+	Encryption               *Encryption      `json:"encryption,omitempty"` // deprecated
+	RTSPEncryption           Encryption       `json:"rtspEncryption"`
+	RTSPAddress              string           `json:"rtspAddress"`
+	RTSPSAddress             string           `json:"rtspsAddress"`
+	RTPAddress               string           `json:"rtpAddress"`
+	RTCPAddress              string           `json:"rtcpAddress"`
+	MulticastIPRange         string           `json:"multicastIPRange"`
+	MulticastRTPPort         int              `json:"multicastRTPPort"`
+	MulticastRTCPPort        int              `json:"multicastRTCPPort"`
+	SRTPAddress              string           `json:"srtpAddress"`
+	SRTCPAddress             string           `json:"srtcpAddress"`
+	MulticastSRTPPort        int              `json:"multicastSRTPPort"`
+	MulticastSRTCPPort       int              `json:"multicastSRTCPPort"`
+	ServerKey                *string          `json:"serverKey,omitempty"`
+	ServerCert               *string          `json:"serverCert,omitempty"`
+	RTSPServerKey            string           `json:"rtspServerKey"`
+	RTSPServerCert           string           `json:"rtspServerCert"`
+	RTSPClientCA             string           `json:"rtspClientCA"`
+	RTSPProxyProtocolSources IPNetworks       `json:"rtspProxyProtocolSources"`
+	AuthMethods              *RTSPAuthMethods `json:"authMethods,omitempty"` // deprecated
+	RTSPAuthMethods          RTSPAuthMethods  `json:"rtspAuthMethods"`
+	RTSPUDPReadBufferSize    uint             `json:"rtspUDPReadBufferSize"`
+	RTSPPlayback             bool             `json:"rtspPlayback"`
 
 	// RTMP server
-	RTMP           bool       `json:"rtmp"`
-	RTMPDisable    *bool      `json:"rtmpDisable,omitempty"` // deprecated
-	RTMPAddress    string     `json:"rtmpAddress"`
-	RTMPEncryption Encryption `json:"rtmpEncryption"`
-	RTMPSAddress   string     `json:"rtmpsAddress"`
-	RTMPServerKey  string     `json:"rtmpServerKey"`
-	RTMPServerCert string     `json:"rtmpServerCert"`
+	RTMP                     bool       `json:"rtmp"`
+	RTMPDisable              *bool      `json:"rtmpDisable,omitempty"` // deprecated
+	RTMPAddress              string     `json:"rtmpAddress"`
+	RTMPEncryption           Encryption `json:"rtmpEncryption"`
+	RTMPSAddress             string     `json:"rtmpsAddress"`
+	RTMPServerKey            string     `json:"rtmpServerKey"`
+	RTMPServerCert           string     `json:"rtmpServerCert"`
+	RTMPClientCA             string     `json:"rtmpClientCA"`
+	RTMPProxyProtocolSources IPNetworks `json:"rtmpProxyProtocolSources"`
 
 	// HLS server
-	HLS                bool       `json:"hls"`
-	HLSDisable         *bool      `json:"hlsDisable,omitempty"` // deprecated
-	HLSAddress         string     `json:"hlsAddress"`
-	HLSEncryption      bool       `json:"hlsEncryption"`
-	HLSServerKey       string     `json:"hlsServerKey"`
-	HLSServerCert      string     `json:"hlsServerCert"`
-	HLSAllowOrigin     string     `json:"hlsAllowOrigin"`
-	HLSTrustedProxies  IPNetworks `json:"hlsTrustedProxies"`
-	HLSAlwaysRemux     bool       `json:"hlsAlwaysRemux"`
-	HLSVariant         HLSVariant `json:"hlsVariant"`
-	HLSSegmentCount    int        `json:"hlsSegmentCount"`
-	HLSSegmentDuration Duration   `json:"hlsSegmentDuration"`
-	HLSPartDuration    Duration   `json:"hlsPartDuration"`
-	HLSSegmentMaxSize  StringSize `json:"hlsSegmentMaxSize"`
-	HLSDirectory       string     `json:"hlsDirectory"`
-	HLSMuxerCloseAfter Duration   `json:"hlsMuxerCloseAfter"`
+	HLS                     bool       `json:"hls"`
+	HLSDisable              *bool      `json:"hlsDisable,omitempty"` // deprecated
+	HLSAddress              string     `json:"hlsAddress"`
+	HLSEncryption           bool       `json:"hlsEncryption"`
+	HLSServerKey            string     `json:"hlsServerKey"`
+	HLSServerCert           string     `json:"hlsServerCert"`
+	HLSClientCA             string     `json:"hlsClientCA"`
+	HLSAllowOrigin          string     `json:"hlsAllowOrigin"`
+	HLSTrustedProxies       IPNetworks `json:"hlsTrustedProxies"`
+	HLSProxyProtocolSources IPNetworks `json:"hlsProxyProtocolSources"`
+	HLSAlwaysRemux          bool       `json:"hlsAlwaysRemux"`
+	HLSVariant              HLSVariant `json:"hlsVariant"`
+	HLSSegmentCount         int        `json:"hlsSegmentCount"`
+	HLSSegmentDuration      Duration   `json:"hlsSegmentDuration"`
+	HLSPartDuration         Duration   `json:"hlsPartDuration"`
+	HLSSegmentMaxSize       StringSize `json:"hlsSegmentMaxSize"`
+	HLSDirectory            string     `json:"hlsDirectory"`
+	HLSMuxerCloseAfter      Duration   `json:"hlsMuxerCloseAfter"`
 
 	// WebRTC server
 	WebRTC                      bool             `json:"webrtc"`
@@ -271,8 +286,10 @@ type Conf struct {
 	WebRTCEncryption            bool             `json:"webrtcEncryption"`
 	WebRTCServerKey             string           `json:"webrtcServerKey"`
 	WebRTCServerCert            string           `json:"webrtcServerCert"`
+	WebRTCClientCA              string           `json:"webrtcClientCA"`
 	WebRTCAllowOrigin           string           `json:"webrtcAllowOrigin"`
 	WebRTCTrustedProxies        IPNetworks       `json:"webrtcTrustedProxies"`
+	WebRTCProxyProtocolSources  IPNetworks       `json:"webrtcProxyProtocolSources"`
 	WebRTCLocalUDPAddress       string           `json:"webrtcLocalUDPAddress"`
 	WebRTCLocalTCPAddress       string           `json:"webrtcLocalTCPAddress"`
 	WebRTCIPsFromInterfaces     bool             `json:"webrtcIPsFromInterfaces"`
@@ -288,8 +305,13 @@ type Conf struct {
 	WebRTCICEServers            *[]string        `json:"webrtcICEServers,omitempty"`        // deprecated
 
 	// SRT server
-	SRT        bool   `json:"srt"`
-	SRTAddress string `json:"srtAddress"`
+	SRT                     bool       `json:"srt"`
+	SRTAddress              string     `json:"srtAddress"`
+	SRTProxyProtocolSources IPNetworks `json:"srtProxyProtocolSources"`
+
+	// Record
+	RecordTotalMaxSize StringSize `json:"recordTotalMaxSize"`
+	RecordMinFreeSpace StringSize `json:"recordMinFreeSpace"`
 
 	// Record (deprecated)
 	Record                *bool         `json:"record,omitempty"`                // deprecated
@@ -334,6 +356,7 @@ func (conf *Conf) setDefaults() {
 	conf.AuthJWTClaimKey = "mediamtx_permissions"
 	conf.AuthJWTExclude = []AuthInternalUserPermission{}
 	conf.AuthJWTInHTTPQuery = true
+	conf.AuthPolicy = AuthPolicy{}
 
 	// Control API
 	conf.APIAddress = ":9997"
@@ -358,6 +381,7 @@ func (conf *Conf) setDefaults() {
 	conf.PlaybackServerKey = "server.key"
 	conf.PlaybackServerCert = "server.crt"
 	conf.PlaybackAllowOrigin = "*"
+	conf.PlaybackExportTTL = 24 * Duration(time.Hour)
 
 	// RTSP server
 	conf.RTSP = true
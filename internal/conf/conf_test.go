@@ -58,6 +58,13 @@ func TestConfFromFile(t *testing.T) {
 			RecordMaxPartSize:            50 * 1024 * 1024,
 			RecordSegmentDuration:        3600000000000,
 			RecordDeleteAfter:            86400000000000,
+			RecordPreRoll:                10000000000,
+			RecordPostRoll:               10000000000,
+			RecordTracks:                 RecordTracks{},
+			RecordCopies:                 RecordCopies{},
+			RecordS3Region:               "us-east-1",
+			RecordS3Key:                  "%path/%Y-%m-%d_%H-%M-%S-%f",
+			RecordS3DeleteLocal:          true,
 			OverridePublisher:            true,
 			RPICameraWidth:               1920,
 			RPICameraHeight:              1080,
@@ -438,6 +445,44 @@ func TestConfErrors(t *testing.T) {
 				"    recordDeleteAfter: 20m\n",
 			`'recordDeleteAfter' cannot be lower than 'recordSegmentDuration'`,
 		},
+		{
+			"record segment align with invalid duration",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    recordSegmentDuration: 7h\n" +
+				"    recordSegmentAlign: yes\n",
+			"when 'recordSegmentAlign' is enabled, 'recordSegmentDuration' must be a divisor of 1 day",
+		},
+		{
+			"invalid record track selector",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    recordTracks: [track:0]\n",
+			"invalid track selector: 'track:0'",
+		},
+		{
+			"invalid record PID selector",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    recordTracks: [pid:9000]\n",
+			"invalid track selector: 'pid:9000'",
+		},
+		{
+			"record copy in same directory",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    recordCopies:\n" +
+				"      - recordPath: ./recordings/%path/%Y-%m-%d_%H-%M-%S-%f-copy\n",
+			"invalid record copy 1: 'recordPath' must be placed in a different directory " +
+				"than other recordings of the path",
+		},
+		{
+			"record encryption without keys",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    recordEncryption: yes\n",
+			`'recordEncryption' requires 'recordEncryptionKeyFile' or 'recordEncryptionKeyCommand'`,
+		},
 	} {
 		t.Run(ca.name, func(t *testing.T) {
 			tmpf, err := createTempFile([]byte(ca.conf))
@@ -114,6 +114,9 @@ type Path struct {
 	// General
 	Source                     string   `json:"source"`
 	SourceFingerprint          string   `json:"sourceFingerprint"`
+	SourceCA                   string   `json:"sourceCA"`
+	SourceClientCert           string   `json:"sourceClientCert"`
+	SourceClientKey            string   `json:"sourceClientKey"`
 	SourceOnDemand             bool     `json:"sourceOnDemand"`
 	SourceOnDemandStartTimeout Duration `json:"sourceOnDemandStartTimeout"`
 	SourceOnDemandCloseAfter   Duration `json:"sourceOnDemandCloseAfter"`
@@ -123,14 +126,33 @@ type Path struct {
 	UseAbsoluteTimestamp       bool     `json:"useAbsoluteTimestamp"`
 
 	// Record
-	Record                bool         `json:"record"`
-	Playback              *bool        `json:"playback,omitempty"` // deprecated
-	RecordPath            string       `json:"recordPath"`
-	RecordFormat          RecordFormat `json:"recordFormat"`
-	RecordPartDuration    Duration     `json:"recordPartDuration"`
-	RecordMaxPartSize     StringSize   `json:"recordMaxPartSize"`
-	RecordSegmentDuration Duration     `json:"recordSegmentDuration"`
-	RecordDeleteAfter     Duration     `json:"recordDeleteAfter"`
+	Record                     bool         `json:"record"`
+	Playback                   *bool        `json:"playback,omitempty"` // deprecated
+	RecordPath                 string       `json:"recordPath"`
+	RecordFormat               RecordFormat `json:"recordFormat"`
+	RecordPartDuration         Duration     `json:"recordPartDuration"`
+	RecordMaxPartSize          StringSize   `json:"recordMaxPartSize"`
+	RecordSegmentDuration      Duration     `json:"recordSegmentDuration"`
+	RecordSegmentAlign         bool         `json:"recordSegmentAlign"`
+	RecordKeyframeInterval     Duration     `json:"recordKeyframeInterval"`
+	RecordDeleteAfter          Duration     `json:"recordDeleteAfter"`
+	RecordMaxSize              StringSize   `json:"recordMaxSize"`
+	RecordTrigger              bool         `json:"recordTrigger"`
+	RecordPreRoll              Duration     `json:"recordPreRoll"`
+	RecordPostRoll             Duration     `json:"recordPostRoll"`
+	RecordTracks               RecordTracks `json:"recordTracks"`
+	RecordCopies               RecordCopies `json:"recordCopies"`
+	RecordS3Upload             bool         `json:"recordS3Upload"`
+	RecordS3Endpoint           string       `json:"recordS3Endpoint"`
+	RecordS3Region             string       `json:"recordS3Region"`
+	RecordS3Bucket             string       `json:"recordS3Bucket"`
+	RecordS3AccessKeyID        string       `json:"recordS3AccessKeyID"`
+	RecordS3SecretKey          string       `json:"recordS3SecretKey"`
+	RecordS3Key                string       `json:"recordS3Key"`
+	RecordS3DeleteLocal        bool         `json:"recordS3DeleteLocal"`
+	RecordEncryption           bool         `json:"recordEncryption"`
+	RecordEncryptionKeyFile    string       `json:"recordEncryptionKeyFile"`
+	RecordEncryptionKeyCommand string       `json:"recordEncryptionKeyCommand"`
 
 	// Authentication (deprecated)
 	PublishUser *Credential `json:"publishUser,omitempty"` // deprecated
@@ -244,6 +266,13 @@ func (pconf *Path) setDefaults() {
 	pconf.RecordMaxPartSize = 50 * 1024 * 1024
 	pconf.RecordSegmentDuration = 3600 * Duration(time.Second)
 	pconf.RecordDeleteAfter = 24 * 3600 * Duration(time.Second)
+	pconf.RecordPreRoll = 10 * Duration(time.Second)
+	pconf.RecordPostRoll = 10 * Duration(time.Second)
+	pconf.RecordTracks = RecordTracks{}
+	pconf.RecordCopies = RecordCopies{}
+	pconf.RecordS3Region = "us-east-1"
+	pconf.RecordS3Key = "%path/%Y-%m-%d_%H-%M-%S-%f"
+	pconf.RecordS3DeleteLocal = true
 
 	// Publisher source
 	pconf.OverridePublisher = true
@@ -354,6 +383,14 @@ func (pconf *Path) validate(
 		return fmt.Errorf("'sourceRedirect' is useless when source is not 'redirect'")
 	}
 
+	if pconf.SourceFingerprint != "" && pconf.SourceCA != "" {
+		return fmt.Errorf("'sourceFingerprint' and 'sourceCA' cannot be used together")
+	}
+
+	if (pconf.SourceClientCert != "") != (pconf.SourceClientKey != "") {
+		return fmt.Errorf("'sourceClientCert' and 'sourceClientKey' must be both provided")
+	}
+
 	// source-dependent settings
 
 	switch {
@@ -466,6 +503,16 @@ func (pconf *Path) validate(
 			return fmt.Errorf("'%s' is not a valid URL", pconf.Source)
 		}
 
+	case strings.HasPrefix(pconf.Source, "record://"):
+		u, err := url.Parse(pconf.Source)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a valid URL", pconf.Source)
+		}
+
+		if u.Host == "" {
+			return fmt.Errorf("'%s' does not contain a path name", pconf.Source)
+		}
+
 	case pconf.Source == "redirect":
 		if pconf.SourceRedirect == "" {
 			return fmt.Errorf("source redirect must be filled")
@@ -652,32 +699,72 @@ func (pconf *Path) validate(
 		l.Log(logger.Warn, "parameter 'playback' is deprecated and has no effect")
 	}
 
-	if !strings.Contains(pconf.RecordPath, "%path") {
-		return fmt.Errorf("'recordPath' must contain %%path")
+	err := validateRecordPath(pconf.RecordPath, conf.Playback)
+	if err != nil {
+		return err
 	}
 
-	if !strings.Contains(pconf.RecordPath, "%s") &&
-		(!strings.Contains(pconf.RecordPath, "%Y") ||
-			!strings.Contains(pconf.RecordPath, "%m") ||
-			!strings.Contains(pconf.RecordPath, "%d") ||
-			!strings.Contains(pconf.RecordPath, "%H") ||
-			!strings.Contains(pconf.RecordPath, "%M") ||
-			!strings.Contains(pconf.RecordPath, "%S")) {
-		return fmt.Errorf("'recordPath' must contain either %%s or %%Y %%m %%d %%H %%M %%S")
+	if pconf.RecordSegmentDuration > Duration(24*time.Hour) { // avoid overflowing DurationV0 of mvhd
+		return fmt.Errorf("maximum segment duration is 1 day")
 	}
 
-	if conf.Playback && !strings.Contains(pconf.RecordPath, "%f") {
-		return fmt.Errorf("'recordPath' must contain %%f")
+	if pconf.RecordSegmentAlign && (pconf.RecordSegmentDuration <= 0 ||
+		(Duration(24*time.Hour)%pconf.RecordSegmentDuration) != 0) {
+		return fmt.Errorf("when 'recordSegmentAlign' is enabled, 'recordSegmentDuration' must be a divisor of 1 day")
 	}
 
-	if pconf.RecordSegmentDuration > Duration(24*time.Hour) { // avoid overflowing DurationV0 of mvhd
-		return fmt.Errorf("maximum segment duration is 1 day")
+	if pconf.RecordKeyframeInterval < 0 {
+		return fmt.Errorf("'recordKeyframeInterval' cannot be negative")
 	}
 
 	if pconf.RecordDeleteAfter != 0 && pconf.RecordDeleteAfter < pconf.RecordSegmentDuration {
 		return fmt.Errorf("'recordDeleteAfter' cannot be lower than 'recordSegmentDuration'")
 	}
 
+	err = pconf.RecordTracks.validate()
+	if err != nil {
+		return err
+	}
+
+	err = pconf.validateRecordCopies(conf.Playback)
+	if err != nil {
+		return err
+	}
+
+	if pconf.RecordTrigger && pconf.RecordPostRoll <= 0 {
+		return fmt.Errorf("'recordPostRoll' must be greater than zero")
+	}
+
+	if pconf.RecordS3Upload {
+		if !strings.HasPrefix(pconf.RecordS3Endpoint, "http://") &&
+			!strings.HasPrefix(pconf.RecordS3Endpoint, "https://") {
+			return fmt.Errorf("'recordS3Endpoint' must be a HTTP or HTTPS URL")
+		}
+
+		if pconf.RecordS3Bucket == "" {
+			return fmt.Errorf("'recordS3Bucket' is required")
+		}
+
+		if !strings.Contains(pconf.RecordS3Key, "%s") &&
+			(!strings.Contains(pconf.RecordS3Key, "%Y") ||
+				!strings.Contains(pconf.RecordS3Key, "%m") ||
+				!strings.Contains(pconf.RecordS3Key, "%d") ||
+				!strings.Contains(pconf.RecordS3Key, "%H") ||
+				!strings.Contains(pconf.RecordS3Key, "%M") ||
+				!strings.Contains(pconf.RecordS3Key, "%S")) {
+			return fmt.Errorf("'recordS3Key' must contain either %%s or %%Y %%m %%d %%H %%M %%S")
+		}
+	}
+
+	if pconf.RecordEncryptionKeyFile != "" && pconf.RecordEncryptionKeyCommand != "" {
+		return fmt.Errorf("'recordEncryptionKeyFile' and 'recordEncryptionKeyCommand' cannot be used together")
+	}
+
+	if pconf.RecordEncryption &&
+		pconf.RecordEncryptionKeyFile == "" && pconf.RecordEncryptionKeyCommand == "" {
+		return fmt.Errorf("'recordEncryption' requires 'recordEncryptionKeyFile' or 'recordEncryptionKeyCommand'")
+	}
+
 	// Authentication (deprecated)
 
 	if deprecatedCredentialsMode {
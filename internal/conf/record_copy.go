@@ -0,0 +1,185 @@
+package conf
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf/jsonwrapper"
+)
+
+// RecordTracks is the recordTracks parameter.
+type RecordTracks []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *RecordTracks) UnmarshalJSON(b []byte) error {
+	// remove default value before loading new value
+	// https://github.com/golang/go/issues/21092
+	*s = nil
+	return jsonwrapper.Unmarshal(b, (*[]string)(s))
+}
+
+func (s RecordTracks) validate() error {
+	for _, sel := range s {
+		switch {
+		case sel == "video" || sel == "audio" || sel == "application":
+
+		case strings.HasPrefix(sel, "track:"):
+			n, err := strconv.ParseUint(strings.TrimPrefix(sel, "track:"), 10, 31)
+			if err != nil || n == 0 {
+				return fmt.Errorf("invalid track selector: '%s'", sel)
+			}
+
+		case strings.HasPrefix(sel, "codec:"):
+			if strings.TrimPrefix(sel, "codec:") == "" {
+				return fmt.Errorf("invalid track selector: '%s'", sel)
+			}
+
+		case strings.HasPrefix(sel, "pid:"):
+			n, err := strconv.ParseUint(strings.TrimPrefix(sel, "pid:"), 10, 16)
+			if err != nil || n > 0x1FFF {
+				return fmt.Errorf("invalid track selector: '%s'", sel)
+			}
+
+		case strings.HasPrefix(sel, "lang:"):
+			if strings.TrimPrefix(sel, "lang:") == "" {
+				return fmt.Errorf("invalid track selector: '%s'", sel)
+			}
+
+		default:
+			return fmt.Errorf("invalid track selector: '%s'", sel)
+		}
+	}
+
+	return nil
+}
+
+// RecordCopy is an additional recording of a path, with its own parameters.
+type RecordCopy struct {
+	RecordPath            string       `json:"recordPath"`
+	RecordFormat          RecordFormat `json:"recordFormat"`
+	RecordSegmentDuration Duration     `json:"recordSegmentDuration"`
+	RecordDeleteAfter     Duration     `json:"recordDeleteAfter"`
+	RecordMaxSize         StringSize   `json:"recordMaxSize"`
+	RecordTracks          RecordTracks `json:"recordTracks"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *RecordCopy) UnmarshalJSON(b []byte) error {
+	d.RecordFormat = RecordFormatFMP4
+	d.RecordSegmentDuration = 3600 * Duration(time.Second)
+	d.RecordDeleteAfter = 24 * 3600 * Duration(time.Second)
+	d.RecordTracks = RecordTracks{}
+
+	type alias RecordCopy
+	return jsonwrapper.Unmarshal(b, (*alias)(d))
+}
+
+// RecordCopies is a list of RecordCopy.
+type RecordCopies []RecordCopy
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *RecordCopies) UnmarshalJSON(b []byte) error {
+	// remove default value before loading new value
+	// https://github.com/golang/go/issues/21092
+	*s = nil
+	return jsonwrapper.Unmarshal(b, (*[]RecordCopy)(s))
+}
+
+// recordPathBaseDir returns the directory that contains all segments of a record path.
+func recordPathBaseDir(recordPath string) string {
+	i := strings.Index(recordPath, "%")
+	if i < 0 {
+		return filepath.Clean(filepath.Dir(recordPath))
+	}
+	return filepath.Clean(filepath.Dir(recordPath[:i+1]))
+}
+
+func validateRecordPath(recordPath string, playback bool) error {
+	if !strings.Contains(recordPath, "%path") {
+		return fmt.Errorf("'recordPath' must contain %%path")
+	}
+
+	if !strings.Contains(recordPath, "%s") &&
+		(!strings.Contains(recordPath, "%Y") ||
+			!strings.Contains(recordPath, "%m") ||
+			!strings.Contains(recordPath, "%d") ||
+			!strings.Contains(recordPath, "%H") ||
+			!strings.Contains(recordPath, "%M") ||
+			!strings.Contains(recordPath, "%S")) {
+		return fmt.Errorf("'recordPath' must contain either %%s or %%Y %%m %%d %%H %%M %%S")
+	}
+
+	if playback && !strings.Contains(recordPath, "%f") {
+		return fmt.Errorf("'recordPath' must contain %%f")
+	}
+
+	return nil
+}
+
+func (pconf *Path) validateRecordCopies(playback bool) error {
+	baseDirs := map[string]struct{}{
+		recordPathBaseDir(pconf.RecordPath): {},
+	}
+
+	for i, c := range pconf.RecordCopies {
+		err := validateRecordPath(c.RecordPath, playback)
+		if err != nil {
+			return fmt.Errorf("invalid record copy %d: %w", i+1, err)
+		}
+
+		// copies share the name of the path, therefore they need a separate index.
+		baseDir := recordPathBaseDir(c.RecordPath)
+		if _, ok := baseDirs[baseDir]; ok {
+			return fmt.Errorf("invalid record copy %d: 'recordPath' must be placed in a different directory "+
+				"than other recordings of the path", i+1)
+		}
+		baseDirs[baseDir] = struct{}{}
+
+		if c.RecordSegmentDuration <= 0 || c.RecordSegmentDuration > Duration(24*time.Hour) {
+			return fmt.Errorf("invalid record copy %d: 'recordSegmentDuration' must be between 0 and 1 day", i+1)
+		}
+
+		if pconf.RecordSegmentAlign && (Duration(24*time.Hour)%c.RecordSegmentDuration) != 0 {
+			return fmt.Errorf("invalid record copy %d: when 'recordSegmentAlign' is enabled, "+
+				"'recordSegmentDuration' must be a divisor of 1 day", i+1)
+		}
+
+		if c.RecordDeleteAfter != 0 && c.RecordDeleteAfter < c.RecordSegmentDuration {
+			return fmt.Errorf("invalid record copy %d: 'recordDeleteAfter' cannot be lower than "+
+				"'recordSegmentDuration'", i+1)
+		}
+
+		err = c.RecordTracks.validate()
+		if err != nil {
+			return fmt.Errorf("invalid record copy %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// RecordRenditions returns configurations of all recordings of the path:
+// the main recording, followed by copies, where recording parameters
+// are replaced with the ones of each copy.
+func (pconf *Path) RecordRenditions() []*Path {
+	out := []*Path{pconf}
+
+	for _, c := range pconf.RecordCopies {
+		cpy := pconf.Clone()
+		cpy.RecordPath = c.RecordPath
+		cpy.RecordFormat = c.RecordFormat
+		cpy.RecordSegmentDuration = c.RecordSegmentDuration
+		cpy.RecordDeleteAfter = c.RecordDeleteAfter
+		cpy.RecordMaxSize = c.RecordMaxSize
+		cpy.RecordTracks = c.RecordTracks
+		cpy.RecordCopies = nil
+		// copies are not uploaded to the object storage.
+		cpy.RecordS3Upload = false
+		out = append(out, cpy)
+	}
+
+	return out
+}
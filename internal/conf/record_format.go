@@ -14,6 +14,8 @@ type RecordFormat int
 const (
 	RecordFormatFMP4 RecordFormat = iota
 	RecordFormatMPEGTS
+	RecordFormatMKV
+	RecordFormatWebM
 )
 
 // MarshalJSON implements json.Marshaler.
@@ -24,6 +26,12 @@ func (d RecordFormat) MarshalJSON() ([]byte, error) {
 	case RecordFormatMPEGTS:
 		out = "mpegts"
 
+	case RecordFormatMKV:
+		out = "mkv"
+
+	case RecordFormatWebM:
+		out = "webm"
+
 	default:
 		out = "fmp4"
 	}
@@ -45,6 +53,12 @@ func (d *RecordFormat) UnmarshalJSON(b []byte) error {
 	case "fmp4":
 		*d = RecordFormatFMP4
 
+	case "mkv":
+		*d = RecordFormatMKV
+
+	case "webm":
+		*d = RecordFormatWebM
+
 	default:
 		return fmt.Errorf("invalid record format '%s'", in)
 	}
@@ -614,13 +614,14 @@ func TestAPIProtocolListGet(t *testing.T) {
 					"itemCount": float64(1),
 					"items": []interface{}{
 						map[string]interface{}{
-							"bytesReceived": out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesReceived"],
-							"bytesSent":     out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesSent"],
-							"created":       out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["created"],
-							"id":            out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["id"],
-							"remoteAddr":    out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["remoteAddr"],
-							"session":       out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["session"],
-							"tunnel":        "none",
+							"bytesReceived":      out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesReceived"],
+							"bytesSent":          out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesSent"],
+							"clientCertIdentity": nil,
+							"created":            out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["created"],
+							"id":                 out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["id"],
+							"remoteAddr":         out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["remoteAddr"],
+							"session":            out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["session"],
+							"tunnel":             "none",
 						},
 					},
 				}, out1)
@@ -633,6 +634,7 @@ func TestAPIProtocolListGet(t *testing.T) {
 						map[string]interface{}{
 							"bytesReceived":       float64(0),
 							"bytesSent":           out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesSent"],
+							"clientCertIdentity":  nil,
 							"created":             out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["created"],
 							"id":                  out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["id"],
 							"path":                "mypath",
@@ -659,13 +661,14 @@ func TestAPIProtocolListGet(t *testing.T) {
 					"itemCount": float64(1),
 					"items": []interface{}{
 						map[string]interface{}{
-							"bytesReceived": out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesReceived"],
-							"bytesSent":     out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesSent"],
-							"created":       out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["created"],
-							"id":            out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["id"],
-							"remoteAddr":    out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["remoteAddr"],
-							"session":       out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["session"],
-							"tunnel":        "none",
+							"bytesReceived":      out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesReceived"],
+							"bytesSent":          out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesSent"],
+							"clientCertIdentity": nil,
+							"created":            out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["created"],
+							"id":                 out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["id"],
+							"remoteAddr":         out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["remoteAddr"],
+							"session":            out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["session"],
+							"tunnel":             "none",
 						},
 					},
 				}, out1)
@@ -678,6 +681,7 @@ func TestAPIProtocolListGet(t *testing.T) {
 						map[string]interface{}{
 							"bytesReceived":       float64(0),
 							"bytesSent":           out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesSent"],
+							"clientCertIdentity":  nil,
 							"created":             out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["created"],
 							"id":                  out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["id"],
 							"path":                "mypath",
@@ -704,14 +708,15 @@ func TestAPIProtocolListGet(t *testing.T) {
 					"itemCount": float64(1),
 					"items": []interface{}{
 						map[string]interface{}{
-							"bytesReceived": out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesReceived"],
-							"bytesSent":     out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesSent"],
-							"created":       out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["created"],
-							"id":            out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["id"],
-							"path":          "mypath",
-							"query":         "key=val",
-							"remoteAddr":    out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["remoteAddr"],
-							"state":         "publish",
+							"bytesReceived":      out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesReceived"],
+							"bytesSent":          out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesSent"],
+							"clientCertIdentity": nil,
+							"created":            out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["created"],
+							"id":                 out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["id"],
+							"path":               "mypath",
+							"query":              "key=val",
+							"remoteAddr":         out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["remoteAddr"],
+							"state":              "publish",
 						},
 					},
 				}, out1)
@@ -722,14 +727,15 @@ func TestAPIProtocolListGet(t *testing.T) {
 					"itemCount": float64(1),
 					"items": []interface{}{
 						map[string]interface{}{
-							"bytesReceived": out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesReceived"],
-							"bytesSent":     out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesSent"],
-							"created":       out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["created"],
-							"id":            out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["id"],
-							"path":          "mypath",
-							"query":         "key=val",
-							"remoteAddr":    out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["remoteAddr"],
-							"state":         "publish",
+							"bytesReceived":      out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesReceived"],
+							"bytesSent":          out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesSent"],
+							"clientCertIdentity": nil,
+							"created":            out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["created"],
+							"id":                 out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["id"],
+							"path":               "mypath",
+							"query":              "key=val",
+							"remoteAddr":         out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["remoteAddr"],
+							"state":              "publish",
 						},
 					},
 				}, out1)
@@ -756,6 +762,7 @@ func TestAPIProtocolListGet(t *testing.T) {
 						map[string]interface{}{
 							"bytesReceived":             out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesReceived"],
 							"bytesSent":                 out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesSent"],
+							"clientCertIdentity":        nil,
 							"created":                   out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["created"],
 							"id":                        out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["id"],
 							"localCandidate":            out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["localCandidate"],
@@ -28,6 +28,8 @@ import (
 	"github.com/bluenviron/mediamtx/internal/playback"
 	"github.com/bluenviron/mediamtx/internal/pprof"
 	"github.com/bluenviron/mediamtx/internal/recordcleaner"
+	"github.com/bluenviron/mediamtx/internal/recordrepairer"
+	"github.com/bluenviron/mediamtx/internal/recorduploader"
 	"github.com/bluenviron/mediamtx/internal/rlimit"
 	"github.com/bluenviron/mediamtx/internal/servers/hls"
 	"github.com/bluenviron/mediamtx/internal/servers/rtmp"
@@ -69,6 +71,31 @@ func atLeastOneRecordDeleteAfter(pathConfs map[string]*conf.Path) bool {
 	return false
 }
 
+func atLeastOneRecordMaxSize(pathConfs map[string]*conf.Path) bool {
+	for _, e := range pathConfs {
+		if e.RecordMaxSize != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func recordCleanerNeeded(cnf *conf.Conf) bool {
+	return atLeastOneRecordDeleteAfter(cnf.Paths) ||
+		atLeastOneRecordMaxSize(cnf.Paths) ||
+		cnf.RecordTotalMaxSize != 0 ||
+		cnf.RecordMinFreeSpace != 0
+}
+
+func recordUploaderNeeded(cnf *conf.Conf) bool {
+	for _, e := range cnf.Paths {
+		if e.RecordS3Upload {
+			return true
+		}
+	}
+	return false
+}
+
 func getRTPMaxPayloadSize(udpMaxPayloadSize int, rtspEncryption conf.Encryption) int {
 	// UDP max payload size - 12 (RTP header)
 	v := udpMaxPayloadSize - 12
@@ -92,7 +119,9 @@ type Core struct {
 	authManager     *auth.Manager
 	metrics         *metrics.Metrics
 	pprof           *pprof.PPROF
+	recordRepairer  *recordrepairer.Repairer
 	recordCleaner   *recordcleaner.Cleaner
+	recordUploader  *recorduploader.Uploader
 	playbackServer  *playback.Server
 	pathManager     *pathManager
 	rtspServer      *rtsp.Server
@@ -312,6 +341,7 @@ func (p *Core) createResources(initial bool) error {
 			JWTClaimKey:        p.conf.AuthJWTClaimKey,
 			JWTExclude:         p.conf.AuthJWTExclude,
 			JWTInHTTPQuery:     p.conf.AuthJWTInHTTPQuery,
+			Policy:             p.conf.AuthPolicy,
 			ReadTimeout:        time.Duration(p.conf.ReadTimeout),
 		}
 	}
@@ -319,16 +349,17 @@ func (p *Core) createResources(initial bool) error {
 	if p.conf.Metrics &&
 		p.metrics == nil {
 		i := &metrics.Metrics{
-			Address:        p.conf.MetricsAddress,
-			Encryption:     p.conf.MetricsEncryption,
-			ServerKey:      p.conf.MetricsServerKey,
-			ServerCert:     p.conf.MetricsServerCert,
-			AllowOrigin:    p.conf.MetricsAllowOrigin,
-			TrustedProxies: p.conf.MetricsTrustedProxies,
-			ReadTimeout:    p.conf.ReadTimeout,
-			WriteTimeout:   p.conf.WriteTimeout,
-			AuthManager:    p.authManager,
-			Parent:         p,
+			Address:              p.conf.MetricsAddress,
+			Encryption:           p.conf.MetricsEncryption,
+			ServerKey:            p.conf.MetricsServerKey,
+			ServerCert:           p.conf.MetricsServerCert,
+			AllowOrigin:          p.conf.MetricsAllowOrigin,
+			TrustedProxies:       p.conf.MetricsTrustedProxies,
+			ProxyProtocolSources: p.conf.MetricsProxyProtocolSources,
+			ReadTimeout:          p.conf.ReadTimeout,
+			WriteTimeout:         p.conf.WriteTimeout,
+			AuthManager:          p.authManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -340,16 +371,17 @@ func (p *Core) createResources(initial bool) error {
 	if p.conf.PPROF &&
 		p.pprof == nil {
 		i := &pprof.PPROF{
-			Address:        p.conf.PPROFAddress,
-			Encryption:     p.conf.PPROFEncryption,
-			ServerKey:      p.conf.PPROFServerKey,
-			ServerCert:     p.conf.PPROFServerCert,
-			AllowOrigin:    p.conf.PPROFAllowOrigin,
-			TrustedProxies: p.conf.PPROFTrustedProxies,
-			ReadTimeout:    p.conf.ReadTimeout,
-			WriteTimeout:   p.conf.WriteTimeout,
-			AuthManager:    p.authManager,
-			Parent:         p,
+			Address:              p.conf.PPROFAddress,
+			Encryption:           p.conf.PPROFEncryption,
+			ServerKey:            p.conf.PPROFServerKey,
+			ServerCert:           p.conf.PPROFServerCert,
+			AllowOrigin:          p.conf.PPROFAllowOrigin,
+			TrustedProxies:       p.conf.PPROFTrustedProxies,
+			ProxyProtocolSources: p.conf.PPROFProxyProtocolSources,
+			ReadTimeout:          p.conf.ReadTimeout,
+			WriteTimeout:         p.conf.WriteTimeout,
+			AuthManager:          p.authManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -358,35 +390,35 @@ func (p *Core) createResources(initial bool) error {
 		p.pprof = i
 	}
 
-	if p.recordCleaner == nil &&
-		atLeastOneRecordDeleteAfter(p.conf.Paths) {
-		p.recordCleaner = &recordcleaner.Cleaner{
+	// find segments left incomplete by an abrupt termination before recorders start,
+	// and repair them in the background
+	if initial && p.recordRepairer == nil {
+		p.recordRepairer = &recordrepairer.Repairer{
 			PathConfs: p.conf.Paths,
 			Parent:    p,
 		}
-		p.recordCleaner.Initialize()
+		p.recordRepairer.Initialize()
 	}
 
-	if p.conf.Playback &&
-		p.playbackServer == nil {
-		i := &playback.Server{
-			Address:        p.conf.PlaybackAddress,
-			Encryption:     p.conf.PlaybackEncryption,
-			ServerKey:      p.conf.PlaybackServerKey,
-			ServerCert:     p.conf.PlaybackServerCert,
-			AllowOrigin:    p.conf.PlaybackAllowOrigin,
-			TrustedProxies: p.conf.PlaybackTrustedProxies,
-			ReadTimeout:    p.conf.ReadTimeout,
-			WriteTimeout:   p.conf.WriteTimeout,
-			PathConfs:      p.conf.Paths,
-			AuthManager:    p.authManager,
-			Parent:         p,
+	if p.recordCleaner == nil &&
+		recordCleanerNeeded(p.conf) {
+		p.recordCleaner = &recordcleaner.Cleaner{
+			PathConfs:    p.conf.Paths,
+			TotalMaxSize: p.conf.RecordTotalMaxSize,
+			MinFreeSpace: p.conf.RecordMinFreeSpace,
+			Metrics:      p.metrics,
+			Parent:       p,
 		}
-		err = i.Initialize()
-		if err != nil {
-			return err
+		p.recordCleaner.Initialize()
+	}
+
+	if p.recordUploader == nil &&
+		recordUploaderNeeded(p.conf) {
+		p.recordUploader = &recorduploader.Uploader{
+			PathConfs: p.conf.Paths,
+			Parent:    p,
 		}
-		p.playbackServer = i
+		p.recordUploader.Initialize()
 	}
 
 	if p.pathManager == nil {
@@ -408,6 +440,32 @@ func (p *Core) createResources(initial bool) error {
 		p.pathManager.initialize()
 	}
 
+	if p.conf.Playback &&
+		p.playbackServer == nil {
+		i := &playback.Server{
+			Address:              p.conf.PlaybackAddress,
+			Encryption:           p.conf.PlaybackEncryption,
+			ServerKey:            p.conf.PlaybackServerKey,
+			ServerCert:           p.conf.PlaybackServerCert,
+			AllowOrigin:          p.conf.PlaybackAllowOrigin,
+			TrustedProxies:       p.conf.PlaybackTrustedProxies,
+			ProxyProtocolSources: p.conf.PlaybackProxyProtocolSources,
+			ReadTimeout:          p.conf.ReadTimeout,
+			WriteTimeout:         p.conf.WriteTimeout,
+			ExportDirectory:      p.conf.PlaybackExportDirectory,
+			ExportTTL:            p.conf.PlaybackExportTTL,
+			PathConfs:            p.conf.Paths,
+			AuthManager:          p.authManager,
+			PathManager:          p.pathManager,
+			Parent:               p,
+		}
+		err = i.Initialize()
+		if err != nil {
+			return err
+		}
+		p.playbackServer = i
+	}
+
 	if p.conf.RTSP &&
 		(p.conf.RTSPEncryption == conf.EncryptionNo ||
 			p.conf.RTSPEncryption == conf.EncryptionOptional) &&
@@ -416,31 +474,34 @@ func (p *Core) createResources(initial bool) error {
 		_, useMulticast := p.conf.RTSPTransports[gortsplib.ProtocolUDPMulticast]
 
 		i := &rtsp.Server{
-			Address:             p.conf.RTSPAddress,
-			AuthMethods:         p.conf.RTSPAuthMethods,
-			UDPReadBufferSize:   p.conf.RTSPUDPReadBufferSize,
-			ReadTimeout:         p.conf.ReadTimeout,
-			WriteTimeout:        p.conf.WriteTimeout,
-			WriteQueueSize:      p.conf.WriteQueueSize,
-			UseUDP:              useUDP,
-			UseMulticast:        useMulticast,
-			RTPAddress:          p.conf.RTPAddress,
-			RTCPAddress:         p.conf.RTCPAddress,
-			MulticastIPRange:    p.conf.MulticastIPRange,
-			MulticastRTPPort:    p.conf.MulticastRTPPort,
-			MulticastRTCPPort:   p.conf.MulticastRTCPPort,
-			IsTLS:               false,
-			ServerCert:          "",
-			ServerKey:           "",
-			RTSPAddress:         p.conf.RTSPAddress,
-			Transports:          p.conf.RTSPTransports,
-			RunOnConnect:        p.conf.RunOnConnect,
-			RunOnConnectRestart: p.conf.RunOnConnectRestart,
-			RunOnDisconnect:     p.conf.RunOnDisconnect,
-			ExternalCmdPool:     p.externalCmdPool,
-			Metrics:             p.metrics,
-			PathManager:         p.pathManager,
-			Parent:              p,
+			Address:              p.conf.RTSPAddress,
+			AuthMethods:          p.conf.RTSPAuthMethods,
+			UDPReadBufferSize:    p.conf.RTSPUDPReadBufferSize,
+			ReadTimeout:          p.conf.ReadTimeout,
+			WriteTimeout:         p.conf.WriteTimeout,
+			WriteQueueSize:       p.conf.WriteQueueSize,
+			UseUDP:               useUDP,
+			UseMulticast:         useMulticast,
+			RTPAddress:           p.conf.RTPAddress,
+			RTCPAddress:          p.conf.RTCPAddress,
+			MulticastIPRange:     p.conf.MulticastIPRange,
+			MulticastRTPPort:     p.conf.MulticastRTPPort,
+			MulticastRTCPPort:    p.conf.MulticastRTCPPort,
+			IsTLS:                false,
+			ServerCert:           "",
+			ServerKey:            "",
+			ProxyProtocolSources: p.conf.RTSPProxyProtocolSources,
+			RTSPAddress:          p.conf.RTSPAddress,
+			Transports:           p.conf.RTSPTransports,
+			Playback:             p.conf.RTSPPlayback,
+			RTPMaxPayloadSize:    getRTPMaxPayloadSize(p.conf.UDPMaxPayloadSize, p.conf.RTSPEncryption),
+			RunOnConnect:         p.conf.RunOnConnect,
+			RunOnConnectRestart:  p.conf.RunOnConnectRestart,
+			RunOnDisconnect:      p.conf.RunOnDisconnect,
+			ExternalCmdPool:      p.externalCmdPool,
+			Metrics:              p.metrics,
+			PathManager:          p.pathManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -457,31 +518,35 @@ func (p *Core) createResources(initial bool) error {
 		_, useMulticast := p.conf.RTSPTransports[gortsplib.ProtocolUDPMulticast]
 
 		i := &rtsp.Server{
-			Address:             p.conf.RTSPSAddress,
-			AuthMethods:         p.conf.RTSPAuthMethods,
-			UDPReadBufferSize:   p.conf.RTSPUDPReadBufferSize,
-			ReadTimeout:         p.conf.ReadTimeout,
-			WriteTimeout:        p.conf.WriteTimeout,
-			WriteQueueSize:      p.conf.WriteQueueSize,
-			UseUDP:              useUDP,
-			UseMulticast:        useMulticast,
-			RTPAddress:          p.conf.SRTPAddress,
-			RTCPAddress:         p.conf.SRTCPAddress,
-			MulticastIPRange:    p.conf.MulticastIPRange,
-			MulticastRTPPort:    p.conf.MulticastSRTPPort,
-			MulticastRTCPPort:   p.conf.MulticastSRTCPPort,
-			IsTLS:               true,
-			ServerCert:          p.conf.RTSPServerCert,
-			ServerKey:           p.conf.RTSPServerKey,
-			RTSPAddress:         p.conf.RTSPAddress,
-			Transports:          p.conf.RTSPTransports,
-			RunOnConnect:        p.conf.RunOnConnect,
-			RunOnConnectRestart: p.conf.RunOnConnectRestart,
-			RunOnDisconnect:     p.conf.RunOnDisconnect,
-			ExternalCmdPool:     p.externalCmdPool,
-			Metrics:             p.metrics,
-			PathManager:         p.pathManager,
-			Parent:              p,
+			Address:              p.conf.RTSPSAddress,
+			AuthMethods:          p.conf.RTSPAuthMethods,
+			UDPReadBufferSize:    p.conf.RTSPUDPReadBufferSize,
+			ReadTimeout:          p.conf.ReadTimeout,
+			WriteTimeout:         p.conf.WriteTimeout,
+			WriteQueueSize:       p.conf.WriteQueueSize,
+			UseUDP:               useUDP,
+			UseMulticast:         useMulticast,
+			RTPAddress:           p.conf.SRTPAddress,
+			RTCPAddress:          p.conf.SRTCPAddress,
+			MulticastIPRange:     p.conf.MulticastIPRange,
+			MulticastRTPPort:     p.conf.MulticastSRTPPort,
+			MulticastRTCPPort:    p.conf.MulticastSRTCPPort,
+			IsTLS:                true,
+			ServerCert:           p.conf.RTSPServerCert,
+			ClientCA:             p.conf.RTSPClientCA,
+			ServerKey:            p.conf.RTSPServerKey,
+			ProxyProtocolSources: p.conf.RTSPProxyProtocolSources,
+			RTSPAddress:          p.conf.RTSPAddress,
+			Transports:           p.conf.RTSPTransports,
+			Playback:             p.conf.RTSPPlayback,
+			RTPMaxPayloadSize:    getRTPMaxPayloadSize(p.conf.UDPMaxPayloadSize, p.conf.RTSPEncryption),
+			RunOnConnect:         p.conf.RunOnConnect,
+			RunOnConnectRestart:  p.conf.RunOnConnectRestart,
+			RunOnDisconnect:      p.conf.RunOnDisconnect,
+			ExternalCmdPool:      p.externalCmdPool,
+			Metrics:              p.metrics,
+			PathManager:          p.pathManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -495,20 +560,21 @@ func (p *Core) createResources(initial bool) error {
 			p.conf.RTMPEncryption == conf.EncryptionOptional) &&
 		p.rtmpServer == nil {
 		i := &rtmp.Server{
-			Address:             p.conf.RTMPAddress,
-			ReadTimeout:         p.conf.ReadTimeout,
-			WriteTimeout:        p.conf.WriteTimeout,
-			IsTLS:               false,
-			ServerCert:          "",
-			ServerKey:           "",
-			RTSPAddress:         p.conf.RTSPAddress,
-			RunOnConnect:        p.conf.RunOnConnect,
-			RunOnConnectRestart: p.conf.RunOnConnectRestart,
-			RunOnDisconnect:     p.conf.RunOnDisconnect,
-			ExternalCmdPool:     p.externalCmdPool,
-			Metrics:             p.metrics,
-			PathManager:         p.pathManager,
-			Parent:              p,
+			Address:              p.conf.RTMPAddress,
+			ReadTimeout:          p.conf.ReadTimeout,
+			WriteTimeout:         p.conf.WriteTimeout,
+			IsTLS:                false,
+			ServerCert:           "",
+			ServerKey:            "",
+			ProxyProtocolSources: p.conf.RTMPProxyProtocolSources,
+			RTSPAddress:          p.conf.RTSPAddress,
+			RunOnConnect:         p.conf.RunOnConnect,
+			RunOnConnectRestart:  p.conf.RunOnConnectRestart,
+			RunOnDisconnect:      p.conf.RunOnDisconnect,
+			ExternalCmdPool:      p.externalCmdPool,
+			Metrics:              p.metrics,
+			PathManager:          p.pathManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -522,20 +588,22 @@ func (p *Core) createResources(initial bool) error {
 			p.conf.RTMPEncryption == conf.EncryptionOptional) &&
 		p.rtmpsServer == nil {
 		i := &rtmp.Server{
-			Address:             p.conf.RTMPSAddress,
-			ReadTimeout:         p.conf.ReadTimeout,
-			WriteTimeout:        p.conf.WriteTimeout,
-			IsTLS:               true,
-			ServerCert:          p.conf.RTMPServerCert,
-			ServerKey:           p.conf.RTMPServerKey,
-			RTSPAddress:         p.conf.RTSPAddress,
-			RunOnConnect:        p.conf.RunOnConnect,
-			RunOnConnectRestart: p.conf.RunOnConnectRestart,
-			RunOnDisconnect:     p.conf.RunOnDisconnect,
-			ExternalCmdPool:     p.externalCmdPool,
-			Metrics:             p.metrics,
-			PathManager:         p.pathManager,
-			Parent:              p,
+			Address:              p.conf.RTMPSAddress,
+			ReadTimeout:          p.conf.ReadTimeout,
+			WriteTimeout:         p.conf.WriteTimeout,
+			IsTLS:                true,
+			ServerCert:           p.conf.RTMPServerCert,
+			ClientCA:             p.conf.RTMPClientCA,
+			ServerKey:            p.conf.RTMPServerKey,
+			ProxyProtocolSources: p.conf.RTMPProxyProtocolSources,
+			RTSPAddress:          p.conf.RTSPAddress,
+			RunOnConnect:         p.conf.RunOnConnect,
+			RunOnConnectRestart:  p.conf.RunOnConnectRestart,
+			RunOnDisconnect:      p.conf.RunOnDisconnect,
+			ExternalCmdPool:      p.externalCmdPool,
+			Metrics:              p.metrics,
+			PathManager:          p.pathManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -547,25 +615,27 @@ func (p *Core) createResources(initial bool) error {
 	if p.conf.HLS &&
 		p.hlsServer == nil {
 		i := &hls.Server{
-			Address:         p.conf.HLSAddress,
-			Encryption:      p.conf.HLSEncryption,
-			ServerKey:       p.conf.HLSServerKey,
-			ServerCert:      p.conf.HLSServerCert,
-			AllowOrigin:     p.conf.HLSAllowOrigin,
-			TrustedProxies:  p.conf.HLSTrustedProxies,
-			AlwaysRemux:     p.conf.HLSAlwaysRemux,
-			Variant:         p.conf.HLSVariant,
-			SegmentCount:    p.conf.HLSSegmentCount,
-			SegmentDuration: p.conf.HLSSegmentDuration,
-			PartDuration:    p.conf.HLSPartDuration,
-			SegmentMaxSize:  p.conf.HLSSegmentMaxSize,
-			Directory:       p.conf.HLSDirectory,
-			ReadTimeout:     p.conf.ReadTimeout,
-			WriteTimeout:    p.conf.WriteTimeout,
-			MuxerCloseAfter: p.conf.HLSMuxerCloseAfter,
-			Metrics:         p.metrics,
-			PathManager:     p.pathManager,
-			Parent:          p,
+			Address:              p.conf.HLSAddress,
+			Encryption:           p.conf.HLSEncryption,
+			ServerKey:            p.conf.HLSServerKey,
+			ServerCert:           p.conf.HLSServerCert,
+			ClientCA:             p.conf.HLSClientCA,
+			AllowOrigin:          p.conf.HLSAllowOrigin,
+			TrustedProxies:       p.conf.HLSTrustedProxies,
+			ProxyProtocolSources: p.conf.HLSProxyProtocolSources,
+			AlwaysRemux:          p.conf.HLSAlwaysRemux,
+			Variant:              p.conf.HLSVariant,
+			SegmentCount:         p.conf.HLSSegmentCount,
+			SegmentDuration:      p.conf.HLSSegmentDuration,
+			PartDuration:         p.conf.HLSPartDuration,
+			SegmentMaxSize:       p.conf.HLSSegmentMaxSize,
+			Directory:            p.conf.HLSDirectory,
+			ReadTimeout:          p.conf.ReadTimeout,
+			WriteTimeout:         p.conf.WriteTimeout,
+			MuxerCloseAfter:      p.conf.HLSMuxerCloseAfter,
+			Metrics:              p.metrics,
+			PathManager:          p.pathManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -581,8 +651,10 @@ func (p *Core) createResources(initial bool) error {
 			Encryption:            p.conf.WebRTCEncryption,
 			ServerKey:             p.conf.WebRTCServerKey,
 			ServerCert:            p.conf.WebRTCServerCert,
+			ClientCA:              p.conf.WebRTCClientCA,
 			AllowOrigin:           p.conf.WebRTCAllowOrigin,
 			TrustedProxies:        p.conf.WebRTCTrustedProxies,
+			ProxyProtocolSources:  p.conf.WebRTCProxyProtocolSources,
 			ReadTimeout:           p.conf.ReadTimeout,
 			WriteTimeout:          p.conf.WriteTimeout,
 			LocalUDPAddress:       p.conf.WebRTCLocalUDPAddress,
@@ -609,18 +681,19 @@ func (p *Core) createResources(initial bool) error {
 	if p.conf.SRT &&
 		p.srtServer == nil {
 		i := &srt.Server{
-			Address:             p.conf.SRTAddress,
-			RTSPAddress:         p.conf.RTSPAddress,
-			ReadTimeout:         p.conf.ReadTimeout,
-			WriteTimeout:        p.conf.WriteTimeout,
-			UDPMaxPayloadSize:   p.conf.UDPMaxPayloadSize,
-			RunOnConnect:        p.conf.RunOnConnect,
-			RunOnConnectRestart: p.conf.RunOnConnectRestart,
-			RunOnDisconnect:     p.conf.RunOnDisconnect,
-			ExternalCmdPool:     p.externalCmdPool,
-			Metrics:             p.metrics,
-			PathManager:         p.pathManager,
-			Parent:              p,
+			Address:              p.conf.SRTAddress,
+			RTSPAddress:          p.conf.RTSPAddress,
+			ReadTimeout:          p.conf.ReadTimeout,
+			WriteTimeout:         p.conf.WriteTimeout,
+			UDPMaxPayloadSize:    p.conf.UDPMaxPayloadSize,
+			ProxyProtocolSources: p.conf.SRTProxyProtocolSources,
+			RunOnConnect:         p.conf.RunOnConnect,
+			RunOnConnectRestart:  p.conf.RunOnConnectRestart,
+			RunOnDisconnect:      p.conf.RunOnDisconnect,
+			ExternalCmdPool:      p.externalCmdPool,
+			Metrics:              p.metrics,
+			PathManager:          p.pathManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -632,27 +705,31 @@ func (p *Core) createResources(initial bool) error {
 	if p.conf.API &&
 		p.api == nil {
 		i := &api.API{
-			Version:        string(version),
-			Started:        started,
-			Address:        p.conf.APIAddress,
-			Encryption:     p.conf.APIEncryption,
-			ServerKey:      p.conf.APIServerKey,
-			ServerCert:     p.conf.APIServerCert,
-			AllowOrigin:    p.conf.APIAllowOrigin,
-			TrustedProxies: p.conf.APITrustedProxies,
-			ReadTimeout:    p.conf.ReadTimeout,
-			WriteTimeout:   p.conf.WriteTimeout,
-			Conf:           p.conf,
-			AuthManager:    p.authManager,
-			PathManager:    p.pathManager,
-			RTSPServer:     p.rtspServer,
-			RTSPSServer:    p.rtspsServer,
-			RTMPServer:     p.rtmpServer,
-			RTMPSServer:    p.rtmpsServer,
-			HLSServer:      p.hlsServer,
-			WebRTCServer:   p.webRTCServer,
-			SRTServer:      p.srtServer,
-			Parent:         p,
+			Version:              string(version),
+			Started:              started,
+			Address:              p.conf.APIAddress,
+			Encryption:           p.conf.APIEncryption,
+			ServerKey:            p.conf.APIServerKey,
+			ServerCert:           p.conf.APIServerCert,
+			ClientCA:             p.conf.APIClientCA,
+			AllowOrigin:          p.conf.APIAllowOrigin,
+			TrustedProxies:       p.conf.APITrustedProxies,
+			ProxyProtocolSources: p.conf.APIProxyProtocolSources,
+			ReadTimeout:          p.conf.ReadTimeout,
+			WriteTimeout:         p.conf.WriteTimeout,
+			Conf:                 p.conf,
+			AuthManager:          p.authManager,
+			PathManager:          p.pathManager,
+			RTSPServer:           p.rtspServer,
+			RTSPSServer:          p.rtspsServer,
+			RTMPServer:           p.rtmpServer,
+			RTMPSServer:          p.rtmpsServer,
+			HLSServer:            p.hlsServer,
+			WebRTCServer:         p.webRTCServer,
+			SRTServer:            p.srtServer,
+			RecordCleaner:        p.recordCleaner,
+			RecordRepairer:       p.recordRepairer,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -693,6 +770,9 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 	if !closeAuthManager && !reflect.DeepEqual(newConf.AuthInternalUsers, p.conf.AuthInternalUsers) {
 		p.authManager.ReloadInternalUsers(newConf.AuthInternalUsers)
 	}
+	if !closeAuthManager && !reflect.DeepEqual(newConf.AuthPolicy, p.conf.AuthPolicy) {
+		p.authManager.ReloadPolicy(newConf.AuthPolicy)
+	}
 
 	closeMetrics := newConf == nil ||
 		newConf.Metrics != p.conf.Metrics ||
@@ -702,6 +782,7 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.MetricsServerCert != p.conf.MetricsServerCert ||
 		newConf.MetricsAllowOrigin != p.conf.MetricsAllowOrigin ||
 		!reflect.DeepEqual(newConf.MetricsTrustedProxies, p.conf.MetricsTrustedProxies) ||
+		!reflect.DeepEqual(newConf.MetricsProxyProtocolSources, p.conf.MetricsProxyProtocolSources) ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
 		closeAuthManager ||
@@ -715,32 +796,27 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.PPROFServerCert != p.conf.PPROFServerCert ||
 		newConf.PPROFAllowOrigin != p.conf.PPROFAllowOrigin ||
 		!reflect.DeepEqual(newConf.PPROFTrustedProxies, p.conf.PPROFTrustedProxies) ||
+		!reflect.DeepEqual(newConf.PPROFProxyProtocolSources, p.conf.PPROFProxyProtocolSources) ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
 		closeAuthManager ||
 		closeLogger
 
 	closeRecorderCleaner := newConf == nil ||
-		atLeastOneRecordDeleteAfter(newConf.Paths) != atLeastOneRecordDeleteAfter(p.conf.Paths) ||
+		recordCleanerNeeded(newConf) != recordCleanerNeeded(p.conf) ||
+		newConf.RecordTotalMaxSize != p.conf.RecordTotalMaxSize ||
+		newConf.RecordMinFreeSpace != p.conf.RecordMinFreeSpace ||
+		closeMetrics ||
 		closeLogger
 	if !closeRecorderCleaner && p.recordCleaner != nil && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
 		p.recordCleaner.ReloadPathConfs(newConf.Paths)
 	}
 
-	closePlaybackServer := newConf == nil ||
-		newConf.Playback != p.conf.Playback ||
-		newConf.PlaybackAddress != p.conf.PlaybackAddress ||
-		newConf.PlaybackEncryption != p.conf.PlaybackEncryption ||
-		newConf.PlaybackServerKey != p.conf.PlaybackServerKey ||
-		newConf.PlaybackServerCert != p.conf.PlaybackServerCert ||
-		newConf.PlaybackAllowOrigin != p.conf.PlaybackAllowOrigin ||
-		!reflect.DeepEqual(newConf.PlaybackTrustedProxies, p.conf.PlaybackTrustedProxies) ||
-		newConf.ReadTimeout != p.conf.ReadTimeout ||
-		newConf.WriteTimeout != p.conf.WriteTimeout ||
-		closeAuthManager ||
+	closeRecordUploader := newConf == nil ||
+		recordUploaderNeeded(newConf) != recordUploaderNeeded(p.conf) ||
 		closeLogger
-	if !closePlaybackServer && p.playbackServer != nil && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
-		p.playbackServer.ReloadPathConfs(newConf.Paths)
+	if !closeRecordUploader && p.recordUploader != nil && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
+		p.recordUploader.ReloadPathConfs(newConf.Paths)
 	}
 
 	closePathManager := newConf == nil ||
@@ -758,12 +834,33 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		p.pathManager.ReloadPathConfs(newConf.Paths)
 	}
 
+	closePlaybackServer := newConf == nil ||
+		newConf.Playback != p.conf.Playback ||
+		newConf.PlaybackAddress != p.conf.PlaybackAddress ||
+		newConf.PlaybackEncryption != p.conf.PlaybackEncryption ||
+		newConf.PlaybackServerKey != p.conf.PlaybackServerKey ||
+		newConf.PlaybackServerCert != p.conf.PlaybackServerCert ||
+		newConf.PlaybackAllowOrigin != p.conf.PlaybackAllowOrigin ||
+		!reflect.DeepEqual(newConf.PlaybackTrustedProxies, p.conf.PlaybackTrustedProxies) ||
+		newConf.PlaybackExportDirectory != p.conf.PlaybackExportDirectory ||
+		newConf.PlaybackExportTTL != p.conf.PlaybackExportTTL ||
+		!reflect.DeepEqual(newConf.PlaybackProxyProtocolSources, p.conf.PlaybackProxyProtocolSources) ||
+		newConf.ReadTimeout != p.conf.ReadTimeout ||
+		newConf.WriteTimeout != p.conf.WriteTimeout ||
+		closeAuthManager ||
+		closePathManager ||
+		closeLogger
+	if !closePlaybackServer && p.playbackServer != nil && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
+		p.playbackServer.ReloadPathConfs(newConf.Paths)
+	}
+
 	closeRTSPServer := newConf == nil ||
 		newConf.RTSP != p.conf.RTSP ||
 		newConf.RTSPEncryption != p.conf.RTSPEncryption ||
 		newConf.RTSPAddress != p.conf.RTSPAddress ||
 		!reflect.DeepEqual(newConf.RTSPAuthMethods, p.conf.RTSPAuthMethods) ||
 		newConf.RTSPUDPReadBufferSize != p.conf.RTSPUDPReadBufferSize ||
+		!reflect.DeepEqual(newConf.RTSPProxyProtocolSources, p.conf.RTSPProxyProtocolSources) ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
 		newConf.WriteQueueSize != p.conf.WriteQueueSize ||
@@ -774,6 +871,7 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.MulticastRTCPPort != p.conf.MulticastRTCPPort ||
 		newConf.RTSPAddress != p.conf.RTSPAddress ||
 		!reflect.DeepEqual(newConf.RTSPTransports, p.conf.RTSPTransports) ||
+		newConf.RTSPPlayback != p.conf.RTSPPlayback ||
 		newConf.RunOnConnect != p.conf.RunOnConnect ||
 		newConf.RunOnConnectRestart != p.conf.RunOnConnectRestart ||
 		newConf.RunOnDisconnect != p.conf.RunOnDisconnect ||
@@ -787,13 +885,16 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.RTSPSAddress != p.conf.RTSPSAddress ||
 		!reflect.DeepEqual(newConf.RTSPAuthMethods, p.conf.RTSPAuthMethods) ||
 		newConf.RTSPUDPReadBufferSize != p.conf.RTSPUDPReadBufferSize ||
+		!reflect.DeepEqual(newConf.RTSPProxyProtocolSources, p.conf.RTSPProxyProtocolSources) ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
 		newConf.WriteQueueSize != p.conf.WriteQueueSize ||
 		newConf.RTSPServerCert != p.conf.RTSPServerCert ||
+		newConf.RTSPClientCA != p.conf.RTSPClientCA ||
 		newConf.RTSPServerKey != p.conf.RTSPServerKey ||
 		newConf.RTSPAddress != p.conf.RTSPAddress ||
 		!reflect.DeepEqual(newConf.RTSPTransports, p.conf.RTSPTransports) ||
+		newConf.RTSPPlayback != p.conf.RTSPPlayback ||
 		newConf.RunOnConnect != p.conf.RunOnConnect ||
 		newConf.RunOnConnectRestart != p.conf.RunOnConnectRestart ||
 		newConf.RunOnDisconnect != p.conf.RunOnDisconnect ||
@@ -805,6 +906,7 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.RTMP != p.conf.RTMP ||
 		newConf.RTMPEncryption != p.conf.RTMPEncryption ||
 		newConf.RTMPAddress != p.conf.RTMPAddress ||
+		!reflect.DeepEqual(newConf.RTMPProxyProtocolSources, p.conf.RTMPProxyProtocolSources) ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
 		newConf.RTSPAddress != p.conf.RTSPAddress ||
@@ -819,9 +921,11 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.RTMP != p.conf.RTMP ||
 		newConf.RTMPEncryption != p.conf.RTMPEncryption ||
 		newConf.RTMPSAddress != p.conf.RTMPSAddress ||
+		!reflect.DeepEqual(newConf.RTMPProxyProtocolSources, p.conf.RTMPProxyProtocolSources) ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
 		newConf.RTMPServerCert != p.conf.RTMPServerCert ||
+		newConf.RTMPClientCA != p.conf.RTMPClientCA ||
 		newConf.RTMPServerKey != p.conf.RTMPServerKey ||
 		newConf.RTSPAddress != p.conf.RTSPAddress ||
 		newConf.RunOnConnect != p.conf.RunOnConnect ||
@@ -837,8 +941,10 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.HLSEncryption != p.conf.HLSEncryption ||
 		newConf.HLSServerKey != p.conf.HLSServerKey ||
 		newConf.HLSServerCert != p.conf.HLSServerCert ||
+		newConf.HLSClientCA != p.conf.HLSClientCA ||
 		newConf.HLSAllowOrigin != p.conf.HLSAllowOrigin ||
 		!reflect.DeepEqual(newConf.HLSTrustedProxies, p.conf.HLSTrustedProxies) ||
+		!reflect.DeepEqual(newConf.HLSProxyProtocolSources, p.conf.HLSProxyProtocolSources) ||
 		newConf.HLSAlwaysRemux != p.conf.HLSAlwaysRemux ||
 		newConf.HLSVariant != p.conf.HLSVariant ||
 		newConf.HLSSegmentCount != p.conf.HLSSegmentCount ||
@@ -859,8 +965,10 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.WebRTCEncryption != p.conf.WebRTCEncryption ||
 		newConf.WebRTCServerKey != p.conf.WebRTCServerKey ||
 		newConf.WebRTCServerCert != p.conf.WebRTCServerCert ||
+		newConf.WebRTCClientCA != p.conf.WebRTCClientCA ||
 		newConf.WebRTCAllowOrigin != p.conf.WebRTCAllowOrigin ||
 		!reflect.DeepEqual(newConf.WebRTCTrustedProxies, p.conf.WebRTCTrustedProxies) ||
+		!reflect.DeepEqual(newConf.WebRTCProxyProtocolSources, p.conf.WebRTCProxyProtocolSources) ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
 		newConf.WebRTCLocalUDPAddress != p.conf.WebRTCLocalUDPAddress ||
@@ -879,6 +987,7 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 	closeSRTServer := newConf == nil ||
 		newConf.SRT != p.conf.SRT ||
 		newConf.SRTAddress != p.conf.SRTAddress ||
+		!reflect.DeepEqual(newConf.SRTProxyProtocolSources, p.conf.SRTProxyProtocolSources) ||
 		newConf.RTSPAddress != p.conf.RTSPAddress ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
@@ -895,8 +1004,10 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.APIEncryption != p.conf.APIEncryption ||
 		newConf.APIServerKey != p.conf.APIServerKey ||
 		newConf.APIServerCert != p.conf.APIServerCert ||
+		newConf.APIClientCA != p.conf.APIClientCA ||
 		newConf.APIAllowOrigin != p.conf.APIAllowOrigin ||
 		!reflect.DeepEqual(newConf.APITrustedProxies, p.conf.APITrustedProxies) ||
+		!reflect.DeepEqual(newConf.APIProxyProtocolSources, p.conf.APIProxyProtocolSources) ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
 		closeAuthManager ||
@@ -907,6 +1018,7 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		closeHLSServer ||
 		closeWebRTCServer ||
 		closeSRTServer ||
+		closeRecorderCleaner ||
 		closeLogger
 
 	if newConf == nil && p.confWatcher != nil {
@@ -958,21 +1070,31 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		p.rtspServer = nil
 	}
 
-	if closePathManager && p.pathManager != nil {
-		p.pathManager.close()
-		p.pathManager = nil
-	}
-
 	if closePlaybackServer && p.playbackServer != nil {
 		p.playbackServer.Close()
 		p.playbackServer = nil
 	}
 
+	if closePathManager && p.pathManager != nil {
+		p.pathManager.close()
+		p.pathManager = nil
+	}
+
 	if closeRecorderCleaner && p.recordCleaner != nil {
 		p.recordCleaner.Close()
 		p.recordCleaner = nil
 	}
 
+	if closeRecordUploader && p.recordUploader != nil {
+		p.recordUploader.Close()
+		p.recordUploader = nil
+	}
+
+	if newConf == nil && p.recordRepairer != nil {
+		p.recordRepairer.Close()
+		p.recordRepairer = nil
+	}
+
 	if closePPROF && p.pprof != nil {
 		p.pprof.Close()
 		p.pprof = nil
@@ -175,6 +175,10 @@ webrtc_sessions_rtp_packets_lost 0
 webrtc_sessions_rtp_packets_jitter 0
 webrtc_sessions_rtcp_packets_received 0
 webrtc_sessions_rtcp_packets_sent 0
+recordings_bytes 0
+recordings_segments 0
+recordings_evictions 0
+recordings_evicted_bytes 0
 `, string(bo))
 	})
 
@@ -474,6 +478,10 @@ webrtc_sessions_rtcp_packets_sent 0
 				`webrtc_sessions_rtp_packets_jitter\{id=".*?",path=".*?",remoteAddr=".*?",state="publish"\} [0-9]+`+"\n"+
 				`webrtc_sessions_rtcp_packets_received\{id=".*?",path=".*?",remoteAddr=".*?",state="publish"\} [0-9]+`+"\n"+
 				`webrtc_sessions_rtcp_packets_sent\{id=".*?",path=".*?",remoteAddr=".*?",state="publish"\} [0-9]+`+"\n"+
+				"recordings_bytes 0\n"+
+				"recordings_segments 0\n"+
+				"recordings_evictions 0\n"+
+				"recordings_evicted_bytes 0\n"+
 				"$",
 			string(bo))
 
@@ -497,7 +505,11 @@ webrtc_sessions_rtcp_packets_sent 0
 		require.Equal(t, "paths 0\n"+
 			"paths_bytes_received 0\n"+
 			"paths_bytes_sent 0\n"+
-			"paths_readers 0\n",
+			"paths_readers 0\n"+
+			"recordings_bytes 0\n"+
+			"recordings_segments 0\n"+
+			"recordings_evictions 0\n"+
+			"recordings_evicted_bytes 0\n",
 			string(bo))
 	})
 }
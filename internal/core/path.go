@@ -4,18 +4,21 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"reflect"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/bluenviron/gortsplib/v5/pkg/description"
 
+	"github.com/bluenviron/mediamtx/internal/auth"
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/defs"
 	"github.com/bluenviron/mediamtx/internal/externalcmd"
 	"github.com/bluenviron/mediamtx/internal/hooks"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/recorder"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/staticsources"
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
@@ -32,6 +35,7 @@ type pathParent interface {
 	pathNotReady(*path)
 	closePath(*path)
 	AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error)
+	FindPathConf(req defs.PathFindPathConfReq) (*conf.Path, error)
 }
 
 type pathOnDemandState int
@@ -63,6 +67,11 @@ type pathAPIPathsGetReq struct {
 	res  chan pathAPIPathsGetRes
 }
 
+type pathAPIRecordingTriggerReq struct {
+	postRoll time.Duration
+	res      chan error
+}
+
 type path struct {
 	parentCtx         context.Context
 	logLevel          conf.LogLevel
@@ -76,6 +85,7 @@ type path struct {
 	matches           []string
 	wg                *sync.WaitGroup
 	externalCmdPool   *externalcmd.Pool
+	limiter           *auth.SessionLimiter
 	parent            pathParent
 
 	ctx                            context.Context
@@ -84,7 +94,7 @@ type path struct {
 	source                         defs.Source
 	publisherQuery                 string
 	stream                         *stream.Stream
-	recorder                       *recorder.Recorder
+	recorders                      []*recorder.Recorder
 	readyTime                      time.Time
 	onUnDemandHook                 func(string)
 	onNotReadyHook                 func()
@@ -108,6 +118,7 @@ type path struct {
 	chAddReader               chan defs.PathAddReaderReq
 	chRemoveReader            chan defs.PathRemoveReaderReq
 	chAPIPathsGet             chan pathAPIPathsGetReq
+	chAPIRecordingTrigger     chan pathAPIRecordingTriggerReq
 
 	// out
 	done chan struct{}
@@ -132,6 +143,7 @@ func (pa *path) initialize() {
 	pa.chAddReader = make(chan defs.PathAddReaderReq)
 	pa.chRemoveReader = make(chan defs.PathRemoveReaderReq)
 	pa.chAPIPathsGet = make(chan pathAPIPathsGetReq)
+	pa.chAPIRecordingTrigger = make(chan pathAPIRecordingTriggerReq)
 	pa.done = make(chan struct{})
 
 	pa.Log(logger.Debug, "created")
@@ -306,6 +318,9 @@ func (pa *path) runInner() error {
 		case req := <-pa.chAPIPathsGet:
 			pa.doAPIPathsGet(req)
 
+		case req := <-pa.chAPIRecordingTrigger:
+			pa.doAPIRecordingTrigger(req)
+
 		case <-pa.ctx.Done():
 			return fmt.Errorf("terminated")
 		}
@@ -359,25 +374,33 @@ func (pa *path) doReloadConf(newConf *conf.Path) {
 		pa.source.(*staticsources.Handler).ReloadConf(newConf)
 	}
 
-	if pa.recorder != nil &&
+	if len(pa.recorders) != 0 &&
 		(newConf.Record != oldConf.Record ||
 			newConf.RecordPath != oldConf.RecordPath ||
 			newConf.RecordFormat != oldConf.RecordFormat ||
 			newConf.RecordPartDuration != oldConf.RecordPartDuration ||
 			newConf.RecordMaxPartSize != oldConf.RecordMaxPartSize ||
 			newConf.RecordSegmentDuration != oldConf.RecordSegmentDuration ||
-			newConf.RecordDeleteAfter != oldConf.RecordDeleteAfter) {
-		pa.recorder.Close()
-		pa.recorder = nil
+			newConf.RecordSegmentAlign != oldConf.RecordSegmentAlign ||
+			newConf.RecordKeyframeInterval != oldConf.RecordKeyframeInterval ||
+			newConf.RecordDeleteAfter != oldConf.RecordDeleteAfter ||
+			newConf.RecordTrigger != oldConf.RecordTrigger ||
+			newConf.RecordPreRoll != oldConf.RecordPreRoll ||
+			!reflect.DeepEqual(newConf.RecordTracks, oldConf.RecordTracks) ||
+			!reflect.DeepEqual(newConf.RecordCopies, oldConf.RecordCopies) ||
+			newConf.RecordEncryption != oldConf.RecordEncryption ||
+			newConf.RecordEncryptionKeyFile != oldConf.RecordEncryptionKeyFile ||
+			newConf.RecordEncryptionKeyCommand != oldConf.RecordEncryptionKeyCommand) {
+		pa.closeRecorders()
 	}
 
-	if newConf.Record && pa.stream != nil && pa.recorder == nil {
+	if newConf.Record && pa.stream != nil && len(pa.recorders) == 0 {
 		pa.startRecording()
 	}
 }
 
 func (pa *path) doSourceStaticSetReady(req defs.PathSourceStaticSetReadyReq) {
-	err := pa.setReady(req.Desc, req.GenerateRTPPackets, req.FillNTP)
+	err := pa.setReady(req.Desc, req.MediaProperties, req.GenerateRTPPackets, req.FillNTP)
 	if err != nil {
 		req.Res <- defs.PathSourceStaticSetReadyRes{Err: err}
 		return
@@ -474,7 +497,7 @@ func (pa *path) doAddPublisher(req defs.PathAddPublisherReq) {
 	pa.source = req.Author
 	pa.publisherQuery = req.AccessRequest.Query
 
-	err := pa.setReady(req.Desc, req.GenerateRTPPackets, req.FillNTP)
+	err := pa.setReady(req.Desc, req.MediaProperties, req.GenerateRTPPackets, req.FillNTP)
 	if err != nil {
 		pa.source = nil
 		req.Res <- defs.PathAddPublisherRes{Err: err}
@@ -592,6 +615,28 @@ func (pa *path) doAPIPathsGet(req pathAPIPathsGetReq) {
 	}
 }
 
+func (pa *path) doAPIRecordingTrigger(req pathAPIRecordingTriggerReq) {
+	if !pa.conf.Record || !pa.conf.RecordTrigger {
+		req.res <- fmt.Errorf("path is not configured for triggered recording")
+		return
+	}
+
+	if len(pa.recorders) == 0 {
+		req.res <- fmt.Errorf("path is not ready")
+		return
+	}
+
+	postRoll := req.postRoll
+	if postRoll == 0 {
+		postRoll = time.Duration(pa.conf.RecordPostRoll)
+	}
+
+	for _, r := range pa.recorders {
+		r.Trigger(postRoll)
+	}
+	req.res <- nil
+}
+
 func (pa *path) SafeConf() *conf.Path {
 	pa.confMutex.RLock()
 	defer pa.confMutex.RUnlock()
@@ -684,11 +729,17 @@ func (pa *path) onDemandPublisherStop(reason string) {
 	pa.onDemandPublisherState = pathOnDemandStateInitial
 }
 
-func (pa *path) setReady(desc *description.Session, generateRTPPackets bool, fillNTP bool) error {
+func (pa *path) setReady(
+	desc *description.Session,
+	mediaProps map[*description.Media]*stream.MediaProperties,
+	generateRTPPackets bool,
+	fillNTP bool,
+) error {
 	pa.stream = &stream.Stream{
 		WriteQueueSize:     pa.writeQueueSize,
 		RTPMaxPayloadSize:  pa.rtpMaxPayloadSize,
 		Desc:               desc,
+		MediaProperties:    mediaProps,
 		GenerateRTPPackets: generateRTPPackets,
 		FillNTP:            fillNTP,
 		Parent:             pa.source,
@@ -742,10 +793,7 @@ func (pa *path) setNotReady() {
 
 	pa.onNotReadyHook()
 
-	if pa.recorder != nil {
-		pa.recorder.Close()
-		pa.recorder = nil
-	}
+	pa.closeRecorders()
 
 	if pa.stream != nil {
 		pa.stream.Close()
@@ -754,50 +802,78 @@ func (pa *path) setNotReady() {
 }
 
 func (pa *path) startRecording() {
-	pa.recorder = &recorder.Recorder{
-		PathFormat:      pa.conf.RecordPath,
-		Format:          pa.conf.RecordFormat,
-		PartDuration:    time.Duration(pa.conf.RecordPartDuration),
-		MaxPartSize:     pa.conf.RecordMaxPartSize,
-		SegmentDuration: time.Duration(pa.conf.RecordSegmentDuration),
-		PathName:        pa.name,
-		Stream:          pa.stream,
-		OnSegmentCreate: func(segmentPath string) {
-			if pa.conf.RunOnRecordSegmentCreate != "" {
-				env := pa.ExternalCmdEnv()
-				env["MTX_SEGMENT_PATH"] = segmentPath
-
-				pa.Log(logger.Info, "runOnRecordSegmentCreate command launched")
-				externalcmd.NewCmd(
-					pa.externalCmdPool,
-					pa.conf.RunOnRecordSegmentCreate,
-					false,
-					env,
-					nil)
-			}
-		},
-		OnSegmentComplete: func(segmentPath string, segmentDuration time.Duration) {
-			if pa.conf.RunOnRecordSegmentComplete != "" {
-				env := pa.ExternalCmdEnv()
-				env["MTX_SEGMENT_PATH"] = segmentPath
-				env["MTX_SEGMENT_DURATION"] = strconv.FormatFloat(segmentDuration.Seconds(), 'f', -1, 64)
-
-				pa.Log(logger.Info, "runOnRecordSegmentComplete command launched")
-				externalcmd.NewCmd(
-					pa.externalCmdPool,
-					pa.conf.RunOnRecordSegmentComplete,
-					false,
-					env,
-					nil)
-			}
-		},
-		Parent: pa,
+	var encryptionKey *recordstore.EncryptionKey
+
+	if pa.conf.RecordEncryption {
+		keys, err := recordstore.LoadEncryptionKeys(pa.conf)
+		if err != nil {
+			pa.Log(logger.Error, "unable to start recording: %v", err)
+			return
+		}
+		encryptionKey = &keys[0]
+	}
+
+	for _, rc := range pa.conf.RecordRenditions() {
+		r := &recorder.Recorder{
+			PathFormat:       rc.RecordPath,
+			Format:           rc.RecordFormat,
+			PartDuration:     time.Duration(pa.conf.RecordPartDuration),
+			MaxPartSize:      pa.conf.RecordMaxPartSize,
+			SegmentDuration:  time.Duration(rc.RecordSegmentDuration),
+			SegmentAlign:     pa.conf.RecordSegmentAlign,
+			KeyframeInterval: time.Duration(pa.conf.RecordKeyframeInterval),
+			Tracks:           rc.RecordTracks,
+			PathName:         pa.name,
+			Stream:           pa.stream,
+			TriggerMode:      pa.conf.RecordTrigger,
+			PreRoll:          time.Duration(pa.conf.RecordPreRoll),
+			EncryptionKey:    encryptionKey,
+			OnSegmentCreate: func(segmentPath string) {
+				if pa.conf.RunOnRecordSegmentCreate != "" {
+					env := pa.ExternalCmdEnv()
+					env["MTX_SEGMENT_PATH"] = segmentPath
+
+					pa.Log(logger.Info, "runOnRecordSegmentCreate command launched")
+					externalcmd.NewCmd(
+						pa.externalCmdPool,
+						pa.conf.RunOnRecordSegmentCreate,
+						false,
+						env,
+						nil)
+				}
+			},
+			OnSegmentComplete: func(segmentPath string, segmentDuration time.Duration) {
+				if pa.conf.RunOnRecordSegmentComplete != "" {
+					env := pa.ExternalCmdEnv()
+					env["MTX_SEGMENT_PATH"] = segmentPath
+					env["MTX_SEGMENT_DURATION"] = strconv.FormatFloat(segmentDuration.Seconds(), 'f', -1, 64)
+
+					pa.Log(logger.Info, "runOnRecordSegmentComplete command launched")
+					externalcmd.NewCmd(
+						pa.externalCmdPool,
+						pa.conf.RunOnRecordSegmentComplete,
+						false,
+						env,
+						nil)
+				}
+			},
+			Parent: pa,
+		}
+		r.Initialize()
+		pa.recorders = append(pa.recorders, r)
 	}
-	pa.recorder.Initialize()
+}
+
+func (pa *path) closeRecorders() {
+	for _, r := range pa.recorders {
+		r.Close()
+	}
+	pa.recorders = nil
 }
 
 func (pa *path) executeRemoveReader(r defs.Reader) {
 	delete(pa.readers, r)
+	pa.limiter.Release(r)
 }
 
 func (pa *path) executeRemovePublisher() {
@@ -805,6 +881,9 @@ func (pa *path) executeRemovePublisher() {
 		pa.setNotReady()
 	}
 
+	if pa.source != nil {
+		pa.limiter.Release(pa.source)
+	}
 	pa.source = nil
 }
 
@@ -952,3 +1031,15 @@ func (pa *path) APIPathsGet(req pathAPIPathsGetReq) (*defs.APIPath, error) {
 		return nil, fmt.Errorf("terminated")
 	}
 }
+
+// APIRecordingTrigger is called by api.
+func (pa *path) APIRecordingTrigger(req pathAPIRecordingTriggerReq) error {
+	req.res = make(chan error)
+	select {
+	case pa.chAPIRecordingTrigger <- req:
+		return <-req.res
+
+	case <-pa.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
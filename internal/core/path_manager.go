@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/bluenviron/mediamtx/internal/auth"
 	"github.com/bluenviron/mediamtx/internal/conf"
@@ -28,7 +29,18 @@ func pathConfCanBeUpdated(oldPathConf *conf.Path, newPathConf *conf.Path) bool {
 	clone.RecordPartDuration = newPathConf.RecordPartDuration
 	clone.RecordMaxPartSize = newPathConf.RecordMaxPartSize
 	clone.RecordSegmentDuration = newPathConf.RecordSegmentDuration
+	clone.RecordSegmentAlign = newPathConf.RecordSegmentAlign
+	clone.RecordKeyframeInterval = newPathConf.RecordKeyframeInterval
 	clone.RecordDeleteAfter = newPathConf.RecordDeleteAfter
+	clone.RecordMaxSize = newPathConf.RecordMaxSize
+	clone.RecordTrigger = newPathConf.RecordTrigger
+	clone.RecordPreRoll = newPathConf.RecordPreRoll
+	clone.RecordPostRoll = newPathConf.RecordPostRoll
+	clone.RecordTracks = newPathConf.RecordTracks
+	clone.RecordCopies = newPathConf.RecordCopies
+	clone.RecordEncryption = newPathConf.RecordEncryption
+	clone.RecordEncryptionKeyFile = newPathConf.RecordEncryptionKeyFile
+	clone.RecordEncryptionKeyCommand = newPathConf.RecordEncryptionKeyCommand
 
 	clone.RPICameraBrightness = newPathConf.RPICameraBrightness
 	clone.RPICameraContrast = newPathConf.RPICameraContrast
@@ -87,6 +99,7 @@ type pathManager struct {
 	wg        sync.WaitGroup
 	hlsServer *hls.Server
 	paths     map[string]*pathData
+	limiter   auth.SessionLimiter
 
 	// in
 	chReloadConf   chan map[string]*conf.Path
@@ -108,6 +121,7 @@ func (pm *pathManager) initialize() {
 	pm.ctx = ctx
 	pm.ctxCancel = ctxCancel
 	pm.paths = make(map[string]*pathData)
+	pm.limiter.Initialize()
 	pm.chReloadConf = make(chan map[string]*conf.Path)
 	pm.chSetHLSServer = make(chan pathSetHLSServerReq)
 	pm.chClosePath = make(chan *path)
@@ -155,6 +169,9 @@ func (pm *pathManager) Log(level logger.Level, format string, args ...interface{
 func (pm *pathManager) run() {
 	defer pm.wg.Done()
 
+	limiterTicker := time.NewTicker(auth.SessionLimiterPeriod)
+	defer limiterTicker.Stop()
+
 outer:
 	for {
 		select {
@@ -192,6 +209,9 @@ outer:
 		case req := <-pm.chAPIPathsGet:
 			pm.doAPIPathsGet(req)
 
+		case <-limiterTicker.C:
+			pm.doCheckLimits()
+
 		case <-pm.ctx.Done():
 			break outer
 		}
@@ -200,6 +220,22 @@ outer:
 	pm.ctxCancel()
 }
 
+func (pm *pathManager) doCheckLimits() {
+	for _, v := range pm.limiter.Check(time.Now()) {
+		switch author := v.Author.(type) {
+		case defs.Reader:
+			desc := author.APIReaderDescribe()
+			pm.Log(logger.Info, "closing reader %s %s: %v", desc.Type, desc.ID, v.Err)
+			author.Close()
+
+		case defs.Publisher:
+			desc := author.APISourceDescribe()
+			pm.Log(logger.Info, "closing publisher %s %s: %v", desc.Type, desc.ID, v.Err)
+			author.Close()
+		}
+	}
+}
+
 func (pm *pathManager) doReloadConf(newPaths map[string]*conf.Path) {
 	confsToRecreate := make(map[string]struct{})
 	confsToReload := make(map[string]struct{})
@@ -323,10 +359,12 @@ func (pm *pathManager) doFindPathConf(req defs.PathFindPathConfReq) {
 		return
 	}
 
-	err2 := pm.authManager.Authenticate(req.AccessRequest.ToAuthRequest())
-	if err2 != nil {
-		req.Res <- defs.PathFindPathConfRes{Err: err2}
-		return
+	if !req.AccessRequest.SkipAuth {
+		err2 := pm.authManager.Authenticate(req.AccessRequest.ToAuthRequest())
+		if err2 != nil {
+			req.Res <- defs.PathFindPathConfRes{Err: err2}
+			return
+		}
 	}
 
 	req.Res <- defs.PathFindPathConfRes{Conf: pathConf}
@@ -361,8 +399,11 @@ func (pm *pathManager) doAddReader(req defs.PathAddReaderReq) {
 		return
 	}
 
+	var limits []auth.Limit
+
 	if !req.AccessRequest.SkipAuth {
-		err2 := pm.authManager.Authenticate(req.AccessRequest.ToAuthRequest())
+		var err2 *auth.Error
+		limits, err2 = pm.authManager.AuthenticateWithLimits(req.AccessRequest.ToAuthRequest())
 		if err2 != nil {
 			req.Res <- defs.PathAddReaderRes{Err: err2}
 			return
@@ -375,6 +416,13 @@ func (pm *pathManager) doAddReader(req defs.PathAddReaderReq) {
 	}
 
 	pd := pm.paths[req.AccessRequest.Name]
+
+	err = pm.limiter.Reserve(req.Author, pd.path, false, limits)
+	if err != nil {
+		req.Res <- defs.PathAddReaderRes{Err: err}
+		return
+	}
+
 	req.Res <- defs.PathAddReaderRes{Path: pd.path}
 }
 
@@ -390,8 +438,11 @@ func (pm *pathManager) doAddPublisher(req defs.PathAddPublisherReq) {
 		return
 	}
 
+	var limits []auth.Limit
+
 	if !req.AccessRequest.SkipAuth {
-		err2 := pm.authManager.Authenticate(req.AccessRequest.ToAuthRequest())
+		var err2 *auth.Error
+		limits, err2 = pm.authManager.AuthenticateWithLimits(req.AccessRequest.ToAuthRequest())
 		if err2 != nil {
 			req.Res <- defs.PathAddPublisherRes{Err: err2}
 			return
@@ -404,6 +455,13 @@ func (pm *pathManager) doAddPublisher(req defs.PathAddPublisherReq) {
 	}
 
 	pd := pm.paths[req.AccessRequest.Name]
+
+	err = pm.limiter.Reserve(req.Author, pd.path, true, limits)
+	if err != nil {
+		req.Res <- defs.PathAddPublisherRes{Err: err}
+		return
+	}
+
 	req.Res <- defs.PathAddPublisherRes{Path: pd.path}
 }
 
@@ -445,6 +503,7 @@ func (pm *pathManager) createPath(
 		matches:           matches,
 		wg:                &pm.wg,
 		externalCmdPool:   pm.externalCmdPool,
+		limiter:           &pm.limiter,
 		parent:            pm,
 	}
 	pa.initialize()
@@ -457,6 +516,7 @@ func (pm *pathManager) createPath(
 
 func (pm *pathManager) removePath(pa *path) {
 	delete(pm.paths, pa.name)
+	pm.limiter.ReleasePath(pa)
 }
 
 // ReloadPathConfs is called by core.
@@ -540,7 +600,14 @@ func (pm *pathManager) AddPublisher(req defs.PathAddPublisherReq) (defs.Path, *s
 			return nil, nil, res.Err
 		}
 
-		return res.Path.(*path).addPublisher(req)
+		pa, strm, err := res.Path.(*path).addPublisher(req)
+		if err != nil {
+			pm.limiter.Release(req.Author)
+			return nil, nil, err
+		}
+
+		pm.limiter.Attach(req.Author)
+		return pa, strm, nil
 
 	case <-pm.ctx.Done():
 		return nil, nil, fmt.Errorf("terminated")
@@ -557,7 +624,14 @@ func (pm *pathManager) AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.
 			return nil, nil, res.Err
 		}
 
-		return res.Path.(*path).addReader(req)
+		pa, strm, err := res.Path.(*path).addReader(req)
+		if err != nil {
+			pm.limiter.Release(req.Author)
+			return nil, nil, err
+		}
+
+		pm.limiter.Attach(req.Author)
+		return pa, strm, nil
 
 	case <-pm.ctx.Done():
 		return nil, nil, fmt.Errorf("terminated")
@@ -634,3 +708,24 @@ func (pm *pathManager) APIPathsGet(name string) (*defs.APIPath, error) {
 		return nil, fmt.Errorf("terminated")
 	}
 }
+
+// APIRecordingTrigger is called by api.
+func (pm *pathManager) APIRecordingTrigger(name string, postRoll time.Duration) error {
+	req := pathAPIPathsGetReq{
+		name: name,
+		res:  make(chan pathAPIPathsGetRes),
+	}
+
+	select {
+	case pm.chAPIPathsGet <- req:
+		res := <-req.res
+		if res.err != nil {
+			return res.err
+		}
+
+		return res.path.APIRecordingTrigger(pathAPIRecordingTriggerReq{postRoll: postRoll})
+
+	case <-pm.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
@@ -1,6 +1,7 @@
 package defs
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,6 +13,17 @@ import (
 type APIPathManager interface {
 	APIPathsList() (*APIPathList, error)
 	APIPathsGet(string) (*APIPath, error)
+	APIRecordingTrigger(string, time.Duration) error
+}
+
+// APIRecordCleaner contains methods used by the API and Metrics server.
+type APIRecordCleaner interface {
+	APIUsage() *APIRecordingUsage
+}
+
+// APIRecordRepairer contains methods used by the API.
+type APIRecordRepairer interface {
+	APIRepairsList() (*APIRecordingRepairList, error)
 }
 
 // APIHLSServer contains methods used by the API and Metrics server.
@@ -61,6 +73,26 @@ type APIInfo struct {
 	Started time.Time `json:"started"`
 }
 
+// APIAuthPolicyTest is a sample request to be evaluated against the authorization policy.
+type APIAuthPolicyTest struct {
+	Policy   *[]string      `json:"policy"`
+	User     string         `json:"user"`
+	IP       string         `json:"ip"`
+	Action   string         `json:"action"`
+	Path     string         `json:"path"`
+	Protocol string         `json:"protocol"`
+	Query    string         `json:"query"`
+	Time     *time.Time     `json:"time"`
+	Claims   map[string]any `json:"claims"`
+}
+
+// APIAuthPolicyTestResult is the result of a policy evaluation.
+type APIAuthPolicyTestResult struct {
+	Allowed         bool    `json:"allowed"`
+	MatchedPolicy   *int    `json:"matchedPolicy"`
+	EvaluationError *string `json:"evaluationError"`
+}
+
 // APIPathConfList is a list of path configurations.
 type APIPathConfList struct {
 	ItemCount int          `json:"itemCount"`
@@ -121,14 +153,15 @@ const (
 
 // APIRTMPConn is a RTMP connection.
 type APIRTMPConn struct {
-	ID            uuid.UUID        `json:"id"`
-	Created       time.Time        `json:"created"`
-	RemoteAddr    string           `json:"remoteAddr"`
-	State         APIRTMPConnState `json:"state"`
-	Path          string           `json:"path"`
-	Query         string           `json:"query"`
-	BytesReceived uint64           `json:"bytesReceived"`
-	BytesSent     uint64           `json:"bytesSent"`
+	ID                 uuid.UUID        `json:"id"`
+	Created            time.Time        `json:"created"`
+	RemoteAddr         string           `json:"remoteAddr"`
+	State              APIRTMPConnState `json:"state"`
+	Path               string           `json:"path"`
+	Query              string           `json:"query"`
+	BytesReceived      uint64           `json:"bytesReceived"`
+	BytesSent          uint64           `json:"bytesSent"`
+	ClientCertIdentity *string          `json:"clientCertIdentity"`
 }
 
 // APIRTMPConnList is a list of RTMP connections.
@@ -140,13 +173,14 @@ type APIRTMPConnList struct {
 
 // APIRTSPConn is a RTSP connection.
 type APIRTSPConn struct {
-	ID            uuid.UUID  `json:"id"`
-	Created       time.Time  `json:"created"`
-	RemoteAddr    string     `json:"remoteAddr"`
-	BytesReceived uint64     `json:"bytesReceived"`
-	BytesSent     uint64     `json:"bytesSent"`
-	Session       *uuid.UUID `json:"session"`
-	Tunnel        string     `json:"tunnel"`
+	ID                 uuid.UUID  `json:"id"`
+	Created            time.Time  `json:"created"`
+	RemoteAddr         string     `json:"remoteAddr"`
+	BytesReceived      uint64     `json:"bytesReceived"`
+	BytesSent          uint64     `json:"bytesSent"`
+	Session            *uuid.UUID `json:"session"`
+	Tunnel             string     `json:"tunnel"`
+	ClientCertIdentity *string    `json:"clientCertIdentity"`
 }
 
 // APIRTSPConnsList is a list of RTSP connections.
@@ -186,6 +220,7 @@ type APIRTSPSession struct {
 	RTCPPacketsReceived uint64              `json:"rtcpPacketsReceived"`
 	RTCPPacketsSent     uint64              `json:"rtcpPacketsSent"`
 	RTCPPacketsInError  uint64              `json:"rtcpPacketsInError"`
+	ClientCertIdentity  *string             `json:"clientCertIdentity"`
 }
 
 // APIRTSPSessionList is a list of RTSP sessions.
@@ -371,6 +406,7 @@ type APIWebRTCSession struct {
 	RTPPacketsJitter          float64               `json:"rtpPacketsJitter"`
 	RTCPPacketsReceived       uint64                `json:"rtcpPacketsReceived"`
 	RTCPPacketsSent           uint64                `json:"rtcpPacketsSent"`
+	ClientCertIdentity        *string               `json:"clientCertIdentity"`
 }
 
 // APIWebRTCSessionList is a list of WebRTC sessions.
@@ -391,6 +427,77 @@ type APIRecording struct {
 	Segments []*APIRecordingSegment `json:"segments"`
 }
 
+// APIRecordingPathUsage is the disk usage of the recordings of a path.
+type APIRecordingPathUsage struct {
+	Name         string  `json:"name"`
+	Bytes        uint64  `json:"bytes"`
+	Segments     int     `json:"segments"`
+	FreeSpace    *uint64 `json:"freeSpace"`
+	Evictions    uint64  `json:"evictions"`
+	EvictedBytes uint64  `json:"evictedBytes"`
+}
+
+// APIRecordingUsage is the disk usage of recordings.
+type APIRecordingUsage struct {
+	LastCheck    *time.Time               `json:"lastCheck"`
+	Bytes        uint64                   `json:"bytes"`
+	Evictions    uint64                   `json:"evictions"`
+	EvictedBytes uint64                   `json:"evictedBytes"`
+	Items        []*APIRecordingPathUsage `json:"items"`
+}
+
+// APIRecordingRepairAction is the action performed on a damaged segment.
+type APIRecordingRepairAction string
+
+// repair actions.
+const (
+	APIRecordingRepairActionTruncated APIRecordingRepairAction = "truncated"
+	APIRecordingRepairActionFinalized APIRecordingRepairAction = "finalized"
+	APIRecordingRepairActionRemoved   APIRecordingRepairAction = "removed"
+)
+
+// APIRecordingRepair is a repair of a recording segment.
+type APIRecordingRepair struct {
+	Time         time.Time                `json:"time"`
+	Path         string                   `json:"path"`
+	Start        time.Time                `json:"start"`
+	Action       APIRecordingRepairAction `json:"action"`
+	RemovedBytes uint64                   `json:"removedBytes"`
+	Duration     float64                  `json:"duration"`
+}
+
+// APIRecordingRepairList is a list of repairs of recording segments.
+type APIRecordingRepairList struct {
+	ItemCount int                   `json:"itemCount"`
+	PageCount int                   `json:"pageCount"`
+	Items     []*APIRecordingRepair `json:"items"`
+}
+
+// APIRecordingMarker is a marker placed on the recording timeline of a path.
+type APIRecordingMarker struct {
+	ID       uuid.UUID       `json:"id"`
+	Created  time.Time       `json:"created"`
+	Start    time.Time       `json:"start"`
+	Duration float64         `json:"duration"`
+	Label    string          `json:"label"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// APIRecordingMarkerAdd is a request to add a marker.
+type APIRecordingMarkerAdd struct {
+	Start    time.Time       `json:"start"`
+	Duration float64         `json:"duration"`
+	Label    string          `json:"label"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// APIRecordingMarkerList is a list of markers.
+type APIRecordingMarkerList struct {
+	ItemCount int                   `json:"itemCount"`
+	PageCount int                   `json:"pageCount"`
+	Items     []*APIRecordingMarker `json:"items"`
+}
+
 // APIRecordingList is a list of recordings.
 type APIRecordingList struct {
 	ItemCount int             `json:"itemCount"`
@@ -0,0 +1,8 @@
+package defs
+
+// BytesCounter is implemented by readers and publishers
+// that are able to report the data exchanged with their client.
+type BytesCounter interface {
+	BytesReceived() uint64
+	BytesSent() uint64
+}
@@ -66,6 +66,7 @@ type PathAddPublisherRes struct {
 type PathAddPublisherReq struct {
 	Author             Publisher
 	Desc               *description.Session
+	MediaProperties    map[*description.Media]*stream.MediaProperties
 	GenerateRTPPackets bool
 	FillNTP            bool
 	ConfToCompare      *conf.Path
@@ -108,6 +109,7 @@ type PathSourceStaticSetReadyRes struct {
 // PathSourceStaticSetReadyReq contains arguments of SetReady().
 type PathSourceStaticSetReadyReq struct {
 	Desc               *description.Session
+	MediaProperties    map[*description.Media]*stream.MediaProperties
 	GenerateRTPPackets bool
 	FillNTP            bool
 	Res                chan PathSourceStaticSetReadyRes
@@ -13,6 +13,7 @@ type PathAccessRequest struct {
 	Name     string
 	Query    string
 	Publish  bool
+	Playback bool
 	SkipAuth bool
 
 	// only if skipAuth = false
@@ -30,6 +31,9 @@ func (r *PathAccessRequest) ToAuthRequest() *auth.Request {
 			if r.Publish {
 				return conf.AuthActionPublish
 			}
+			if r.Playback {
+				return conf.AuthActionPlayback
+			}
 			return conf.AuthActionRead
 		}(),
 		Path:             r.Name,
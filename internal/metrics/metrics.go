@@ -70,27 +70,29 @@ type metricsParent interface {
 
 // Metrics is a metrics provider.
 type Metrics struct {
-	Address        string
-	Encryption     bool
-	ServerKey      string
-	ServerCert     string
-	AllowOrigin    string
-	TrustedProxies conf.IPNetworks
-	ReadTimeout    conf.Duration
-	WriteTimeout   conf.Duration
-	AuthManager    metricsAuthManager
-	Parent         metricsParent
-
-	httpServer   *httpp.Server
-	mutex        sync.Mutex
-	pathManager  defs.APIPathManager
-	hlsServer    defs.APIHLSServer
-	rtspServer   defs.APIRTSPServer
-	rtspsServer  defs.APIRTSPServer
-	rtmpServer   defs.APIRTMPServer
-	rtmpsServer  defs.APIRTMPServer
-	srtServer    defs.APISRTServer
-	webRTCServer defs.APIWebRTCServer
+	Address              string
+	Encryption           bool
+	ServerKey            string
+	ServerCert           string
+	AllowOrigin          string
+	TrustedProxies       conf.IPNetworks
+	ProxyProtocolSources conf.IPNetworks
+	ReadTimeout          conf.Duration
+	WriteTimeout         conf.Duration
+	AuthManager          metricsAuthManager
+	Parent               metricsParent
+
+	httpServer    *httpp.Server
+	mutex         sync.Mutex
+	pathManager   defs.APIPathManager
+	hlsServer     defs.APIHLSServer
+	rtspServer    defs.APIRTSPServer
+	rtspsServer   defs.APIRTSPServer
+	rtmpServer    defs.APIRTMPServer
+	rtmpsServer   defs.APIRTMPServer
+	srtServer     defs.APISRTServer
+	webRTCServer  defs.APIWebRTCServer
+	recordCleaner defs.APIRecordCleaner
 }
 
 // Initialize initializes metrics.
@@ -104,14 +106,15 @@ func (m *Metrics) Initialize() error {
 	router.GET("/metrics", m.onMetrics)
 
 	m.httpServer = &httpp.Server{
-		Address:      m.Address,
-		ReadTimeout:  time.Duration(m.ReadTimeout),
-		WriteTimeout: time.Duration(m.WriteTimeout),
-		Encryption:   m.Encryption,
-		ServerCert:   m.ServerCert,
-		ServerKey:    m.ServerKey,
-		Handler:      router,
-		Parent:       m,
+		Address:              m.Address,
+		ReadTimeout:          time.Duration(m.ReadTimeout),
+		WriteTimeout:         time.Duration(m.WriteTimeout),
+		Encryption:           m.Encryption,
+		ServerCert:           m.ServerCert,
+		ServerKey:            m.ServerKey,
+		ProxyProtocolSources: m.ProxyProtocolSources,
+		Handler:              router,
+		Parent:               m,
 	}
 	err := m.httpServer.Initialize()
 	if err != nil {
@@ -591,6 +594,33 @@ func (m *Metrics) onMetrics(ctx *gin.Context) {
 		}
 	}
 
+	if !interfaceIsEmpty(m.recordCleaner) &&
+		(typ == "" || typ == "recordings") &&
+		(!anyFilterActive || pathFilter != "") {
+		data := m.recordCleaner.APIUsage()
+		if len(data.Items) != 0 {
+			for _, i := range data.Items {
+				if pathFilter == "" || pathFilter == i.Name {
+					ta := tags(map[string]string{
+						"name": i.Name,
+					})
+					out += metric("recordings_bytes", ta, int64(i.Bytes))
+					out += metric("recordings_segments", ta, int64(i.Segments))
+					out += metric("recordings_evictions", ta, int64(i.Evictions))
+					out += metric("recordings_evicted_bytes", ta, int64(i.EvictedBytes))
+					if i.FreeSpace != nil {
+						out += metric("recordings_free_space_bytes", ta, int64(*i.FreeSpace))
+					}
+				}
+			}
+		} else if pathFilter == "" {
+			out += metric("recordings_bytes", "", 0)
+			out += metric("recordings_segments", "", 0)
+			out += metric("recordings_evictions", "", 0)
+			out += metric("recordings_evicted_bytes", "", 0)
+		}
+	}
+
 	ctx.Writer.WriteHeader(http.StatusOK)
 	io.WriteString(ctx.Writer, out) //nolint:errcheck
 }
@@ -650,3 +680,10 @@ func (m *Metrics) SetWebRTCServer(s defs.APIWebRTCServer) {
 	defer m.mutex.Unlock()
 	m.webRTCServer = s
 }
+
+// SetRecordCleaner is called by core.
+func (m *Metrics) SetRecordCleaner(c defs.APIRecordCleaner) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.recordCleaner = c
+}
@@ -52,6 +52,10 @@ func (dummyPathManager) APIPathsGet(string) (*defs.APIPath, error) {
 	panic("unused")
 }
 
+func (dummyPathManager) APIRecordingTrigger(string, time.Duration) error {
+	panic("unused")
+}
+
 type dummyHLSServer struct{}
 
 func (dummyHLSServer) APIMuxersList() (*defs.APIHLSMuxerList, error) {
@@ -189,6 +193,25 @@ func (dummyWebRTCServer) APISessionsKick(uuid.UUID) error {
 	panic("unused")
 }
 
+type dummyRecordCleaner struct{}
+
+func (dummyRecordCleaner) APIUsage() *defs.APIRecordingUsage {
+	freeSpace := uint64(789)
+	return &defs.APIRecordingUsage{
+		Bytes:        123,
+		Evictions:    2,
+		EvictedBytes: 456,
+		Items: []*defs.APIRecordingPathUsage{{
+			Name:         "mypath",
+			Bytes:        123,
+			Segments:     3,
+			FreeSpace:    &freeSpace,
+			Evictions:    2,
+			EvictedBytes: 456,
+		}},
+	}
+}
+
 func TestPreflightRequest(t *testing.T) {
 	m := Metrics{
 		Address:      "localhost:9998",
@@ -257,6 +280,7 @@ func TestMetrics(t *testing.T) {
 	m.SetRTMPServer(&dummyRTMPServer{})
 	m.SetRTMPSServer(&dummyRTMPServer{})
 	m.SetWebRTCServer(&dummyWebRTCServer{})
+	m.SetRecordCleaner(&dummyRecordCleaner{})
 
 	tr := &http.Transport{}
 	defer tr.CloseIdleConnections()
@@ -357,7 +381,12 @@ func TestMetrics(t *testing.T) {
 			`webrtc_sessions_rtcp_packets_received{id="f47ac10b-58cc-4372-a567-0e02b2c3d479",`+
 			`path="mypath",remoteAddr="127.0.0.1:3455",state="read"} 123`+"\n"+
 			`webrtc_sessions_rtcp_packets_sent{id="f47ac10b-58cc-4372-a567-0e02b2c3d479",`+
-			`path="mypath",remoteAddr="127.0.0.1:3455",state="read"} 456`+"\n",
+			`path="mypath",remoteAddr="127.0.0.1:3455",state="read"} 456`+"\n"+
+			`recordings_bytes{name="mypath"} 123`+"\n"+
+			`recordings_segments{name="mypath"} 3`+"\n"+
+			`recordings_evictions{name="mypath"} 2`+"\n"+
+			`recordings_evicted_bytes{name="mypath"} 456`+"\n"+
+			`recordings_free_space_bytes{name="mypath"} 789`+"\n",
 		string(byts))
 
 	require.True(t, checked)
@@ -0,0 +1,518 @@
+package playback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/google/uuid"
+)
+
+const (
+	// period between removals of expired exports.
+	exportCleanupPeriod = 1 * time.Minute
+
+	// maximum number of exports that can run at the same time.
+	exportMaxRunning = 4
+
+	exportMetadataExt = ".json"
+)
+
+var (
+	errExportNotFound = errors.New("export not found")
+	errTooManyExports = errors.New("too many running exports")
+)
+
+type exportStatus string
+
+const (
+	exportStatusRunning   exportStatus = "running"
+	exportStatusCompleted exportStatus = "completed"
+	exportStatusFailed    exportStatus = "failed"
+)
+
+func exportFileExtension(format string) string {
+	if format == "mpegts" {
+		return ".ts"
+	}
+	return ".mp4"
+}
+
+type exportJSON struct {
+	ID       uuid.UUID         `json:"id"`
+	Path     string            `json:"path"`
+	Start    time.Time         `json:"start"`
+	Duration listEntryDuration `json:"duration"`
+	Format   string            `json:"format"`
+	Tracks   []int             `json:"tracks"`
+	Status   exportStatus      `json:"status"`
+	Progress float64           `json:"progress"`
+	Error    string            `json:"error,omitempty"`
+	Size     int64             `json:"size"`
+	Created  time.Time         `json:"created"`
+	Expires  *time.Time        `json:"expires"`
+}
+
+// exportMetadata is saved next to the file of a completed export,
+// in order to restore the export after a restart.
+type exportMetadata struct {
+	ID       uuid.UUID     `json:"id"`
+	Path     string        `json:"path"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Format   string        `json:"format"`
+	Tracks   []int         `json:"tracks"`
+	Size     int64         `json:"size"`
+	Created  time.Time     `json:"created"`
+	Expires  time.Time     `json:"expires"`
+}
+
+// export is an asynchronous export of recordings into a file.
+type export struct {
+	id       uuid.UUID
+	pathName string
+	pathConf *conf.Path
+	segments []*recordstore.Segment
+	start    time.Time
+	duration time.Duration
+	format   string
+	tracks   []int
+	filePath string
+	ttl      time.Duration
+	parent   logger.Writer
+
+	created   time.Time
+	ctx       context.Context
+	ctxCancel func()
+	done      chan struct{}
+
+	mutex    sync.RWMutex
+	status   exportStatus
+	progress float64
+	err      error
+	size     int64
+	expires  time.Time
+}
+
+func (e *export) initialize(parentCtx context.Context) {
+	e.created = time.Now()
+	e.status = exportStatusRunning
+	e.ctx, e.ctxCancel = context.WithCancel(parentCtx)
+	e.done = make(chan struct{})
+
+	go e.run()
+}
+
+// close stops the export. Files of completed exports are kept.
+func (e *export) close() {
+	e.ctxCancel()
+	<-e.done
+}
+
+// remove stops the export and deletes its files.
+func (e *export) remove() {
+	e.close()
+	os.Remove(e.filePath)
+	os.Remove(e.metadataPath())
+}
+
+func (e *export) metadataPath() string {
+	return strings.TrimSuffix(e.filePath, filepath.Ext(e.filePath)) + exportMetadataExt
+}
+
+func (e *export) saveMetadata() error {
+	byts, err := json.Marshal(exportMetadata{
+		ID:       e.id,
+		Path:     e.pathName,
+		Start:    e.start,
+		Duration: e.duration,
+		Format:   e.format,
+		Tracks:   e.tracks,
+		Size:     e.size,
+		Created:  e.created,
+		Expires:  e.expires,
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(e.metadataPath(), byts, 0o644)
+}
+
+func (e *export) run() {
+	defer close(e.done)
+
+	size, err := e.runInner()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.expires = time.Now().Add(e.ttl)
+
+	if err != nil {
+		os.Remove(e.filePath)
+
+		e.status = exportStatusFailed
+		e.err = err
+
+		if e.ctx.Err() == nil {
+			e.parent.Log(logger.Error, "export %v failed: %v", e.id, err)
+		}
+	} else {
+		e.status = exportStatusCompleted
+		e.progress = 1
+		e.size = size
+
+		err = e.saveMetadata()
+		if err != nil {
+			e.parent.Log(logger.Warn, "unable to save metadata of export %v: %v", e.id, err)
+		}
+
+		e.parent.Log(logger.Info, "export %v completed", e.id)
+	}
+}
+
+func (e *export) runInner() (int64, error) {
+	f, err := os.Create(e.filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var m muxer
+
+	switch e.format {
+	case "mp4":
+		m = &muxerMP4{w: f}
+
+	case "mpegts":
+		m = &muxerMPEGTS{w: f}
+
+	default:
+		m = &muxerFMP4{w: f}
+	}
+
+	m = &muxerExport{
+		ctx:      e.ctx,
+		m:        m,
+		trackIDs: e.tracks,
+		duration: e.duration,
+		onProgress: func(p float64) {
+			e.mutex.Lock()
+			defer e.mutex.Unlock()
+			e.progress = p
+		},
+	}
+
+	err = seekAndMux(e.ctx, e.pathConf, e.segments, e.start, e.duration, m)
+	if err != nil {
+		return 0, err
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return 0, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return fi.Size(), nil
+}
+
+func (e *export) running() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.status == exportStatusRunning
+}
+
+func (e *export) completed() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.status == exportStatusCompleted
+}
+
+func (e *export) expired(now time.Time) bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.status != exportStatusRunning && now.After(e.expires)
+}
+
+func (e *export) describe() *exportJSON {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	ret := &exportJSON{
+		ID:       e.id,
+		Path:     e.pathName,
+		Start:    e.start,
+		Duration: listEntryDuration(e.duration),
+		Format:   e.format,
+		Tracks:   e.tracks,
+		Status:   e.status,
+		Progress: e.progress,
+		Size:     e.size,
+		Created:  e.created,
+	}
+
+	if ret.Tracks == nil {
+		ret.Tracks = []int{}
+	}
+
+	if e.err != nil {
+		ret.Error = e.err.Error()
+	}
+
+	if e.status != exportStatusRunning {
+		expires := e.expires
+		ret.Expires = &expires
+	}
+
+	return ret
+}
+
+// exporter runs exports and removes them when they expire.
+// Completed exports are restored from the directory when the exporter is created.
+type exporter struct {
+	directory string
+	ttl       time.Duration
+	parent    logger.Writer
+
+	ctx       context.Context
+	ctxCancel func()
+	mutex     sync.Mutex
+	exports   map[uuid.UUID]*export
+	done      chan struct{}
+}
+
+func (x *exporter) initialize() error {
+	err := os.MkdirAll(x.directory, 0o755)
+	if err != nil {
+		return err
+	}
+
+	x.ctx, x.ctxCancel = context.WithCancel(context.Background())
+	x.exports = make(map[uuid.UUID]*export)
+	x.done = make(chan struct{})
+
+	x.loadCompleted()
+
+	go x.run()
+
+	return nil
+}
+
+func (x *exporter) close() {
+	x.ctxCancel()
+	<-x.done
+
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	for _, e := range x.exports {
+		e.close()
+	}
+}
+
+// loadCompleted restores exports that were completed by a previous instance.
+func (x *exporter) loadCompleted() {
+	entries, err := os.ReadDir(x.directory)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if filepath.Ext(name) != exportMetadataExt {
+			continue
+		}
+
+		byts, err := os.ReadFile(filepath.Join(x.directory, name))
+		if err != nil {
+			continue
+		}
+
+		var m exportMetadata
+		err = json.Unmarshal(byts, &m)
+		if err != nil {
+			continue
+		}
+
+		e := &export{
+			id:        m.ID,
+			pathName:  m.Path,
+			start:     m.Start,
+			duration:  m.Duration,
+			format:    m.Format,
+			tracks:    m.Tracks,
+			filePath:  filepath.Join(x.directory, m.ID.String()+exportFileExtension(m.Format)),
+			ttl:       x.ttl,
+			parent:    x.parent,
+			created:   m.Created,
+			ctxCancel: func() {},
+			done:      make(chan struct{}),
+			status:    exportStatusCompleted,
+			progress:  1,
+			size:      m.Size,
+			expires:   m.Expires,
+		}
+		close(e.done)
+
+		if _, err := os.Stat(e.filePath); err != nil {
+			continue
+		}
+
+		x.exports[e.id] = e
+	}
+}
+
+func (x *exporter) run() {
+	defer close(x.done)
+
+	t := time.NewTicker(exportCleanupPeriod)
+	defer t.Stop()
+
+	// exports and files of a previous execution
+	x.removeExpired()
+	x.removeOrphans()
+
+	for {
+		select {
+		case <-t.C:
+			x.removeExpired()
+			x.removeOrphans()
+
+		case <-x.ctx.Done():
+			return
+		}
+	}
+}
+
+func (x *exporter) removeExpired() {
+	now := time.Now()
+
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	for id, e := range x.exports {
+		if e.expired(now) {
+			e.remove()
+			delete(x.exports, id)
+		}
+	}
+}
+
+// removeOrphans removes expired files that do not belong to any export.
+func (x *exporter) removeOrphans() {
+	entries, err := os.ReadDir(x.directory)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		id, err := uuid.Parse(strings.TrimSuffix(name, filepath.Ext(name)))
+		if err != nil {
+			continue
+		}
+
+		if _, ok := x.exports[id]; ok {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < x.ttl {
+			continue
+		}
+
+		os.Remove(filepath.Join(x.directory, name))
+	}
+}
+
+func (x *exporter) add(
+	pathName string,
+	pathConf *conf.Path,
+	segments []*recordstore.Segment,
+	start time.Time,
+	duration time.Duration,
+	format string,
+	tracks []int,
+) (*export, error) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	running := 0
+	for _, e := range x.exports {
+		if e.running() {
+			running++
+		}
+	}
+
+	if running >= exportMaxRunning {
+		return nil, errTooManyExports
+	}
+
+	id := uuid.New()
+
+	e := &export{
+		id:       id,
+		pathName: pathName,
+		pathConf: pathConf,
+		segments: segments,
+		start:    start,
+		duration: duration,
+		format:   format,
+		tracks:   tracks,
+		filePath: filepath.Join(x.directory, id.String()+exportFileExtension(format)),
+		ttl:      x.ttl,
+		parent:   x.parent,
+	}
+	e.initialize(x.ctx)
+
+	x.exports[id] = e
+
+	return e, nil
+}
+
+func (x *exporter) get(id uuid.UUID) (*export, error) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	e, ok := x.exports[id]
+	if !ok {
+		return nil, errExportNotFound
+	}
+	return e, nil
+}
+
+func (x *exporter) remove(id uuid.UUID) error {
+	x.mutex.Lock()
+	e, ok := x.exports[id]
+	if ok {
+		delete(x.exports, id)
+	}
+	x.mutex.Unlock()
+
+	if !ok {
+		return errExportNotFound
+	}
+
+	e.remove()
+	return nil
+}
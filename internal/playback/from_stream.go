@@ -0,0 +1,122 @@
+package playback
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
+	"github.com/bluenviron/gortsplib/v5/pkg/format"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+	mfmp4 "github.com/bluenviron/mediamtx/internal/protocols/fmp4"
+	"github.com/bluenviron/mediamtx/internal/stream"
+)
+
+var errNoSupportedCodecsFrom = errors.New(
+	"the stream doesn't contain any track that can be converted to fMP4")
+
+// fromStreamTracks contains tracks of a live stream.
+// Tracks are replaced when codec parameters change, in order to allow
+// the receiver to compare them with the previous ones.
+type fromStreamTracks struct {
+	tracks   []*fmp4.InitTrack
+	onTracks func([]*fmp4.InitTrack)
+	onSample func(*Sample) error
+}
+
+func (t *fromStreamTracks) setCodec(id int, codec mp4.Codec) {
+	tracks := make([]*fmp4.InitTrack, len(t.tracks))
+
+	for i, track := range t.tracks {
+		if track.ID == id {
+			track = &fmp4.InitTrack{
+				ID:        track.ID,
+				TimeScale: track.TimeScale,
+				Codec:     codec,
+			}
+		}
+		tracks[i] = track
+	}
+
+	t.tracks = tracks
+	t.onTracks(tracks)
+}
+
+type fromStreamTrack struct {
+	t         *fromStreamTracks
+	id        int
+	timeScale uint32
+}
+
+// UpdateCodec implements mfmp4.Track.
+func (t *fromStreamTrack) UpdateCodec(codec mp4.Codec) {
+	t.t.setCodec(t.id, codec)
+}
+
+// WriteSample implements mfmp4.Track.
+func (t *fromStreamTrack) WriteSample(s *mfmp4.Sample) error {
+	ptsOffset := durationMp4ToGo(int64(s.PTSOffset), t.timeScale)
+
+	// NTP of recorded samples refers to their decode time.
+	return t.t.onSample(&Sample{
+		TrackID:         t.id,
+		NTP:             s.NTP.Add(-ptsOffset),
+		PTSOffset:       ptsOffset,
+		IsNonSyncSample: s.IsNonSyncSample,
+		Payload:         s.Payload,
+	})
+}
+
+// fromStream maps a live stream to fMP4 tracks and samples.
+// onTracks is called before the first sample and every time codec parameters change.
+func fromStream(
+	desc *description.Session,
+	r *stream.Reader,
+	onTracks func([]*fmp4.InitTrack),
+	onSample func(*Sample) error,
+) error {
+	t := &fromStreamTracks{
+		onTracks: onTracks,
+		onSample: onSample,
+	}
+
+	mfmp4.FromStream(
+		desc,
+		r.OnData,
+		func(_ int, _ *description.Media, forma format.Format, codec mp4.Codec) mfmp4.Track {
+			track := &fmp4.InitTrack{
+				ID:        len(t.tracks) + 1,
+				TimeScale: uint32(forma.ClockRate()),
+				Codec:     codec,
+			}
+			t.tracks = append(t.tracks, track)
+
+			return &fromStreamTrack{
+				t:         t,
+				id:        track.ID,
+				timeScale: track.TimeScale,
+			}
+		})
+
+	if len(t.tracks) == 0 {
+		return errNoSupportedCodecsFrom
+	}
+
+	setuppedFormats := r.Formats()
+
+	n := 1
+	for _, media := range desc.Medias {
+		for _, forma := range media.Formats {
+			if !slices.Contains(setuppedFormats, forma) {
+				r.Parent.Log(logger.Warn, "skipping track %d (%s)", n, forma.Codec())
+			}
+			n++
+		}
+	}
+
+	onTracks(t.tracks)
+
+	return nil
+}
@@ -1,6 +1,13 @@
 package playback
 
-import "github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+import (
+	"errors"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+)
+
+// errMuxingComplete is returned by muxers that don't need further samples.
+var errMuxingComplete = errors.New("muxing complete")
 
 type muxer interface {
 	writeInit(init *fmp4.Init)
@@ -0,0 +1,136 @@
+package playback
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+type muxerExportTrack struct {
+	timeScale uint32
+	progress  float64
+}
+
+// muxerExport is a muxer that forwards selected tracks to another muxer
+// and computes the progress of an export.
+// Progress is measured by the payloads that have been read,
+// since some muxers read payloads when flushing only.
+type muxerExport struct {
+	ctx        context.Context
+	m          muxer
+	trackIDs   []int
+	duration   time.Duration
+	onProgress func(float64)
+
+	tracks   map[int]*muxerExportTrack
+	curTrack *muxerExportTrack
+	written  bool
+}
+
+func (m *muxerExport) trackSelected(id int) bool {
+	if len(m.trackIDs) == 0 {
+		return true
+	}
+
+	for _, sid := range m.trackIDs {
+		if sid == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *muxerExport) writeInit(init *fmp4.Init) {
+	m.tracks = make(map[int]*muxerExportTrack)
+	outInit := &fmp4.Init{}
+
+	for _, track := range init.Tracks {
+		if m.trackSelected(track.ID) {
+			outInit.Tracks = append(outInit.Tracks, track)
+			m.tracks[track.ID] = &muxerExportTrack{
+				timeScale: track.TimeScale,
+			}
+		}
+	}
+
+	m.m.writeInit(outInit)
+}
+
+func (m *muxerExport) setTrack(trackID int) {
+	m.curTrack = m.tracks[trackID]
+	if m.curTrack != nil {
+		m.m.setTrack(trackID)
+	}
+}
+
+func (m *muxerExport) updateProgress(track *muxerExportTrack, dts int64) {
+	p := float64(durationMp4ToGo(dts, track.timeScale)) / float64(m.duration)
+	if p > 1 {
+		p = 1
+	}
+	if p <= track.progress {
+		return
+	}
+	track.progress = p
+
+	sum := float64(0)
+	for _, t := range m.tracks {
+		sum += t.progress
+	}
+	m.onProgress(sum / float64(len(m.tracks)))
+}
+
+func (m *muxerExport) writeSample(
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	payloadSize uint32,
+	getPayload func() ([]byte, error),
+) error {
+	if m.curTrack == nil {
+		return nil
+	}
+
+	// exports can last long, allow to stop them
+	if err := m.ctx.Err(); err != nil {
+		return err
+	}
+
+	m.written = true
+	track := m.curTrack
+
+	return m.m.writeSample(dts, ptsOffset, isNonSyncSample, payloadSize, func() ([]byte, error) {
+		if err := m.ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		pl, err := getPayload()
+		if err != nil {
+			return nil, err
+		}
+
+		m.updateProgress(track, dts)
+		return pl, nil
+	})
+}
+
+func (m *muxerExport) writeFinalDTS(dts int64) {
+	if m.curTrack != nil {
+		m.m.writeFinalDTS(dts)
+	}
+}
+
+func (m *muxerExport) flush() error {
+	if len(m.tracks) == 0 {
+		return fmt.Errorf("recordings do not contain any of the selected tracks")
+	}
+
+	if !m.written {
+		return recordstore.ErrNoSegmentsFound
+	}
+
+	return m.m.flush()
+}
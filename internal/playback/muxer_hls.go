@@ -0,0 +1,160 @@
+package playback
+
+import (
+	"io"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+type muxerHLSSegmentTrack struct {
+	id        int
+	timeScale uint32
+	started   bool
+	done      bool
+	firstDTS  int64
+	lastDTS   int64
+	samples   []*fmp4.Sample
+}
+
+func (t *muxerHLSSegmentTrack) setLastDuration(dts int64) {
+	duration := dts - t.lastDTS
+	if duration < 0 {
+		duration = 0
+	}
+	t.samples[len(t.samples)-1].Duration = uint32(duration)
+}
+
+// muxerHLSSegment writes a single HLS media segment.
+// Each track starts with the first sync sample after the segment start
+// and ends before the first sync sample after the segment end,
+// in order to obtain segments that begin with a keyframe and do not overlap.
+// When trackID is set, the segment contains that track only.
+type muxerHLSSegment struct {
+	w        io.Writer
+	duration time.Duration
+	baseTime time.Duration
+	trackID  int
+
+	tracks    []*muxerHLSSegmentTrack
+	curTrack  *muxerHLSSegmentTrack
+	remaining int
+}
+
+func (w *muxerHLSSegment) writeInit(init *fmp4.Init) {
+	w.tracks = nil
+
+	for _, track := range init.Tracks {
+		if w.trackID != 0 && track.ID != w.trackID {
+			continue
+		}
+
+		w.tracks = append(w.tracks, &muxerHLSSegmentTrack{
+			id:        track.ID,
+			timeScale: track.TimeScale,
+		})
+	}
+
+	w.remaining = len(w.tracks)
+}
+
+func (w *muxerHLSSegment) setTrack(trackID int) {
+	w.curTrack = nil
+
+	for _, track := range w.tracks {
+		if track.id == trackID {
+			w.curTrack = track
+			return
+		}
+	}
+}
+
+func (w *muxerHLSSegment) writeSample(
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	_ uint32,
+	getPayload func() ([]byte, error),
+) error {
+	track := w.curTrack
+
+	if track == nil || track.done {
+		return nil
+	}
+
+	if !isNonSyncSample && dts >= durationGoToMp4(w.duration, track.timeScale) {
+		if track.started {
+			track.setLastDuration(dts)
+		}
+		track.done = true
+
+		w.remaining--
+		if w.remaining == 0 {
+			return errMuxingComplete
+		}
+		return nil
+	}
+
+	if !track.started {
+		// samples that precede the first sync sample belong to the previous segment
+		if dts < 0 || isNonSyncSample {
+			return nil
+		}
+
+		track.started = true
+		track.firstDTS = dts
+	} else {
+		track.setLastDuration(dts)
+	}
+
+	pl, err := getPayload()
+	if err != nil {
+		return err
+	}
+
+	track.samples = append(track.samples, &fmp4.Sample{
+		PTSOffset:       ptsOffset,
+		IsNonSyncSample: isNonSyncSample,
+		Payload:         pl,
+	})
+	track.lastDTS = dts
+
+	return nil
+}
+
+func (w *muxerHLSSegment) writeFinalDTS(dts int64) {
+	track := w.curTrack
+
+	if track != nil && track.started && !track.done {
+		track.setLastDuration(dts)
+	}
+}
+
+func (w *muxerHLSSegment) flush() error {
+	var part fmp4.Part
+
+	for _, track := range w.tracks {
+		if len(track.samples) != 0 {
+			part.Tracks = append(part.Tracks, &fmp4.PartTrack{
+				ID:       track.id,
+				BaseTime: uint64(track.firstDTS + durationGoToMp4(w.baseTime, track.timeScale)),
+				Samples:  track.samples,
+			})
+		}
+	}
+
+	if part.Tracks == nil {
+		return recordstore.ErrNoSegmentsFound
+	}
+
+	var buf seekablebuffer.Buffer
+	err := part.Marshal(&buf)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.w.Write(buf.Bytes())
+	return err
+}
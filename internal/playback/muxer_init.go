@@ -0,0 +1,34 @@
+package playback
+
+import (
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+)
+
+// muxerInit stores the initialization section, ignoring samples.
+type muxerInit struct {
+	init *fmp4.Init
+}
+
+func (w *muxerInit) writeInit(init *fmp4.Init) {
+	w.init = init
+}
+
+func (w *muxerInit) setTrack(_ int) {
+}
+
+func (w *muxerInit) writeSample(
+	_ int64,
+	_ int32,
+	_ bool,
+	_ uint32,
+	_ func() ([]byte, error),
+) error {
+	return errMuxingComplete
+}
+
+func (w *muxerInit) writeFinalDTS(_ int64) {
+}
+
+func (w *muxerInit) flush() error {
+	return nil
+}
@@ -0,0 +1,105 @@
+package playback
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/pmp4"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+// duration of the frame inside single-frame files.
+const keyframeDuration = 1 * time.Second
+
+func findVideoTrack(tracks []*fmp4.InitTrack) *fmp4.InitTrack {
+	for _, track := range tracks {
+		if track.Codec.IsVideo() {
+			return track
+		}
+	}
+	return nil
+}
+
+// writeKeyframe writes a MP4 file that contains a single keyframe.
+func writeKeyframe(w io.Writer, track *fmp4.InitTrack, payload []byte) error {
+	h := pmp4.Presentation{
+		Tracks: []*pmp4.Track{{
+			ID:        track.ID,
+			TimeScale: track.TimeScale,
+			Codec:     track.Codec,
+			Samples: []*pmp4.Sample{{
+				Duration:    uint32(durationGoToMp4(keyframeDuration, track.TimeScale)),
+				PayloadSize: uint32(len(payload)),
+				GetPayload: func() ([]byte, error) {
+					return payload, nil
+				},
+			}},
+		}},
+	}
+
+	return h.Marshal(w)
+}
+
+// muxerKeyframe is a muxer that writes the keyframe of the first video track
+// that precedes the start into a single-frame MP4 file.
+// When there are no keyframes before the start, the first keyframe after the start is used.
+type muxerKeyframe struct {
+	w io.Writer
+
+	track    *fmp4.InitTrack
+	curTrack bool
+	payload  []byte
+}
+
+func (m *muxerKeyframe) writeInit(init *fmp4.Init) {
+	m.track = findVideoTrack(init.Tracks)
+}
+
+func (m *muxerKeyframe) setTrack(trackID int) {
+	m.curTrack = (m.track != nil && trackID == m.track.ID)
+}
+
+func (m *muxerKeyframe) writeSample(
+	dts int64,
+	_ int32,
+	isNonSyncSample bool,
+	_ uint32,
+	getPayload func() ([]byte, error),
+) error {
+	if !m.curTrack || isNonSyncSample {
+		return nil
+	}
+
+	if dts > 0 && m.payload != nil {
+		return errMuxingComplete
+	}
+
+	payload, err := getPayload()
+	if err != nil {
+		return err
+	}
+	m.payload = payload
+
+	if dts >= 0 {
+		return errMuxingComplete
+	}
+
+	return nil
+}
+
+func (m *muxerKeyframe) writeFinalDTS(_ int64) {
+}
+
+func (m *muxerKeyframe) flush() error {
+	if m.track == nil {
+		return fmt.Errorf("recordings do not contain any video track")
+	}
+
+	if m.payload == nil {
+		return recordstore.ErrNoSegmentsFound
+	}
+
+	return writeKeyframe(m.w, m.track, m.payload)
+}
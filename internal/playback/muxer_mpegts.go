@@ -0,0 +1,224 @@
+package playback
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mpegts"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+const (
+	mpegtsBufferSize = 64 * 1024
+)
+
+type muxerMPEGTSSample struct {
+	track      *muxerMPEGTSTrack
+	dts        int64
+	pts        int64
+	getPayload func() ([]byte, error)
+}
+
+type muxerMPEGTSTrack struct {
+	id        int
+	timeScale uint32
+	track     *mpegts.Track
+	write     func(w *mpegts.Writer, pts int64, dts int64, payload []byte) error
+	samples   []*muxerMPEGTSSample
+	lastDTS   int64
+}
+
+func newMuxerMPEGTSTrack(track *fmp4.InitTrack) *muxerMPEGTSTrack {
+	t := &muxerMPEGTSTrack{
+		id:        track.ID,
+		timeScale: track.TimeScale,
+	}
+
+	switch codec := track.Codec.(type) {
+	case *mp4.CodecH265:
+		t.track = &mpegts.Track{Codec: &mpegts.CodecH265{}}
+		t.write = func(w *mpegts.Writer, pts int64, dts int64, payload []byte) error {
+			var au h264.AVCC
+			err := au.Unmarshal(payload)
+			if err != nil {
+				return err
+			}
+			return w.WriteH265(t.track, pts, dts, au)
+		}
+
+	case *mp4.CodecH264:
+		t.track = &mpegts.Track{Codec: &mpegts.CodecH264{}}
+		t.write = func(w *mpegts.Writer, pts int64, dts int64, payload []byte) error {
+			var au h264.AVCC
+			err := au.Unmarshal(payload)
+			if err != nil {
+				return err
+			}
+			return w.WriteH264(t.track, pts, dts, au)
+		}
+
+	case *mp4.CodecMPEG4Video:
+		t.track = &mpegts.Track{Codec: &mpegts.CodecMPEG4Video{}}
+		t.write = func(w *mpegts.Writer, pts int64, _ int64, payload []byte) error {
+			return w.WriteMPEG4Video(t.track, pts, payload)
+		}
+
+	case *mp4.CodecMPEG1Video:
+		t.track = &mpegts.Track{Codec: &mpegts.CodecMPEG1Video{}}
+		t.write = func(w *mpegts.Writer, pts int64, _ int64, payload []byte) error {
+			return w.WriteMPEG1Video(t.track, pts, payload)
+		}
+
+	case *mp4.CodecOpus:
+		t.track = &mpegts.Track{Codec: &mpegts.CodecOpus{
+			ChannelCount: codec.ChannelCount,
+		}}
+		t.write = func(w *mpegts.Writer, pts int64, _ int64, payload []byte) error {
+			return w.WriteOpus(t.track, pts, [][]byte{payload})
+		}
+
+	case *mp4.CodecMPEG4Audio:
+		t.track = &mpegts.Track{Codec: &mpegts.CodecMPEG4Audio{
+			Config: codec.Config,
+		}}
+		t.write = func(w *mpegts.Writer, pts int64, _ int64, payload []byte) error {
+			return w.WriteMPEG4Audio(t.track, pts, [][]byte{payload})
+		}
+
+	case *mp4.CodecMPEG1Audio:
+		t.track = &mpegts.Track{Codec: &mpegts.CodecMPEG1Audio{}}
+		t.write = func(w *mpegts.Writer, pts int64, _ int64, payload []byte) error {
+			return w.WriteMPEG1Audio(t.track, pts, [][]byte{payload})
+		}
+
+	case *mp4.CodecAC3:
+		t.track = &mpegts.Track{Codec: &mpegts.CodecAC3{}}
+		t.write = func(w *mpegts.Writer, pts int64, _ int64, payload []byte) error {
+			return w.WriteAC3(t.track, pts, payload)
+		}
+
+	default:
+		return nil
+	}
+
+	return t
+}
+
+// muxerMPEGTS writes recordings in MPEG-TS format.
+// Like muxerMP4, samples are collected and written when flushing,
+// in order to interleave tracks.
+type muxerMPEGTS struct {
+	w io.Writer
+
+	tracks   []*muxerMPEGTSTrack
+	curTrack *muxerMPEGTSTrack
+}
+
+func (w *muxerMPEGTS) writeInit(init *fmp4.Init) {
+	w.tracks = nil
+
+	for _, track := range init.Tracks {
+		t := newMuxerMPEGTSTrack(track)
+		if t != nil {
+			w.tracks = append(w.tracks, t)
+		}
+	}
+}
+
+func (w *muxerMPEGTS) setTrack(trackID int) {
+	w.curTrack = nil
+
+	for _, track := range w.tracks {
+		if track.id == trackID {
+			w.curTrack = track
+			break
+		}
+	}
+}
+
+func (w *muxerMPEGTS) writeSample(
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	_ uint32,
+	getPayload func() ([]byte, error),
+) error {
+	// track can't be converted to MPEG-TS
+	if w.curTrack == nil {
+		return nil
+	}
+
+	// remove GOPs before the GOP of the first sample
+	if (dts < 0 || (dts >= 0 && w.curTrack.lastDTS < 0)) && !isNonSyncSample {
+		w.curTrack.samples = w.curTrack.samples[:0]
+	}
+
+	w.curTrack.samples = append(w.curTrack.samples, &muxerMPEGTSSample{
+		track:      w.curTrack,
+		dts:        multiplyAndDivide(dts, 90000, int64(w.curTrack.timeScale)),
+		pts:        multiplyAndDivide(dts+int64(ptsOffset), 90000, int64(w.curTrack.timeScale)),
+		getPayload: getPayload,
+	})
+	w.curTrack.lastDTS = dts
+
+	return nil
+}
+
+func (w *muxerMPEGTS) writeFinalDTS(_ int64) {
+}
+
+func (w *muxerMPEGTS) flush() error {
+	if len(w.tracks) == 0 {
+		return fmt.Errorf("no tracks can be converted to MPEG-TS")
+	}
+
+	var samples []*muxerMPEGTSSample
+	mpegtsTracks := make([]*mpegts.Track, len(w.tracks))
+
+	for i, track := range w.tracks {
+		samples = append(samples, track.samples...)
+		mpegtsTracks[i] = track.track
+	}
+
+	if len(samples) == 0 {
+		return recordstore.ErrNoSegmentsFound
+	}
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].dts < samples[j].dts
+	})
+
+	// samples that precede the start have negative timestamps
+	offset := int64(0)
+	if samples[0].dts < 0 {
+		offset = -samples[0].dts
+	}
+
+	bw := bufio.NewWriterSize(w.w, mpegtsBufferSize)
+
+	mw := &mpegts.Writer{W: bw, Tracks: mpegtsTracks}
+	err := mw.Initialize()
+	if err != nil {
+		return err
+	}
+
+	for _, sample := range samples {
+		var pl []byte
+		pl, err = sample.getPayload()
+		if err != nil {
+			return err
+		}
+
+		err = sample.track.write(mw, sample.pts+offset, sample.dts+offset, pl)
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,255 @@
+package playback
+
+import (
+	"context"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+type muxerMultiEventType int
+
+const (
+	muxerMultiEventInit muxerMultiEventType = iota
+	muxerMultiEventSample
+	muxerMultiEventFinalDTS
+	muxerMultiEventFlush
+	muxerMultiEventDone
+)
+
+type muxerMultiEvent struct {
+	typ             muxerMultiEventType
+	init            *fmp4.Init
+	trackID         int
+	dts             int64
+	ptsOffset       int32
+	isNonSyncSample bool
+	payloadSize     uint32
+	getPayload      func() ([]byte, error)
+	err             error
+}
+
+// muxerMultiSource is a muxer that forwards the samples of a path to seekAndMuxMulti.
+// Each event is forwarded only after the previous one has been processed,
+// in order to allow samples of all paths to be merged by timestamp.
+type muxerMultiSource struct {
+	terminate <-chan struct{}
+
+	events   chan *muxerMultiEvent
+	ack      chan struct{}
+	done     chan error
+	curTrack int
+	finished bool
+}
+
+func (m *muxerMultiSource) send(e *muxerMultiEvent) error {
+	select {
+	case m.events <- e:
+	case <-m.terminate:
+		return errTerminated
+	}
+
+	select {
+	case <-m.ack:
+		return nil
+	case <-m.terminate:
+		return errTerminated
+	}
+}
+
+func (m *muxerMultiSource) writeInit(init *fmp4.Init) {
+	m.send(&muxerMultiEvent{ //nolint:errcheck
+		typ:  muxerMultiEventInit,
+		init: init,
+	})
+}
+
+func (m *muxerMultiSource) setTrack(trackID int) {
+	m.curTrack = trackID
+}
+
+func (m *muxerMultiSource) writeSample(
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	payloadSize uint32,
+	getPayload func() ([]byte, error),
+) error {
+	return m.send(&muxerMultiEvent{
+		typ:             muxerMultiEventSample,
+		trackID:         m.curTrack,
+		dts:             dts,
+		ptsOffset:       ptsOffset,
+		isNonSyncSample: isNonSyncSample,
+		payloadSize:     payloadSize,
+		getPayload:      getPayload,
+	})
+}
+
+func (m *muxerMultiSource) writeFinalDTS(dts int64) {
+	m.send(&muxerMultiEvent{ //nolint:errcheck
+		typ:     muxerMultiEventFinalDTS,
+		trackID: m.curTrack,
+		dts:     dts,
+	})
+}
+
+func (m *muxerMultiSource) flush() error {
+	select {
+	case m.events <- &muxerMultiEvent{typ: muxerMultiEventFlush}:
+	case <-m.terminate:
+		return errTerminated
+	}
+
+	// keep segments open until the output has been flushed,
+	// since payloads may be read during the flush.
+	<-m.terminate
+	return nil
+}
+
+func (m *muxerMultiSource) next() *muxerMultiEvent {
+	select {
+	case e := <-m.events:
+		return e
+	case err := <-m.done:
+		m.finished = true
+		return &muxerMultiEvent{typ: muxerMultiEventDone, err: err}
+	}
+}
+
+type muxerMultiTrack struct {
+	id        int
+	timeScale uint32
+}
+
+// multiPath is a path read by seekAndMuxMulti.
+type multiPath struct {
+	pathConf *conf.Path
+	segments []*recordstore.Segment
+}
+
+// seekAndMuxMulti muxes recordings of multiple paths into a single output,
+// in which each track of each path is a separate track.
+// Tracks are aligned by the absolute timestamps of recordings.
+func seekAndMuxMulti(
+	ctx context.Context,
+	paths []*multiPath,
+	start time.Time,
+	duration time.Duration,
+	m muxer,
+) error {
+	terminate := make(chan struct{})
+	sources := make([]*muxerMultiSource, len(paths))
+
+	for i, pa := range paths {
+		src := &muxerMultiSource{
+			terminate: terminate,
+			events:    make(chan *muxerMultiEvent),
+			ack:       make(chan struct{}),
+			done:      make(chan error, 1),
+		}
+		sources[i] = src
+
+		go func() {
+			src.done <- seekAndMux(ctx, pa.pathConf, pa.segments, start, duration, src)
+		}()
+	}
+
+	defer func() {
+		close(terminate)
+		for _, src := range sources {
+			if !src.finished {
+				<-src.done
+			}
+		}
+	}()
+
+	// track IDs of each path are mapped to sequential IDs
+	trackMaps := make([]map[int]*muxerMultiTrack, len(sources))
+	outInit := &fmp4.Init{}
+
+	for i, src := range sources {
+		e := src.next()
+		if e.typ != muxerMultiEventInit {
+			if e.err != nil {
+				return e.err
+			}
+			return recordstore.ErrNoSegmentsFound
+		}
+
+		trackMaps[i] = make(map[int]*muxerMultiTrack)
+
+		for _, track := range e.init.Tracks {
+			outTrack := *track
+			outTrack.ID = len(outInit.Tracks) + 1
+			outInit.Tracks = append(outInit.Tracks, &outTrack)
+
+			trackMaps[i][track.ID] = &muxerMultiTrack{
+				id:        outTrack.ID,
+				timeScale: track.TimeScale,
+			}
+		}
+
+		src.ack <- struct{}{}
+	}
+
+	m.writeInit(outInit)
+
+	heads := make([]*muxerMultiEvent, len(sources))
+	for i, src := range sources {
+		heads[i] = src.next()
+	}
+
+	for {
+		// pick the oldest event
+		var cur int
+		var curTime time.Duration
+		found := false
+
+		for i, e := range heads {
+			switch e.typ {
+			case muxerMultiEventSample, muxerMultiEventFinalDTS:
+				var t time.Duration
+				if track, ok := trackMaps[i][e.trackID]; ok {
+					t = durationMp4ToGo(e.dts, track.timeScale)
+				}
+				if !found || t < curTime {
+					cur = i
+					curTime = t
+					found = true
+				}
+
+			case muxerMultiEventDone:
+				if e.err != nil {
+					return e.err
+				}
+			}
+		}
+
+		// all paths have been read
+		if !found {
+			return m.flush()
+		}
+
+		e := heads[cur]
+
+		// skip tracks that are not part of the initialization section
+		if track, ok := trackMaps[cur][e.trackID]; ok {
+			m.setTrack(track.id)
+
+			if e.typ == muxerMultiEventSample {
+				err := m.writeSample(e.dts, e.ptsOffset, e.isNonSyncSample, e.payloadSize, e.getPayload)
+				if err != nil {
+					return err
+				}
+			} else {
+				m.writeFinalDTS(e.dts)
+			}
+		}
+
+		sources[cur].ack <- struct{}{}
+		heads[cur] = sources[cur].next()
+	}
+}
@@ -0,0 +1,122 @@
+package playback
+
+import (
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+)
+
+type muxerSamplesTrack struct {
+	id        int
+	timeScale uint32
+	gop       []*Sample
+}
+
+// muxerSamples passes samples to a callback.
+// Samples that precede the start are buffered, in order to begin from a sync sample.
+type muxerSamples struct {
+	start    time.Time
+	onTracks func(tracks []*fmp4.InitTrack)
+	onSample func(sample *Sample) error
+
+	tracks   []*muxerSamplesTrack
+	curTrack *muxerSamplesTrack
+}
+
+func (w *muxerSamples) writeInit(init *fmp4.Init) {
+	w.tracks = make([]*muxerSamplesTrack, len(init.Tracks))
+
+	for i, track := range init.Tracks {
+		w.tracks[i] = &muxerSamplesTrack{
+			id:        track.ID,
+			timeScale: track.TimeScale,
+		}
+	}
+
+	if w.onTracks != nil {
+		w.onTracks(init.Tracks)
+	}
+}
+
+func (w *muxerSamples) setTrack(trackID int) {
+	for _, track := range w.tracks {
+		if track.id == trackID {
+			w.curTrack = track
+			return
+		}
+	}
+}
+
+func (w *muxerSamples) flushGOP(track *muxerSamplesTrack) error {
+	for _, sample := range track.gop {
+		err := w.onSample(sample)
+		if err != nil {
+			return err
+		}
+	}
+	track.gop = nil
+	return nil
+}
+
+func (w *muxerSamples) writeSample(
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	_ uint32,
+	getPayload func() ([]byte, error),
+) error {
+	track := w.curTrack
+
+	// sample precedes the start and the GOP has not begun yet
+	if dts < 0 && isNonSyncSample && len(track.gop) == 0 {
+		return nil
+	}
+
+	pl, err := getPayload()
+	if err != nil {
+		return err
+	}
+
+	sample := &Sample{
+		TrackID:         track.id,
+		NTP:             w.start.Add(durationMp4ToGo(dts, track.timeScale)),
+		PTSOffset:       durationMp4ToGo(int64(ptsOffset), track.timeScale),
+		IsNonSyncSample: isNonSyncSample,
+		Payload:         pl,
+	}
+
+	if dts < 0 {
+		if !isNonSyncSample {
+			track.gop = track.gop[:0]
+		}
+		track.gop = append(track.gop, sample)
+		return nil
+	}
+
+	// a sync sample at the start makes the preceding GOP unnecessary
+	if !isNonSyncSample {
+		track.gop = nil
+	}
+
+	err = w.flushGOP(track)
+	if err != nil {
+		return err
+	}
+
+	return w.onSample(sample)
+}
+
+func (w *muxerSamples) writeFinalDTS(_ int64) {
+}
+
+func (w *muxerSamples) flush() error {
+	// start is placed after the last sample
+	for _, track := range w.tracks {
+		err := w.flushGOP(track)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
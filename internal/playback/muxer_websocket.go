@@ -0,0 +1,164 @@
+package playback
+
+import (
+	"io"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+)
+
+type muxerWebSocketTrack struct {
+	id        int
+	timeScale uint32
+	firstDTS  int64
+	lastDTS   int64
+	samples   []*fmp4.Sample
+}
+
+// muxerWebSocket groups samples into fMP4 parts.
+// Decode times are relative to hlsBaseTime, in order to allow players
+// to place parts of different seeks and recordings on the same timeline.
+type muxerWebSocket struct {
+	w io.Writer
+
+	init               *fmp4.Init
+	nextSequenceNumber uint32
+	tracks             []*muxerWebSocketTrack
+	outBuf             seekablebuffer.Buffer
+}
+
+// setTracks flushes pending samples and sets tracks of next samples.
+// The initialization section is written together with the next part.
+func (w *muxerWebSocket) setTracks(tracks []*fmp4.InitTrack) error {
+	err := w.flush()
+	if err != nil {
+		return err
+	}
+
+	w.init = &fmp4.Init{Tracks: tracks}
+
+	w.tracks = make([]*muxerWebSocketTrack, len(tracks))
+
+	for i, track := range tracks {
+		w.tracks[i] = &muxerWebSocketTrack{
+			id:        track.ID,
+			timeScale: track.TimeScale,
+		}
+	}
+
+	return nil
+}
+
+func (w *muxerWebSocket) writeSample(sample *Sample) error {
+	var track *muxerWebSocketTrack
+	for _, t := range w.tracks {
+		if t.id == sample.TrackID {
+			track = t
+			break
+		}
+	}
+	if track == nil {
+		return nil
+	}
+
+	dts := durationGoToMp4(sample.NTP.Sub(hlsBaseTime), track.timeScale)
+
+	if len(track.samples) != 0 {
+		duration := dts - track.lastDTS
+		if duration < 0 {
+			duration = 0
+		}
+		track.samples[len(track.samples)-1].Duration = uint32(duration)
+	} else {
+		track.firstDTS = dts
+	}
+
+	track.samples = append(track.samples, &fmp4.Sample{
+		PTSOffset:       int32(durationGoToMp4(sample.PTSOffset, track.timeScale)),
+		IsNonSyncSample: sample.IsNonSyncSample,
+		Payload:         sample.Payload,
+	})
+	track.lastDTS = dts
+
+	if (track.lastDTS - track.firstDTS) >= durationGoToMp4(partDuration, track.timeScale) {
+		return w.writePart(false)
+	}
+
+	return nil
+}
+
+func (w *muxerWebSocket) writePart(final bool) error {
+	var part fmp4.Part
+
+	for _, track := range w.tracks {
+		n := len(track.samples)
+
+		// do not write the last sample, since its duration is unknown
+		if !final {
+			n--
+		}
+
+		if n <= 0 {
+			continue
+		}
+
+		// estimate duration of the last sample from the previous one
+		if final && n >= 2 && track.samples[n-1].Duration == 0 {
+			track.samples[n-1].Duration = track.samples[n-2].Duration
+		}
+
+		part.Tracks = append(part.Tracks, &fmp4.PartTrack{
+			ID:       track.id,
+			BaseTime: uint64(track.firstDTS),
+			Samples:  track.samples[:n],
+		})
+
+		if final {
+			track.samples = nil
+		} else {
+			track.samples = []*fmp4.Sample{track.samples[n]}
+			track.firstDTS = track.lastDTS
+		}
+	}
+
+	if part.Tracks == nil {
+		return nil
+	}
+
+	if w.init != nil {
+		err := w.init.Marshal(&w.outBuf)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.w.Write(w.outBuf.Bytes())
+		if err != nil {
+			return err
+		}
+
+		w.init = nil
+		w.outBuf.Reset()
+	}
+
+	part.SequenceNumber = w.nextSequenceNumber
+	w.nextSequenceNumber++
+
+	err := part.Marshal(&w.outBuf)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.w.Write(w.outBuf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	w.outBuf.Reset()
+
+	return nil
+}
+
+// flush writes all pending samples.
+func (w *muxerWebSocket) flush() error {
+	return w.writePart(true)
+}
@@ -0,0 +1,231 @@
+package playback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/protocols/dash"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+// entryBandwidth estimates the bitrate of an entry from the size of its segments.
+func entryBandwidth(ctx context.Context, pathConf *conf.Path, entry listEntry) int {
+	var size int64
+	var duration time.Duration
+
+	for _, ps := range entry.segments {
+		s, err := recordstore.SegmentSize(ctx, pathConf, ps.segment)
+		if err != nil {
+			continue
+		}
+		size += s
+		duration += ps.duration
+	}
+
+	if duration <= 0 {
+		return 0
+	}
+
+	return int(float64(size*8) / duration.Seconds())
+}
+
+// entryInitSegment returns the segment that contains the start of an entry.
+func entryInitSegment(entry listEntry) *recordstore.Segment {
+	ret := entry.segments[0]
+
+	for _, ps := range entry.segments[1:] {
+		if ps.start.After(entry.Start) {
+			break
+		}
+		ret = ps
+	}
+
+	return ret.segment
+}
+
+// generateDASHPeriod generates the period of an entry,
+// with an adaptation set for each track that points to HLS segments of that track.
+func generateDASHPeriod(
+	ctx context.Context,
+	pathConf *conf.Path,
+	entry listEntry,
+	baseQuery url.Values,
+	origin time.Time,
+	incomplete bool,
+) (*dash.Period, error) {
+	init, err := readInit(ctx, pathConf, entryInitSegment(entry), entry.Start)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := entrySegments(entry, incomplete)
+	bandwidth := entryBandwidth(ctx, pathConf, entry)
+
+	duration := dash.Duration(time.Duration(entry.Duration))
+
+	period := &dash.Period{
+		ID:       strconv.FormatInt(entry.Start.UnixNano(), 10),
+		Start:    dash.Duration(entry.Start.Sub(origin)),
+		Duration: &duration,
+	}
+
+	for i, track := range init.Tracks {
+		query := url.Values{}
+		for key, values := range baseQuery {
+			query[key] = values
+		}
+		query.Set("track", strconv.FormatInt(int64(track.ID), 10))
+
+		pto := uint64(durationGoToMp4(entry.Start.Sub(hlsBaseTime), track.TimeScale))
+
+		sl := &dash.SegmentList{
+			Timescale:              track.TimeScale,
+			PresentationTimeOffset: pto,
+			Initialization: &dash.URL{
+				SourceURL: "init.mp4?" + hlsQuery(query, entry.Start, nil),
+			},
+		}
+
+		durations := make([]uint64, len(segments))
+
+		for j, seg := range segments {
+			durations[j] = uint64(durationGoToMp4(seg.duration, track.TimeScale))
+			sl.SegmentURLs = append(sl.SegmentURLs, &dash.SegmentURL{
+				Media: "segment.mp4?" + hlsQuery(query, seg.start, &seg.duration),
+			})
+		}
+
+		sl.SegmentTimeline = dash.NewTimeline(pto, durations)
+
+		as := dash.NewAdaptationSet(i, strconv.FormatInt(int64(track.ID), 10), track.Codec, bandwidth)
+		as.Representations[0].SegmentList = sl
+		period.AdaptationSets = append(period.AdaptationSets, as)
+	}
+
+	return period, nil
+}
+
+func generateDASHManifest(
+	ctx context.Context,
+	pathConf *conf.Path,
+	entries []listEntry,
+	baseQuery url.Values,
+	live bool,
+) ([]byte, error) {
+	m := &dash.MPD{
+		Profiles:      dash.ProfileMain,
+		MinBufferTime: dash.Duration(hlsSegmentDuration),
+	}
+
+	// in dynamic manifests, the decode time of segments is relative to hlsBaseTime,
+	// therefore segments become available at their wall clock date.
+	var origin time.Time
+
+	if live {
+		now := time.Now()
+		origin = hlsBaseTime
+
+		m.Type = "dynamic"
+		m.AvailabilityStartTime = &origin
+		m.PublishTime = &now
+		minimumUpdatePeriod := dash.Duration(hlsSegmentDuration)
+		m.MinimumUpdatePeriod = &minimumUpdatePeriod
+		m.UTCTiming = &dash.UTCTiming{
+			SchemeIDURI: dash.UTCTimingDirect,
+			Value:       now.UTC().Format(time.RFC3339Nano),
+		}
+	} else {
+		origin = entries[0].Start
+		lastEntry := entries[len(entries)-1]
+
+		m.Type = "static"
+		mediaPresentationDuration := dash.Duration(
+			lastEntry.Start.Add(time.Duration(lastEntry.Duration)).Sub(origin))
+		m.MediaPresentationDuration = &mediaPresentationDuration
+	}
+
+	for i, entry := range entries {
+		period, err := generateDASHPeriod(ctx, pathConf, entry, baseQuery, origin,
+			live && i == (len(entries)-1))
+		if err != nil {
+			return nil, err
+		}
+
+		m.Periods = append(m.Periods, period)
+	}
+
+	return m.Marshal()
+}
+
+func (s *Server) onDASHManifest(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	duration, err := parseDuration(ctx.Query("duration"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid duration: %w", err))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	end := start.Add(duration)
+	segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	entries, err := parseAndConcatenate(ctx.Request.Context(), pathConf, segments)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	entries = clipEntries(entries, &start, &end)
+	if len(entries) == 0 {
+		s.writeError(ctx, http.StatusNotFound, recordstore.ErrNoSegmentsFound)
+		return
+	}
+
+	// keep additional query parameters (e.g. credentials) in segment URLs
+	query := ctx.Request.URL.Query()
+	query.Del("start")
+	query.Del("duration")
+
+	// when the requested interval is not over yet, recordings can still grow
+	live := end.After(time.Now())
+
+	byts, err := generateDASHManifest(ctx.Request.Context(), pathConf, entries, query, live)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Data(http.StatusOK, dash.MPDType, byts)
+}
@@ -0,0 +1,152 @@
+package playback
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/protocols/dash"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnDASH(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegmentGOP(t, filepath.Join(dir, "mypath", "2008-11-07_11-22-00-000000.mp4"), 10)
+	writeSegmentGOP(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-00-000000.mp4"), 4)
+
+	s := &Server{
+		Address:      "127.0.0.1:9996",
+		ReadTimeout:  conf.Duration(10 * time.Second),
+		WriteTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:       "mypath",
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	// prevent following tests from reusing connections to a closed server
+	defer http.DefaultClient.CloseIdleConnections()
+
+	start1 := time.Date(2008, 11, 7, 11, 22, 0, 0, time.Local)
+	start2 := time.Date(2008, 11, 7, 11, 23, 0, 0, time.Local)
+
+	q := func(start time.Time, duration string) string {
+		v := url.Values{}
+		v.Set("path", "mypath")
+		v.Set("start", start.Format(time.RFC3339Nano))
+		v.Set("track", "1")
+		if duration != "" {
+			v.Set("duration", duration)
+		}
+		return v.Encode()
+	}
+
+	t.Run("manifest", func(t *testing.T) {
+		v := url.Values{}
+		v.Set("path", "mypath")
+		v.Set("start", start1.Format(time.RFC3339Nano))
+		v.Set("duration", "120")
+
+		byts := doHLSRequest(t, "/dash/index.mpd", v)
+
+		var m dash.MPD
+		err = m.Unmarshal(byts)
+		require.NoError(t, err)
+
+		require.Equal(t, "static", m.Type)
+		require.Equal(t, dash.ProfileMain, m.Profiles)
+		require.Equal(t, dash.Duration(64*time.Second), *m.MediaPresentationDuration)
+		require.Len(t, m.Periods, 2)
+
+		require.Equal(t, dash.Duration(0), m.Periods[0].Start)
+		require.Equal(t, dash.Duration(10*time.Second), *m.Periods[0].Duration)
+		require.Equal(t, dash.Duration(60*time.Second), m.Periods[1].Start)
+		require.Equal(t, dash.Duration(4*time.Second), *m.Periods[1].Duration)
+
+		as := m.Periods[0].AdaptationSets
+		require.Len(t, as, 1)
+		require.Equal(t, "video", as[0].ContentType)
+		require.Equal(t, "avc1.42c028", as[0].Representations[0].Codecs)
+
+		pto := uint64(start1.Unix()) * 90000
+		six := uint64(6 * 90000)
+		four := uint64(4 * 90000)
+
+		require.Equal(t, &dash.SegmentList{
+			Timescale:              90000,
+			PresentationTimeOffset: pto,
+			Initialization: &dash.URL{
+				SourceURL: "init.mp4?" + q(start1, ""),
+			},
+			SegmentTimeline: &dash.SegmentTimeline{
+				S: []*dash.S{
+					{T: &pto, D: six},
+					{D: four},
+				},
+			},
+			SegmentURLs: []*dash.SegmentURL{
+				{Media: "segment.mp4?" + q(start1, "6")},
+				{Media: "segment.mp4?" + q(start1.Add(6*time.Second), "4")},
+			},
+		}, as[0].Representations[0].SegmentList)
+
+		pto2 := uint64(start2.Unix()) * 90000
+
+		require.Equal(t, &dash.SegmentTimeline{
+			S: []*dash.S{
+				{T: &pto2, D: four},
+			},
+		}, m.Periods[1].AdaptationSets[0].Representations[0].SegmentList.SegmentTimeline)
+	})
+
+	t.Run("init", func(t *testing.T) {
+		v := url.Values{}
+		v.Set("path", "mypath")
+		v.Set("start", start1.Format(time.RFC3339Nano))
+		v.Set("track", "1")
+
+		byts := doHLSRequest(t, "/dash/init.mp4", v)
+
+		var init fmp4.Init
+		err = init.Unmarshal(bytes.NewReader(byts))
+		require.NoError(t, err)
+		require.Len(t, init.Tracks, 1)
+	})
+
+	t.Run("segment", func(t *testing.T) {
+		v := url.Values{}
+		v.Set("path", "mypath")
+		v.Set("start", start1.Format(time.RFC3339Nano))
+		v.Set("duration", "6")
+		v.Set("track", "1")
+
+		byts := doHLSRequest(t, "/dash/segment.mp4", v)
+
+		var parts fmp4.Parts
+		err = parts.Unmarshal(byts)
+		require.NoError(t, err)
+		require.Len(t, parts, 1)
+		require.Equal(t, uint64(start1.Unix())*90000, parts[0].Tracks[0].BaseTime)
+		require.Len(t, parts[0].Tracks[0].Samples, 6)
+	})
+}
@@ -0,0 +1,179 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf/jsonwrapper"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type exportReq struct {
+	Path     string    `json:"path"`
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration"`
+	Format   string    `json:"format"`
+	Tracks   []int     `json:"tracks"`
+}
+
+func (s *Server) checkExporter(ctx *gin.Context) bool {
+	if s.exporter == nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("exports are disabled"))
+		return false
+	}
+	return true
+}
+
+// findExport finds the export in the URL and checks that the user can access its path.
+func (s *Server) findExport(ctx *gin.Context) (*export, bool) {
+	if !s.checkExporter(ctx) {
+		return nil, false
+	}
+
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid ID: %w", err))
+		return nil, false
+	}
+
+	e, err := s.exporter.get(id)
+	if err != nil {
+		s.writeError(ctx, http.StatusNotFound, err)
+		return nil, false
+	}
+
+	if !s.doAuth(ctx, e.pathName) {
+		return nil, false
+	}
+
+	return e, true
+}
+
+func (s *Server) onExportsCreate(ctx *gin.Context) {
+	if !s.checkExporter(ctx) {
+		return
+	}
+
+	var req exportReq
+	err := jsonwrapper.Decode(ctx.Request.Body, &req)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if !s.doAuth(ctx, req.Path) {
+		return
+	}
+
+	if req.Start.IsZero() {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start"))
+		return
+	}
+
+	if req.Duration <= 0 {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid duration"))
+		return
+	}
+	duration := time.Duration(req.Duration * float64(time.Second))
+
+	switch req.Format {
+	case "":
+		req.Format = "fmp4"
+
+	case "fmp4", "mp4", "mpegts":
+
+	default:
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid format: %s", req.Format))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(req.Path)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	end := req.Start.Add(duration)
+	segments, err := recordstore.FindSegments(pathConf, req.Path, &req.Start, &end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	e, err := s.exporter.add(req.Path, pathConf, segments, req.Start, duration, req.Format, req.Tracks)
+	if err != nil {
+		s.writeError(ctx, http.StatusTooManyRequests, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, e.describe())
+}
+
+func (s *Server) onExportsGet(ctx *gin.Context) {
+	e, ok := s.findExport(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, e.describe())
+}
+
+func (s *Server) onExportsDownload(ctx *gin.Context) {
+	e, ok := s.findExport(ctx)
+	if !ok {
+		return
+	}
+
+	if !e.completed() {
+		s.writeError(ctx, http.StatusConflict, fmt.Errorf("export is not completed"))
+		return
+	}
+
+	f, err := os.Open(e.filePath)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if e.format == "mpegts" {
+		ctx.Header("Content-Type", "video/MP2T")
+	} else {
+		ctx.Header("Content-Type", "video/mp4")
+	}
+	ctx.Header("Content-Disposition", `attachment; filename="`+filepath.Base(e.filePath)+`"`)
+
+	// Range requests are handled by ServeContent
+	http.ServeContent(ctx.Writer, ctx.Request, "", fi.ModTime(), f)
+}
+
+func (s *Server) onExportsDelete(ctx *gin.Context) {
+	e, ok := s.findExport(ctx)
+	if !ok {
+		return
+	}
+
+	err := s.exporter.remove(e.id)
+	if err != nil {
+		s.writeError(ctx, http.StatusNotFound, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
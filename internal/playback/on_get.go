@@ -1,17 +1,19 @@
 package playback
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/protocols/mkv"
 	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/gin-gonic/gin"
 )
@@ -41,14 +43,15 @@ func parseDuration(raw string) (time.Duration, error) {
 }
 
 func seekAndMux(
-	recordFormat conf.RecordFormat,
+	ctx context.Context,
+	pathConf *conf.Path,
 	segments []*recordstore.Segment,
 	start time.Time,
 	duration time.Duration,
 	m muxer,
 ) error {
-	if recordFormat == conf.RecordFormatFMP4 {
-		f, err := os.Open(segments[0].Fpath)
+	if pathConf.RecordFormat == conf.RecordFormatFMP4 {
+		f, err := recordstore.OpenSegment(ctx, pathConf, segments[0])
 		if err != nil {
 			return err
 		}
@@ -76,7 +79,7 @@ func seekAndMux(
 		segmentEnd := segments[0].Start.Add(segmentDuration)
 
 		for _, seg := range segments[1:] {
-			f, err = os.Open(seg.Fpath)
+			f, err = recordstore.OpenSegment(ctx, pathConf, seg)
 			if err != nil {
 				return err
 			}
@@ -116,7 +119,149 @@ func seekAndMux(
 		return nil
 	}
 
-	return fmt.Errorf("MPEG-TS format is not supported yet")
+	if pathConf.RecordFormat == conf.RecordFormatMKV || pathConf.RecordFormat == conf.RecordFormatWebM {
+		f, err := recordstore.OpenSegment(ctx, pathConf, segments[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r := &mkv.Reader{R: f}
+		firstInfo, err := segmentMKVReadHeader(r)
+		if err != nil {
+			return err
+		}
+
+		tracks, err := segmentMKVReadTracks(r)
+		if err != nil {
+			return err
+		}
+
+		initTracks := segmentMKVInitTracks(tracks)
+		if len(initTracks) == 0 {
+			return fmt.Errorf("no tracks can be converted to MP4")
+		}
+
+		m.writeInit(&fmp4.Init{
+			Tracks: initTracks,
+		})
+
+		startOffset := segments[0].Start.Sub(start) // this is negative
+		dts := startOffset
+		prevInfo := firstInfo
+
+		segmentDuration, err := segmentMKVMuxBlocks(f, r, dts, duration, initTracks, m)
+		if err != nil {
+			return err
+		}
+
+		segmentEnd := segments[0].Start.Add(segmentDuration)
+
+		for _, seg := range segments[1:] {
+			f, err = recordstore.OpenSegment(ctx, pathConf, seg)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			r = &mkv.Reader{R: f}
+			var info *recordstore.MKVInfo
+			info, err = segmentMKVReadHeader(r)
+			if err != nil {
+				return err
+			}
+
+			if !segmentMKVCanBeConcatenated(prevInfo, segmentEnd, info, seg.Start) {
+				break
+			}
+
+			dts = time.Duration(info.DTS-firstInfo.DTS) + startOffset
+
+			segmentDuration, err = segmentMKVMuxBlocks(f, r, dts, duration, initTracks, m)
+			if err != nil {
+				return err
+			}
+
+			segmentEnd = seg.Start.Add(segmentDuration)
+			prevInfo = info
+		}
+
+		return m.flush()
+	}
+
+	// MPEG-TS
+
+	f, err := recordstore.OpenSegment(ctx, pathConf, segments[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	firstHeader, err := segmentMPEGTSReadHeader(f)
+	if err != nil {
+		return err
+	}
+
+	if len(firstHeader.tracks) == 0 {
+		return fmt.Errorf("no tracks can be converted to MP4")
+	}
+
+	m.writeInit(&fmp4.Init{
+		Tracks: firstHeader.tracks,
+	})
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	startOffset := segments[0].Start.Sub(start) // this is negative
+	dts := startOffset
+	prevHeader := firstHeader
+	prevStart := segments[0].Start
+
+	segmentDuration, err := segmentMPEGTSMuxSamples(f, firstHeader, dts, duration, firstHeader.tracks, m)
+	if err != nil {
+		return err
+	}
+
+	segmentEnd := segments[0].Start.Add(segmentDuration)
+
+	for _, seg := range segments[1:] {
+		f, err = recordstore.OpenSegment(ctx, pathConf, seg)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		var header *segmentMPEGTSHeader
+		header, err = segmentMPEGTSReadHeader(f)
+		if err != nil {
+			return err
+		}
+
+		if !segmentMPEGTSCanBeConcatenated(prevHeader, prevStart, segmentEnd, header, seg.Start) {
+			break
+		}
+
+		_, err = f.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+
+		dts = header.elapsedSince(firstHeader) + startOffset
+
+		segmentDuration, err = segmentMPEGTSMuxSamples(f, header, dts, duration, firstHeader.tracks, m)
+		if err != nil {
+			return err
+		}
+
+		segmentEnd = seg.Start.Add(segmentDuration)
+		prevHeader = header
+		prevStart = seg.Start
+	}
+
+	return m.flush()
 }
 
 func (s *Server) onGet(ctx *gin.Context) {
@@ -171,26 +316,30 @@ func (s *Server) onGet(ctx *gin.Context) {
 		return
 	}
 
-	err = seekAndMux(pathConf.RecordFormat, segments, start, duration, m)
+	err = seekAndMux(ctx.Request.Context(), pathConf, segments, start, duration, m)
 	if err != nil {
-		// user aborted the download
-		var neterr *net.OpError
-		if errors.As(err, &neterr) {
-			return
-		}
-
-		// nothing has been written yet; send back JSON
-		if !ww.written {
-			if errors.Is(err, recordstore.ErrNoSegmentsFound) {
-				s.writeError(ctx, http.StatusNotFound, err)
-			} else {
-				s.writeError(ctx, http.StatusBadRequest, err)
-			}
-			return
-		}
+		s.writeMuxError(ctx, ww, err)
+		return
+	}
+}
 
-		// something has already been written: abort and write logs only
-		s.Log(logger.Error, err.Error())
+func (s *Server) writeMuxError(ctx *gin.Context, ww *writerWrapper, err error) {
+	// user aborted the download
+	var neterr *net.OpError
+	if errors.As(err, &neterr) {
 		return
 	}
+
+	// nothing has been written yet; send back JSON
+	if !ww.written {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	// something has already been written: abort and write logs only
+	s.Log(logger.Error, err.Error())
 }
@@ -0,0 +1,320 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	hlsSegmentDuration = 6 * time.Second
+
+	// maximum distance between the end of a segment and the next keyframe.
+	hlsKeyframeLookahead = 60 * time.Second
+)
+
+// hlsBaseTime is the origin of timestamps of HLS segments.
+// Using an absolute origin allows to generate segments independently from each other.
+var hlsBaseTime = time.Unix(0, 0)
+
+func hlsQuery(base url.Values, start time.Time, duration *time.Duration) string {
+	v := url.Values{}
+	for key, values := range base {
+		v[key] = values
+	}
+
+	v.Set("start", start.Format(time.RFC3339Nano))
+	if duration != nil {
+		v.Set("duration", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64))
+	}
+
+	return v.Encode()
+}
+
+// parseTrackID parses the optional ID of the track to serve.
+func parseTrackID(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+
+	v, err := strconv.ParseUint(raw, 10, 31)
+	if err != nil || v == 0 {
+		return 0, fmt.Errorf("invalid track")
+	}
+
+	return int(v), nil
+}
+
+// filterInit returns an initialization section that contains a single track.
+func filterInit(init *fmp4.Init, trackID int) *fmp4.Init {
+	for _, track := range init.Tracks {
+		if track.ID == trackID {
+			return &fmp4.Init{Tracks: []*fmp4.InitTrack{track}}
+		}
+	}
+	return nil
+}
+
+type hlsSegment struct {
+	start    time.Time
+	duration time.Duration
+}
+
+// entrySegments splits an entry into segments.
+// When incomplete is true, the last segment is skipped if shorter than the nominal duration.
+func entrySegments(entry listEntry, incomplete bool) []hlsSegment {
+	var out []hlsSegment
+
+	entryEnd := entry.Start.Add(time.Duration(entry.Duration))
+
+	for t := entry.Start; t.Before(entryEnd); t = t.Add(hlsSegmentDuration) {
+		duration := entryEnd.Sub(t)
+		if duration > hlsSegmentDuration {
+			duration = hlsSegmentDuration
+		}
+
+		if incomplete && duration < hlsSegmentDuration {
+			break
+		}
+
+		out = append(out, hlsSegment{start: t, duration: duration})
+	}
+
+	return out
+}
+
+func generateHLSPlaylist(entries []listEntry, baseQuery url.Values, live bool) []byte {
+	var b strings.Builder
+
+	b.WriteString("#EXTM3U\n" +
+		"#EXT-X-VERSION:7\n" +
+		"#EXT-X-INDEPENDENT-SEGMENTS\n" +
+		"#EXT-X-TARGETDURATION:" + strconv.FormatInt(int64(math.Ceil(hlsSegmentDuration.Seconds())), 10) + "\n" +
+		"#EXT-X-MEDIA-SEQUENCE:0\n")
+
+	if live {
+		b.WriteString("#EXT-X-PLAYLIST-TYPE:EVENT\n")
+	} else {
+		b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	}
+
+	for i, entry := range entries {
+		if i != 0 {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+
+		b.WriteString("#EXT-X-MAP:URI=\"init.mp4?" + hlsQuery(baseQuery, entry.Start, nil) + "\"\n")
+
+		// the recording is still being written and its last segment is not complete yet
+		incomplete := live && i == (len(entries)-1)
+
+		for _, seg := range entrySegments(entry, incomplete) {
+			b.WriteString("#EXT-X-PROGRAM-DATE-TIME:" + seg.start.Format("2006-01-02T15:04:05.000Z07:00") + "\n" +
+				"#EXTINF:" + strconv.FormatFloat(seg.duration.Seconds(), 'f', 3, 64) + ",\n" +
+				"segment.mp4?" + hlsQuery(baseQuery, seg.start, &seg.duration) + "\n")
+		}
+	}
+
+	if !live {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+
+	return []byte(b.String())
+}
+
+func (s *Server) onHLSPlaylist(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	duration, err := parseDuration(ctx.Query("duration"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid duration: %w", err))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	end := start.Add(duration)
+	segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	entries, err := parseAndConcatenate(ctx.Request.Context(), pathConf, segments)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	entries = clipEntries(entries, &start, &end)
+	if len(entries) == 0 {
+		s.writeError(ctx, http.StatusNotFound, recordstore.ErrNoSegmentsFound)
+		return
+	}
+
+	// keep additional query parameters (e.g. credentials) in segment URLs
+	query := ctx.Request.URL.Query()
+	query.Del("start")
+	query.Del("duration")
+
+	// when the requested interval is not over yet, recordings can still grow
+	live := end.After(time.Now())
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Data(http.StatusOK, "application/vnd.apple.mpegurl", generateHLSPlaylist(entries, query, live))
+}
+
+func (s *Server) onHLSInit(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	trackID, err := parseTrackID(ctx.Query("track"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	segments, err := recordstore.FindSegments(pathConf, pathName, &start, &start)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	init, err := readInit(ctx.Request.Context(), pathConf, segments[0], start)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	if trackID != 0 {
+		init = filterInit(init, trackID)
+		if init == nil {
+			s.writeError(ctx, http.StatusNotFound, fmt.Errorf("track not found"))
+			return
+		}
+	}
+
+	var buf seekablebuffer.Buffer
+	err = init.Marshal(&buf)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Data(http.StatusOK, "video/mp4", buf.Bytes())
+}
+
+func (s *Server) onHLSSegment(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	duration, err := parseDuration(ctx.Query("duration"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid duration: %w", err))
+		return
+	}
+
+	trackID, err := parseTrackID(ctx.Query("track"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	// the segment ends at the first keyframe after its nominal end,
+	// therefore read beyond it
+	readDuration := duration + hlsKeyframeLookahead
+
+	end := start.Add(readDuration)
+	segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	ww := &writerWrapper{ctx: ctx}
+	m := &muxerHLSSegment{
+		w:        ww,
+		duration: duration,
+		baseTime: start.Sub(hlsBaseTime),
+		trackID:  trackID,
+	}
+
+	err = seekAndMux(ctx.Request.Context(), pathConf, segments, start, readDuration, m)
+	if errors.Is(err, errMuxingComplete) {
+		err = m.flush()
+	}
+	if err != nil {
+		s.writeMuxError(ctx, ww, err)
+		return
+	}
+}
@@ -0,0 +1,216 @@
+package playback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+// maximum distance between the requested time and the keyframe
+// when there are no keyframes before the requested time.
+const keyframeLookahead = 10 * time.Second
+
+type keyframeEntry struct {
+	Time    time.Time `json:"time"`
+	Segment time.Time `json:"segment"`
+	Offset  int64     `json:"offset"`
+	Size    int64     `json:"size"`
+	URL     string    `json:"url"`
+}
+
+// findIndexedKeyframe returns the last indexed keyframe of a segment that precedes the given time.
+func findIndexedKeyframe(pathConf *conf.Path, pathName string, seg *recordstore.Segment, t time.Time) (
+	*recordstore.Keyframe, error,
+) {
+	// time is placed in a gap after the segment
+	if seg.Info != nil && t.After(seg.Start.Add(seg.Info.Duration)) {
+		return nil, nil
+	}
+
+	keyframes, err := recordstore.ReadKeyframes(pathConf.RecordPath, pathName, seg.Fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	var out *recordstore.Keyframe
+
+	for i := range keyframes {
+		if seg.Start.Add(keyframes[i].Time).After(t) {
+			break
+		}
+		out = &keyframes[i]
+	}
+
+	return out, nil
+}
+
+// writeIndexedKeyframe reads a keyframe directly from its position in the segment.
+func writeIndexedKeyframe(
+	ctx context.Context,
+	pathConf *conf.Path,
+	seg *recordstore.Segment,
+	k *recordstore.Keyframe,
+	ww *writerWrapper,
+) error {
+	f, err := recordstore.OpenSegment(ctx, pathConf, seg)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	init, _, err := segmentFMP4ReadHeader(f)
+	if err != nil {
+		return err
+	}
+
+	track := findVideoTrack(init.Tracks)
+	if track == nil {
+		return fmt.Errorf("recordings do not contain any video track")
+	}
+
+	payload := make([]byte, k.Size)
+	_, err = f.ReadAt(payload, k.Offset)
+	if err != nil {
+		return err
+	}
+
+	return writeKeyframe(ww, track, payload)
+}
+
+func (s *Server) onKeyframes(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	duration, err := parseDuration(ctx.Query("duration"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid duration: %w", err))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	end := start.Add(duration)
+	segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	out := []keyframeEntry{}
+
+	for _, seg := range segments {
+		var keyframes []recordstore.Keyframe
+		keyframes, err = recordstore.ReadKeyframes(pathConf.RecordPath, pathName, seg.Fpath)
+		if err != nil {
+			s.writeError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+
+		for _, k := range keyframes {
+			t := seg.Start.Add(k.Time)
+			if t.Before(start) || !t.Before(end) {
+				continue
+			}
+
+			v := url.Values{}
+			v.Add("path", pathName)
+			v.Add("time", t.Format(time.RFC3339Nano))
+
+			out = append(out, keyframeEntry{
+				Time:    t,
+				Segment: seg.Start,
+				Offset:  k.Offset,
+				Size:    k.Size,
+				URL:     s.endpointURL(ctx, "/keyframe", v),
+			})
+		}
+	}
+
+	ctx.JSON(http.StatusOK, out)
+}
+
+func (s *Server) onKeyframe(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, pathName) {
+		return
+	}
+
+	t, err := time.Parse(time.RFC3339, ctx.Query("time"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid time: %w", err))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	end := t.Add(keyframeLookahead)
+	segments, err := recordstore.FindSegments(pathConf, pathName, &t, &end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	ww := &writerWrapper{ctx: ctx}
+
+	// use the keyframe index when available, in order to avoid reading the segment
+	if pathConf.RecordFormat == conf.RecordFormatFMP4 {
+		var k *recordstore.Keyframe
+		k, err = findIndexedKeyframe(pathConf, pathName, segments[0], t)
+		if err != nil {
+			s.writeError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+
+		if k != nil {
+			err = writeIndexedKeyframe(ctx.Request.Context(), pathConf, segments[0], k, ww)
+			if err != nil {
+				s.writeMuxError(ctx, ww, err)
+			}
+			return
+		}
+	}
+
+	m := &muxerKeyframe{w: ww}
+
+	err = seekAndMux(ctx.Request.Context(), pathConf, segments, t, keyframeLookahead, m)
+	if errors.Is(err, errMuxingComplete) {
+		err = m.flush()
+	}
+	if err != nil {
+		s.writeMuxError(ctx, ww, err)
+		return
+	}
+}
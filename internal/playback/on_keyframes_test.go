@@ -0,0 +1,147 @@
+package playback
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/pmp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func getKeyframe(t *testing.T, ti time.Time) []byte {
+	v := url.Values{}
+	v.Set("path", "mypath")
+	v.Set("time", ti.Format(time.RFC3339Nano))
+
+	res, err := http.Get("http://localhost:9996/keyframe?" + v.Encode())
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "video/mp4", res.Header.Get("Content-Type"))
+
+	byts, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	var p pmp4.Presentation
+	err = p.Unmarshal(bytes.NewReader(byts))
+	require.NoError(t, err)
+	require.Len(t, p.Tracks, 1)
+	require.Len(t, p.Tracks[0].Samples, 1)
+
+	payload, err := p.Tracks[0].Samples[0].GetPayload()
+	require.NoError(t, err)
+
+	return payload
+}
+
+func TestOnKeyframes(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	recordPath := filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")
+	fpath := filepath.Join(dir, "mypath", "2008-11-07_11-22-00-000000.mp4")
+
+	writeSegmentH264(t, fpath, 10)
+
+	byts, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+
+	payload := func(i int) []byte {
+		pl, err2 := h264.AVCC{{byte(h264.NALUTypeIDR), byte(i)}}.Marshal()
+		require.NoError(t, err2)
+		return pl
+	}
+
+	var keyframes []recordstore.Keyframe
+	for _, i := range []int{0, 4, 8} {
+		pl := payload(i)
+		keyframes = append(keyframes, recordstore.Keyframe{
+			Time:   time.Duration(i) * time.Second,
+			Offset: int64(bytes.Index(byts, pl)),
+			Size:   int64(len(pl)),
+		})
+	}
+
+	err = recordstore.WriteKeyframes(recordPath, "mypath", fpath, keyframes)
+	require.NoError(t, err)
+
+	s := &Server{
+		Address:      "127.0.0.1:9996",
+		ReadTimeout:  conf.Duration(10 * time.Second),
+		WriteTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:       "mypath",
+				RecordPath: recordPath,
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	segStart := time.Date(2008, 11, 7, 11, 22, 0, 0, time.Local)
+
+	t.Run("list", func(t *testing.T) {
+		v := url.Values{}
+		v.Set("path", "mypath")
+		v.Set("start", segStart.Add(1*time.Second).Format(time.RFC3339Nano))
+		v.Set("duration", "10")
+
+		res, err2 := http.Get("http://localhost:9996/keyframes?" + v.Encode())
+		require.NoError(t, err2)
+		defer res.Body.Close()
+
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var out interface{}
+		err2 = json.NewDecoder(res.Body).Decode(&out)
+		require.NoError(t, err2)
+
+		var expected []interface{}
+		for _, k := range keyframes[1:] {
+			ti := segStart.Add(k.Time)
+			expected = append(expected, map[string]interface{}{
+				"time":    ti.Format(time.RFC3339Nano),
+				"segment": segStart.Format(time.RFC3339Nano),
+				"offset":  float64(k.Offset),
+				"size":    float64(k.Size),
+				"url": "http://localhost:9996/keyframe?path=mypath&time=" +
+					url.QueryEscape(ti.Format(time.RFC3339Nano)),
+			})
+		}
+
+		require.Equal(t, expected, out)
+	})
+
+	t.Run("indexed", func(t *testing.T) {
+		pl := getKeyframe(t, segStart.Add(5*time.Second))
+		require.Equal(t, payload(4), pl)
+	})
+
+	t.Run("not indexed", func(t *testing.T) {
+		err2 := recordstore.RemoveKeyframes(recordPath, "mypath", fpath)
+		require.NoError(t, err2)
+
+		pl := getKeyframe(t, segStart.Add(3*time.Second))
+		require.Equal(t, payload(2), pl)
+	})
+}
@@ -1,19 +1,22 @@
 package playback
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/protocols/mkv"
 	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type listEntryDuration time.Duration
@@ -23,13 +26,92 @@ func (d listEntryDuration) MarshalJSON() ([]byte, error) {
 }
 
 type parsedSegment struct {
-	start    time.Time
-	init     *fmp4.Init
-	duration time.Duration
+	segment      *recordstore.Segment
+	start        time.Time
+	init         *fmp4.Init
+	mkvInfo      *recordstore.MKVInfo
+	mpegtsHeader *segmentMPEGTSHeader
+	info         *recordstore.SegmentInfo
+	duration     time.Duration
 }
 
-func parseSegment(seg *recordstore.Segment) (*parsedSegment, error) {
-	f, err := os.Open(seg.Fpath)
+func parseSegmentMPEGTS(ctx context.Context, pathConf *conf.Path, seg *recordstore.Segment) (*parsedSegment, error) {
+	f, err := recordstore.OpenSegment(ctx, pathConf, seg)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header, err := segmentMPEGTSReadHeader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	// MPEG-TS segments don't contain their duration, compute it
+	// by parsing each sample
+	duration, err := segmentMPEGTSReadDuration(f, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsedSegment{
+		start:        seg.Start,
+		mpegtsHeader: header,
+		duration:     duration,
+	}, nil
+}
+
+func parseSegmentMKV(ctx context.Context, pathConf *conf.Path, seg *recordstore.Segment) (*parsedSegment, error) {
+	f, err := recordstore.OpenSegment(ctx, pathConf, seg)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := &mkv.Reader{R: f}
+	info, err := segmentMKVReadHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// if duration is not present in the header, compute it
+	// by parsing each block
+	duration, err := segmentMKVReadDuration(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsedSegment{
+		start:    seg.Start,
+		mkvInfo:  info,
+		duration: duration,
+	}, nil
+}
+
+func parseSegment(ctx context.Context, pathConf *conf.Path, seg *recordstore.Segment) (*parsedSegment, error) {
+	// segment is complete and its informations are stored in the index
+	if seg.Info != nil {
+		return &parsedSegment{
+			start:    seg.Start,
+			info:     seg.Info,
+			duration: seg.Info.Duration,
+		}, nil
+	}
+
+	switch pathConf.RecordFormat {
+	case conf.RecordFormatMPEGTS:
+		return parseSegmentMPEGTS(ctx, pathConf, seg)
+
+	case conf.RecordFormatMKV, conf.RecordFormatWebM:
+		return parseSegmentMKV(ctx, pathConf, seg)
+	}
+
+	f, err := recordstore.OpenSegment(ctx, pathConf, seg)
 	if err != nil {
 		return nil, err
 	}
@@ -56,7 +138,11 @@ func parseSegment(seg *recordstore.Segment) (*parsedSegment, error) {
 	}, nil
 }
 
-func parseSegments(segments []*recordstore.Segment) ([]*parsedSegment, error) {
+func parseSegments(
+	ctx context.Context,
+	pathConf *conf.Path,
+	segments []*recordstore.Segment,
+) ([]*parsedSegment, error) {
 	parsed := make([]*parsedSegment, len(segments))
 	ch := make(chan error)
 
@@ -65,8 +151,11 @@ func parseSegments(segments []*recordstore.Segment) ([]*parsedSegment, error) {
 	// ref: https://pkolaczk.github.io/disk-parallelism/
 	for i, seg := range segments {
 		go func(i int, seg *recordstore.Segment) {
-			var err error
-			parsed[i], err = parseSegment(seg)
+			p, err := parseSegment(ctx, pathConf, seg)
+			if err == nil {
+				p.segment = seg
+			}
+			parsed[i] = p
 			ch <- err
 		}(i, seg)
 	}
@@ -83,53 +172,158 @@ func parseSegments(segments []*recordstore.Segment) ([]*parsedSegment, error) {
 	return parsed, err
 }
 
-type listEntry struct {
+type listMarker struct {
+	ID       uuid.UUID         `json:"id"`
 	Start    time.Time         `json:"start"`
 	Duration listEntryDuration `json:"duration"`
-	URL      string            `json:"url"`
+	Label    string            `json:"label"`
+	Data     json.RawMessage   `json:"data,omitempty"`
+}
+
+type listEntry struct {
+	Start        time.Time         `json:"start"`
+	Duration     listEntryDuration `json:"duration"`
+	URL          string            `json:"url"`
+	Markers      []listMarker      `json:"markers,omitempty"`
+	Tracks       []listTrack       `json:"tracks,omitempty"`
+	CodecChanged bool              `json:"codecChanged,omitempty"`
+	Segments     []listSegment     `json:"segments,omitempty"`
+
+	segments []*parsedSegment
+}
+
+// segmentInfo returns the stream ID and segment number of a segment.
+func (p *parsedSegment) segmentInfo() *recordstore.MKVInfo {
+	switch {
+	case p.info != nil:
+		return &recordstore.MKVInfo{
+			StreamID:      p.info.StreamID,
+			SegmentNumber: p.info.SegmentNumber,
+		}
+
+	case p.init != nil:
+		if mtxi := findMtxi(p.init.UserData); mtxi != nil {
+			return &recordstore.MKVInfo{
+				StreamID:      mtxi.StreamID,
+				SegmentNumber: mtxi.SegmentNumber,
+			}
+		}
+		return nil
+
+	default:
+		return p.mkvInfo
+	}
+}
+
+func segmentsCanBeConcatenated(prev *parsedSegment, prevEnd time.Time, cur *parsedSegment) bool {
+	if prev.init != nil && cur.init != nil {
+		return segmentFMP4CanBeConcatenated(prev.init, prevEnd, cur.init, cur.start)
+	}
+
+	switch {
+	case prev.mpegtsHeader != nil && cur.mpegtsHeader != nil:
+		return segmentMPEGTSCanBeConcatenated(prev.mpegtsHeader, prev.start, prevEnd, cur.mpegtsHeader, cur.start)
+
+	// MPEG-TS segments don't contain a stream ID, therefore
+	// a segment read from the index can only be compared with timing.
+	case prev.mpegtsHeader != nil || cur.mpegtsHeader != nil:
+		return !cur.start.Before(prevEnd.Add(-concatenationTolerance)) &&
+			!cur.start.After(prevEnd.Add(concatenationTolerance))
+	}
+
+	// at least one segment has been read from the index or is a Matroska segment,
+	// compare stream IDs and segment numbers.
+	return segmentMKVCanBeConcatenated(prev.segmentInfo(), prevEnd, cur.segmentInfo(), cur.start)
 }
 
 func concatenateSegments(parsed []*parsedSegment) []listEntry {
 	out := []listEntry{}
-	var prevInit *fmp4.Init
+	var prev *parsedSegment
 
 	for _, parsed := range parsed {
-		if len(out) != 0 && segmentFMP4CanBeConcatenated(
-			prevInit,
+		if len(out) != 0 && segmentsCanBeConcatenated(
+			prev,
 			out[len(out)-1].Start.Add(time.Duration(out[len(out)-1].Duration)),
-			parsed.init,
-			parsed.start) {
+			parsed) {
 			prevStart := out[len(out)-1].Start
 			curEnd := parsed.start.Add(parsed.duration)
 			out[len(out)-1].Duration = listEntryDuration(curEnd.Sub(prevStart))
+			out[len(out)-1].segments = append(out[len(out)-1].segments, parsed)
 		} else {
 			out = append(out, listEntry{
 				Start:    parsed.start,
 				Duration: listEntryDuration(parsed.duration),
+				segments: []*parsedSegment{parsed},
 			})
 		}
 
-		prevInit = parsed.init
+		prev = parsed
 	}
 
 	return out
 }
 
+// assignMarkers attaches markers to the entries they overlap.
+func assignMarkers(entries []listEntry, markers []*recordstore.Marker) {
+	for i := range entries {
+		entryEnd := entries[i].Start.Add(time.Duration(entries[i].Duration))
+
+		for _, m := range markers {
+			if !m.End().Before(entries[i].Start) && m.Start.Before(entryEnd) {
+				entries[i].Markers = append(entries[i].Markers, listMarker{
+					ID:       m.ID,
+					Start:    m.Start,
+					Duration: listEntryDuration(m.Duration),
+					Label:    m.Label,
+					Data:     m.Data,
+				})
+			}
+		}
+	}
+}
+
 func parseAndConcatenate(
-	recordFormat conf.RecordFormat,
+	ctx context.Context,
+	pathConf *conf.Path,
 	segments []*recordstore.Segment,
 ) ([]listEntry, error) {
-	if recordFormat == conf.RecordFormatFMP4 {
-		parsed, err := parseSegments(segments)
-		if err != nil {
-			return nil, err
+	parsed, err := parseSegments(ctx, pathConf, segments)
+	if err != nil {
+		return nil, err
+	}
+
+	return concatenateSegments(parsed), nil
+}
+
+// clipEntries restricts entries to the interval between start and end.
+func clipEntries(entries []listEntry, start *time.Time, end *time.Time) []listEntry {
+	if start != nil {
+		firstEntry := entries[0]
+
+		// when start is placed in a gap between the first and second segment,
+		// or when there's no second segment,
+		// the first segment is erroneously included with a negative duration.
+		// remove it.
+		if firstEntry.Start.Add(time.Duration(firstEntry.Duration)).Before(*start) {
+			entries = entries[1:]
+
+			if len(entries) == 0 {
+				return nil
+			}
+		} else if firstEntry.Start.Before(*start) {
+			entries[0].Duration -= listEntryDuration(start.Sub(firstEntry.Start))
+			entries[0].Start = *start
 		}
+	}
 
-		out := concatenateSegments(parsed)
-		return out, nil
+	if end != nil {
+		lastEntry := entries[len(entries)-1]
+		if lastEntry.Start.Add(time.Duration(lastEntry.Duration)).After(*end) {
+			entries[len(entries)-1].Duration = listEntryDuration(end.Sub(lastEntry.Start))
+		}
 	}
 
-	return nil, fmt.Errorf("MPEG-TS format is not supported yet")
+	return entries
 }
 
 func (s *Server) onList(ctx *gin.Context) {
@@ -169,6 +363,17 @@ func (s *Server) onList(ctx *gin.Context) {
 		end = &tmp
 	}
 
+	extended := ctx.Query("extended") == "true"
+
+	summaryPeriod := ctx.Query("summary")
+	switch summaryPeriod {
+	case "", "day", "month":
+
+	default:
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid summary: %s", summaryPeriod))
+		return
+	}
+
 	segments, err := recordstore.FindSegments(pathConf, pathName, start, end)
 	if err != nil {
 		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
@@ -179,39 +384,46 @@ func (s *Server) onList(ctx *gin.Context) {
 		return
 	}
 
-	entries, err := parseAndConcatenate(pathConf.RecordFormat, segments)
+	entries, err := parseAndConcatenate(ctx.Request.Context(), pathConf, segments)
 	if err != nil {
 		s.writeError(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
-	if start != nil {
-		firstEntry := entries[0]
+	entries = clipEntries(entries, start, end)
+	if len(entries) == 0 {
+		s.writeError(ctx, http.StatusNotFound, recordstore.ErrNoSegmentsFound)
+		return
+	}
 
-		// when start is placed in a gap between the first and second segment,
-		// or when there's no second segment,
-		// the first segment is erroneously included with a negative duration.
-		// remove it.
-		if firstEntry.Start.Add(time.Duration(firstEntry.Duration)).Before(*start) {
-			entries = entries[1:]
+	markers, err := recordstore.FindMarkers(pathConf.RecordPath, pathName, start, end)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
 
-			if len(entries) == 0 {
-				s.writeError(ctx, http.StatusNotFound, recordstore.ErrNoSegmentsFound)
-				return
-			}
-		} else if firstEntry.Start.Before(*start) {
-			entries[0].Duration -= listEntryDuration(start.Sub(firstEntry.Start))
-			entries[0].Start = *start
-		}
+	assignMarkers(entries, markers)
+
+	for i := range entries {
+		entries[i].URL = s.getURL(ctx, pathName, entries[i].Start, time.Duration(entries[i].Duration))
 	}
 
-	if end != nil {
-		lastEntry := entries[len(entries)-1]
-		if lastEntry.Start.Add(time.Duration(lastEntry.Duration)).After(*end) {
-			entries[len(entries)-1].Duration = listEntryDuration(end.Sub(lastEntry.Start))
-		}
+	if !extended {
+		ctx.JSON(http.StatusOK, entries)
+		return
+	}
+
+	timeline, err := s.buildTimeline(ctx, pathConf, pathName, entries, start, end, summaryPeriod)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
 	}
 
+	ctx.JSON(http.StatusOK, timeline)
+}
+
+// endpointURL returns the URL of an endpoint of the server.
+func (s *Server) endpointURL(ctx *gin.Context, path string, v url.Values) string {
 	var scheme string
 	if s.Encryption {
 		scheme = "https"
@@ -219,19 +431,20 @@ func (s *Server) onList(ctx *gin.Context) {
 		scheme = "http"
 	}
 
-	for i := range entries {
-		v := url.Values{}
-		v.Add("path", pathName)
-		v.Add("start", entries[i].Start.Format(time.RFC3339Nano))
-		v.Add("duration", strconv.FormatFloat(time.Duration(entries[i].Duration).Seconds(), 'f', -1, 64))
-		u := &url.URL{
-			Scheme:   scheme,
-			Host:     ctx.Request.Host,
-			Path:     "/get",
-			RawQuery: v.Encode(),
-		}
-		entries[i].URL = u.String()
+	u := &url.URL{
+		Scheme:   scheme,
+		Host:     ctx.Request.Host,
+		Path:     path,
+		RawQuery: v.Encode(),
 	}
+	return u.String()
+}
 
-	ctx.JSON(http.StatusOK, entries)
+// getURL returns the URL of the /get endpoint for the given interval.
+func (s *Server) getURL(ctx *gin.Context, pathName string, start time.Time, duration time.Duration) string {
+	v := url.Values{}
+	v.Add("path", pathName)
+	v.Add("start", start.Format(time.RFC3339Nano))
+	v.Add("duration", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64))
+	return s.endpointURL(ctx, "/get", v)
 }
@@ -0,0 +1,142 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/protocols/websocket"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+type websocketWriter struct {
+	c *websocket.ServerConn
+}
+
+func (w *websocketWriter) Write(p []byte) (int, error) {
+	err := w.c.WriteBinary(p)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// findMultiPaths parses a request that involves multiple paths.
+// Paths without recordings in the requested timespan are skipped.
+func (s *Server) findMultiPaths(ctx *gin.Context) ([]*multiPath, time.Time, time.Duration, bool) {
+	pathNames := ctx.QueryArray("path")
+	if len(pathNames) == 0 {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("no paths provided"))
+		return nil, time.Time{}, 0, false
+	}
+
+	for _, pathName := range pathNames {
+		if !s.doAuth(ctx, pathName) {
+			return nil, time.Time{}, 0, false
+		}
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return nil, time.Time{}, 0, false
+	}
+
+	duration, err := parseDuration(ctx.Query("duration"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid duration: %w", err))
+		return nil, time.Time{}, 0, false
+	}
+
+	end := start.Add(duration)
+	var paths []*multiPath
+
+	for _, pathName := range pathNames {
+		pathConf, err := s.safeFindPathConf(pathName)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, err)
+			return nil, time.Time{}, 0, false
+		}
+
+		segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+		if err != nil {
+			if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+				continue
+			}
+			s.writeError(ctx, http.StatusBadRequest, err)
+			return nil, time.Time{}, 0, false
+		}
+
+		paths = append(paths, &multiPath{
+			pathConf: pathConf,
+			segments: segments,
+		})
+	}
+
+	if len(paths) == 0 {
+		s.writeError(ctx, http.StatusNotFound, recordstore.ErrNoSegmentsFound)
+		return nil, time.Time{}, 0, false
+	}
+
+	return paths, start, duration, true
+}
+
+func (s *Server) onMultiGet(ctx *gin.Context) {
+	paths, start, duration, ok := s.findMultiPaths(ctx)
+	if !ok {
+		return
+	}
+
+	ww := &writerWrapper{ctx: ctx}
+	var m muxer
+
+	format := ctx.Query("format")
+	switch format {
+	case "", "fmp4":
+		m = &muxerFMP4{w: ww}
+
+	case "mp4":
+		m = &muxerMP4{w: ww}
+
+	default:
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid format: %s", format))
+		return
+	}
+
+	err := seekAndMuxMulti(ctx.Request.Context(), paths, start, duration, m)
+	if err != nil {
+		s.writeMuxError(ctx, ww, err)
+		return
+	}
+}
+
+func (s *Server) onMultiWebSocket(ctx *gin.Context) {
+	paths, start, duration, ok := s.findMultiPaths(ctx)
+	if !ok {
+		return
+	}
+
+	c, err := websocket.NewServerConn(ctx.Writer, ctx.Request)
+	if err != nil {
+		s.Log(logger.Error, err.Error())
+		return
+	}
+	defer c.Close()
+
+	m := &muxerFMP4{w: &websocketWriter{c: c}}
+
+	err = seekAndMuxMulti(ctx.Request.Context(), paths, start, duration, m)
+	if err != nil {
+		// user closed the connection
+		var neterr *net.OpError
+		if errors.As(err, &neterr) {
+			return
+		}
+
+		s.Log(logger.Error, err.Error())
+	}
+}
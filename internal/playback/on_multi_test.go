@@ -0,0 +1,165 @@
+package playback
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/pmp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnMulti(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, pathName := range []string{"cam1", "cam2", "cam3"} {
+		err = os.Mkdir(filepath.Join(dir, pathName), 0o755)
+		require.NoError(t, err)
+	}
+
+	writeSegmentGOP(t, filepath.Join(dir, "cam1", "2008-11-07_11-22-00-000000.mp4"), 6)
+	writeSegmentGOP(t, filepath.Join(dir, "cam2", "2008-11-07_11-22-02-000000.mp4"), 4)
+
+	s := &Server{
+		Address:      "127.0.0.1:9996",
+		ReadTimeout:  conf.Duration(10 * time.Second),
+		WriteTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"all_others": {
+				Regexp:     regexp.MustCompile("^.*$"),
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	start := time.Date(2008, 11, 7, 11, 22, 0, 0, time.Local)
+
+	v := url.Values{}
+	v.Add("path", "cam1")
+	v.Add("path", "cam2")
+	v.Add("path", "cam3") // no recordings
+	v.Set("start", start.Format(time.RFC3339Nano))
+	v.Set("duration", "10")
+
+	expectedTracks := func(t *testing.T, parts fmp4.Parts) {
+		byTrack := make(map[int][]*fmp4.PartTrack)
+		for _, part := range parts {
+			for _, track := range part.Tracks {
+				byTrack[track.ID] = append(byTrack[track.ID], track)
+			}
+		}
+
+		require.Len(t, byTrack, 2)
+
+		// tracks are aligned by absolute timestamps
+		require.Equal(t, uint64(0), byTrack[1][0].BaseTime)
+		require.Equal(t, uint64(2*90000), byTrack[2][0].BaseTime)
+
+		for id, count := range map[int]int{1: 6, 2: 4} {
+			n := 0
+			for _, track := range byTrack[id] {
+				n += len(track.Samples)
+			}
+			require.Equal(t, count, n)
+		}
+	}
+
+	t.Run("get", func(t *testing.T) {
+		byts := doHLSRequest(t, "/multi/get", v)
+
+		var init fmp4.Init
+		err = init.Unmarshal(bytes.NewReader(byts))
+		require.NoError(t, err)
+		require.Len(t, init.Tracks, 2)
+		require.Equal(t, 1, init.Tracks[0].ID)
+		require.Equal(t, 2, init.Tracks[1].ID)
+
+		var parts fmp4.Parts
+		err = parts.Unmarshal(byts)
+		require.NoError(t, err)
+
+		expectedTracks(t, parts)
+	})
+
+	t.Run("mp4", func(t *testing.T) {
+		v2 := url.Values{}
+		for key, values := range v {
+			v2[key] = values
+		}
+		v2.Set("format", "mp4")
+
+		byts := doHLSRequest(t, "/multi/get", v2)
+
+		var p pmp4.Presentation
+		err = p.Unmarshal(bytes.NewReader(byts))
+		require.NoError(t, err)
+
+		require.Len(t, p.Tracks, 2)
+		require.Equal(t, int32(0), p.Tracks[0].TimeOffset)
+		require.Equal(t, int32(2*90000), p.Tracks[1].TimeOffset)
+
+		for i, track := range p.Tracks {
+			for j, sample := range track.Samples {
+				var pl []byte
+				pl, err = sample.GetPayload()
+				require.NoError(t, err)
+				require.Equal(t, []byte{byte(j)}, pl, "track %d", i)
+			}
+		}
+	})
+
+	t.Run("websocket", func(t *testing.T) {
+		c, res, err2 := websocket.DefaultDialer.Dial("ws://localhost:9996/multi/ws?"+v.Encode(), nil)
+		require.NoError(t, err2)
+		defer res.Body.Close()
+		defer c.Close()
+
+		var buf []byte
+
+		for {
+			var typ int
+			var msg []byte
+			typ, msg, err2 = c.ReadMessage()
+			if err2 != nil {
+				break
+			}
+			require.Equal(t, websocket.BinaryMessage, typ)
+			buf = append(buf, msg...)
+		}
+
+		var parts fmp4.Parts
+		err = parts.Unmarshal(buf)
+		require.NoError(t, err)
+
+		expectedTracks(t, parts)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		v2 := url.Values{}
+		v2.Add("path", "cam3")
+		v2.Set("start", start.Format(time.RFC3339Nano))
+		v2.Set("duration", "10")
+
+		res, err2 := http.Get("http://localhost:9996/multi/get?" + v2.Encode())
+		require.NoError(t, err2)
+		defer res.Body.Close()
+
+		require.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+}
@@ -0,0 +1,84 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/protocols/httpp"
+	"github.com/bluenviron/mediamtx/internal/protocols/websocket"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) onWebSocket(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, pathName) {
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	// when start is not provided, playback begins from the live edge
+	start := time.Now()
+
+	if v := ctx.Query("start"); v != "" {
+		start, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+			return
+		}
+
+		_, err = recordstore.FindSegments(pathConf, pathName, nil, nil)
+		if err != nil {
+			if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+				s.writeError(ctx, http.StatusNotFound, err)
+			} else {
+				s.writeError(ctx, http.StatusBadRequest, err)
+			}
+			return
+		}
+	}
+
+	c, err := websocket.NewServerConn(ctx.Writer, ctx.Request)
+	if err != nil {
+		s.Log(logger.Error, err.Error())
+		return
+	}
+	defer c.Close()
+
+	sess := &websocketSession{
+		conn:     c,
+		pathConf: pathConf,
+		pathName: pathName,
+		accessRequest: defs.PathAccessRequest{
+			Name:        pathName,
+			Query:       ctx.Request.URL.RawQuery,
+			Credentials: httpp.Credentials(ctx.Request),
+			IP:          net.ParseIP(ctx.ClientIP()),
+		},
+		pathManager: s.PathManager,
+		parent:      s,
+	}
+	sess.initialize()
+
+	err = sess.run(ctx.Request.Context(), start)
+	if err != nil {
+		// user closed the connection
+		var neterr *net.OpError
+		if errors.As(err, &neterr) {
+			return
+		}
+
+		s.Log(logger.Error, err.Error())
+	}
+}
@@ -0,0 +1,311 @@
+package playback
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/externalcmd"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/bluenviron/mediamtx/internal/unit"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+type websocketTestMessage struct {
+	Type     string     `json:"type"`
+	Codecs   string     `json:"codecs"`
+	Position *time.Time `json:"position"`
+	Paused   bool       `json:"paused"`
+	Speed    float64    `json:"speed"`
+	Live     bool       `json:"live"`
+	Error    string     `json:"error"`
+}
+
+// readUntilLive reads messages until the live edge is reached,
+// and returns text messages and the base time and sample count of received parts.
+func readUntilLive(t *testing.T, c *websocket.Conn) ([]*websocketTestMessage, []uint64, int) {
+	var msgs []*websocketTestMessage
+	var baseTimes []uint64
+	sampleCount := 0
+
+	for {
+		typ, byts, err := c.ReadMessage()
+		require.NoError(t, err)
+
+		if typ == websocket.TextMessage {
+			var msg websocketTestMessage
+			err = json.Unmarshal(byts, &msg)
+			require.NoError(t, err)
+
+			if msg.Type == "state" && msg.Live {
+				return msgs, baseTimes, sampleCount
+			}
+
+			msgs = append(msgs, &msg)
+			continue
+		}
+
+		// initialization section
+		if bytes.Equal(byts[4:8], []byte("ftyp")) {
+			var init fmp4.Init
+			err = init.Unmarshal(bytes.NewReader(byts))
+			require.NoError(t, err)
+			require.Len(t, init.Tracks, 1)
+			continue
+		}
+
+		var parts fmp4.Parts
+		err = parts.Unmarshal(byts)
+		require.NoError(t, err)
+
+		for _, part := range parts {
+			baseTimes = append(baseTimes, part.Tracks[0].BaseTime)
+			sampleCount += len(part.Tracks[0].Samples)
+		}
+	}
+}
+
+func readMessage(t *testing.T, c *websocket.Conn) *websocketTestMessage {
+	for {
+		typ, byts, err := c.ReadMessage()
+		require.NoError(t, err)
+
+		if typ == websocket.TextMessage {
+			var msg websocketTestMessage
+			err = json.Unmarshal(byts, &msg)
+			require.NoError(t, err)
+			return &msg
+		}
+	}
+}
+
+func TestOnWebSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegmentGOP(t, filepath.Join(dir, "mypath", "2008-11-07_11-22-00-000000.mp4"), 10)
+	writeSegmentGOP(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-00-000000.mp4"), 4)
+
+	s := &Server{
+		Address:      "127.0.0.1:9996",
+		ReadTimeout:  conf.Duration(10 * time.Second),
+		WriteTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:               "mypath",
+				RecordPath:         filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				RecordPartDuration: conf.Duration(100 * time.Millisecond),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		PathManager: &test.PathManager{
+			AddReaderImpl: func(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error) {
+				return nil, nil, defs.PathNoStreamAvailableError{PathName: req.AccessRequest.Name}
+			},
+		},
+		Parent: test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	start1 := time.Date(2008, 11, 7, 11, 22, 0, 0, time.Local)
+	start2 := time.Date(2008, 11, 7, 11, 23, 0, 0, time.Local)
+
+	v := url.Values{}
+	v.Set("path", "mypath")
+	v.Set("start", start1.Format(time.RFC3339Nano))
+
+	c, res, err := websocket.DefaultDialer.Dial("ws://localhost:9996/ws?"+v.Encode(), nil)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	defer c.Close()
+
+	msg := readMessage(t, c)
+	require.Equal(t, &websocketTestMessage{Type: "init", Codecs: "avc1.42c028"}, msg)
+
+	err = c.WriteJSON(websocketCommand{Type: "speed", Speed: 100})
+	require.NoError(t, err)
+
+	_, baseTimes, sampleCount := readUntilLive(t, c)
+	require.Equal(t, 14, sampleCount)
+	require.Equal(t, uint64(start1.Unix())*90000, baseTimes[0])
+	require.Contains(t, baseTimes, uint64(start2.Unix())*90000)
+
+	// speed is reset when the live edge is reached
+	err = c.WriteJSON(websocketCommand{Type: "speed", Speed: 100})
+	require.NoError(t, err)
+
+	msg = readMessage(t, c)
+	require.Equal(t, "state", msg.Type)
+	require.Equal(t, float64(100), msg.Speed)
+
+	seekPos := start1.Add(5 * time.Second)
+	err = c.WriteJSON(websocketCommand{Type: "seek", Position: &seekPos})
+	require.NoError(t, err)
+
+	// playback starts from the keyframe that precedes the position
+	msgs, baseTimes, sampleCount := readUntilLive(t, c)
+	require.Contains(t, msgs, &websocketTestMessage{Type: "init", Codecs: "avc1.42c028"})
+	require.Equal(t, 10, sampleCount)
+	require.Equal(t, uint64(start1.Add(4*time.Second).Unix())*90000, baseTimes[0])
+
+	err = c.WriteJSON(websocketCommand{Type: "pause"})
+	require.NoError(t, err)
+
+	msg = readMessage(t, c)
+	require.Equal(t, "state", msg.Type)
+	require.True(t, msg.Paused)
+	require.Equal(t, float64(1), msg.Speed)
+	require.Equal(t, start2.Add(3*time.Second).Unix(), msg.Position.Unix())
+
+	err = c.WriteJSON(websocketCommand{Type: "speed", Speed: -1})
+	require.NoError(t, err)
+
+	msg = readMessage(t, c)
+	require.Equal(t, &websocketTestMessage{Type: "error", Error: "invalid speed"}, msg)
+}
+
+type dummyPath struct{}
+
+func (pa *dummyPath) Name() string {
+	return "mypath"
+}
+
+func (pa *dummyPath) SafeConf() *conf.Path {
+	return &conf.Path{}
+}
+
+func (pa *dummyPath) ExternalCmdEnv() externalcmd.Environment {
+	return nil
+}
+
+func (pa *dummyPath) RemovePublisher(_ defs.PathRemovePublisherReq) {
+}
+
+func (pa *dummyPath) RemoveReader(_ defs.PathRemoveReaderReq) {
+}
+
+func TestOnWebSocketLive(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	desc := &description.Session{Medias: []*description.Media{test.MediaH264}}
+
+	strm := &stream.Stream{
+		WriteQueueSize:     512,
+		RTPMaxPayloadSize:  1450,
+		Desc:               desc,
+		GenerateRTPPackets: true,
+		Parent:             test.NilLogger,
+	}
+	err = strm.Initialize()
+	require.NoError(t, err)
+	defer strm.Close()
+
+	readerAdded := make(chan struct{})
+
+	s := &Server{
+		Address:      "127.0.0.1:9996",
+		ReadTimeout:  conf.Duration(10 * time.Second),
+		WriteTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:               "mypath",
+				RecordPath:         filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				RecordPartDuration: conf.Duration(100 * time.Millisecond),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		PathManager: &test.PathManager{
+			AddReaderImpl: func(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error) {
+				require.Equal(t, "mypath", req.AccessRequest.Name)
+				close(readerAdded)
+				return &dummyPath{}, strm, nil
+			},
+		},
+		Parent: test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	// the path is not recorded, therefore playback starts from the live stream
+	c, res, err := websocket.DefaultDialer.Dial("ws://localhost:9996/ws?path=mypath", nil)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	defer c.Close()
+
+	msg := readMessage(t, c)
+	require.Equal(t, "state", msg.Type)
+	require.True(t, msg.Live)
+
+	<-readerAdded
+	time.Sleep(100 * time.Millisecond)
+
+	ntp := time.Date(2008, 11, 7, 11, 22, 0, 0, time.UTC)
+
+	for i := range 3 {
+		strm.WriteUnit(test.MediaH264, test.FormatH264, &unit.Unit{
+			NTP: ntp.Add(time.Duration(i) * time.Second),
+			PTS: int64(i) * 90000,
+			Payload: unit.PayloadH264{
+				{5, byte(i)}, // IDR
+			},
+		})
+	}
+
+	msg = readMessage(t, c)
+	require.Equal(t, &websocketTestMessage{Type: "init", Codecs: "avc1.42c028"}, msg)
+
+	// samples are sent as soon as their duration is known
+	var samples []*fmp4.Sample
+	var baseTime uint64
+
+	for len(samples) < 2 {
+		typ, byts, err := c.ReadMessage()
+		require.NoError(t, err)
+		require.Equal(t, websocket.BinaryMessage, typ)
+
+		if bytes.Equal(byts[4:8], []byte("ftyp")) {
+			continue
+		}
+
+		var parts fmp4.Parts
+		err = parts.Unmarshal(byts)
+		require.NoError(t, err)
+
+		for _, part := range parts {
+			if len(samples) == 0 {
+				baseTime = part.Tracks[0].BaseTime
+			}
+			samples = append(samples, part.Tracks[0].Samples...)
+		}
+	}
+
+	require.Equal(t, uint64(ntp.Unix())*90000, baseTime)
+
+	// parameters are prepended to IDRs by the stream
+	for i, sample := range samples {
+		var expected fmp4.Sample
+		err = expected.FillH264(0, [][]byte{test.FormatH264.SPS, test.FormatH264.PPS, {5, byte(i)}})
+		require.NoError(t, err)
+		expected.Duration = 90000
+		require.Equal(t, &expected, sample)
+	}
+}
@@ -0,0 +1,121 @@
+package playback
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+// Sample is a sample read from recordings.
+type Sample struct {
+	TrackID         int
+	NTP             time.Time
+	PTSOffset       time.Duration
+	IsNonSyncSample bool
+	Payload         []byte
+}
+
+func readInit(
+	ctx context.Context,
+	pathConf *conf.Path,
+	segment *recordstore.Segment,
+	date time.Time,
+) (*fmp4.Init, error) {
+	m := &muxerInit{}
+
+	err := seekAndMux(ctx, pathConf, []*recordstore.Segment{segment}, date, 0, m)
+	if err != nil && !errors.Is(err, errMuxingComplete) {
+		return nil, err
+	}
+
+	if m.init == nil {
+		return nil, recordstore.ErrNoSegmentsFound
+	}
+
+	return m.init, nil
+}
+
+// Reader reads samples from the recordings of a path.
+// It allows other servers to serve recordings.
+type Reader struct {
+	PathConf *conf.Path
+	PathName string
+	OnTracks func(tracks []*fmp4.InitTrack)
+	OnSample func(sample *Sample) error
+}
+
+// Tracks returns the tracks of the recording that contains the given date.
+func (r *Reader) Tracks(ctx context.Context, date time.Time) ([]*fmp4.InitTrack, error) {
+	segments, err := recordstore.FindSegments(r.PathConf, r.PathName, &date, &date)
+	if err != nil {
+		return nil, err
+	}
+
+	init, err := readInit(ctx, r.PathConf, segments[0], date)
+	if err != nil {
+		return nil, err
+	}
+
+	return init.Tracks, nil
+}
+
+// Read reads samples, starting from the last sync sample that precedes start.
+// Reading stops when duration is reached or when the recording is interrupted.
+func (r *Reader) Read(ctx context.Context, start time.Time, duration time.Duration) error {
+	end := start.Add(duration)
+	segments, err := recordstore.FindSegments(r.PathConf, r.PathName, &start, &end)
+	if err != nil {
+		return err
+	}
+
+	m := &muxerSamples{
+		start:    start,
+		onTracks: r.OnTracks,
+		onSample: r.OnSample,
+	}
+
+	return seekAndMux(ctx, r.PathConf, segments, start, duration, m)
+}
+
+// NextStart returns the start date of the first recording that begins after the given date.
+func (r *Reader) NextStart(date time.Time) (time.Time, error) {
+	segments, err := recordstore.FindSegments(r.PathConf, r.PathName, nil, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, seg := range segments {
+		if seg.Start.After(date) {
+			return seg.Start, nil
+		}
+	}
+
+	return time.Time{}, recordstore.ErrNoSegmentsFound
+}
+
+// PrevEnd returns the end date of the last recording that begins before the given date.
+func (r *Reader) PrevEnd(ctx context.Context, date time.Time) (time.Time, error) {
+	segments, err := recordstore.FindSegments(r.PathConf, r.PathName, nil, &date)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	seg := segments[len(segments)-1]
+	if !seg.Start.Before(date) {
+		if len(segments) == 1 {
+			return time.Time{}, recordstore.ErrNoSegmentsFound
+		}
+		seg = segments[len(segments)-2]
+	}
+
+	parsed, err := parseSegment(ctx, r.PathConf, seg)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return seg.Start.Add(parsed.duration), nil
+}
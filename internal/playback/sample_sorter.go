@@ -0,0 +1,54 @@
+package playback
+
+import (
+	"sort"
+	"time"
+)
+
+// SampleSorter sorts samples of different tracks by NTP.
+// Samples of fMP4 recordings are grouped by track into parts,
+// therefore they must be sorted before being sent.
+type SampleSorter struct {
+	// maximum distance between samples that are out of order.
+	Window   time.Duration
+	OnSample func(sample *Sample) error
+
+	pending []*Sample
+}
+
+// Push adds a sample and releases samples that are older than the window.
+func (s *SampleSorter) Push(sample *Sample) error {
+	i := sort.Search(len(s.pending), func(i int) bool {
+		return s.pending[i].NTP.After(sample.NTP)
+	})
+	s.pending = append(s.pending, nil)
+	copy(s.pending[i+1:], s.pending[i:])
+	s.pending[i] = sample
+
+	return s.release(false)
+}
+
+// Flush releases all samples.
+func (s *SampleSorter) Flush() error {
+	return s.release(true)
+}
+
+func (s *SampleSorter) release(all bool) error {
+	for len(s.pending) != 0 {
+		sample := s.pending[0]
+
+		if !all && s.pending[len(s.pending)-1].NTP.Sub(sample.NTP) < s.Window {
+			break
+		}
+
+		s.pending = s.pending[1:]
+
+		err := s.OnSample(sample)
+		if err != nil {
+			s.pending = nil
+			return err
+		}
+	}
+
+	return nil
+}
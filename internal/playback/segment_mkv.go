@@ -0,0 +1,412 @@
+package playback
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/ac3"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+
+	"github.com/bluenviron/mediamtx/internal/protocols/mkv"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+type dtsExtractor interface {
+	Extract(au [][]byte, pts int64) (int64, error)
+}
+
+// segmentMKVTrack converts a Matroska track into a MP4 track.
+// Matroska stores PTS only, therefore DTS of H264 and H265 tracks is recomputed.
+type segmentMKVTrack struct {
+	initTrack       *fmp4.InitTrack
+	params          [][]byte
+	newDTSExtractor func() dtsExtractor
+
+	dtsExtractor dtsExtractor
+	started      bool
+	lastDTS      int64
+	lastDuration int64
+	done         bool
+	finalDTS     int64
+}
+
+func ac3ReadCodec(r *mkv.Reader, trackNumber int) (*mp4.CodecAC3, error) {
+	var frame []byte
+
+	err := r.ReadBlocks(func(b *mkv.BlockInfo) error {
+		if b.TrackNumber != trackNumber {
+			return nil
+		}
+
+		var err error
+		frame, err = r.ReadPayload(b)
+		if err != nil {
+			return err
+		}
+		return errTerminated
+	})
+	if err != nil && !errors.Is(err, errTerminated) {
+		return nil, err
+	}
+
+	if frame == nil {
+		return nil, fmt.Errorf("AC-3 track is empty")
+	}
+
+	return ac3CodecFromFrame(frame)
+}
+
+func ac3CodecFromFrame(frame []byte) (*mp4.CodecAC3, error) {
+	var syncInfo ac3.SyncInfo
+	err := syncInfo.Unmarshal(frame)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AC-3 frame: %w", err)
+	}
+
+	var bsi ac3.BSI
+	err = bsi.Unmarshal(frame[5:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid AC-3 frame: %w", err)
+	}
+
+	return &mp4.CodecAC3{
+		SampleRate:   syncInfo.SampleRate(),
+		ChannelCount: bsi.ChannelCount(),
+		Fscod:        syncInfo.Fscod,
+		Bsid:         bsi.Bsid,
+		Bsmod:        bsi.Bsmod,
+		Acmod:        bsi.Acmod,
+		LfeOn:        bsi.LfeOn,
+		BitRateCode:  syncInfo.Frmsizecod >> 1,
+	}, nil
+}
+
+func segmentMKVReadTracks(r *mkv.Reader) (map[int]*segmentMKVTrack, error) {
+	tracks := make(map[int]*segmentMKVTrack)
+
+	for _, track := range r.Tracks {
+		st := &segmentMKVTrack{
+			initTrack: &fmp4.InitTrack{
+				ID:        track.Number,
+				TimeScale: 90000,
+			},
+		}
+
+		switch codec := track.Codec.(type) {
+		case *mkv.CodecAV1:
+			st.initTrack.Codec = &mp4.CodecAV1{
+				SequenceHeader: codec.SequenceHeader,
+			}
+
+		case *mkv.CodecVP9:
+			st.initTrack.Codec = &mp4.CodecVP9{
+				Width:             codec.Width,
+				Height:            codec.Height,
+				Profile:           codec.Profile,
+				BitDepth:          codec.BitDepth,
+				ChromaSubsampling: codec.ChromaSubsampling,
+				ColorRange:        codec.ColorRange,
+			}
+
+		case *mkv.CodecH265:
+			st.initTrack.Codec = &mp4.CodecH265{
+				VPS: codec.VPS,
+				SPS: codec.SPS,
+				PPS: codec.PPS,
+			}
+			st.params = [][]byte{codec.VPS, codec.SPS, codec.PPS}
+			st.newDTSExtractor = func() dtsExtractor {
+				d := &h265.DTSExtractor{}
+				d.Initialize()
+				return d
+			}
+
+		case *mkv.CodecH264:
+			st.initTrack.Codec = &mp4.CodecH264{
+				SPS: codec.SPS,
+				PPS: codec.PPS,
+			}
+			st.params = [][]byte{codec.SPS, codec.PPS}
+			st.newDTSExtractor = func() dtsExtractor {
+				d := &h264.DTSExtractor{}
+				d.Initialize()
+				return d
+			}
+
+		case *mkv.CodecMPEG4Video:
+			st.initTrack.Codec = &mp4.CodecMPEG4Video{
+				Config: codec.Config,
+			}
+
+		case *mkv.CodecMPEG1Video:
+			st.initTrack.Codec = &mp4.CodecMPEG1Video{
+				Config: codec.Config,
+			}
+
+		case *mkv.CodecMJPEG:
+			st.initTrack.Codec = &mp4.CodecMJPEG{
+				Width:  codec.Width,
+				Height: codec.Height,
+			}
+
+		case *mkv.CodecOpus:
+			st.initTrack.TimeScale = 48000
+			st.initTrack.Codec = &mp4.CodecOpus{
+				ChannelCount: codec.ChannelCount,
+			}
+
+		case *mkv.CodecMPEG4Audio:
+			st.initTrack.TimeScale = uint32(codec.Config.SampleRate)
+			st.initTrack.Codec = &mp4.CodecMPEG4Audio{
+				Config: codec.Config,
+			}
+
+		case *mkv.CodecMPEG1Audio:
+			st.initTrack.TimeScale = uint32(codec.SampleRate)
+			st.initTrack.Codec = &mp4.CodecMPEG1Audio{
+				SampleRate:   codec.SampleRate,
+				ChannelCount: codec.ChannelCount,
+			}
+
+		case *mkv.CodecAC3:
+			c, err := ac3ReadCodec(r, track.Number)
+			if err != nil {
+				return nil, err
+			}
+			st.initTrack.TimeScale = uint32(c.SampleRate)
+			st.initTrack.Codec = c
+
+		case *mkv.CodecLPCM:
+			st.initTrack.TimeScale = uint32(codec.SampleRate)
+			st.initTrack.Codec = &mp4.CodecLPCM{
+				LittleEndian: codec.LittleEndian,
+				BitDepth:     codec.BitDepth,
+				SampleRate:   codec.SampleRate,
+				ChannelCount: codec.ChannelCount,
+			}
+
+		default: // VP8 is not supported by MP4
+			continue
+		}
+
+		tracks[track.Number] = st
+	}
+
+	return tracks, nil
+}
+
+func segmentMKVInitTracks(tracks map[int]*segmentMKVTrack) []*fmp4.InitTrack {
+	out := make([]*fmp4.InitTrack, 0, len(tracks))
+
+	// keep the order of the file
+	for id := 1; len(out) < len(tracks); id++ {
+		if track, ok := tracks[id]; ok {
+			out = append(out, track.initTrack)
+		}
+	}
+
+	return out
+}
+
+func segmentMKVReadHeader(r *mkv.Reader) (*recordstore.MKVInfo, error) {
+	err := r.Initialize()
+	if err != nil {
+		return nil, err
+	}
+
+	var info recordstore.MKVInfo
+	if !info.Unmarshal(r.Tags) {
+		return nil, nil
+	}
+
+	return &info, nil
+}
+
+func segmentMKVCanBeConcatenated(
+	prevInfo *recordstore.MKVInfo,
+	prevEnd time.Time,
+	curInfo *recordstore.MKVInfo,
+	curStart time.Time,
+) bool {
+	return prevInfo != nil && curInfo != nil &&
+		bytes.Equal(prevInfo.StreamID[:], curInfo.StreamID[:]) &&
+		(prevInfo.SegmentNumber+1) == curInfo.SegmentNumber &&
+		!curStart.Before(prevEnd.Add(-concatenationTolerance)) &&
+		!curStart.After(prevEnd.Add(concatenationTolerance))
+}
+
+// segmentMKVReadDuration returns the duration of a segment.
+// When the segment has not been finalized, blocks are scanned.
+func segmentMKVReadDuration(r *mkv.Reader) (time.Duration, error) {
+	if r.Duration != 0 {
+		return r.Duration, nil
+	}
+
+	var duration time.Duration
+
+	err := r.ReadBlocks(func(b *mkv.BlockInfo) error {
+		if b.Timestamp > duration {
+			duration = b.Timestamp
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return duration, nil
+}
+
+func (t *segmentMKVTrack) computeDTS(au [][]byte, pts int64) int64 {
+	dts := pts
+
+	if t.newDTSExtractor != nil {
+		if t.dtsExtractor == nil {
+			t.dtsExtractor = t.newDTSExtractor()
+			au = append(append([][]byte(nil), t.params...), au...)
+		}
+
+		// timestamps are rounded to milliseconds, therefore extraction may fail.
+		// in this case, fall back to PTS.
+		if v, err := t.dtsExtractor.Extract(au, pts); err == nil && v <= pts {
+			dts = v
+		}
+	}
+
+	if t.started && dts < t.lastDTS {
+		dts = t.lastDTS
+	}
+
+	return dts
+}
+
+func segmentMKVMuxBlocks(
+	f readSeekerAt,
+	r *mkv.Reader,
+	startDTS time.Duration,
+	duration time.Duration,
+	initTracks []*fmp4.InitTrack,
+	m muxer,
+) (time.Duration, error) {
+	tracks, err := segmentMKVReadTracks(r)
+	if err != nil {
+		return 0, err
+	}
+
+	for id := range tracks {
+		// track is not present in the first segment
+		if findInitTrack(initTracks, id) == nil {
+			delete(tracks, id)
+		}
+	}
+
+	remaining := len(tracks)
+
+	err = r.ReadBlocks(func(b *mkv.BlockInfo) error {
+		track, ok := tracks[b.TrackNumber]
+		if !ok || track.done {
+			return nil
+		}
+
+		timeScale := track.initTrack.TimeScale
+		pts := durationGoToMp4(b.Timestamp, timeScale)
+		startDTSMP4 := durationGoToMp4(startDTS, timeScale)
+
+		var payload []byte
+
+		var dts int64
+
+		if track.newDTSExtractor != nil {
+			var err2 error
+			payload, err2 = r.ReadPayload(b)
+			if err2 != nil {
+				return err2
+			}
+
+			var au h264.AVCC
+			err2 = au.Unmarshal(payload)
+			if err2 != nil {
+				return err2
+			}
+
+			dts = track.computeDTS(au, pts)
+		} else {
+			dts = track.computeDTS(nil, pts)
+		}
+
+		if (dts + startDTSMP4) >= durationGoToMp4(duration, timeScale) {
+			track.done = true
+			track.finalDTS = dts
+
+			remaining--
+			if remaining == 0 {
+				return errTerminated
+			}
+			return nil
+		}
+
+		if track.started {
+			track.lastDuration = dts - track.lastDTS
+		}
+		track.started = true
+		track.lastDTS = dts
+
+		offset := b.PayloadOffset
+		size := b.PayloadSize
+
+		m.setTrack(track.initTrack.ID)
+
+		return m.writeSample(
+			dts+startDTSMP4,
+			int32(pts-dts),
+			!b.Keyframe,
+			uint32(size),
+			func() ([]byte, error) {
+				if payload != nil {
+					return payload, nil
+				}
+
+				buf := make([]byte, size)
+				n, err2 := f.ReadAt(buf, offset)
+				if err2 != nil {
+					return nil, err2
+				}
+				if n != size {
+					return nil, fmt.Errorf("partial read")
+				}
+
+				return buf, nil
+			},
+		)
+	})
+	if err != nil && !errors.Is(err, errTerminated) {
+		return 0, err
+	}
+
+	var segmentDuration time.Duration
+
+	for _, track := range tracks {
+		if !track.started {
+			continue
+		}
+
+		if !track.done {
+			track.finalDTS = track.lastDTS + track.lastDuration
+		}
+
+		m.setTrack(track.initTrack.ID)
+		m.writeFinalDTS(track.finalDTS + durationGoToMp4(startDTS, track.initTrack.TimeScale))
+
+		elapsed := durationMp4ToGo(track.finalDTS, track.initTrack.TimeScale)
+		if elapsed > segmentDuration {
+			segmentDuration = elapsed
+		}
+	}
+
+	return segmentDuration, nil
+}
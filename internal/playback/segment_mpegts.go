@@ -0,0 +1,603 @@
+package playback
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg1audio"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4video"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/opus"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mpegts"
+)
+
+const (
+	mpegtsTimestampMask = 0x1FFFFFFFF // 33 bits
+)
+
+func multiplyAndDivide(v, m, d int64) int64 {
+	secs := v / d
+	dec := v % d
+	return (secs*m + dec*m/d)
+}
+
+// mpegtsTimestampDiff returns the difference between two MPEG-TS timestamps,
+// taking into account wrap-around.
+func mpegtsTimestampDiff(a int64, b int64) int64 {
+	diff := (a - b) & mpegtsTimestampMask
+	if diff > (mpegtsTimestampMask / 2) {
+		return -((b - a) & mpegtsTimestampMask)
+	}
+	return diff
+}
+
+// segmentMPEGTSTrack converts a MPEG-TS track into a MP4 track.
+// MPEG-TS doesn't store codec parameters, therefore they are extracted from the first samples.
+type segmentMPEGTSTrack struct {
+	id        int
+	initTrack *fmp4.InitTrack
+
+	started      bool
+	lastDTS      int64
+	lastDuration int64
+	done         bool
+	finalDTS     int64
+}
+
+// segmentMPEGTSReader reads samples of a MPEG-TS segment.
+// Tracks are numbered in order of appearance in the PMT, starting from 1.
+// Sample DTS is expressed in the time scale of the track,
+// relative to the first timestamp of the segment.
+type segmentMPEGTSReader struct {
+	R        io.Reader
+	OnSample func(track *segmentMPEGTSTrack, dts int64, sample *fmp4.Sample) error
+
+	mr             *mpegts.Reader
+	td             *mpegts.TimeDecoder
+	tracks         []*segmentMPEGTSTrack
+	firstTimestamp int64
+	firstReceived  bool
+}
+
+func (r *segmentMPEGTSReader) initialize() error {
+	r.mr = &mpegts.Reader{R: r.R}
+	err := r.mr.Initialize()
+	if err != nil {
+		return err
+	}
+
+	r.td = &mpegts.TimeDecoder{}
+	r.td.Initialize()
+
+	for i, track := range r.mr.Tracks() {
+		st := &segmentMPEGTSTrack{
+			id: i + 1,
+		}
+
+		switch codec := track.Codec.(type) {
+		case *mpegts.CodecH265:
+			var vps, sps, pps []byte
+
+			r.mr.OnDataH265(track, func(pts int64, dts int64, au [][]byte) error {
+				if st.initTrack == nil {
+					for _, nalu := range au {
+						switch h265.NALUType((nalu[0] >> 1) & 0b111111) {
+						case h265.NALUType_VPS_NUT:
+							vps = nalu
+
+						case h265.NALUType_SPS_NUT:
+							sps = nalu
+
+						case h265.NALUType_PPS_NUT:
+							pps = nalu
+						}
+					}
+
+					if vps == nil || sps == nil || pps == nil {
+						return nil
+					}
+
+					st.initTrack = &fmp4.InitTrack{
+						ID:        st.id,
+						TimeScale: 90000,
+						Codec: &mp4.CodecH265{
+							VPS: vps,
+							SPS: sps,
+							PPS: pps,
+						},
+					}
+				}
+
+				var sample fmp4.Sample
+				err2 := sample.FillH265(0, au)
+				if err2 != nil {
+					return err2
+				}
+
+				return r.writeSample(st, pts, dts, 0, &sample)
+			})
+
+		case *mpegts.CodecH264:
+			var sps, pps []byte
+
+			r.mr.OnDataH264(track, func(pts int64, dts int64, au [][]byte) error {
+				if st.initTrack == nil {
+					for _, nalu := range au {
+						switch h264.NALUType(nalu[0] & 0x1F) {
+						case h264.NALUTypeSPS:
+							sps = nalu
+
+						case h264.NALUTypePPS:
+							pps = nalu
+						}
+					}
+
+					if sps == nil || pps == nil {
+						return nil
+					}
+
+					st.initTrack = &fmp4.InitTrack{
+						ID:        st.id,
+						TimeScale: 90000,
+						Codec: &mp4.CodecH264{
+							SPS: sps,
+							PPS: pps,
+						},
+					}
+				}
+
+				var sample fmp4.Sample
+				err2 := sample.FillH264(0, au)
+				if err2 != nil {
+					return err2
+				}
+
+				return r.writeSample(st, pts, dts, 0, &sample)
+			})
+
+		case *mpegts.CodecMPEG4Video:
+			r.mr.OnDataMPEGxVideo(track, func(pts int64, frame []byte) error {
+				if st.initTrack == nil {
+					if !bytes.HasPrefix(frame, []byte{0, 0, 1, byte(mpeg4video.VisualObjectSequenceStartCode)}) {
+						return nil
+					}
+
+					end := bytes.Index(frame[4:], []byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)})
+					if end < 0 {
+						return nil
+					}
+
+					st.initTrack = &fmp4.InitTrack{
+						ID:        st.id,
+						TimeScale: 90000,
+						Codec: &mp4.CodecMPEG4Video{
+							Config: frame[:end+4],
+						},
+					}
+				}
+
+				return r.writeSample(st, pts, pts, 0, &fmp4.Sample{
+					Payload: frame,
+					IsNonSyncSample: !bytes.Contains(frame,
+						[]byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)}),
+				})
+			})
+
+		case *mpegts.CodecMPEG1Video:
+			r.mr.OnDataMPEGxVideo(track, func(pts int64, frame []byte) error {
+				if st.initTrack == nil {
+					if !bytes.HasPrefix(frame, []byte{0, 0, 1, 0xB3}) {
+						return nil
+					}
+
+					end := bytes.Index(frame[4:], []byte{0, 0, 1, 0xB8})
+					if end < 0 {
+						return nil
+					}
+
+					st.initTrack = &fmp4.InitTrack{
+						ID:        st.id,
+						TimeScale: 90000,
+						Codec: &mp4.CodecMPEG1Video{
+							Config: frame[:end+4],
+						},
+					}
+				}
+
+				return r.writeSample(st, pts, pts, 0, &fmp4.Sample{
+					Payload:         frame,
+					IsNonSyncSample: !bytes.Contains(frame, []byte{0, 0, 1, 0xB8}),
+				})
+			})
+
+		case *mpegts.CodecOpus:
+			st.initTrack = &fmp4.InitTrack{
+				ID:        st.id,
+				TimeScale: 48000,
+				Codec: &mp4.CodecOpus{
+					ChannelCount: codec.ChannelCount,
+				},
+			}
+
+			r.mr.OnDataOpus(track, func(pts int64, packets [][]byte) error {
+				var offset int64
+
+				for _, packet := range packets {
+					err2 := r.writeSample(st, pts, pts, offset, &fmp4.Sample{
+						Payload: packet,
+					})
+					if err2 != nil {
+						return err2
+					}
+
+					offset += opus.PacketDuration2(packet)
+				}
+
+				return nil
+			})
+
+		case *mpegts.CodecMPEG4Audio:
+			st.initTrack = &fmp4.InitTrack{
+				ID:        st.id,
+				TimeScale: uint32(codec.Config.SampleRate),
+				Codec: &mp4.CodecMPEG4Audio{
+					Config: codec.Config,
+				},
+			}
+
+			r.mr.OnDataMPEG4Audio(track, func(pts int64, aus [][]byte) error {
+				for i, au := range aus {
+					err2 := r.writeSample(st, pts, pts, int64(i)*mpeg4audio.SamplesPerAccessUnit, &fmp4.Sample{
+						Payload: au,
+					})
+					if err2 != nil {
+						return err2
+					}
+				}
+
+				return nil
+			})
+
+		case *mpegts.CodecMPEG1Audio:
+			r.mr.OnDataMPEG1Audio(track, func(pts int64, frames [][]byte) error {
+				var offset int64
+
+				for _, frame := range frames {
+					var h mpeg1audio.FrameHeader
+					err2 := h.Unmarshal(frame)
+					if err2 != nil {
+						return err2
+					}
+
+					if st.initTrack == nil {
+						channelCount := 2
+						if h.ChannelMode == mpeg1audio.ChannelModeMono {
+							channelCount = 1
+						}
+
+						st.initTrack = &fmp4.InitTrack{
+							ID:        st.id,
+							TimeScale: uint32(h.SampleRate),
+							Codec: &mp4.CodecMPEG1Audio{
+								SampleRate:   h.SampleRate,
+								ChannelCount: channelCount,
+							},
+						}
+					}
+
+					err2 = r.writeSample(st, pts, pts, offset, &fmp4.Sample{
+						Payload: frame,
+					})
+					if err2 != nil {
+						return err2
+					}
+
+					offset += int64(h.SampleCount())
+				}
+
+				return nil
+			})
+
+		case *mpegts.CodecAC3:
+			r.mr.OnDataAC3(track, func(pts int64, frame []byte) error {
+				if st.initTrack == nil {
+					c, err2 := ac3CodecFromFrame(frame)
+					if err2 != nil {
+						return err2
+					}
+
+					st.initTrack = &fmp4.InitTrack{
+						ID:        st.id,
+						TimeScale: uint32(c.SampleRate),
+						Codec:     c,
+					}
+				}
+
+				return r.writeSample(st, pts, pts, 0, &fmp4.Sample{
+					Payload: frame,
+				})
+			})
+
+		default: // KLV and other codecs are not supported by MP4
+			continue
+		}
+
+		r.tracks = append(r.tracks, st)
+	}
+
+	return nil
+}
+
+func (r *segmentMPEGTSReader) writeSample(
+	track *segmentMPEGTSTrack,
+	pts int64,
+	dts int64,
+	offset int64,
+	sample *fmp4.Sample,
+) error {
+	if !r.firstReceived {
+		r.firstReceived = true
+		r.firstTimestamp = dts
+	}
+
+	decodedDTS := r.td.Decode(dts)
+
+	if track.initTrack == nil {
+		return nil
+	}
+
+	timeScale := int64(track.initTrack.TimeScale)
+
+	ptsOffset := mpegtsTimestampDiff(pts, dts)
+	if ptsOffset < 0 {
+		ptsOffset = 0
+	}
+	sample.PTSOffset = int32(multiplyAndDivide(ptsOffset, timeScale, 90000))
+
+	return r.OnSample(track, multiplyAndDivide(decodedDTS, timeScale, 90000)+offset, sample)
+}
+
+func (r *segmentMPEGTSReader) read() error {
+	for {
+		err := r.mr.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// segmentMPEGTSHeader contains informations about a MPEG-TS segment.
+type segmentMPEGTSHeader struct {
+	tracks []*fmp4.InitTrack
+
+	// first timestamp of the segment, as written into the file.
+	firstTimestamp int64
+
+	// DTS of the first sample, relative to firstTimestamp.
+	start time.Duration
+}
+
+// elapsedSince returns the time elapsed between the start of a previous segment and the start of this one,
+// computed with timestamps.
+func (h *segmentMPEGTSHeader) elapsedSince(prev *segmentMPEGTSHeader) time.Duration {
+	diff := mpegtsTimestampDiff(h.firstTimestamp, prev.firstTimestamp)
+	return durationMp4ToGo(diff, 90000) + h.start - prev.start
+}
+
+// segmentMPEGTSReadHeader reads tracks and the starting DTS of a segment.
+// The segment is read until every track has received its first sample.
+func segmentMPEGTSReadHeader(r io.Reader) (*segmentMPEGTSHeader, error) {
+	received := make(map[*segmentMPEGTSTrack]time.Duration)
+
+	sr := &segmentMPEGTSReader{R: r}
+	sr.OnSample = func(track *segmentMPEGTSTrack, dts int64, _ *fmp4.Sample) error {
+		if _, ok := received[track]; ok {
+			return nil
+		}
+
+		received[track] = durationMp4ToGo(dts, track.initTrack.TimeScale)
+
+		if len(received) == len(sr.tracks) {
+			return errTerminated
+		}
+		return nil
+	}
+
+	err := sr.initialize()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sr.tracks) != 0 {
+		err = sr.read()
+		if err != nil && !errors.Is(err, errTerminated) {
+			return nil, err
+		}
+	}
+
+	h := &segmentMPEGTSHeader{
+		firstTimestamp: sr.firstTimestamp,
+	}
+
+	first := true
+
+	for _, track := range sr.tracks {
+		start, ok := received[track]
+		if !ok {
+			continue
+		}
+
+		h.tracks = append(h.tracks, track.initTrack)
+
+		if first || start < h.start {
+			h.start = start
+			first = false
+		}
+	}
+
+	return h, nil
+}
+
+// segmentMPEGTSReadDuration returns the duration of a segment, by scanning every sample.
+func segmentMPEGTSReadDuration(r io.Reader, h *segmentMPEGTSHeader) (time.Duration, error) {
+	var duration time.Duration
+
+	sr := &segmentMPEGTSReader{R: r}
+	sr.OnSample = func(track *segmentMPEGTSTrack, dts int64, _ *fmp4.Sample) error {
+		d := durationMp4ToGo(dts, track.initTrack.TimeScale) - h.start
+		if d > duration {
+			duration = d
+		}
+		return nil
+	}
+
+	err := sr.initialize()
+	if err != nil {
+		return 0, err
+	}
+
+	err = sr.read()
+	if err != nil {
+		return 0, err
+	}
+
+	return duration, nil
+}
+
+func segmentMPEGTSTracksAreEqual(tracks1 []*fmp4.InitTrack, tracks2 []*fmp4.InitTrack) bool {
+	if len(tracks1) != len(tracks2) {
+		return false
+	}
+
+	for i, track1 := range tracks1 {
+		track2 := tracks2[i]
+
+		if track1.ID != track2.ID ||
+			track1.TimeScale != track2.TimeScale ||
+			reflect.TypeOf(track1.Codec) != reflect.TypeOf(track2.Codec) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// segmentMPEGTSCanBeConcatenated checks whether two segments belong to the same stream.
+// MPEG-TS segments do not contain a stream ID, but timestamps of segments of the same stream
+// are consecutive, therefore the time elapsed between segments must match their timestamps.
+func segmentMPEGTSCanBeConcatenated(
+	prevHeader *segmentMPEGTSHeader,
+	prevStart time.Time,
+	prevEnd time.Time,
+	curHeader *segmentMPEGTSHeader,
+	curStart time.Time,
+) bool {
+	if !segmentMPEGTSTracksAreEqual(prevHeader.tracks, curHeader.tracks) ||
+		curStart.Before(prevEnd.Add(-concatenationTolerance)) ||
+		curStart.After(prevEnd.Add(concatenationTolerance)) {
+		return false
+	}
+
+	diff := curHeader.elapsedSince(prevHeader) - curStart.Sub(prevStart)
+	return diff > -concatenationTolerance && diff < concatenationTolerance
+}
+
+func segmentMPEGTSMuxSamples(
+	r io.Reader,
+	h *segmentMPEGTSHeader,
+	startDTS time.Duration,
+	duration time.Duration,
+	initTracks []*fmp4.InitTrack,
+	m muxer,
+) (time.Duration, error) {
+	remaining := 0
+
+	sr := &segmentMPEGTSReader{R: r}
+	sr.OnSample = func(track *segmentMPEGTSTrack, dts int64, sample *fmp4.Sample) error {
+		// track is not present in the first segment
+		if track.done || findInitTrack(initTracks, track.id) == nil {
+			return nil
+		}
+
+		timeScale := track.initTrack.TimeScale
+		dts -= durationGoToMp4(h.start, timeScale)
+		startDTSMP4 := durationGoToMp4(startDTS, timeScale)
+
+		if (dts + startDTSMP4) >= durationGoToMp4(duration, timeScale) {
+			track.done = true
+			track.finalDTS = dts
+
+			remaining--
+			if remaining == 0 {
+				return errTerminated
+			}
+			return nil
+		}
+
+		if track.started {
+			track.lastDuration = dts - track.lastDTS
+		}
+		track.started = true
+		track.lastDTS = dts
+
+		m.setTrack(track.id)
+
+		return m.writeSample(
+			dts+startDTSMP4,
+			sample.PTSOffset,
+			sample.IsNonSyncSample,
+			uint32(len(sample.Payload)),
+			func() ([]byte, error) {
+				return sample.Payload, nil
+			},
+		)
+	}
+
+	err := sr.initialize()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, track := range sr.tracks {
+		if findInitTrack(initTracks, track.id) != nil {
+			remaining++
+		}
+	}
+
+	err = sr.read()
+	if err != nil && !errors.Is(err, errTerminated) {
+		return 0, err
+	}
+
+	var segmentDuration time.Duration
+
+	for _, track := range sr.tracks {
+		if !track.started {
+			continue
+		}
+
+		if !track.done {
+			track.finalDTS = track.lastDTS + track.lastDuration
+		}
+
+		m.setTrack(track.id)
+		m.writeFinalDTS(track.finalDTS + durationGoToMp4(startDTS, track.initTrack.TimeScale))
+
+		elapsed := durationMp4ToGo(track.finalDTS, track.initTrack.TimeScale)
+		if elapsed > segmentDuration {
+			segmentDuration = elapsed
+		}
+	}
+
+	return segmentDuration, nil
+}
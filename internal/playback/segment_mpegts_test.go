@@ -0,0 +1,45 @@
+package playback
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMPEGTSTimestampDiff(t *testing.T) {
+	for _, ca := range []struct {
+		name string
+		a    int64
+		b    int64
+		diff int64
+	}{
+		{
+			"positive",
+			90000,
+			45000,
+			45000,
+		},
+		{
+			"negative",
+			45000,
+			90000,
+			-45000,
+		},
+		{
+			"wrap around",
+			1000,
+			0x1FFFFFFFF - 1000,
+			2001,
+		},
+		{
+			"negative wrap around",
+			0x1FFFFFFFF - 1000,
+			1000,
+			-2001,
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			require.Equal(t, ca.diff, mpegtsTimestampDiff(ca.a, ca.b))
+		})
+	}
+}
@@ -9,8 +9,10 @@ import (
 
 	"github.com/bluenviron/mediamtx/internal/auth"
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/protocols/httpp"
+	"github.com/bluenviron/mediamtx/internal/stream"
 	"github.com/gin-gonic/gin"
 )
 
@@ -18,26 +20,47 @@ type serverAuthManager interface {
 	Authenticate(req *auth.Request) *auth.Error
 }
 
+type serverPathManager interface {
+	AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error)
+}
+
 // Server is the playback server.
 type Server struct {
-	Address        string
-	Encryption     bool
-	ServerKey      string
-	ServerCert     string
-	AllowOrigin    string
-	TrustedProxies conf.IPNetworks
-	ReadTimeout    conf.Duration
-	WriteTimeout   conf.Duration
-	PathConfs      map[string]*conf.Path
-	AuthManager    serverAuthManager
-	Parent         logger.Writer
+	Address              string
+	Encryption           bool
+	ServerKey            string
+	ServerCert           string
+	AllowOrigin          string
+	TrustedProxies       conf.IPNetworks
+	ProxyProtocolSources conf.IPNetworks
+	ReadTimeout          conf.Duration
+	WriteTimeout         conf.Duration
+	ExportDirectory      string
+	ExportTTL            conf.Duration
+	PathConfs            map[string]*conf.Path
+	AuthManager          serverAuthManager
+	PathManager          serverPathManager
+	Parent               logger.Writer
 
 	httpServer *httpp.Server
+	exporter   *exporter
 	mutex      sync.RWMutex
 }
 
 // Initialize initializes Server.
 func (s *Server) Initialize() error {
+	if s.ExportDirectory != "" {
+		s.exporter = &exporter{
+			directory: s.ExportDirectory,
+			ttl:       time.Duration(s.ExportTTL),
+			parent:    s,
+		}
+		err := s.exporter.initialize()
+		if err != nil {
+			return err
+		}
+	}
+
 	router := gin.New()
 	router.SetTrustedProxies(s.TrustedProxies.ToTrustedProxies()) //nolint:errcheck
 
@@ -45,19 +68,38 @@ func (s *Server) Initialize() error {
 
 	router.GET("/list", s.onList)
 	router.GET("/get", s.onGet)
+	router.GET("/hls/index.m3u8", s.onHLSPlaylist)
+	router.GET("/hls/init.mp4", s.onHLSInit)
+	router.GET("/hls/segment.mp4", s.onHLSSegment)
+	router.GET("/dash/index.mpd", s.onDASHManifest)
+	router.GET("/dash/init.mp4", s.onHLSInit)
+	router.GET("/dash/segment.mp4", s.onHLSSegment)
+	router.GET("/multi/get", s.onMultiGet)
+	router.GET("/multi/ws", s.onMultiWebSocket)
+	router.GET("/ws", s.onWebSocket)
+	router.GET("/keyframes", s.onKeyframes)
+	router.GET("/keyframe", s.onKeyframe)
+	router.POST("/exports", s.onExportsCreate)
+	router.GET("/exports/:id", s.onExportsGet)
+	router.GET("/exports/:id/download", s.onExportsDownload)
+	router.DELETE("/exports/:id", s.onExportsDelete)
 
 	s.httpServer = &httpp.Server{
-		Address:      s.Address,
-		ReadTimeout:  time.Duration(s.ReadTimeout),
-		WriteTimeout: time.Duration(s.WriteTimeout),
-		Encryption:   s.Encryption,
-		ServerCert:   s.ServerCert,
-		ServerKey:    s.ServerKey,
-		Handler:      router,
-		Parent:       s,
+		Address:              s.Address,
+		ReadTimeout:          time.Duration(s.ReadTimeout),
+		WriteTimeout:         time.Duration(s.WriteTimeout),
+		Encryption:           s.Encryption,
+		ServerCert:           s.ServerCert,
+		ServerKey:            s.ServerKey,
+		ProxyProtocolSources: s.ProxyProtocolSources,
+		Handler:              router,
+		Parent:               s,
 	}
 	err := s.httpServer.Initialize()
 	if err != nil {
+		if s.exporter != nil {
+			s.exporter.close()
+		}
 		return err
 	}
 
@@ -70,6 +112,10 @@ func (s *Server) Initialize() error {
 func (s *Server) Close() {
 	s.Log(logger.Info, "listener is closing")
 	s.httpServer.Close()
+
+	if s.exporter != nil {
+		s.exporter.close()
+	}
 }
 
 // Log implements logger.Writer.
@@ -107,8 +153,8 @@ func (s *Server) middlewareOrigin(ctx *gin.Context) {
 	// preflight requests
 	if ctx.Request.Method == http.MethodOptions &&
 		ctx.Request.Header.Get("Access-Control-Request-Method") != "" {
-		ctx.Header("Access-Control-Allow-Methods", "OPTIONS, GET")
-		ctx.Header("Access-Control-Allow-Headers", "Authorization")
+		ctx.Header("Access-Control-Allow-Methods", "OPTIONS, GET, POST, DELETE")
+		ctx.Header("Access-Control-Allow-Headers", "Authorization, Content-Type")
 		ctx.AbortWithStatus(http.StatusNoContent)
 		return
 	}
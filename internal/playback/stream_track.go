@@ -0,0 +1,164 @@
+package playback
+
+import (
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
+	"github.com/bluenviron/gortsplib/v5/pkg/format"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/av1"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+// StreamTrack allows to write samples of a recorded track to a stream.
+type StreamTrack struct {
+	Media     *description.Media
+	Format    format.Format
+	ToPayload func([]byte) (unit.Payload, error)
+}
+
+// NewStreamTrack converts a recorded track into a StreamTrack.
+// It returns nil if the track can't be converted.
+func NewStreamTrack(track *fmp4.InitTrack) *StreamTrack {
+	t := &StreamTrack{}
+
+	switch codec := track.Codec.(type) {
+	case *mp4.CodecAV1:
+		t.Media = &description.Media{Type: description.MediaTypeVideo}
+		t.Format = &format.AV1{
+			PayloadTyp: 96,
+		}
+		t.ToPayload = func(b []byte) (unit.Payload, error) {
+			var bs av1.Bitstream
+			err := bs.Unmarshal(b)
+			return unit.PayloadAV1(bs), err
+		}
+
+	case *mp4.CodecVP9:
+		t.Media = &description.Media{Type: description.MediaTypeVideo}
+		t.Format = &format.VP9{
+			PayloadTyp: 96,
+		}
+		t.ToPayload = func(b []byte) (unit.Payload, error) {
+			return unit.PayloadVP9(b), nil
+		}
+
+	case *mp4.CodecH265:
+		t.Media = &description.Media{Type: description.MediaTypeVideo}
+		t.Format = &format.H265{
+			PayloadTyp: 96,
+			VPS:        codec.VPS,
+			SPS:        codec.SPS,
+			PPS:        codec.PPS,
+		}
+		t.ToPayload = func(b []byte) (unit.Payload, error) {
+			var au h264.AVCC
+			err := au.Unmarshal(b)
+			return unit.PayloadH265(au), err
+		}
+
+	case *mp4.CodecH264:
+		t.Media = &description.Media{Type: description.MediaTypeVideo}
+		t.Format = &format.H264{
+			PayloadTyp:        96,
+			PacketizationMode: 1,
+			SPS:               codec.SPS,
+			PPS:               codec.PPS,
+		}
+		t.ToPayload = func(b []byte) (unit.Payload, error) {
+			var au h264.AVCC
+			err := au.Unmarshal(b)
+			return unit.PayloadH264(au), err
+		}
+
+	case *mp4.CodecMPEG4Video:
+		t.Media = &description.Media{Type: description.MediaTypeVideo}
+		t.Format = &format.MPEG4Video{
+			PayloadTyp: 96,
+			Config:     codec.Config,
+		}
+		t.ToPayload = func(b []byte) (unit.Payload, error) {
+			return unit.PayloadMPEG4Video(b), nil
+		}
+
+	case *mp4.CodecMPEG1Video:
+		t.Media = &description.Media{Type: description.MediaTypeVideo}
+		t.Format = &format.MPEG1Video{}
+		t.ToPayload = func(b []byte) (unit.Payload, error) {
+			return unit.PayloadMPEG1Video(b), nil
+		}
+
+	case *mp4.CodecMJPEG:
+		t.Media = &description.Media{Type: description.MediaTypeVideo}
+		t.Format = &format.MJPEG{}
+		t.ToPayload = func(b []byte) (unit.Payload, error) {
+			return unit.PayloadMJPEG(b), nil
+		}
+
+	case *mp4.CodecOpus:
+		t.Media = &description.Media{Type: description.MediaTypeAudio}
+		t.Format = &format.Opus{
+			PayloadTyp:   96,
+			ChannelCount: codec.ChannelCount,
+		}
+		t.ToPayload = func(b []byte) (unit.Payload, error) {
+			return unit.PayloadOpus{b}, nil
+		}
+
+	case *mp4.CodecMPEG4Audio:
+		t.Media = &description.Media{Type: description.MediaTypeAudio}
+		t.Format = &format.MPEG4Audio{
+			PayloadTyp:       96,
+			SizeLength:       13,
+			IndexLength:      3,
+			IndexDeltaLength: 3,
+			Config:           &codec.Config,
+		}
+		t.ToPayload = func(b []byte) (unit.Payload, error) {
+			return unit.PayloadMPEG4Audio{b}, nil
+		}
+
+	case *mp4.CodecMPEG1Audio:
+		t.Media = &description.Media{Type: description.MediaTypeAudio}
+		t.Format = &format.MPEG1Audio{}
+		t.ToPayload = func(b []byte) (unit.Payload, error) {
+			return unit.PayloadMPEG1Audio{b}, nil
+		}
+
+	case *mp4.CodecAC3:
+		t.Media = &description.Media{Type: description.MediaTypeAudio}
+		t.Format = &format.AC3{
+			PayloadTyp:   96,
+			SampleRate:   codec.SampleRate,
+			ChannelCount: codec.ChannelCount,
+		}
+		t.ToPayload = func(b []byte) (unit.Payload, error) {
+			return unit.PayloadAC3{b}, nil
+		}
+
+	case *mp4.CodecLPCM:
+		// RTP supports big-endian LPCM only
+		if codec.LittleEndian {
+			return nil
+		}
+
+		t.Media = &description.Media{Type: description.MediaTypeAudio}
+		t.Format = &format.LPCM{
+			PayloadTyp:   96,
+			BitDepth:     codec.BitDepth,
+			SampleRate:   codec.SampleRate,
+			ChannelCount: codec.ChannelCount,
+		}
+		t.ToPayload = func(b []byte) (unit.Payload, error) {
+			return unit.PayloadLPCM(b), nil
+		}
+
+	default:
+		return nil
+	}
+
+	t.Media.Formats = []format.Format{t.Format}
+
+	return t
+}
@@ -0,0 +1,427 @@
+package playback
+
+import (
+	"context"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/av1"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+	"github.com/gin-gonic/gin"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/protocols/mkv"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+type listTrack struct {
+	ID           int    `json:"id"`
+	Codec        string `json:"codec"`
+	Width        int    `json:"width,omitempty"`
+	Height       int    `json:"height,omitempty"`
+	SampleRate   int    `json:"sampleRate,omitempty"`
+	ChannelCount int    `json:"channelCount,omitempty"`
+}
+
+type listSegment struct {
+	Start    time.Time         `json:"start"`
+	Duration listEntryDuration `json:"duration"`
+	Format   conf.RecordFormat `json:"format"`
+	Size     int64             `json:"size"`
+	URL      string            `json:"url"`
+}
+
+type listGap struct {
+	Start    time.Time         `json:"start"`
+	Duration listEntryDuration `json:"duration"`
+}
+
+type listSummary struct {
+	Start    time.Time         `json:"start"`
+	Duration listEntryDuration `json:"duration"`
+	Recorded listEntryDuration `json:"recorded"`
+	Coverage float64           `json:"coverage"`
+}
+
+type listTimeline struct {
+	Entries []listEntry   `json:"entries"`
+	Gaps    []listGap     `json:"gaps"`
+	Summary []listSummary `json:"summary"`
+}
+
+func describeTrack(track *fmp4.InitTrack) listTrack {
+	out := listTrack{ID: track.ID}
+
+	switch codec := track.Codec.(type) {
+	case *mp4.CodecAV1:
+		out.Codec = "AV1"
+		var sh av1.SequenceHeader
+		if sh.Unmarshal(codec.SequenceHeader) == nil {
+			out.Width = sh.Width()
+			out.Height = sh.Height()
+		}
+
+	case *mp4.CodecVP9:
+		out.Codec = "VP9"
+		out.Width = codec.Width
+		out.Height = codec.Height
+
+	case *mp4.CodecH265:
+		out.Codec = "H265"
+		var sps h265.SPS
+		if sps.Unmarshal(codec.SPS) == nil {
+			out.Width = sps.Width()
+			out.Height = sps.Height()
+		}
+
+	case *mp4.CodecH264:
+		out.Codec = "H264"
+		var sps h264.SPS
+		if sps.Unmarshal(codec.SPS) == nil {
+			out.Width = sps.Width()
+			out.Height = sps.Height()
+		}
+
+	case *mp4.CodecMPEG4Video:
+		out.Codec = "MPEG-4 Video"
+
+	case *mp4.CodecMPEG1Video:
+		out.Codec = "MPEG-1/2 Video"
+
+	case *mp4.CodecMJPEG:
+		out.Codec = "M-JPEG"
+		out.Width = codec.Width
+		out.Height = codec.Height
+
+	case *mp4.CodecOpus:
+		out.Codec = "Opus"
+		out.SampleRate = 48000
+		out.ChannelCount = codec.ChannelCount
+
+	case *mp4.CodecMPEG4Audio:
+		out.Codec = "MPEG-4 Audio"
+		out.SampleRate = codec.Config.SampleRate
+		out.ChannelCount = codec.Config.ChannelCount
+
+	case *mp4.CodecMPEG1Audio:
+		out.Codec = "MPEG-1/2 Audio"
+		out.SampleRate = codec.SampleRate
+		out.ChannelCount = codec.ChannelCount
+
+	case *mp4.CodecAC3:
+		out.Codec = "AC-3"
+		out.SampleRate = codec.SampleRate
+		out.ChannelCount = codec.ChannelCount
+
+	case *mp4.CodecLPCM:
+		out.Codec = "LPCM"
+		out.SampleRate = codec.SampleRate
+		out.ChannelCount = codec.ChannelCount
+	}
+
+	return out
+}
+
+func tracksAreEqual(tracks1 []listTrack, tracks2 []listTrack) bool {
+	if len(tracks1) != len(tracks2) {
+		return false
+	}
+
+	for i, track := range tracks1 {
+		if track != tracks2[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func segmentReadTracks(ctx context.Context, pathConf *conf.Path, p *parsedSegment) ([]*fmp4.InitTrack, error) {
+	// tracks have already been read when parsing the segment
+	switch {
+	case p.init != nil:
+		return p.init.Tracks, nil
+
+	case p.mpegtsHeader != nil:
+		return p.mpegtsHeader.tracks, nil
+	}
+
+	f, err := recordstore.OpenSegment(ctx, pathConf, p.segment)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch pathConf.RecordFormat {
+	case conf.RecordFormatMPEGTS:
+		var header *segmentMPEGTSHeader
+		header, err = segmentMPEGTSReadHeader(f)
+		if err != nil {
+			return nil, err
+		}
+		return header.tracks, nil
+
+	case conf.RecordFormatMKV, conf.RecordFormatWebM:
+		r := &mkv.Reader{R: f}
+		err = r.Initialize()
+		if err != nil {
+			return nil, err
+		}
+
+		var tracks map[int]*segmentMKVTrack
+		tracks, err = segmentMKVReadTracks(r)
+		if err != nil {
+			return nil, err
+		}
+		return segmentMKVInitTracks(tracks), nil
+	}
+
+	init, _, err := segmentFMP4ReadHeader(f)
+	if err != nil {
+		return nil, err
+	}
+	return init.Tracks, nil
+}
+
+type segmentDetails struct {
+	tracks []listTrack
+	size   int64
+}
+
+func readSegmentDetails(ctx context.Context, pathConf *conf.Path, p *parsedSegment) (*segmentDetails, error) {
+	size, err := recordstore.SegmentSize(ctx, pathConf, p.segment)
+	if err != nil {
+		return nil, err
+	}
+
+	tracks, err := segmentReadTracks(ctx, pathConf, p)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &segmentDetails{
+		tracks: make([]listTrack, len(tracks)),
+		size:   size,
+	}
+
+	for i, track := range tracks {
+		out.tracks[i] = describeTrack(track)
+	}
+
+	return out, nil
+}
+
+func readAllSegmentDetails(
+	ctx context.Context,
+	pathConf *conf.Path,
+	entries []listEntry,
+) (map[*parsedSegment]*segmentDetails, error) {
+	type result struct {
+		p       *parsedSegment
+		details *segmentDetails
+		err     error
+	}
+
+	ch := make(chan result)
+	n := 0
+
+	// like parseSegments(), read segments in parallel.
+	for _, entry := range entries {
+		for _, p := range entry.segments {
+			n++
+			go func(p *parsedSegment) {
+				details, err := readSegmentDetails(ctx, pathConf, p)
+				ch <- result{p, details, err}
+			}(p)
+		}
+	}
+
+	out := make(map[*parsedSegment]*segmentDetails, n)
+	var err error
+
+	for range n {
+		res := <-ch
+		if res.err != nil {
+			err = res.err
+		} else {
+			out[res.p] = res.details
+		}
+	}
+
+	return out, err
+}
+
+// timelineRange returns the interval covered by the timeline.
+func timelineRange(entries []listEntry, start *time.Time, end *time.Time) (time.Time, time.Time) {
+	var rangeStart time.Time
+	if start != nil {
+		rangeStart = *start
+	} else {
+		rangeStart = entries[0].Start
+	}
+
+	var rangeEnd time.Time
+	if end != nil {
+		rangeEnd = *end
+
+		// do not report the future as a gap
+		if now := time.Now(); rangeEnd.After(now) {
+			rangeEnd = now
+		}
+	} else {
+		last := entries[len(entries)-1]
+		rangeEnd = last.Start.Add(time.Duration(last.Duration))
+	}
+
+	if rangeEnd.Before(rangeStart) {
+		rangeEnd = rangeStart
+	}
+
+	return rangeStart, rangeEnd
+}
+
+func timelineGaps(entries []listEntry, rangeStart time.Time, rangeEnd time.Time) []listGap {
+	out := []listGap{}
+	cur := rangeStart
+
+	for _, entry := range entries {
+		if entry.Start.After(cur) {
+			out = append(out, listGap{
+				Start:    cur,
+				Duration: listEntryDuration(entry.Start.Sub(cur)),
+			})
+		}
+
+		entryEnd := entry.Start.Add(time.Duration(entry.Duration))
+		if entryEnd.After(cur) {
+			cur = entryEnd
+		}
+	}
+
+	if rangeEnd.After(cur) {
+		out = append(out, listGap{
+			Start:    cur,
+			Duration: listEntryDuration(rangeEnd.Sub(cur)),
+		})
+	}
+
+	return out
+}
+
+// recordedDuration returns the recorded duration between start and end.
+func recordedDuration(entries []listEntry, start time.Time, end time.Time) time.Duration {
+	var out time.Duration
+
+	for _, entry := range entries {
+		entryStart := entry.Start
+		if entryStart.Before(start) {
+			entryStart = start
+		}
+
+		entryEnd := entry.Start.Add(time.Duration(entry.Duration))
+		if entryEnd.After(end) {
+			entryEnd = end
+		}
+
+		if entryEnd.After(entryStart) {
+			out += entryEnd.Sub(entryStart)
+		}
+	}
+
+	return out
+}
+
+func newListSummary(entries []listEntry, start time.Time, end time.Time) listSummary {
+	duration := end.Sub(start)
+	recorded := recordedDuration(entries, start, end)
+
+	var coverage float64
+	if duration > 0 {
+		coverage = float64(recorded) * 100 / float64(duration)
+	}
+
+	return listSummary{
+		Start:    start,
+		Duration: listEntryDuration(duration),
+		Recorded: listEntryDuration(recorded),
+		Coverage: coverage,
+	}
+}
+
+// timelineSummary computes coverage of the whole range, or of every day or month of the range.
+func timelineSummary(entries []listEntry, rangeStart time.Time, rangeEnd time.Time, period string) []listSummary {
+	if period == "" {
+		return []listSummary{newListSummary(entries, rangeStart, rangeEnd)}
+	}
+
+	out := []listSummary{}
+
+	y, m, d := rangeStart.Date()
+	if period == "month" {
+		d = 1
+	}
+	cur := time.Date(y, m, d, 0, 0, 0, 0, rangeStart.Location())
+
+	for {
+		var next time.Time
+		if period == "month" {
+			next = cur.AddDate(0, 1, 0)
+		} else {
+			next = cur.AddDate(0, 0, 1)
+		}
+
+		out = append(out, newListSummary(entries, cur, next))
+
+		if !next.Before(rangeEnd) {
+			break
+		}
+		cur = next
+	}
+
+	return out
+}
+
+func (s *Server) buildTimeline(
+	ctx *gin.Context,
+	pathConf *conf.Path,
+	pathName string,
+	entries []listEntry,
+	start *time.Time,
+	end *time.Time,
+	summaryPeriod string,
+) (*listTimeline, error) {
+	details, err := readAllSegmentDetails(ctx.Request.Context(), pathConf, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		entries[i].Tracks = details[entries[i].segments[0]].tracks
+
+		if i != 0 && !tracksAreEqual(entries[i-1].Tracks, entries[i].Tracks) {
+			entries[i].CodecChanged = true
+		}
+
+		entries[i].Segments = make([]listSegment, len(entries[i].segments))
+
+		for j, p := range entries[i].segments {
+			entries[i].Segments[j] = listSegment{
+				Start:    p.start,
+				Duration: listEntryDuration(p.duration),
+				Format:   pathConf.RecordFormat,
+				Size:     details[p].size,
+				URL:      s.getURL(ctx, pathName, p.start, p.duration),
+			}
+		}
+	}
+
+	rangeStart, rangeEnd := timelineRange(entries, start, end)
+
+	return &listTimeline{
+		Entries: entries,
+		Gaps:    timelineGaps(entries, rangeStart, rangeEnd),
+		Summary: timelineSummary(entries, rangeStart, rangeEnd, summaryPeriod),
+	}, nil
+}
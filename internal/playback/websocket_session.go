@@ -0,0 +1,612 @@
+package playback
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/protocols/dash"
+	"github.com/bluenviron/mediamtx/internal/protocols/websocket"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/bluenviron/mediamtx/internal/stream"
+)
+
+const (
+	// duration of each read.
+	websocketReadDuration = 24 * time.Hour
+
+	// how much samples are sent in advance with respect to the playback position.
+	websocketLead = 2 * time.Second
+
+	// how often the live stream is requested when no one is publishing.
+	websocketLiveRetryPause = 2 * time.Second
+)
+
+var errWebSocketPaused = errors.New("playback paused")
+
+type websocketCommand struct {
+	Type     string     `json:"type"`
+	Position *time.Time `json:"position,omitempty"`
+	Speed    float64    `json:"speed,omitempty"`
+}
+
+type websocketInitMessage struct {
+	Type   string `json:"type"`
+	Codecs string `json:"codecs"`
+}
+
+type websocketStateMessage struct {
+	Type     string     `json:"type"`
+	Position *time.Time `json:"position"`
+	Paused   bool       `json:"paused"`
+	Speed    float64    `json:"speed"`
+	Live     bool       `json:"live"`
+}
+
+type websocketErrorMessage struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+func initTracksAreEqual(tracks1 []*fmp4.InitTrack, tracks2 []*fmp4.InitTrack) bool {
+	if len(tracks1) != len(tracks2) {
+		return false
+	}
+
+	for i, track := range tracks1 {
+		if track.ID != tracks2[i].ID ||
+			track.TimeScale != tracks2[i].TimeScale ||
+			!reflect.DeepEqual(track.Codec, tracks2[i].Codec) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func tracksCodecs(tracks []*fmp4.InitTrack) string {
+	codecs := make([]string, len(tracks))
+	for i, track := range tracks {
+		codecs[i] = dash.CodecString(track.Codec)
+	}
+	return strings.Join(codecs, ",")
+}
+
+// websocketLiveReader is the reader of the live stream of a path.
+type websocketLiveReader struct {
+	ctxCancel func()
+}
+
+// Close implements defs.Reader.
+func (r *websocketLiveReader) Close() {
+	r.ctxCancel()
+}
+
+// APIReaderDescribe implements defs.Reader.
+func (r *websocketLiveReader) APIReaderDescribe() defs.APIPathSourceOrReader {
+	return defs.APIPathSourceOrReader{
+		Type: "playbackWebSocket",
+		ID:   "",
+	}
+}
+
+// websocketSession streams recordings of a path through a WebSocket connection, in real time.
+// When there are no more recordings, it reads the live stream of the path.
+type websocketSession struct {
+	conn          *websocket.ServerConn
+	pathConf      *conf.Path
+	pathName      string
+	accessRequest defs.PathAccessRequest
+	pathManager   serverPathManager
+	parent        logger.Writer
+
+	mutex      sync.Mutex
+	paused     bool
+	speed      float64
+	live       bool
+	position   time.Time
+	originWall time.Time
+	originNTP  time.Time
+	changed    chan struct{}
+}
+
+func (s *websocketSession) initialize() {
+	s.speed = 1
+	s.changed = make(chan struct{})
+}
+
+// update changes the state of the session and restarts pacing.
+func (s *websocketSession) update(cb func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cb()
+	s.originNTP = time.Time{}
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *websocketSession) writeState() error {
+	s.mutex.Lock()
+	msg := websocketStateMessage{
+		Type:   "state",
+		Paused: s.paused,
+		Speed:  s.speed,
+		Live:   s.live,
+	}
+	if !s.position.IsZero() {
+		position := s.position
+		msg.Position = &position
+	}
+	s.mutex.Unlock()
+
+	return s.conn.WriteJSON(msg)
+}
+
+func (s *websocketSession) writeError(err error) error {
+	return s.conn.WriteJSON(websocketErrorMessage{
+		Type:  "error",
+		Error: err.Error(),
+	})
+}
+
+// wait waits until a sample with the given NTP can be sent.
+func (s *websocketSession) wait(ctx context.Context, ntp time.Time) error {
+	for {
+		s.mutex.Lock()
+		changed := s.changed
+		paused := s.paused
+		var d time.Duration
+
+		if !paused {
+			if s.originNTP.IsZero() {
+				s.originNTP = ntp
+				s.originWall = time.Now()
+			}
+
+			elapsed := time.Duration(float64(ntp.Sub(s.originNTP)) / s.speed)
+			d = time.Until(s.originWall.Add(elapsed - websocketLead))
+		}
+		s.mutex.Unlock()
+
+		if !paused {
+			if d <= 0 {
+				return nil
+			}
+
+			t := time.NewTimer(d)
+
+			select {
+			case <-t.C:
+				return nil
+			case <-changed:
+				t.Stop()
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			}
+		} else {
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// setLive is called when the live edge has been reached.
+func (s *websocketSession) setLive() error {
+	s.mutex.Lock()
+	live := s.live
+	s.mutex.Unlock()
+
+	if live {
+		return nil
+	}
+
+	s.update(func() {
+		s.live = true
+		s.speed = 1
+	})
+
+	return s.writeState()
+}
+
+// runLive sends samples of the live stream until playback is paused or the stream ends.
+func (s *websocketSession) runLive(
+	ctx context.Context,
+	onTracks func([]*fmp4.InitTrack),
+	onSample func(*Sample) error,
+) error {
+	s.mutex.Lock()
+	paused := s.paused
+	changed := s.changed
+	s.mutex.Unlock()
+
+	// wait until playback is resumed, then continue from recordings
+	if paused {
+		select {
+		case <-changed:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	err := s.setLive()
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	changed = s.changed
+	s.mutex.Unlock()
+
+	liveCtx, liveCtxCancel := context.WithCancel(ctx)
+	defer liveCtxCancel()
+
+	lr := &websocketLiveReader{ctxCancel: liveCtxCancel}
+
+	path, strm, err := s.pathManager.AddReader(defs.PathAddReaderReq{
+		Author:        lr,
+		AccessRequest: s.accessRequest,
+	})
+	if err != nil {
+		var terr defs.PathNoStreamAvailableError
+		if !errors.As(err, &terr) {
+			return err
+		}
+
+		t := time.NewTimer(websocketLiveRetryPause)
+		defer t.Stop()
+
+		select {
+		case <-t.C:
+			return nil
+		case <-changed:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	defer path.RemoveReader(defs.PathRemoveReaderReq{Author: lr})
+
+	r := &stream.Reader{Parent: s.parent}
+
+	err = fromStream(strm.Desc, r, onTracks, func(sample *Sample) error {
+		s.mutex.Lock()
+		paused := s.paused
+		s.mutex.Unlock()
+
+		if paused {
+			return errWebSocketPaused
+		}
+
+		return onSample(sample)
+	})
+	if err != nil {
+		return err
+	}
+
+	strm.AddReader(r)
+	defer strm.RemoveReader(r)
+
+	select {
+	case err = <-r.Error():
+		if errors.Is(err, errWebSocketPaused) {
+			return nil
+		}
+		return err
+
+	case <-liveCtx.Done():
+		// the stream has ended
+		if ctx.Err() == nil {
+			return nil
+		}
+		return ctx.Err()
+	}
+}
+
+func (s *websocketSession) runReader(ctx context.Context, start time.Time) error {
+	m := &muxerWebSocket{w: &websocketWriter{c: s.conn}}
+
+	var curTracks []*fmp4.InitTrack
+	var nextTracks []*fmp4.InitTrack
+	lastNTP := make(map[int]time.Time)
+	var latest time.Time
+
+	onTracks := func(tracks []*fmp4.InitTrack) {
+		nextTracks = tracks
+	}
+
+	writeSample := func(sample *Sample) error {
+		if nextTracks != nil {
+			if !initTracksAreEqual(curTracks, nextTracks) {
+				err := s.conn.WriteJSON(websocketInitMessage{
+					Type:   "init",
+					Codecs: tracksCodecs(nextTracks),
+				})
+				if err != nil {
+					return err
+				}
+
+				err = m.setTracks(nextTracks)
+				if err != nil {
+					return err
+				}
+
+				curTracks = nextTracks
+			}
+			nextTracks = nil
+		}
+
+		err := m.writeSample(sample)
+		if err != nil {
+			return err
+		}
+
+		s.mutex.Lock()
+		if sample.NTP.After(s.position) {
+			s.position = sample.NTP
+		}
+		s.mutex.Unlock()
+
+		return nil
+	}
+
+	// samples that have already been sent are skipped, since the recording
+	// that is being written also contains samples sent from the live stream.
+	isNew := func(sample *Sample) bool {
+		if !sample.NTP.After(lastNTP[sample.TrackID]) {
+			return false
+		}
+		lastNTP[sample.TrackID] = sample.NTP
+
+		if sample.NTP.After(latest) {
+			latest = sample.NTP
+		}
+
+		return true
+	}
+
+	sorter := &SampleSorter{
+		Window: 2 * time.Duration(s.pathConf.RecordPartDuration),
+		OnSample: func(sample *Sample) error {
+			err := s.wait(ctx, sample.NTP)
+			if err != nil {
+				return err
+			}
+
+			return writeSample(sample)
+		},
+	}
+
+	r := &Reader{
+		PathConf: s.pathConf,
+		PathName: s.pathName,
+		OnTracks: onTracks,
+		OnSample: func(sample *Sample) error {
+			if !isNew(sample) {
+				return nil
+			}
+
+			return sorter.Push(sample)
+		},
+	}
+
+	from := start
+
+	for {
+		prevLatest := latest
+
+		err := r.Read(ctx, from, websocketReadDuration)
+		if err == nil || errors.Is(err, recordstore.ErrNoSegmentsFound) ||
+			// the last part of the recording that is being written may be incomplete
+			errors.Is(err, io.ErrUnexpectedEOF) {
+			err = sorter.Flush()
+		}
+		if err != nil {
+			return err
+		}
+
+		// reading stopped before the end of the recording
+		if latest.After(prevLatest) {
+			from = latest
+			continue
+		}
+
+		// move to the next recording
+		next, err := r.NextStart(from)
+		if err == nil {
+			err = m.flush()
+			if err != nil {
+				return err
+			}
+
+			s.update(func() {})
+			from = next
+			continue
+		}
+		if !errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			return err
+		}
+
+		// send pending samples and read the live stream
+		err = m.flush()
+		if err != nil {
+			return err
+		}
+
+		// live samples are sent as soon as they are received
+		err = s.runLive(ctx, onTracks, func(sample *Sample) error {
+			if !isNew(sample) {
+				return nil
+			}
+
+			err := writeSample(sample)
+			if err != nil {
+				return err
+			}
+
+			return m.writePart(false)
+		})
+		if err != nil {
+			return err
+		}
+
+		err = m.flush()
+		if err != nil {
+			return err
+		}
+
+		s.update(func() {})
+
+		if latest.After(from) {
+			from = latest
+		}
+	}
+}
+
+type websocketReader struct {
+	ctxCancel func()
+	done      chan error
+}
+
+func (r *websocketReader) close() {
+	r.ctxCancel()
+	<-r.done
+}
+
+func (s *websocketSession) startReader(ctx context.Context, start time.Time) *websocketReader {
+	readerCtx, readerCtxCancel := context.WithCancel(ctx)
+
+	r := &websocketReader{
+		ctxCancel: readerCtxCancel,
+		done:      make(chan error, 1),
+	}
+
+	go func() {
+		r.done <- s.runReader(readerCtx, start)
+	}()
+
+	return r
+}
+
+func (s *websocketSession) run(ctx context.Context, start time.Time) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	commands := make(chan websocketCommand)
+	readErr := make(chan error)
+
+	go func() {
+		for {
+			var cmd websocketCommand
+			err := s.conn.ReadJSON(&cmd)
+			if err != nil {
+				select {
+				case readErr <- err:
+				case <-done:
+				}
+				return
+			}
+
+			select {
+			case commands <- cmd:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	reader := s.startReader(ctx, start)
+
+	defer func() {
+		reader.close()
+	}()
+
+	for {
+		select {
+		case cmd := <-commands:
+			switch cmd.Type {
+			case "seek", "live":
+				pos := time.Now()
+
+				if cmd.Type == "seek" {
+					if cmd.Position == nil {
+						err := s.writeError(errors.New("position is missing"))
+						if err != nil {
+							return err
+						}
+						continue
+					}
+					pos = *cmd.Position
+				}
+
+				reader.close()
+
+				s.update(func() {
+					s.live = false
+					s.position = time.Time{}
+				})
+
+				reader = s.startReader(ctx, pos)
+
+			case "pause":
+				s.update(func() {
+					s.paused = true
+					s.live = false
+				})
+
+			case "play":
+				s.update(func() {
+					s.paused = false
+				})
+
+			case "speed":
+				if cmd.Speed <= 0 {
+					err := s.writeError(errors.New("invalid speed"))
+					if err != nil {
+						return err
+					}
+					continue
+				}
+
+				s.update(func() {
+					s.speed = cmd.Speed
+				})
+
+			default:
+				err := s.writeError(errors.New("invalid command: " + cmd.Type))
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			err := s.writeState()
+			if err != nil {
+				return err
+			}
+
+		case err := <-reader.done:
+			// make the deferred function return immediately
+			reader.done <- err
+			return err
+
+		case <-readErr:
+			// client closed the connection
+			return nil
+		}
+	}
+}
@@ -25,16 +25,17 @@ type pprofParent interface {
 
 // PPROF is a pprof exporter.
 type PPROF struct {
-	Address        string
-	Encryption     bool
-	ServerKey      string
-	ServerCert     string
-	AllowOrigin    string
-	TrustedProxies conf.IPNetworks
-	ReadTimeout    conf.Duration
-	WriteTimeout   conf.Duration
-	AuthManager    pprofAuthManager
-	Parent         pprofParent
+	Address              string
+	Encryption           bool
+	ServerKey            string
+	ServerCert           string
+	AllowOrigin          string
+	TrustedProxies       conf.IPNetworks
+	ProxyProtocolSources conf.IPNetworks
+	ReadTimeout          conf.Duration
+	WriteTimeout         conf.Duration
+	AuthManager          pprofAuthManager
+	Parent               pprofParent
 
 	httpServer *httpp.Server
 }
@@ -50,14 +51,15 @@ func (pp *PPROF) Initialize() error {
 	pprof.Register(router)
 
 	pp.httpServer = &httpp.Server{
-		Address:      pp.Address,
-		ReadTimeout:  time.Duration(pp.ReadTimeout),
-		WriteTimeout: time.Duration(pp.WriteTimeout),
-		Encryption:   pp.Encryption,
-		ServerCert:   pp.ServerCert,
-		ServerKey:    pp.ServerKey,
-		Handler:      router,
-		Parent:       pp,
+		Address:              pp.Address,
+		ReadTimeout:          time.Duration(pp.ReadTimeout),
+		WriteTimeout:         time.Duration(pp.WriteTimeout),
+		Encryption:           pp.Encryption,
+		ServerCert:           pp.ServerCert,
+		ServerKey:            pp.ServerKey,
+		ProxyProtocolSources: pp.ProxyProtocolSources,
+		Handler:              router,
+		Parent:               pp,
 	}
 	err := pp.httpServer.Initialize()
 	if err != nil {
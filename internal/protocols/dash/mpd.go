@@ -0,0 +1,192 @@
+// Package dash contains MPEG-DASH utilities.
+package dash
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ProfileMain is the ISO Base Media File Format main profile.
+	ProfileMain = "urn:mpeg:dash:profile:isoff-main:2011"
+
+	// ProfileLive is the ISO Base Media File Format live profile.
+	ProfileLive = "urn:mpeg:dash:profile:isoff-live:2011"
+
+	// ProfileLowLatency is the low-latency profile.
+	ProfileLowLatency = "http://www.dashif.org/guidelines/low-latency-live-v5"
+
+	// UTCTimingDirect is the scheme of UTC timings that contain the server time.
+	UTCTimingDirect = "urn:mpeg:dash:utc:direct:2014"
+
+	// MPDType is the content type of MPDs.
+	MPDType = "application/dash+xml"
+)
+
+// Duration is a duration encoded in the ISO 8601 format.
+type Duration time.Duration
+
+// MarshalXMLAttr implements xml.MarshalerAttr.
+func (d Duration) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	return xml.Attr{
+		Name:  name,
+		Value: "PT" + strconv.FormatFloat(time.Duration(d).Seconds(), 'f', -1, 64) + "S",
+	}, nil
+}
+
+// UnmarshalXMLAttr implements xml.UnmarshalerAttr.
+func (d *Duration) UnmarshalXMLAttr(attr xml.Attr) error {
+	tmp, err := time.ParseDuration(strings.ToLower(strings.TrimPrefix(attr.Value, "PT")))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(tmp)
+	return nil
+}
+
+// UTCTiming is a UTCTiming element.
+type UTCTiming struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr"`
+}
+
+// Latency is a Latency element.
+type Latency struct {
+	Target int64 `xml:"target,attr"`
+}
+
+// ServiceDescription is a ServiceDescription element.
+type ServiceDescription struct {
+	ID      int      `xml:"id,attr"`
+	Latency *Latency `xml:"Latency"`
+}
+
+// S is an entry of a segment timeline.
+type S struct {
+	T *uint64 `xml:"t,attr,omitempty"`
+	D uint64  `xml:"d,attr"`
+	R int     `xml:"r,attr,omitempty"`
+}
+
+// SegmentTimeline is a SegmentTimeline element.
+type SegmentTimeline struct {
+	S []*S `xml:"S"`
+}
+
+// SegmentTemplate is a SegmentTemplate element.
+type SegmentTemplate struct {
+	Timescale                uint32           `xml:"timescale,attr"`
+	Initialization           string           `xml:"initialization,attr"`
+	Media                    string           `xml:"media,attr"`
+	StartNumber              uint64           `xml:"startNumber,attr"`
+	PresentationTimeOffset   uint64           `xml:"presentationTimeOffset,attr,omitempty"`
+	AvailabilityTimeOffset   float64          `xml:"availabilityTimeOffset,attr,omitempty"`
+	AvailabilityTimeComplete *bool            `xml:"availabilityTimeComplete,attr,omitempty"`
+	SegmentTimeline          *SegmentTimeline `xml:"SegmentTimeline"`
+}
+
+// URL is an element that contains the URL of the initialization section.
+type URL struct {
+	SourceURL string `xml:"sourceURL,attr"`
+}
+
+// SegmentURL is a SegmentURL element.
+type SegmentURL struct {
+	Media string `xml:"media,attr"`
+}
+
+// SegmentList is a SegmentList element.
+type SegmentList struct {
+	Timescale              uint32           `xml:"timescale,attr"`
+	PresentationTimeOffset uint64           `xml:"presentationTimeOffset,attr,omitempty"`
+	Initialization         *URL             `xml:"Initialization"`
+	SegmentTimeline        *SegmentTimeline `xml:"SegmentTimeline"`
+	SegmentURLs            []*SegmentURL    `xml:"SegmentURL"`
+}
+
+// Representation is a Representation element.
+type Representation struct {
+	ID                string           `xml:"id,attr"`
+	Codecs            string           `xml:"codecs,attr"`
+	Bandwidth         int              `xml:"bandwidth,attr"`
+	Width             int              `xml:"width,attr,omitempty"`
+	Height            int              `xml:"height,attr,omitempty"`
+	AudioSamplingRate int              `xml:"audioSamplingRate,attr,omitempty"`
+	SegmentTemplate   *SegmentTemplate `xml:"SegmentTemplate"`
+	SegmentList       *SegmentList     `xml:"SegmentList"`
+}
+
+// AdaptationSet is an AdaptationSet element.
+type AdaptationSet struct {
+	ID               int               `xml:"id,attr"`
+	ContentType      string            `xml:"contentType,attr"`
+	MimeType         string            `xml:"mimeType,attr"`
+	SegmentAlignment bool              `xml:"segmentAlignment,attr"`
+	StartWithSAP     int               `xml:"startWithSAP,attr"`
+	Representations  []*Representation `xml:"Representation"`
+}
+
+// Period is a Period element.
+type Period struct {
+	ID             string           `xml:"id,attr"`
+	Start          Duration         `xml:"start,attr"`
+	Duration       *Duration        `xml:"duration,attr,omitempty"`
+	AdaptationSets []*AdaptationSet `xml:"AdaptationSet"`
+}
+
+// MPD is a Media Presentation Description.
+type MPD struct {
+	XMLName                    xml.Name            `xml:"urn:mpeg:dash:schema:mpd:2011 MPD"`
+	Profiles                   string              `xml:"profiles,attr"`
+	Type                       string              `xml:"type,attr"`
+	AvailabilityStartTime      *time.Time          `xml:"availabilityStartTime,attr,omitempty"`
+	PublishTime                *time.Time          `xml:"publishTime,attr,omitempty"`
+	MediaPresentationDuration  *Duration           `xml:"mediaPresentationDuration,attr,omitempty"`
+	MinimumUpdatePeriod        *Duration           `xml:"minimumUpdatePeriod,attr,omitempty"`
+	MinBufferTime              Duration            `xml:"minBufferTime,attr"`
+	TimeShiftBufferDepth       *Duration           `xml:"timeShiftBufferDepth,attr,omitempty"`
+	SuggestedPresentationDelay *Duration           `xml:"suggestedPresentationDelay,attr,omitempty"`
+	MaxSegmentDuration         *Duration           `xml:"maxSegmentDuration,attr,omitempty"`
+	ServiceDescription         *ServiceDescription `xml:"ServiceDescription"`
+	Periods                    []*Period           `xml:"Period"`
+	UTCTiming                  *UTCTiming          `xml:"UTCTiming"`
+}
+
+// Marshal encodes the MPD.
+func (m *MPD) Marshal() ([]byte, error) {
+	byts, err := xml.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), append(byts, '\n')...), nil
+}
+
+// Unmarshal decodes a MPD.
+func (m *MPD) Unmarshal(buf []byte) error {
+	return xml.Unmarshal(buf, m)
+}
+
+// NewTimeline builds a segment timeline from the start time and durations of consecutive segments.
+// Consecutive segments with the same duration are merged into a single entry.
+func NewTimeline(start uint64, durations []uint64) *SegmentTimeline {
+	tl := &SegmentTimeline{}
+
+	for i, d := range durations {
+		if i != 0 && tl.S[len(tl.S)-1].D == d {
+			tl.S[len(tl.S)-1].R++
+			continue
+		}
+
+		s := &S{D: d}
+		if i == 0 {
+			s.T = &start
+		}
+		tl.S = append(tl.S, s)
+	}
+
+	return tl
+}
@@ -0,0 +1,84 @@
+package dash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTimeline(t *testing.T) {
+	start := uint64(1000)
+
+	require.Equal(t, &SegmentTimeline{
+		S: []*S{
+			{T: &start, D: 90, R: 2},
+			{D: 45},
+			{D: 90},
+		},
+	}, NewTimeline(1000, []uint64{90, 90, 90, 45, 90}))
+}
+
+func TestMPDMarshal(t *testing.T) {
+	ast := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	start := uint64(0)
+	mup := Duration(2 * time.Second)
+
+	m := &MPD{
+		Profiles:              ProfileLive,
+		Type:                  "dynamic",
+		AvailabilityStartTime: &ast,
+		MinimumUpdatePeriod:   &mup,
+		MinBufferTime:         Duration(1500 * time.Millisecond),
+		Periods: []*Period{{
+			ID: "0",
+			AdaptationSets: []*AdaptationSet{{
+				ID:               0,
+				ContentType:      "video",
+				MimeType:         "video/mp4",
+				SegmentAlignment: true,
+				StartWithSAP:     1,
+				Representations: []*Representation{{
+					ID:        "video1",
+					Codecs:    "avc1.42c028",
+					Bandwidth: 1000,
+					SegmentTemplate: &SegmentTemplate{
+						Timescale:      90000,
+						Initialization: "init.mp4",
+						Media:          "seg$Number$.mp4",
+						StartNumber:    3,
+						SegmentTimeline: &SegmentTimeline{
+							S: []*S{{T: &start, D: 180000, R: 1}},
+						},
+					},
+				}},
+			}},
+		}},
+	}
+
+	byts, err := m.Marshal()
+	require.NoError(t, err)
+
+	require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-live:2011" `+
+		`type="dynamic" availabilityStartTime="2010-01-01T00:00:00Z" minimumUpdatePeriod="PT2S" minBufferTime="PT1.5S">
+  <Period id="0" start="PT0S">
+    <AdaptationSet id="0" contentType="video" mimeType="video/mp4" segmentAlignment="true" startWithSAP="1">
+      <Representation id="video1" codecs="avc1.42c028" bandwidth="1000">
+        <SegmentTemplate timescale="90000" initialization="init.mp4" media="seg$Number$.mp4" startNumber="3">
+          <SegmentTimeline>
+            <S t="0" d="180000" r="1"></S>
+          </SegmentTimeline>
+        </SegmentTemplate>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`, string(byts))
+
+	var dec MPD
+	err = dec.Unmarshal(byts)
+	require.NoError(t, err)
+	require.Equal(t, m.Periods, dec.Periods)
+	require.Equal(t, *m.MinimumUpdatePeriod, *dec.MinimumUpdatePeriod)
+}
@@ -0,0 +1,114 @@
+package dash
+
+import (
+	"github.com/bluenviron/gohlslib/v2/pkg/codecparams"
+	"github.com/bluenviron/gohlslib/v2/pkg/codecs"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/av1"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+)
+
+// CodecString returns the RFC6381 codec string of a MP4 codec.
+func CodecString(codec mp4.Codec) string {
+	switch codec.(type) {
+	case *mp4.CodecMPEG4Video:
+		return "mp4v.20"
+
+	case *mp4.CodecMPEG1Video:
+		return "mp4v.61"
+
+	case *mp4.CodecMJPEG:
+		return "mp4v.6c"
+
+	case *mp4.CodecMPEG1Audio:
+		return "mp4a.6b"
+
+	case *mp4.CodecAC3:
+		return "ac-3"
+
+	case *mp4.CodecLPCM:
+		return "ipcm"
+	}
+
+	return codecparams.Marshal(codecs.FromFMP4(codec))
+}
+
+func videoSize(codec mp4.Codec) (int, int) {
+	switch codec := codec.(type) {
+	case *mp4.CodecAV1:
+		var sh av1.SequenceHeader
+		if sh.Unmarshal(codec.SequenceHeader) == nil {
+			return sh.Width(), sh.Height()
+		}
+
+	case *mp4.CodecVP9:
+		return codec.Width, codec.Height
+
+	case *mp4.CodecH265:
+		var sps h265.SPS
+		if sps.Unmarshal(codec.SPS) == nil {
+			return sps.Width(), sps.Height()
+		}
+
+	case *mp4.CodecH264:
+		var sps h264.SPS
+		if sps.Unmarshal(codec.SPS) == nil {
+			return sps.Width(), sps.Height()
+		}
+
+	case *mp4.CodecMJPEG:
+		return codec.Width, codec.Height
+	}
+
+	return 0, 0
+}
+
+func sampleRate(codec mp4.Codec) int {
+	switch codec := codec.(type) {
+	case *mp4.CodecOpus:
+		return 48000
+
+	case *mp4.CodecMPEG4Audio:
+		return codec.Config.SampleRate
+
+	case *mp4.CodecMPEG1Audio:
+		return codec.SampleRate
+
+	case *mp4.CodecAC3:
+		return codec.SampleRate
+
+	case *mp4.CodecLPCM:
+		return codec.SampleRate
+	}
+
+	return 0
+}
+
+// NewAdaptationSet returns an adaptation set that contains a single representation of a codec.
+func NewAdaptationSet(id int, representationID string, codec mp4.Codec, bandwidth int) *AdaptationSet {
+	r := &Representation{
+		ID:        representationID,
+		Codecs:    CodecString(codec),
+		Bandwidth: bandwidth,
+	}
+
+	as := &AdaptationSet{
+		ID:               id,
+		SegmentAlignment: true,
+		StartWithSAP:     1,
+		Representations:  []*Representation{r},
+	}
+
+	if codec.IsVideo() {
+		as.ContentType = "video"
+		as.MimeType = "video/mp4"
+		r.Width, r.Height = videoSize(codec)
+	} else {
+		as.ContentType = "audio"
+		as.MimeType = "audio/mp4"
+		r.AudioSamplingRate = sampleRate(codec)
+	}
+
+	return as
+}
@@ -0,0 +1,890 @@
+// Package fmp4 contains fMP4 utilities.
+package fmp4
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
+	"github.com/bluenviron/gortsplib/v5/pkg/format"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/ac3"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/av1"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/g711"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/jpeg"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg1audio"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4video"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/opus"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/vp9"
+	mcfmp4 "github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+
+	"github.com/bluenviron/mediamtx/internal/codecprocessor"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+func timestampToDuration(t int64, clockRate int) time.Duration {
+	return time.Duration(t) * time.Second / time.Duration(clockRate)
+}
+
+// MPEG1AudioChannelCount returns the channel count of a MPEG-1 Audio channel mode.
+func MPEG1AudioChannelCount(cm mpeg1audio.ChannelMode) int {
+	switch cm {
+	case mpeg1audio.ChannelModeStereo,
+		mpeg1audio.ChannelModeJointStereo,
+		mpeg1audio.ChannelModeDualChannel:
+		return 2
+
+	default:
+		return 1
+	}
+}
+
+// JPEGExtractSize returns the width and height of a JPEG image.
+func JPEGExtractSize(image []byte) (int, int, error) {
+	l := len(image)
+	if l < 2 || image[0] != 0xFF || image[1] != jpeg.MarkerStartOfImage {
+		return 0, 0, fmt.Errorf("invalid header")
+	}
+
+	image = image[2:]
+
+	for {
+		if len(image) < 2 {
+			return 0, 0, fmt.Errorf("not enough bits")
+		}
+
+		h0, h1 := image[0], image[1]
+		image = image[2:]
+
+		if h0 != 0xFF {
+			return 0, 0, fmt.Errorf("invalid image")
+		}
+
+		switch h1 {
+		case 0xE0, 0xE1, 0xE2, // JFIF
+			jpeg.MarkerDefineHuffmanTable,
+			jpeg.MarkerComment,
+			jpeg.MarkerDefineQuantizationTable,
+			jpeg.MarkerDefineRestartInterval:
+			mlen := int(image[0])<<8 | int(image[1])
+			if len(image) < mlen {
+				return 0, 0, fmt.Errorf("not enough bits")
+			}
+			image = image[mlen:]
+
+		case jpeg.MarkerStartOfFrame1:
+			mlen := int(image[0])<<8 | int(image[1])
+			if len(image) < mlen {
+				return 0, 0, fmt.Errorf("not enough bits")
+			}
+
+			var sof jpeg.StartOfFrame1
+			err := sof.Unmarshal(image[2:mlen])
+			if err != nil {
+				return 0, 0, err
+			}
+
+			return sof.Width, sof.Height, nil
+
+		case jpeg.MarkerStartOfScan:
+			return 0, 0, fmt.Errorf("SOF not found")
+
+		default:
+			return 0, 0, fmt.Errorf("unknown marker: 0x%.2x", h1)
+		}
+	}
+}
+
+// Sample is a fMP4 sample of a stream.
+type Sample struct {
+	*mcfmp4.Sample
+
+	// decode timestamp.
+	DTS int64
+
+	// absolute time of the presentation timestamp.
+	NTP time.Time
+}
+
+// Track receives codec parameters and samples of a stream track.
+type Track interface {
+	// UpdateCodec is called when codec parameters change.
+	// Codecs are never modified in place, a new codec is provided instead.
+	UpdateCodec(codec mp4.Codec)
+
+	// WriteSample is called when a sample is available.
+	WriteSample(sample *Sample) error
+}
+
+// FromStream maps a MediaMTX stream to fMP4 tracks.
+// addTrack is called for every supported format, with the format number
+// (starting from 1) and the initial codec; it returns the Track that
+// receives codec parameters and samples, or nil to skip the format.
+// onData is used to register callbacks of formats.
+func FromStream(
+	desc *description.Session,
+	onData func(*description.Media, format.Format, stream.OnDataFunc),
+	addTrack func(n int, media *description.Media, forma format.Format, codec mp4.Codec) Track,
+) {
+	n := 0
+
+	for _, media := range desc.Medias {
+		for _, forma := range media.Formats {
+			n++
+			clockRate := forma.ClockRate()
+
+			switch forma := forma.(type) {
+			case *format.AV1:
+				codec := &mp4.CodecAV1{
+					SequenceHeader: codecprocessor.AV1DefaultSequenceHeader,
+				}
+				track := addTrack(n, media, forma, codec)
+				if track == nil {
+					continue
+				}
+
+				firstReceived := false
+
+				onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						randomAccess := false
+
+						for _, obu := range u.Payload.(unit.PayloadAV1) {
+							typ := av1.OBUType((obu[0] >> 3) & 0b1111)
+
+							if typ == av1.OBUTypeSequenceHeader {
+								if !bytes.Equal(codec.SequenceHeader, obu) {
+									codec = &mp4.CodecAV1{
+										SequenceHeader: obu,
+									}
+									track.UpdateCodec(codec)
+								}
+								randomAccess = true
+							}
+						}
+
+						if !firstReceived {
+							if !randomAccess {
+								return nil
+							}
+							firstReceived = true
+						}
+
+						var sampl mcfmp4.Sample
+						err := sampl.FillAV1(u.Payload.(unit.PayloadAV1))
+						if err != nil {
+							return err
+						}
+
+						return track.WriteSample(&Sample{
+							Sample: &sampl,
+							DTS:    u.PTS,
+							NTP:    u.NTP,
+						})
+					})
+
+			case *format.VP9:
+				codec := &mp4.CodecVP9{
+					Width:             1280,
+					Height:            720,
+					Profile:           1,
+					BitDepth:          8,
+					ChromaSubsampling: 1,
+					ColorRange:        false,
+				}
+				track := addTrack(n, media, forma, codec)
+				if track == nil {
+					continue
+				}
+
+				firstReceived := false
+
+				onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						var h vp9.Header
+						err := h.Unmarshal(u.Payload.(unit.PayloadVP9))
+						if err != nil {
+							return err
+						}
+
+						randomAccess := !h.NonKeyFrame
+
+						if randomAccess {
+							newCodec := &mp4.CodecVP9{
+								Width:             h.Width(),
+								Height:            h.Height(),
+								Profile:           h.Profile,
+								BitDepth:          h.ColorConfig.BitDepth,
+								ChromaSubsampling: h.ChromaSubsampling(),
+								ColorRange:        h.ColorConfig.ColorRange,
+							}
+							if *newCodec != *codec {
+								codec = newCodec
+								track.UpdateCodec(codec)
+							}
+						}
+
+						if !firstReceived {
+							if !randomAccess {
+								return nil
+							}
+							firstReceived = true
+						}
+
+						return track.WriteSample(&Sample{
+							Sample: &mcfmp4.Sample{
+								IsNonSyncSample: !randomAccess,
+								Payload:         u.Payload.(unit.PayloadVP9),
+							},
+							DTS: u.PTS,
+							NTP: u.NTP,
+						})
+					})
+
+			case *format.VP8:
+				// TODO
+
+			case *format.H265:
+				vps, sps, pps := forma.SafeParams()
+				if vps == nil || sps == nil || pps == nil {
+					vps = codecprocessor.H265DefaultVPS
+					sps = codecprocessor.H265DefaultSPS
+					pps = codecprocessor.H265DefaultPPS
+				}
+
+				codec := &mp4.CodecH265{
+					VPS: vps,
+					SPS: sps,
+					PPS: pps,
+				}
+				track := addTrack(n, media, forma, codec)
+				if track == nil {
+					continue
+				}
+
+				var dtsExtractor *h265.DTSExtractor
+
+				onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						randomAccess := false
+						newCodec := *codec
+
+						for _, nalu := range u.Payload.(unit.PayloadH265) {
+							typ := h265.NALUType((nalu[0] >> 1) & 0b111111)
+
+							switch typ {
+							case h265.NALUType_VPS_NUT:
+								newCodec.VPS = nalu
+
+							case h265.NALUType_SPS_NUT:
+								newCodec.SPS = nalu
+
+							case h265.NALUType_PPS_NUT:
+								newCodec.PPS = nalu
+
+							case h265.NALUType_IDR_W_RADL, h265.NALUType_IDR_N_LP, h265.NALUType_CRA_NUT:
+								randomAccess = true
+							}
+						}
+
+						if !bytes.Equal(newCodec.VPS, codec.VPS) ||
+							!bytes.Equal(newCodec.SPS, codec.SPS) ||
+							!bytes.Equal(newCodec.PPS, codec.PPS) {
+							codec = &newCodec
+							track.UpdateCodec(codec)
+						}
+
+						if dtsExtractor == nil {
+							if !randomAccess {
+								return nil
+							}
+							dtsExtractor = &h265.DTSExtractor{}
+							dtsExtractor.Initialize()
+						}
+
+						dts, err := dtsExtractor.Extract(u.Payload.(unit.PayloadH265), u.PTS)
+						if err != nil {
+							return err
+						}
+
+						var sampl mcfmp4.Sample
+						err = sampl.FillH265(int32(u.PTS-dts), u.Payload.(unit.PayloadH265))
+						if err != nil {
+							return err
+						}
+
+						return track.WriteSample(&Sample{
+							Sample: &sampl,
+							DTS:    dts,
+							NTP:    u.NTP,
+						})
+					})
+
+			case *format.H264:
+				sps, pps := forma.SafeParams()
+				if sps == nil || pps == nil {
+					sps = codecprocessor.H264DefaultSPS
+					pps = codecprocessor.H264DefaultPPS
+				}
+
+				codec := &mp4.CodecH264{
+					SPS: sps,
+					PPS: pps,
+				}
+				track := addTrack(n, media, forma, codec)
+				if track == nil {
+					continue
+				}
+
+				var dtsExtractor *h264.DTSExtractor
+
+				onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						randomAccess := false
+						newCodec := *codec
+
+						for _, nalu := range u.Payload.(unit.PayloadH264) {
+							typ := h264.NALUType(nalu[0] & 0x1F)
+
+							switch typ {
+							case h264.NALUTypeSPS:
+								newCodec.SPS = nalu
+
+							case h264.NALUTypePPS:
+								newCodec.PPS = nalu
+
+							case h264.NALUTypeIDR:
+								randomAccess = true
+							}
+						}
+
+						if !bytes.Equal(newCodec.SPS, codec.SPS) ||
+							!bytes.Equal(newCodec.PPS, codec.PPS) {
+							codec = &newCodec
+							track.UpdateCodec(codec)
+						}
+
+						if dtsExtractor == nil {
+							if !randomAccess {
+								return nil
+							}
+							dtsExtractor = &h264.DTSExtractor{}
+							dtsExtractor.Initialize()
+						}
+
+						dts, err := dtsExtractor.Extract(u.Payload.(unit.PayloadH264), u.PTS)
+						if err != nil {
+							return err
+						}
+
+						var sampl mcfmp4.Sample
+						err = sampl.FillH264(int32(u.PTS-dts), u.Payload.(unit.PayloadH264))
+						if err != nil {
+							return err
+						}
+
+						return track.WriteSample(&Sample{
+							Sample: &sampl,
+							DTS:    dts,
+							NTP:    u.NTP,
+						})
+					})
+
+			case *format.MPEG4Video:
+				config := forma.SafeParams()
+
+				if config == nil {
+					config = codecprocessor.MPEG4VideoDefaultConfig
+				}
+
+				codec := &mp4.CodecMPEG4Video{
+					Config: config,
+				}
+				track := addTrack(n, media, forma, codec)
+				if track == nil {
+					continue
+				}
+
+				firstReceived := false
+				var lastPTS int64
+
+				onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						randomAccess := bytes.Contains(u.Payload.(unit.PayloadMPEG4Video),
+							[]byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)})
+
+						if bytes.HasPrefix(u.Payload.(unit.PayloadMPEG4Video),
+							[]byte{0, 0, 1, byte(mpeg4video.VisualObjectSequenceStartCode)}) {
+							end := bytes.Index(u.Payload.(unit.PayloadMPEG4Video)[4:],
+								[]byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)})
+							if end >= 0 {
+								config2 := u.Payload.(unit.PayloadMPEG4Video)[:end+4]
+
+								if !bytes.Equal(codec.Config, config2) {
+									codec = &mp4.CodecMPEG4Video{
+										Config: config2,
+									}
+									track.UpdateCodec(codec)
+								}
+							}
+						}
+
+						if !firstReceived {
+							if !randomAccess {
+								return nil
+							}
+							firstReceived = true
+						} else if u.PTS < lastPTS {
+							return fmt.Errorf("MPEG-4 Video streams with B-frames are not supported (yet)")
+						}
+						lastPTS = u.PTS
+
+						return track.WriteSample(&Sample{
+							Sample: &mcfmp4.Sample{
+								Payload:         u.Payload.(unit.PayloadMPEG4Video),
+								IsNonSyncSample: !randomAccess,
+							},
+							DTS: u.PTS,
+							NTP: u.NTP,
+						})
+					})
+
+			case *format.MPEG1Video:
+				codec := &mp4.CodecMPEG1Video{
+					Config: codecprocessor.MPEG1VideoDefaultConfig,
+				}
+				track := addTrack(n, media, forma, codec)
+				if track == nil {
+					continue
+				}
+
+				firstReceived := false
+				var lastPTS int64
+
+				onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						randomAccess := bytes.Contains(u.Payload.(unit.PayloadMPEG1Video), []byte{0, 0, 1, 0xB8})
+
+						if bytes.HasPrefix(u.Payload.(unit.PayloadMPEG1Video), []byte{0, 0, 1, 0xB3}) {
+							end := bytes.Index(u.Payload.(unit.PayloadMPEG1Video)[4:], []byte{0, 0, 1, 0xB8})
+							if end >= 0 {
+								config := u.Payload.(unit.PayloadMPEG1Video)[:end+4]
+
+								if !bytes.Equal(codec.Config, config) {
+									codec = &mp4.CodecMPEG1Video{
+										Config: config,
+									}
+									track.UpdateCodec(codec)
+								}
+							}
+						}
+
+						if !firstReceived {
+							if !randomAccess {
+								return nil
+							}
+							firstReceived = true
+						} else if u.PTS < lastPTS {
+							return fmt.Errorf("MPEG-1 Video streams with B-frames are not supported (yet)")
+						}
+						lastPTS = u.PTS
+
+						return track.WriteSample(&Sample{
+							Sample: &mcfmp4.Sample{
+								Payload:         u.Payload.(unit.PayloadMPEG1Video),
+								IsNonSyncSample: !randomAccess,
+							},
+							DTS: u.PTS,
+							NTP: u.NTP,
+						})
+					})
+
+			case *format.MJPEG:
+				codec := &mp4.CodecMJPEG{
+					Width:  800,
+					Height: 600,
+				}
+				track := addTrack(n, media, forma, codec)
+				if track == nil {
+					continue
+				}
+
+				parsed := false
+
+				onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						if !parsed {
+							parsed = true
+							width, height, err := JPEGExtractSize(u.Payload.(unit.PayloadMJPEG))
+							if err != nil {
+								return err
+							}
+							track.UpdateCodec(&mp4.CodecMJPEG{
+								Width:  width,
+								Height: height,
+							})
+						}
+
+						return track.WriteSample(&Sample{
+							Sample: &mcfmp4.Sample{
+								Payload: u.Payload.(unit.PayloadMJPEG),
+							},
+							DTS: u.PTS,
+							NTP: u.NTP,
+						})
+					})
+
+			case *format.Opus:
+				codec := &mp4.CodecOpus{
+					ChannelCount: forma.ChannelCount,
+				}
+				track := addTrack(n, media, forma, codec)
+				if track == nil {
+					continue
+				}
+
+				onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						pts := u.PTS
+
+						for _, packet := range u.Payload.(unit.PayloadOpus) {
+							err := track.WriteSample(&Sample{
+								Sample: &mcfmp4.Sample{
+									Payload: packet,
+								},
+								DTS: pts,
+								NTP: u.NTP.Add(timestampToDuration(pts-u.PTS, clockRate)),
+							})
+							if err != nil {
+								return err
+							}
+
+							pts += opus.PacketDuration2(packet)
+						}
+
+						return nil
+					})
+
+			case *format.MPEG4Audio:
+				codec := &mp4.CodecMPEG4Audio{
+					Config: *forma.Config,
+				}
+				track := addTrack(n, media, forma, codec)
+				if track == nil {
+					continue
+				}
+
+				onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						for i, au := range u.Payload.(unit.PayloadMPEG4Audio) {
+							pts := u.PTS + int64(i)*mpeg4audio.SamplesPerAccessUnit
+
+							err := track.WriteSample(&Sample{
+								Sample: &mcfmp4.Sample{
+									Payload: au,
+								},
+								DTS: pts,
+								NTP: u.NTP.Add(timestampToDuration(pts-u.PTS, clockRate)),
+							})
+							if err != nil {
+								return err
+							}
+						}
+
+						return nil
+					})
+
+			case *format.MPEG4AudioLATM:
+				if !forma.CPresent {
+					codec := &mp4.CodecMPEG4Audio{
+						Config: *forma.StreamMuxConfig.Programs[0].Layers[0].AudioSpecificConfig,
+					}
+					track := addTrack(n, media, forma, codec)
+					if track == nil {
+						continue
+					}
+
+					onData(
+						media,
+						forma,
+						func(u *unit.Unit) error {
+							if u.NilPayload() {
+								return nil
+							}
+
+							var ame mpeg4audio.AudioMuxElement
+							ame.StreamMuxConfig = forma.StreamMuxConfig
+							err := ame.Unmarshal(u.Payload.(unit.PayloadMPEG4AudioLATM))
+							if err != nil {
+								return err
+							}
+
+							return track.WriteSample(&Sample{
+								Sample: &mcfmp4.Sample{
+									Payload: ame.Payloads[0][0][0],
+								},
+								DTS: u.PTS,
+								NTP: u.NTP,
+							})
+						})
+				}
+
+			case *format.MPEG1Audio:
+				codec := &mp4.CodecMPEG1Audio{
+					SampleRate:   32000,
+					ChannelCount: 2,
+				}
+				track := addTrack(n, media, forma, codec)
+				if track == nil {
+					continue
+				}
+
+				parsed := false
+
+				onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						var dt time.Duration
+
+						for _, frame := range u.Payload.(unit.PayloadMPEG1Audio) {
+							var h mpeg1audio.FrameHeader
+							err := h.Unmarshal(frame)
+							if err != nil {
+								return err
+							}
+
+							if !parsed {
+								parsed = true
+								track.UpdateCodec(&mp4.CodecMPEG1Audio{
+									SampleRate:   h.SampleRate,
+									ChannelCount: MPEG1AudioChannelCount(h.ChannelMode),
+								})
+							}
+
+							err = track.WriteSample(&Sample{
+								Sample: &mcfmp4.Sample{
+									Payload: frame,
+								},
+								DTS: u.PTS + u.PTS,
+								NTP: u.NTP,
+							})
+							if err != nil {
+								return err
+							}
+
+							dt += time.Duration(h.SampleCount()) *
+								time.Second / time.Duration(h.SampleRate)
+						}
+
+						return nil
+					})
+
+			case *format.AC3:
+				codec := &mp4.CodecAC3{
+					SampleRate:   forma.SampleRate,
+					ChannelCount: forma.ChannelCount,
+					Fscod:        0,
+					Bsid:         8,
+					Bsmod:        0,
+					Acmod:        7,
+					LfeOn:        true,
+					BitRateCode:  7,
+				}
+				track := addTrack(n, media, forma, codec)
+				if track == nil {
+					continue
+				}
+
+				parsed := false
+
+				onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						for i, frame := range u.Payload.(unit.PayloadAC3) {
+							var syncInfo ac3.SyncInfo
+							err := syncInfo.Unmarshal(frame)
+							if err != nil {
+								return fmt.Errorf("invalid AC-3 frame: %w", err)
+							}
+
+							var bsi ac3.BSI
+							err = bsi.Unmarshal(frame[5:])
+							if err != nil {
+								return fmt.Errorf("invalid AC-3 frame: %w", err)
+							}
+
+							if !parsed {
+								parsed = true
+								track.UpdateCodec(&mp4.CodecAC3{
+									SampleRate:   syncInfo.SampleRate(),
+									ChannelCount: bsi.ChannelCount(),
+									Fscod:        syncInfo.Fscod,
+									Bsid:         bsi.Bsid,
+									Bsmod:        bsi.Bsmod,
+									Acmod:        bsi.Acmod,
+									LfeOn:        bsi.LfeOn,
+									BitRateCode:  syncInfo.Frmsizecod >> 1,
+								})
+							}
+
+							pts := u.PTS + int64(i)*ac3.SamplesPerFrame
+
+							err = track.WriteSample(&Sample{
+								Sample: &mcfmp4.Sample{
+									Payload: frame,
+								},
+								DTS: pts,
+								NTP: u.NTP.Add(timestampToDuration(pts-u.PTS, clockRate)),
+							})
+							if err != nil {
+								return err
+							}
+						}
+
+						return nil
+					})
+
+			case *format.G722:
+				// TODO
+
+			case *format.G711:
+				codec := &mp4.CodecLPCM{
+					LittleEndian: false,
+					BitDepth:     16,
+					SampleRate:   forma.SampleRate,
+					ChannelCount: forma.ChannelCount,
+				}
+				track := addTrack(n, media, forma, codec)
+				if track == nil {
+					continue
+				}
+
+				onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						var lpcm []byte
+						if forma.MULaw {
+							var mu g711.Mulaw
+							mu.Unmarshal(u.Payload.(unit.PayloadG711))
+							lpcm = mu
+						} else {
+							var al g711.Alaw
+							al.Unmarshal(u.Payload.(unit.PayloadG711))
+							lpcm = al
+						}
+
+						return track.WriteSample(&Sample{
+							Sample: &mcfmp4.Sample{
+								Payload: lpcm,
+							},
+							DTS: u.PTS,
+							NTP: u.NTP,
+						})
+					})
+
+			case *format.LPCM:
+				codec := &mp4.CodecLPCM{
+					LittleEndian: false,
+					BitDepth:     forma.BitDepth,
+					SampleRate:   forma.SampleRate,
+					ChannelCount: forma.ChannelCount,
+				}
+				track := addTrack(n, media, forma, codec)
+				if track == nil {
+					continue
+				}
+
+				onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						return track.WriteSample(&Sample{
+							Sample: &mcfmp4.Sample{
+								Payload: u.Payload.(unit.PayloadLPCM),
+							},
+							DTS: u.PTS,
+							NTP: u.NTP,
+						})
+					})
+			}
+		}
+	}
+}
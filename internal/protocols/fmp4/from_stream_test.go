@@ -0,0 +1,94 @@
+package fmp4
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
+	"github.com/bluenviron/gortsplib/v5/pkg/format"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+	"github.com/bluenviron/mediamtx/internal/codecprocessor"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/unit"
+	"github.com/stretchr/testify/require"
+)
+
+type testTrack struct {
+	codecs  []mp4.Codec
+	samples []*Sample
+}
+
+func (t *testTrack) UpdateCodec(codec mp4.Codec) {
+	t.codecs = append(t.codecs, codec)
+}
+
+func (t *testTrack) WriteSample(sample *Sample) error {
+	t.samples = append(t.samples, sample)
+	return nil
+}
+
+func TestFromStream(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type:    description.MediaTypeVideo,
+			Formats: []format.Format{&format.VP8{}},
+		},
+		{
+			Type: description.MediaTypeVideo,
+			Formats: []format.Format{&format.H264{
+				PayloadTyp:        96,
+				PacketizationMode: 1,
+			}},
+		},
+	}}
+
+	onDatas := make(map[format.Format]stream.OnDataFunc)
+	var initialCodec mp4.Codec
+	track := &testTrack{}
+
+	FromStream(
+		desc,
+		func(_ *description.Media, forma format.Format, cb stream.OnDataFunc) {
+			onDatas[forma] = cb
+		},
+		func(n int, _ *description.Media, forma format.Format, codec mp4.Codec) Track {
+			require.Equal(t, 2, n)
+			require.Equal(t, desc.Medias[1].Formats[0], forma)
+			initialCodec = codec
+			return track
+		})
+
+	require.Len(t, onDatas, 1)
+	require.Equal(t, &mp4.CodecH264{
+		SPS: codecprocessor.H264DefaultSPS,
+		PPS: codecprocessor.H264DefaultPPS,
+	}, initialCodec)
+
+	pps := []byte{0x08, 0x06, 0x07, 0x09}
+
+	ntp := time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC)
+
+	err := onDatas[desc.Medias[1].Formats[0]](&unit.Unit{
+		PTS: 90000,
+		NTP: ntp,
+		Payload: unit.PayloadH264{
+			codecprocessor.H264DefaultSPS,
+			pps,
+			{5, 1},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []mp4.Codec{&mp4.CodecH264{
+		SPS: codecprocessor.H264DefaultSPS,
+		PPS: pps,
+	}}, track.codecs)
+
+	// codecs are replaced, not modified in place.
+	require.Equal(t, codecprocessor.H264DefaultPPS, initialCodec.(*mp4.CodecH264).PPS)
+
+	require.Len(t, track.samples, 1)
+	require.Equal(t, int64(90000), track.samples[0].DTS)
+	require.Equal(t, ntp, track.samples[0].NTP)
+	require.False(t, track.samples[0].IsNonSyncSample)
+}
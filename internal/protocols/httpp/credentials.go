@@ -5,12 +5,17 @@ import (
 	"strings"
 
 	"github.com/bluenviron/mediamtx/internal/auth"
+	"github.com/bluenviron/mediamtx/internal/protocols/tls"
 )
 
 // Credentials extracts credentials from a HTTP request.
 func Credentials(h *http.Request) *auth.Credentials {
 	c := &auth.Credentials{}
 
+	if h.TLS != nil {
+		c.ClientCertIdentities = tls.ClientIdentities(*h.TLS)
+	}
+
 	for _, auth := range h.Header["Authorization"] {
 		if strings.HasPrefix(auth, "Bearer ") {
 			// user:pass in Authorization Bearer
@@ -1,6 +1,8 @@
 package httpp
 
 import (
+	"bufio"
+	"net"
 	"net/http"
 	"time"
 )
@@ -25,6 +27,16 @@ func (w *writeTimeoutWriter) WriteHeader(statusCode int) {
 	w.w.WriteHeader(statusCode)
 }
 
+// Hijack implements http.Hijacker.
+func (w *writeTimeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.rc.Hijack()
+}
+
+// Flush implements http.Flusher.
+func (w *writeTimeoutWriter) Flush() {
+	w.rc.Flush() //nolint:errcheck
+}
+
 // apply write deadline before every Write() call.
 // this allows to write long responses, splitted in chunks,
 // without causing timeouts.
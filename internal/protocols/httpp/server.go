@@ -14,6 +14,8 @@ import (
 
 	"github.com/bluenviron/mediamtx/internal/certloader"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/protocols/proxyprotocol"
+	mtls "github.com/bluenviron/mediamtx/internal/protocols/tls"
 	"github.com/bluenviron/mediamtx/internal/restrictnetwork"
 )
 
@@ -31,14 +33,16 @@ func (nilWriter) Write(p []byte) (int, error) {
 // - server header
 // - filtering of invalid requests
 type Server struct {
-	Address      string
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
-	Encryption   bool
-	ServerCert   string
-	ServerKey    string
-	Handler      http.Handler
-	Parent       logger.Writer
+	Address              string
+	ReadTimeout          time.Duration
+	WriteTimeout         time.Duration
+	Encryption           bool
+	ServerCert           string
+	ServerKey            string
+	ClientCA             string
+	ProxyProtocolSources []net.IPNet
+	Handler              http.Handler
+	Parent               logger.Writer
 
 	ln     net.Listener
 	inner  *http.Server
@@ -73,6 +77,12 @@ func (s *Server) Initialize() error {
 		tlsConfig = &tls.Config{
 			GetCertificate: s.loader.GetCertificate(),
 		}
+
+		err = mtls.ConfigureClientAuth(tlsConfig, s.ClientCA)
+		if err != nil {
+			s.loader.Close()
+			return err
+		}
 	}
 
 	var network string
@@ -97,6 +107,8 @@ func (s *Server) Initialize() error {
 
 	if network == "unix" {
 		os.Chmod(address, 0o755) //nolint:errcheck
+	} else {
+		s.ln = proxyprotocol.Wrap(s.ln, s.ProxyProtocolSources, s.ReadTimeout)
 	}
 
 	h := s.Handler
@@ -79,3 +79,40 @@ func TestUnixSocket(t *testing.T) {
 	_, err = os.Stat("http.sock")
 	require.EqualError(t, err, "stat http.sock: no such file or directory")
 }
+
+func TestProxyProtocol(t *testing.T) {
+	_, trusted, err := net.ParseCIDR("127.0.0.0/8")
+	require.NoError(t, err)
+
+	s := &Server{
+		Address:              "localhost:4555",
+		ReadTimeout:          10 * time.Second,
+		WriteTimeout:         10 * time.Second,
+		ProxyProtocolSources: []net.IPNet{*trusted},
+		Parent:               test.NilLogger,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Remote-Addr", r.RemoteAddr)
+			w.WriteHeader(http.StatusOK)
+		}),
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", "localhost:4555")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("PROXY TCP4 192.168.3.4 127.0.0.1 34567 4555\r\n" +
+		"OPTIONS / HTTP/1.1\n" +
+		"Host: localhost:8889\n\n"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 200)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+
+	res := strings.Split(string(buf[:n]), "\r\n")
+	require.Equal(t, "HTTP/1.1 200 OK", res[0])
+	require.Contains(t, res, "X-Remote-Addr: 192.168.3.4:34567")
+}
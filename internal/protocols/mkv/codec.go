@@ -0,0 +1,166 @@
+package mkv
+
+import (
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
+)
+
+// Codec is a Matroska codec.
+type Codec interface {
+	IsVideo() bool
+	isCodec()
+}
+
+// CodecH264 is a H264 codec.
+type CodecH264 struct {
+	SPS []byte
+	PPS []byte
+}
+
+// IsVideo implements Codec.
+func (CodecH264) IsVideo() bool { return true }
+
+func (*CodecH264) isCodec() {}
+
+// CodecH265 is a H265 codec.
+type CodecH265 struct {
+	VPS []byte
+	SPS []byte
+	PPS []byte
+}
+
+// IsVideo implements Codec.
+func (CodecH265) IsVideo() bool { return true }
+
+func (*CodecH265) isCodec() {}
+
+// CodecAV1 is an AV1 codec.
+type CodecAV1 struct {
+	SequenceHeader []byte
+}
+
+// IsVideo implements Codec.
+func (CodecAV1) IsVideo() bool { return true }
+
+func (*CodecAV1) isCodec() {}
+
+// CodecVP8 is a VP8 codec.
+type CodecVP8 struct {
+	Width  int
+	Height int
+}
+
+// IsVideo implements Codec.
+func (CodecVP8) IsVideo() bool { return true }
+
+func (*CodecVP8) isCodec() {}
+
+// CodecVP9 is a VP9 codec.
+type CodecVP9 struct {
+	Width             int
+	Height            int
+	Profile           uint8
+	BitDepth          uint8
+	ChromaSubsampling uint8
+	ColorRange        bool
+}
+
+// IsVideo implements Codec.
+func (CodecVP9) IsVideo() bool { return true }
+
+func (*CodecVP9) isCodec() {}
+
+// CodecMPEG4Video is a MPEG-4 Video codec.
+type CodecMPEG4Video struct {
+	Config []byte
+}
+
+// IsVideo implements Codec.
+func (CodecMPEG4Video) IsVideo() bool { return true }
+
+func (*CodecMPEG4Video) isCodec() {}
+
+// CodecMPEG1Video is a MPEG-1/2 Video codec.
+type CodecMPEG1Video struct {
+	Config []byte
+}
+
+// IsVideo implements Codec.
+func (CodecMPEG1Video) IsVideo() bool { return true }
+
+func (*CodecMPEG1Video) isCodec() {}
+
+// CodecMJPEG is a Motion-JPEG codec.
+type CodecMJPEG struct {
+	Width  int
+	Height int
+}
+
+// IsVideo implements Codec.
+func (CodecMJPEG) IsVideo() bool { return true }
+
+func (*CodecMJPEG) isCodec() {}
+
+// CodecOpus is an Opus codec.
+type CodecOpus struct {
+	ChannelCount int
+}
+
+// IsVideo implements Codec.
+func (CodecOpus) IsVideo() bool { return false }
+
+func (*CodecOpus) isCodec() {}
+
+// CodecMPEG4Audio is a MPEG-4 Audio codec.
+type CodecMPEG4Audio struct {
+	Config mpeg4audio.AudioSpecificConfig
+}
+
+// IsVideo implements Codec.
+func (CodecMPEG4Audio) IsVideo() bool { return false }
+
+func (*CodecMPEG4Audio) isCodec() {}
+
+// CodecMPEG1Audio is a MPEG-1/2 Audio codec.
+type CodecMPEG1Audio struct {
+	Layer        int
+	SampleRate   int
+	ChannelCount int
+}
+
+// IsVideo implements Codec.
+func (CodecMPEG1Audio) IsVideo() bool { return false }
+
+func (*CodecMPEG1Audio) isCodec() {}
+
+// CodecAC3 is an AC-3 codec.
+type CodecAC3 struct {
+	SampleRate   int
+	ChannelCount int
+}
+
+// IsVideo implements Codec.
+func (CodecAC3) IsVideo() bool { return false }
+
+func (*CodecAC3) isCodec() {}
+
+// CodecLPCM is a LPCM codec.
+type CodecLPCM struct {
+	LittleEndian bool
+	BitDepth     int
+	SampleRate   int
+	ChannelCount int
+}
+
+// IsVideo implements Codec.
+func (CodecLPCM) IsVideo() bool { return false }
+
+func (*CodecLPCM) isCodec() {}
+
+// IsWebM checks whether a codec can be stored in WebM files.
+func IsWebM(c Codec) bool {
+	switch c.(type) {
+	case *CodecVP8, *CodecVP9, *CodecAV1, *CodecOpus:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,334 @@
+package mkv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// element IDs.
+// Specification: RFC 9559
+const (
+	idEBML               = 0x1A45DFA3
+	idEBMLVersion        = 0x4286
+	idEBMLReadVersion    = 0x42F7
+	idEBMLMaxIDLength    = 0x42F2
+	idEBMLMaxSizeLength  = 0x42F3
+	idDocType            = 0x4282
+	idDocTypeVersion     = 0x4287
+	idDocTypeReadVersion = 0x4285
+
+	idSegment      = 0x18538067
+	idSeekHead     = 0x114D9B74
+	idSeek         = 0x4DBB
+	idSeekID       = 0x53AB
+	idSeekPosition = 0x53AC
+
+	idInfo           = 0x1549A966
+	idTimestampScale = 0x2AD7B1
+	idDuration       = 0x4489
+	idDateUTC        = 0x4461
+	idMuxingApp      = 0x4D80
+	idWritingApp     = 0x5741
+
+	idTracks            = 0x1654AE6B
+	idTrackEntry        = 0xAE
+	idTrackNumber       = 0xD7
+	idTrackUID          = 0x73C5
+	idTrackType         = 0x83
+	idFlagLacing        = 0x9C
+	idCodecID           = 0x86
+	idCodecPrivate      = 0x63A2
+	idSeekPreRoll       = 0x56BB
+	idVideo             = 0xE0
+	idPixelWidth        = 0xB0
+	idPixelHeight       = 0xBA
+	idAudio             = 0xE1
+	idSamplingFrequency = 0xB5
+	idChannels          = 0x9F
+	idBitDepth          = 0x6264
+
+	idCluster     = 0x1F43B675
+	idTimestamp   = 0xE7
+	idSimpleBlock = 0xA3
+	idBlockGroup  = 0xA0
+	idBlock       = 0xA1
+
+	idCues               = 0x1C53BB6B
+	idCuePoint           = 0xBB
+	idCueTime            = 0xB3
+	idCueTrackPositions  = 0xB7
+	idCueTrack           = 0xF7
+	idCueClusterPosition = 0xF1
+
+	idTags      = 0x1254C367
+	idTag       = 0x7373
+	idTargets   = 0x63C0
+	idSimpleTag = 0x67C8
+	idTagName   = 0x45A3
+	idTagString = 0x4487
+
+	idVoid = 0xEC
+)
+
+const (
+	// value of a size field when size is unknown.
+	unknownSize = (1 << 56) - 1
+
+	maxElementSize = 1 << 31
+)
+
+var errUnknownSize = errors.New("unknown size")
+
+func idSize(id uint32) int {
+	switch {
+	case id <= 0xFF:
+		return 1
+	case id <= 0xFFFF:
+		return 2
+	case id <= 0xFFFFFF:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func appendID(buf []byte, id uint32) []byte {
+	for i := idSize(id) - 1; i >= 0; i-- {
+		buf = append(buf, byte(id>>(i*8)))
+	}
+	return buf
+}
+
+func sizeSize(size uint64) int {
+	n := 1
+	// all bits set is reserved for unknown sizes
+	for size >= (1<<(7*n))-1 {
+		n++
+	}
+	return n
+}
+
+func appendSizeN(buf []byte, size uint64, n int) []byte {
+	size |= 1 << (7 * n)
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, byte(size>>(i*8)))
+	}
+	return buf
+}
+
+func appendSize(buf []byte, size uint64) []byte {
+	return appendSizeN(buf, size, sizeSize(size))
+}
+
+func appendMaster(buf []byte, id uint32, payload []byte) []byte {
+	buf = appendID(buf, id)
+	buf = appendSize(buf, uint64(len(payload)))
+	return append(buf, payload...)
+}
+
+func appendUint(buf []byte, id uint32, v uint64) []byte {
+	n := 1
+	for n < 8 && (v>>(n*8)) != 0 {
+		n++
+	}
+
+	buf = appendID(buf, id)
+	buf = appendSize(buf, uint64(n))
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, byte(v>>(i*8)))
+	}
+	return buf
+}
+
+func appendInt(buf []byte, id uint32, v int64) []byte {
+	buf = appendID(buf, id)
+	buf = appendSize(buf, 8)
+	return binary.BigEndian.AppendUint64(buf, uint64(v))
+}
+
+func appendFloat(buf []byte, id uint32, v float64) []byte {
+	buf = appendID(buf, id)
+	buf = appendSize(buf, 8)
+	return binary.BigEndian.AppendUint64(buf, math.Float64bits(v))
+}
+
+func appendString(buf []byte, id uint32, v string) []byte {
+	return appendMaster(buf, id, []byte(v))
+}
+
+// appendVoid appends a Void element that occupies exactly n bytes.
+func appendVoid(buf []byte, n int) []byte {
+	buf = appendID(buf, idVoid)
+	if n <= 128 {
+		buf = appendSizeN(buf, uint64(n-2), 1)
+		return append(buf, make([]byte, n-2)...)
+	}
+	buf = appendSizeN(buf, uint64(n-9), 8)
+	return append(buf, make([]byte, n-9)...)
+}
+
+type elementHeader struct {
+	id   uint32
+	size uint64
+	len  int
+}
+
+func readElementHeader(r io.Reader) (elementHeader, error) {
+	var h elementHeader
+
+	buf := make([]byte, 8)
+
+	_, err := io.ReadFull(r, buf[:1])
+	if err != nil {
+		return h, err
+	}
+
+	n := 1
+	for n <= 4 && (buf[0]&(0x80>>(n-1))) == 0 {
+		n++
+	}
+	if n > 4 {
+		return h, fmt.Errorf("invalid element ID")
+	}
+
+	_, err = io.ReadFull(r, buf[1:n])
+	if err != nil {
+		return h, err
+	}
+
+	for i := range n {
+		h.id = h.id<<8 | uint32(buf[i])
+	}
+	h.len = n
+
+	_, err = io.ReadFull(r, buf[:1])
+	if err != nil {
+		return h, err
+	}
+
+	n = 1
+	for n <= 8 && (buf[0]&(0x80>>(n-1))) == 0 {
+		n++
+	}
+	if n > 8 {
+		return h, fmt.Errorf("invalid element size")
+	}
+
+	_, err = io.ReadFull(r, buf[1:n])
+	if err != nil {
+		return h, err
+	}
+
+	h.size = uint64(buf[0] & (0xFF >> n))
+	allOnes := h.size == uint64(0xFF>>n)
+	for i := 1; i < n; i++ {
+		h.size = h.size<<8 | uint64(buf[i])
+		allOnes = allOnes && buf[i] == 0xFF
+	}
+	h.len += n
+
+	if allOnes {
+		h.size = unknownSize
+	}
+
+	return h, nil
+}
+
+func readPayload(r io.Reader, h elementHeader) ([]byte, error) {
+	if h.size == unknownSize {
+		return nil, errUnknownSize
+	}
+	if h.size > maxElementSize {
+		return nil, fmt.Errorf("element size (%d) is too big", h.size)
+	}
+
+	buf := make([]byte, h.size)
+	_, err := io.ReadFull(r, buf)
+	return buf, err
+}
+
+// parseChildren calls cb for each child of a master element.
+func parseChildren(buf []byte, cb func(id uint32, payload []byte) error) error {
+	for len(buf) != 0 {
+		r := &sliceReader{buf: buf}
+
+		h, err := readElementHeader(r)
+		if err != nil {
+			return err
+		}
+
+		buf = buf[h.len:]
+
+		if h.size == unknownSize || h.size > uint64(len(buf)) {
+			return fmt.Errorf("invalid size of element %x", h.id)
+		}
+
+		err = cb(h.id, buf[:h.size])
+		if err != nil {
+			return err
+		}
+
+		buf = buf[h.size:]
+	}
+
+	return nil
+}
+
+type sliceReader struct {
+	buf []byte
+}
+
+func (r *sliceReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func decodeUint(buf []byte) (uint64, error) {
+	if len(buf) > 8 {
+		return 0, fmt.Errorf("invalid unsigned integer size")
+	}
+
+	var v uint64
+	for _, b := range buf {
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+func decodeInt(buf []byte) (int64, error) {
+	if len(buf) > 8 {
+		return 0, fmt.Errorf("invalid integer size")
+	}
+	if len(buf) == 0 {
+		return 0, nil
+	}
+
+	v := int64(int8(buf[0]))
+	for _, b := range buf[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+func decodeFloat(buf []byte) (float64, error) {
+	switch len(buf) {
+	case 0:
+		return 0, nil
+
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(buf))), nil
+
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(buf)), nil
+
+	default:
+		return 0, fmt.Errorf("invalid float size")
+	}
+}
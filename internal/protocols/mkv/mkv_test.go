@@ -0,0 +1,208 @@
+package mkv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bluenviron/mediamtx/internal/test"
+)
+
+var testTracks = []*Track{
+	{
+		Number: 1,
+		Codec: &CodecH264{
+			SPS: test.FormatH264.SPS,
+			PPS: test.FormatH264.PPS,
+		},
+	},
+	{
+		Number: 2,
+		Codec: &CodecH265{
+			VPS: test.FormatH265.VPS,
+			SPS: test.FormatH265.SPS,
+			PPS: test.FormatH265.PPS,
+		},
+	},
+	{
+		Number: 3,
+		Codec: &CodecVP9{
+			Width:             1920,
+			Height:            1080,
+			Profile:           1,
+			BitDepth:          8,
+			ChromaSubsampling: 1,
+		},
+	},
+	{
+		Number: 4,
+		Codec: &CodecVP8{
+			Width:  640,
+			Height: 480,
+		},
+	},
+	{
+		Number: 5,
+		Codec: &CodecOpus{
+			ChannelCount: 6,
+		},
+	},
+	{
+		Number: 6,
+		Codec: &CodecMPEG4Audio{
+			Config: mpeg4audio.AudioSpecificConfig{
+				Type:         2,
+				SampleRate:   44100,
+				ChannelCount: 2,
+			},
+		},
+	},
+	{
+		Number: 7,
+		Codec: &CodecMPEG1Audio{
+			Layer:        3,
+			SampleRate:   48000,
+			ChannelCount: 2,
+		},
+	},
+	{
+		Number: 8,
+		Codec: &CodecLPCM{
+			BitDepth:     16,
+			SampleRate:   8000,
+			ChannelCount: 1,
+		},
+	},
+}
+
+func writeTestFile(t *testing.T, fpath string, finalize bool) {
+	f, err := os.Create(fpath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	w := &Writer{
+		W:       f,
+		DocType: "matroska",
+		Date:    time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC),
+		Tags:    []Tag{{Name: "KEY", Value: "value"}},
+		Tracks:  testTracks,
+	}
+	err = w.Initialize()
+	require.NoError(t, err)
+
+	err = w.WriteCluster(&Cluster{
+		Timestamp: 0,
+		Blocks: []*Block{
+			{TrackNumber: 1, Timestamp: 0, Keyframe: true, Payload: []byte{1, 2}},
+			{TrackNumber: 6, Timestamp: 0, Keyframe: true, Payload: []byte{3, 4}},
+			{TrackNumber: 1, Timestamp: 100 * time.Millisecond, Payload: []byte{5}},
+		},
+	})
+	require.NoError(t, err)
+
+	err = w.WriteCluster(&Cluster{
+		Timestamp: 2 * time.Second,
+		Blocks: []*Block{
+			{TrackNumber: 1, Timestamp: 2 * time.Second, Keyframe: true, Payload: []byte{6}},
+			{TrackNumber: 6, Timestamp: 1990 * time.Millisecond, Keyframe: true, Payload: []byte{7, 8, 9}},
+		},
+	})
+	require.NoError(t, err)
+
+	if finalize {
+		err = w.Close(2100 * time.Millisecond)
+		require.NoError(t, err)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	for _, ca := range []string{"finalized", "not finalized"} {
+		t.Run(ca, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "mediamtx-mkv")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			fpath := filepath.Join(dir, "test.mkv")
+			writeTestFile(t, fpath, ca == "finalized")
+
+			f, err := os.Open(fpath)
+			require.NoError(t, err)
+			defer f.Close()
+
+			r := &Reader{R: f}
+			err = r.Initialize()
+			require.NoError(t, err)
+
+			require.Equal(t, "matroska", r.DocType)
+			require.Equal(t, time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC), r.Date.UTC())
+			require.Equal(t, []Tag{{Name: "KEY", Value: "value"}}, r.Tags)
+			require.Equal(t, testTracks, r.Tracks)
+
+			if ca == "finalized" {
+				require.Equal(t, 2100*time.Millisecond, r.Duration)
+			} else {
+				require.Equal(t, time.Duration(0), r.Duration)
+			}
+
+			type block struct {
+				track     int
+				timestamp time.Duration
+				keyframe  bool
+				payload   []byte
+			}
+			var blocks []block
+
+			err = r.ReadBlocks(func(b *BlockInfo) error {
+				pl, err2 := r.ReadPayload(b)
+				require.NoError(t, err2)
+				blocks = append(blocks, block{b.TrackNumber, b.Timestamp, b.Keyframe, pl})
+				return nil
+			})
+			require.NoError(t, err)
+
+			require.Equal(t, []block{
+				{1, 0, true, []byte{1, 2}},
+				{6, 0, true, []byte{3, 4}},
+				{1, 100 * time.Millisecond, false, []byte{5}},
+				{1, 2 * time.Second, true, []byte{6}},
+				{6, 1990 * time.Millisecond, true, []byte{7, 8, 9}},
+			}, blocks)
+		})
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-mkv")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "test.mkv")
+	writeTestFile(t, fpath, false)
+
+	fi, err := os.Stat(fpath)
+	require.NoError(t, err)
+
+	// simulate an abrupt termination in the middle of the last cluster
+	err = os.Truncate(fpath, fi.Size()-2)
+	require.NoError(t, err)
+
+	f, err := os.Open(fpath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	r := &Reader{R: f}
+	err = r.Initialize()
+	require.NoError(t, err)
+
+	n := 0
+	err = r.ReadBlocks(func(_ *BlockInfo) error {
+		n++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+}
@@ -0,0 +1,441 @@
+package mkv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// BlockInfo contains information about a block.
+type BlockInfo struct {
+	TrackNumber int
+	// relative to the beginning of the segment
+	Timestamp time.Duration
+	Keyframe  bool
+	// position of the payload in the file
+	PayloadOffset int64
+	PayloadSize   int
+}
+
+func isEOF(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Reader reads a Matroska file.
+type Reader struct {
+	R io.ReadSeeker
+
+	//
+	// filled by Initialize()
+	//
+
+	DocType string
+	Date    time.Time
+	// zero when the file has not been finalized
+	Duration time.Duration
+	Tags     []Tag
+	// tracks with unsupported codecs are skipped
+	Tracks []*Track
+
+	fileSize         int64
+	segmentDataStart int64
+	segmentEnd       int64
+	firstClusterPos  int64
+	timestampScale   time.Duration
+}
+
+func (r *Reader) readHeaderAt(pos int64) (elementHeader, error) {
+	_, err := r.R.Seek(pos, io.SeekStart)
+	if err != nil {
+		return elementHeader{}, err
+	}
+	return readElementHeader(r.R)
+}
+
+// Initialize reads the file header.
+func (r *Reader) Initialize() error {
+	var err error
+	r.fileSize, err = r.R.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	h, err := r.readHeaderAt(0)
+	if err != nil {
+		return err
+	}
+
+	if h.id != idEBML {
+		return fmt.Errorf("EBML header not found")
+	}
+
+	buf, err := readPayload(r.R, h)
+	if err != nil {
+		return err
+	}
+
+	err = parseChildren(buf, func(id uint32, payload []byte) error {
+		if id == idDocType {
+			r.DocType = string(payload)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.DocType != "matroska" && r.DocType != "webm" {
+		return fmt.Errorf("unsupported document type: '%s'", r.DocType)
+	}
+
+	pos := int64(h.len) + int64(h.size)
+
+	h, err = r.readHeaderAt(pos)
+	if err != nil {
+		return err
+	}
+
+	if h.id != idSegment {
+		return fmt.Errorf("segment not found")
+	}
+
+	r.segmentDataStart = pos + int64(h.len)
+
+	if h.size == unknownSize {
+		r.segmentEnd = math.MaxInt64
+	} else {
+		r.segmentEnd = r.segmentDataStart + int64(h.size)
+	}
+
+	r.timestampScale = timestampScale
+	var duration float64
+
+	pos = r.segmentDataStart
+
+	for pos < r.segmentEnd {
+		h, err = r.readHeaderAt(pos)
+		if err != nil {
+			if isEOF(err) {
+				break
+			}
+			return err
+		}
+
+		if h.id == idCluster {
+			break
+		}
+
+		switch h.id {
+		case idInfo:
+			buf, err = readPayload(r.R, h)
+			if err != nil {
+				return err
+			}
+
+			err = parseChildren(buf, func(id uint32, payload []byte) error {
+				switch id {
+				case idTimestampScale:
+					v, err2 := decodeUint(payload)
+					if err2 != nil {
+						return err2
+					}
+					if v == 0 {
+						return fmt.Errorf("invalid timestamp scale")
+					}
+					r.timestampScale = time.Duration(v)
+
+				case idDuration:
+					v, err2 := decodeFloat(payload)
+					if err2 != nil {
+						return err2
+					}
+					duration = v
+
+				case idDateUTC:
+					v, err2 := decodeInt(payload)
+					if err2 != nil {
+						return err2
+					}
+					r.Date = matroskaEpoch.Add(time.Duration(v))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+		case idTracks:
+			buf, err = readPayload(r.R, h)
+			if err != nil {
+				return err
+			}
+
+			err = parseChildren(buf, func(id uint32, payload []byte) error {
+				if id != idTrackEntry {
+					return nil
+				}
+
+				var t Track
+				err2 := t.unmarshal(payload)
+				if err2 != nil {
+					if errors.Is(err2, errUnsupportedCodec) {
+						return nil
+					}
+					return err2
+				}
+
+				r.Tracks = append(r.Tracks, &t)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+		case idTags:
+			buf, err = readPayload(r.R, h)
+			if err != nil {
+				return err
+			}
+
+			err = r.parseTags(buf)
+			if err != nil {
+				return err
+			}
+		}
+
+		if h.size == unknownSize {
+			return errUnknownSize
+		}
+
+		pos += int64(h.len) + int64(h.size)
+	}
+
+	r.firstClusterPos = pos
+	r.Duration = time.Duration(duration * float64(r.timestampScale))
+
+	return nil
+}
+
+func (r *Reader) parseTags(buf []byte) error {
+	return parseChildren(buf, func(id uint32, payload []byte) error {
+		if id != idTag {
+			return nil
+		}
+
+		return parseChildren(payload, func(id uint32, payload []byte) error {
+			if id != idSimpleTag {
+				return nil
+			}
+
+			var t Tag
+			err := parseChildren(payload, func(id uint32, payload []byte) error {
+				switch id {
+				case idTagName:
+					t.Name = string(payload)
+				case idTagString:
+					t.Value = string(payload)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			r.Tags = append(r.Tags, t)
+			return nil
+		})
+	})
+}
+
+// ReadBlocks calls cb for each block of the file, in storage order.
+// Blocks that have been truncated by an abrupt termination are skipped.
+func (r *Reader) ReadBlocks(cb func(b *BlockInfo) error) error {
+	pos := r.firstClusterPos
+
+	for pos < r.segmentEnd {
+		h, err := r.readHeaderAt(pos)
+		if err != nil {
+			if isEOF(err) {
+				return nil
+			}
+			return err
+		}
+
+		pos += int64(h.len)
+
+		if h.id != idCluster {
+			if h.size == unknownSize {
+				return nil
+			}
+			pos += int64(h.size)
+			continue
+		}
+
+		clusterEnd := r.segmentEnd
+		if h.size != unknownSize {
+			clusterEnd = pos + int64(h.size)
+		}
+
+		pos, err = r.readCluster(pos, clusterEnd, cb)
+		if err != nil {
+			if isEOF(err) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Reader) readCluster(pos int64, end int64, cb func(b *BlockInfo) error) (int64, error) {
+	var clusterTS int64
+
+	for pos < end {
+		h, err := r.readHeaderAt(pos)
+		if err != nil {
+			return 0, err
+		}
+
+		// end of a cluster with unknown size, signaled by a top-level element
+		if idSize(h.id) == 4 {
+			return pos, nil
+		}
+
+		if h.size == unknownSize {
+			return 0, errUnknownSize
+		}
+
+		dataPos := pos + int64(h.len)
+		pos = dataPos + int64(h.size)
+
+		if pos > r.fileSize {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		switch h.id {
+		case idTimestamp:
+			buf, err := readPayload(r.R, h)
+			if err != nil {
+				return 0, err
+			}
+
+			v, err := decodeUint(buf)
+			if err != nil {
+				return 0, err
+			}
+			clusterTS = int64(v)
+
+		case idSimpleBlock:
+			// read header only
+			buf := make([]byte, min(h.size, 12))
+			_, err = io.ReadFull(r.R, buf)
+			if err != nil {
+				return 0, err
+			}
+
+			b, err := r.parseBlockHeader(buf, clusterTS, dataPos, int(h.size))
+			if err != nil {
+				return 0, err
+			}
+			b.Keyframe = (buf[b.headerLen-1] & 0x80) != 0
+
+			err = cb(&b.BlockInfo)
+			if err != nil {
+				return 0, err
+			}
+
+		case idBlockGroup:
+			buf, err := readPayload(r.R, h)
+			if err != nil {
+				return 0, err
+			}
+
+			var b *parsedBlock
+			keyframe := true
+
+			err = parseChildren(buf, func(id uint32, payload []byte) error {
+				switch id {
+				case idBlock:
+					// payload is a subslice of buf, therefore its position can be computed from capacities
+					var err2 error
+					b, err2 = r.parseBlockHeader(payload, clusterTS,
+						dataPos+int64(cap(buf)-cap(payload)), len(payload))
+					return err2
+
+				case 0xFB: // ReferenceBlock
+					keyframe = false
+				}
+				return nil
+			})
+			if err != nil {
+				return 0, err
+			}
+
+			if b != nil {
+				b.Keyframe = keyframe
+
+				err = cb(&b.BlockInfo)
+				if err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+
+	return pos, nil
+}
+
+type parsedBlock struct {
+	BlockInfo
+	headerLen int
+}
+
+func (r *Reader) parseBlockHeader(buf []byte, clusterTS int64, dataPos int64, size int) (*parsedBlock, error) {
+	// track number is encoded like an element size
+	n := 1
+	for n <= 8 && len(buf) != 0 && (buf[0]&(0x80>>(n-1))) == 0 {
+		n++
+	}
+	if n > 8 || len(buf) < n+3 {
+		return nil, fmt.Errorf("invalid block")
+	}
+
+	trackNumber := uint64(buf[0] & (0xFF >> n))
+	for i := 1; i < n; i++ {
+		trackNumber = trackNumber<<8 | uint64(buf[i])
+	}
+
+	rel := int16(uint16(buf[n])<<8 | uint16(buf[n+1]))
+	flags := buf[n+2]
+
+	if (flags & 0x06) != 0 {
+		return nil, fmt.Errorf("laced blocks are not supported")
+	}
+
+	headerLen := n + 3
+
+	return &parsedBlock{
+		BlockInfo: BlockInfo{
+			TrackNumber:   int(trackNumber),
+			Timestamp:     time.Duration(clusterTS+int64(rel)) * r.timestampScale,
+			PayloadOffset: dataPos + int64(headerLen),
+			PayloadSize:   size - headerLen,
+		},
+		headerLen: headerLen,
+	}, nil
+}
+
+// ReadPayload reads the payload of a block.
+func (r *Reader) ReadPayload(b *BlockInfo) ([]byte, error) {
+	_, err := r.R.Seek(b.PayloadOffset, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, b.PayloadSize)
+	_, err = io.ReadFull(r.R, buf)
+	return buf, err
+}
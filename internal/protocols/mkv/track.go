@@ -0,0 +1,611 @@
+package mkv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/av1"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
+)
+
+const (
+	trackTypeVideo = 1
+	trackTypeAudio = 2
+
+	// recommended by the WebM specification for Opus.
+	opusSeekPreRoll = 80000000
+)
+
+var errUnsupportedCodec = errors.New("unsupported codec")
+
+// Opus channel mapping family 1 (Vorbis order).
+// Specification: RFC 7845, section 5.1.1.2
+var opusVorbisMappings = map[int]struct {
+	streams int
+	coupled int
+	mapping []byte
+}{
+	3: {2, 1, []byte{0, 2, 1}},
+	4: {2, 2, []byte{0, 1, 2, 3}},
+	5: {3, 2, []byte{0, 4, 1, 2, 3}},
+	6: {4, 2, []byte{0, 4, 1, 2, 3, 5}},
+	7: {4, 3, []byte{0, 4, 1, 2, 3, 5, 6}},
+	8: {5, 3, []byte{0, 6, 1, 2, 3, 4, 5, 7}},
+}
+
+func boolToUint8(v bool) uint8 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// Track is a Matroska track.
+type Track struct {
+	Number int
+	Codec  Codec
+}
+
+func marshalAVCC(sps []byte, pps []byte) ([]byte, error) {
+	if len(sps) < 4 {
+		return nil, fmt.Errorf("invalid SPS")
+	}
+
+	buf := []byte{1, sps[1], sps[2], sps[3], 0xFF, 0xE1}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(sps)))
+	buf = append(buf, sps...)
+	buf = append(buf, 1)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(pps)))
+	buf = append(buf, pps...)
+	return buf, nil
+}
+
+func readParameterSet(buf []byte, pos *int) ([]byte, error) {
+	if len(buf) < *pos+2 {
+		return nil, fmt.Errorf("not enough bytes")
+	}
+	le := int(binary.BigEndian.Uint16(buf[*pos:]))
+	*pos += 2
+
+	if len(buf) < *pos+le {
+		return nil, fmt.Errorf("not enough bytes")
+	}
+	ps := buf[*pos : *pos+le]
+	*pos += le
+
+	return ps, nil
+}
+
+func unmarshalAVCC(buf []byte) ([]byte, []byte, error) {
+	if len(buf) < 6 {
+		return nil, nil, fmt.Errorf("not enough bytes")
+	}
+
+	pos := 6
+	var sps []byte
+	var pps []byte
+
+	for i := range int(buf[5] & 0x1F) {
+		ps, err := readParameterSet(buf, &pos)
+		if err != nil {
+			return nil, nil, err
+		}
+		if i == 0 {
+			sps = ps
+		}
+	}
+
+	if len(buf) < pos+1 {
+		return nil, nil, fmt.Errorf("not enough bytes")
+	}
+	count := int(buf[pos])
+	pos++
+
+	for i := range count {
+		ps, err := readParameterSet(buf, &pos)
+		if err != nil {
+			return nil, nil, err
+		}
+		if i == 0 {
+			pps = ps
+		}
+	}
+
+	if sps == nil || pps == nil {
+		return nil, nil, fmt.Errorf("SPS or PPS not found")
+	}
+
+	return sps, pps, nil
+}
+
+func marshalHVCC(vps []byte, sps []byte, pps []byte, parsedSPS *h265.SPS) ([]byte, error) {
+	rawSPS := h264.EmulationPreventionRemove(sps)
+	if len(rawSPS) < 15 {
+		return nil, fmt.Errorf("invalid SPS")
+	}
+
+	buf := []byte{1}
+	// general profile, tier, compatibility flags, constraint flags and level
+	buf = append(buf, rawSPS[3:15]...)
+	buf = append(buf,
+		0xF0, 0x00, // min_spatial_segmentation_idc
+		0xFC, // parallelismType
+		0xFC|uint8(parsedSPS.ChromaFormatIdc),
+		0xF8|uint8(parsedSPS.BitDepthLumaMinus8),
+		0xF8|uint8(parsedSPS.BitDepthChromaMinus8),
+		0, 0, // avgFrameRate
+		1<<3|3, // numTemporalLayers, lengthSizeMinusOne
+		3,      // numOfArrays
+	)
+
+	for _, nalu := range [][]byte{vps, sps, pps} {
+		buf = append(buf, 1<<7|(nalu[0]>>1)&0b111111)
+		buf = binary.BigEndian.AppendUint16(buf, 1)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(nalu)))
+		buf = append(buf, nalu...)
+	}
+
+	return buf, nil
+}
+
+func unmarshalHVCC(buf []byte) ([]byte, []byte, []byte, error) {
+	if len(buf) < 23 {
+		return nil, nil, nil, fmt.Errorf("not enough bytes")
+	}
+
+	pos := 23
+	var vps []byte
+	var sps []byte
+	var pps []byte
+
+	for range int(buf[22]) {
+		if len(buf) < pos+3 {
+			return nil, nil, nil, fmt.Errorf("not enough bytes")
+		}
+		typ := h265.NALUType(buf[pos] & 0b111111)
+		count := int(binary.BigEndian.Uint16(buf[pos+1:]))
+		pos += 3
+
+		for i := range count {
+			ps, err := readParameterSet(buf, &pos)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+
+			if i == 0 {
+				switch typ {
+				case h265.NALUType_VPS_NUT:
+					vps = ps
+				case h265.NALUType_SPS_NUT:
+					sps = ps
+				case h265.NALUType_PPS_NUT:
+					pps = ps
+				}
+			}
+		}
+	}
+
+	if vps == nil || sps == nil || pps == nil {
+		return nil, nil, nil, fmt.Errorf("VPS, SPS or PPS not found")
+	}
+
+	return vps, sps, pps, nil
+}
+
+func marshalAV1C(sequenceHeader []byte, h *av1.SequenceHeader) ([]byte, error) {
+	configOBUs, err := av1.Bitstream([][]byte{sequenceHeader}).Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var levelIdx uint8
+	if len(h.SeqLevelIdx) != 0 {
+		levelIdx = h.SeqLevelIdx[0]
+	}
+
+	var tier bool
+	if len(h.SeqTier) != 0 {
+		tier = h.SeqTier[0]
+	}
+
+	buf := []byte{
+		0x81, // marker, version
+		h.SeqProfile<<5 | levelIdx&0b11111,
+		boolToUint8(tier)<<7 |
+			boolToUint8(h.ColorConfig.HighBitDepth)<<6 |
+			boolToUint8(h.ColorConfig.TwelveBit)<<5 |
+			boolToUint8(h.ColorConfig.MonoChrome)<<4 |
+			boolToUint8(h.ColorConfig.SubsamplingX)<<3 |
+			boolToUint8(h.ColorConfig.SubsamplingY)<<2 |
+			uint8(h.ColorConfig.ChromaSamplePosition)&0b11,
+		0,
+	}
+	return append(buf, configOBUs...), nil
+}
+
+func unmarshalAV1C(buf []byte) ([]byte, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("not enough bytes")
+	}
+
+	var bs av1.Bitstream
+	err := bs.Unmarshal(buf[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, obu := range bs {
+		if av1.OBUType((obu[0]>>3)&0b1111) == av1.OBUTypeSequenceHeader {
+			return obu, nil
+		}
+	}
+
+	return nil, fmt.Errorf("sequence header not found")
+}
+
+func marshalOpusHead(channelCount int) []byte {
+	buf := []byte("OpusHead")
+	buf = append(buf, 1, byte(channelCount))
+	buf = binary.LittleEndian.AppendUint16(buf, 312) // pre-skip
+	buf = binary.LittleEndian.AppendUint32(buf, 48000)
+	buf = binary.LittleEndian.AppendUint16(buf, 0) // output gain
+
+	if m, ok := opusVorbisMappings[channelCount]; ok {
+		buf = append(buf, 1, byte(m.streams), byte(m.coupled))
+		buf = append(buf, m.mapping...)
+	} else {
+		buf = append(buf, 0)
+	}
+
+	return buf
+}
+
+func (t Track) marshal() ([]byte, error) {
+	var codecID string
+	var codecPrivate []byte
+	var width int
+	var height int
+	var sampleRate int
+	var channelCount int
+	var bitDepth int
+	var seekPreRoll uint64
+
+	switch codec := t.Codec.(type) {
+	case *CodecH264:
+		var sps h264.SPS
+		err := sps.Unmarshal(codec.SPS)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse SPS: %w", err)
+		}
+
+		codecID = "V_MPEG4/ISO/AVC"
+		codecPrivate, err = marshalAVCC(codec.SPS, codec.PPS)
+		if err != nil {
+			return nil, err
+		}
+		width, height = sps.Width(), sps.Height()
+
+	case *CodecH265:
+		var sps h265.SPS
+		err := sps.Unmarshal(codec.SPS)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse SPS: %w", err)
+		}
+
+		codecID = "V_MPEGH/ISO/HEVC"
+		codecPrivate, err = marshalHVCC(codec.VPS, codec.SPS, codec.PPS, &sps)
+		if err != nil {
+			return nil, err
+		}
+		width, height = sps.Width(), sps.Height()
+
+	case *CodecAV1:
+		var h av1.SequenceHeader
+		err := h.Unmarshal(codec.SequenceHeader)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse sequence header: %w", err)
+		}
+
+		codecID = "V_AV1"
+		codecPrivate, err = marshalAV1C(codec.SequenceHeader, &h)
+		if err != nil {
+			return nil, err
+		}
+		width, height = h.Width(), h.Height()
+
+	case *CodecVP8:
+		codecID = "V_VP8"
+		width, height = codec.Width, codec.Height
+
+	case *CodecVP9:
+		codecID = "V_VP9"
+		codecPrivate = []byte{
+			1, 1, codec.Profile,
+			3, 1, codec.BitDepth,
+			4, 1, codec.ChromaSubsampling,
+		}
+		width, height = codec.Width, codec.Height
+
+	case *CodecMPEG4Video:
+		codecID = "V_MPEG4/ISO/ASP"
+		codecPrivate = codec.Config
+		width, height = 800, 600
+
+	case *CodecMPEG1Video:
+		// a sequence extension is present in MPEG-2 streams only
+		if bytes.Contains(codec.Config, []byte{0, 0, 1, 0xB5}) {
+			codecID = "V_MPEG2"
+		} else {
+			codecID = "V_MPEG1"
+		}
+		codecPrivate = codec.Config
+		width, height = 800, 600
+
+	case *CodecMJPEG:
+		codecID = "V_MJPEG"
+		width, height = codec.Width, codec.Height
+
+	case *CodecOpus:
+		codecID = "A_OPUS"
+		codecPrivate = marshalOpusHead(codec.ChannelCount)
+		sampleRate = 48000
+		channelCount = codec.ChannelCount
+		seekPreRoll = opusSeekPreRoll
+
+	case *CodecMPEG4Audio:
+		var err error
+		codecID = "A_AAC"
+		codecPrivate, err = codec.Config.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		sampleRate = codec.Config.SampleRate
+		channelCount = codec.Config.ChannelCount
+
+	case *CodecMPEG1Audio:
+		codecID = "A_MPEG/L" + strconv.FormatInt(int64(codec.Layer), 10)
+		sampleRate = codec.SampleRate
+		channelCount = codec.ChannelCount
+
+	case *CodecAC3:
+		codecID = "A_AC3"
+		sampleRate = codec.SampleRate
+		channelCount = codec.ChannelCount
+
+	case *CodecLPCM:
+		if codec.LittleEndian {
+			codecID = "A_PCM/INT/LIT"
+		} else {
+			codecID = "A_PCM/INT/BIG"
+		}
+		sampleRate = codec.SampleRate
+		channelCount = codec.ChannelCount
+		bitDepth = codec.BitDepth
+
+	default:
+		return nil, errUnsupportedCodec
+	}
+
+	var buf []byte
+	buf = appendUint(buf, idTrackNumber, uint64(t.Number))
+	buf = appendUint(buf, idTrackUID, uint64(t.Number))
+	buf = appendUint(buf, idFlagLacing, 0)
+	buf = appendString(buf, idCodecID, codecID)
+
+	if codecPrivate != nil {
+		buf = appendMaster(buf, idCodecPrivate, codecPrivate)
+	}
+
+	if seekPreRoll != 0 {
+		buf = appendUint(buf, idSeekPreRoll, seekPreRoll)
+	}
+
+	if t.Codec.IsVideo() {
+		buf = appendUint(buf, idTrackType, trackTypeVideo)
+
+		var video []byte
+		video = appendUint(video, idPixelWidth, uint64(width))
+		video = appendUint(video, idPixelHeight, uint64(height))
+		buf = appendMaster(buf, idVideo, video)
+	} else {
+		buf = appendUint(buf, idTrackType, trackTypeAudio)
+
+		var audio []byte
+		audio = appendFloat(audio, idSamplingFrequency, float64(sampleRate))
+		audio = appendUint(audio, idChannels, uint64(channelCount))
+		if bitDepth != 0 {
+			audio = appendUint(audio, idBitDepth, uint64(bitDepth))
+		}
+		buf = appendMaster(buf, idAudio, audio)
+	}
+
+	return appendMaster(nil, idTrackEntry, buf), nil
+}
+
+func (t *Track) unmarshal(buf []byte) error {
+	var codecID string
+	var codecPrivate []byte
+	var width int
+	var height int
+	var sampleRate int
+	channelCount := 1
+	var bitDepth int
+
+	err := parseChildren(buf, func(id uint32, payload []byte) error {
+		switch id {
+		case idTrackNumber:
+			v, err := decodeUint(payload)
+			if err != nil {
+				return err
+			}
+			t.Number = int(v)
+
+		case idCodecID:
+			codecID = string(payload)
+
+		case idCodecPrivate:
+			codecPrivate = payload
+
+		case idVideo:
+			return parseChildren(payload, func(id uint32, payload []byte) error {
+				switch id {
+				case idPixelWidth:
+					v, err := decodeUint(payload)
+					if err != nil {
+						return err
+					}
+					width = int(v)
+
+				case idPixelHeight:
+					v, err := decodeUint(payload)
+					if err != nil {
+						return err
+					}
+					height = int(v)
+				}
+				return nil
+			})
+
+		case idAudio:
+			return parseChildren(payload, func(id uint32, payload []byte) error {
+				switch id {
+				case idSamplingFrequency:
+					v, err := decodeFloat(payload)
+					if err != nil {
+						return err
+					}
+					sampleRate = int(v)
+
+				case idChannels:
+					v, err := decodeUint(payload)
+					if err != nil {
+						return err
+					}
+					channelCount = int(v)
+
+				case idBitDepth:
+					v, err := decodeUint(payload)
+					if err != nil {
+						return err
+					}
+					bitDepth = int(v)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case codecID == "V_MPEG4/ISO/AVC":
+		sps, pps, err2 := unmarshalAVCC(codecPrivate)
+		if err2 != nil {
+			return fmt.Errorf("invalid H264 configuration: %w", err2)
+		}
+		t.Codec = &CodecH264{SPS: sps, PPS: pps}
+
+	case codecID == "V_MPEGH/ISO/HEVC":
+		vps, sps, pps, err2 := unmarshalHVCC(codecPrivate)
+		if err2 != nil {
+			return fmt.Errorf("invalid H265 configuration: %w", err2)
+		}
+		t.Codec = &CodecH265{VPS: vps, SPS: sps, PPS: pps}
+
+	case codecID == "V_AV1":
+		sequenceHeader, err2 := unmarshalAV1C(codecPrivate)
+		if err2 != nil {
+			return fmt.Errorf("invalid AV1 configuration: %w", err2)
+		}
+		t.Codec = &CodecAV1{SequenceHeader: sequenceHeader}
+
+	case codecID == "V_VP8":
+		t.Codec = &CodecVP8{Width: width, Height: height}
+
+	case codecID == "V_VP9":
+		codec := &CodecVP9{
+			Width:             width,
+			Height:            height,
+			BitDepth:          8,
+			ChromaSubsampling: 1,
+		}
+
+		for i := 0; i+2 < len(codecPrivate); i += 2 + int(codecPrivate[i+1]) {
+			if codecPrivate[i+1] != 1 {
+				continue
+			}
+
+			switch codecPrivate[i] {
+			case 1:
+				codec.Profile = codecPrivate[i+2]
+			case 3:
+				codec.BitDepth = codecPrivate[i+2]
+			case 4:
+				codec.ChromaSubsampling = codecPrivate[i+2]
+			}
+		}
+
+		t.Codec = codec
+
+	case codecID == "V_MPEG4/ISO/ASP":
+		t.Codec = &CodecMPEG4Video{Config: codecPrivate}
+
+	case codecID == "V_MPEG1", codecID == "V_MPEG2":
+		t.Codec = &CodecMPEG1Video{Config: codecPrivate}
+
+	case codecID == "V_MJPEG":
+		t.Codec = &CodecMJPEG{Width: width, Height: height}
+
+	case codecID == "A_OPUS":
+		if len(codecPrivate) >= 10 {
+			channelCount = int(codecPrivate[9])
+		}
+		t.Codec = &CodecOpus{ChannelCount: channelCount}
+
+	case codecID == "A_AAC":
+		var config mpeg4audio.AudioSpecificConfig
+		err = config.Unmarshal(codecPrivate)
+		if err != nil {
+			return fmt.Errorf("invalid MPEG-4 Audio configuration: %w", err)
+		}
+		t.Codec = &CodecMPEG4Audio{Config: config}
+
+	case strings.HasPrefix(codecID, "A_MPEG/L"):
+		layer, err2 := strconv.ParseUint(strings.TrimPrefix(codecID, "A_MPEG/L"), 10, 8)
+		if err2 != nil {
+			return errUnsupportedCodec
+		}
+		t.Codec = &CodecMPEG1Audio{
+			Layer:        int(layer),
+			SampleRate:   sampleRate,
+			ChannelCount: channelCount,
+		}
+
+	case codecID == "A_AC3":
+		t.Codec = &CodecAC3{
+			SampleRate:   sampleRate,
+			ChannelCount: channelCount,
+		}
+
+	case codecID == "A_PCM/INT/BIG", codecID == "A_PCM/INT/LIT":
+		t.Codec = &CodecLPCM{
+			LittleEndian: codecID == "A_PCM/INT/LIT",
+			BitDepth:     bitDepth,
+			SampleRate:   sampleRate,
+			ChannelCount: channelCount,
+		}
+
+	default:
+		return errUnsupportedCodec
+	}
+
+	return nil
+}
@@ -0,0 +1,307 @@
+package mkv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	timestampScale = time.Millisecond
+
+	// SeekHead with 4 entries and fixed-size positions.
+	seekHeadSize = 100
+
+	// Duration element with a 64-bit float.
+	durationSize = 11
+)
+
+var matroskaEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Tag is a global tag.
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// Block is a block.
+type Block struct {
+	TrackNumber int
+	// relative to the beginning of the segment
+	Timestamp time.Duration
+	Keyframe  bool
+	Payload   []byte
+}
+
+// Cluster is a group of blocks.
+type Cluster struct {
+	// relative to the beginning of the segment
+	Timestamp time.Duration
+	Blocks    []*Block
+}
+
+// Accepts checks whether a block with the given timestamp can be added to the cluster.
+func (c *Cluster) Accepts(ts time.Duration) bool {
+	rel := int64(ts/timestampScale) - int64(c.Timestamp/timestampScale)
+	return rel >= math.MinInt16 && rel <= math.MaxInt16
+}
+
+func (c *Cluster) marshal() ([]byte, error) {
+	clusterTS := int64(c.Timestamp / timestampScale)
+	if clusterTS < 0 {
+		return nil, fmt.Errorf("negative cluster timestamp")
+	}
+
+	var buf []byte
+	buf = appendUint(buf, idTimestamp, uint64(clusterTS))
+
+	for _, b := range c.Blocks {
+		if b.TrackNumber < 1 || b.TrackNumber > 126 {
+			return nil, fmt.Errorf("invalid track number: %d", b.TrackNumber)
+		}
+
+		rel := int64(b.Timestamp/timestampScale) - clusterTS
+		if rel < math.MinInt16 || rel > math.MaxInt16 {
+			return nil, fmt.Errorf("block timestamp is too far from cluster timestamp")
+		}
+
+		var flags byte
+		if b.Keyframe {
+			flags |= 0x80
+		}
+
+		buf = appendID(buf, idSimpleBlock)
+		buf = appendSize(buf, uint64(4+len(b.Payload)))
+		buf = append(buf, 0x80|byte(b.TrackNumber))
+		buf = binary.BigEndian.AppendUint16(buf, uint16(int16(rel)))
+		buf = append(buf, flags)
+		buf = append(buf, b.Payload...)
+	}
+
+	return appendMaster(nil, idCluster, buf), nil
+}
+
+type cuePoint struct {
+	timestamp       uint64
+	track           int
+	clusterPosition uint64
+}
+
+// Writer writes a Matroska file.
+// The segment has an unknown size until Close() is called,
+// therefore files are readable even when Close() is never called.
+type Writer struct {
+	W       io.WriteSeeker
+	DocType string
+	Date    time.Time
+	Tags    []Tag
+	Tracks  []*Track
+
+	pos              int64
+	segmentSizePos   int64
+	segmentDataStart int64
+	seekHeadPos      int64
+	durationPos      int64
+	infoPos          int64
+	tagsPos          int64
+	tracksPos        int64
+	cueTrack         int
+	cues             []cuePoint
+}
+
+func (w *Writer) write(buf []byte) error {
+	n, err := w.W.Write(buf)
+	w.pos += int64(n)
+	return err
+}
+
+// Initialize writes the file header.
+func (w *Writer) Initialize() error {
+	docType := w.DocType
+	if docType == "" {
+		docType = "matroska"
+	}
+
+	var ebml []byte
+	ebml = appendUint(ebml, idEBMLVersion, 1)
+	ebml = appendUint(ebml, idEBMLReadVersion, 1)
+	ebml = appendUint(ebml, idEBMLMaxIDLength, 4)
+	ebml = appendUint(ebml, idEBMLMaxSizeLength, 8)
+	ebml = appendString(ebml, idDocType, docType)
+	ebml = appendUint(ebml, idDocTypeVersion, 4)
+	ebml = appendUint(ebml, idDocTypeReadVersion, 2)
+
+	buf := appendMaster(nil, idEBML, ebml)
+
+	buf = appendID(buf, idSegment)
+	w.segmentSizePos = int64(len(buf))
+	buf = appendSizeN(buf, unknownSize, 8)
+	w.segmentDataStart = int64(len(buf))
+
+	// space for the SeekHead, which is written when closing
+	w.seekHeadPos = int64(len(buf))
+	buf = appendVoid(buf, seekHeadSize)
+
+	var info []byte
+	info = appendUint(info, idTimestampScale, uint64(timestampScale))
+	info = appendInt(info, idDateUTC, w.Date.Sub(matroskaEpoch).Nanoseconds())
+	info = appendString(info, idMuxingApp, "mediamtx")
+	info = appendString(info, idWritingApp, "mediamtx")
+	durationOffset := len(info)
+	// space for the Duration, which is written when closing
+	info = appendVoid(info, durationSize)
+
+	w.infoPos = int64(len(buf))
+	buf = appendID(buf, idInfo)
+	buf = appendSize(buf, uint64(len(info)))
+	w.durationPos = int64(len(buf) + durationOffset)
+	buf = append(buf, info...)
+
+	if len(w.Tags) != 0 {
+		tag := appendMaster(nil, idTargets, nil)
+
+		for _, t := range w.Tags {
+			var simpleTag []byte
+			simpleTag = appendString(simpleTag, idTagName, t.Name)
+			simpleTag = appendString(simpleTag, idTagString, t.Value)
+			tag = appendMaster(tag, idSimpleTag, simpleTag)
+		}
+
+		w.tagsPos = int64(len(buf))
+		buf = appendMaster(buf, idTags, appendMaster(nil, idTag, tag))
+	}
+
+	var tracks []byte
+	for _, t := range w.Tracks {
+		entry, err := t.marshal()
+		if err != nil {
+			return err
+		}
+		tracks = append(tracks, entry...)
+
+		if w.cueTrack == 0 || (t.Codec.IsVideo() && !w.cueTrackIsVideo()) {
+			w.cueTrack = t.Number
+		}
+	}
+
+	w.tracksPos = int64(len(buf))
+	buf = appendMaster(buf, idTracks, tracks)
+
+	return w.write(buf)
+}
+
+func (w *Writer) cueTrackIsVideo() bool {
+	for _, t := range w.Tracks {
+		if t.Number == w.cueTrack {
+			return t.Codec.IsVideo()
+		}
+	}
+	return false
+}
+
+// WriteCluster writes a cluster.
+func (w *Writer) WriteCluster(c *Cluster) error {
+	buf, err := c.marshal()
+	if err != nil {
+		return err
+	}
+
+	for _, b := range c.Blocks {
+		if b.TrackNumber == w.cueTrack && b.Keyframe {
+			w.cues = append(w.cues, cuePoint{
+				timestamp:       uint64(b.Timestamp / timestampScale),
+				track:           b.TrackNumber,
+				clusterPosition: uint64(w.pos - w.segmentDataStart),
+			})
+			break
+		}
+	}
+
+	return w.write(buf)
+}
+
+// Close writes cues and fills the segment size, the duration and the SeekHead.
+// It does not close the underlying writer.
+func (w *Writer) Close(duration time.Duration) error {
+	cuesPos := w.pos
+
+	var cues []byte
+	for _, c := range w.cues {
+		var positions []byte
+		positions = appendUint(positions, idCueTrack, uint64(c.track))
+		positions = appendUint(positions, idCueClusterPosition, c.clusterPosition)
+
+		var point []byte
+		point = appendUint(point, idCueTime, c.timestamp)
+		point = appendMaster(point, idCueTrackPositions, positions)
+
+		cues = appendMaster(cues, idCuePoint, point)
+	}
+
+	if cues != nil {
+		err := w.write(appendMaster(nil, idCues, cues))
+		if err != nil {
+			return err
+		}
+	}
+
+	end := w.pos
+
+	var seekHead []byte
+
+	appendSeek := func(id uint32, pos int64) {
+		var seek []byte
+		seek = appendMaster(seek, idSeekID, appendID(nil, id))
+		seek = appendID(seek, idSeekPosition)
+		seek = appendSize(seek, 8)
+		seek = binary.BigEndian.AppendUint64(seek, uint64(pos-w.segmentDataStart))
+		seekHead = appendMaster(seekHead, idSeek, seek)
+	}
+
+	appendSeek(idInfo, w.infoPos)
+	if w.tagsPos != 0 {
+		appendSeek(idTags, w.tagsPos)
+	}
+	appendSeek(idTracks, w.tracksPos)
+	if cues != nil {
+		appendSeek(idCues, cuesPos)
+	}
+
+	buf := appendMaster(nil, idSeekHead, seekHead)
+	buf = appendVoid(buf, seekHeadSize-len(buf))
+
+	_, err := w.W.Seek(w.seekHeadPos, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.W.Write(buf)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.W.Seek(w.durationPos, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.W.Write(appendFloat(nil, idDuration, float64(duration)/float64(timestampScale)))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.W.Seek(w.segmentSizePos, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.W.Write(appendSizeN(nil, uint64(end-w.segmentDataStart), 8))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.W.Seek(end, io.SeekStart)
+	return err
+}
@@ -1,8 +1,11 @@
 package mpegts
 
 import (
+	"bytes"
+	"context"
 	"io"
 
+	"github.com/asticode/go-astits"
 	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
 	mcmpegts "github.com/bluenviron/mediacommon/v2/pkg/formats/mpegts"
 	"github.com/bluenviron/mediacommon/v2/pkg/rewindablereader"
@@ -17,11 +20,49 @@ type EnhancedReader struct {
 	*mcmpegts.Reader
 
 	latmConfigs map[uint16]*mpeg4audio.StreamMuxConfig
+	languages   map[uint16]string
+}
+
+// readLanguages reads languages of elementary streams from the first program map table.
+func readLanguages(r io.Reader) map[uint16]string {
+	ret := make(map[uint16]string)
+
+	dem := astits.NewDemuxer(context.Background(), r, astits.DemuxerOptPacketSize(188))
+
+	for {
+		data, err := dem.NextData()
+		if err != nil {
+			return ret
+		}
+
+		if data.PMT == nil {
+			continue
+		}
+
+		for _, es := range data.PMT.ElementaryStreams {
+			for _, d := range es.ElementaryStreamDescriptors {
+				if d.Tag == astits.DescriptorTagISO639LanguageAndAudioType && d.ISO639LanguageAndAudioType != nil {
+					lang := string(bytes.TrimRight(d.ISO639LanguageAndAudioType.Language, "\x00 "))
+					if lang != "" {
+						ret[es.ElementaryPID] = lang
+						break
+					}
+				}
+			}
+		}
+
+		return ret
+	}
 }
 
 // Initialize initializes EnhancedReader.
 func (r *EnhancedReader) Initialize() error {
-	rr := &rewindablereader.Reader{R: r.R}
+	// languages are not provided by mcmpegts.Reader
+	lr := &rewindablereader.Reader{R: r.R}
+	r.languages = readLanguages(lr)
+	lr.Rewind()
+
+	rr := &rewindablereader.Reader{R: lr}
 	mr := &mcmpegts.Reader{R: rr}
 	err := mr.Initialize()
 	if err != nil {
@@ -21,12 +21,14 @@ var errNoSupportedCodecs = errors.New(
 		"H265, H264, MPEG-4 Video, MPEG-1/2 Video, Opus, MPEG-4 Audio, MPEG-1 Audio, AC-3")
 
 // ToStream maps a MPEG-TS stream to a MediaMTX stream.
+// It returns the medias of the stream and their PIDs and languages.
 func ToStream(
 	r *EnhancedReader,
 	strm **stream.Stream,
 	l logger.Writer,
-) ([]*description.Media, error) {
+) ([]*description.Media, map[*description.Media]*stream.MediaProperties, error) {
 	var medias []*description.Media //nolint:prealloc
+	props := make(map[*description.Media]*stream.MediaProperties)
 	var unsupportedTracks []int
 
 	td := &mpegts.TimeDecoder{}
@@ -262,15 +264,19 @@ func ToStream(
 		}
 
 		medias = append(medias, medi)
+		props[medi] = &stream.MediaProperties{
+			PID:      track.PID,
+			Language: r.languages[track.PID],
+		}
 	}
 
 	if len(medias) == 0 {
-		return nil, errNoSupportedCodecs
+		return nil, nil, errNoSupportedCodecs
 	}
 
 	for _, id := range unsupportedTracks {
 		l.Log(logger.Warn, "skipping track %d (unsupported codec)", id)
 	}
 
-	return medias, nil
+	return medias, props, nil
 }
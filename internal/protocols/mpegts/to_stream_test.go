@@ -11,6 +11,7 @@ import (
 	"github.com/bluenviron/gortsplib/v5/pkg/format"
 	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/stream"
 	"github.com/bluenviron/mediamtx/internal/test"
 	"github.com/stretchr/testify/require"
 )
@@ -103,7 +104,7 @@ func TestToStream(t *testing.T) {
 			err := r.Initialize()
 			require.NoError(t, err)
 
-			desc, err := ToStream(r, nil, nil)
+			desc, _, err := ToStream(r, nil, nil)
 			require.NoError(t, err)
 
 			switch ca {
@@ -171,7 +172,7 @@ func TestToStreamNoSupportedCodecs(t *testing.T) {
 	l := test.Logger(func(logger.Level, string, ...interface{}) {
 		t.Error("should not happen")
 	})
-	_, err = ToStream(r, nil, l)
+	_, _, err = ToStream(r, nil, l)
 	require.Equal(t, errNoSupportedCodecs, err)
 }
 
@@ -210,6 +211,55 @@ func TestToStreamSkipUnsupportedTracks(t *testing.T) {
 		n++
 	})
 
-	_, err = ToStream(r, nil, l)
+	_, _, err = ToStream(r, nil, l)
 	require.NoError(t, err)
 }
+
+func TestToStreamMediaProperties(t *testing.T) {
+	var buf bytes.Buffer
+	mux := astits.NewMuxer(context.Background(), &buf)
+
+	err := mux.AddElementaryStream(astits.PMTElementaryStream{
+		ElementaryPID: 256,
+		StreamType:    astits.StreamTypeH264Video,
+	})
+	require.NoError(t, err)
+
+	for _, ca := range []struct {
+		pid  uint16
+		lang string
+	}{
+		{257, "eng"},
+		{258, "ita"},
+	} {
+		err = mux.AddElementaryStream(astits.PMTElementaryStream{
+			ElementaryPID: ca.pid,
+			StreamType:    astits.StreamTypeMPEG1Audio,
+			ElementaryStreamDescriptors: []*astits.Descriptor{{
+				Tag:    astits.DescriptorTagISO639LanguageAndAudioType,
+				Length: 4,
+				ISO639LanguageAndAudioType: &astits.DescriptorISO639LanguageAndAudioType{
+					Language: []byte(ca.lang),
+				},
+			}},
+		})
+		require.NoError(t, err)
+	}
+
+	mux.SetPCRPID(256)
+
+	_, err = mux.WriteTables()
+	require.NoError(t, err)
+
+	r := &EnhancedReader{R: &buf}
+	err = r.Initialize()
+	require.NoError(t, err)
+
+	medias, props, err := ToStream(r, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, medias, 3)
+
+	require.Equal(t, &stream.MediaProperties{PID: 256}, props[medias[0]])
+	require.Equal(t, &stream.MediaProperties{PID: 257, Language: "eng"}, props[medias[1]])
+	require.Equal(t, &stream.MediaProperties{PID: 258, Language: "ita"}, props[medias[2]])
+}
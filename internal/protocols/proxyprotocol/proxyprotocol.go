@@ -0,0 +1,52 @@
+// Package proxyprotocol contains utilities to read PROXY protocol headers.
+package proxyprotocol
+
+import (
+	"net"
+	"time"
+
+	"github.com/pires/go-proxyproto"
+)
+
+func isTrusted(addr net.Addr, sources []net.IPNet) bool {
+	var ip net.IP
+
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+	case *net.UDPAddr:
+		ip = addr.IP
+	default:
+		return false
+	}
+
+	for _, n := range sources {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Wrap wraps a listener in order to read PROXY protocol headers (v1 and v2)
+// sent by trusted sources. Headers allow to replace the remote address of
+// connections with the one of the original client.
+// Connections from other sources are left untouched.
+// If there are no trusted sources, the listener is returned as is.
+func Wrap(ln net.Listener, sources []net.IPNet, readTimeout time.Duration) net.Listener {
+	if len(sources) == 0 {
+		return ln
+	}
+
+	return &proxyproto.Listener{
+		Listener: ln,
+		ConnPolicy: func(opts proxyproto.ConnPolicyOptions) (proxyproto.Policy, error) {
+			if isTrusted(opts.Upstream, sources) {
+				return proxyproto.USE, nil
+			}
+			return proxyproto.SKIP, nil
+		},
+		ReadHeaderTimeout: readTimeout,
+	}
+}
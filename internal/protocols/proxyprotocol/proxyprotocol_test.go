@@ -0,0 +1,84 @@
+package proxyprotocol
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pires/go-proxyproto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrap(t *testing.T) {
+	for _, ca := range []string{"v1", "v2", "untrusted"} {
+		t.Run(ca, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:9123")
+			require.NoError(t, err)
+
+			var sources []net.IPNet
+			if ca == "untrusted" {
+				_, n, _ := net.ParseCIDR("10.0.0.0/8")
+				sources = []net.IPNet{*n}
+			} else {
+				_, n, _ := net.ParseCIDR("127.0.0.0/8")
+				sources = []net.IPNet{*n}
+			}
+
+			ln = Wrap(ln, sources, 10*time.Second)
+			defer ln.Close()
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+
+				nconn, err2 := net.Dial("tcp", "127.0.0.1:9123")
+				require.NoError(t, err2)
+				defer nconn.Close()
+
+				if ca != "untrusted" {
+					h := &proxyproto.Header{
+						Version:           1,
+						Command:           proxyproto.PROXY,
+						TransportProtocol: proxyproto.TCPv4,
+						SourceAddr:        &net.TCPAddr{IP: net.ParseIP("1.2.3.4"), Port: 1000},
+						DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("5.6.7.8"), Port: 2000},
+					}
+					if ca == "v2" {
+						h.Version = 2
+					}
+					_, err2 = h.WriteTo(nconn)
+					require.NoError(t, err2)
+				}
+
+				_, err2 = nconn.Write([]byte("hello"))
+				require.NoError(t, err2)
+			}()
+
+			nconn, err := ln.Accept()
+			require.NoError(t, err)
+			defer nconn.Close()
+
+			if ca == "untrusted" {
+				require.Equal(t, "127.0.0.1", nconn.RemoteAddr().(*net.TCPAddr).IP.String())
+			} else {
+				require.Equal(t, "1.2.3.4:1000", nconn.RemoteAddr().String())
+			}
+
+			buf := make([]byte, 5)
+			_, err = io.ReadFull(nconn, buf)
+			require.NoError(t, err)
+			require.Equal(t, []byte("hello"), buf)
+
+			<-done
+		})
+	}
+}
+
+func TestWrapDisabled(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:9123")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	require.Equal(t, ln, Wrap(ln, nil, 10*time.Second))
+}
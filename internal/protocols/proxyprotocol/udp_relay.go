@@ -0,0 +1,270 @@
+package proxyprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pires/go-proxyproto"
+)
+
+const (
+	udpMaxDatagramSize = 65535
+	udpDefaultMaxFlows = 1024
+)
+
+var signatureV2 = []byte{'\r', '\n', '\r', '\n', 0x00, '\r', '\n', 'Q', 'U', 'I', 'T', '\n'}
+
+// readHeaderV2 reads a PROXY protocol v2 header at the beginning of a datagram.
+// It returns the address of the client, or nil if the header does not contain
+// one, and the payload that follows the header.
+func readHeaderV2(buf []byte) (*net.UDPAddr, []byte, error) {
+	if len(buf) < 16 {
+		return nil, nil, fmt.Errorf("header is too short")
+	}
+
+	l := 16 + int(binary.BigEndian.Uint16(buf[14:16]))
+	if len(buf) < l {
+		return nil, nil, fmt.Errorf("header is too short")
+	}
+
+	h, err := proxyproto.Read(bufio.NewReaderSize(bytes.NewReader(buf[:l]), l))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !h.Command.IsProxy() {
+		return nil, buf[l:], nil
+	}
+
+	ip, _, ok := h.IPs()
+	if !ok {
+		return nil, buf[l:], nil
+	}
+
+	port, _, ok := h.Ports()
+	if !ok {
+		return nil, buf[l:], nil
+	}
+
+	return &net.UDPAddr{IP: ip, Port: port}, buf[l:], nil
+}
+
+type udpRelayFlow struct {
+	peer         *net.UDPAddr
+	client       *net.UDPAddr
+	conn         *net.UDPConn
+	lastReceived *int64
+}
+
+// UDPRelay receives datagrams on a public UDP address, reads PROXY protocol v2
+// headers sent by trusted sources and forwards payloads to a target address,
+// through a dedicated socket for each peer.
+// This allows to read PROXY protocol headers with servers that open their own
+// UDP sockets: the client address of each peer can be obtained by passing the
+// remote address seen by the server to ClientAddr().
+// Datagrams from other sources are forwarded without changes.
+// Datagrams from new peers are discarded when MaxFlows peers are active.
+type UDPRelay struct {
+	Address     string
+	Target      *net.UDPAddr
+	Sources     []net.IPNet
+	ReadTimeout time.Duration
+	MaxFlows    int // defaults to 1024
+
+	pc      *net.UDPConn
+	wg      sync.WaitGroup
+	mutex   sync.RWMutex
+	flows   map[string]*udpRelayFlow
+	byLocal map[string]*udpRelayFlow
+	closed  bool
+}
+
+// Initialize initializes UDPRelay.
+func (r *UDPRelay) Initialize() error {
+	addr, err := net.ResolveUDPAddr("udp", r.Address)
+	if err != nil {
+		return err
+	}
+
+	r.pc, err = net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	if r.MaxFlows == 0 {
+		r.MaxFlows = udpDefaultMaxFlows
+	}
+
+	r.flows = make(map[string]*udpRelayFlow)
+	r.byLocal = make(map[string]*udpRelayFlow)
+
+	r.wg.Add(1)
+	go r.run()
+
+	return nil
+}
+
+// Close closes UDPRelay.
+func (r *UDPRelay) Close() {
+	r.pc.Close()
+
+	r.mutex.Lock()
+	r.closed = true
+	for _, f := range r.flows {
+		f.conn.Close()
+	}
+	r.mutex.Unlock()
+
+	r.wg.Wait()
+}
+
+// LocalAddr returns the public address of the relay.
+func (r *UDPRelay) LocalAddr() net.Addr {
+	return r.pc.LocalAddr()
+}
+
+// ClientAddr returns the address of the client whose datagrams are forwarded
+// to the target from the given address.
+// If the address does not belong to the relay, it is returned as is.
+func (r *UDPRelay) ClientAddr(addr net.Addr) net.Addr {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if f, ok := r.byLocal[addr.String()]; ok {
+		return f.client
+	}
+	return addr
+}
+
+func (r *UDPRelay) run() {
+	defer r.wg.Done()
+
+	buf := make([]byte, udpMaxDatagramSize)
+
+	for {
+		n, peer, err := r.pc.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		r.handleDatagram(buf[:n], peer)
+	}
+}
+
+func (r *UDPRelay) handleDatagram(buf []byte, peer *net.UDPAddr) {
+	var client *net.UDPAddr
+
+	// load balancers may send the header with the first datagram only
+	// or with all datagrams.
+	if isTrusted(peer, r.Sources) && bytes.HasPrefix(buf, signatureV2) {
+		var err error
+		client, buf, err = readHeaderV2(buf)
+		if err != nil {
+			return
+		}
+	}
+
+	f := r.findOrCreateFlow(peer, client)
+	if f == nil {
+		return
+	}
+
+	atomic.StoreInt64(f.lastReceived, time.Now().UnixNano())
+
+	f.conn.Write(buf) //nolint:errcheck
+}
+
+func (r *UDPRelay) findOrCreateFlow(peer *net.UDPAddr, client *net.UDPAddr) *udpRelayFlow {
+	key := peer.String()
+
+	r.mutex.RLock()
+	f, ok := r.flows[key]
+	full := len(r.flows) >= r.MaxFlows
+	r.mutex.RUnlock()
+
+	if ok {
+		// the header may be received after the first datagram of the peer
+		if client != nil {
+			r.mutex.Lock()
+			f.client = client
+			r.mutex.Unlock()
+		}
+		return f
+	}
+
+	if full {
+		return nil
+	}
+
+	if client == nil {
+		client = peer
+	}
+
+	conn, err := net.DialUDP("udp", nil, r.Target)
+	if err != nil {
+		return nil
+	}
+
+	f = &udpRelayFlow{
+		peer:         peer,
+		client:       client,
+		conn:         conn,
+		lastReceived: new(int64),
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		conn.Close()
+		return nil
+	}
+
+	r.flows[key] = f
+	r.byLocal[conn.LocalAddr().String()] = f
+
+	r.wg.Add(1)
+	go r.runFlow(f)
+
+	return f
+}
+
+func (r *UDPRelay) runFlow(f *udpRelayFlow) {
+	defer r.wg.Done()
+	defer r.removeFlow(f)
+
+	buf := make([]byte, udpMaxDatagramSize)
+
+	for {
+		f.conn.SetReadDeadline(time.Now().Add(r.ReadTimeout)) //nolint:errcheck
+		n, err := f.conn.Read(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() &&
+				time.Since(time.Unix(0, atomic.LoadInt64(f.lastReceived))) < r.ReadTimeout {
+				continue
+			}
+			return
+		}
+
+		r.pc.WriteToUDP(buf[:n], f.peer) //nolint:errcheck
+	}
+}
+
+func (r *UDPRelay) removeFlow(f *udpRelayFlow) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.flows[f.peer.String()] == f {
+		delete(r.flows, f.peer.String())
+	}
+	delete(r.byLocal, f.conn.LocalAddr().String())
+	f.conn.Close()
+}
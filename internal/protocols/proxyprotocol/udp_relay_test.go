@@ -0,0 +1,181 @@
+package proxyprotocol
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pires/go-proxyproto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUDPRelay(t *testing.T) {
+	for _, ca := range []string{"trusted", "untrusted"} {
+		t.Run(ca, func(t *testing.T) {
+			target, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+			require.NoError(t, err)
+			defer target.Close()
+
+			var sources []net.IPNet
+			if ca == "untrusted" {
+				_, n, _ := net.ParseCIDR("10.0.0.0/8")
+				sources = []net.IPNet{*n}
+			} else {
+				_, n, _ := net.ParseCIDR("127.0.0.0/8")
+				sources = []net.IPNet{*n}
+			}
+
+			r := &UDPRelay{
+				Address:     "127.0.0.1:9124",
+				Target:      target.LocalAddr().(*net.UDPAddr),
+				Sources:     sources,
+				ReadTimeout: 10 * time.Second,
+			}
+			err = r.Initialize()
+			require.NoError(t, err)
+			defer r.Close()
+
+			client, err := net.DialUDP("udp", nil, r.LocalAddr().(*net.UDPAddr))
+			require.NoError(t, err)
+			defer client.Close()
+
+			h := &proxyproto.Header{
+				Version:           2,
+				Command:           proxyproto.PROXY,
+				TransportProtocol: proxyproto.UDPv4,
+				SourceAddr:        &net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: 1000},
+				DestinationAddr:   &net.UDPAddr{IP: net.ParseIP("5.6.7.8"), Port: 2000},
+			}
+			var buf bytes.Buffer
+			_, err = h.WriteTo(&buf)
+			require.NoError(t, err)
+			header := buf.Bytes()
+
+			// header with the first datagram only
+			_, err = client.Write(append(append([]byte(nil), header...), []byte("hello")...))
+			require.NoError(t, err)
+			_, err = client.Write([]byte("again"))
+			require.NoError(t, err)
+
+			recv := make([]byte, 1500)
+
+			n, addr, err := target.ReadFrom(recv)
+			require.NoError(t, err)
+
+			if ca == "untrusted" {
+				require.Equal(t, append(append([]byte(nil), header...), []byte("hello")...), recv[:n])
+				require.Equal(t, client.LocalAddr().String(), r.ClientAddr(addr).String())
+			} else {
+				require.Equal(t, []byte("hello"), recv[:n])
+				require.Equal(t, "1.2.3.4:1000", r.ClientAddr(addr).String())
+			}
+
+			n, addr2, err := target.ReadFrom(recv)
+			require.NoError(t, err)
+			require.Equal(t, []byte("again"), recv[:n])
+			require.Equal(t, addr.String(), addr2.String())
+
+			_, err = target.WriteTo([]byte("world"), addr)
+			require.NoError(t, err)
+
+			n, err = client.Read(recv)
+			require.NoError(t, err)
+			require.Equal(t, []byte("world"), recv[:n])
+		})
+	}
+}
+
+func TestUDPRelayLateHeader(t *testing.T) {
+	target, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+	defer target.Close()
+
+	_, n, _ := net.ParseCIDR("127.0.0.0/8")
+
+	r := &UDPRelay{
+		Address:     "127.0.0.1:9124",
+		Target:      target.LocalAddr().(*net.UDPAddr),
+		Sources:     []net.IPNet{*n},
+		ReadTimeout: 10 * time.Second,
+	}
+	err = r.Initialize()
+	require.NoError(t, err)
+	defer r.Close()
+
+	client, err := net.DialUDP("udp", nil, r.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	recv := make([]byte, 1500)
+
+	_, addr, err := target.ReadFrom(recv)
+	require.NoError(t, err)
+	require.Equal(t, client.LocalAddr().String(), r.ClientAddr(addr).String())
+
+	h := &proxyproto.Header{
+		Version:           2,
+		Command:           proxyproto.PROXY,
+		TransportProtocol: proxyproto.UDPv4,
+		SourceAddr:        &net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: 1000},
+		DestinationAddr:   &net.UDPAddr{IP: net.ParseIP("5.6.7.8"), Port: 2000},
+	}
+	var buf bytes.Buffer
+	_, err = h.WriteTo(&buf)
+	require.NoError(t, err)
+
+	_, err = client.Write(append(buf.Bytes(), []byte("again")...))
+	require.NoError(t, err)
+
+	n2, addr2, err := target.ReadFrom(recv)
+	require.NoError(t, err)
+	require.Equal(t, []byte("again"), recv[:n2])
+	require.Equal(t, addr.String(), addr2.String())
+	require.Equal(t, "1.2.3.4:1000", r.ClientAddr(addr).String())
+}
+
+func TestUDPRelayMaxFlows(t *testing.T) {
+	target, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+	defer target.Close()
+
+	r := &UDPRelay{
+		Address:     "127.0.0.1:9124",
+		Target:      target.LocalAddr().(*net.UDPAddr),
+		ReadTimeout: 10 * time.Second,
+		MaxFlows:    1,
+	}
+	err = r.Initialize()
+	require.NoError(t, err)
+	defer r.Close()
+
+	client1, err := net.DialUDP("udp", nil, r.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer client1.Close()
+
+	client2, err := net.DialUDP("udp", nil, r.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer client2.Close()
+
+	_, err = client1.Write([]byte("first"))
+	require.NoError(t, err)
+
+	recv := make([]byte, 1500)
+
+	n, _, err := target.ReadFrom(recv)
+	require.NoError(t, err)
+	require.Equal(t, []byte("first"), recv[:n])
+
+	_, err = client2.Write([]byte("discarded"))
+	require.NoError(t, err)
+
+	_, err = client1.Write([]byte("second"))
+	require.NoError(t, err)
+
+	n, _, err = target.ReadFrom(recv)
+	require.NoError(t, err)
+	require.Equal(t, []byte("second"), recv[:n])
+}
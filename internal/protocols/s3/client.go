@@ -0,0 +1,259 @@
+// Package s3 contains a client for S3-compatible object storages.
+package s3
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when an object doesn't exist.
+var ErrNotFound = errors.New("object not found")
+
+var timeNow = time.Now
+
+type errorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// Client is a client for S3-compatible object storages.
+// Buckets are addressed with path-style URLs, that are supported by most implementations.
+type Client struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	HTTPClient      *http.Client
+
+	endpoint *url.URL
+}
+
+// Initialize initializes Client.
+func (c *Client) Initialize() error {
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme: '%s'", u.Scheme)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	c.endpoint = u
+
+	if c.HTTPClient == nil {
+		c.HTTPClient = http.DefaultClient
+	}
+
+	return nil
+}
+
+func (c *Client) bucketURL() *url.URL {
+	u := *c.endpoint
+	u.Path += "/" + c.Bucket
+	return &u
+}
+
+func (c *Client) objectURL(key string) *url.URL {
+	u := c.bucketURL()
+	u.Path += "/" + strings.TrimPrefix(key, "/")
+	return u
+}
+
+func (c *Client) do(
+	ctx context.Context,
+	method string,
+	u *url.URL,
+	header http.Header,
+	body io.Reader,
+	contentLength int64,
+) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	req.ContentLength = contentLength
+
+	if body == nil {
+		req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+	} else {
+		req.Header.Set("x-amz-content-sha256", unsignedPayload)
+	}
+
+	sign(req, c.Region, c.AccessKeyID, c.SecretAccessKey, timeNow())
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+
+		if res.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+
+		var e errorResponse
+		if err = xml.NewDecoder(res.Body).Decode(&e); err == nil && e.Code != "" {
+			return nil, fmt.Errorf("bad status code: %d (%s: %s)", res.StatusCode, e.Code, e.Message)
+		}
+
+		return nil, fmt.Errorf("bad status code: %d", res.StatusCode)
+	}
+
+	return res, nil
+}
+
+// PutObject uploads an object.
+// Integrity of the upload is checked by the storage through Content-MD5.
+func (c *Client) PutObject(ctx context.Context, key string, r io.ReadSeeker) error {
+	h := md5.New() //nolint:gosec
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	sum := h.Sum(nil)
+
+	res, err := c.do(ctx, http.MethodPut, c.objectURL(key), http.Header{
+		"Content-Md5": []string{base64.StdEncoding.EncodeToString(sum)},
+	}, io.NopCloser(r), size)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// ETag of objects uploaded in a single part is their MD5 hash.
+	etag := strings.Trim(res.Header.Get("ETag"), "\"")
+	if etag != "" && etag != hex.EncodeToString(sum) {
+		return fmt.Errorf("ETag mismatch: expected %s, got %s", hex.EncodeToString(sum), etag)
+	}
+
+	return nil
+}
+
+// HeadObject returns the size of an object.
+func (c *Client) HeadObject(ctx context.Context, key string) (int64, error) {
+	res, err := c.do(ctx, http.MethodHead, c.objectURL(key), nil, nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+
+	return res.ContentLength, nil
+}
+
+// GetObjectRange reads a range of an object.
+func (c *Client) GetObjectRange(ctx context.Context, key string, offset int64, length int64) ([]byte, error) {
+	res, err := c.do(ctx, http.MethodGet, c.objectURL(key), http.Header{
+		"Range": []string{"bytes=" + strconv.FormatInt(offset, 10) + "-" + strconv.FormatInt(offset+length-1, 10)},
+	}, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("range requests are not supported")
+	}
+
+	return io.ReadAll(io.LimitReader(res.Body, length))
+}
+
+// DeleteObject deletes an object.
+func (c *Client) DeleteObject(ctx context.Context, key string) error {
+	res, err := c.do(ctx, http.MethodDelete, c.objectURL(key), nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+// ListObjects returns the keys of all objects that start with given prefix.
+func (c *Client) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+
+	for {
+		u := c.bucketURL()
+		q := url.Values{}
+		q.Set("list-type", "2")
+		q.Set("prefix", prefix)
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = q.Encode()
+
+		res, err := c.do(ctx, http.MethodGet, u, nil, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		var l listBucketResult
+		err = xml.NewDecoder(res.Body).Decode(&l)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, content := range l.Contents {
+			keys = append(keys, content.Key)
+		}
+
+		if !l.IsTruncated || l.NextContinuationToken == "" {
+			break
+		}
+		token = l.NextContinuationToken
+	}
+
+	return keys, nil
+}
+
+// Open opens an object for reading.
+func (c *Client) Open(ctx context.Context, key string) (*Object, error) {
+	size, err := c.HeadObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Object{
+		ctx:  ctx,
+		c:    c,
+		key:  key,
+		size: size,
+	}, nil
+}
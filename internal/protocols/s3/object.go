@@ -0,0 +1,126 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// objects are read in chunks, in order to reduce the number of requests.
+const objectChunkSize = 1024 * 1024
+
+// Object is an object opened for reading.
+// It implements io.Reader, io.Seeker and io.ReaderAt.
+type Object struct {
+	ctx  context.Context
+	c    *Client
+	key  string
+	size int64
+
+	pos        int64
+	chunk      []byte
+	chunkStart int64
+}
+
+// Size returns the size of the object.
+func (o *Object) Size() int64 {
+	return o.size
+}
+
+// Close implements io.Closer.
+func (o *Object) Close() error {
+	o.chunk = nil
+	return nil
+}
+
+func (o *Object) loadChunk(pos int64) error {
+	start := pos - (pos % objectChunkSize)
+
+	if o.chunk != nil && o.chunkStart == start {
+		return nil
+	}
+
+	length := int64(objectChunkSize)
+	if (start + length) > o.size {
+		length = o.size - start
+	}
+
+	buf, err := o.c.GetObjectRange(o.ctx, o.key, start, length)
+	if err != nil {
+		return err
+	}
+
+	if int64(len(buf)) != length {
+		return io.ErrUnexpectedEOF
+	}
+
+	o.chunk = buf
+	o.chunkStart = start
+
+	return nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (o *Object) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	n := 0
+
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= o.size {
+			return n, io.EOF
+		}
+
+		err := o.loadChunk(pos)
+		if err != nil {
+			return n, err
+		}
+
+		n += copy(p[n:], o.chunk[pos-o.chunkStart:])
+	}
+
+	return n, nil
+}
+
+// Read implements io.Reader.
+func (o *Object) Read(p []byte) (int, error) {
+	if o.pos >= o.size {
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > (o.size - o.pos) {
+		p = p[:o.size-o.pos]
+	}
+
+	n, err := o.ReadAt(p, o.pos)
+	o.pos += int64(n)
+
+	if errors.Is(err, io.EOF) && n != 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (o *Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += o.pos
+	case io.SeekEnd:
+		offset += o.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	o.pos = offset
+	return o.pos, nil
+}
@@ -0,0 +1,144 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	signAlgorithm = "AWS4-HMAC-SHA256"
+	timeFormat    = "20060102T150405Z"
+	dateFormat    = "20060102"
+
+	// SHA256 hash of an empty payload.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// payloads are not hashed, since integrity of uploads is checked with Content-MD5.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data string) string {
+	h := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(h[:])
+}
+
+// uriEncode encodes a string as described in the AWS Signature Version 4 specification.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~':
+			b.WriteByte(c)
+
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+
+		default:
+			b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+
+	return b.String()
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+
+		for _, v := range vals {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// sign signs a request with the AWS Signature Version 4 algorithm.
+// Header x-amz-content-sha256 must be already set.
+func sign(req *http.Request, region string, accessKeyID string, secretAccessKey string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("x-amz-date", now.Format(timeFormat))
+
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "x-amz-") || k == "range" || k == "content-md5" || k == "content-type" {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+
+	headerNames := make([]string, 0, len(headers))
+	for k := range headers {
+		headerNames = append(headerNames, k)
+	}
+	sort.Strings(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range headerNames {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+
+	signedHeaders := strings.Join(headerNames, ";")
+
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("x-amz-content-sha256"),
+	}, "\n")
+
+	scope := now.Format(dateFormat) + "/" + region + "/s3/aws4_request"
+
+	stringToSign := strings.Join([]string{
+		signAlgorithm,
+		now.Format(timeFormat),
+		scope,
+		sha256Hex(canonicalRequest),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), now.Format(dateFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", signAlgorithm+" "+
+		"Credential="+accessKeyID+"/"+scope+", "+
+		"SignedHeaders="+signedHeaders+", "+
+		"Signature="+signature)
+}
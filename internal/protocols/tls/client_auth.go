@@ -0,0 +1,65 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+)
+
+// ConfigureClientAuth enables verification of client certificates
+// against the CA bundle contained in caPath.
+// Clients that do not provide a certificate are still accepted.
+func ConfigureClientAuth(conf *tls.Config, caPath string) error {
+	if caPath == "" {
+		return nil
+	}
+
+	byts, err := os.ReadFile(caPath)
+	if err != nil {
+		return err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(byts) {
+		return fmt.Errorf("no certificates found in '%s'", caPath)
+	}
+
+	conf.ClientCAs = pool
+	conf.ClientAuth = tls.VerifyClientCertIfGiven
+
+	return nil
+}
+
+// ClientIdentities returns the identities contained in a verified client certificate:
+// the subject common name, then DNS names, email addresses and URIs.
+func ClientIdentities(cs tls.ConnectionState) []string {
+	if len(cs.VerifiedChains) == 0 || len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+
+	cert := cs.PeerCertificates[0]
+	var ret []string
+
+	if cert.Subject.CommonName != "" {
+		ret = append(ret, cert.Subject.CommonName)
+	}
+
+	ret = append(ret, cert.DNSNames...)
+	ret = append(ret, cert.EmailAddresses...)
+
+	for _, u := range cert.URIs {
+		ret = append(ret, u.String())
+	}
+
+	return ret
+}
+
+// ConnClientIdentities returns the client identities of a connection, if it uses TLS.
+func ConnClientIdentities(nconn net.Conn) []string {
+	if tnconn, ok := nconn.(*tls.Conn); ok {
+		return ClientIdentities(tnconn.ConnectionState())
+	}
+	return nil
+}
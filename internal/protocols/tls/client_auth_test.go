@@ -0,0 +1,88 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientAuth(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mydevice"},
+		DNSNames:              []string{"mydevice.local"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	caPath := filepath.Join(t.TempDir(), "ca.crt")
+	err = os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+	require.NoError(t, err)
+
+	serverCert, err := tls.X509KeyPair(testTLSCertPub, testTLSCertKey)
+	require.NoError(t, err)
+
+	serverConf := &tls.Config{Certificates: []tls.Certificate{serverCert}}
+	err = ConfigureClientAuth(serverConf, caPath)
+	require.NoError(t, err)
+
+	l, err := tls.Listen("tcp", "localhost:8557", serverConf)
+	require.NoError(t, err)
+	defer l.Close()
+
+	serverDone := make(chan struct{})
+	defer func() { <-serverDone }()
+
+	go func() {
+		defer close(serverDone)
+
+		nconn, err2 := l.Accept()
+		require.NoError(t, err2)
+		defer nconn.Close()
+
+		err2 = nconn.(*tls.Conn).Handshake()
+		require.NoError(t, err2)
+
+		require.Equal(t, []string{"mydevice", "mydevice.local"}, ConnClientIdentities(nconn))
+	}()
+
+	conn, err := tls.Dial("tcp", "localhost:8557", &tls.Config{
+		InsecureSkipVerify: true,
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{der},
+			PrivateKey:  key,
+		}},
+	})
+	require.NoError(t, err)
+	defer conn.Close() //nolint:errcheck
+
+	err = conn.Handshake()
+	require.NoError(t, err)
+}
+
+func TestClientIdentitiesNotVerified(t *testing.T) {
+	require.Nil(t, ClientIdentities(tls.ConnectionState{}))
+	require.Nil(t, ConnClientIdentities(&net.TCPConn{}))
+}
@@ -0,0 +1,55 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// MakeClientConfig returns a tls.Config suitable for connecting to a server, with:
+// - server name indicator (SNI) support
+// - fingerprint support
+// - validation of the server certificate against a CA bundle
+// - a client certificate, loaded from disk at every handshake in order to support rotation
+func MakeClientConfig(
+	serverName string,
+	fingerprint string,
+	caPath string,
+	certPath string,
+	keyPath string,
+) (*tls.Config, error) {
+	conf := MakeConfig(serverName, fingerprint)
+
+	if caPath != "" {
+		byts, err := os.ReadFile(caPath)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(byts) {
+			return nil, fmt.Errorf("no certificates found in '%s'", caPath)
+		}
+
+		conf.RootCAs = pool
+	}
+
+	if certPath != "" {
+		// check that the certificate can be loaded
+		_, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		conf.GetClientCertificate = func(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err2 := tls.LoadX509KeyPair(certPath, keyPath)
+			if err2 != nil {
+				return nil, err2
+			}
+			return &cert, nil
+		}
+	}
+
+	return conf, nil
+}
@@ -0,0 +1,113 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestCert(t *testing.T, dir string, name string, commonName string) (tls.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              []string{commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath := filepath.Join(dir, name+".crt")
+	err = os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+	require.NoError(t, err)
+
+	keyPath := filepath.Join(dir, name+".key")
+	err = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o644)
+	require.NoError(t, err)
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	require.NoError(t, err)
+
+	return cert, certPath, keyPath
+}
+
+func TestMakeClientConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	serverCert, serverCertPath, _ := writeTestCert(t, dir, "server", "localhost")
+	_, clientCertPath, clientKeyPath := writeTestCert(t, dir, "client", "mydevice")
+
+	serverConf := &tls.Config{Certificates: []tls.Certificate{serverCert}}
+	err := ConfigureClientAuth(serverConf, clientCertPath)
+	require.NoError(t, err)
+	serverConf.ClientAuth = tls.RequireAndVerifyClientCert
+
+	l, err := tls.Listen("tcp", "localhost:8558", serverConf)
+	require.NoError(t, err)
+	defer l.Close()
+
+	serverDone := make(chan struct{})
+	defer func() { <-serverDone }()
+
+	go func() {
+		defer close(serverDone)
+
+		nconn, err2 := l.Accept()
+		require.NoError(t, err2)
+		defer nconn.Close()
+
+		err2 = nconn.(*tls.Conn).Handshake()
+		require.NoError(t, err2)
+
+		require.Equal(t, []string{"mydevice", "mydevice"}, ConnClientIdentities(nconn))
+	}()
+
+	clientConf, err := MakeClientConfig("localhost", "", serverCertPath, clientCertPath, clientKeyPath)
+	require.NoError(t, err)
+
+	conn, err := tls.Dial("tcp", "localhost:8558", clientConf)
+	require.NoError(t, err)
+	defer conn.Close() //nolint:errcheck
+
+	err = conn.Handshake()
+	require.NoError(t, err)
+}
+
+func TestMakeClientConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := MakeClientConfig("localhost", "", filepath.Join(dir, "missing.crt"), "", "")
+	require.Error(t, err)
+
+	emptyPath := filepath.Join(dir, "empty.crt")
+	err = os.WriteFile(emptyPath, []byte("test"), 0o644)
+	require.NoError(t, err)
+
+	_, err = MakeClientConfig("localhost", "", emptyPath, "", "")
+	require.EqualError(t, err, "no certificates found in '"+emptyPath+"'")
+
+	_, err = MakeClientConfig("localhost", "", "", emptyPath, emptyPath)
+	require.Error(t, err)
+}
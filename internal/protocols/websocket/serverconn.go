@@ -23,6 +23,11 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+type message struct {
+	typ  int
+	byts []byte
+}
+
 // ServerConn is a server-side WebSocket connection with
 // automatic, periodic ping-pong
 type ServerConn struct {
@@ -30,7 +35,7 @@ type ServerConn struct {
 
 	// in
 	terminate chan struct{}
-	write     chan []byte
+	write     chan message
 
 	// out
 	writeErr chan error
@@ -46,7 +51,7 @@ func NewServerConn(w http.ResponseWriter, req *http.Request) (*ServerConn, error
 	c := &ServerConn{
 		wc:        wc,
 		terminate: make(chan struct{}),
-		write:     make(chan []byte),
+		write:     make(chan message),
 		writeErr:  make(chan error),
 	}
 
@@ -79,9 +84,9 @@ func (c *ServerConn) run() {
 
 	for {
 		select {
-		case byts := <-c.write:
+		case msg := <-c.write:
 			c.wc.SetWriteDeadline(time.Now().Add(writeTimeout)) //nolint:errcheck
-			err := c.wc.WriteMessage(websocket.TextMessage, byts)
+			err := c.wc.WriteMessage(msg.typ, msg.byts)
 			c.writeErr <- err
 
 		case <-pingTicker.C:
@@ -106,8 +111,17 @@ func (c *ServerConn) WriteJSON(in interface{}) error {
 		return err
 	}
 
+	return c.writeMessage(message{typ: websocket.TextMessage, byts: byts})
+}
+
+// WriteBinary writes a binary message.
+func (c *ServerConn) WriteBinary(byts []byte) error {
+	return c.writeMessage(message{typ: websocket.BinaryMessage, byts: byts})
+}
+
+func (c *ServerConn) writeMessage(msg message) error {
 	select {
-	case c.write <- byts:
+	case c.write <- msg:
 		return <-c.writeErr
 	case <-c.terminate:
 		return fmt.Errorf("terminated")
@@ -3,27 +3,72 @@ package recordcleaner
 
 import (
 	"context"
+	"errors"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/recordstore"
 )
 
+const (
+	// size limits must be enforced quickly, since bitrate can change suddenly.
+	sizeCleanInterval = 10 * time.Second
+)
+
 var timeNow = time.Now
 
-// Cleaner removes expired recording segments from disk.
+func interfaceIsEmpty(i interface{}) bool {
+	return reflect.ValueOf(i).Kind() != reflect.Ptr || reflect.ValueOf(i).IsNil()
+}
+
+type cleanerMetrics interface {
+	SetRecordCleaner(defs.APIRecordCleaner)
+}
+
+type segment struct {
+	pathName   string
+	recordPath string
+	fpath      string
+	remoteKey  string
+	start      time.Time
+	size       uint64
+	inUse      bool
+}
+
+type pathUsage struct {
+	bytes        uint64
+	segments     int
+	freeSpace    *uint64
+	evictions    uint64
+	evictedBytes uint64
+}
+
+// Cleaner removes expired recording segments from disk,
+// and evicts the oldest segments when size limits are exceeded.
 type Cleaner struct {
-	PathConfs map[string]*conf.Path
-	Parent    logger.Writer
+	PathConfs    map[string]*conf.Path
+	TotalMaxSize conf.StringSize
+	MinFreeSpace conf.StringSize
+	Metrics      cleanerMetrics
+	Parent       logger.Writer
 
 	ctx       context.Context
 	ctxCancel func()
 
+	mutex     sync.RWMutex
+	lastCheck time.Time
+	usages    map[string]*pathUsage
+
 	chReloadConf chan map[string]*conf.Path
 	done         chan struct{}
 }
@@ -31,14 +76,23 @@ type Cleaner struct {
 // Initialize initializes a Cleaner.
 func (c *Cleaner) Initialize() {
 	c.ctx, c.ctxCancel = context.WithCancel(context.Background())
+	c.usages = make(map[string]*pathUsage)
 	c.chReloadConf = make(chan map[string]*conf.Path)
 	c.done = make(chan struct{})
 
+	if !interfaceIsEmpty(c.Metrics) {
+		c.Metrics.SetRecordCleaner(c)
+	}
+
 	go c.run()
 }
 
 // Close closes the Cleaner.
 func (c *Cleaner) Close() {
+	if !interfaceIsEmpty(c.Metrics) {
+		c.Metrics.SetRecordCleaner(nil)
+	}
+
 	c.ctxCancel()
 	<-c.done
 }
@@ -75,13 +129,36 @@ func (c *Cleaner) run() {
 	}
 }
 
+// renditionConfs returns configurations of all recordings, including copies.
+func renditionConfs(pathConfs map[string]*conf.Path) map[string]*conf.Path {
+	out := make(map[string]*conf.Path, len(pathConfs))
+
+	for key, pathConf := range pathConfs {
+		for i, rc := range pathConf.RecordRenditions() {
+			out[key+"#"+strconv.Itoa(i)] = rc
+		}
+	}
+
+	return out
+}
+
 func (c *Cleaner) cleanInterval() time.Duration {
+	if c.TotalMaxSize != 0 || c.MinFreeSpace != 0 {
+		return sizeCleanInterval
+	}
+
 	interval := 30 * 60 * time.Second
 
-	for _, e := range c.PathConfs {
-		if e.RecordDeleteAfter != 0 &&
-			interval > (time.Duration(e.RecordDeleteAfter)/2) {
-			interval = time.Duration(e.RecordDeleteAfter) / 2
+	for _, pathConf := range c.PathConfs {
+		for _, e := range pathConf.RecordRenditions() {
+			if e.RecordMaxSize != 0 {
+				return sizeCleanInterval
+			}
+
+			if e.RecordDeleteAfter != 0 &&
+				interval > (time.Duration(e.RecordDeleteAfter)/2) {
+				interval = time.Duration(e.RecordDeleteAfter) / 2
+			}
 		}
 	}
 
@@ -91,48 +168,232 @@ func (c *Cleaner) cleanInterval() time.Duration {
 func (c *Cleaner) doRun() {
 	now := timeNow()
 
-	pathNames := recordstore.FindAllPathsWithSegments(c.PathConfs)
+	pathNames := recordstore.FindAllPathsWithSegments(renditionConfs(c.PathConfs))
+
+	// eviction counters are preserved, current usage is computed again.
+	usages := make(map[string]*pathUsage, len(pathNames))
+	c.mutex.RLock()
+	for pathName, prev := range c.usages {
+		usages[pathName] = &pathUsage{
+			evictions:    prev.evictions,
+			evictedBytes: prev.evictedBytes,
+		}
+	}
+	c.mutex.RUnlock()
+
+	for _, pathName := range pathNames {
+		if _, ok := usages[pathName]; !ok {
+			usages[pathName] = &pathUsage{}
+		}
+	}
+
+	var all []*segment
+
+	for _, pathName := range pathNames {
+		segments, err := c.processPath(now, pathName, usages[pathName])
+		if err == nil {
+			all = append(all, segments...)
+		}
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].start.Before(all[j].start)
+	})
+
+	if c.TotalMaxSize != 0 {
+		all = c.enforceTotalMaxSize(all, usages)
+	}
+
+	if c.MinFreeSpace != 0 {
+		all = c.enforceMinFreeSpace(all, usages)
+	}
 
 	for _, pathName := range pathNames {
-		c.processPath(now, pathName) //nolint:errcheck
+		pathConf, _, err := conf.FindPathConf(c.PathConfs, pathName)
+		if err == nil {
+			for _, rc := range pathConf.RecordRenditions() {
+				c.deleteEmptyDirs(rc)
+			}
+		}
 	}
+
+	for _, seg := range all {
+		usages[seg.pathName].bytes += seg.size
+		usages[seg.pathName].segments++
+	}
+
+	for pathName, usage := range usages {
+		pathConf, _, err := conf.FindPathConf(c.PathConfs, pathName)
+		if err == nil {
+			recordPath := strings.ReplaceAll(pathConf.RecordPath, "%path", pathName)
+			if v, err2 := freeSpace(recordstore.CommonPath(recordPath)); err2 == nil {
+				usage.freeSpace = &v
+			}
+		}
+	}
+
+	c.mutex.Lock()
+	c.lastCheck = now
+	c.usages = usages
+	c.mutex.Unlock()
 }
 
-func (c *Cleaner) processPath(now time.Time, pathName string) error {
+func (c *Cleaner) processPath(now time.Time, pathName string, usage *pathUsage) ([]*segment, error) {
 	pathConf, _, err := conf.FindPathConf(c.PathConfs, pathName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if pathConf.RecordDeleteAfter == 0 {
-		return nil
+	var out []*segment
+
+	// each recording of the path, including copies, has its own retention.
+	for _, rc := range pathConf.RecordRenditions() {
+		segments, err := c.processRendition(now, pathName, rc, usage)
+		if err != nil {
+			continue
+		}
+		out = append(out, segments...)
 	}
 
-	err = c.deleteExpiredSegments(now, pathName, pathConf)
+	return out, nil
+}
+
+func (c *Cleaner) processRendition(
+	now time.Time,
+	pathName string,
+	pathConf *conf.Path,
+	usage *pathUsage,
+) ([]*segment, error) {
+	if pathConf.RecordDeleteAfter != 0 {
+		err := c.deleteExpiredSegments(now, pathName, pathConf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	segments, err := c.findSegments(pathConf, pathName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	c.deleteEmptyDirs(pathConf)
+	if pathConf.RecordMaxSize != 0 {
+		var total uint64
+		for _, seg := range segments {
+			total += seg.size
+		}
 
-	return nil
+		for len(segments) != 0 && !segments[0].inUse && total > uint64(pathConf.RecordMaxSize) {
+			if !c.evict(segments[0], usage, "recordMaxSize") {
+				break
+			}
+			total -= segments[0].size
+			segments = segments[1:]
+		}
+	}
+
+	return segments, nil
 }
 
 func (c *Cleaner) deleteExpiredSegments(now time.Time, pathName string, pathConf *conf.Path) error {
 	end := now.Add(-time.Duration(pathConf.RecordDeleteAfter))
 	segments, err := recordstore.FindSegments(pathConf, pathName, nil, &end)
-	if err != nil {
+	if err != nil && !errors.Is(err, recordstore.ErrNoSegmentsFound) {
 		return err
 	}
 
 	for _, seg := range segments {
+		// segments uploaded to the object storage are kept in the index,
+		// their retention is managed by the bucket.
+		if seg.RemoteKey != "" {
+			err = os.Remove(seg.Fpath)
+			if err == nil {
+				c.Log(logger.Debug, "removing local copy of %s", seg.Fpath)
+			}
+			continue
+		}
+
 		c.Log(logger.Debug, "removing %s", seg.Fpath)
-		os.Remove(seg.Fpath)
+		err = os.Remove(seg.Fpath)
+		if err == nil || os.IsNotExist(err) {
+			c.removeFromIndex(pathConf.RecordPath, pathName, seg.Fpath)
+		}
+	}
+
+	// markers are removed when they expire, independently of segments.
+	n, err := recordstore.RemoveMarkersBefore(pathConf.RecordPath, pathName, end)
+	if err != nil {
+		c.Log(logger.Warn, "unable to remove expired markers: %v", err)
+	} else if n != 0 {
+		c.Log(logger.Debug, "removed %d expired markers of path %s", n, pathName)
 	}
 
 	return nil
 }
 
+func (c *Cleaner) enforceTotalMaxSize(all []*segment, usages map[string]*pathUsage) []*segment {
+	var total uint64
+	for _, seg := range all {
+		total += seg.size
+	}
+
+	remaining := all[:0]
+
+	for _, seg := range all {
+		if total > uint64(c.TotalMaxSize) && !seg.inUse &&
+			c.evict(seg, usages[seg.pathName], "recordTotalMaxSize") {
+			total -= seg.size
+		} else {
+			remaining = append(remaining, seg)
+		}
+	}
+
+	return remaining
+}
+
+func (c *Cleaner) enforceMinFreeSpace(all []*segment, usages map[string]*pathUsage) []*segment {
+	remaining := all[:0]
+
+	for _, seg := range all {
+		if !seg.inUse {
+			// free space is checked on the segment directory, since paths can be on different disks
+			free, err := freeSpace(filepath.Dir(seg.fpath))
+			if err == nil && free < uint64(c.MinFreeSpace) &&
+				c.evict(seg, usages[seg.pathName], "recordMinFreeSpace") {
+				continue
+			}
+		}
+
+		remaining = append(remaining, seg)
+	}
+
+	return remaining
+}
+
+func (c *Cleaner) evict(seg *segment, usage *pathUsage, reason string) bool {
+	c.Log(logger.Info, "removing %s since '%s' has been exceeded", seg.fpath, reason)
+
+	err := os.Remove(seg.fpath)
+	if err != nil {
+		c.Log(logger.Warn, err.Error())
+		return false
+	}
+
+	if seg.remoteKey == "" {
+		c.removeFromIndex(seg.recordPath, seg.pathName, seg.fpath)
+	}
+
+	usage.evictions++
+	usage.evictedBytes += seg.size
+	return true
+}
+
+func (c *Cleaner) removeFromIndex(recordPath string, pathName string, fpath string) {
+	err := recordstore.IndexRemoveSegment(recordPath, pathName, fpath)
+	if err != nil {
+		c.Log(logger.Warn, "unable to update recording index: %v", err)
+	}
+}
+
 func (c *Cleaner) deleteEmptyDirs(pathConf *conf.Path) {
 	recordPath := strings.ReplaceAll(pathConf.RecordPath, "%path", pathConf.Name)
 	commonPath := recordstore.CommonPath(recordPath)
@@ -149,3 +410,77 @@ func (c *Cleaner) deleteEmptyDirs(pathConf *conf.Path) {
 		return nil
 	})
 }
+
+// findSegments returns segments of a path, with their size, sorted by starting date.
+// Segments that have been deleted by someone else are removed from the index,
+// while segments that are stored in the object storage only are skipped.
+func (c *Cleaner) findSegments(pathConf *conf.Path, pathName string) ([]*segment, error) {
+	segments, err := recordstore.FindSegments(pathConf, pathName, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*segment, 0, len(segments))
+
+	for _, seg := range segments {
+		fi, err2 := os.Stat(seg.Fpath)
+		if err2 != nil {
+			if os.IsNotExist(err2) && seg.RemoteKey == "" {
+				c.removeFromIndex(pathConf.RecordPath, pathName, seg.Fpath)
+			}
+			continue
+		}
+
+		ret = append(ret, &segment{
+			pathName:   pathName,
+			recordPath: pathConf.RecordPath,
+			fpath:      seg.Fpath,
+			remoteKey:  seg.RemoteKey,
+			start:      seg.Start,
+			size:       uint64(fi.Size()),
+		})
+	}
+
+	// the last segment may be currently written by a recorder
+	if len(ret) != 0 {
+		ret[len(ret)-1].inUse = true
+	}
+
+	return ret, nil
+}
+
+// APIUsage is called by api.
+func (c *Cleaner) APIUsage() *defs.APIRecordingUsage {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	data := &defs.APIRecordingUsage{
+		Items: []*defs.APIRecordingPathUsage{},
+	}
+
+	if !c.lastCheck.IsZero() {
+		v := c.lastCheck
+		data.LastCheck = &v
+	}
+
+	for pathName, usage := range c.usages {
+		data.Bytes += usage.bytes
+		data.Evictions += usage.evictions
+		data.EvictedBytes += usage.evictedBytes
+
+		data.Items = append(data.Items, &defs.APIRecordingPathUsage{
+			Name:         pathName,
+			Bytes:        usage.bytes,
+			Segments:     usage.segments,
+			FreeSpace:    usage.freeSpace,
+			Evictions:    usage.evictions,
+			EvictedBytes: usage.evictedBytes,
+		})
+	}
+
+	sort.Slice(data.Items, func(i, j int) bool {
+		return data.Items[i].Name < data.Items[j].Name
+	})
+
+	return data
+}
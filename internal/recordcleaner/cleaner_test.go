@@ -1,6 +1,8 @@
 package recordcleaner
 
 import (
+	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -8,6 +10,7 @@ import (
 	"time"
 
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/test"
 	"github.com/stretchr/testify/require"
 )
@@ -32,6 +35,16 @@ func TestCleaner(t *testing.T) {
 	err = os.WriteFile(filepath.Join(dir, specialChars+"_mypath", "2009-05-20_22-15-25-000427.mp4"), []byte{1}, 0o644)
 	require.NoError(t, err)
 
+	recordPath := filepath.Join(dir, specialChars+"_%path/%Y-%m-%d_%H-%M-%S-%f")
+
+	expiredMarker := &recordstore.Marker{Start: time.Date(2008, 5, 20, 22, 15, 25, 0, time.Local)}
+	err = recordstore.AddMarker(recordPath, "mypath", expiredMarker)
+	require.NoError(t, err)
+
+	recentMarker := &recordstore.Marker{Start: time.Date(2009, 5, 20, 22, 15, 25, 0, time.Local)}
+	err = recordstore.AddMarker(recordPath, "mypath", recentMarker)
+	require.NoError(t, err)
+
 	c := &Cleaner{
 		PathConfs: map[string]*conf.Path{
 			"~^.*$": {
@@ -54,6 +67,11 @@ func TestCleaner(t *testing.T) {
 
 	_, err = os.Stat(filepath.Join(dir, specialChars+"_mypath", "2009-05-20_22-15-25-000427.mp4"))
 	require.NoError(t, err)
+
+	markers, err := recordstore.FindMarkers(recordPath, "mypath", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, markers, 1)
+	require.Equal(t, recentMarker.ID, markers[0].ID)
 }
 
 func TestCleanerMultipleEntriesSamePath(t *testing.T) {
@@ -108,3 +126,145 @@ func TestCleanerMultipleEntriesSamePath(t *testing.T) {
 	_, err = os.Stat(filepath.Join(dir, "path2", "2009-05-19_22-15-25-000427.mp4"))
 	require.NoError(t, err)
 }
+
+func TestCleanerRecordCopies(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Date(2009, 5, 20, 22, 15, 25, 427000, time.Local)
+	}
+
+	dir, err := os.MkdirTemp("", "mediamtx-cleaner")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, sub := range []string{"main", "copy"} {
+		err = os.MkdirAll(filepath.Join(dir, sub, "mypath"), 0o755)
+		require.NoError(t, err)
+
+		err = os.WriteFile(filepath.Join(dir, sub, "mypath", "2009-05-19_22-15-25-000427.mp4"), []byte{1}, 0o644)
+		require.NoError(t, err)
+	}
+
+	c := &Cleaner{
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:              "mypath",
+				RecordPath:        filepath.Join(dir, "main", "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				RecordFormat:      conf.RecordFormatFMP4,
+				RecordDeleteAfter: conf.Duration(10 * 24 * time.Hour),
+				RecordCopies: conf.RecordCopies{{
+					RecordPath:        filepath.Join(dir, "copy", "%path/%Y-%m-%d_%H-%M-%S-%f"),
+					RecordFormat:      conf.RecordFormatFMP4,
+					RecordDeleteAfter: conf.Duration(10 * time.Second),
+				}},
+			},
+		},
+		Parent: test.NilLogger,
+	}
+	c.Initialize()
+	defer c.Close()
+
+	time.Sleep(500 * time.Millisecond)
+
+	_, err = os.Stat(filepath.Join(dir, "main", "mypath", "2009-05-19_22-15-25-000427.mp4"))
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "copy", "mypath", "2009-05-19_22-15-25-000427.mp4"))
+	require.Error(t, err)
+}
+
+func TestCleanerSizeLimits(t *testing.T) {
+	for _, ca := range []string{
+		"recordMaxSize",
+		"recordTotalMaxSize",
+		"recordMinFreeSpace",
+	} {
+		t.Run(ca, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "mediamtx-cleaner")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			for _, pathName := range []string{"path1", "path2"} {
+				err = os.Mkdir(filepath.Join(dir, pathName), 0o755)
+				require.NoError(t, err)
+			}
+
+			for _, fname := range []string{
+				"path1/2009-05-19_22-15-25-000000.mp4",
+				"path2/2009-05-19_22-16-25-000000.mp4",
+				"path1/2009-05-19_22-17-25-000000.mp4",
+				"path2/2009-05-19_22-18-25-000000.mp4",
+				"path1/2009-05-19_22-19-25-000000.mp4",
+			} {
+				err = os.WriteFile(filepath.Join(dir, fname), make([]byte, 1000), 0o644)
+				require.NoError(t, err)
+			}
+
+			pathConf := &conf.Path{
+				Name:         "~^.*$",
+				Regexp:       regexp.MustCompile("^.*$"),
+				RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				RecordFormat: conf.RecordFormatFMP4,
+			}
+
+			c := &Cleaner{
+				PathConfs: map[string]*conf.Path{
+					"~^.*$": pathConf,
+				},
+				Parent: test.NilLogger,
+			}
+
+			var expected []string
+
+			switch ca {
+			case "recordMaxSize":
+				pathConf.RecordMaxSize = 1500
+				expected = []string{
+					"path1/2009-05-19_22-19-25-000000.mp4",
+					"path2/2009-05-19_22-18-25-000000.mp4",
+				}
+
+			case "recordTotalMaxSize":
+				c.TotalMaxSize = 3000
+				expected = []string{
+					"path1/2009-05-19_22-17-25-000000.mp4",
+					"path1/2009-05-19_22-19-25-000000.mp4",
+					"path2/2009-05-19_22-18-25-000000.mp4",
+				}
+
+			case "recordMinFreeSpace":
+				c.MinFreeSpace = math.MaxUint64
+				expected = []string{
+					"path1/2009-05-19_22-19-25-000000.mp4",
+					"path2/2009-05-19_22-18-25-000000.mp4",
+				}
+			}
+
+			c.Initialize()
+			defer c.Close()
+
+			time.Sleep(500 * time.Millisecond)
+
+			var found []string
+			err = filepath.WalkDir(dir, func(fpath string, info fs.DirEntry, err error) error {
+				require.NoError(t, err)
+				if info.IsDir() && info.Name() == recordstore.IndexDirName {
+					return filepath.SkipDir
+				}
+				if !info.IsDir() {
+					rel, _ := filepath.Rel(dir, fpath)
+					found = append(found, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, expected, found)
+
+			usage := c.APIUsage()
+			require.NotNil(t, usage.LastCheck)
+			require.Equal(t, uint64(len(expected)*1000), usage.Bytes)
+			require.Equal(t, uint64(5-len(expected)), usage.Evictions)
+			require.Equal(t, uint64((5-len(expected))*1000), usage.EvictedBytes)
+			require.Len(t, usage.Items, 2)
+		})
+	}
+}
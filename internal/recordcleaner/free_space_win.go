@@ -0,0 +1,22 @@
+//go:build windows
+
+package recordcleaner
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+func freeSpace(dir string) (uint64, error) {
+	dirPtr, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var avail uint64
+	err = windows.GetDiskFreeSpaceEx(dirPtr, &avail, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return avail, nil
+}
@@ -3,4 +3,5 @@ package recorder
 type format interface {
 	initialize() bool
 	close()
+	closeSegment() error
 }
@@ -8,13 +8,39 @@ import (
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 )
 
+// sampleOffsets returns the position of samples inside a marshaled part.
+// Samples are stored into the mdat box, that is placed at the end of the part,
+// in the same order of tracks.
+func sampleOffsets(partSize int64, tracks []*fmp4.PartTrack) map[*fmp4.Sample]int64 {
+	var dataSize int64
+	for _, track := range tracks {
+		for _, sample := range track.Samples {
+			dataSize += int64(len(sample.Payload))
+		}
+	}
+
+	out := make(map[*fmp4.Sample]int64)
+	pos := partSize - dataSize
+
+	for _, track := range tracks {
+		for _, sample := range track.Samples {
+			out[sample] = pos
+			pos += int64(len(sample.Payload))
+		}
+	}
+
+	return out
+}
+
+// writePart writes a part and returns its size and the position of samples inside it.
 func writePart(
 	f io.Writer,
 	sequenceNumber uint32,
 	partTracks map[*formatFMP4Track]*fmp4.PartTrack,
-) error {
+) (int64, map[*fmp4.Sample]int64, error) {
 	fmp4PartTracks := make([]*fmp4.PartTrack, len(partTracks))
 	i := 0
 	for _, partTrack := range partTracks {
@@ -30,11 +56,22 @@ func writePart(
 	var buf seekablebuffer.Buffer
 	err := part.Marshal(&buf)
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
 
 	_, err = f.Write(buf.Bytes())
-	return err
+	if err != nil {
+		return 0, nil, err
+	}
+
+	size := int64(buf.Len())
+
+	return size, sampleOffsets(size, fmp4PartTracks), nil
+}
+
+type formatFMP4PartKeyframe struct {
+	sample *fmp4.Sample
+	time   time.Duration
 }
 
 type formatFMP4Part struct {
@@ -44,6 +81,7 @@ type formatFMP4Part struct {
 	startDTS        time.Duration
 
 	partTracks map[*formatFMP4Track]*fmp4.PartTrack
+	keyframes  []formatFMP4PartKeyframe
 	size       uint64
 	endDTS     time.Duration
 }
@@ -52,8 +90,25 @@ func (p *formatFMP4Part) initialize() {
 	p.partTracks = make(map[*formatFMP4Track]*fmp4.PartTrack)
 }
 
-func (p *formatFMP4Part) close(w io.Writer) error {
-	return writePart(w, p.number, p.partTracks)
+// close writes the part at the given offset of the segment,
+// and returns its size and the keyframes it contains.
+func (p *formatFMP4Part) close(w io.Writer, offset int64) (int64, []recordstore.Keyframe, error) {
+	size, offsets, err := writePart(w, p.number, p.partTracks)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	keyframes := make([]recordstore.Keyframe, len(p.keyframes))
+
+	for i, k := range p.keyframes {
+		keyframes[i] = recordstore.Keyframe{
+			Time:   k.time,
+			Offset: offset + offsets[k.sample],
+			Size:   int64(len(k.sample.Payload)),
+		}
+	}
+
+	return size, keyframes, nil
 }
 
 func (p *formatFMP4Part) write(track *formatFMP4Track, sample *sample, dts time.Duration) error {
@@ -83,6 +138,14 @@ func (p *formatFMP4Part) write(track *formatFMP4Track, sample *sample, dts time.
 	return nil
 }
 
+// addKeyframe marks the last written sample as a keyframe to be indexed.
+func (p *formatFMP4Part) addKeyframe(sample *sample, elapsed time.Duration) {
+	p.keyframes = append(p.keyframes, formatFMP4PartKeyframe{
+		sample: sample.Sample,
+		time:   elapsed,
+	})
+}
+
 func (p *formatFMP4Part) duration() time.Duration {
 	return p.endDTS - p.startDTS
 }
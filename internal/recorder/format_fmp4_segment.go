@@ -24,7 +24,7 @@ func writeInit(
 	dts time.Duration,
 	ntp time.Time,
 	tracks []*formatFMP4Track,
-) error {
+) (int64, error) {
 	fmp4Tracks := make([]*fmp4.InitTrack, len(tracks))
 	for i, track := range tracks {
 		fmp4Tracks[i] = track.initTrack
@@ -48,11 +48,15 @@ func writeInit(
 	var buf seekablebuffer.Buffer
 	err := init.Marshal(&buf)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	_, err = f.Write(buf.Bytes())
-	return err
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(buf.Len()), nil
 }
 
 func writeDuration(f io.ReadWriteSeeker, d time.Duration) error {
@@ -126,10 +130,13 @@ type formatFMP4Segment struct {
 	number   uint64
 
 	path           string
-	fi             *os.File
+	fi             recordstore.SegmentFile
+	size           int64
 	curPart        *formatFMP4Part
 	endDTS         time.Duration
 	nextPartNumber uint32
+	keyframes      []recordstore.Keyframe
+	lastKeyframe   *time.Duration
 }
 
 func (s *formatFMP4Segment) initialize() {
@@ -159,7 +166,11 @@ func (s *formatFMP4Segment) close() error {
 		}
 
 		if err2 == nil {
-			s.f.ri.onSegmentComplete(s.path, duration)
+			if len(s.keyframes) != 0 {
+				s.f.ri.keyframesCompleted(s.path, s.keyframes)
+			}
+
+			s.f.ri.segmentCompleted(s.path, s.number, duration)
 		}
 	}
 
@@ -176,14 +187,14 @@ func (s *formatFMP4Segment) closeCurPart() error {
 			return err
 		}
 
-		fi, err := os.Create(s.path)
+		fi, err := recordstore.CreateSegmentFile(s.path, s.f.ri.encryptionKey)
 		if err != nil {
 			return err
 		}
 
-		s.f.ri.onSegmentCreate(s.path)
+		s.f.ri.segmentCreated(s.path)
 
-		err = writeInit(
+		size, err := writeInit(
 			fi,
 			s.f.ri.streamID,
 			s.number,
@@ -196,9 +207,33 @@ func (s *formatFMP4Segment) closeCurPart() error {
 		}
 
 		s.fi = fi
+		s.size = size
 	}
 
-	return s.curPart.close(s.fi)
+	size, keyframes, err := s.curPart.close(s.fi, s.size)
+	if err != nil {
+		return err
+	}
+
+	s.size += size
+	s.keyframes = append(s.keyframes, keyframes...)
+
+	return nil
+}
+
+// isIndexedKeyframe returns whether a sample has to be added to the keyframe index.
+func (s *formatFMP4Segment) isIndexedKeyframe(track *formatFMP4Track, sample *sample, dts time.Duration) bool {
+	if s.f.ri.keyframeInterval <= 0 || sample.IsNonSyncSample || track != s.f.keyframeTrack() {
+		return false
+	}
+
+	elapsed := dts - s.startDTS
+	if s.lastKeyframe != nil && (elapsed-*s.lastKeyframe) < s.f.ri.keyframeInterval {
+		return false
+	}
+
+	s.lastKeyframe = &elapsed
+	return true
 }
 
 func (s *formatFMP4Segment) write(track *formatFMP4Track, sample *sample, dts time.Duration) error {
@@ -234,5 +269,14 @@ func (s *formatFMP4Segment) write(track *formatFMP4Track, sample *sample, dts ti
 		s.nextPartNumber++
 	}
 
-	return s.curPart.write(track, sample, dts)
+	err := s.curPart.write(track, sample, dts)
+	if err != nil {
+		return err
+	}
+
+	if s.isIndexedKeyframe(track, sample, dts) {
+		s.curPart.addKeyframe(sample, dts-s.startDTS)
+	}
+
+	return nil
 }
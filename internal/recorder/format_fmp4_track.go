@@ -4,7 +4,9 @@ import (
 	"time"
 
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	mfmp4 "github.com/bluenviron/mediamtx/internal/protocols/fmp4"
 )
 
 const (
@@ -48,6 +50,21 @@ type formatFMP4Track struct {
 	nextSample *sample
 }
 
+// UpdateCodec implements mfmp4.Track.
+func (t *formatFMP4Track) UpdateCodec(codec mp4.Codec) {
+	t.initTrack.Codec = codec
+	t.f.ri.Log(logger.Debug, "codec parameters have changed")
+}
+
+// WriteSample implements mfmp4.Track.
+func (t *formatFMP4Track) WriteSample(s *mfmp4.Sample) error {
+	return t.write(&sample{
+		Sample: s.Sample,
+		dts:    s.DTS,
+		ntp:    s.NTP,
+	})
+}
+
 func (t *formatFMP4Track) write(sample *sample) error {
 	// wait the first video sample before setting hasVideo
 	if t.initTrack.Codec.IsVideo() {
@@ -85,7 +102,7 @@ func (t *formatFMP4Track) write(sample *sample) error {
 
 	if (!t.f.hasVideo || t.initTrack.Codec.IsVideo()) &&
 		!t.nextSample.IsNonSyncSample &&
-		(nextDTS-t.f.currentSegment.startDTS) >= t.f.ri.segmentDuration {
+		t.f.ri.segmentEnded(t.f.currentSegment.startDTS, t.f.currentSegment.startNTP, nextDTS) {
 		err = t.f.currentSegment.close()
 		if err != nil {
 			return err
@@ -0,0 +1,925 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"time"
+
+	rtspformat "github.com/bluenviron/gortsplib/v5/pkg/format"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/ac3"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/av1"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/g711"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg1audio"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4video"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/opus"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/vp9"
+
+	"github.com/bluenviron/mediamtx/internal/codecprocessor"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	mfmp4 "github.com/bluenviron/mediamtx/internal/protocols/fmp4"
+	"github.com/bluenviron/mediamtx/internal/protocols/mkv"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+// vp8ParseKeyFrame returns the size of a VP8 key frame.
+// Specification: RFC 6386, section 9.1
+func vp8ParseKeyFrame(frame []byte) (bool, int, int) {
+	if len(frame) < 10 || (frame[0]&0x01) != 0 {
+		return false, 0, 0
+	}
+
+	if !bytes.Equal(frame[3:6], []byte{0x9D, 0x01, 0x2A}) {
+		return true, 0, 0
+	}
+
+	width := int(binary.LittleEndian.Uint16(frame[6:]) & 0x3FFF)
+	height := int(binary.LittleEndian.Uint16(frame[8:]) & 0x3FFF)
+	return true, width, height
+}
+
+// mkvReorderedDTSExtractor computes decode timestamps of MPEG-4 Video and MPEG-1 Video frames.
+// Frames are received in decoding order: B-frames are decoded when they are presented,
+// while reference frames are decoded when the previous reference frame is presented.
+// Matroska only stores presentation timestamps, therefore decode timestamps are
+// used to split clusters and segments only.
+type mkvReorderedDTSExtractor struct {
+	initialized bool
+	lastRefPTS  int64
+}
+
+func (d *mkvReorderedDTSExtractor) extract(pts int64) int64 {
+	if !d.initialized {
+		d.initialized = true
+		d.lastRefPTS = pts
+		return pts
+	}
+
+	if pts > d.lastRefPTS {
+		dts := d.lastRefPTS
+		d.lastRefPTS = pts
+		return dts
+	}
+
+	return pts
+}
+
+type formatMKVTrack struct {
+	f     *formatMKV
+	track *mkv.Track
+}
+
+func (t *formatMKVTrack) write(
+	dts time.Duration,
+	pts time.Duration,
+	ntp time.Time,
+	randomAccess bool,
+	payload []byte,
+) error {
+	return t.f.write(t, dts, pts, ntp, randomAccess, payload)
+}
+
+type formatMKV struct {
+	ri   *recorderInstance
+	webm bool
+
+	tracks            []*formatMKVTrack
+	hasVideo          bool
+	currentSegment    *formatMKVSegment
+	nextSegmentNumber uint64
+}
+
+func (f *formatMKV) initialize() bool {
+	addTrack := func(codec mkv.Codec) *formatMKVTrack {
+		track := &formatMKVTrack{
+			f: f,
+			track: &mkv.Track{
+				Number: len(f.tracks) + 1,
+				Codec:  codec,
+			},
+		}
+		f.tracks = append(f.tracks, track)
+		return track
+	}
+
+	trackNum := 0
+
+	for _, media := range f.ri.stream.Desc.Medias {
+		for _, forma := range media.Formats {
+			trackNum++
+			if !f.ri.trackSelected(trackNum, media, forma) {
+				continue
+			}
+
+			clockRate := forma.ClockRate()
+
+			switch forma := forma.(type) {
+			case *rtspformat.AV1:
+				codec := &mkv.CodecAV1{
+					SequenceHeader: codecprocessor.AV1DefaultSequenceHeader,
+				}
+				track := addTrack(codec)
+
+				firstReceived := false
+
+				f.ri.onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						randomAccess := false
+
+						for _, obu := range u.Payload.(unit.PayloadAV1) {
+							typ := av1.OBUType((obu[0] >> 3) & 0b1111)
+
+							if typ == av1.OBUTypeSequenceHeader {
+								if !bytes.Equal(codec.SequenceHeader, obu) {
+									codec.SequenceHeader = obu
+									f.updateCodecParams()
+								}
+								randomAccess = true
+							}
+						}
+
+						if !firstReceived {
+							if !randomAccess {
+								return nil
+							}
+							firstReceived = true
+						}
+
+						bs, err := av1.Bitstream(u.Payload.(unit.PayloadAV1)).Marshal()
+						if err != nil {
+							return err
+						}
+
+						pts := timestampToDuration(u.PTS, clockRate)
+
+						return track.write(pts, pts, u.NTP, randomAccess, bs)
+					})
+
+			case *rtspformat.VP9:
+				codec := &mkv.CodecVP9{
+					Width:             1280,
+					Height:            720,
+					Profile:           1,
+					BitDepth:          8,
+					ChromaSubsampling: 1,
+				}
+				track := addTrack(codec)
+
+				firstReceived := false
+
+				f.ri.onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						var h vp9.Header
+						err := h.Unmarshal(u.Payload.(unit.PayloadVP9))
+						if err != nil {
+							return err
+						}
+
+						randomAccess := !h.NonKeyFrame
+
+						if randomAccess {
+							codec2 := mkv.CodecVP9{
+								Width:             h.Width(),
+								Height:            h.Height(),
+								Profile:           h.Profile,
+								BitDepth:          h.ColorConfig.BitDepth,
+								ChromaSubsampling: h.ChromaSubsampling(),
+								ColorRange:        h.ColorConfig.ColorRange,
+							}
+
+							if *codec != codec2 {
+								*codec = codec2
+								f.updateCodecParams()
+							}
+						}
+
+						if !firstReceived {
+							if !randomAccess {
+								return nil
+							}
+							firstReceived = true
+						}
+
+						pts := timestampToDuration(u.PTS, clockRate)
+
+						return track.write(pts, pts, u.NTP, randomAccess, u.Payload.(unit.PayloadVP9))
+					})
+
+			case *rtspformat.VP8:
+				codec := &mkv.CodecVP8{
+					Width:  1280,
+					Height: 720,
+				}
+				track := addTrack(codec)
+
+				firstReceived := false
+
+				f.ri.onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						randomAccess, width, height := vp8ParseKeyFrame(u.Payload.(unit.PayloadVP8))
+
+						if width != 0 && (codec.Width != width || codec.Height != height) {
+							codec.Width = width
+							codec.Height = height
+							f.updateCodecParams()
+						}
+
+						if !firstReceived {
+							if !randomAccess {
+								return nil
+							}
+							firstReceived = true
+						}
+
+						pts := timestampToDuration(u.PTS, clockRate)
+
+						return track.write(pts, pts, u.NTP, randomAccess, u.Payload.(unit.PayloadVP8))
+					})
+
+			case *rtspformat.H265:
+				if f.webm {
+					continue
+				}
+
+				vps, sps, pps := forma.SafeParams()
+				if vps == nil || sps == nil || pps == nil {
+					vps = codecprocessor.H265DefaultVPS
+					sps = codecprocessor.H265DefaultSPS
+					pps = codecprocessor.H265DefaultPPS
+				}
+
+				codec := &mkv.CodecH265{
+					VPS: vps,
+					SPS: sps,
+					PPS: pps,
+				}
+				track := addTrack(codec)
+
+				var dtsExtractor *h265.DTSExtractor
+
+				f.ri.onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						randomAccess := false
+
+						for _, nalu := range u.Payload.(unit.PayloadH265) {
+							typ := h265.NALUType((nalu[0] >> 1) & 0b111111)
+
+							switch typ {
+							case h265.NALUType_VPS_NUT:
+								if !bytes.Equal(codec.VPS, nalu) {
+									codec.VPS = nalu
+									f.updateCodecParams()
+								}
+
+							case h265.NALUType_SPS_NUT:
+								if !bytes.Equal(codec.SPS, nalu) {
+									codec.SPS = nalu
+									f.updateCodecParams()
+								}
+
+							case h265.NALUType_PPS_NUT:
+								if !bytes.Equal(codec.PPS, nalu) {
+									codec.PPS = nalu
+									f.updateCodecParams()
+								}
+
+							case h265.NALUType_IDR_W_RADL, h265.NALUType_IDR_N_LP, h265.NALUType_CRA_NUT:
+								randomAccess = true
+							}
+						}
+
+						if dtsExtractor == nil {
+							if !randomAccess {
+								return nil
+							}
+							dtsExtractor = &h265.DTSExtractor{}
+							dtsExtractor.Initialize()
+						}
+
+						dts, err := dtsExtractor.Extract(u.Payload.(unit.PayloadH265), u.PTS)
+						if err != nil {
+							return err
+						}
+
+						avcc, err := h264.AVCC(u.Payload.(unit.PayloadH265)).Marshal()
+						if err != nil {
+							return err
+						}
+
+						return track.write(
+							timestampToDuration(dts, clockRate),
+							timestampToDuration(u.PTS, clockRate),
+							u.NTP,
+							randomAccess,
+							avcc)
+					})
+
+			case *rtspformat.H264:
+				if f.webm {
+					continue
+				}
+
+				sps, pps := forma.SafeParams()
+				if sps == nil || pps == nil {
+					sps = codecprocessor.H264DefaultSPS
+					pps = codecprocessor.H264DefaultPPS
+				}
+
+				codec := &mkv.CodecH264{
+					SPS: sps,
+					PPS: pps,
+				}
+				track := addTrack(codec)
+
+				var dtsExtractor *h264.DTSExtractor
+
+				f.ri.onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						randomAccess := false
+
+						for _, nalu := range u.Payload.(unit.PayloadH264) {
+							typ := h264.NALUType(nalu[0] & 0x1F)
+							switch typ {
+							case h264.NALUTypeSPS:
+								if !bytes.Equal(codec.SPS, nalu) {
+									codec.SPS = nalu
+									f.updateCodecParams()
+								}
+
+							case h264.NALUTypePPS:
+								if !bytes.Equal(codec.PPS, nalu) {
+									codec.PPS = nalu
+									f.updateCodecParams()
+								}
+
+							case h264.NALUTypeIDR:
+								randomAccess = true
+							}
+						}
+
+						if dtsExtractor == nil {
+							if !randomAccess {
+								return nil
+							}
+							dtsExtractor = &h264.DTSExtractor{}
+							dtsExtractor.Initialize()
+						}
+
+						dts, err := dtsExtractor.Extract(u.Payload.(unit.PayloadH264), u.PTS)
+						if err != nil {
+							return err
+						}
+
+						avcc, err := h264.AVCC(u.Payload.(unit.PayloadH264)).Marshal()
+						if err != nil {
+							return err
+						}
+
+						return track.write(
+							timestampToDuration(dts, clockRate),
+							timestampToDuration(u.PTS, clockRate),
+							u.NTP,
+							randomAccess,
+							avcc)
+					})
+
+			case *rtspformat.MPEG4Video:
+				if f.webm {
+					continue
+				}
+
+				config := forma.SafeParams()
+				if config == nil {
+					config = codecprocessor.MPEG4VideoDefaultConfig
+				}
+
+				codec := &mkv.CodecMPEG4Video{
+					Config: config,
+				}
+				track := addTrack(codec)
+
+				firstReceived := false
+				var dtsExtractor mkvReorderedDTSExtractor
+
+				f.ri.onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						randomAccess := bytes.Contains(u.Payload.(unit.PayloadMPEG4Video),
+							[]byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)})
+
+						if bytes.HasPrefix(u.Payload.(unit.PayloadMPEG4Video),
+							[]byte{0, 0, 1, byte(mpeg4video.VisualObjectSequenceStartCode)}) {
+							end := bytes.Index(u.Payload.(unit.PayloadMPEG4Video)[4:],
+								[]byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)})
+							if end >= 0 {
+								config2 := u.Payload.(unit.PayloadMPEG4Video)[:end+4]
+
+								if !bytes.Equal(codec.Config, config2) {
+									codec.Config = config2
+									f.updateCodecParams()
+								}
+							}
+						}
+
+						if !firstReceived {
+							if !randomAccess {
+								return nil
+							}
+							firstReceived = true
+						}
+
+						dts := timestampToDuration(dtsExtractor.extract(u.PTS), clockRate)
+						pts := timestampToDuration(u.PTS, clockRate)
+
+						return track.write(dts, pts, u.NTP, randomAccess, u.Payload.(unit.PayloadMPEG4Video))
+					})
+
+			case *rtspformat.MPEG1Video:
+				if f.webm {
+					continue
+				}
+
+				codec := &mkv.CodecMPEG1Video{
+					Config: codecprocessor.MPEG1VideoDefaultConfig,
+				}
+				track := addTrack(codec)
+
+				firstReceived := false
+				var dtsExtractor mkvReorderedDTSExtractor
+
+				f.ri.onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						randomAccess := bytes.Contains(u.Payload.(unit.PayloadMPEG1Video), []byte{0, 0, 1, 0xB8})
+
+						if bytes.HasPrefix(u.Payload.(unit.PayloadMPEG1Video), []byte{0, 0, 1, 0xB3}) {
+							end := bytes.Index(u.Payload.(unit.PayloadMPEG1Video)[4:], []byte{0, 0, 1, 0xB8})
+							if end >= 0 {
+								config := u.Payload.(unit.PayloadMPEG1Video)[:end+4]
+
+								if !bytes.Equal(codec.Config, config) {
+									codec.Config = config
+									f.updateCodecParams()
+								}
+							}
+						}
+
+						if !firstReceived {
+							if !randomAccess {
+								return nil
+							}
+							firstReceived = true
+						}
+
+						dts := timestampToDuration(dtsExtractor.extract(u.PTS), clockRate)
+						pts := timestampToDuration(u.PTS, clockRate)
+
+						return track.write(dts, pts, u.NTP, randomAccess, u.Payload.(unit.PayloadMPEG1Video))
+					})
+
+			case *rtspformat.MJPEG:
+				if f.webm {
+					continue
+				}
+
+				codec := &mkv.CodecMJPEG{
+					Width:  800,
+					Height: 600,
+				}
+				track := addTrack(codec)
+
+				parsed := false
+
+				f.ri.onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						if !parsed {
+							parsed = true
+							width, height, err := mfmp4.JPEGExtractSize(u.Payload.(unit.PayloadMJPEG))
+							if err != nil {
+								return err
+							}
+							codec.Width = width
+							codec.Height = height
+							f.updateCodecParams()
+						}
+
+						pts := timestampToDuration(u.PTS, clockRate)
+
+						return track.write(pts, pts, u.NTP, true, u.Payload.(unit.PayloadMJPEG))
+					})
+
+			case *rtspformat.Opus:
+				codec := &mkv.CodecOpus{
+					ChannelCount: forma.ChannelCount,
+				}
+				track := addTrack(codec)
+
+				f.ri.onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						pts := u.PTS
+
+						for _, packet := range u.Payload.(unit.PayloadOpus) {
+							ptsGo := timestampToDuration(pts, clockRate)
+
+							err := track.write(
+								ptsGo,
+								ptsGo,
+								u.NTP.Add(timestampToDuration(pts-u.PTS, clockRate)),
+								true,
+								packet)
+							if err != nil {
+								return err
+							}
+
+							pts += opus.PacketDuration2(packet)
+						}
+
+						return nil
+					})
+
+			case *rtspformat.MPEG4Audio:
+				if f.webm {
+					continue
+				}
+
+				codec := &mkv.CodecMPEG4Audio{
+					Config: *forma.Config,
+				}
+				track := addTrack(codec)
+
+				f.ri.onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						for i, au := range u.Payload.(unit.PayloadMPEG4Audio) {
+							pts := u.PTS + int64(i)*mpeg4audio.SamplesPerAccessUnit
+							ptsGo := timestampToDuration(pts, clockRate)
+
+							err := track.write(
+								ptsGo,
+								ptsGo,
+								u.NTP.Add(timestampToDuration(pts-u.PTS, clockRate)),
+								true,
+								au)
+							if err != nil {
+								return err
+							}
+						}
+
+						return nil
+					})
+
+			case *rtspformat.MPEG4AudioLATM:
+				if f.webm || forma.CPresent {
+					continue
+				}
+
+				codec := &mkv.CodecMPEG4Audio{
+					Config: *forma.StreamMuxConfig.Programs[0].Layers[0].AudioSpecificConfig,
+				}
+				track := addTrack(codec)
+
+				f.ri.onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						var ame mpeg4audio.AudioMuxElement
+						ame.StreamMuxConfig = forma.StreamMuxConfig
+						err := ame.Unmarshal(u.Payload.(unit.PayloadMPEG4AudioLATM))
+						if err != nil {
+							return err
+						}
+
+						pts := timestampToDuration(u.PTS, clockRate)
+
+						return track.write(pts, pts, u.NTP, true, ame.Payloads[0][0][0])
+					})
+
+			case *rtspformat.MPEG1Audio:
+				if f.webm {
+					continue
+				}
+
+				codec := &mkv.CodecMPEG1Audio{
+					Layer:        3,
+					SampleRate:   32000,
+					ChannelCount: 2,
+				}
+				track := addTrack(codec)
+
+				parsed := false
+
+				f.ri.onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						pts := timestampToDuration(u.PTS, clockRate)
+
+						for _, frame := range u.Payload.(unit.PayloadMPEG1Audio) {
+							var h mpeg1audio.FrameHeader
+							err := h.Unmarshal(frame)
+							if err != nil {
+								return err
+							}
+
+							if !parsed {
+								parsed = true
+								codec.Layer = int(h.Layer)
+								codec.SampleRate = h.SampleRate
+								codec.ChannelCount = mfmp4.MPEG1AudioChannelCount(h.ChannelMode)
+								f.updateCodecParams()
+							}
+
+							err = track.write(pts, pts, u.NTP.Add(pts-timestampToDuration(u.PTS, clockRate)), true, frame)
+							if err != nil {
+								return err
+							}
+
+							pts += time.Duration(h.SampleCount()) *
+								time.Second / time.Duration(h.SampleRate)
+						}
+
+						return nil
+					})
+
+			case *rtspformat.AC3:
+				if f.webm {
+					continue
+				}
+
+				codec := &mkv.CodecAC3{
+					SampleRate:   forma.SampleRate,
+					ChannelCount: forma.ChannelCount,
+				}
+				track := addTrack(codec)
+
+				parsed := false
+
+				f.ri.onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						for i, frame := range u.Payload.(unit.PayloadAC3) {
+							if !parsed {
+								var syncInfo ac3.SyncInfo
+								err := syncInfo.Unmarshal(frame)
+								if err != nil {
+									return fmt.Errorf("invalid AC-3 frame: %w", err)
+								}
+
+								var bsi ac3.BSI
+								err = bsi.Unmarshal(frame[5:])
+								if err != nil {
+									return fmt.Errorf("invalid AC-3 frame: %w", err)
+								}
+
+								parsed = true
+								codec.SampleRate = syncInfo.SampleRate()
+								codec.ChannelCount = bsi.ChannelCount()
+								f.updateCodecParams()
+							}
+
+							pts := u.PTS + int64(i)*ac3.SamplesPerFrame
+							ptsGo := timestampToDuration(pts, clockRate)
+
+							err := track.write(
+								ptsGo,
+								ptsGo,
+								u.NTP.Add(timestampToDuration(pts-u.PTS, clockRate)),
+								true,
+								frame)
+							if err != nil {
+								return err
+							}
+						}
+
+						return nil
+					})
+
+			case *rtspformat.G711:
+				if f.webm {
+					continue
+				}
+
+				codec := &mkv.CodecLPCM{
+					LittleEndian: false,
+					BitDepth:     16,
+					SampleRate:   forma.SampleRate,
+					ChannelCount: forma.ChannelCount,
+				}
+				track := addTrack(codec)
+
+				f.ri.onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						var lpcm []byte
+						if forma.MULaw {
+							var mu g711.Mulaw
+							mu.Unmarshal(u.Payload.(unit.PayloadG711))
+							lpcm = mu
+						} else {
+							var al g711.Alaw
+							al.Unmarshal(u.Payload.(unit.PayloadG711))
+							lpcm = al
+						}
+
+						pts := timestampToDuration(u.PTS, clockRate)
+
+						return track.write(pts, pts, u.NTP, true, lpcm)
+					})
+
+			case *rtspformat.LPCM:
+				if f.webm {
+					continue
+				}
+
+				codec := &mkv.CodecLPCM{
+					LittleEndian: false,
+					BitDepth:     forma.BitDepth,
+					SampleRate:   forma.SampleRate,
+					ChannelCount: forma.ChannelCount,
+				}
+				track := addTrack(codec)
+
+				f.ri.onData(
+					media,
+					forma,
+					func(u *unit.Unit) error {
+						if u.NilPayload() {
+							return nil
+						}
+
+						pts := timestampToDuration(u.PTS, clockRate)
+
+						return track.write(pts, pts, u.NTP, true, u.Payload.(unit.PayloadLPCM))
+					})
+			}
+		}
+	}
+
+	if len(f.tracks) == 0 {
+		f.ri.Log(logger.Warn, "no supported tracks found, skipping recording")
+		return false
+	}
+
+	setuppedFormats := f.ri.reader.Formats()
+
+	n := 1
+	for _, medi := range f.ri.stream.Desc.Medias {
+		for _, forma := range medi.Formats {
+			if !slices.Contains(setuppedFormats, forma) && f.ri.trackSelected(n, medi, forma) {
+				f.ri.Log(logger.Warn, "skipping track %d (%s)", n, forma.Codec())
+			}
+			n++
+		}
+	}
+
+	f.ri.Log(logger.Info, "recording %s",
+		defs.FormatsInfo(setuppedFormats))
+
+	return true
+}
+
+func (f *formatMKV) updateCodecParams() {
+	f.ri.Log(logger.Debug, "codec parameters have changed")
+}
+
+func (f *formatMKV) close() {
+	if f.currentSegment != nil {
+		f.currentSegment.close() //nolint:errcheck
+	}
+}
+
+func (f *formatMKV) closeSegment() error {
+	if f.currentSegment == nil {
+		return nil
+	}
+
+	err := f.currentSegment.close()
+	f.currentSegment = nil
+	return err
+}
+
+func (f *formatMKV) write(
+	track *formatMKVTrack,
+	dts time.Duration,
+	pts time.Duration,
+	ntp time.Time,
+	randomAccess bool,
+	payload []byte,
+) error {
+	isVideo := track.track.Codec.IsVideo()
+	if isVideo {
+		f.hasVideo = true
+	}
+
+	switch {
+	case f.currentSegment == nil:
+		f.currentSegment = &formatMKVSegment{
+			f:        f,
+			startDTS: dts,
+			startNTP: ntp,
+			number:   f.nextSegmentNumber,
+		}
+		f.currentSegment.initialize()
+		f.nextSegmentNumber++
+
+	case (!f.hasVideo || isVideo) &&
+		randomAccess &&
+		f.ri.segmentEnded(f.currentSegment.startDTS, f.currentSegment.startNTP, dts):
+		f.currentSegment.lastDTS = dts
+		err := f.currentSegment.close()
+		if err != nil {
+			return err
+		}
+
+		f.currentSegment = &formatMKVSegment{
+			f:        f,
+			startDTS: dts,
+			startNTP: ntp,
+			number:   f.nextSegmentNumber,
+		}
+		f.currentSegment.initialize()
+		f.nextSegmentNumber++
+	}
+
+	return f.currentSegment.write(track, dts, pts, randomAccess, payload)
+}
@@ -0,0 +1,165 @@
+package recorder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/protocols/mkv"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+type formatMKVSegment struct {
+	f        *formatMKV
+	startDTS time.Duration
+	startNTP time.Time
+	number   uint64
+
+	path       string
+	fi         recordstore.SegmentFile
+	w          *mkv.Writer
+	curCluster *mkv.Cluster
+	clusterDTS time.Duration
+	size       uint64
+	lastDTS    time.Duration
+}
+
+func (s *formatMKVSegment) initialize() {
+	s.lastDTS = s.startDTS
+}
+
+func (s *formatMKVSegment) close() error {
+	var err error
+
+	if s.curCluster != nil {
+		err = s.flushCluster()
+	}
+
+	if s.fi != nil {
+		s.f.ri.Log(logger.Debug, "closing segment %s", s.path)
+
+		// write cues and overall duration to allow seeking
+		duration := s.lastDTS - s.startDTS
+		err2 := s.w.Close(duration)
+		if err == nil {
+			err = err2
+		}
+
+		err2 = s.fi.Close()
+		if err == nil {
+			err = err2
+		}
+
+		if err2 == nil {
+			s.f.ri.segmentCompleted(s.path, s.number, duration)
+		}
+	}
+
+	return err
+}
+
+func (s *formatMKVSegment) flushCluster() error {
+	if s.fi == nil {
+		s.path = recordstore.Path{Start: s.startNTP}.Encode(s.f.ri.pathFormat2)
+		s.f.ri.Log(logger.Debug, "creating segment %s", s.path)
+
+		err := os.MkdirAll(filepath.Dir(s.path), 0o755)
+		if err != nil {
+			return err
+		}
+
+		fi, err := recordstore.CreateSegmentFile(s.path, s.f.ri.encryptionKey)
+		if err != nil {
+			return err
+		}
+
+		s.f.ri.segmentCreated(s.path)
+
+		tracks := make([]*mkv.Track, len(s.f.tracks))
+		for i, track := range s.f.tracks {
+			tracks[i] = track.track
+		}
+
+		docType := "matroska"
+		if s.f.webm {
+			docType = "webm"
+		}
+
+		w := &mkv.Writer{
+			W:       fi,
+			DocType: docType,
+			Date:    s.startNTP,
+			Tags: recordstore.MKVInfo{
+				StreamID:      s.f.ri.streamID,
+				SegmentNumber: s.number,
+				DTS:           int64(s.startDTS),
+			}.Marshal(),
+			Tracks: tracks,
+		}
+		err = w.Initialize()
+		if err != nil {
+			fi.Close()
+			return err
+		}
+
+		s.fi = fi
+		s.w = w
+	}
+
+	c := s.curCluster
+	s.curCluster = nil
+	s.size = 0
+
+	return s.w.WriteCluster(c)
+}
+
+func (s *formatMKVSegment) write(
+	track *formatMKVTrack,
+	dts time.Duration,
+	pts time.Duration,
+	randomAccess bool,
+	payload []byte,
+) error {
+	if dts > s.lastDTS {
+		s.lastDTS = dts
+	}
+
+	ts := pts - s.startDTS
+	if ts < 0 {
+		s.f.ri.Log(logger.Warn, "sample of track %d has a timestamp that precedes the segment, discarding",
+			track.track.Number)
+		return nil
+	}
+
+	if s.curCluster != nil &&
+		((dts-s.clusterDTS) >= s.f.ri.partDuration || !s.curCluster.Accepts(ts)) {
+		err := s.flushCluster()
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.curCluster == nil {
+		s.curCluster = &mkv.Cluster{
+			Timestamp: ts,
+		}
+		s.clusterDTS = dts
+	}
+
+	size := uint64(len(payload))
+	if (s.size + size) > uint64(s.f.ri.maxPartSize) {
+		return fmt.Errorf("reached maximum part size")
+	}
+	s.size += size
+
+	s.curCluster.Blocks = append(s.curCluster.Blocks, &mkv.Block{
+		TrackNumber: track.track.Number,
+		Timestamp:   ts,
+		Keyframe:    randomAccess,
+		Payload:     payload,
+	})
+
+	return nil
+}
@@ -56,11 +56,12 @@ func (d *dynamicWriter) setTarget(w io.Writer) {
 type formatMPEGTS struct {
 	ri *recorderInstance
 
-	dw             *dynamicWriter
-	bw             *bufio.Writer
-	mw             *mpegts.Writer
-	hasVideo       bool
-	currentSegment *formatMPEGTSSegment
+	dw                *dynamicWriter
+	bw                *bufio.Writer
+	mw                *mpegts.Writer
+	hasVideo          bool
+	currentSegment    *formatMPEGTSSegment
+	nextSegmentNumber uint64
 }
 
 func (f *formatMPEGTS) initialize() bool {
@@ -74,8 +75,15 @@ func (f *formatMPEGTS) initialize() bool {
 		return track
 	}
 
+	trackNum := 0
+
 	for _, media := range f.ri.stream.Desc.Medias {
 		for _, forma := range media.Formats {
+			trackNum++
+			if !f.ri.trackSelected(trackNum, media, forma) {
+				continue
+			}
+
 			clockRate := forma.ClockRate()
 
 			switch forma := forma.(type) {
@@ -84,7 +92,7 @@ func (f *formatMPEGTS) initialize() bool {
 
 				var dtsExtractor *h265.DTSExtractor
 
-				f.ri.reader.OnData(
+				f.ri.onData(
 					media,
 					forma,
 					func(u *unit.Unit) error {
@@ -127,7 +135,7 @@ func (f *formatMPEGTS) initialize() bool {
 
 				var dtsExtractor *h264.DTSExtractor
 
-				f.ri.reader.OnData(
+				f.ri.onData(
 					media,
 					forma,
 					func(u *unit.Unit) error {
@@ -171,7 +179,7 @@ func (f *formatMPEGTS) initialize() bool {
 				firstReceived := false
 				var lastPTS int64
 
-				f.ri.reader.OnData(
+				f.ri.onData(
 					media,
 					forma,
 					func(u *unit.Unit) error {
@@ -209,7 +217,7 @@ func (f *formatMPEGTS) initialize() bool {
 				firstReceived := false
 				var lastPTS int64
 
-				f.ri.reader.OnData(
+				f.ri.onData(
 					media,
 					forma,
 					func(u *unit.Unit) error {
@@ -245,7 +253,7 @@ func (f *formatMPEGTS) initialize() bool {
 					ChannelCount: forma.ChannelCount,
 				})
 
-				f.ri.reader.OnData(
+				f.ri.onData(
 					media,
 					forma,
 					func(u *unit.Unit) error {
@@ -272,7 +280,7 @@ func (f *formatMPEGTS) initialize() bool {
 					Synchronous: true,
 				})
 
-				f.ri.reader.OnData(
+				f.ri.onData(
 					media,
 					forma,
 					func(u *unit.Unit) error {
@@ -296,7 +304,7 @@ func (f *formatMPEGTS) initialize() bool {
 					Config: *forma.Config,
 				})
 
-				f.ri.reader.OnData(
+				f.ri.onData(
 					media,
 					forma,
 					func(u *unit.Unit) error {
@@ -324,7 +332,7 @@ func (f *formatMPEGTS) initialize() bool {
 						Config: *forma.StreamMuxConfig.Programs[0].Layers[0].AudioSpecificConfig,
 					})
 
-					f.ri.reader.OnData(
+					f.ri.onData(
 						media,
 						forma,
 						func(u *unit.Unit) error {
@@ -357,7 +365,7 @@ func (f *formatMPEGTS) initialize() bool {
 			case *rtspformat.MPEG1Audio:
 				track := addTrack(&mpegts.CodecMPEG1Audio{})
 
-				f.ri.reader.OnData(
+				f.ri.onData(
 					media,
 					forma,
 					func(u *unit.Unit) error {
@@ -382,7 +390,7 @@ func (f *formatMPEGTS) initialize() bool {
 			case *rtspformat.AC3:
 				track := addTrack(&mpegts.CodecAC3{})
 
-				f.ri.reader.OnData(
+				f.ri.onData(
 					media,
 					forma,
 					func(u *unit.Unit) error {
@@ -426,7 +434,7 @@ func (f *formatMPEGTS) initialize() bool {
 	n := 1
 	for _, medi := range f.ri.stream.Desc.Medias {
 		for _, forma := range medi.Formats {
-			if !slices.Contains(setuppedFormats, forma) {
+			if !slices.Contains(setuppedFormats, forma) && f.ri.trackSelected(n, medi, forma) {
 				f.ri.Log(logger.Warn, "skipping track %d (%s)", n, forma.Codec())
 			}
 			n++
@@ -454,6 +462,16 @@ func (f *formatMPEGTS) close() {
 	}
 }
 
+func (f *formatMPEGTS) closeSegment() error {
+	if f.currentSegment == nil {
+		return nil
+	}
+
+	err := f.currentSegment.close()
+	f.currentSegment = nil
+	return err
+}
+
 func (f *formatMPEGTS) write(
 	dts time.Duration,
 	ntp time.Time,
@@ -470,17 +488,20 @@ func (f *formatMPEGTS) write(
 		f.currentSegment = &formatMPEGTSSegment{
 			pathFormat2:       f.ri.pathFormat2,
 			flush:             f.bw.Flush,
-			onSegmentCreate:   f.ri.onSegmentCreate,
-			onSegmentComplete: f.ri.onSegmentComplete,
+			onSegmentCreate:   f.ri.segmentCreated,
+			onSegmentComplete: f.ri.segmentCompleted,
+			encryptionKey:     f.ri.encryptionKey,
 			startDTS:          dts,
 			startNTP:          ntp,
+			number:            f.nextSegmentNumber,
 			log:               f.ri,
 		}
 		f.currentSegment.initialize()
 		f.dw.setTarget(f.currentSegment)
+		f.nextSegmentNumber++
 	case (!f.hasVideo || isVideo) &&
 		randomAccess &&
-		(dts-f.currentSegment.startDTS) >= f.ri.segmentDuration:
+		f.ri.segmentEnded(f.currentSegment.startDTS, f.currentSegment.startNTP, dts):
 		f.currentSegment.lastDTS = dts
 		err := f.currentSegment.close()
 		if err != nil {
@@ -490,14 +511,17 @@ func (f *formatMPEGTS) write(
 		f.currentSegment = &formatMPEGTSSegment{
 			pathFormat2:       f.ri.pathFormat2,
 			flush:             f.bw.Flush,
-			onSegmentCreate:   f.ri.onSegmentCreate,
-			onSegmentComplete: f.ri.onSegmentComplete,
+			onSegmentCreate:   f.ri.segmentCreated,
+			onSegmentComplete: f.ri.segmentCompleted,
+			encryptionKey:     f.ri.encryptionKey,
 			startDTS:          dts,
 			startNTP:          ntp,
+			number:            f.nextSegmentNumber,
 			log:               f.ri,
 		}
 		f.currentSegment.initialize()
 		f.dw.setTarget(f.currentSegment)
+		f.nextSegmentNumber++
 
 	case (dts - f.currentSegment.lastFlush) >= f.ri.partDuration:
 		err := f.bw.Flush()
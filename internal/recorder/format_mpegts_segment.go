@@ -12,14 +12,16 @@ import (
 type formatMPEGTSSegment struct {
 	pathFormat2       string
 	flush             func() error
-	onSegmentCreate   OnSegmentCreateFunc
-	onSegmentComplete OnSegmentCompleteFunc
+	onSegmentCreate   func(path string)
+	onSegmentComplete func(path string, number uint64, duration time.Duration)
 	startDTS          time.Duration
 	startNTP          time.Time
+	number            uint64
+	encryptionKey     *recordstore.EncryptionKey
 	log               logger.Writer
 
 	path      string
-	fi        *os.File
+	fi        recordstore.SegmentFile
 	lastFlush time.Duration
 	lastDTS   time.Duration
 }
@@ -41,7 +43,7 @@ func (s *formatMPEGTSSegment) close() error {
 
 		if err2 == nil {
 			duration := s.lastDTS - s.startDTS
-			s.onSegmentComplete(s.path, duration)
+			s.onSegmentComplete(s.path, s.number, duration)
 		}
 	}
 
@@ -58,7 +60,7 @@ func (s *formatMPEGTSSegment) Write(p []byte) (int, error) {
 			return 0, err
 		}
 
-		fi, err := os.Create(s.path)
+		fi, err := recordstore.CreateSegmentFile(s.path, s.encryptionKey)
 		if err != nil {
 			return 0, err
 		}
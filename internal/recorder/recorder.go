@@ -6,6 +6,7 @@ import (
 
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
 
@@ -22,14 +23,21 @@ type Recorder struct {
 	PartDuration      time.Duration
 	MaxPartSize       conf.StringSize
 	SegmentDuration   time.Duration
+	SegmentAlign      bool
+	KeyframeInterval  time.Duration
+	Tracks            []string
 	PathName          string
 	Stream            *stream.Stream
+	TriggerMode       bool
+	PreRoll           time.Duration
+	EncryptionKey     *recordstore.EncryptionKey
 	OnSegmentCreate   OnSegmentCreateFunc
 	OnSegmentComplete OnSegmentCompleteFunc
 	Parent            logger.Writer
 
 	restartPause time.Duration
 
+	triggerRequests *triggerRequests
 	currentInstance *recorderInstance
 
 	terminate chan struct{}
@@ -50,6 +58,7 @@ func (r *Recorder) Initialize() {
 		r.restartPause = 2 * time.Second
 	}
 
+	r.triggerRequests = &triggerRequests{}
 	r.terminate = make(chan struct{})
 	r.done = make(chan struct{})
 
@@ -59,8 +68,15 @@ func (r *Recorder) Initialize() {
 		partDuration:      r.PartDuration,
 		maxPartSize:       r.MaxPartSize,
 		segmentDuration:   r.SegmentDuration,
+		segmentAlign:      r.SegmentAlign,
+		keyframeInterval:  r.KeyframeInterval,
+		tracks:            r.Tracks,
 		pathName:          r.PathName,
 		stream:            r.Stream,
+		triggerMode:       r.TriggerMode,
+		preRoll:           r.PreRoll,
+		encryptionKey:     r.EncryptionKey,
+		triggerRequests:   r.triggerRequests,
 		onSegmentCreate:   r.OnSegmentCreate,
 		onSegmentComplete: r.OnSegmentComplete,
 		parent:            r,
@@ -82,6 +98,13 @@ func (r *Recorder) Close() {
 	<-r.done
 }
 
+// Trigger writes the buffered pre-roll and the following postRoll to disk.
+// When a triggered recording is in progress, it is extended.
+// It is only effective when TriggerMode is true.
+func (r *Recorder) Trigger(postRoll time.Duration) {
+	r.triggerRequests.push(postRoll)
+}
+
 func (r *Recorder) run() {
 	defer close(r.done)
 
@@ -106,8 +129,15 @@ func (r *Recorder) run() {
 			partDuration:      r.PartDuration,
 			maxPartSize:       r.MaxPartSize,
 			segmentDuration:   r.SegmentDuration,
+			segmentAlign:      r.SegmentAlign,
+			keyframeInterval:  r.KeyframeInterval,
+			tracks:            r.Tracks,
 			pathName:          r.PathName,
 			stream:            r.Stream,
+			triggerMode:       r.TriggerMode,
+			preRoll:           r.PreRoll,
+			encryptionKey:     r.EncryptionKey,
+			triggerRequests:   r.triggerRequests,
 			onSegmentCreate:   r.OnSegmentCreate,
 			onSegmentComplete: r.OnSegmentComplete,
 			parent:            r,
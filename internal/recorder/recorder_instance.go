@@ -1,9 +1,12 @@
 package recorder
 
 import (
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
+	rtspformat "github.com/bluenviron/gortsplib/v5/pkg/format"
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
 	"github.com/google/uuid"
 
@@ -19,23 +22,40 @@ type sample struct {
 	ntp time.Time
 }
 
+// alignedSegmentDuration returns the duration of a segment that starts at the given time
+// and ends at the next multiple of segmentDuration since midnight.
+func alignedSegmentDuration(start time.Time, segmentDuration time.Duration) time.Duration {
+	y, m, d := start.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, start.Location())
+	elapsed := start.Sub(midnight)
+	return (elapsed/segmentDuration+1)*segmentDuration - elapsed
+}
+
 type recorderInstance struct {
 	pathFormat        string
 	format            conf.RecordFormat
 	partDuration      time.Duration
 	maxPartSize       conf.StringSize
 	segmentDuration   time.Duration
+	segmentAlign      bool
+	keyframeInterval  time.Duration
+	tracks            []string
 	pathName          string
 	stream            *stream.Stream
+	triggerMode       bool
+	preRoll           time.Duration
+	encryptionKey     *recordstore.EncryptionKey
+	triggerRequests   *triggerRequests
 	onSegmentCreate   OnSegmentCreateFunc
 	onSegmentComplete OnSegmentCompleteFunc
 	parent            logger.Writer
 
-	streamID    uuid.UUID
-	pathFormat2 string
-	format2     format
-	skip        bool
-	reader      *stream.Reader
+	streamID      uuid.UUID
+	pathFormat2   string
+	format2       format
+	skip          bool
+	reader        *stream.Reader
+	triggerBuffer *triggerBuffer
 
 	terminate chan struct{}
 	done      chan struct{}
@@ -58,6 +78,17 @@ func (ri *recorderInstance) initialize() {
 		Parent:        ri,
 	}
 
+	if ri.triggerMode {
+		ri.triggerBuffer = &triggerBuffer{
+			preRoll:  ri.preRoll,
+			requests: ri.triggerRequests,
+			onClipEnd: func() error {
+				return ri.format2.closeSegment()
+			},
+			log: ri,
+		}
+	}
+
 	ri.terminate = make(chan struct{})
 	ri.done = make(chan struct{})
 
@@ -69,6 +100,14 @@ func (ri *recorderInstance) initialize() {
 		ok := ri.format2.initialize()
 		ri.skip = !ok
 
+	case conf.RecordFormatMKV, conf.RecordFormatWebM:
+		ri.format2 = &formatMKV{
+			ri:   ri,
+			webm: ri.format == conf.RecordFormatWebM,
+		}
+		ok := ri.format2.initialize()
+		ri.skip = !ok
+
 	default:
 		ri.format2 = &formatFMP4{
 			ri: ri,
@@ -84,6 +123,98 @@ func (ri *recorderInstance) initialize() {
 	go ri.run()
 }
 
+// onData is used by formats in place of reader.OnData, in order to route units
+// through the trigger buffer when recording is triggered.
+func (ri *recorderInstance) onData(
+	media *description.Media,
+	forma rtspformat.Format,
+	cb stream.OnDataFunc,
+) {
+	if ri.triggerBuffer != nil {
+		cb = ri.triggerBuffer.wrap(media, cb)
+	}
+	ri.reader.OnData(media, forma, cb)
+}
+
+// segmentCreated is called by formats when a segment is created.
+func (ri *recorderInstance) segmentCreated(path string) {
+	err := recordstore.IndexAddSegment(ri.pathFormat, ri.pathName, path)
+	if err != nil {
+		ri.Log(logger.Warn, "unable to update recording index: %v", err)
+	}
+
+	ri.onSegmentCreate(path)
+}
+
+// segmentCompleted is called by formats when a segment is complete.
+func (ri *recorderInstance) segmentCompleted(path string, number uint64, duration time.Duration) {
+	err := recordstore.IndexCompleteSegment(ri.pathFormat, ri.pathName, path, recordstore.SegmentInfo{
+		Duration:      duration,
+		StreamID:      ri.streamID,
+		SegmentNumber: number,
+	})
+	if err != nil {
+		ri.Log(logger.Warn, "unable to update recording index: %v", err)
+	}
+
+	ri.onSegmentComplete(path, duration)
+}
+
+// keyframesCompleted is called by formats when the keyframe index of a complete segment is available.
+func (ri *recorderInstance) keyframesCompleted(path string, keyframes []recordstore.Keyframe) {
+	err := recordstore.WriteKeyframes(ri.pathFormat, ri.pathName, path, keyframes)
+	if err != nil {
+		ri.Log(logger.Warn, "unable to write keyframe index: %v", err)
+	}
+}
+
+// segmentEnded returns whether a segment can be closed at the given DTS.
+func (ri *recorderInstance) segmentEnded(startDTS time.Duration, startNTP time.Time, dts time.Duration) bool {
+	if ri.segmentAlign {
+		return (dts - startDTS) >= alignedSegmentDuration(startNTP, ri.segmentDuration)
+	}
+	return (dts - startDTS) >= ri.segmentDuration
+}
+
+// trackSelected returns whether a track has to be recorded.
+// Tracks are numbered starting from 1.
+func (ri *recorderInstance) trackSelected(n int, media *description.Media, forma rtspformat.Format) bool {
+	if len(ri.tracks) == 0 {
+		return true
+	}
+
+	for _, sel := range ri.tracks {
+		switch {
+		case strings.HasPrefix(sel, "track:"):
+			if sel[len("track:"):] == strconv.Itoa(n) {
+				return true
+			}
+
+		case strings.HasPrefix(sel, "codec:"):
+			if strings.EqualFold(sel[len("codec:"):], forma.Codec()) {
+				return true
+			}
+
+		case strings.HasPrefix(sel, "pid:"):
+			if props, ok := ri.stream.MediaProperties[media]; ok &&
+				sel[len("pid:"):] == strconv.FormatUint(uint64(props.PID), 10) {
+				return true
+			}
+
+		case strings.HasPrefix(sel, "lang:"):
+			if props, ok := ri.stream.MediaProperties[media]; ok &&
+				props.Language != "" && strings.EqualFold(sel[len("lang:"):], props.Language) {
+				return true
+			}
+
+		case string(media.Type) == sel:
+			return true
+		}
+	}
+
+	return false
+}
+
 func (ri *recorderInstance) close() {
 	close(ri.terminate)
 	<-ri.done
@@ -1,7 +1,9 @@
 package recorder
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
@@ -10,13 +12,16 @@ import (
 	amp4 "github.com/abema/go-mp4"
 	"github.com/bluenviron/gortsplib/v5/pkg/description"
 	rtspformat "github.com/bluenviron/gortsplib/v5/pkg/format"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
 	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4video"
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
 	"github.com/stretchr/testify/require"
 
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/protocols/mkv"
 	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/stream"
 	"github.com/bluenviron/mediamtx/internal/test"
@@ -114,7 +119,7 @@ func TestRecorder(t *testing.T) {
 		}
 	}
 
-	for _, ca := range []string{"fmp4", "mpegts"} {
+	for _, ca := range []string{"fmp4", "mpegts", "mkv"} {
 		t.Run(ca, func(t *testing.T) {
 			strm := &stream.Stream{
 				WriteQueueSize:     512,
@@ -137,17 +142,20 @@ func TestRecorder(t *testing.T) {
 			segDone := make(chan struct{}, 4)
 
 			var f conf.RecordFormat
-			if ca == "fmp4" {
-				f = conf.RecordFormatFMP4
-			} else {
-				f = conf.RecordFormatMPEGTS
-			}
-
 			var ext string
-			if ca == "fmp4" {
+
+			switch ca {
+			case "fmp4":
+				f = conf.RecordFormatFMP4
 				ext = "mp4"
-			} else {
+
+			case "mpegts":
+				f = conf.RecordFormatMPEGTS
 				ext = "ts"
+
+			default:
+				f = conf.RecordFormatMKV
+				ext = "mkv"
 			}
 
 			n := 0
@@ -282,6 +290,81 @@ func TestRecorder(t *testing.T) {
 					},
 				}, init)
 
+				_, err = os.Stat(filepath.Join(dir, "mypath", "2008-05-20_22-16-25-000000."+ext))
+				require.NoError(t, err)
+			} else if ca == "mkv" {
+				func() {
+					f, err2 := os.Open(filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000."+ext))
+					require.NoError(t, err2)
+					defer f.Close()
+
+					r := &mkv.Reader{R: f}
+					err2 = r.Initialize()
+					require.NoError(t, err2)
+
+					require.Equal(t, "matroska", r.DocType)
+					require.Equal(t, 2*time.Second, r.Duration)
+					require.Equal(t, time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC), r.Date.UTC())
+
+					var info recordstore.MKVInfo
+					ok := info.Unmarshal(r.Tags)
+					require.True(t, ok)
+					require.Equal(t, int64(50000000000), info.DTS)
+					require.Equal(t, uint64(0), info.SegmentNumber)
+
+					require.Equal(t, []*mkv.Track{
+						{
+							Number: 1,
+							Codec: &mkv.CodecH264{
+								SPS: test.FormatH264.SPS,
+								PPS: test.FormatH264.PPS,
+							},
+						},
+						{
+							Number: 2,
+							Codec: &mkv.CodecH265{
+								VPS: test.FormatH265.VPS,
+								SPS: test.FormatH265.SPS,
+								PPS: test.FormatH265.PPS,
+							},
+						},
+						{
+							Number: 3,
+							Codec: &mkv.CodecMPEG4Audio{
+								Config: mpeg4audio.AudioSpecificConfig{
+									Type:         2,
+									SampleRate:   44100,
+									ChannelCount: 2,
+								},
+							},
+						},
+						{
+							Number: 4,
+							Codec: &mkv.CodecLPCM{
+								BitDepth:     16,
+								SampleRate:   8000,
+								ChannelCount: 1,
+							},
+						},
+						{
+							Number: 5,
+							Codec: &mkv.CodecLPCM{
+								BitDepth:     16,
+								SampleRate:   44100,
+								ChannelCount: 2,
+							},
+						},
+					}, r.Tracks)
+
+					n := 0
+					err2 = r.ReadBlocks(func(_ *mkv.BlockInfo) error {
+						n++
+						return nil
+					})
+					require.NoError(t, err2)
+					require.Equal(t, 10, n)
+				}()
+
 				_, err = os.Stat(filepath.Join(dir, "mypath", "2008-05-20_22-16-25-000000."+ext))
 				require.NoError(t, err)
 			} else {
@@ -407,6 +490,269 @@ func TestRecorderFMP4NegativeDTS(t *testing.T) {
 	require.True(t, found)
 }
 
+func TestRecorderMKVBFrames(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{{
+		Type: description.MediaTypeVideo,
+		Formats: []rtspformat.Format{&rtspformat.MPEG4Video{
+			PayloadTyp: 96,
+		}},
+	}}}
+
+	strm := &stream.Stream{
+		WriteQueueSize:     512,
+		RTPMaxPayloadSize:  1450,
+		Desc:               desc,
+		GenerateRTPPackets: true,
+		Parent:             test.NilLogger,
+	}
+	err := strm.Initialize()
+	require.NoError(t, err)
+	defer strm.Close()
+
+	dir, err := os.MkdirTemp("", "mediamtx-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recordPath := filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")
+
+	w := &Recorder{
+		PathFormat:      recordPath,
+		Format:          conf.RecordFormatMKV,
+		PartDuration:    100 * time.Millisecond,
+		MaxPartSize:     50 * 1024 * 1024,
+		SegmentDuration: 1 * time.Hour,
+		PathName:        "mypath",
+		Stream:          strm,
+		Parent:          test.NilLogger,
+	}
+	w.Initialize()
+
+	// frames in decoding order: I P B B P B B
+	for i, pts := range []int64{0, 120, 40, 80, 240, 160, 200} {
+		payload := unit.PayloadMPEG4Video{0, 0, 1, 0xB6, byte(i)}
+		if i == 0 {
+			payload = append(unit.PayloadMPEG4Video{
+				0, 0, 1, byte(mpeg4video.VisualObjectSequenceStartCode),
+				0, 0, 1, 0xFF,
+				0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode),
+			}, payload...)
+		}
+
+		strm.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.Unit{
+			PTS:     pts * 90000 / 1000,
+			NTP:     time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC),
+			Payload: payload,
+		})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	w.Close()
+
+	f, err := os.Open(filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mkv"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	r := &mkv.Reader{R: f}
+	err = r.Initialize()
+	require.NoError(t, err)
+
+	var timestamps []time.Duration
+	err = r.ReadBlocks(func(b *mkv.BlockInfo) error {
+		timestamps = append(timestamps, b.Timestamp)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []time.Duration{
+		0,
+		120 * time.Millisecond,
+		40 * time.Millisecond,
+		80 * time.Millisecond,
+		240 * time.Millisecond,
+		160 * time.Millisecond,
+		200 * time.Millisecond,
+	}, timestamps)
+}
+
+func TestRecorderEncryption(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type: description.MediaTypeVideo,
+			Formats: []rtspformat.Format{&rtspformat.H264{
+				PayloadTyp:        96,
+				PacketizationMode: 1,
+			}},
+		},
+	}}
+
+	strm := &stream.Stream{
+		WriteQueueSize:     512,
+		RTPMaxPayloadSize:  1450,
+		Desc:               desc,
+		GenerateRTPPackets: true,
+		Parent:             test.NilLogger,
+	}
+	err := strm.Initialize()
+	require.NoError(t, err)
+	defer strm.Close()
+
+	dir, err := os.MkdirTemp("", "mediamtx-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recordPath := filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")
+
+	key := recordstore.EncryptionKey{1, 2, 3, 4}
+
+	w := &Recorder{
+		PathFormat:      recordPath,
+		Format:          conf.RecordFormatFMP4,
+		PartDuration:    100 * time.Millisecond,
+		MaxPartSize:     50 * 1024 * 1024,
+		SegmentDuration: 1 * time.Second,
+		PathName:        "mypath",
+		Stream:          strm,
+		EncryptionKey:   &key,
+		Parent:          test.NilLogger,
+	}
+	w.Initialize()
+
+	for i := 0; i < 3; i++ {
+		strm.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.Unit{
+			PTS: int64(i) * 200 * 90000 / 1000,
+			NTP: time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC),
+			Payload: unit.PayloadH264{
+				test.FormatH264.SPS,
+				test.FormatH264.PPS,
+				{5}, // IDR
+			},
+		})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	w.Close()
+
+	fpath := filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mp4")
+
+	raw, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(raw, []byte("ftyp")))
+
+	f, err := recordstore.OpenSegmentFile(fpath, []recordstore.EncryptionKey{key})
+	require.NoError(t, err)
+	defer f.Close()
+
+	byts, err := io.ReadAll(f)
+	require.NoError(t, err)
+
+	var init fmp4.Init
+	err = init.Unmarshal(bytes.NewReader(byts))
+	require.NoError(t, err)
+	require.Len(t, init.Tracks, 1)
+
+	var parts fmp4.Parts
+	err = parts.Unmarshal(byts)
+	require.NoError(t, err)
+	require.NotEmpty(t, parts)
+}
+
+func TestRecorderKeyframes(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type: description.MediaTypeVideo,
+			Formats: []rtspformat.Format{&rtspformat.H264{
+				PayloadTyp:        96,
+				PacketizationMode: 1,
+			}},
+		},
+		{
+			Type: description.MediaTypeAudio,
+			Formats: []rtspformat.Format{&rtspformat.MPEG4Audio{
+				PayloadTyp: 96,
+				Config: &mpeg4audio.AudioSpecificConfig{
+					Type:         2,
+					SampleRate:   44100,
+					ChannelCount: 2,
+				},
+				SizeLength:       13,
+				IndexLength:      3,
+				IndexDeltaLength: 3,
+			}},
+		},
+	}}
+
+	strm := &stream.Stream{
+		WriteQueueSize:     512,
+		RTPMaxPayloadSize:  1450,
+		Desc:               desc,
+		GenerateRTPPackets: true,
+		Parent:             test.NilLogger,
+	}
+	err := strm.Initialize()
+	require.NoError(t, err)
+	defer strm.Close()
+
+	dir, err := os.MkdirTemp("", "mediamtx-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recordPath := filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")
+
+	w := &Recorder{
+		PathFormat:       recordPath,
+		Format:           conf.RecordFormatFMP4,
+		PartDuration:     100 * time.Millisecond,
+		MaxPartSize:      50 * 1024 * 1024,
+		SegmentDuration:  1 * time.Hour,
+		KeyframeInterval: 500 * time.Millisecond,
+		PathName:         "mypath",
+		Stream:           strm,
+		Parent:           test.NilLogger,
+	}
+	w.Initialize()
+
+	for i := 0; i < 10; i++ {
+		strm.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.Unit{
+			PTS: int64(i) * 200 * 90000 / 1000,
+			NTP: time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC),
+			Payload: unit.PayloadH264{
+				test.FormatH264.SPS,
+				test.FormatH264.PPS,
+				{5, byte(i)}, // IDR
+			},
+		})
+
+		strm.WriteUnit(desc.Medias[1], desc.Medias[1].Formats[0], &unit.Unit{
+			PTS:     int64(i) * 200 * 44100 / 1000,
+			Payload: unit.PayloadMPEG4Audio{{1, 2, 3, 4}},
+		})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	w.Close()
+
+	fpath := filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mp4")
+
+	keyframes, err := recordstore.ReadKeyframes(recordPath, "mypath", fpath)
+	require.NoError(t, err)
+	require.Len(t, keyframes, 3)
+
+	byts, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+
+	for i, k := range keyframes {
+		require.Equal(t, time.Duration(i)*600*time.Millisecond, k.Time)
+
+		var au h264.AVCC
+		err = au.Unmarshal(byts[k.Offset : k.Offset+k.Size])
+		require.NoError(t, err)
+		require.Equal(t, []byte{5, byte(i * 3)}, au[len(au)-1])
+	}
+}
+
 func TestRecorderSkipTracksPartial(t *testing.T) {
 	for _, ca := range []string{"fmp4", "mpegts"} {
 		t.Run(ca, func(t *testing.T) {
@@ -535,6 +881,78 @@ func TestRecorderSkipTracksFull(t *testing.T) {
 	}
 }
 
+func TestRecorderTracks(t *testing.T) {
+	for _, ca := range []string{"fmp4", "mpegts", "mkv"} {
+		for _, sel := range []string{"track:2", "pid:257", "lang:ENG"} {
+			t.Run(ca+"_"+sel, func(t *testing.T) {
+				desc := &description.Session{Medias: []*description.Media{
+					{
+						Type:    description.MediaTypeVideo,
+						Formats: []rtspformat.Format{test.FormatH264},
+					},
+					{
+						Type:    description.MediaTypeAudio,
+						Formats: []rtspformat.Format{test.FormatMPEG4Audio},
+					},
+				}}
+
+				strm := &stream.Stream{
+					WriteQueueSize:    512,
+					RTPMaxPayloadSize: 1450,
+					Desc:              desc,
+					MediaProperties: map[*description.Media]*stream.MediaProperties{
+						desc.Medias[0]: {PID: 256},
+						desc.Medias[1]: {PID: 257, Language: "eng"},
+					},
+					GenerateRTPPackets: true,
+					Parent:             test.NilLogger,
+				}
+				err := strm.Initialize()
+				require.NoError(t, err)
+				defer strm.Close()
+
+				dir, err := os.MkdirTemp("", "mediamtx-agent")
+				require.NoError(t, err)
+				defer os.RemoveAll(dir)
+
+				recordPath := filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")
+
+				var msgs []string
+
+				l := test.Logger(func(_ logger.Level, format string, args ...interface{}) {
+					msgs = append(msgs, fmt.Sprintf(format, args...))
+				})
+
+				var fo conf.RecordFormat
+				switch ca {
+				case "fmp4":
+					fo = conf.RecordFormatFMP4
+				case "mpegts":
+					fo = conf.RecordFormatMPEGTS
+				default:
+					fo = conf.RecordFormatMKV
+				}
+
+				w := &Recorder{
+					PathFormat:      recordPath,
+					Format:          fo,
+					PartDuration:    100 * time.Millisecond,
+					MaxPartSize:     50 * 1024 * 1024,
+					SegmentDuration: 1 * time.Second,
+					PathName:        "mypath",
+					Stream:          strm,
+					Tracks:          []string{sel},
+					Parent:          l,
+				}
+				w.Initialize()
+				defer w.Close()
+
+				require.Equal(t, []string{"[recorder] recording 1 track (MPEG-4 Audio)"}, msgs)
+			})
+		}
+	}
+}
+
 func TestRecorderFMP4SegmentSwitch(t *testing.T) {
 	desc := &description.Session{Medias: []*description.Media{
 		{
@@ -642,3 +1060,169 @@ func TestRecorderFMP4SegmentSwitch(t *testing.T) {
 
 	require.Equal(t, 2, n)
 }
+
+func TestRecorderSegmentAlign(t *testing.T) {
+	for _, ca := range []string{"fmp4", "mpegts"} {
+		t.Run(ca, func(t *testing.T) {
+			desc := &description.Session{Medias: []*description.Media{
+				{
+					Type:    description.MediaTypeVideo,
+					Formats: []rtspformat.Format{test.FormatH264},
+				},
+			}}
+
+			strm := &stream.Stream{
+				WriteQueueSize:     512,
+				RTPMaxPayloadSize:  1450,
+				Desc:               desc,
+				GenerateRTPPackets: true,
+				Parent:             test.NilLogger,
+			}
+			err := strm.Initialize()
+			require.NoError(t, err)
+			defer strm.Close()
+
+			dir, err := os.MkdirTemp("", "mediamtx-agent")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			var format conf.RecordFormat
+			var ext string
+
+			if ca == "fmp4" {
+				format = conf.RecordFormatFMP4
+				ext = "mp4"
+			} else {
+				format = conf.RecordFormatMPEGTS
+				ext = "ts"
+			}
+
+			var created []string
+
+			w := &Recorder{
+				PathFormat:      filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				Format:          format,
+				PartDuration:    100 * time.Millisecond,
+				MaxPartSize:     50 * 1024 * 1024,
+				SegmentDuration: 10 * time.Second,
+				SegmentAlign:    true,
+				PathName:        "mypath",
+				Stream:          strm,
+				Parent:          test.NilLogger,
+				OnSegmentCreate: func(segPath string) {
+					created = append(created, segPath)
+				},
+			}
+			w.Initialize()
+
+			// segments end at the first keyframe after every multiple of 10 seconds.
+			for i := 0; i < 6; i++ {
+				strm.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.Unit{
+					PTS: int64(i) * 90000,
+					NTP: time.Date(2008, 5, 20, 22, 15, 27, 0, time.UTC).Add(time.Duration(i) * time.Second),
+					Payload: unit.PayloadH264{
+						test.FormatH264.SPS,
+						test.FormatH264.PPS,
+						{5}, // IDR
+					},
+				})
+			}
+
+			time.Sleep(100 * time.Millisecond)
+
+			w.Close()
+
+			require.Equal(t, []string{
+				filepath.Join(dir, "mypath", "2008-05-20_22-15-27-000000."+ext),
+				filepath.Join(dir, "mypath", "2008-05-20_22-15-30-000000."+ext),
+			}, created)
+		})
+	}
+}
+
+func TestRecorderTrigger(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type:    description.MediaTypeVideo,
+			Formats: []rtspformat.Format{test.FormatH264},
+		},
+	}}
+
+	strm := &stream.Stream{
+		WriteQueueSize:     512,
+		RTPMaxPayloadSize:  1450,
+		Desc:               desc,
+		GenerateRTPPackets: true,
+		Parent:             test.NilLogger,
+	}
+	err := strm.Initialize()
+	require.NoError(t, err)
+	defer strm.Close()
+
+	dir, err := os.MkdirTemp("", "mediamtx-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var created []string
+	var completed []time.Duration
+
+	w := &Recorder{
+		PathFormat:      filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		Format:          conf.RecordFormatFMP4,
+		PartDuration:    100 * time.Millisecond,
+		MaxPartSize:     50 * 1024 * 1024,
+		SegmentDuration: 1 * time.Hour,
+		PathName:        "mypath",
+		Stream:          strm,
+		TriggerMode:     true,
+		PreRoll:         3 * time.Second,
+		Parent:          test.NilLogger,
+		OnSegmentCreate: func(segPath string) {
+			created = append(created, segPath)
+		},
+		OnSegmentComplete: func(_ string, duration time.Duration) {
+			completed = append(completed, duration)
+		},
+	}
+	w.Initialize()
+
+	ntp := time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC)
+
+	// a frame every 500ms, an IDR every 2s
+	writeFrames := func(start int, end int) {
+		for i := start; i < end; i++ {
+			var payload unit.PayloadH264
+			if (i % 4) == 0 {
+				payload = unit.PayloadH264{{5}} // IDR
+			} else {
+				payload = unit.PayloadH264{{1}} // non-IDR
+			}
+
+			strm.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.Unit{
+				PTS:     int64(i) * 90000 / 2,
+				NTP:     ntp.Add(time.Duration(i) * 500 * time.Millisecond),
+				Payload: payload,
+			})
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	writeFrames(0, 14) // 0s - 6.5s
+	require.Empty(t, created)
+
+	w.Trigger(2 * time.Second)
+	writeFrames(14, 16) // 7s - 7.5s
+
+	// extend the clip
+	w.Trigger(2 * time.Second)
+	writeFrames(16, 22) // 8s - 10.5s
+
+	w.Close()
+
+	// pre-roll starts from the IDR at 2s, post-roll ends at 10s.
+	// the last frame is discarded since its duration is unknown.
+	require.Equal(t, []string{
+		filepath.Join(dir, "mypath", "2008-05-20_22-15-27-000000.mp4"),
+	}, created)
+	require.Equal(t, []time.Duration{7500 * time.Millisecond}, completed)
+}
@@ -0,0 +1,182 @@
+package recorder
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/av1"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4video"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/vp9"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+// unitIsRandomAccess checks whether decoding can start from a unit.
+func unitIsRandomAccess(media *description.Media, u *unit.Unit) bool {
+	if media.Type != description.MediaTypeVideo {
+		return true
+	}
+
+	switch payload := u.Payload.(type) {
+	case unit.PayloadH265:
+		return h265.IsRandomAccess(payload)
+
+	case unit.PayloadH264:
+		return h264.IsRandomAccess(payload)
+
+	case unit.PayloadAV1:
+		for _, obu := range payload {
+			if av1.OBUType((obu[0]>>3)&0b1111) == av1.OBUTypeSequenceHeader {
+				return true
+			}
+		}
+		return false
+
+	case unit.PayloadVP9:
+		var h vp9.Header
+		err := h.Unmarshal(payload)
+		return err == nil && !h.NonKeyFrame
+
+	case unit.PayloadMPEG4Video:
+		return bytes.Contains(payload, []byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)})
+
+	case unit.PayloadMPEG1Video:
+		return bytes.Contains(payload, []byte{0, 0, 1, 0xB8})
+
+	default:
+		return true
+	}
+}
+
+// triggerRequests collects recording triggers coming from other goroutines.
+type triggerRequests struct {
+	mutex    sync.Mutex
+	postRoll time.Duration
+}
+
+func (t *triggerRequests) push(postRoll time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if postRoll > t.postRoll {
+		t.postRoll = postRoll
+	}
+}
+
+func (t *triggerRequests) pop() time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	v := t.postRoll
+	t.postRoll = 0
+	return v
+}
+
+type triggerBufferEntry struct {
+	u            *unit.Unit
+	randomAccess bool
+	cb           func(*unit.Unit) error
+}
+
+// triggerBuffer keeps the last seconds of a stream in memory, starting from a random access point,
+// and routes them to the format when a recording is triggered.
+type triggerBuffer struct {
+	preRoll   time.Duration
+	requests  *triggerRequests
+	onClipEnd func() error
+	log       logger.Writer
+
+	hasVideo  bool
+	entries   []*triggerBufferEntry
+	recording bool
+	deadline  time.Time
+}
+
+func (b *triggerBuffer) wrap(media *description.Media, cb func(*unit.Unit) error) func(*unit.Unit) error {
+	isVideo := media.Type == description.MediaTypeVideo
+	if isVideo {
+		b.hasVideo = true
+	}
+
+	return func(u *unit.Unit) error {
+		return b.process(&triggerBufferEntry{
+			u:            u,
+			randomAccess: (!b.hasVideo || isVideo) && unitIsRandomAccess(media, u),
+			cb:           cb,
+		})
+	}
+}
+
+func (b *triggerBuffer) process(e *triggerBufferEntry) error {
+	if postRoll := b.requests.pop(); postRoll != 0 {
+		if deadline := e.u.NTP.Add(postRoll); deadline.After(b.deadline) {
+			b.deadline = deadline
+		}
+	}
+
+	if e.u.NTP.Before(b.deadline) {
+		if !b.recording {
+			b.recording = true
+			b.log.Log(logger.Info, "recording triggered")
+
+			entries := b.entries
+			b.entries = nil
+
+			for _, e2 := range entries {
+				err := e2.cb(e2.u)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return e.cb(e.u)
+	}
+
+	if b.recording {
+		b.recording = false
+		b.log.Log(logger.Info, "triggered recording ended")
+
+		err := b.onClipEnd()
+		if err != nil {
+			return err
+		}
+	}
+
+	b.push(e)
+	return nil
+}
+
+func (b *triggerBuffer) push(e *triggerBufferEntry) {
+	// the buffer must always start with a random access point
+	if len(b.entries) == 0 && !e.randomAccess {
+		return
+	}
+
+	b.entries = append(b.entries, e)
+
+	// remove the oldest group of units, as long as the remaining ones cover the pre-roll.
+	for {
+		next := -1
+		for i := 1; i < len(b.entries); i++ {
+			if b.entries[i].randomAccess {
+				next = i
+				break
+			}
+		}
+
+		if next < 0 || e.u.NTP.Sub(b.entries[next].u.NTP) < b.preRoll {
+			break
+		}
+
+		for i := range next {
+			b.entries[i] = nil
+		}
+		b.entries = b.entries[next:]
+	}
+}
@@ -0,0 +1,308 @@
+package recordrepairer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	amp4 "github.com/abema/go-mp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+var errIncomplete = errors.New("incomplete box")
+
+type repairResult struct {
+	action       defs.APIRecordingRepairAction
+	removedBytes uint64
+	duration     time.Duration
+	mtxi         *recordstore.Mtxi
+}
+
+type boxHeader struct {
+	typ       string
+	pos       int64
+	headerLen int64
+	size      int64
+}
+
+func (h boxHeader) end() int64 {
+	return h.pos + h.size
+}
+
+// readBoxHeader reads the header of a box and checks that the box is complete.
+func readBoxHeader(f io.ReaderAt, pos int64, fileSize int64) (boxHeader, error) {
+	buf := make([]byte, 16)
+
+	if (pos + 8) > fileSize {
+		return boxHeader{}, errIncomplete
+	}
+
+	_, err := f.ReadAt(buf[:8], pos)
+	if err != nil {
+		return boxHeader{}, err
+	}
+
+	h := boxHeader{
+		typ:       string(buf[4:8]),
+		pos:       pos,
+		headerLen: 8,
+		size:      int64(binary.BigEndian.Uint32(buf[:4])),
+	}
+
+	switch h.size {
+	case 0: // box extends to the end of the file, never used by the recorder
+		return boxHeader{}, errIncomplete
+
+	case 1: // 64-bit size
+		if (pos + 16) > fileSize {
+			return boxHeader{}, errIncomplete
+		}
+
+		_, err = f.ReadAt(buf[8:16], pos+8)
+		if err != nil {
+			return boxHeader{}, err
+		}
+
+		h.headerLen = 16
+		h.size = int64(binary.BigEndian.Uint64(buf[8:16]))
+	}
+
+	if h.size < h.headerLen || h.end() > fileSize {
+		return boxHeader{}, errIncomplete
+	}
+
+	return h, nil
+}
+
+func readInit(f io.ReaderAt, moov boxHeader) (*fmp4.Init, *amp4.Mvhd, error) {
+	buf := make([]byte, moov.size)
+	_, err := f.ReadAt(buf, moov.pos)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var init fmp4.Init
+	err = init.Unmarshal(bytes.NewReader(buf))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// mvhd is the first box of moov
+	if len(buf) < int(moov.headerLen+8) || string(buf[moov.headerLen+4:moov.headerLen+8]) != "mvhd" {
+		return nil, nil, fmt.Errorf("mvhd box not found")
+	}
+
+	var mvhd amp4.Mvhd
+	_, err = amp4.Unmarshal(bytes.NewReader(buf[moov.headerLen+8:]),
+		uint64(moov.size-moov.headerLen-8), &mvhd, amp4.Context{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &init, &mvhd, nil
+}
+
+// partEndTime returns the end time of a part, that is the maximum
+// end time of its tracks.
+func partEndTime(f io.ReaderAt, moof boxHeader, init *fmp4.Init) (time.Duration, error) {
+	buf := make([]byte, moof.size)
+	_, err := f.ReadAt(buf, moof.pos)
+	if err != nil {
+		return 0, err
+	}
+
+	var timeScale uint32
+	var baseTime uint64
+	var maxEnd time.Duration
+
+	_, err = amp4.ReadBoxStructure(bytes.NewReader(buf), func(h *amp4.ReadHandle) (interface{}, error) {
+		switch h.BoxInfo.Type.String() {
+		case "moof", "traf":
+			return h.Expand()
+
+		case "tfhd":
+			box, _, err2 := h.ReadPayload()
+			if err2 != nil {
+				return nil, err2
+			}
+			tfhd := box.(*amp4.Tfhd)
+
+			timeScale = 0
+			for _, track := range init.Tracks {
+				if track.ID == int(tfhd.TrackID) {
+					timeScale = track.TimeScale
+				}
+			}
+			if timeScale == 0 {
+				return nil, fmt.Errorf("invalid track ID: %v", tfhd.TrackID)
+			}
+
+		case "tfdt":
+			box, _, err2 := h.ReadPayload()
+			if err2 != nil {
+				return nil, err2
+			}
+			baseTime = box.(*amp4.Tfdt).BaseMediaDecodeTimeV1
+
+		case "trun":
+			box, _, err2 := h.ReadPayload()
+			if err2 != nil {
+				return nil, err2
+			}
+
+			end := baseTime
+			for _, e := range box.(*amp4.Trun).Entries {
+				end += uint64(e.SampleDuration)
+			}
+
+			endGo := time.Duration(end/uint64(timeScale))*time.Second +
+				time.Duration(end%uint64(timeScale))*time.Second/time.Duration(timeScale)
+			if endGo > maxEnd {
+				maxEnd = endGo
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return maxEnd, nil
+}
+
+func writeDuration(f io.WriteSeeker, moov boxHeader, mvhd *amp4.Mvhd, d time.Duration) error {
+	mvhd.DurationV0 = uint32(d * time.Duration(mvhd.Timescale) / time.Second)
+
+	_, err := f.Seek(moov.pos+moov.headerLen+8, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = amp4.Marshal(f, mvhd, amp4.Context{})
+	return err
+}
+
+// repairFMP4 repairs a fMP4 segment that has not been finalized by the recorder.
+// Trailing incomplete parts are removed and the overall duration is written into the header.
+// Segments that don't contain any complete part are deleted.
+// Encrypted segments are decrypted with one of the provided keys.
+// When the segment doesn't need to be repaired, the returned action is empty.
+func repairFMP4(fpath string, keys []recordstore.EncryptionKey) (*repairResult, error) {
+	f, err := recordstore.OpenSegmentFile(fpath, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := func() (*repairResult, error) {
+		defer f.Close()
+
+		fileSize, err2 := f.Size()
+		if err2 != nil {
+			return nil, err2
+		}
+
+		ftyp, err2 := readBoxHeader(f, 0, fileSize)
+		if err2 != nil || ftyp.typ != "ftyp" {
+			return &repairResult{action: defs.APIRecordingRepairActionRemoved}, nil
+		}
+
+		moov, err2 := readBoxHeader(f, ftyp.end(), fileSize)
+		if err2 != nil || moov.typ != "moov" {
+			return &repairResult{action: defs.APIRecordingRepairActionRemoved}, nil
+		}
+
+		init, mvhd, err2 := readInit(f, moov)
+		if err2 != nil {
+			return nil, err2
+		}
+
+		res := &repairResult{}
+
+		for _, box := range init.UserData {
+			if mtxi, ok := box.(*recordstore.Mtxi); ok {
+				res.mtxi = mtxi
+			}
+		}
+
+		// duration is written when the segment is closed
+		if mvhd.DurationV0 != 0 {
+			res.duration = time.Duration(mvhd.DurationV0) * time.Second / time.Duration(mvhd.Timescale)
+			return res, nil
+		}
+
+		// find last complete part
+
+		validEnd := moov.end()
+		var lastMoof *boxHeader
+
+		for {
+			moof, err3 := readBoxHeader(f, validEnd, fileSize)
+			if err3 != nil || moof.typ != "moof" {
+				break
+			}
+
+			mdat, err3 := readBoxHeader(f, moof.end(), fileSize)
+			if err3 != nil || mdat.typ != "mdat" {
+				break
+			}
+
+			lastMoof = &moof
+			validEnd = mdat.end()
+		}
+
+		if lastMoof == nil {
+			return &repairResult{action: defs.APIRecordingRepairActionRemoved}, nil
+		}
+
+		res.action = defs.APIRecordingRepairActionFinalized
+
+		if validEnd < fileSize {
+			err2 = f.Truncate(validEnd)
+			if err2 != nil {
+				return nil, err2
+			}
+
+			res.action = defs.APIRecordingRepairActionTruncated
+			res.removedBytes = uint64(fileSize - validEnd)
+		}
+
+		res.duration, err2 = partEndTime(f, *lastMoof, init)
+		if err2 != nil {
+			return nil, err2
+		}
+
+		// the mtxi box contains the stream ID, the segment number and the timestamps
+		// of the beginning of the segment, that are not affected by the removal
+		// of trailing parts, therefore only the duration needs to be updated.
+		err2 = writeDuration(f, moov, mvhd, res.duration)
+		if err2 != nil {
+			return nil, err2
+		}
+
+		return res, nil
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	if res != nil && res.action == defs.APIRecordingRepairActionRemoved {
+		fi, err2 := os.Stat(fpath)
+		if err2 == nil {
+			res.removedBytes = uint64(fi.Size())
+		}
+
+		err = os.Remove(fpath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
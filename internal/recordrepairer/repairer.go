@@ -0,0 +1,217 @@
+// Package recordrepairer contains the recording repairer.
+package recordrepairer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+var timeNow = time.Now
+
+type candidate struct {
+	pathConf *conf.Path
+	pathName string
+	seg      *recordstore.Segment
+	keys     []recordstore.EncryptionKey
+}
+
+// Repairer repairs recording segments that have been left incomplete
+// by an abrupt termination.
+// Segments are listed on startup, before recorders start,
+// and are repaired in the background.
+type Repairer struct {
+	PathConfs map[string]*conf.Path
+	Parent    logger.Writer
+
+	ctx       context.Context
+	ctxCancel func()
+	mutex     sync.RWMutex
+	repairs   []*defs.APIRecordingRepair
+
+	done chan struct{}
+}
+
+// Initialize initializes a Repairer.
+func (r *Repairer) Initialize() {
+	r.ctx, r.ctxCancel = context.WithCancel(context.Background())
+	r.done = make(chan struct{})
+
+	candidates := r.findCandidates()
+
+	go r.run(candidates)
+}
+
+// Close closes the Repairer.
+func (r *Repairer) Close() {
+	r.ctxCancel()
+	<-r.done
+}
+
+// findCandidates lists segments that may be incomplete, without opening them.
+func (r *Repairer) findCandidates() []*candidate {
+	var out []*candidate
+
+	for _, pathName := range recordstore.FindAllPathsWithSegments(r.PathConfs) {
+		pathConf, _, err := conf.FindPathConf(r.PathConfs, pathName)
+		if err != nil {
+			continue
+		}
+
+		// MPEG-TS and Matroska segments can be read even when truncated
+		if pathConf.RecordFormat != conf.RecordFormatFMP4 {
+			continue
+		}
+
+		segments, err := recordstore.FindSegments(pathConf, pathName, nil, nil)
+		if err != nil {
+			continue
+		}
+
+		var keys []recordstore.EncryptionKey
+		keysLoaded := false
+
+		for _, seg := range segments {
+			// segments that have been completed by the recorder or by a previous repair
+			// are marked as complete in the index, and uploaded segments are complete.
+			if seg.Info != nil || seg.RemoteKey != "" {
+				continue
+			}
+
+			// keys are needed only by encrypted segments
+			if !keysLoaded {
+				keys, err = recordstore.LoadEncryptionKeys(pathConf)
+				if err != nil {
+					r.Log(logger.Warn, "%v", err)
+				}
+				keysLoaded = true
+			}
+
+			out = append(out, &candidate{
+				pathConf: pathConf,
+				pathName: pathName,
+				seg:      seg,
+				keys:     keys,
+			})
+		}
+	}
+
+	return out
+}
+
+func (r *Repairer) run(candidates []*candidate) {
+	defer close(r.done)
+
+	n := 0
+
+	for _, c := range candidates {
+		select {
+		case <-r.ctx.Done():
+			return
+		default:
+		}
+
+		if r.repairSegment(c) {
+			n++
+		}
+	}
+
+	if n != 0 {
+		r.Log(logger.Info, "repaired %d segment(s)", n)
+	}
+}
+
+// Log implements logger.Writer.
+func (r *Repairer) Log(level logger.Level, format string, args ...interface{}) {
+	r.Parent.Log(level, "[record repairer] "+format, args...)
+}
+
+func (r *Repairer) repairSegment(c *candidate) bool {
+	res, err := repairFMP4(c.seg.Fpath, c.keys)
+	if err != nil {
+		r.Log(logger.Warn, "unable to repair segment %s: %v", c.seg.Fpath, err)
+		return false
+	}
+
+	// segment is complete, but it has not been marked as such in the index,
+	// for instance because the index has been rebuilt.
+	if res.action == "" {
+		err = r.updateIndex(c.pathConf, c.pathName, c.seg, res)
+		if err != nil {
+			r.Log(logger.Warn, "unable to update recording index: %v", err)
+		}
+		return false
+	}
+
+	seg := c.seg
+
+	switch res.action {
+	case defs.APIRecordingRepairActionRemoved:
+		r.Log(logger.Warn, "segment %s contains no complete parts, removed", seg.Fpath)
+
+	case defs.APIRecordingRepairActionTruncated:
+		r.Log(logger.Warn, "segment %s was truncated, removed %d bytes of incomplete data, duration is %v",
+			seg.Fpath, res.removedBytes, res.duration)
+
+	default:
+		r.Log(logger.Info, "segment %s was not finalized, duration is %v", seg.Fpath, res.duration)
+	}
+
+	err = r.updateIndex(c.pathConf, c.pathName, seg, res)
+	if err != nil {
+		r.Log(logger.Warn, "unable to update recording index: %v", err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.repairs = append(r.repairs, &defs.APIRecordingRepair{
+		Time:         timeNow(),
+		Path:         c.pathName,
+		Start:        seg.Start,
+		Action:       res.action,
+		RemovedBytes: res.removedBytes,
+		Duration:     res.duration.Seconds(),
+	})
+
+	return true
+}
+
+func (r *Repairer) updateIndex(
+	pathConf *conf.Path,
+	pathName string,
+	seg *recordstore.Segment,
+	res *repairResult,
+) error {
+	if res.action == defs.APIRecordingRepairActionRemoved {
+		return recordstore.IndexRemoveSegment(pathConf.RecordPath, pathName, seg.Fpath)
+	}
+
+	info := recordstore.SegmentInfo{
+		Duration: res.duration,
+	}
+	if res.mtxi != nil {
+		info.StreamID = res.mtxi.StreamID
+		info.SegmentNumber = res.mtxi.SegmentNumber
+	}
+
+	return recordstore.IndexCompleteSegment(pathConf.RecordPath, pathName, seg.Fpath, info)
+}
+
+// APIRepairsList is called by api.
+func (r *Repairer) APIRepairsList() (*defs.APIRecordingRepairList, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	items := make([]*defs.APIRecordingRepair, len(r.repairs))
+	copy(items, r.repairs)
+
+	return &defs.APIRecordingRepairList{
+		Items: items,
+	}, nil
+}
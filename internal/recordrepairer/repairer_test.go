@@ -0,0 +1,330 @@
+package recordrepairer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	amp4 "github.com/abema/go-mp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/bluenviron/mediamtx/internal/test"
+)
+
+func marshalSegment(t *testing.T) ([]byte, []byte) {
+	init := fmp4.Init{
+		Tracks: []*fmp4.InitTrack{
+			{
+				ID:        1,
+				TimeScale: 90000,
+				Codec: &mp4.CodecH264{
+					SPS: test.FormatH264.SPS,
+					PPS: test.FormatH264.PPS,
+				},
+			},
+		},
+		UserData: []amp4.IBox{
+			&recordstore.Mtxi{
+				StreamID:      uuid.MustParse("31564107-9e7e-4923-bf2f-631371a35397"),
+				SegmentNumber: 3,
+			},
+		},
+	}
+
+	var buf1 seekablebuffer.Buffer
+	err := init.Marshal(&buf1)
+	require.NoError(t, err)
+
+	var buf2 seekablebuffer.Buffer
+	parts := fmp4.Parts{
+		{
+			Tracks: []*fmp4.PartTrack{{
+				ID: 1,
+				Samples: []*fmp4.Sample{
+					{Duration: 90000, Payload: []byte{1, 2}},
+					{Duration: 90000, Payload: []byte{3, 4}},
+				},
+			}},
+		},
+		{
+			SequenceNumber: 1,
+			Tracks: []*fmp4.PartTrack{{
+				ID:       1,
+				BaseTime: 2 * 90000,
+				Samples: []*fmp4.Sample{
+					{Duration: 45000, Payload: []byte{5, 6}},
+				},
+			}},
+		},
+	}
+	err = parts.Marshal(&buf2)
+	require.NoError(t, err)
+
+	return buf1.Bytes(), buf2.Bytes()
+}
+
+func readDuration(t *testing.T, fpath string, keys ...recordstore.EncryptionKey) time.Duration {
+	f, err := recordstore.OpenSegmentFile(fpath, keys)
+	require.NoError(t, err)
+	defer f.Close()
+
+	size, err := f.Size()
+	require.NoError(t, err)
+
+	ftyp, err := readBoxHeader(f, 0, size)
+	require.NoError(t, err)
+
+	moov, err := readBoxHeader(f, ftyp.end(), size)
+	require.NoError(t, err)
+
+	_, mvhd, err := readInit(f, moov)
+	require.NoError(t, err)
+
+	return time.Duration(mvhd.DurationV0) * time.Second / time.Duration(mvhd.Timescale)
+}
+
+func TestRepairer(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Date(2009, 5, 20, 22, 15, 25, 427000, time.UTC)
+	}
+
+	dir, err := os.MkdirTemp("", "mediamtx-repairer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	init, parts := marshalSegment(t)
+
+	// finalized segment
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mp4"),
+		append(append([]byte(nil), init...), parts...), 0o644)
+	require.NoError(t, err)
+
+	func() {
+		var f *os.File
+		f, err = os.OpenFile(filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mp4"), os.O_RDWR, 0)
+		require.NoError(t, err)
+		defer f.Close()
+
+		var ftyp boxHeader
+		ftyp, err = readBoxHeader(f, 0, int64(len(init)))
+		require.NoError(t, err)
+
+		var moov boxHeader
+		moov, err = readBoxHeader(f, ftyp.end(), int64(len(init)))
+		require.NoError(t, err)
+
+		var mvhd *amp4.Mvhd
+		_, mvhd, err = readInit(f, moov)
+		require.NoError(t, err)
+
+		err = writeDuration(f, moov, mvhd, 10*time.Second)
+		require.NoError(t, err)
+	}()
+
+	// segment that has not been finalized
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2008-05-20_22-16-25-000000.mp4"),
+		append(append([]byte(nil), init...), parts...), 0o644)
+	require.NoError(t, err)
+
+	// segment with a truncated part
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2008-05-20_22-17-25-000000.mp4"),
+		append(append(append([]byte(nil), init...), parts...), parts[:20]...), 0o644)
+	require.NoError(t, err)
+
+	// segment with a truncated init
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2008-05-20_22-18-25-000000.mp4"),
+		init[:len(init)-10], 0o644)
+	require.NoError(t, err)
+
+	r := &Repairer{
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:         "mypath",
+				RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				RecordFormat: conf.RecordFormatFMP4,
+			},
+		},
+		Parent: test.NilLogger,
+	}
+	r.Initialize()
+	<-r.done
+	defer r.Close()
+
+	list, err := r.APIRepairsList()
+	require.NoError(t, err)
+
+	require.Equal(t, &defs.APIRecordingRepairList{
+		Items: []*defs.APIRecordingRepair{
+			{
+				Time:     timeNow(),
+				Path:     "mypath",
+				Start:    time.Date(2008, 5, 20, 22, 16, 25, 0, time.Local),
+				Action:   defs.APIRecordingRepairActionFinalized,
+				Duration: 2.5,
+			},
+			{
+				Time:         timeNow(),
+				Path:         "mypath",
+				Start:        time.Date(2008, 5, 20, 22, 17, 25, 0, time.Local),
+				Action:       defs.APIRecordingRepairActionTruncated,
+				RemovedBytes: 20,
+				Duration:     2.5,
+			},
+			{
+				Time:         timeNow(),
+				Path:         "mypath",
+				Start:        time.Date(2008, 5, 20, 22, 18, 25, 0, time.Local),
+				Action:       defs.APIRecordingRepairActionRemoved,
+				RemovedBytes: uint64(len(init) - 10),
+			},
+		},
+	}, list)
+
+	require.Equal(t, 10*time.Second, readDuration(t, filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mp4")))
+	require.Equal(t, 2500*time.Millisecond, readDuration(t, filepath.Join(dir, "mypath", "2008-05-20_22-16-25-000000.mp4")))
+	require.Equal(t, 2500*time.Millisecond, readDuration(t, filepath.Join(dir, "mypath", "2008-05-20_22-17-25-000000.mp4")))
+
+	buf, err := os.ReadFile(filepath.Join(dir, "mypath", "2008-05-20_22-17-25-000000.mp4"))
+	require.NoError(t, err)
+	require.Equal(t, parts, buf[len(init):])
+
+	_, err = os.Stat(filepath.Join(dir, "mypath", "2008-05-20_22-18-25-000000.mp4"))
+	require.Error(t, err)
+
+	// all segments are marked as complete in the index
+	segments, err := recordstore.FindSegments(r.PathConfs["mypath"], "mypath", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, segments, 3)
+
+	for _, seg := range segments {
+		require.NotNil(t, seg.Info)
+		require.Equal(t, uint64(3), seg.Info.SegmentNumber)
+	}
+}
+
+func TestRepairerSkipComplete(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-repairer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	recordPath := filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")
+
+	init, parts := marshalSegment(t)
+
+	// segment that has not been finalized, but that is marked as complete in the index
+	fpath := filepath.Join(dir, "mypath", "2008-05-20_22-16-25-000000.mp4")
+
+	err = os.WriteFile(fpath, append(append(append([]byte(nil), init...), parts...), parts[:20]...), 0o644)
+	require.NoError(t, err)
+
+	err = recordstore.IndexAddSegment(recordPath, "mypath", fpath)
+	require.NoError(t, err)
+
+	err = recordstore.IndexCompleteSegment(recordPath, "mypath", fpath, recordstore.SegmentInfo{
+		Duration: 2 * time.Second,
+	})
+	require.NoError(t, err)
+
+	r := &Repairer{
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:         "mypath",
+				RecordPath:   recordPath,
+				RecordFormat: conf.RecordFormatFMP4,
+			},
+		},
+		Parent: test.NilLogger,
+	}
+	r.Initialize()
+	<-r.done
+	defer r.Close()
+
+	list, err := r.APIRepairsList()
+	require.NoError(t, err)
+	require.Empty(t, list.Items)
+
+	buf, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+	require.Equal(t, len(init)+len(parts)+20, len(buf))
+}
+
+func TestRepairerEncrypted(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Date(2009, 5, 20, 22, 15, 25, 427000, time.UTC)
+	}
+
+	dir, err := os.MkdirTemp("", "mediamtx-repairer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "keys"),
+		[]byte("5f4dcc3b5aa765d61d8327deb882cf995f4dcc3b5aa765d61d8327deb882cf99\n"), 0o644)
+	require.NoError(t, err)
+
+	pathConf := &conf.Path{
+		Name:                    "mypath",
+		RecordPath:              filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat:            conf.RecordFormatFMP4,
+		RecordEncryption:        true,
+		RecordEncryptionKeyFile: filepath.Join(dir, "keys"),
+	}
+
+	keys, err := recordstore.LoadEncryptionKeys(pathConf)
+	require.NoError(t, err)
+
+	init, parts := marshalSegment(t)
+
+	// encrypted segment with a truncated part
+	fpath := filepath.Join(dir, "mypath", "2008-05-20_22-17-25-000000.mp4")
+
+	f, err := recordstore.CreateSegmentFile(fpath, &keys[0])
+	require.NoError(t, err)
+	_, err = f.Write(append(append(append([]byte(nil), init...), parts...), parts[:20]...))
+	require.NoError(t, err)
+	f.Close()
+
+	r := &Repairer{
+		PathConfs: map[string]*conf.Path{
+			"mypath": pathConf,
+		},
+		Parent: test.NilLogger,
+	}
+	r.Initialize()
+	<-r.done
+	defer r.Close()
+
+	list, err := r.APIRepairsList()
+	require.NoError(t, err)
+
+	require.Equal(t, &defs.APIRecordingRepairList{
+		Items: []*defs.APIRecordingRepair{
+			{
+				Time:         timeNow(),
+				Path:         "mypath",
+				Start:        time.Date(2008, 5, 20, 22, 17, 25, 0, time.Local),
+				Action:       defs.APIRecordingRepairActionTruncated,
+				RemovedBytes: 20,
+				Duration:     2.5,
+			},
+		},
+	}, list)
+
+	require.Equal(t, 2500*time.Millisecond, readDuration(t, fpath, keys...))
+}
@@ -0,0 +1,530 @@
+package recordstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+// encrypted segments are made of a header and of a sequence of chunks.
+// Each chunk is encrypted with AES-256-GCM and a random nonce,
+// that is regenerated every time the chunk is written,
+// therefore chunks can be rewritten safely.
+const (
+	encryptionMagic          = "MTXENC01"
+	encryptionKeyIDSize      = 8
+	encryptionFileIDSize     = 16
+	encryptionHeaderSize     = len(encryptionMagic) + encryptionKeyIDSize + encryptionFileIDSize
+	encryptionChunkSize      = 64 * 1024
+	encryptionNonceSize      = 12
+	encryptionTagSize        = 16
+	encryptionChunkOverhead  = encryptionNonceSize + encryptionTagSize
+	encryptionDiskChunkSize  = encryptionChunkSize + encryptionChunkOverhead
+	encryptionKeyCacheTTL    = 1 * time.Minute
+	encryptionCommandTimeout = 10 * time.Second
+)
+
+// ErrDecryptionFailed is returned when a segment can't be decrypted.
+var ErrDecryptionFailed = errors.New("decryption failed")
+
+// EncryptionKey is a key used to encrypt segments.
+type EncryptionKey [32]byte
+
+func (k *EncryptionKey) id() []byte {
+	h := sha256.Sum256(k[:])
+	return h[:encryptionKeyIDSize]
+}
+
+func parseEncryptionKeys(byts []byte) ([]EncryptionKey, error) {
+	var keys []EncryptionKey
+
+	sc := bufio.NewScanner(bytes.NewReader(byts))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		dec, err := hex.DecodeString(line)
+		if err != nil || len(dec) != 32 {
+			return nil, fmt.Errorf("invalid key: keys must be 64 hexadecimal characters")
+		}
+
+		var k EncryptionKey
+		copy(k[:], dec)
+		keys = append(keys, k)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys found")
+	}
+
+	return keys, nil
+}
+
+func runKeyCommand(command string) ([]byte, error) {
+	parts, err := shellquote.Split(command)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	ctx, ctxCancel := context.WithTimeout(context.Background(), encryptionCommandTimeout)
+	defer ctxCancel()
+
+	return exec.CommandContext(ctx, parts[0], parts[1:]...).Output()
+}
+
+type keyCacheEntry struct {
+	keys []EncryptionKey
+	time time.Time
+}
+
+var keyCache = struct {
+	mutex sync.Mutex
+	m     map[string]*keyCacheEntry
+}{
+	m: make(map[string]*keyCacheEntry),
+}
+
+// LoadEncryptionKeys returns the encryption keys of a path.
+// The first key is used to encrypt new segments, while all keys can be used to decrypt them.
+// It returns nil when no key source is configured.
+func LoadEncryptionKeys(pathConf *conf.Path) ([]EncryptionKey, error) {
+	var source string
+	switch {
+	case pathConf.RecordEncryptionKeyFile != "":
+		source = "file:" + pathConf.RecordEncryptionKeyFile
+
+	case pathConf.RecordEncryptionKeyCommand != "":
+		source = "command:" + pathConf.RecordEncryptionKeyCommand
+
+	default:
+		return nil, nil
+	}
+
+	keyCache.mutex.Lock()
+	defer keyCache.mutex.Unlock()
+
+	if e, ok := keyCache.m[source]; ok && time.Since(e.time) < encryptionKeyCacheTTL {
+		return e.keys, nil
+	}
+
+	var byts []byte
+	var err error
+
+	if pathConf.RecordEncryptionKeyFile != "" {
+		byts, err = os.ReadFile(pathConf.RecordEncryptionKeyFile)
+	} else {
+		byts, err = runKeyCommand(pathConf.RecordEncryptionKeyCommand)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to load encryption keys: %w", err)
+	}
+
+	keys, err := parseEncryptionKeys(byts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load encryption keys: %w", err)
+	}
+
+	keyCache.m[source] = &keyCacheEntry{
+		keys: keys,
+		time: time.Now(),
+	}
+
+	return keys, nil
+}
+
+func isEncrypted(r io.ReaderAt) bool {
+	buf := make([]byte, len(encryptionMagic))
+	_, err := r.ReadAt(buf, 0)
+	return err == nil && string(buf) == encryptionMagic
+}
+
+func encryptedPlainSize(diskSize int64) int64 {
+	n := diskSize - int64(encryptionHeaderSize)
+	if n <= 0 {
+		return 0
+	}
+
+	size := (n / encryptionDiskChunkSize) * encryptionChunkSize
+
+	// a trailing chunk that doesn't contain any data is the result of an interrupted write
+	if rem := n % encryptionDiskChunkSize; rem > encryptionChunkOverhead {
+		size += rem - encryptionChunkOverhead
+	}
+
+	return size
+}
+
+func encryptedDiskSize(plainSize int64) int64 {
+	size := int64(encryptionHeaderSize) + (plainSize/encryptionChunkSize)*encryptionDiskChunkSize
+
+	if rem := plainSize % encryptionChunkSize; rem > 0 {
+		size += rem + encryptionChunkOverhead
+	}
+
+	return size
+}
+
+// encryptedFile is a segment file encrypted with a EncryptionKey.
+type encryptedFile struct {
+	r        io.ReaderAt
+	w        io.WriterAt            // nil when the file is read-only
+	truncate func(size int64) error // nil when the file is read-only
+	closer   io.Closer
+
+	aead       cipher.AEAD
+	fileID     []byte
+	size       int64
+	pos        int64
+	cache      []byte
+	cacheIndex int64
+}
+
+func newAEAD(key *EncryptionKey) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func createEncryptedFile(f *os.File, key *EncryptionKey) (*encryptedFile, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	fileID := make([]byte, encryptionFileIDSize)
+	_, err = rand.Read(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, encryptionHeaderSize)
+	header = append(header, encryptionMagic...)
+	header = append(header, key.id()...)
+	header = append(header, fileID...)
+
+	_, err = f.WriteAt(header, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptedFile{
+		r:          f,
+		w:          f,
+		truncate:   f.Truncate,
+		closer:     f,
+		aead:       aead,
+		fileID:     fileID,
+		cacheIndex: -1,
+	}, nil
+}
+
+func openEncryptedFile(r io.ReaderAt, diskSize int64, keys []EncryptionKey) (*encryptedFile, error) {
+	header := make([]byte, encryptionHeaderSize)
+	_, err := r.ReadAt(header, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(header[:len(encryptionMagic)]) != encryptionMagic {
+		return nil, fmt.Errorf("segment is not encrypted")
+	}
+
+	keyID := header[len(encryptionMagic) : len(encryptionMagic)+encryptionKeyIDSize]
+
+	for i := range keys {
+		if !bytes.Equal(keys[i].id(), keyID) {
+			continue
+		}
+
+		aead, err := newAEAD(&keys[i])
+		if err != nil {
+			return nil, err
+		}
+
+		return &encryptedFile{
+			r:          r,
+			aead:       aead,
+			fileID:     header[len(encryptionMagic)+encryptionKeyIDSize:],
+			size:       encryptedPlainSize(diskSize),
+			cacheIndex: -1,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("segment has been encrypted with an unknown key (%x)", keyID)
+}
+
+// recoverTail removes the last chunk when it has been partially written.
+func (f *encryptedFile) recoverTail() error {
+	if f.size == 0 {
+		return nil
+	}
+
+	last := (f.size - 1) / encryptionChunkSize
+
+	_, err := f.readChunk(last)
+	if err == nil || !errors.Is(err, ErrDecryptionFailed) {
+		return err
+	}
+
+	f.size = last * encryptionChunkSize
+	f.cacheIndex = -1
+
+	return f.truncate(encryptedDiskSize(f.size))
+}
+
+func (f *encryptedFile) additionalData(index int64) []byte {
+	ad := make([]byte, encryptionFileIDSize+8)
+	copy(ad, f.fileID)
+	binary.BigEndian.PutUint64(ad[encryptionFileIDSize:], uint64(index))
+	return ad
+}
+
+func (f *encryptedFile) readChunk(index int64) ([]byte, error) {
+	if index == f.cacheIndex {
+		return f.cache, nil
+	}
+
+	plainLen := f.size - index*encryptionChunkSize
+	if plainLen <= 0 {
+		return nil, io.EOF
+	}
+	if plainLen > encryptionChunkSize {
+		plainLen = encryptionChunkSize
+	}
+
+	buf := make([]byte, plainLen+encryptionChunkOverhead)
+	n, err := f.r.ReadAt(buf, int64(encryptionHeaderSize)+index*encryptionDiskChunkSize)
+	if err != nil && !(errors.Is(err, io.EOF) && n == len(buf)) {
+		return nil, err
+	}
+
+	plain, err := f.aead.Open(nil, buf[:encryptionNonceSize], buf[encryptionNonceSize:], f.additionalData(index))
+	if err != nil {
+		return nil, fmt.Errorf("%w: chunk %d is corrupted or has been tampered", ErrDecryptionFailed, index)
+	}
+
+	f.cache = plain
+	f.cacheIndex = index
+
+	return plain, nil
+}
+
+func (f *encryptedFile) writeChunk(index int64, plain []byte) error {
+	buf := make([]byte, encryptionNonceSize, len(plain)+encryptionChunkOverhead)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return err
+	}
+
+	buf = f.aead.Seal(buf, buf[:encryptionNonceSize], plain, f.additionalData(index))
+
+	_, err = f.w.WriteAt(buf, int64(encryptionHeaderSize)+index*encryptionDiskChunkSize)
+	if err != nil {
+		f.cacheIndex = -1
+		return err
+	}
+
+	f.cache = plain
+	f.cacheIndex = index
+
+	return nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (f *encryptedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	n := 0
+
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= f.size {
+			return n, io.EOF
+		}
+
+		chunk, err := f.readChunk(pos / encryptionChunkSize)
+		if err != nil {
+			return n, err
+		}
+
+		n += copy(p[n:], chunk[pos%encryptionChunkSize:])
+	}
+
+	return n, nil
+}
+
+// WriteAt implements io.WriterAt.
+func (f *encryptedFile) WriteAt(p []byte, off int64) (int, error) {
+	if f.w == nil {
+		return 0, errors.New("file is read-only")
+	}
+
+	if off < 0 || off > f.size {
+		return 0, errors.New("writing beyond the end of file is not supported")
+	}
+
+	n := 0
+
+	for n < len(p) {
+		pos := off + int64(n)
+		index := pos / encryptionChunkSize
+		inner := int(pos % encryptionChunkSize)
+
+		var existing []byte
+		if index*encryptionChunkSize < f.size {
+			var err error
+			existing, err = f.readChunk(index)
+			if err != nil {
+				return n, err
+			}
+		}
+
+		l := len(p) - n
+		if l > (encryptionChunkSize - inner) {
+			l = encryptionChunkSize - inner
+		}
+
+		chunkLen := len(existing)
+		if (inner + l) > chunkLen {
+			chunkLen = inner + l
+		}
+
+		chunk := make([]byte, chunkLen)
+		copy(chunk, existing)
+		copy(chunk[inner:], p[n:n+l])
+
+		err := f.writeChunk(index, chunk)
+		if err != nil {
+			return n, err
+		}
+
+		if end := index*encryptionChunkSize + int64(chunkLen); end > f.size {
+			f.size = end
+		}
+
+		n += l
+	}
+
+	return n, nil
+}
+
+// Read implements io.Reader.
+func (f *encryptedFile) Read(p []byte) (int, error) {
+	if f.pos >= f.size {
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > (f.size - f.pos) {
+		p = p[:f.size-f.pos]
+	}
+
+	n, err := f.ReadAt(p, f.pos)
+	f.pos += int64(n)
+
+	if errors.Is(err, io.EOF) && n != 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// Write implements io.Writer.
+func (f *encryptedFile) Write(p []byte) (int, error) {
+	n, err := f.WriteAt(p, f.pos)
+	f.pos += int64(n)
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (f *encryptedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	f.pos = offset
+	return f.pos, nil
+}
+
+// Truncate implements SegmentFile.
+func (f *encryptedFile) Truncate(size int64) error {
+	if f.truncate == nil {
+		return errors.New("file is read-only")
+	}
+
+	if size > f.size {
+		return errors.New("extending files is not supported")
+	}
+
+	index := size / encryptionChunkSize
+
+	if rem := size % encryptionChunkSize; rem > 0 {
+		chunk, err := f.readChunk(index)
+		if err != nil {
+			return err
+		}
+
+		err = f.writeChunk(index, chunk[:rem])
+		if err != nil {
+			return err
+		}
+	} else if f.cacheIndex >= index {
+		f.cacheIndex = -1
+	}
+
+	f.size = size
+
+	return f.truncate(encryptedDiskSize(size))
+}
+
+// Size implements SegmentFile.
+func (f *encryptedFile) Size() (int64, error) {
+	return f.size, nil
+}
+
+// Close implements io.Closer.
+func (f *encryptedFile) Close() error {
+	f.cache = nil
+	if f.closer != nil {
+		return f.closer.Close()
+	}
+	return nil
+}
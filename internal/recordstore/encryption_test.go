@@ -0,0 +1,143 @@
+package recordstore
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+func TestEncryption(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "keys")
+	err = os.WriteFile(keyFile, []byte("# current key\n"+
+		"5f4dcc3b5aa765d61d8327deb882cf995f4dcc3b5aa765d61d8327deb882cf99\n"+
+		"\n"+
+		"# old key\n"+
+		"0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef\n"), 0o644)
+	require.NoError(t, err)
+
+	pathConf := &conf.Path{
+		RecordEncryptionKeyFile: keyFile,
+	}
+
+	keys, err := LoadEncryptionKeys(pathConf)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	fpath := filepath.Join(dir, "2015-05-19_22-15-25-000427.mp4")
+
+	// segment is encrypted with the old key
+	f, err := CreateSegmentFile(fpath, &keys[1])
+	require.NoError(t, err)
+
+	payload := make([]byte, encryptionChunkSize*2+1000)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	// write in parts and overwrite the beginning, as the recorder does
+	_, err = f.Write(payload[:100])
+	require.NoError(t, err)
+
+	_, err = f.Write(payload[100:])
+	require.NoError(t, err)
+
+	_, err = f.Seek(10, io.SeekStart)
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte{1, 2, 3})
+	require.NoError(t, err)
+	copy(payload[10:], []byte{1, 2, 3})
+
+	err = f.Close()
+	require.NoError(t, err)
+
+	raw, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+	require.Equal(t, encryptedDiskSize(int64(len(payload))), int64(len(raw)))
+	require.False(t, bytes.Contains(raw, payload[1000:1100]))
+
+	// segment is decrypted transparently
+	r, err := OpenSegment(context.Background(), pathConf, &Segment{Fpath: fpath})
+	require.NoError(t, err)
+
+	dec, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, payload, dec)
+
+	buf := make([]byte, 20)
+	_, err = r.ReadAt(buf, encryptionChunkSize-10)
+	require.NoError(t, err)
+	require.Equal(t, payload[encryptionChunkSize-10:encryptionChunkSize+10], buf)
+
+	r.Close()
+
+	// segment is truncated
+	sf, err := OpenSegmentFile(fpath, keys)
+	require.NoError(t, err)
+
+	err = sf.Truncate(encryptionChunkSize + 500)
+	require.NoError(t, err)
+
+	size, err := sf.Size()
+	require.NoError(t, err)
+	require.Equal(t, int64(encryptionChunkSize+500), size)
+
+	sf.Close()
+
+	// an interrupted write is removed when the segment is opened for writing
+	raw, err = os.ReadFile(fpath)
+	require.NoError(t, err)
+	err = os.WriteFile(fpath, raw[:len(raw)-10], 0o644)
+	require.NoError(t, err)
+
+	sf, err = OpenSegmentFile(fpath, keys)
+	require.NoError(t, err)
+
+	dec, err = io.ReadAll(sf)
+	require.NoError(t, err)
+	require.Equal(t, payload[:encryptionChunkSize], dec)
+
+	sf.Close()
+
+	// tampering is detected
+	raw, err = os.ReadFile(fpath)
+	require.NoError(t, err)
+	raw[encryptionHeaderSize+encryptionNonceSize] ^= 0xFF
+	err = os.WriteFile(fpath, raw, 0o644)
+	require.NoError(t, err)
+
+	r, err = OpenSegment(context.Background(), pathConf, &Segment{Fpath: fpath})
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.ErrorIs(t, err, ErrDecryptionFailed)
+	r.Close()
+
+	// segment can't be opened without its key
+	_, err = OpenSegmentFile(fpath, keys[:1])
+	require.EqualError(t, err, "segment has been encrypted with an unknown key (4884fdaafea47c29)")
+}
+
+func TestEncryptionKeyCommand(t *testing.T) {
+	pathConf := &conf.Path{
+		RecordEncryptionKeyCommand: "echo 5f4dcc3b5aa765d61d8327deb882cf995f4dcc3b5aa765d61d8327deb882cf99",
+	}
+
+	keys, err := LoadEncryptionKeys(pathConf)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.Equal(t, byte(0x5f), keys[0][0])
+
+	_, err = LoadEncryptionKeys(&conf.Path{RecordEncryptionKeyCommand: "echo notakey"})
+	require.EqualError(t, err, "unable to load encryption keys: invalid key: keys must be 64 hexadecimal characters")
+}
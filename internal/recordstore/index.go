@@ -0,0 +1,584 @@
+package recordstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+const (
+	// IndexDirName is the name of the directory that contains indexes,
+	// placed into the common path of recordings.
+	IndexDirName = ".mediamtx-index"
+
+	indexFileExt = ".jsonl"
+
+	// indexes are compacted when removed entries exceed this amount.
+	indexCompactThreshold = 1000
+)
+
+var recordFormats = []conf.RecordFormat{
+	conf.RecordFormatFMP4,
+	conf.RecordFormatMPEGTS,
+	conf.RecordFormatMKV,
+	conf.RecordFormatWebM,
+}
+
+// SegmentInfo contains informations about a complete segment.
+type SegmentInfo struct {
+	Duration      time.Duration
+	StreamID      uuid.UUID
+	SegmentNumber uint64
+}
+
+type indexOp string
+
+const (
+	indexOpAdd      indexOp = "add"
+	indexOpComplete indexOp = "complete"
+	indexOpRemove   indexOp = "remove"
+	indexOpUpload   indexOp = "upload"
+)
+
+// indexLine is a line of an index file.
+type indexLine struct {
+	Op            indexOp       `json:"op"`
+	File          string        `json:"file"`
+	Start         time.Time     `json:"start,omitzero"`
+	Duration      time.Duration `json:"duration,omitempty"`
+	StreamID      *uuid.UUID    `json:"streamID,omitempty"`
+	SegmentNumber uint64        `json:"segmentNumber,omitempty"`
+	Key           string        `json:"key,omitempty"`
+}
+
+type indexEntry struct {
+	start     time.Time
+	info      *SegmentInfo
+	remoteKey string
+}
+
+// index is an append-only index of the segments of a path.
+// Every change is appended to a file, that is replayed when the index is loaded.
+// When the file can't be written (for instance, because recordings are on a
+// read-only storage), the index is kept in memory and rebuilt at every access.
+type index struct {
+	root  string
+	fpath string
+
+	mutex   sync.Mutex
+	loaded  bool
+	size    int64
+	removed int
+	entries map[string]*indexEntry
+
+	// file doesn't reflect entries and must be entirely rewritten
+	// before appending other lines.
+	dirty bool
+}
+
+var indexes = struct {
+	mutex sync.Mutex
+	m     map[string]*index
+}{
+	m: make(map[string]*index),
+}
+
+func indexRoot(recordPath string) string {
+	// we have to convert to absolute paths
+	// otherwise, recordPath and fpath won't have common elements
+	root, _ := filepath.Abs(CommonPath(recordPath))
+	return root
+}
+
+func indexFilePath(root string, pathName string) string {
+	return filepath.Join(root, IndexDirName, url.PathEscape(pathName)+indexFileExt)
+}
+
+func getIndex(recordPath string, pathName string) *index {
+	root := indexRoot(recordPath)
+	fpath := indexFilePath(root, pathName)
+
+	indexes.mutex.Lock()
+	defer indexes.mutex.Unlock()
+
+	idx, ok := indexes.m[fpath]
+	if !ok {
+		idx = &index{
+			root:  root,
+			fpath: fpath,
+		}
+		indexes.m[fpath] = idx
+	}
+
+	return idx
+}
+
+// open loads the index, or builds it by scanning the disk when it is missing.
+// It must be called with the mutex locked.
+func (idx *index) open(recordPath string, pathName string, listRemote func() ([]*Segment, error)) error {
+	fi, err := os.Stat(idx.fpath)
+	if err != nil {
+		return idx.rebuild(recordPath, pathName, listRemote)
+	}
+
+	// file has been changed by someone else
+	if !idx.loaded || fi.Size() != idx.size {
+		return idx.load()
+	}
+
+	return nil
+}
+
+func (idx *index) load() error {
+	buf, err := os.ReadFile(idx.fpath)
+	if err != nil {
+		return err
+	}
+
+	size := int64(len(buf))
+	idx.dirty = false
+
+	// skip incomplete line left by an abrupt termination.
+	// The file is repaired by the next writer.
+	if i := bytes.LastIndexByte(buf, '\n'); i != (len(buf) - 1) {
+		buf = buf[:i+1]
+		idx.dirty = true
+	}
+
+	idx.entries = make(map[string]*indexEntry)
+	idx.removed = 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	scanner.Buffer(nil, len(buf)+1)
+
+	for scanner.Scan() {
+		var l indexLine
+		err = json.Unmarshal(scanner.Bytes(), &l)
+		if err != nil {
+			continue
+		}
+		idx.apply(&l)
+	}
+
+	idx.loaded = true
+	idx.size = size
+
+	return nil
+}
+
+func (idx *index) apply(l *indexLine) bool {
+	switch l.Op {
+	case indexOpAdd:
+		if _, ok := idx.entries[l.File]; ok {
+			return false
+		}
+		idx.entries[l.File] = &indexEntry{
+			start: l.Start,
+		}
+
+	case indexOpComplete:
+		e, ok := idx.entries[l.File]
+		if !ok {
+			return false
+		}
+		e.info = &SegmentInfo{
+			Duration:      l.Duration,
+			SegmentNumber: l.SegmentNumber,
+		}
+		if l.StreamID != nil {
+			e.info.StreamID = *l.StreamID
+		}
+
+	case indexOpUpload:
+		e, ok := idx.entries[l.File]
+		if !ok {
+			return false
+		}
+		e.remoteKey = l.Key
+
+	case indexOpRemove:
+		if _, ok := idx.entries[l.File]; !ok {
+			return false
+		}
+		delete(idx.entries, l.File)
+		idx.removed++
+
+	default:
+		return false
+	}
+
+	return true
+}
+
+func segmentFormats(recordPath string, pathName string) []string {
+	recordPath = strings.ReplaceAll(recordPath, "%path", pathName)
+
+	formats := make([]string, len(recordFormats))
+	for i, format := range recordFormats {
+		// we have to convert to absolute paths
+		// otherwise, recordPath and fpath won't have common elements
+		formats[i], _ = filepath.Abs(PathAddExtension(recordPath, format))
+	}
+
+	return formats
+}
+
+func decodeSegmentStart(formats []string, fpath string) (time.Time, bool) {
+	for _, format := range formats {
+		var pa Path
+		if pa.Decode(format, fpath) {
+			return pa.Start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (idx *index) rebuild(recordPath string, pathName string, listRemote func() ([]*Segment, error)) error {
+	formats := segmentFormats(recordPath, pathName)
+	commonPath := CommonPath(formats[0])
+
+	idx.entries = make(map[string]*indexEntry)
+	idx.removed = 0
+
+	filepath.WalkDir(commonPath, func(fpath string, info fs.DirEntry, err error) error { //nolint:errcheck
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == IndexDirName || info.Name() == MarkersDirName ||
+				info.Name() == KeyframesDirName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if start, ok := decodeSegmentStart(formats, fpath); ok {
+			if rel, err2 := idx.relPath(fpath); err2 == nil {
+				idx.entries[rel] = &indexEntry{start: start}
+			}
+		}
+
+		return nil
+	})
+
+	// segments that have been uploaded to the object storage
+	if listRemote != nil {
+		remote, err := listRemote()
+		if err != nil {
+			return err
+		}
+
+		for _, seg := range remote {
+			rel, err := idx.relPath(seg.Fpath)
+			if err != nil {
+				continue
+			}
+
+			if e, ok := idx.entries[rel]; ok {
+				e.remoteKey = seg.RemoteKey
+			} else {
+				idx.entries[rel] = &indexEntry{
+					start:     seg.Start,
+					remoteKey: seg.RemoteKey,
+				}
+			}
+		}
+	}
+
+	idx.loaded = true
+
+	// the index is usable even when it can't be persisted.
+	// Writers report the error when they try to update the file.
+	if idx.write() != nil {
+		idx.dirty = true
+	}
+
+	return nil
+}
+
+func (idx *index) lines() []*indexLine {
+	files := make([]string, 0, len(idx.entries))
+	for file := range idx.entries {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	var out []*indexLine
+
+	for _, file := range files {
+		e := idx.entries[file]
+
+		out = append(out, &indexLine{
+			Op:    indexOpAdd,
+			File:  file,
+			Start: e.start,
+		})
+
+		if e.info != nil {
+			streamID := e.info.StreamID
+			out = append(out, &indexLine{
+				Op:            indexOpComplete,
+				File:          file,
+				Duration:      e.info.Duration,
+				StreamID:      &streamID,
+				SegmentNumber: e.info.SegmentNumber,
+			})
+		}
+
+		if e.remoteKey != "" {
+			out = append(out, &indexLine{
+				Op:   indexOpUpload,
+				File: file,
+				Key:  e.remoteKey,
+			})
+		}
+	}
+
+	return out
+}
+
+// write writes the entire index into a new file, that replaces the existing one.
+func (idx *index) write() error {
+	// do not create indexes of paths without segments
+	if len(idx.entries) == 0 {
+		err := os.Remove(idx.fpath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		idx.size = 0
+		idx.removed = 0
+		idx.dirty = false
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, l := range idx.lines() {
+		byts, _ := json.Marshal(l)
+		buf.Write(append(byts, '\n'))
+	}
+
+	err := os.MkdirAll(filepath.Dir(idx.fpath), 0o755)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := idx.fpath + ".tmp"
+
+	err = os.WriteFile(tmpPath, buf.Bytes(), 0o644)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpPath, idx.fpath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	idx.size = int64(buf.Len())
+	idx.removed = 0
+	idx.dirty = false
+
+	return nil
+}
+
+func (idx *index) append(l *indexLine) error {
+	if !idx.apply(l) {
+		return nil
+	}
+
+	if idx.dirty || idx.removed >= indexCompactThreshold || len(idx.entries) == 0 {
+		return idx.write()
+	}
+
+	err := os.MkdirAll(filepath.Dir(idx.fpath), 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(idx.fpath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	byts, _ := json.Marshal(l)
+	byts = append(byts, '\n')
+
+	_, err = f.Write(byts)
+	if err != nil {
+		return err
+	}
+
+	idx.size += int64(len(byts))
+
+	return nil
+}
+
+func (idx *index) relPath(fpath string) (string, error) {
+	fpath, err := filepath.Abs(fpath)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(idx.root, fpath)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.ToSlash(rel), nil
+}
+
+func (idx *index) segments(format conf.RecordFormat) []*Segment {
+	ext := PathAddExtension("", format)
+
+	var out []*Segment
+
+	for file, e := range idx.entries {
+		if !strings.HasSuffix(file, ext) {
+			continue
+		}
+
+		out = append(out, &Segment{
+			Fpath:     filepath.Join(idx.root, filepath.FromSlash(file)),
+			Start:     e.start,
+			Info:      e.info,
+			RemoteKey: e.remoteKey,
+		})
+	}
+
+	return out
+}
+
+func indexSegments(pathConf *conf.Path, pathName string) ([]*Segment, error) {
+	idx := getIndex(pathConf.RecordPath, pathName)
+
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	var listRemote func() ([]*Segment, error)
+	if pathConf.RecordS3Upload {
+		listRemote = func() ([]*Segment, error) {
+			return listS3Segments(pathConf, pathName)
+		}
+	}
+
+	err := idx.open(pathConf.RecordPath, pathName, listRemote)
+	if err != nil {
+		return nil, err
+	}
+
+	return idx.segments(pathConf.RecordFormat), nil
+}
+
+// indexPathNames returns the names of paths that have an index.
+// It returns false when the index directory doesn't exist.
+func indexPathNames(recordPath string) ([]string, bool) {
+	entries, err := os.ReadDir(filepath.Join(indexRoot(recordPath), IndexDirName))
+	if err != nil {
+		return nil, false
+	}
+
+	var out []string
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, indexFileExt) {
+			continue
+		}
+
+		pathName, err := url.PathUnescape(strings.TrimSuffix(name, indexFileExt))
+		if err != nil {
+			continue
+		}
+
+		out = append(out, pathName)
+	}
+
+	return out, true
+}
+
+func indexUpdate(recordPath string, pathName string, fpath string, l *indexLine) error {
+	idx := getIndex(recordPath, pathName)
+
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	err := idx.open(recordPath, pathName, nil)
+	if err != nil {
+		return err
+	}
+
+	l.File, err = idx.relPath(fpath)
+	if err != nil {
+		return err
+	}
+
+	return idx.append(l)
+}
+
+// IndexAddSegment adds a segment to the index of a path.
+// It must be called when the segment is created.
+func IndexAddSegment(recordPath string, pathName string, fpath string) error {
+	fpath, err := filepath.Abs(fpath)
+	if err != nil {
+		return err
+	}
+
+	start, ok := decodeSegmentStart(segmentFormats(recordPath, pathName), fpath)
+	if !ok {
+		return fmt.Errorf("unable to decode segment path '%s'", fpath)
+	}
+
+	return indexUpdate(recordPath, pathName, fpath, &indexLine{
+		Op:    indexOpAdd,
+		Start: start,
+	})
+}
+
+// IndexCompleteSegment stores informations about a complete segment into the index of a path.
+func IndexCompleteSegment(recordPath string, pathName string, fpath string, info SegmentInfo) error {
+	return indexUpdate(recordPath, pathName, fpath, &indexLine{
+		Op:            indexOpComplete,
+		Duration:      info.Duration,
+		StreamID:      &info.StreamID,
+		SegmentNumber: info.SegmentNumber,
+	})
+}
+
+// IndexRemoveSegment removes a segment from the index of a path.
+// It must be called when the segment is deleted.
+// The keyframe index of the segment is removed too.
+func IndexRemoveSegment(recordPath string, pathName string, fpath string) error {
+	err := RemoveKeyframes(recordPath, pathName, fpath)
+	if err != nil {
+		return err
+	}
+
+	return indexUpdate(recordPath, pathName, fpath, &indexLine{
+		Op: indexOpRemove,
+	})
+}
+
+// IndexUploadSegment stores the key of a segment that has been uploaded to the object storage.
+func IndexUploadSegment(recordPath string, pathName string, fpath string, key string) error {
+	return indexUpdate(recordPath, pathName, fpath, &indexLine{
+		Op:  indexOpUpload,
+		Key: key,
+	})
+}
@@ -0,0 +1,251 @@
+package recordstore
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+)
+
+func resetIndexes() {
+	indexes.mutex.Lock()
+	defer indexes.mutex.Unlock()
+	indexes.m = make(map[string]*index)
+}
+
+func TestIndex(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "path1"), 0o755)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4"), []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	pathConf := &conf.Path{
+		Name:         "path1",
+		RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat: conf.RecordFormatFMP4,
+	}
+
+	// index is built from existing segments
+	segments, err := FindSegments(pathConf, "path1", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []*Segment{{
+		Fpath: filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4"),
+		Start: time.Date(2015, 5, 19, 22, 15, 25, 427000, time.Local),
+	}}, segments)
+
+	_, err = os.Stat(filepath.Join(dir, IndexDirName, "path1.jsonl"))
+	require.NoError(t, err)
+
+	// segments are added and completed by the recorder
+	fpath := filepath.Join(dir, "path1", "2015-05-19_23-15-25-000000.mp4")
+	err = os.WriteFile(fpath, []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	err = IndexAddSegment(pathConf.RecordPath, "path1", fpath)
+	require.NoError(t, err)
+
+	info := SegmentInfo{
+		Duration:      time.Hour,
+		StreamID:      uuid.MustParse("31564107-9e7e-4923-bf2f-631371a35397"),
+		SegmentNumber: 2,
+	}
+	err = IndexCompleteSegment(pathConf.RecordPath, "path1", fpath, info)
+	require.NoError(t, err)
+
+	// segments are removed by the cleaner
+	err = os.Remove(filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4"))
+	require.NoError(t, err)
+
+	err = IndexRemoveSegment(pathConf.RecordPath, "path1",
+		filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4"))
+	require.NoError(t, err)
+
+	expected := []*Segment{{
+		Fpath: fpath,
+		Start: time.Date(2015, 5, 19, 23, 15, 25, 0, time.Local),
+		Info:  &info,
+	}}
+
+	segments, err = FindSegments(pathConf, "path1", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, expected, segments)
+
+	// index is read from disk, including files with an incomplete trailing line
+	f, err := os.OpenFile(filepath.Join(dir, IndexDirName, "path1.jsonl"), os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(`{"op":"remove","fi`))
+	require.NoError(t, err)
+	f.Close()
+
+	resetIndexes()
+
+	before, err := os.ReadFile(filepath.Join(dir, IndexDirName, "path1.jsonl"))
+	require.NoError(t, err)
+
+	segments, err = FindSegments(pathConf, "path1", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, expected[0].Fpath, segments[0].Fpath)
+	require.True(t, expected[0].Start.Equal(segments[0].Start))
+	require.Equal(t, expected[0].Info, segments[0].Info)
+
+	// readers do not change the file
+	after, err := os.ReadFile(filepath.Join(dir, IndexDirName, "path1.jsonl"))
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+
+	paths := FindAllPathsWithSegments(map[string]*conf.Path{"path1": pathConf})
+	require.Equal(t, []string{"path1"}, paths)
+
+	// index is rebuilt when missing
+	err = os.RemoveAll(filepath.Join(dir, IndexDirName))
+	require.NoError(t, err)
+
+	segments, err = FindSegments(pathConf, "path1", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []*Segment{{
+		Fpath: fpath,
+		Start: time.Date(2015, 5, 19, 23, 15, 25, 0, time.Local),
+	}}, segments)
+}
+
+func TestIndexRepair(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pathConf := &conf.Path{
+		Name:         "path1",
+		RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat: conf.RecordFormatFMP4,
+	}
+
+	fpath1 := filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4")
+	fpath2 := filepath.Join(dir, "path1", "2015-05-19_23-15-25-000000.mp4")
+
+	err = IndexAddSegment(pathConf.RecordPath, "path1", fpath1)
+	require.NoError(t, err)
+
+	f, err := os.OpenFile(filepath.Join(dir, IndexDirName, "path1.jsonl"), os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(`{"op":"remove","fi`))
+	require.NoError(t, err)
+	f.Close()
+
+	resetIndexes()
+
+	// incomplete line is removed by writers
+	err = IndexAddSegment(pathConf.RecordPath, "path1", fpath2)
+	require.NoError(t, err)
+
+	resetIndexes()
+
+	segments, err := FindSegments(pathConf, "path1", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, segments, 2)
+}
+
+func TestIndexReadOnly(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "path1"), 0o755)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4"), []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	// prevent the index from being written
+	err = os.WriteFile(filepath.Join(dir, IndexDirName), []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	pathConf := &conf.Path{
+		Name:         "path1",
+		RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat: conf.RecordFormatFMP4,
+	}
+
+	for range 2 {
+		segments, err2 := FindSegments(pathConf, "path1", nil, nil)
+		require.NoError(t, err2)
+		require.Equal(t, []*Segment{{
+			Fpath: filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4"),
+			Start: time.Date(2015, 5, 19, 22, 15, 25, 427000, time.Local),
+		}}, segments)
+	}
+
+	// segments added meanwhile are found
+	err = os.WriteFile(filepath.Join(dir, "path1", "2015-05-19_23-15-25-000000.mp4"), []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	segments, err := FindSegments(pathConf, "path1", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, segments, 2)
+
+	paths := FindAllPathsWithSegments(map[string]*conf.Path{"path1": pathConf})
+	require.Equal(t, []string{"path1"}, paths)
+
+	// writers report the error
+	err = IndexAddSegment(pathConf.RecordPath, "path1",
+		filepath.Join(dir, "path1", "2015-05-20_22-15-25-000000.mp4"))
+	require.Error(t, err)
+}
+
+func TestIndexRebuildRemote(t *testing.T) {
+	s := &test.S3Server{
+		Bucket:      "mybucket",
+		AccessKeyID: "myaccesskey",
+	}
+	err := s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	s.SetObject("rec/path1/2015-05-19_22-15-25-000427.mp4", []byte{1, 2})
+	s.SetObject("rec/path2/2015-05-19_22-15-25-000427.mp4", []byte{1, 2})
+
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pathConf := &conf.Path{
+		Name:                "path1",
+		RecordPath:          filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat:        conf.RecordFormatFMP4,
+		RecordS3Upload:      true,
+		RecordS3Endpoint:    s.Endpoint(),
+		RecordS3Region:      "us-east-1",
+		RecordS3Bucket:      "mybucket",
+		RecordS3AccessKeyID: "myaccesskey",
+		RecordS3Key:         "rec/%path/%Y-%m-%d_%H-%M-%S-%f",
+	}
+
+	// segments that are only in the bucket are added to the index
+	segments, err := FindSegments(pathConf, "path1", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []*Segment{{
+		Fpath:     filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4"),
+		Start:     time.Date(2015, 5, 19, 22, 15, 25, 427000, time.Local),
+		RemoteKey: "rec/path1/2015-05-19_22-15-25-000427.mp4",
+	}}, segments)
+
+	r, err := OpenSegment(context.Background(), pathConf, segments[0])
+	require.NoError(t, err)
+	defer r.Close()
+
+	byts, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2}, byts)
+}
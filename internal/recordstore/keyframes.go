@@ -0,0 +1,114 @@
+package recordstore
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// KeyframesDirName is the name of the directory that contains keyframe indexes,
+	// placed into the common path of recordings.
+	KeyframesDirName = ".mediamtx-keyframes"
+
+	keyframesFileExt = ".json"
+)
+
+// Keyframe is the position of a keyframe inside a segment.
+type Keyframe struct {
+	// time elapsed since the start of the segment.
+	Time time.Duration `json:"time"`
+
+	// position and size of the access unit inside the segment.
+	Offset int64 `json:"offset"`
+	Size   int64 `json:"size"`
+}
+
+func keyframesFilePath(recordPath string, pathName string, fpath string) (string, error) {
+	root := indexRoot(recordPath)
+
+	fpath, err := filepath.Abs(fpath)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(root, fpath)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(root, KeyframesDirName, url.PathEscape(pathName),
+		url.PathEscape(filepath.ToSlash(rel))+keyframesFileExt), nil
+}
+
+// WriteKeyframes stores the keyframe index of a segment.
+func WriteKeyframes(recordPath string, pathName string, fpath string, keyframes []Keyframe) error {
+	kpath, err := keyframesFilePath(recordPath, pathName, fpath)
+	if err != nil {
+		return err
+	}
+
+	byts, err := json.Marshal(keyframes)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(kpath), 0o755)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file and rename it, in order to never leave a partial file.
+	tmpPath := kpath + ".tmp"
+
+	err = os.WriteFile(tmpPath, byts, 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, kpath)
+}
+
+// ReadKeyframes returns the keyframe index of a segment.
+// It returns nil when the segment doesn't have an index.
+func ReadKeyframes(recordPath string, pathName string, fpath string) ([]Keyframe, error) {
+	kpath, err := keyframesFilePath(recordPath, pathName, fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	byts, err := os.ReadFile(kpath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var keyframes []Keyframe
+	err = json.Unmarshal(byts, &keyframes)
+	if err != nil {
+		return nil, err
+	}
+
+	return keyframes, nil
+}
+
+// RemoveKeyframes removes the keyframe index of a segment.
+func RemoveKeyframes(recordPath string, pathName string, fpath string) error {
+	kpath, err := keyframesFilePath(recordPath, pathName, fpath)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(kpath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
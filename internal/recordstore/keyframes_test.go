@@ -0,0 +1,63 @@
+package recordstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+func TestKeyframes(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recordPath := filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	fpath := filepath.Join(dir, "mypath", "2008-11-07_11-22-00-000000.mp4")
+
+	err = os.WriteFile(fpath, []byte{1, 2, 3, 4}, 0o644)
+	require.NoError(t, err)
+
+	err = IndexAddSegment(recordPath, "mypath", fpath)
+	require.NoError(t, err)
+
+	keyframes, err := ReadKeyframes(recordPath, "mypath", fpath)
+	require.NoError(t, err)
+	require.Nil(t, keyframes)
+
+	err = WriteKeyframes(recordPath, "mypath", fpath, []Keyframe{
+		{Time: 0, Offset: 100, Size: 10},
+		{Time: 2 * time.Second, Offset: 300, Size: 12},
+	})
+	require.NoError(t, err)
+
+	keyframes, err = ReadKeyframes(recordPath, "mypath", fpath)
+	require.NoError(t, err)
+	require.Equal(t, []Keyframe{
+		{Time: 0, Offset: 100, Size: 10},
+		{Time: 2 * time.Second, Offset: 300, Size: 12},
+	}, keyframes)
+
+	// keyframe indexes are not mistaken for segments
+	segments, err := FindSegments(&conf.Path{
+		Name:       "mypath",
+		RecordPath: recordPath,
+	}, "mypath", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+
+	err = IndexRemoveSegment(recordPath, "mypath", fpath)
+	require.NoError(t, err)
+
+	keyframes, err = ReadKeyframes(recordPath, "mypath", fpath)
+	require.NoError(t, err)
+	require.Nil(t, keyframes)
+}
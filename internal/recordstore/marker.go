@@ -0,0 +1,197 @@
+package recordstore
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MarkersDirName is the name of the directory that contains markers,
+	// placed into the common path of recordings.
+	MarkersDirName = ".mediamtx-markers"
+
+	markersFileExt = ".json"
+)
+
+// ErrMarkerNotFound is returned when a marker is not found.
+var ErrMarkerNotFound = errors.New("marker not found")
+
+// Marker is a marker placed on the recording timeline of a path.
+type Marker struct {
+	ID       uuid.UUID       `json:"id"`
+	Created  time.Time       `json:"created"`
+	Start    time.Time       `json:"start"`
+	Duration time.Duration   `json:"duration,omitempty"`
+	Label    string          `json:"label"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// End returns the end of the marker.
+func (m *Marker) End() time.Time {
+	return m.Start.Add(m.Duration)
+}
+
+// markers of all paths are protected by a single mutex,
+// since they are edited rarely.
+var markersMutex sync.Mutex
+
+func markersFilePath(recordPath string, pathName string) string {
+	return filepath.Join(indexRoot(recordPath), MarkersDirName, url.PathEscape(pathName)+markersFileExt)
+}
+
+func readMarkers(fpath string) ([]*Marker, error) {
+	byts, err := os.ReadFile(fpath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var markers []*Marker
+	err = json.Unmarshal(byts, &markers)
+	if err != nil {
+		return nil, err
+	}
+
+	return markers, nil
+}
+
+func writeMarkers(fpath string, markers []*Marker) error {
+	if len(markers) == 0 {
+		err := os.Remove(fpath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	sort.Slice(markers, func(i, j int) bool {
+		return markers[i].Start.Before(markers[j].Start)
+	})
+
+	byts, err := json.Marshal(markers)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(fpath), 0o755)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file and rename it, in order to never leave a partial file.
+	tmpPath := fpath + ".tmp"
+
+	err = os.WriteFile(tmpPath, byts, 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, fpath)
+}
+
+// AddMarker adds a marker to the recording timeline of a path.
+// ID and Created are filled when empty.
+func AddMarker(recordPath string, pathName string, m *Marker) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	if m.Created.IsZero() {
+		m.Created = time.Now()
+	}
+
+	markersMutex.Lock()
+	defer markersMutex.Unlock()
+
+	fpath := markersFilePath(recordPath, pathName)
+
+	markers, err := readMarkers(fpath)
+	if err != nil {
+		return err
+	}
+
+	return writeMarkers(fpath, append(markers, m))
+}
+
+// FindMarkers returns markers of a path that overlap the given time range,
+// sorted by starting date.
+func FindMarkers(recordPath string, pathName string, start *time.Time, end *time.Time) ([]*Marker, error) {
+	markersMutex.Lock()
+	defer markersMutex.Unlock()
+
+	markers, err := readMarkers(markersFilePath(recordPath, pathName))
+	if err != nil {
+		return nil, err
+	}
+
+	out := markers[:0]
+
+	for _, m := range markers {
+		if (start == nil || !m.End().Before(*start)) &&
+			(end == nil || m.Start.Before(*end)) {
+			out = append(out, m)
+		}
+	}
+
+	return out, nil
+}
+
+// RemoveMarker removes a marker from the recording timeline of a path.
+func RemoveMarker(recordPath string, pathName string, id uuid.UUID) error {
+	markersMutex.Lock()
+	defer markersMutex.Unlock()
+
+	fpath := markersFilePath(recordPath, pathName)
+
+	markers, err := readMarkers(fpath)
+	if err != nil {
+		return err
+	}
+
+	for i, m := range markers {
+		if m.ID == id {
+			return writeMarkers(fpath, append(markers[:i], markers[i+1:]...))
+		}
+	}
+
+	return ErrMarkerNotFound
+}
+
+// RemoveMarkersBefore removes markers of a path that end before the given time.
+// It returns the number of removed markers.
+func RemoveMarkersBefore(recordPath string, pathName string, t time.Time) (int, error) {
+	markersMutex.Lock()
+	defer markersMutex.Unlock()
+
+	fpath := markersFilePath(recordPath, pathName)
+
+	markers, err := readMarkers(fpath)
+	if err != nil {
+		return 0, err
+	}
+
+	remaining := markers[:0]
+
+	for _, m := range markers {
+		if !m.End().Before(t) {
+			remaining = append(remaining, m)
+		}
+	}
+
+	n := len(markers) - len(remaining)
+	if n == 0 {
+		return 0, nil
+	}
+
+	return n, writeMarkers(fpath, remaining)
+}
@@ -0,0 +1,66 @@
+package recordstore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkers(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recordPath := filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")
+
+	m1 := &Marker{
+		Start: time.Date(2015, 5, 19, 22, 15, 25, 0, time.UTC),
+		Label: "door opened",
+		Data:  json.RawMessage(`{"door":2}`),
+	}
+	err = AddMarker(recordPath, "mypath", m1)
+	require.NoError(t, err)
+
+	m2 := &Marker{
+		Start:    time.Date(2015, 5, 19, 22, 10, 0, 0, time.UTC),
+		Duration: 2 * time.Minute,
+		Label:    "goal scored",
+	}
+	err = AddMarker(recordPath, "mypath", m2)
+	require.NoError(t, err)
+
+	// markers don't get mixed up with indexes
+	_, ok := indexPathNames(recordPath)
+	require.False(t, ok)
+
+	markers, err := FindMarkers(recordPath, "mypath", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, markers, 2)
+	require.Equal(t, m2.ID, markers[0].ID)
+	require.Equal(t, m1.ID, markers[1].ID)
+	require.JSONEq(t, `{"door":2}`, string(markers[1].Data))
+
+	start := time.Date(2015, 5, 19, 22, 13, 0, 0, time.UTC)
+	markers, err = FindMarkers(recordPath, "mypath", &start, nil)
+	require.NoError(t, err)
+	require.Len(t, markers, 1)
+	require.Equal(t, m1.ID, markers[0].ID)
+
+	n, err := RemoveMarkersBefore(recordPath, "mypath", start)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	err = RemoveMarker(recordPath, "mypath", m2.ID)
+	require.ErrorIs(t, err, ErrMarkerNotFound)
+
+	err = RemoveMarker(recordPath, "mypath", m1.ID)
+	require.NoError(t, err)
+
+	markers, err = FindMarkers(recordPath, "mypath", nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, markers)
+}
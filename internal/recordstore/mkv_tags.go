@@ -0,0 +1,62 @@
+package recordstore
+
+import (
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"github.com/bluenviron/mediamtx/internal/protocols/mkv"
+)
+
+const (
+	mkvTagStreamID      = "MEDIAMTX_STREAM_ID"
+	mkvTagSegmentNumber = "MEDIAMTX_SEGMENT_NUMBER"
+	mkvTagDTS           = "MEDIAMTX_DTS"
+)
+
+// MKVInfo is a MediaMTX segment info stored into Matroska tags.
+// It is the equivalent of Mtxi.
+type MKVInfo struct {
+	StreamID      uuid.UUID
+	SegmentNumber uint64
+	DTS           int64
+}
+
+// Marshal encodes a MKVInfo.
+func (i MKVInfo) Marshal() []mkv.Tag {
+	return []mkv.Tag{
+		{Name: mkvTagStreamID, Value: i.StreamID.String()},
+		{Name: mkvTagSegmentNumber, Value: strconv.FormatUint(i.SegmentNumber, 10)},
+		{Name: mkvTagDTS, Value: strconv.FormatInt(i.DTS, 10)},
+	}
+}
+
+// Unmarshal decodes a MKVInfo. It returns false if tags are not present.
+func (i *MKVInfo) Unmarshal(tags []mkv.Tag) bool {
+	found := 0
+
+	for _, tag := range tags {
+		var err error
+
+		switch tag.Name {
+		case mkvTagStreamID:
+			i.StreamID, err = uuid.Parse(tag.Value)
+
+		case mkvTagSegmentNumber:
+			i.SegmentNumber, err = strconv.ParseUint(tag.Value, 10, 64)
+
+		case mkvTagDTS:
+			i.DTS, err = strconv.ParseInt(tag.Value, 10, 64)
+
+		default:
+			continue
+		}
+
+		if err != nil {
+			return false
+		}
+		found++
+	}
+
+	return found == 3
+}
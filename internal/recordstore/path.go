@@ -71,6 +71,12 @@ func PathAddExtension(path string, format conf.RecordFormat) string {
 	case conf.RecordFormatMPEGTS:
 		return path + ".ts"
 
+	case conf.RecordFormatMKV:
+		return path + ".mkv"
+
+	case conf.RecordFormatWebM:
+		return path + ".webm"
+
 	default:
 		return path + ".mp4"
 	}
@@ -0,0 +1,130 @@
+package recordstore
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/protocols/s3"
+)
+
+const s3ListTimeout = 30 * time.Second
+
+// NewS3Client returns a client for the object storage of a path.
+func NewS3Client(pathConf *conf.Path) (*s3.Client, error) {
+	c := &s3.Client{
+		Endpoint:        pathConf.RecordS3Endpoint,
+		Region:          pathConf.RecordS3Region,
+		Bucket:          pathConf.RecordS3Bucket,
+		AccessKeyID:     pathConf.RecordS3AccessKeyID,
+		SecretAccessKey: pathConf.RecordS3SecretKey,
+	}
+	err := c.Initialize()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// S3Key returns the key of a segment in the object storage.
+func S3Key(pathConf *conf.Path, pathName string, start time.Time) string {
+	keyFormat := PathAddExtension(
+		strings.ReplaceAll(pathConf.RecordS3Key, "%path", pathName),
+		pathConf.RecordFormat,
+	)
+
+	return Path{Start: start}.Encode(keyFormat)
+}
+
+// listS3Segments returns segments of a path that are stored in the object storage.
+func listS3Segments(pathConf *conf.Path, pathName string) ([]*Segment, error) {
+	c, err := NewS3Client(pathConf)
+	if err != nil {
+		return nil, err
+	}
+
+	keyFormat := PathAddExtension(
+		strings.ReplaceAll(pathConf.RecordS3Key, "%path", pathName),
+		pathConf.RecordFormat,
+	)
+
+	prefix := CommonPath(keyFormat)
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	ctx, ctxCancel := context.WithTimeout(context.Background(), s3ListTimeout)
+	defer ctxCancel()
+
+	keys, err := c.ListObjects(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	recordPath, _ := filepath.Abs(PathAddExtension(
+		strings.ReplaceAll(pathConf.RecordPath, "%path", pathName),
+		pathConf.RecordFormat,
+	))
+
+	var out []*Segment
+
+	for _, key := range keys {
+		var pa Path
+		if pa.Decode(keyFormat, key) {
+			out = append(out, &Segment{
+				Fpath:     Path{Start: pa.Start}.Encode(recordPath),
+				Start:     pa.Start,
+				RemoteKey: key,
+			})
+		}
+	}
+
+	return out, nil
+}
+
+// DeleteSegment deletes a segment from disk, from the object storage and from the index.
+func DeleteSegment(ctx context.Context, pathConf *conf.Path, pathName string, fpath string) error {
+	fpath, err := filepath.Abs(fpath)
+	if err != nil {
+		return err
+	}
+
+	segments, err := indexSegments(pathConf, pathName)
+	if err != nil {
+		return err
+	}
+
+	remoteKey := ""
+	for _, seg := range segments {
+		if seg.Fpath == fpath {
+			remoteKey = seg.RemoteKey
+			break
+		}
+	}
+
+	err = os.Remove(fpath)
+	if err != nil && (!errors.Is(err, fs.ErrNotExist) || remoteKey == "") {
+		return err
+	}
+
+	if remoteKey != "" {
+		var c *s3.Client
+		c, err = NewS3Client(pathConf)
+		if err != nil {
+			return err
+		}
+
+		err = c.DeleteObject(ctx, remoteKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	return IndexRemoveSegment(pathConf.RecordPath, pathName, fpath)
+}
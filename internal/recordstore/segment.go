@@ -5,7 +5,6 @@ import (
 	"io/fs"
 	"path/filepath"
 	"sort"
-	"strings"
 	"time"
 
 	"github.com/bluenviron/mediamtx/internal/conf"
@@ -20,40 +19,18 @@ var errFound = errors.New("found")
 type Segment struct {
 	Fpath string
 	Start time.Time
-}
-
-func fixedPathHasSegments(pathConf *conf.Path) bool {
-	recordPath := PathAddExtension(
-		strings.ReplaceAll(pathConf.RecordPath, "%path", pathConf.Name),
-		pathConf.RecordFormat,
-	)
-
-	// we have to convert to absolute paths
-	// otherwise, recordPath and fpath inside Walk() won't have common elements
-	recordPath, _ = filepath.Abs(recordPath)
-
-	commonPath := CommonPath(recordPath)
 
-	err := filepath.WalkDir(commonPath, func(fpath string, info fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if !info.IsDir() {
-			var pa Path
-			ok := pa.Decode(recordPath, fpath)
-			if ok {
-				return errFound
-			}
-		}
+	// filled when the segment is complete, nil otherwise.
+	Info *SegmentInfo
 
-		return nil
-	})
-	if err != nil && !errors.Is(err, errFound) {
-		return false
-	}
+	// filled when the segment has been uploaded to the object storage.
+	// The local copy may not exist anymore.
+	RemoteKey string
+}
 
-	return errors.Is(err, errFound)
+func fixedPathHasSegments(pathConf *conf.Path) bool {
+	segments, err := indexSegments(pathConf, pathConf.Name)
+	return err == nil && len(segments) != 0
 }
 
 func regexpPathFindPathsWithSegments(pathConf *conf.Path) map[string]struct{} {
@@ -75,13 +52,19 @@ func regexpPathFindPathsWithSegments(pathConf *conf.Path) map[string]struct{} {
 			return err
 		}
 
-		if !info.IsDir() {
-			var pa Path
-			if ok := pa.Decode(recordPath, fpath); ok {
-				if err = conf.IsValidPathName(pa.Path); err == nil {
-					if pathConf.Regexp.FindStringSubmatch(pa.Path) != nil {
-						ret[pa.Path] = struct{}{}
-					}
+		if info.IsDir() {
+			if info.Name() == IndexDirName || info.Name() == MarkersDirName ||
+				info.Name() == KeyframesDirName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		var pa Path
+		if ok := pa.Decode(recordPath, fpath); ok {
+			if err = conf.IsValidPathName(pa.Path); err == nil {
+				if pathConf.Regexp.FindStringSubmatch(pa.Path) != nil {
+					ret[pa.Path] = struct{}{}
 				}
 			}
 		}
@@ -92,8 +75,36 @@ func regexpPathFindPathsWithSegments(pathConf *conf.Path) map[string]struct{} {
 	return ret
 }
 
+// buildMissingIndexes builds indexes of all paths
+// whose recordings are stored in a directory without indexes.
+func buildMissingIndexes(pathConfs map[string]*conf.Path) {
+	missing := make(map[string]struct{})
+
+	for _, pathConf := range pathConfs {
+		if _, ok := indexPathNames(pathConf.RecordPath); !ok {
+			missing[indexRoot(pathConf.RecordPath)] = struct{}{}
+		}
+	}
+
+	for _, pathConf := range pathConfs {
+		if _, ok := missing[indexRoot(pathConf.RecordPath)]; !ok {
+			continue
+		}
+
+		if pathConf.Regexp == nil {
+			indexSegments(pathConf, pathConf.Name) //nolint:errcheck
+		} else {
+			for pathName := range regexpPathFindPathsWithSegments(pathConf) {
+				indexSegments(pathConf, pathName) //nolint:errcheck
+			}
+		}
+	}
+}
+
 // FindAllPathsWithSegments returns all paths that have at least one segment.
 func FindAllPathsWithSegments(pathConfs map[string]*conf.Path) []string {
+	buildMissingIndexes(pathConfs)
+
 	pathNames := make(map[string]struct{})
 
 	for _, pathConf := range pathConfs {
@@ -102,8 +113,22 @@ func FindAllPathsWithSegments(pathConfs map[string]*conf.Path) []string {
 				pathNames[pathConf.Name] = struct{}{}
 			}
 		} else {
-			for name := range regexpPathFindPathsWithSegments(pathConf) {
-				pathNames[name] = struct{}{}
+			indexed, _ := indexPathNames(pathConf.RecordPath)
+
+			for _, pathName := range indexed {
+				if _, ok := pathNames[pathName]; ok {
+					continue
+				}
+
+				if conf.IsValidPathName(pathName) != nil ||
+					pathConf.Regexp.FindStringSubmatch(pathName) == nil {
+					continue
+				}
+
+				segments, err := indexSegments(pathConf, pathName)
+				if err == nil && len(segments) != 0 {
+					pathNames[pathName] = struct{}{}
+				}
 			}
 		}
 	}
@@ -127,40 +152,18 @@ func FindSegments(
 	start *time.Time,
 	end *time.Time,
 ) ([]*Segment, error) {
-	recordPath := PathAddExtension(
-		strings.ReplaceAll(pathConf.RecordPath, "%path", pathName),
-		pathConf.RecordFormat,
-	)
-
-	// we have to convert to absolute paths
-	// otherwise, recordPath and fpath inside Walk() won't have common elements
-	recordPath, _ = filepath.Abs(recordPath)
+	all, err := indexSegments(pathConf, pathName)
+	if err != nil {
+		return nil, err
+	}
 
-	commonPath := CommonPath(recordPath)
 	var segments []*Segment
 
-	err := filepath.WalkDir(commonPath, func(fpath string, info fs.DirEntry, err error) error {
-		if err != nil {
-			return err
+	// gather all segments that start before the end of the playback
+	for _, seg := range all {
+		if end == nil || !end.Before(seg.Start) {
+			segments = append(segments, seg)
 		}
-
-		if !info.IsDir() {
-			var pa Path
-			ok := pa.Decode(recordPath, fpath)
-
-			// gather all segments that start before the end of the playback
-			if ok && (end == nil || !end.Before(pa.Start)) {
-				segments = append(segments, &Segment{
-					Fpath: fpath,
-					Start: pa.Start,
-				})
-			}
-		}
-
-		return nil
-	})
-	if err != nil {
-		return nil, err
 	}
 
 	if segments == nil {
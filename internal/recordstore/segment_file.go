@@ -0,0 +1,182 @@
+package recordstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+// SegmentReader allows to read a segment, stored on disk or in the object storage.
+type SegmentReader interface {
+	io.Reader
+	io.Seeker
+	io.ReaderAt
+	io.Closer
+}
+
+// SegmentFile is a segment file stored on disk, that may be encrypted.
+type SegmentFile interface {
+	io.Reader
+	io.Writer
+	io.Seeker
+	io.ReaderAt
+	io.Closer
+	Truncate(size int64) error
+	Size() (int64, error)
+}
+
+type plainFile struct {
+	*os.File
+}
+
+// Size implements SegmentFile.
+func (f plainFile) Size() (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// CreateSegmentFile creates a segment file.
+// When a key is provided, the segment is encrypted.
+func CreateSegmentFile(fpath string, key *EncryptionKey) (SegmentFile, error) {
+	f, err := os.Create(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == nil {
+		return plainFile{f}, nil
+	}
+
+	ef, err := createEncryptedFile(f, key)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return ef, nil
+}
+
+// OpenSegmentFile opens a segment file for reading and writing.
+// Encrypted segments are decrypted with one of the provided keys,
+// and their last chunk is removed when it has been partially written.
+func OpenSegmentFile(fpath string, keys []EncryptionKey) (SegmentFile, error) {
+	f, err := os.OpenFile(fpath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isEncrypted(f) {
+		return plainFile{f}, nil
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	ef, err := openEncryptedFile(f, fi.Size(), keys)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	ef.w = f
+	ef.truncate = f.Truncate
+	ef.closer = f
+
+	err = ef.recoverTail()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return ef, nil
+}
+
+type segmentReaderWithSize interface {
+	SegmentReader
+	Size() int64
+}
+
+type fileWithSize struct {
+	*os.File
+	size int64
+}
+
+func (f *fileWithSize) Size() int64 {
+	return f.size
+}
+
+func openRawSegment(ctx context.Context, pathConf *conf.Path, seg *Segment) (segmentReaderWithSize, error) {
+	f, err := os.Open(seg.Fpath)
+	if err == nil {
+		var fi os.FileInfo
+		fi, err = f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		return &fileWithSize{File: f, size: fi.Size()}, nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) || seg.RemoteKey == "" {
+		return nil, err
+	}
+
+	c, err := NewS3Client(pathConf)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Open(ctx, seg.RemoteKey)
+}
+
+// SegmentSize returns the size of a segment, as stored on disk or on the object storage.
+func SegmentSize(ctx context.Context, pathConf *conf.Path, seg *Segment) (int64, error) {
+	r, err := openRawSegment(ctx, pathConf, seg)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	return r.Size(), nil
+}
+
+// OpenSegment opens a segment for reading.
+// When the local copy of the segment doesn't exist anymore, the segment is read from the object storage.
+// Encrypted segments are decrypted transparently.
+func OpenSegment(ctx context.Context, pathConf *conf.Path, seg *Segment) (SegmentReader, error) {
+	r, err := openRawSegment(ctx, pathConf, seg)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isEncrypted(r) {
+		return r, nil
+	}
+
+	keys, err := LoadEncryptionKeys(pathConf)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	ef, err := openEncryptedFile(r, r.Size(), keys)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	ef.closer = r
+
+	return ef, nil
+}
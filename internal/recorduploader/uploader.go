@@ -0,0 +1,240 @@
+// Package recorduploader contains the recording uploader.
+package recorduploader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+const (
+	scanInterval   = 5 * time.Second
+	uploadTimeout  = 10 * time.Minute
+	minRetryPause  = 5 * time.Second
+	maxRetryPause  = 5 * time.Minute
+	retryPauseBase = 2
+)
+
+var timeNow = time.Now
+
+type failure struct {
+	count int
+	next  time.Time
+}
+
+// Uploader uploads complete recording segments to S3-compatible object storages.
+type Uploader struct {
+	PathConfs map[string]*conf.Path
+	Parent    logger.Writer
+
+	ctx       context.Context
+	ctxCancel func()
+	failures  map[string]*failure
+
+	chReloadConf chan map[string]*conf.Path
+	done         chan struct{}
+}
+
+// Initialize initializes an Uploader.
+func (u *Uploader) Initialize() {
+	u.ctx, u.ctxCancel = context.WithCancel(context.Background())
+	u.failures = make(map[string]*failure)
+	u.chReloadConf = make(chan map[string]*conf.Path, 1)
+	u.done = make(chan struct{})
+
+	go u.run()
+}
+
+// Close closes the Uploader.
+func (u *Uploader) Close() {
+	u.ctxCancel()
+	<-u.done
+}
+
+// Log implements logger.Writer.
+func (u *Uploader) Log(level logger.Level, format string, args ...interface{}) {
+	u.Parent.Log(level, "[record uploader]"+format, args...)
+}
+
+// ReloadPathConfs is called by core.Core.
+// It doesn't wait for running uploads: the last configuration
+// is applied when they are finished.
+func (u *Uploader) ReloadPathConfs(pathConfs map[string]*conf.Path) {
+	for {
+		select {
+		case u.chReloadConf <- pathConfs:
+			return
+
+		case <-u.ctx.Done():
+			return
+
+		default:
+			// replace the configuration that has not been applied yet
+			select {
+			case <-u.chReloadConf:
+			default:
+			}
+		}
+	}
+}
+
+func (u *Uploader) run() {
+	defer close(u.done)
+
+	u.doRun()
+
+	for {
+		select {
+		case <-time.After(scanInterval):
+			u.doRun()
+
+		case cnf := <-u.chReloadConf:
+			u.PathConfs = cnf
+
+		case <-u.ctx.Done():
+			return
+		}
+	}
+}
+
+func (u *Uploader) doRun() {
+	now := timeNow()
+	listed := make(map[string]struct{})
+
+	for _, pathName := range recordstore.FindAllPathsWithSegments(u.PathConfs) {
+		pathConf, _, err := conf.FindPathConf(u.PathConfs, pathName)
+		if err != nil || !pathConf.RecordS3Upload {
+			continue
+		}
+
+		segments, err := recordstore.FindSegments(pathConf, pathName, nil, nil)
+		if err != nil {
+			continue
+		}
+
+		for _, seg := range segments {
+			if u.ctx.Err() != nil {
+				return
+			}
+
+			listed[seg.Fpath] = struct{}{}
+			u.processSegment(now, pathConf, pathName, seg)
+		}
+	}
+
+	// forget segments that have been deleted
+	for fpath := range u.failures {
+		if _, ok := listed[fpath]; !ok {
+			delete(u.failures, fpath)
+		}
+	}
+}
+
+func (u *Uploader) processSegment(now time.Time, pathConf *conf.Path, pathName string, seg *recordstore.Segment) {
+	// segment has already been uploaded.
+	// the local copy may have been left on disk by a previous termination.
+	if seg.RemoteKey != "" {
+		if pathConf.RecordS3DeleteLocal {
+			err := os.Remove(seg.Fpath)
+			if err == nil {
+				u.Log(logger.Debug, "removed local copy of %s", seg.Fpath)
+			}
+		}
+		return
+	}
+
+	// segment is still being written
+	if seg.Info == nil {
+		return
+	}
+
+	if f, ok := u.failures[seg.Fpath]; ok && now.Before(f.next) {
+		return
+	}
+
+	err := u.upload(pathConf, pathName, seg)
+	if err != nil {
+		f, ok := u.failures[seg.Fpath]
+		if !ok {
+			f = &failure{}
+			u.failures[seg.Fpath] = f
+		}
+
+		pause := minRetryPause
+		for i := 0; i < f.count && pause < maxRetryPause; i++ {
+			pause *= retryPauseBase
+		}
+		if pause > maxRetryPause {
+			pause = maxRetryPause
+		}
+
+		f.count++
+		f.next = now.Add(pause)
+
+		u.Log(logger.Warn, "unable to upload %s: %v (retrying in %v)", seg.Fpath, err, pause)
+		return
+	}
+
+	delete(u.failures, seg.Fpath)
+}
+
+func (u *Uploader) upload(pathConf *conf.Path, pathName string, seg *recordstore.Segment) error {
+	c, err := recordstore.NewS3Client(pathConf)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(seg.Fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	key := recordstore.S3Key(pathConf, pathName, seg.Start)
+
+	ctx, ctxCancel := context.WithTimeout(u.ctx, uploadTimeout)
+	defer ctxCancel()
+
+	err = c.PutObject(ctx, key, f)
+	if err != nil {
+		return err
+	}
+
+	// verify that the object has been stored entirely before touching the local copy
+	size, err := c.HeadObject(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if size != fi.Size() {
+		return fmt.Errorf("size mismatch: expected %d, got %d", fi.Size(), size)
+	}
+
+	err = recordstore.IndexUploadSegment(pathConf.RecordPath, pathName, seg.Fpath, key)
+	if err != nil {
+		return err
+	}
+
+	u.Log(logger.Info, "uploaded %s to %s", seg.Fpath, key)
+
+	if pathConf.RecordS3DeleteLocal {
+		f.Close()
+
+		err = os.Remove(seg.Fpath)
+		if err != nil {
+			u.Log(logger.Warn, "unable to remove local copy of %s: %v", seg.Fpath, err)
+		}
+	}
+
+	return nil
+}
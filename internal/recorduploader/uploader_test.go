@@ -0,0 +1,184 @@
+package recorduploader
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploader(t *testing.T) {
+	now := time.Date(2009, 5, 20, 22, 15, 25, 427000, time.Local)
+	timeNow = func() time.Time {
+		return now
+	}
+
+	s := &test.S3Server{
+		Bucket:       "mybucket",
+		AccessKeyID:  "myaccesskey",
+		FailRequests: 1,
+	}
+	err := s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	dir, err := os.MkdirTemp("", "mediamtx-uploader")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pathConf := &conf.Path{
+		Name:                "~^.*$",
+		Regexp:              regexp.MustCompile("^.*$"),
+		RecordPath:          filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat:        conf.RecordFormatFMP4,
+		RecordS3Upload:      true,
+		RecordS3Endpoint:    s.Endpoint(),
+		RecordS3Region:      "us-east-1",
+		RecordS3Bucket:      "mybucket",
+		RecordS3AccessKeyID: "myaccesskey",
+		RecordS3SecretKey:   "mysecretkey",
+		RecordS3Key:         "recordings/%path/%Y-%m-%d_%H-%M-%S-%f",
+		RecordS3DeleteLocal: true,
+	}
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	fpath := filepath.Join(dir, "mypath", "2009-05-20_22-15-25-000427.mp4")
+
+	err = os.WriteFile(fpath, []byte{1, 2, 3, 4}, 0o644)
+	require.NoError(t, err)
+
+	err = recordstore.IndexAddSegment(pathConf.RecordPath, "mypath", fpath)
+	require.NoError(t, err)
+
+	err = recordstore.IndexCompleteSegment(pathConf.RecordPath, "mypath", fpath, recordstore.SegmentInfo{
+		Duration: time.Second,
+	})
+	require.NoError(t, err)
+
+	u := &Uploader{
+		PathConfs: map[string]*conf.Path{pathConf.Name: pathConf},
+		Parent:    test.NilLogger,
+	}
+	u.ctx, u.ctxCancel = context.WithCancel(context.Background())
+	defer u.ctxCancel()
+	u.failures = make(map[string]*failure)
+
+	// first attempt fails
+	u.doRun()
+	require.Empty(t, s.Objects())
+	require.Equal(t, 1, u.failures[fpath].count)
+
+	// retry is delayed
+	u.doRun()
+	require.Empty(t, s.Objects())
+
+	now = now.Add(minRetryPause)
+
+	u.doRun()
+	require.Equal(t, map[string][]byte{
+		"recordings/mypath/2009-05-20_22-15-25-000427.mp4": {1, 2, 3, 4},
+	}, s.Objects())
+	require.Empty(t, u.failures)
+
+	_, err = os.Stat(fpath)
+	require.True(t, os.IsNotExist(err))
+
+	// segment can still be listed and read
+	segments, err := recordstore.FindSegments(pathConf, "mypath", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	require.Equal(t, "recordings/mypath/2009-05-20_22-15-25-000427.mp4", segments[0].RemoteKey)
+
+	r, err := recordstore.OpenSegment(context.Background(), pathConf, segments[0])
+	require.NoError(t, err)
+	defer r.Close()
+
+	byts, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3, 4}, byts)
+}
+
+func TestUploaderForgetDeletedSegments(t *testing.T) {
+	now := time.Date(2009, 5, 20, 22, 15, 25, 427000, time.Local)
+	timeNow = func() time.Time {
+		return now
+	}
+
+	s := &test.S3Server{
+		Bucket:       "mybucket",
+		AccessKeyID:  "myaccesskey",
+		FailRequests: 1,
+	}
+	err := s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	dir, err := os.MkdirTemp("", "mediamtx-uploader")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pathConf := &conf.Path{
+		Name:                "mypath",
+		RecordPath:          filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat:        conf.RecordFormatFMP4,
+		RecordS3Upload:      true,
+		RecordS3Endpoint:    s.Endpoint(),
+		RecordS3Region:      "us-east-1",
+		RecordS3Bucket:      "mybucket",
+		RecordS3AccessKeyID: "myaccesskey",
+		RecordS3SecretKey:   "mysecretkey",
+		RecordS3Key:         "recordings/%path/%Y-%m-%d_%H-%M-%S-%f",
+	}
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	fpath := filepath.Join(dir, "mypath", "2009-05-20_22-15-25-000427.mp4")
+
+	err = os.WriteFile(fpath, []byte{1, 2, 3, 4}, 0o644)
+	require.NoError(t, err)
+
+	err = recordstore.IndexAddSegment(pathConf.RecordPath, "mypath", fpath)
+	require.NoError(t, err)
+
+	err = recordstore.IndexCompleteSegment(pathConf.RecordPath, "mypath", fpath, recordstore.SegmentInfo{
+		Duration: time.Second,
+	})
+	require.NoError(t, err)
+
+	u := &Uploader{
+		PathConfs: map[string]*conf.Path{pathConf.Name: pathConf},
+		Parent:    test.NilLogger,
+	}
+	u.ctx, u.ctxCancel = context.WithCancel(context.Background())
+	defer u.ctxCancel()
+	u.failures = make(map[string]*failure)
+	u.chReloadConf = make(chan map[string]*conf.Path, 1)
+
+	u.doRun()
+	require.Equal(t, 1, u.failures[fpath].count)
+
+	err = os.Remove(fpath)
+	require.NoError(t, err)
+
+	err = recordstore.IndexRemoveSegment(pathConf.RecordPath, "mypath", fpath)
+	require.NoError(t, err)
+
+	u.doRun()
+	require.Empty(t, u.failures)
+
+	// reloads do not wait for the run loop
+	u.ReloadPathConfs(map[string]*conf.Path{})
+	u.ReloadPathConfs(u.PathConfs)
+	require.Equal(t, u.PathConfs, <-u.chReloadConf)
+}
@@ -0,0 +1,567 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gohlslib/v2"
+	"github.com/bluenviron/gohlslib/v2/pkg/playlist"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/gin-gonic/gin"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/protocols/dash"
+)
+
+const (
+	dashManifestName = "index.mpd"
+	dashChunkMarker  = "_chunk"
+)
+
+var errDASHNotFound = errors.New("not found")
+
+// dashResponse stores the response of a request routed to the HLS muxer.
+type dashResponse struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func (r *dashResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *dashResponse) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.buf.Write(p)
+}
+
+func (r *dashResponse) WriteHeader(status int) {
+	r.status = status
+}
+
+// dashStream contains the timing of segments of a HLS stream.
+// It is filled by reading the decode time of each segment,
+// in order to produce a timeline that matches segments exactly.
+type dashStream struct {
+	track *fmp4.InitTrack
+	times map[uint64]uint64
+	sizes map[uint64]int
+}
+
+// dashState is the state of MPD generation, shared between requests.
+type dashState struct {
+	mutex                 sync.Mutex
+	availabilityStartTime time.Time
+	streams               map[string]*dashStream
+}
+
+type dashSegment struct {
+	id       uint64
+	duration time.Duration
+	dateTime *time.Time
+	fname    string
+}
+
+func stripQuery(uri string) string {
+	fname, _, _ := strings.Cut(uri, "?")
+	return fname
+}
+
+func appendQuery(fname string, rawQuery string) string {
+	if rawQuery == "" {
+		return fname
+	}
+	return fname + "?" + rawQuery
+}
+
+// fetch routes a request to the HLS muxer and returns the response body.
+func (mi *muxerInstance) fetch(ctx context.Context, fname string, rawQuery string) ([]byte, error) {
+	req := (&http.Request{
+		Method: http.MethodGet,
+		URL:    &url.URL{Path: fname, RawQuery: rawQuery},
+		Header: http.Header{},
+	}).WithContext(ctx)
+
+	res := &dashResponse{header: http.Header{}}
+	mi.hmuxer.Handle(res, req)
+
+	if res.status != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s", errDASHNotFound, fname)
+	}
+
+	return res.buf.Bytes(), nil
+}
+
+func (mi *muxerInstance) fetchMediaPlaylist(ctx context.Context, fname string, rawQuery string) (*playlist.Media, error) {
+	byts, err := mi.fetch(ctx, fname, rawQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	pl, err := playlist.Unmarshal(byts)
+	if err != nil {
+		return nil, err
+	}
+
+	mpl, ok := pl.(*playlist.Media)
+	if !ok {
+		return nil, fmt.Errorf("invalid media playlist")
+	}
+
+	return mpl, nil
+}
+
+// streamPlaylists returns the media playlists of all HLS streams.
+func (mi *muxerInstance) streamPlaylists(ctx context.Context) ([]string, error) {
+	byts, err := mi.fetch(ctx, "index.m3u8", "")
+	if err != nil {
+		return nil, err
+	}
+
+	pl, err := playlist.Unmarshal(byts)
+	if err != nil {
+		return nil, err
+	}
+
+	mpl, ok := pl.(*playlist.Multivariant)
+	if !ok {
+		return nil, fmt.Errorf("invalid multivariant playlist")
+	}
+
+	var out []string
+
+	add := func(uri string) {
+		fname := stripQuery(uri)
+		for _, cur := range out {
+			if cur == fname {
+				return
+			}
+		}
+		out = append(out, fname)
+	}
+
+	for _, v := range mpl.Variants {
+		add(v.URI)
+	}
+
+	for _, r := range mpl.Renditions {
+		if r.URI != nil {
+			add(*r.URI)
+		}
+	}
+
+	return out, nil
+}
+
+func mediaSegments(pl *playlist.Media) []*dashSegment {
+	var out []*dashSegment
+
+	for i, seg := range pl.Segments {
+		// gaps are placed at the beginning of Low-Latency HLS streams
+		if seg.Gap {
+			continue
+		}
+
+		out = append(out, &dashSegment{
+			id:       uint64(pl.MediaSequence + i),
+			duration: seg.Duration,
+			dateTime: seg.DateTime,
+			fname:    stripQuery(seg.URI),
+		})
+	}
+
+	return out
+}
+
+// segmentBaseTime returns the decode time of the first sample of a segment.
+// Parts without samples do not contain any track.
+func segmentBaseTime(parts fmp4.Parts) (uint64, bool) {
+	for _, part := range parts {
+		if len(part.Tracks) != 0 {
+			return part.Tracks[0].BaseTime, true
+		}
+	}
+	return 0, false
+}
+
+// update reads the initialization section and the decode time of new segments.
+func (ds *dashStream) update(ctx context.Context, mi *muxerInstance, pl *playlist.Media, segments []*dashSegment) error {
+	if ds.track == nil {
+		if pl.Map == nil {
+			return fmt.Errorf("media playlist doesn't contain an initialization section")
+		}
+
+		byts, err := mi.fetch(ctx, stripQuery(pl.Map.URI), "")
+		if err != nil {
+			return err
+		}
+
+		var init fmp4.Init
+		err = init.Unmarshal(bytes.NewReader(byts))
+		if err != nil {
+			return err
+		}
+
+		if len(init.Tracks) != 1 {
+			return fmt.Errorf("unexpected track count: %d", len(init.Tracks))
+		}
+
+		ds.track = init.Tracks[0]
+		ds.times = make(map[uint64]uint64)
+		ds.sizes = make(map[uint64]int)
+	}
+
+	present := make(map[uint64]struct{})
+
+	for _, seg := range segments {
+		present[seg.id] = struct{}{}
+
+		if _, ok := ds.times[seg.id]; ok {
+			continue
+		}
+
+		byts, err := mi.fetch(ctx, seg.fname, "")
+		if err != nil {
+			return err
+		}
+
+		var parts fmp4.Parts
+		err = parts.Unmarshal(byts)
+		if err != nil {
+			return err
+		}
+
+		ds.sizes[seg.id] = len(byts)
+
+		if baseTime, ok := segmentBaseTime(parts); ok {
+			ds.times[seg.id] = baseTime
+		}
+	}
+
+	// segments without samples do not contain their decode time.
+	// Compute it from adjacent segments.
+	for i := 1; i < len(segments); i++ {
+		_, ok := ds.times[segments[i].id]
+		prev, prevOK := ds.times[segments[i-1].id]
+		if !ok && prevOK {
+			ds.times[segments[i].id] = prev + durationToTimeScale(segments[i-1].duration, ds.track.TimeScale)
+		}
+	}
+	for i := len(segments) - 2; i >= 0; i-- {
+		_, ok := ds.times[segments[i].id]
+		next, nextOK := ds.times[segments[i+1].id]
+		if !ok && nextOK {
+			ds.times[segments[i].id] = next - durationToTimeScale(segments[i].duration, ds.track.TimeScale)
+		}
+	}
+
+	if _, ok := ds.times[segments[0].id]; !ok {
+		return fmt.Errorf("%w: stream has no samples", errDASHNotFound)
+	}
+
+	// remove segments that are not in the playlist anymore
+	for id := range ds.times {
+		if _, ok := present[id]; !ok {
+			delete(ds.times, id)
+			delete(ds.sizes, id)
+		}
+	}
+
+	return nil
+}
+
+func (ds *dashStream) bandwidth(segments []*dashSegment) int {
+	ret := 0
+
+	for _, seg := range segments {
+		if seg.duration <= 0 {
+			continue
+		}
+
+		bw := int(float64(ds.sizes[seg.id]*8) / seg.duration.Seconds())
+		if bw > ret {
+			ret = bw
+		}
+	}
+
+	return ret
+}
+
+func durationToTimeScale(d time.Duration, timeScale uint32) uint64 {
+	return uint64(d.Seconds()*float64(timeScale) + 0.5)
+}
+
+// timeline returns durations of segments, computed from their decode times.
+// The last duration is taken from the playlist.
+func (ds *dashStream) timeline(segments []*dashSegment) []uint64 {
+	out := make([]uint64, len(segments))
+
+	for i, seg := range segments {
+		if i != len(segments)-1 {
+			out[i] = ds.times[segments[i+1].id] - ds.times[seg.id]
+		} else {
+			out[i] = durationToTimeScale(seg.duration, ds.track.TimeScale)
+		}
+	}
+
+	return out
+}
+
+func (mi *muxerInstance) generateMPD(ctx context.Context, rawQuery string) ([]byte, error) {
+	playlists, err := mi.streamPlaylists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lowLatency := (mi.variant == conf.HLSVariant(gohlslib.MuxerVariantLowLatency))
+
+	mi.dash.mutex.Lock()
+	defer mi.dash.mutex.Unlock()
+
+	if mi.dash.streams == nil {
+		mi.dash.streams = make(map[string]*dashStream)
+	}
+
+	now := time.Now()
+
+	period := &dash.Period{ID: "0"}
+
+	var targetDuration time.Duration
+	var partTarget time.Duration
+	var timeShiftBufferDepth time.Duration
+
+	for i, plName := range playlists {
+		var pl *playlist.Media
+		pl, err = mi.fetchMediaPlaylist(ctx, plName, "")
+		if err != nil {
+			return nil, err
+		}
+
+		segments := mediaSegments(pl)
+		if len(segments) == 0 {
+			return nil, fmt.Errorf("%w: stream has no segments", errDASHNotFound)
+		}
+
+		ds, ok := mi.dash.streams[plName]
+		if !ok {
+			ds = &dashStream{}
+			mi.dash.streams[plName] = ds
+		}
+
+		err = ds.update(ctx, mi, pl, segments)
+		if err != nil {
+			return nil, err
+		}
+
+		// availability start time is computed once, with the wall clock date of a segment,
+		// and is shared by all streams, since they share the same clock.
+		if mi.dash.availabilityStartTime.IsZero() {
+			for _, seg := range segments {
+				if seg.dateTime != nil {
+					elapsed := time.Duration(float64(ds.times[seg.id]) / float64(ds.track.TimeScale) * float64(time.Second))
+					mi.dash.availabilityStartTime = seg.dateTime.Add(-elapsed)
+				}
+			}
+
+			if mi.dash.availabilityStartTime.IsZero() {
+				return nil, fmt.Errorf("segments do not contain dates")
+			}
+		}
+
+		streamID := strings.TrimSuffix(plName, "_stream.m3u8")
+		prefix := strings.TrimSuffix(segments[0].fname, "_seg"+strconv.FormatUint(segments[0].id, 10)+".mp4")
+
+		durations := ds.timeline(segments)
+
+		if td := time.Duration(pl.TargetDuration) * time.Second; td > targetDuration {
+			targetDuration = td
+		}
+
+		var windowDuration time.Duration
+		for _, seg := range segments {
+			windowDuration += seg.duration
+		}
+		if windowDuration > timeShiftBufferDepth {
+			timeShiftBufferDepth = windowDuration
+		}
+
+		tmpl := &dash.SegmentTemplate{
+			Timescale:      ds.track.TimeScale,
+			Initialization: appendQuery(stripQuery(pl.Map.URI), rawQuery),
+			Media:          appendQuery(prefix+"_seg$Number$.mp4", rawQuery),
+			StartNumber:    segments[0].id,
+		}
+
+		if lowLatency && pl.PartInf != nil {
+			// the segment in progress is added to the timeline and is served in chunks,
+			// one for each part, as soon as parts are produced.
+			durations = append(durations, durations[len(durations)-1])
+
+			partTarget = pl.PartInf.PartTarget
+
+			tmpl.Media = appendQuery(prefix+dashChunkMarker+"$Number$.mp4", rawQuery)
+			tmpl.AvailabilityTimeOffset = (time.Duration(pl.TargetDuration)*time.Second - partTarget).Seconds()
+			tmpl.AvailabilityTimeComplete = ptrOf(false)
+		}
+
+		tmpl.SegmentTimeline = dash.NewTimeline(ds.times[segments[0].id], durations)
+
+		as := dash.NewAdaptationSet(i, streamID, ds.track.Codec, ds.bandwidth(segments))
+		as.Representations[0].SegmentTemplate = tmpl
+		period.AdaptationSets = append(period.AdaptationSets, as)
+	}
+
+	ast := mi.dash.availabilityStartTime
+
+	m := &dash.MPD{
+		Profiles:              dash.ProfileLive,
+		Type:                  "dynamic",
+		AvailabilityStartTime: &ast,
+		PublishTime:           &now,
+		MinimumUpdatePeriod:   ptrOf(dash.Duration(targetDuration)),
+		MinBufferTime:         dash.Duration(targetDuration),
+		TimeShiftBufferDepth:  ptrOf(dash.Duration(timeShiftBufferDepth)),
+		MaxSegmentDuration:    ptrOf(dash.Duration(targetDuration)),
+		Periods:               []*dash.Period{period},
+		UTCTiming: &dash.UTCTiming{
+			SchemeIDURI: dash.UTCTimingDirect,
+			Value:       now.UTC().Format(time.RFC3339Nano),
+		},
+	}
+
+	if lowLatency {
+		m.Profiles += "," + dash.ProfileLowLatency
+		m.MinimumUpdatePeriod = ptrOf(dash.Duration(partTarget))
+		m.ServiceDescription = &dash.ServiceDescription{
+			Latency: &dash.Latency{
+				Target: (partTarget * 3).Milliseconds(),
+			},
+		}
+	} else {
+		m.SuggestedPresentationDelay = ptrOf(dash.Duration(targetDuration * 3))
+	}
+
+	return m.Marshal()
+}
+
+func (mi *muxerInstance) handleMPD(ctx *gin.Context) {
+	if mi.variant == conf.HLSVariant(gohlslib.MuxerVariantMPEGTS) {
+		ctx.Writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	byts, err := mi.generateMPD(ctx.Request.Context(), ctx.Request.URL.RawQuery)
+	if err != nil {
+		if errors.Is(err, errDASHNotFound) {
+			ctx.Writer.WriteHeader(http.StatusNotFound)
+		} else {
+			mi.Log(logger.Warn, "unable to generate MPD: %v", err)
+			ctx.Writer.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Data(http.StatusOK, dash.MPDType, byts)
+}
+
+// handleChunk serves a segment with chunked transfer encoding,
+// writing its parts while they are produced.
+func (mi *muxerInstance) handleChunk(ctx *gin.Context, fname string) {
+	i := strings.LastIndex(fname, dashChunkMarker)
+	base := fname[:i]
+	id, err := strconv.ParseUint(strings.TrimSuffix(fname[i+len(dashChunkMarker):], ".mp4"), 10, 64)
+	if err != nil {
+		ctx.Writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	streamID := base[strings.LastIndex(base, "_")+1:]
+	plName := streamID + "_stream.m3u8"
+	segName := base + "_seg" + strconv.FormatUint(id, 10) + ".mp4"
+
+	written := 0
+	partsWritten := 0
+
+	w := &responseWriterWithCounter{
+		ResponseWriter: ctx.Writer,
+		bytesSent:      mi.bytesSent,
+	}
+
+	write := func(byts []byte) error {
+		if written == 0 {
+			ctx.Header("Content-Type", "video/mp4")
+			ctx.Writer.WriteHeader(http.StatusOK)
+		}
+
+		_, err2 := w.Write(byts)
+		if err2 != nil {
+			return err2
+		}
+
+		http.NewResponseController(ctx.Writer).Flush() //nolint:errcheck
+		written += len(byts)
+		return nil
+	}
+
+	for {
+		// wait for the next part of the segment
+		v := url.Values{}
+		v.Set("_HLS_msn", strconv.FormatUint(id, 10))
+		v.Set("_HLS_part", strconv.FormatInt(int64(partsWritten), 10))
+
+		var pl *playlist.Media
+		pl, err = mi.fetchMediaPlaylist(ctx.Request.Context(), plName, v.Encode())
+		if err != nil {
+			break
+		}
+
+		// segment is complete: write the rest of it
+		if id < uint64(pl.MediaSequence+len(pl.Segments)) {
+			var byts []byte
+			byts, err = mi.fetch(ctx.Request.Context(), segName, "")
+			if err != nil || len(byts) < written {
+				break
+			}
+
+			if len(byts) != written {
+				write(byts[written:]) //nolint:errcheck
+			}
+			return
+		}
+
+		for _, part := range pl.Parts[partsWritten:] {
+			var byts []byte
+			byts, err = mi.fetch(ctx.Request.Context(), stripQuery(part.URI), "")
+			if err != nil {
+				break
+			}
+
+			err = write(byts)
+			if err != nil {
+				return
+			}
+			partsWritten++
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if written == 0 {
+		ctx.Writer.WriteHeader(http.StatusNotFound)
+	}
+}
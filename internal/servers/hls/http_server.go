@@ -27,16 +27,18 @@ func mergePathAndQuery(path string, rawQuery string) string {
 }
 
 type httpServer struct {
-	address        string
-	encryption     bool
-	serverKey      string
-	serverCert     string
-	allowOrigin    string
-	trustedProxies conf.IPNetworks
-	readTimeout    conf.Duration
-	writeTimeout   conf.Duration
-	pathManager    serverPathManager
-	parent         *Server
+	address              string
+	encryption           bool
+	serverKey            string
+	serverCert           string
+	clientCA             string
+	allowOrigin          string
+	trustedProxies       conf.IPNetworks
+	proxyProtocolSources conf.IPNetworks
+	readTimeout          conf.Duration
+	writeTimeout         conf.Duration
+	pathManager          serverPathManager
+	parent               *Server
 
 	inner *httpp.Server
 }
@@ -50,14 +52,16 @@ func (s *httpServer) initialize() error {
 	router.Use(s.onRequest)
 
 	s.inner = &httpp.Server{
-		Address:      s.address,
-		ReadTimeout:  time.Duration(s.readTimeout),
-		WriteTimeout: time.Duration(s.writeTimeout),
-		Encryption:   s.encryption,
-		ServerCert:   s.serverCert,
-		ServerKey:    s.serverKey,
-		Handler:      router,
-		Parent:       s,
+		Address:              s.address,
+		ReadTimeout:          time.Duration(s.readTimeout),
+		WriteTimeout:         time.Duration(s.writeTimeout),
+		Encryption:           s.encryption,
+		ServerCert:           s.serverCert,
+		ServerKey:            s.serverKey,
+		ClientCA:             s.clientCA,
+		ProxyProtocolSources: s.proxyProtocolSources,
+		Handler:              router,
+		Parent:               s,
 	}
 	err := s.inner.Initialize()
 	if err != nil {
@@ -103,6 +107,7 @@ func (s *httpServer) onRequest(ctx *gin.Context) {
 
 	switch {
 	case strings.HasSuffix(pa, ".m3u8") ||
+		strings.HasSuffix(pa, ".mpd") ||
 		strings.HasSuffix(pa, ".ts") ||
 		strings.HasSuffix(pa, ".mp4") ||
 		strings.HasSuffix(pa, ".mp"):
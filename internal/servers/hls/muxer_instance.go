@@ -3,6 +3,7 @@ package hls
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/bluenviron/gohlslib/v2"
@@ -28,6 +29,7 @@ type muxerInstance struct {
 
 	hmuxer *gohlslib.Muxer
 	reader *stream.Reader
+	dash   dashState
 }
 
 func (mi *muxerInstance) initialize() error {
@@ -90,6 +92,16 @@ func (mi *muxerInstance) errorChan() chan error {
 }
 
 func (mi *muxerInstance) handleRequest(ctx *gin.Context) {
+	switch {
+	case ctx.Request.URL.Path == dashManifestName:
+		mi.handleMPD(ctx)
+		return
+
+	case strings.Contains(ctx.Request.URL.Path, dashChunkMarker):
+		mi.handleChunk(ctx, ctx.Request.URL.Path)
+		return
+	}
+
 	w := &responseWriterWithCounter{
 		ResponseWriter: ctx.Writer,
 		bytesSent:      mi.bytesSent,
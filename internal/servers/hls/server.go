@@ -70,25 +70,27 @@ type serverParent interface {
 
 // Server is a HLS server.
 type Server struct {
-	Address         string
-	Encryption      bool
-	ServerKey       string
-	ServerCert      string
-	AllowOrigin     string
-	TrustedProxies  conf.IPNetworks
-	AlwaysRemux     bool
-	Variant         conf.HLSVariant
-	SegmentCount    int
-	SegmentDuration conf.Duration
-	PartDuration    conf.Duration
-	SegmentMaxSize  conf.StringSize
-	Directory       string
-	ReadTimeout     conf.Duration
-	WriteTimeout    conf.Duration
-	MuxerCloseAfter conf.Duration
-	Metrics         serverMetrics
-	PathManager     serverPathManager
-	Parent          serverParent
+	Address              string
+	Encryption           bool
+	ServerKey            string
+	ServerCert           string
+	ClientCA             string
+	AllowOrigin          string
+	TrustedProxies       conf.IPNetworks
+	ProxyProtocolSources conf.IPNetworks
+	AlwaysRemux          bool
+	Variant              conf.HLSVariant
+	SegmentCount         int
+	SegmentDuration      conf.Duration
+	PartDuration         conf.Duration
+	SegmentMaxSize       conf.StringSize
+	Directory            string
+	ReadTimeout          conf.Duration
+	WriteTimeout         conf.Duration
+	MuxerCloseAfter      conf.Duration
+	Metrics              serverMetrics
+	PathManager          serverPathManager
+	Parent               serverParent
 
 	ctx        context.Context
 	ctxCancel  func()
@@ -120,16 +122,18 @@ func (s *Server) Initialize() error {
 	s.chAPIMuxerGet = make(chan serverAPIMuxersGetReq)
 
 	s.httpServer = &httpServer{
-		address:        s.Address,
-		encryption:     s.Encryption,
-		serverKey:      s.ServerKey,
-		serverCert:     s.ServerCert,
-		allowOrigin:    s.AllowOrigin,
-		trustedProxies: s.TrustedProxies,
-		readTimeout:    s.ReadTimeout,
-		writeTimeout:   s.WriteTimeout,
-		pathManager:    s.PathManager,
-		parent:         s,
+		address:              s.Address,
+		encryption:           s.Encryption,
+		serverKey:            s.ServerKey,
+		serverCert:           s.ServerCert,
+		clientCA:             s.ClientCA,
+		allowOrigin:          s.AllowOrigin,
+		trustedProxies:       s.TrustedProxies,
+		proxyProtocolSources: s.ProxyProtocolSources,
+		readTimeout:          s.ReadTimeout,
+		writeTimeout:         s.WriteTimeout,
+		pathManager:          s.PathManager,
+		parent:               s,
 	}
 	err := s.httpServer.initialize()
 	if err != nil {
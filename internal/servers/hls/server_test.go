@@ -1,11 +1,14 @@
 package hls
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,11 +16,13 @@ import (
 	"github.com/bluenviron/gohlslib/v2/pkg/codecs"
 	"github.com/bluenviron/gortsplib/v5/pkg/description"
 	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
 	"github.com/bluenviron/mediamtx/internal/auth"
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/defs"
 	"github.com/bluenviron/mediamtx/internal/externalcmd"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/protocols/dash"
 	"github.com/bluenviron/mediamtx/internal/stream"
 	"github.com/bluenviron/mediamtx/internal/test"
 	"github.com/bluenviron/mediamtx/internal/unit"
@@ -564,3 +569,175 @@ func TestAuthError(t *testing.T) {
 
 	require.Equal(t, 2, n)
 }
+
+func TestServerDASH(t *testing.T) {
+	for _, ca := range []string{
+		"fmp4",
+		"lowLatency",
+	} {
+		t.Run(ca, func(t *testing.T) {
+			desc := &description.Session{Medias: []*description.Media{
+				test.MediaH264,
+				test.MediaMPEG4Audio,
+			}}
+
+			strm := &stream.Stream{
+				WriteQueueSize:     512,
+				RTPMaxPayloadSize:  1450,
+				Desc:               desc,
+				GenerateRTPPackets: true,
+				Parent:             test.NilLogger,
+			}
+			err := strm.Initialize()
+			require.NoError(t, err)
+
+			pm := &dummyPathManager{
+				findPathConfImpl: func(_ defs.PathFindPathConfReq) (*conf.Path, error) {
+					return &conf.Path{}, nil
+				},
+				addReaderImpl: func(_ defs.PathAddReaderReq) (defs.Path, *stream.Stream, error) {
+					return &dummyPath{}, strm, nil
+				},
+			}
+
+			var variant gohlslib.MuxerVariant
+			if ca == "fmp4" {
+				variant = gohlslib.MuxerVariantFMP4
+			} else {
+				variant = gohlslib.MuxerVariantLowLatency
+			}
+
+			s := &Server{
+				Address:         "127.0.0.1:8888",
+				AlwaysRemux:     true,
+				Variant:         conf.HLSVariant(variant),
+				SegmentCount:    7,
+				SegmentDuration: conf.Duration(1 * time.Second),
+				PartDuration:    conf.Duration(200 * time.Millisecond),
+				SegmentMaxSize:  50 * 1024 * 1024,
+				TrustedProxies:  conf.IPNetworks{},
+				ReadTimeout:     conf.Duration(10 * time.Second),
+				WriteTimeout:    conf.Duration(10 * time.Second),
+				PathManager:     pm,
+				Parent:          test.NilLogger,
+			}
+			err = s.Initialize()
+			require.NoError(t, err)
+			defer s.Close()
+
+			s.PathReady(&dummyPath{})
+
+			time.Sleep(500 * time.Millisecond)
+
+			ntp := time.Date(2010, 1, 1, 12, 0, 0, 0, time.UTC)
+
+			writeUnits := func(from int, to int) {
+				for i := from; i < to; i++ {
+					strm.WriteUnit(test.MediaH264, test.FormatH264, &unit.Unit{
+						NTP: ntp.Add(time.Duration(i) * time.Second),
+						PTS: int64(i) * 90000,
+						Payload: unit.PayloadH264{
+							{5, 1}, // IDR
+						},
+					})
+					strm.WriteUnit(test.MediaMPEG4Audio, test.FormatMPEG4Audio, &unit.Unit{
+						NTP:     ntp.Add(time.Duration(i) * time.Second),
+						PTS:     int64(i) * 44100,
+						Payload: unit.PayloadMPEG4Audio{{1, 2}},
+					})
+				}
+			}
+
+			writeUnits(0, 5)
+
+			tr := &http.Transport{}
+			defer tr.CloseIdleConnections()
+			hc := &http.Client{Transport: tr}
+
+			get := func(u string) []byte {
+				res, err2 := hc.Get("http://127.0.0.1:8888/teststream/" + u)
+				require.NoError(t, err2)
+				defer res.Body.Close()
+
+				require.Equal(t, http.StatusOK, res.StatusCode)
+
+				byts, err2 := io.ReadAll(res.Body)
+				require.NoError(t, err2)
+				return byts
+			}
+
+			var mpd dash.MPD
+			err = mpd.Unmarshal(get("index.mpd"))
+			require.NoError(t, err)
+
+			require.Equal(t, "dynamic", mpd.Type)
+			require.Len(t, mpd.Periods, 1)
+			require.Len(t, mpd.Periods[0].AdaptationSets, 2)
+
+			video := mpd.Periods[0].AdaptationSets[0]
+			require.Equal(t, "video", video.ContentType)
+			require.Equal(t, "avc1.42c028", video.Representations[0].Codecs)
+			require.Equal(t, "audio", mpd.Periods[0].AdaptationSets[1].ContentType)
+			require.Equal(t, "mp4a.40.2", mpd.Periods[0].AdaptationSets[1].Representations[0].Codecs)
+
+			tmpl := video.Representations[0].SegmentTemplate
+			require.Equal(t, uint32(90000), tmpl.Timescale)
+			require.NotNil(t, tmpl.SegmentTimeline.S[0].T)
+
+			// segment dates and decode times must be consistent with the availability start time
+			firstSegment := tmpl.StartNumber
+			if ca == "lowLatency" {
+				firstSegment -= 7 // initial gaps
+			}
+			seg0Start := mpd.AvailabilityStartTime.Add(time.Duration(
+				float64(*tmpl.SegmentTimeline.S[0].T) / 90000 * float64(time.Second)))
+			require.WithinDuration(t, ntp.Add(time.Duration(firstSegment)*time.Second), seg0Start, 10*time.Millisecond)
+
+			var init fmp4.Init
+			err = init.Unmarshal(bytes.NewReader(get(tmpl.Initialization)))
+			require.NoError(t, err)
+			require.Equal(t, 90000, int(init.Tracks[0].TimeScale))
+
+			var parts fmp4.Parts
+			err = parts.Unmarshal(get(strings.ReplaceAll(tmpl.Media, "$Number$",
+				strconv.FormatUint(tmpl.StartNumber, 10))))
+			require.NoError(t, err)
+			require.Equal(t, *tmpl.SegmentTimeline.S[0].T, parts[0].Tracks[0].BaseTime)
+
+			if ca == "lowLatency" {
+				require.Contains(t, tmpl.Media, "_chunk$Number$.mp4")
+				require.Equal(t, false, *tmpl.AvailabilityTimeComplete)
+
+				count := 0
+				for _, s := range tmpl.SegmentTimeline.S {
+					count += 1 + s.R
+				}
+
+				// the last entry of the timeline is the segment in progress,
+				// that is served while it is being produced.
+				done := make(chan []byte)
+
+				go func() {
+					defer close(done)
+
+					res, err2 := hc.Get("http://127.0.0.1:8888/teststream/" + strings.ReplaceAll(tmpl.Media, "$Number$",
+						strconv.FormatUint(tmpl.StartNumber+uint64(count-1), 10)))
+					if err2 != nil || res.StatusCode != http.StatusOK {
+						return
+					}
+					defer res.Body.Close()
+
+					byts, _ := io.ReadAll(res.Body)
+					done <- byts
+				}()
+
+				time.Sleep(100 * time.Millisecond)
+				writeUnits(5, 8)
+
+				err = parts.Unmarshal(<-done)
+				require.NoError(t, err)
+				require.NotEmpty(t, parts)
+			}
+		})
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/hooks"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/protocols/rtmp"
+	"github.com/bluenviron/mediamtx/internal/protocols/tls"
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
 
@@ -159,8 +160,9 @@ func (c *conn) runRead() error {
 			Proto: auth.ProtocolRTMP,
 			ID:    &c.uuid,
 			Credentials: &auth.Credentials{
-				User: query.Get("user"),
-				Pass: query.Get("pass"),
+				User:                 query.Get("user"),
+				Pass:                 query.Get("pass"),
+				ClientCertIdentities: tls.ConnClientIdentities(c.nconn),
 			},
 			IP: c.ip(),
 		},
@@ -249,8 +251,9 @@ func (c *conn) runPublish() error {
 			Proto:   auth.ProtocolRTMP,
 			ID:      &c.uuid,
 			Credentials: &auth.Credentials{
-				User: query.Get("user"),
-				Pass: query.Get("pass"),
+				User:                 query.Get("user"),
+				Pass:                 query.Get("pass"),
+				ClientCertIdentities: tls.ConnClientIdentities(c.nconn),
 			},
 			IP: c.ip(),
 		},
@@ -302,6 +305,28 @@ func (c *conn) APISourceDescribe() defs.APIPathSourceOrReader {
 	return c.APIReaderDescribe()
 }
 
+// BytesReceived implements defs.BytesCounter.
+func (c *conn) BytesReceived() uint64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if c.rconn == nil {
+		return 0
+	}
+	return c.rconn.BytesReceived()
+}
+
+// BytesSent implements defs.BytesCounter.
+func (c *conn) BytesSent() uint64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if c.rconn == nil {
+		return 0
+	}
+	return c.rconn.BytesSent()
+}
+
 func (c *conn) apiItem() *defs.APIRTMPConn {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
@@ -323,5 +348,12 @@ func (c *conn) apiItem() *defs.APIRTMPConn {
 		Query:         c.query,
 		BytesReceived: bytesReceived,
 		BytesSent:     bytesSent,
+		ClientCertIdentity: func() *string {
+			ids := tls.ConnClientIdentities(c.nconn)
+			if len(ids) == 0 {
+				return nil
+			}
+			return &ids[0]
+		}(),
 	}
 }
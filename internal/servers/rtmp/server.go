@@ -10,6 +10,7 @@ import (
 	"reflect"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -18,6 +19,8 @@ import (
 	"github.com/bluenviron/mediamtx/internal/defs"
 	"github.com/bluenviron/mediamtx/internal/externalcmd"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/protocols/proxyprotocol"
+	mtls "github.com/bluenviron/mediamtx/internal/protocols/tls"
 	"github.com/bluenviron/mediamtx/internal/restrictnetwork"
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
@@ -73,20 +76,22 @@ type serverParent interface {
 
 // Server is a RTMP server.
 type Server struct {
-	Address             string
-	ReadTimeout         conf.Duration
-	WriteTimeout        conf.Duration
-	IsTLS               bool
-	ServerCert          string
-	ServerKey           string
-	RTSPAddress         string
-	RunOnConnect        string
-	RunOnConnectRestart bool
-	RunOnDisconnect     string
-	ExternalCmdPool     *externalcmd.Pool
-	Metrics             serverMetrics
-	PathManager         serverPathManager
-	Parent              serverParent
+	Address              string
+	ReadTimeout          conf.Duration
+	WriteTimeout         conf.Duration
+	IsTLS                bool
+	ServerCert           string
+	ServerKey            string
+	ClientCA             string
+	ProxyProtocolSources conf.IPNetworks
+	RTSPAddress          string
+	RunOnConnect         string
+	RunOnConnectRestart  bool
+	RunOnDisconnect      string
+	ExternalCmdPool      *externalcmd.Pool
+	Metrics              serverMetrics
+	PathManager          serverPathManager
+	Parent               serverParent
 
 	ctx       context.Context
 	ctxCancel func()
@@ -108,7 +113,11 @@ type Server struct {
 func (s *Server) Initialize() error {
 	ln, err := func() (net.Listener, error) {
 		if !s.IsTLS {
-			return net.Listen(restrictnetwork.Restrict("tcp", s.Address))
+			ln, err := net.Listen(restrictnetwork.Restrict("tcp", s.Address))
+			if err != nil {
+				return nil, err
+			}
+			return proxyprotocol.Wrap(ln, s.ProxyProtocolSources, time.Duration(s.ReadTimeout)), nil
 		}
 
 		s.loader = &certloader.CertLoader{
@@ -121,8 +130,19 @@ func (s *Server) Initialize() error {
 			return nil, err
 		}
 
-		network, address := restrictnetwork.Restrict("tcp", s.Address)
-		return tls.Listen(network, address, &tls.Config{GetCertificate: s.loader.GetCertificate()})
+		tlsConfig := &tls.Config{GetCertificate: s.loader.GetCertificate()}
+
+		err = mtls.ConfigureClientAuth(tlsConfig, s.ClientCA)
+		if err != nil {
+			s.loader.Close()
+			return nil, err
+		}
+
+		ln, err := net.Listen(restrictnetwork.Restrict("tcp", s.Address))
+		if err != nil {
+			return nil, err
+		}
+		return tls.NewListener(proxyprotocol.Wrap(ln, s.ProxyProtocolSources, time.Duration(s.ReadTimeout)), tlsConfig), nil
 	}()
 	if err != nil {
 		return err
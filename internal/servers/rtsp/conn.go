@@ -21,6 +21,8 @@ import (
 	"github.com/bluenviron/mediamtx/internal/hooks"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/protocols/rtsp"
+	"github.com/bluenviron/mediamtx/internal/protocols/tls"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 )
 
 func absoluteURL(req *base.Request, v string) string {
@@ -53,9 +55,20 @@ type connParent interface {
 	findSessionByRSessionUnsafe(rsession *gortsplib.ServerSession) *session
 }
 
+func clientCertIdentity(nconn net.Conn) *string {
+	ids := tls.ConnClientIdentities(nconn)
+	if len(ids) == 0 {
+		return nil
+	}
+	return &ids[0]
+}
+
 type conn struct {
 	isTLS               bool
 	rtspAddress         string
+	playback            bool
+	writeQueueSize      int
+	rtpMaxPayloadSize   int
 	authMethods         []rtspauth.VerifyMethod
 	readTimeout         conf.Duration
 	runOnConnect        string
@@ -70,6 +83,7 @@ type conn struct {
 	uuid             uuid.UUID
 	created          time.Time
 	onDisconnectHook func()
+	describePlayback *sessionPlayback
 }
 
 func (c *conn) initialize() {
@@ -104,6 +118,12 @@ func (c *conn) Log(level logger.Level, format string, args ...interface{}) {
 	c.parent.Log(level, "[conn %v] "+format, append([]interface{}{c.rconn.NetConn().RemoteAddr()}, args...)...)
 }
 
+func (c *conn) credentials(req *base.Request) *auth.Credentials {
+	ret := rtsp.Credentials(req)
+	ret.ClientCertIdentities = tls.ConnClientIdentities(c.rconn.NetConn())
+	return ret
+}
+
 // Conn returns the RTSP connection.
 func (c *conn) Conn() *gortsplib.ServerConn {
 	return c.rconn
@@ -121,6 +141,10 @@ func (c *conn) ip() net.IP {
 func (c *conn) onClose(err error) {
 	c.Log(logger.Info, "closed: %v", err)
 
+	if c.describePlayback != nil {
+		c.describePlayback.close()
+	}
+
 	c.onDisconnectHook()
 }
 
@@ -153,13 +177,17 @@ func (c *conn) onDescribe(ctx *gortsplib.ServerHandlerOnDescribeCtx,
 		}
 	}
 
+	if isPlaybackRequest(ctx.Query) {
+		return c.onDescribePlayback(ctx, customVerifyFunc)
+	}
+
 	res := c.pathManager.Describe(defs.PathDescribeReq{
 		AccessRequest: defs.PathAccessRequest{
 			Name:             ctx.Path,
 			Query:            ctx.Query,
 			Proto:            auth.ProtocolRTSP,
 			ID:               &c.uuid,
-			Credentials:      rtsp.Credentials(ctx.Request),
+			Credentials:      c.credentials(ctx.Request),
 			IP:               c.ip(),
 			CustomVerifyFunc: customVerifyFunc,
 		},
@@ -205,6 +233,106 @@ func (c *conn) onDescribe(ctx *gortsplib.ServerHandlerOnDescribeCtx,
 	}, stream, nil
 }
 
+func (c *conn) onDescribePlayback(
+	ctx *gortsplib.ServerHandlerOnDescribeCtx,
+	customVerifyFunc func(expectedUser, expectedPass string) bool,
+) (*base.Response, *gortsplib.ServerStream, error) {
+	pathConf, res, err := c.findPlaybackPathConf(ctx.Request, ctx.Path, ctx.Query, customVerifyFunc)
+	if err != nil {
+		return res, nil, err
+	}
+
+	pb, res, err := c.newPlayback(pathConf, ctx.Path, c)
+	if err != nil {
+		return res, nil, err
+	}
+
+	// the stream is needed to generate the response and is released later
+	if c.describePlayback != nil {
+		c.describePlayback.close()
+	}
+	c.describePlayback = pb
+
+	var stream *gortsplib.ServerStream
+	if !c.isTLS {
+		stream = pb.stream.RTSPStream(c.rserver)
+	} else {
+		stream = pb.stream.RTSPSStream(c.rserver)
+	}
+
+	return &base.Response{
+		StatusCode: base.StatusOK,
+	}, stream, nil
+}
+
+// findPlaybackPathConf authenticates a playback request and returns the path configuration.
+func (c *conn) findPlaybackPathConf(
+	req *base.Request,
+	pathName string,
+	query string,
+	customVerifyFunc func(expectedUser, expectedPass string) bool,
+) (*conf.Path, *base.Response, error) {
+	if !c.playback {
+		return nil, &base.Response{
+			StatusCode: base.StatusBadRequest,
+		}, fmt.Errorf("playback is disabled")
+	}
+
+	pathConf, err := c.pathManager.FindPathConf(defs.PathFindPathConfReq{
+		AccessRequest: defs.PathAccessRequest{
+			Name:             pathName,
+			Query:            query,
+			Playback:         true,
+			Proto:            auth.ProtocolRTSP,
+			ID:               &c.uuid,
+			Credentials:      c.credentials(req),
+			IP:               c.ip(),
+			CustomVerifyFunc: customVerifyFunc,
+		},
+	})
+	if err != nil {
+		var terr *auth.Error
+		if errors.As(err, &terr) {
+			res, err2 := c.handleAuthError(terr)
+			return nil, res, err2
+		}
+
+		return nil, &base.Response{
+			StatusCode: base.StatusBadRequest,
+		}, err
+	}
+
+	return pathConf, nil, nil
+}
+
+func (c *conn) newPlayback(
+	pathConf *conf.Path,
+	pathName string,
+	parent logger.Writer,
+) (*sessionPlayback, *base.Response, error) {
+	pb := &sessionPlayback{
+		pathConf:          pathConf,
+		pathName:          pathName,
+		writeQueueSize:    c.writeQueueSize,
+		rtpMaxPayloadSize: c.rtpMaxPayloadSize,
+		parent:            parent,
+	}
+	err := pb.initialize()
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			return nil, &base.Response{
+				StatusCode: base.StatusNotFound,
+			}, err
+		}
+
+		return nil, &base.Response{
+			StatusCode: base.StatusBadRequest,
+		}, err
+	}
+
+	return pb, nil, nil
+}
+
 func (c *conn) handleAuthError(err *auth.Error) (*base.Response, error) {
 	if err.AskCredentials {
 		return &base.Response{
@@ -236,6 +364,7 @@ func (c *conn) apiItem() *defs.APIRTSPConn {
 			}
 			return nil
 		}(),
-		Tunnel: tunnelLabel(c.rconn.Transport().Tunnel),
+		Tunnel:             tunnelLabel(c.rconn.Transport().Tunnel),
+		ClientCertIdentity: clientCertIdentity(c.rconn.NetConn()),
 	}
 }
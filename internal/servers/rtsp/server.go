@@ -6,6 +6,7 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
 	"reflect"
 	"sort"
 	"strings"
@@ -23,6 +24,8 @@ import (
 	"github.com/bluenviron/mediamtx/internal/defs"
 	"github.com/bluenviron/mediamtx/internal/externalcmd"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/protocols/proxyprotocol"
+	mtls "github.com/bluenviron/mediamtx/internal/protocols/tls"
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
 
@@ -70,31 +73,35 @@ type serverParent interface {
 
 // Server is a RTSP server.
 type Server struct {
-	Address             string
-	AuthMethods         []auth.VerifyMethod
-	UDPReadBufferSize   uint
-	ReadTimeout         conf.Duration
-	WriteTimeout        conf.Duration
-	WriteQueueSize      int
-	UseUDP              bool
-	UseMulticast        bool
-	RTPAddress          string
-	RTCPAddress         string
-	MulticastIPRange    string
-	MulticastRTPPort    int
-	MulticastRTCPPort   int
-	IsTLS               bool
-	ServerCert          string
-	ServerKey           string
-	RTSPAddress         string
-	Transports          conf.RTSPTransports
-	RunOnConnect        string
-	RunOnConnectRestart bool
-	RunOnDisconnect     string
-	ExternalCmdPool     *externalcmd.Pool
-	Metrics             serverMetrics
-	PathManager         serverPathManager
-	Parent              serverParent
+	Address              string
+	AuthMethods          []auth.VerifyMethod
+	UDPReadBufferSize    uint
+	ReadTimeout          conf.Duration
+	WriteTimeout         conf.Duration
+	WriteQueueSize       int
+	UseUDP               bool
+	UseMulticast         bool
+	RTPAddress           string
+	RTCPAddress          string
+	MulticastIPRange     string
+	MulticastRTPPort     int
+	MulticastRTCPPort    int
+	IsTLS                bool
+	ServerCert           string
+	ServerKey            string
+	ClientCA             string
+	ProxyProtocolSources conf.IPNetworks
+	RTSPAddress          string
+	Transports           conf.RTSPTransports
+	Playback             bool
+	RTPMaxPayloadSize    int
+	RunOnConnect         string
+	RunOnConnectRestart  bool
+	RunOnDisconnect      string
+	ExternalCmdPool      *externalcmd.Pool
+	Metrics              serverMetrics
+	PathManager          serverPathManager
+	Parent               serverParent
 
 	ctx       context.Context
 	ctxCancel func()
@@ -123,6 +130,16 @@ func (s *Server) Initialize() error {
 		AuthMethods:       s.AuthMethods,
 	}
 
+	if len(s.ProxyProtocolSources) != 0 {
+		s.srv.Listen = func(network string, address string) (net.Listener, error) {
+			ln, err := net.Listen(network, address)
+			if err != nil {
+				return nil, err
+			}
+			return proxyprotocol.Wrap(ln, s.ProxyProtocolSources, time.Duration(s.ReadTimeout)), nil
+		}
+	}
+
 	if s.UseUDP {
 		s.srv.UDPRTPAddress = s.RTPAddress
 		s.srv.UDPRTCPAddress = s.RTCPAddress
@@ -146,6 +163,12 @@ func (s *Server) Initialize() error {
 		}
 
 		s.srv.TLSConfig = &tls.Config{GetCertificate: s.loader.GetCertificate()}
+
+		err = mtls.ConfigureClientAuth(s.srv.TLSConfig, s.ClientCA)
+		if err != nil {
+			s.loader.Close()
+			return err
+		}
 	}
 
 	err := s.srv.Start()
@@ -228,6 +251,9 @@ func (s *Server) OnConnOpen(ctx *gortsplib.ServerHandlerOnConnOpenCtx) {
 	c := &conn{
 		isTLS:               s.IsTLS,
 		rtspAddress:         s.RTSPAddress,
+		playback:            s.Playback,
+		writeQueueSize:      s.WriteQueueSize,
+		rtpMaxPayloadSize:   s.RTPMaxPayloadSize,
 		authMethods:         s.AuthMethods,
 		readTimeout:         s.ReadTimeout,
 		runOnConnect:        s.RunOnConnect,
@@ -2,6 +2,8 @@ package rtsp
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -11,6 +13,11 @@ import (
 	"github.com/bluenviron/gortsplib/v5/pkg/base"
 	"github.com/bluenviron/gortsplib/v5/pkg/description"
 	"github.com/bluenviron/gortsplib/v5/pkg/format"
+	"github.com/bluenviron/gortsplib/v5/pkg/headers"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
 	"github.com/bluenviron/mediamtx/internal/auth"
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/defs"
@@ -444,3 +451,128 @@ func TestAuthError(t *testing.T) {
 
 	<-done
 }
+
+func TestServerPlayback(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "teststream"), 0o755)
+	require.NoError(t, err)
+
+	init := fmp4.Init{
+		Tracks: []*fmp4.InitTrack{{
+			ID:        1,
+			TimeScale: 90000,
+			Codec: &mp4.CodecH264{
+				SPS: test.FormatH264.SPS,
+				PPS: test.FormatH264.PPS,
+			},
+		}},
+	}
+
+	var buf1 seekablebuffer.Buffer
+	err = init.Marshal(&buf1)
+	require.NoError(t, err)
+
+	// a sample per second and a keyframe every two seconds
+	samples := make([]*fmp4.Sample, 10)
+	for i := range samples {
+		typ := byte(h264.NALUTypeIDR)
+		if (i % 2) != 0 {
+			typ = byte(h264.NALUTypeNonIDR)
+		}
+
+		var pl []byte
+		pl, err = h264.AVCC{{typ, byte(i)}}.Marshal()
+		require.NoError(t, err)
+
+		samples[i] = &fmp4.Sample{
+			Duration:        90000,
+			IsNonSyncSample: (i % 2) != 0,
+			Payload:         pl,
+		}
+	}
+
+	var buf2 seekablebuffer.Buffer
+	parts := fmp4.Parts{{
+		Tracks: []*fmp4.PartTrack{{
+			ID:      1,
+			Samples: samples,
+		}},
+	}}
+	err = parts.Marshal(&buf2)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "teststream", "2008-11-07_11-22-00-000000.mp4"),
+		append(buf1.Bytes(), buf2.Bytes()...), 0o644)
+	require.NoError(t, err)
+
+	pathManager := &test.PathManager{
+		FindPathConfImpl: func(req defs.PathFindPathConfReq) (*conf.Path, error) {
+			require.Equal(t, "teststream", req.AccessRequest.Name)
+			require.Equal(t, "playback", req.AccessRequest.Query)
+			require.True(t, req.AccessRequest.Playback)
+
+			return &conf.Path{
+				Name:       "teststream",
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			}, nil
+		},
+	}
+
+	s := &Server{
+		Address:           "127.0.0.1:8557",
+		ReadTimeout:       conf.Duration(10 * time.Second),
+		WriteTimeout:      conf.Duration(10 * time.Second),
+		WriteQueueSize:    512,
+		Transports:        conf.RTSPTransports{gortsplib.ProtocolTCP: {}},
+		Playback:          true,
+		RTPMaxPayloadSize: 1450,
+		PathManager:       pathManager,
+		Parent:            test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	u, err := base.ParseURL("rtsp://127.0.0.1:8557/teststream?playback")
+	require.NoError(t, err)
+
+	reader := gortsplib.Client{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+	}
+
+	err = reader.Start()
+	require.NoError(t, err)
+	defer reader.Close()
+
+	desc, _, err := reader.Describe(u)
+	require.NoError(t, err)
+	require.Len(t, desc.Medias, 1)
+
+	err = reader.SetupAll(desc.BaseURL, desc.Medias)
+	require.NoError(t, err)
+
+	recv := make(chan []byte, 10)
+
+	reader.OnPacketRTPAny(func(_ *description.Media, _ format.Format, p *rtp.Packet) {
+		recv <- p.Payload
+	})
+
+	start := time.Date(2008, 11, 7, 11, 22, 2, 0, time.Local).UTC()
+
+	res, err := reader.Play(&headers.Range{
+		Value: &headers.RangeUTC{Start: start},
+	})
+	require.NoError(t, err)
+	require.Equal(t, base.HeaderValue{"clock=20081107T" + start.Format("150405") + "Z-"}, res.Header["Range"])
+
+	// first packet contains the keyframe at the requested date
+	pl := <-recv
+	require.Equal(t, []byte{0x00, 0x02, 0x05, 0x02}, pl[len(pl)-4:])
+
+	pl = <-recv
+	require.Equal(t, []byte{0x01, 0x03}, pl)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"slices"
+	"strconv"
 	"time"
 
 	"github.com/bluenviron/gortsplib/v5"
@@ -22,6 +23,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/hooks"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/protocols/rtsp"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
 
@@ -50,6 +52,7 @@ type session struct {
 	pathConf        *conf.Path // record only
 	path            defs.Path
 	stream          *stream.Stream
+	playback        *sessionPlayback
 	onUnreadHook    func()
 	packetsLost     *counterdumper.CounterDumper
 	decodeErrors    *counterdumper.CounterDumper
@@ -124,13 +127,18 @@ func (s *session) Log(level logger.Level, format string, args ...interface{}) {
 
 // onClose is called by rtspServer.
 func (s *session) onClose(err error) {
-	if s.rsession.State() == gortsplib.ServerSessionStatePlay {
+	if s.playback != nil {
+		s.playback.close()
+		s.playback = nil
+	} else if s.rsession.State() == gortsplib.ServerSessionStatePlay {
 		s.onUnreadHook()
 	}
 
 	switch s.rsession.State() {
 	case gortsplib.ServerSessionStatePrePlay, gortsplib.ServerSessionStatePlay:
-		s.path.RemoveReader(defs.PathRemoveReaderReq{Author: s})
+		if s.path != nil {
+			s.path.RemoveReader(defs.PathRemoveReaderReq{Author: s})
+		}
 
 	case gortsplib.ServerSessionStateRecord:
 		s.path.RemovePublisher(defs.PathRemovePublisherReq{Author: s})
@@ -171,7 +179,7 @@ func (s *session) onAnnounce(c *conn, ctx *gortsplib.ServerHandlerOnAnnounceCtx)
 			Publish:          true,
 			Proto:            auth.ProtocolRTSP,
 			ID:               &c.uuid,
-			Credentials:      rtsp.Credentials(ctx.Request),
+			Credentials:      c.credentials(ctx.Request),
 			IP:               c.ip(),
 			CustomVerifyFunc: customVerifyFunc,
 		},
@@ -227,6 +235,10 @@ func (s *session) onSetup(c *conn, ctx *gortsplib.ServerHandlerOnSetupCtx,
 
 	switch s.rsession.State() {
 	case gortsplib.ServerSessionStateInitial, gortsplib.ServerSessionStatePrePlay: // play
+		if isPlaybackRequest(ctx.Query) {
+			return s.onSetupPlayback(c, ctx, customVerifyFunc)
+		}
+
 		path, stream, err := s.pathManager.AddReader(defs.PathAddReaderReq{
 			Author: s,
 			AccessRequest: defs.PathAccessRequest{
@@ -234,7 +246,7 @@ func (s *session) onSetup(c *conn, ctx *gortsplib.ServerHandlerOnSetupCtx,
 				Query:            ctx.Query,
 				Proto:            auth.ProtocolRTSP,
 				ID:               &c.uuid,
-				Credentials:      rtsp.Credentials(ctx.Request),
+				Credentials:      c.credentials(ctx.Request),
 				IP:               c.ip(),
 				CustomVerifyFunc: customVerifyFunc,
 			},
@@ -279,8 +291,49 @@ func (s *session) onSetup(c *conn, ctx *gortsplib.ServerHandlerOnSetupCtx,
 	}
 }
 
+func (s *session) onSetupPlayback(
+	c *conn,
+	ctx *gortsplib.ServerHandlerOnSetupCtx,
+	customVerifyFunc func(expectedUser, expectedPass string) bool,
+) (*base.Response, *gortsplib.ServerStream, error) {
+	if s.path != nil {
+		return &base.Response{
+			StatusCode: base.StatusBadRequest,
+		}, nil, fmt.Errorf("can't mix live streams and recordings in the same session")
+	}
+
+	pathConf, res, err := c.findPlaybackPathConf(ctx.Request, ctx.Path, ctx.Query, customVerifyFunc)
+	if err != nil {
+		return res, nil, err
+	}
+
+	if s.playback == nil {
+		var pb *sessionPlayback
+		pb, res, err = c.newPlayback(pathConf, ctx.Path, s)
+		if err != nil {
+			return res, nil, err
+		}
+		s.playback = pb
+	}
+
+	var rstream *gortsplib.ServerStream
+	if !s.isTLS {
+		rstream = s.playback.stream.RTSPStream(s.rserver)
+	} else {
+		rstream = s.playback.stream.RTSPSStream(s.rserver)
+	}
+
+	return &base.Response{
+		StatusCode: base.StatusOK,
+	}, rstream, nil
+}
+
 // onPlay is called by rtspServer.
-func (s *session) onPlay(_ *gortsplib.ServerHandlerOnPlayCtx) (*base.Response, error) {
+func (s *session) onPlay(ctx *gortsplib.ServerHandlerOnPlayCtx) (*base.Response, error) {
+	if s.playback != nil {
+		return s.onPlayPlayback(ctx)
+	}
+
 	h := make(base.Header)
 
 	if s.rsession.State() == gortsplib.ServerSessionStatePrePlay {
@@ -305,6 +358,70 @@ func (s *session) onPlay(_ *gortsplib.ServerHandlerOnPlayCtx) (*base.Response, e
 	}, nil
 }
 
+func (s *session) onPlayPlayback(ctx *gortsplib.ServerHandlerOnPlayCtx) (*base.Response, error) {
+	rng, err := parsePlaybackRange(ctx.Request)
+	if err != nil {
+		return &base.Response{
+			StatusCode: base.StatusInvalidRange,
+		}, err
+	}
+
+	scale, err := parsePlaybackScale(ctx.Request)
+	if err != nil {
+		return &base.Response{
+			StatusCode: base.StatusBadRequest,
+		}, err
+	}
+
+	var start *time.Time
+	var end *time.Time
+	if rng != nil {
+		start = &rng.Start
+		end = rng.End
+	}
+
+	from, err := s.playback.play(start, end, scale)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			return &base.Response{
+				StatusCode: base.StatusNotFound,
+			}, err
+		}
+
+		return &base.Response{
+			StatusCode: base.StatusBadRequest,
+		}, err
+	}
+
+	if s.rsession.State() == gortsplib.ServerSessionStatePrePlay {
+		s.Log(logger.Info, "is reading recordings of path '%s', with %s, %s",
+			s.playback.pathName,
+			s.rsession.Transport().Protocol,
+			defs.MediasInfo(s.rsession.Medias()))
+	}
+
+	s.Log(logger.Debug, "playing from %v, scale %v", from, scale)
+
+	return &base.Response{
+		StatusCode: base.StatusOK,
+		Header: base.Header{
+			"Range": headers.Range{
+				Value: &headers.RangeUTC{
+					Start: from.UTC(),
+					End: func() *time.Time {
+						if end == nil {
+							return nil
+						}
+						v := end.UTC()
+						return &v
+					}(),
+				},
+			}.Marshal(),
+			"Scale": base.HeaderValue{strconv.FormatFloat(scale, 'f', -1, 64)},
+		},
+	}, nil
+}
+
 // onRecord is called by rtspServer.
 func (s *session) onRecord(_ *gortsplib.ServerHandlerOnRecordCtx) (*base.Response, error) {
 	path, stream, err := s.pathManager.AddPublisher(defs.PathAddPublisherReq{
@@ -343,6 +460,14 @@ func (s *session) onRecord(_ *gortsplib.ServerHandlerOnRecordCtx) (*base.Respons
 
 // onPause is called by rtspServer.
 func (s *session) onPause(_ *gortsplib.ServerHandlerOnPauseCtx) (*base.Response, error) {
+	if s.playback != nil {
+		s.playback.stop()
+
+		return &base.Response{
+			StatusCode: base.StatusOK,
+		}, nil
+	}
+
 	switch s.rsession.State() {
 	case gortsplib.ServerSessionStatePlay:
 		s.onUnreadHook()
@@ -374,6 +499,16 @@ func (s *session) APISourceDescribe() defs.APIPathSourceOrReader {
 	return s.APIReaderDescribe()
 }
 
+// BytesReceived implements defs.BytesCounter.
+func (s *session) BytesReceived() uint64 {
+	return s.rsession.Stats().BytesReceived
+}
+
+// BytesSent implements defs.BytesCounter.
+func (s *session) BytesSent() uint64 {
+	return s.rsession.Stats().BytesSent
+}
+
 // onPacketLost is called by rtspServer.
 func (s *session) onPacketsLost(ctx *gortsplib.ServerHandlerOnPacketsLostCtx) {
 	s.packetsLost.Add(ctx.Lost)
@@ -444,5 +579,6 @@ func (s *session) apiItem() *defs.APIRTSPSession {
 		RTCPPacketsReceived: stats.RTCPPacketsReceived,
 		RTCPPacketsSent:     stats.RTCPPacketsSent,
 		RTCPPacketsInError:  stats.RTCPPacketsInError,
+		ClientCertIdentity:  clientCertIdentity(s.rconn.NetConn()),
 	}
 }
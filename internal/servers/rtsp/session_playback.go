@@ -0,0 +1,487 @@
+package rtsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v5/pkg/base"
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
+	"github.com/bluenviron/gortsplib/v5/pkg/headers"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/playback"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+const (
+	// duration of each read performed during forward playback.
+	playbackReadDuration = 24 * time.Hour
+
+	// duration of each read performed during reverse playback.
+	playbackReverseWindow = 10 * time.Second
+
+	// period between checks for new data when the end of recordings is reached.
+	playbackPollPeriod = 1 * time.Second
+
+	// gap between presentation timestamps of consecutive plays.
+	playbackRestartGap = 100 * time.Millisecond
+)
+
+var errPlaybackEnd = errors.New("end of playback")
+
+func isPlaybackRequest(query string) bool {
+	v, err := url.ParseQuery(query)
+	if err != nil {
+		return false
+	}
+	_, ok := v["playback"]
+	return ok
+}
+
+func multiplyAndDivide(v, m, d int64) int64 {
+	secs := v / d
+	dec := v % d
+	return (secs*m + dec*m/d)
+}
+
+// parsePlaybackRange parses the Range header of a PLAY request.
+// Only absolute ranges are supported; a NPT range starting from zero is treated as no range.
+func parsePlaybackRange(req *base.Request) (*headers.RangeUTC, error) {
+	v, ok := req.Header["Range"]
+	if !ok {
+		return nil, nil
+	}
+
+	var h headers.Range
+	err := h.Unmarshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	switch rv := h.Value.(type) {
+	case *headers.RangeUTC:
+		return rv, nil
+
+	case *headers.RangeNPT:
+		if rv.Start == 0 {
+			return nil, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported range: %v", v[0])
+}
+
+func parsePlaybackScale(req *base.Request) (float64, error) {
+	v, ok := req.Header["Scale"]
+	if !ok || len(v) != 1 {
+		return 1, nil
+	}
+
+	scale, err := strconv.ParseFloat(v[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid scale: %w", err)
+	}
+
+	if scale == 0 {
+		return 0, fmt.Errorf("invalid scale: %v", v[0])
+	}
+
+	return scale, nil
+}
+
+type sessionPlaybackTrack struct {
+	*playback.StreamTrack
+	codecType reflect.Type
+
+	enabled bool
+	lastNTP time.Time
+}
+
+// sessionPlaybackPacer sends samples at the pace set by the scale.
+type sessionPlaybackPacer struct {
+	scale       float64
+	originWall  time.Time
+	originClock time.Duration
+}
+
+// wait waits until a sample, placed at the given media distance from the first one, has to be sent,
+// and returns its presentation time.
+func (pc *sessionPlaybackPacer) wait(ctx context.Context, elapsed time.Duration) (time.Duration, error) {
+	scaled := time.Duration(float64(elapsed) / pc.scale)
+
+	if d := time.Until(pc.originWall.Add(scaled)); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+
+	return pc.originClock + scaled, nil
+}
+
+// sessionPlayback serves the recordings of a path to a RTSP session.
+type sessionPlayback struct {
+	pathConf          *conf.Path
+	pathName          string
+	writeQueueSize    int
+	rtpMaxPayloadSize int
+	parent            logger.Writer
+
+	reader *playback.Reader
+	tracks map[int]*sessionPlaybackTrack
+	stream *stream.Stream
+
+	position  time.Time
+	clock     time.Duration
+	ctxCancel func()
+	done      chan struct{}
+}
+
+func (p *sessionPlayback) initialize() error {
+	p.reader = &playback.Reader{
+		PathConf: p.pathConf,
+		PathName: p.pathName,
+		OnTracks: p.onTracks,
+	}
+
+	// use tracks of the most recent recording
+	tracks, err := p.reader.Tracks(context.Background(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	p.tracks = make(map[int]*sessionPlaybackTrack)
+	var medias []*description.Media //nolint:prealloc
+
+	for _, track := range tracks {
+		st := playback.NewStreamTrack(track)
+		if st == nil {
+			continue
+		}
+
+		t := &sessionPlaybackTrack{
+			StreamTrack: st,
+			codecType:   reflect.TypeOf(track.Codec),
+		}
+
+		p.tracks[track.ID] = t
+		medias = append(medias, t.Media)
+	}
+
+	if len(medias) == 0 {
+		return fmt.Errorf("recordings do not contain any track that can be sent with RTSP")
+	}
+
+	p.stream = &stream.Stream{
+		WriteQueueSize:     p.writeQueueSize,
+		RTPMaxPayloadSize:  p.rtpMaxPayloadSize,
+		Desc:               &description.Session{Medias: medias},
+		GenerateRTPPackets: true,
+		FillNTP:            false,
+		Parent:             p.parent,
+	}
+	return p.stream.Initialize()
+}
+
+func (p *sessionPlayback) close() {
+	p.stop()
+	p.stream.Close()
+}
+
+func (p *sessionPlayback) hasVideo() bool {
+	for _, t := range p.tracks {
+		if t.Media.Type == description.MediaTypeVideo {
+			return true
+		}
+	}
+	return false
+}
+
+// play starts playback from the given date, or resumes it when start is nil.
+// It returns the date from which playback starts.
+func (p *sessionPlayback) play(start *time.Time, end *time.Time, scale float64) (time.Time, error) {
+	if scale < 0 && !p.hasVideo() {
+		return time.Time{}, fmt.Errorf("reverse playback requires a video track")
+	}
+
+	p.stop()
+
+	var from time.Time
+
+	switch {
+	case start != nil:
+		from = *start
+
+		// samples that have already been sent can be sent again
+		for _, t := range p.tracks {
+			t.lastNTP = time.Time{}
+		}
+
+	case !p.position.IsZero():
+		from = p.position
+
+	case scale > 0:
+		var err error
+		from, err = p.reader.NextStart(time.Time{})
+		if err != nil {
+			return time.Time{}, err
+		}
+
+	default:
+		from = time.Now()
+	}
+
+	pacer := &sessionPlaybackPacer{
+		scale:       scale,
+		originWall:  time.Now(),
+		originClock: p.clock,
+	}
+	if !p.position.IsZero() {
+		pacer.originClock += playbackRestartGap
+	}
+
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	p.ctxCancel = ctxCancel
+	p.done = make(chan struct{})
+
+	go p.run(ctx, pacer, from, end)
+
+	return from, nil
+}
+
+// stop stops playback, preserving its position.
+func (p *sessionPlayback) stop() {
+	if p.ctxCancel != nil {
+		p.ctxCancel()
+		<-p.done
+		p.ctxCancel = nil
+	}
+}
+
+func (p *sessionPlayback) run(ctx context.Context, pacer *sessionPlaybackPacer, from time.Time, end *time.Time) {
+	defer close(p.done)
+
+	var err error
+	if pacer.scale > 0 {
+		err = p.runForward(ctx, pacer, from, end)
+	} else {
+		pacer.scale = -pacer.scale
+		err = p.runReverse(ctx, pacer, from, end)
+	}
+
+	if err != nil && ctx.Err() == nil && !errors.Is(err, errPlaybackEnd) {
+		p.parent.Log(logger.Error, "playback error: %v", err)
+	}
+}
+
+func (p *sessionPlayback) onTracks(tracks []*fmp4.InitTrack) {
+	for _, t := range p.tracks {
+		t.enabled = false
+	}
+
+	// tracks of older recordings may differ
+	for _, track := range tracks {
+		if t, ok := p.tracks[track.ID]; ok && t.codecType == reflect.TypeOf(track.Codec) {
+			t.enabled = true
+		}
+	}
+}
+
+func (p *sessionPlayback) writeSample(
+	t *sessionPlaybackTrack,
+	sample *playback.Sample,
+	pts time.Duration,
+) {
+	pl, err := t.ToPayload(sample.Payload)
+	if err != nil {
+		p.parent.Log(logger.Warn, "unable to decode sample: %v", err)
+		return
+	}
+
+	p.stream.WriteUnit(t.Media, t.Format, &unit.Unit{
+		PTS:     multiplyAndDivide(int64(pts), int64(t.Format.ClockRate()), int64(time.Second)),
+		NTP:     sample.NTP.Add(sample.PTSOffset),
+		Payload: pl,
+	})
+}
+
+func (p *sessionPlayback) runForward(
+	ctx context.Context,
+	pacer *sessionPlaybackPacer,
+	from time.Time,
+	end *time.Time,
+) error {
+	var originNTP time.Time
+
+	sorter := &playback.SampleSorter{
+		Window: 2 * time.Duration(p.pathConf.RecordPartDuration),
+		OnSample: func(sample *playback.Sample) error {
+			if end != nil && !sample.NTP.Before(*end) {
+				return errPlaybackEnd
+			}
+
+			if originNTP.IsZero() {
+				originNTP = sample.NTP
+			}
+
+			pres, err := pacer.wait(ctx, sample.NTP.Sub(originNTP))
+			if err != nil {
+				return err
+			}
+
+			t := p.tracks[sample.TrackID]
+			p.writeSample(t, sample, pres+time.Duration(float64(sample.PTSOffset)/pacer.scale))
+
+			t.lastNTP = sample.NTP
+			if sample.NTP.After(p.position) {
+				p.position = sample.NTP
+			}
+			if pres > p.clock {
+				p.clock = pres
+			}
+
+			return nil
+		},
+	}
+
+	p.reader.OnSample = func(sample *playback.Sample) error {
+		t, ok := p.tracks[sample.TrackID]
+		if !ok || !t.enabled || !sample.NTP.After(t.lastNTP) {
+			return nil
+		}
+
+		return sorter.Push(sample)
+	}
+
+	for {
+		prevPosition := p.position
+
+		err := p.reader.Read(ctx, from, playbackReadDuration)
+		if err != nil && !errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			return err
+		}
+
+		err = sorter.Flush()
+		if err != nil {
+			return err
+		}
+
+		// reading stopped before the end of the recording
+		if p.position.After(prevPosition) {
+			from = p.position
+			continue
+		}
+
+		// move to the next recording
+		next, err := p.reader.NextStart(from)
+		if err == nil {
+			from = next
+			continue
+		}
+
+		// wait for new data
+		select {
+		case <-time.After(playbackPollPeriod):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// runReverse sends keyframes of video tracks in reverse order.
+func (p *sessionPlayback) runReverse(
+	ctx context.Context,
+	pacer *sessionPlaybackPacer,
+	from time.Time,
+	end *time.Time,
+) error {
+	var originNTP time.Time
+	windowEnd := from
+
+	for {
+		windowStart := windowEnd.Add(-playbackReverseWindow)
+		if end != nil && windowStart.Before(*end) {
+			windowStart = *end
+		}
+
+		var keyframes []*playback.Sample
+
+		p.reader.OnSample = func(sample *playback.Sample) error {
+			t, ok := p.tracks[sample.TrackID]
+			if !ok || !t.enabled || t.Media.Type != description.MediaTypeVideo || sample.IsNonSyncSample ||
+				sample.NTP.Before(windowStart) || !sample.NTP.Before(windowEnd) {
+				return nil
+			}
+
+			keyframes = append(keyframes, sample)
+			return nil
+		}
+
+		err := p.reader.Read(ctx, windowStart, windowEnd.Sub(windowStart))
+		if err != nil && !errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			return err
+		}
+
+		sort.Slice(keyframes, func(i, j int) bool {
+			return keyframes[i].NTP.Before(keyframes[j].NTP)
+		})
+
+		for i := len(keyframes) - 1; i >= 0; i-- {
+			sample := keyframes[i]
+
+			if originNTP.IsZero() {
+				originNTP = sample.NTP
+			}
+
+			pres, err2 := pacer.wait(ctx, originNTP.Sub(sample.NTP))
+			if err2 != nil {
+				return err2
+			}
+
+			p.writeSample(p.tracks[sample.TrackID], sample, pres)
+
+			// when playback is resumed forward, start after this keyframe
+			for _, t := range p.tracks {
+				t.lastNTP = sample.NTP
+			}
+			p.position = sample.NTP
+			p.clock = pres
+		}
+
+		if end != nil && !windowStart.After(*end) {
+			return errPlaybackEnd
+		}
+
+		// skip gaps between recordings
+		if len(keyframes) == 0 {
+			prevEnd, err2 := p.reader.PrevEnd(ctx, windowStart)
+			if err2 != nil {
+				if errors.Is(err2, recordstore.ErrNoSegmentsFound) {
+					return errPlaybackEnd
+				}
+				return err2
+			}
+
+			if prevEnd.Before(windowStart) {
+				windowEnd = prevEnd
+				continue
+			}
+		}
+
+		windowEnd = windowStart
+	}
+}
@@ -48,6 +48,7 @@ type conn struct {
 	writeTimeout        conf.Duration
 	udpMaxPayloadSize   int
 	connReq             srt.ConnRequest
+	remoteAddr          net.Addr
 	runOnConnect        string
 	runOnConnectRestart bool
 	runOnDisconnect     string
@@ -86,11 +87,11 @@ func (c *conn) Close() {
 
 // Log implements logger.Writer.
 func (c *conn) Log(level logger.Level, format string, args ...interface{}) {
-	c.parent.Log(level, "[conn %v] "+format, append([]interface{}{c.connReq.RemoteAddr()}, args...)...)
+	c.parent.Log(level, "[conn %v] "+format, append([]interface{}{c.remoteAddr}, args...)...)
 }
 
 func (c *conn) ip() net.IP {
-	return c.connReq.RemoteAddr().(*net.UDPAddr).IP
+	return c.remoteAddr.(*net.UDPAddr).IP
 }
 
 func (c *conn) run() { //nolint:dupl
@@ -215,7 +216,7 @@ func (c *conn) runPublishReader(sconn srt.Conn, streamID *streamID, pathConf *co
 
 	var stream *stream.Stream
 
-	medias, err := mpegts.ToStream(r, &stream, c)
+	medias, mediaProps, err := mpegts.ToStream(r, &stream, c)
 	if err != nil {
 		return err
 	}
@@ -224,6 +225,7 @@ func (c *conn) runPublishReader(sconn srt.Conn, streamID *streamID, pathConf *co
 	path, stream, err = c.pathManager.AddPublisher(defs.PathAddPublisherReq{
 		Author:             c,
 		Desc:               &description.Session{Medias: medias},
+		MediaProperties:    mediaProps,
 		GenerateRTPPackets: true,
 		FillNTP:            true,
 		ConfToCompare:      pathConf,
@@ -353,6 +355,29 @@ func (c *conn) APISourceDescribe() defs.APIPathSourceOrReader {
 	return c.APIReaderDescribe()
 }
 
+func (c *conn) stats() srt.Statistics {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	var s srt.Statistics
+	if c.sconn != nil {
+		c.sconn.Stats(&s)
+	}
+	return s
+}
+
+// BytesReceived implements defs.BytesCounter.
+func (c *conn) BytesReceived() uint64 {
+	s := c.stats()
+	return s.Accumulated.ByteRecv
+}
+
+// BytesSent implements defs.BytesCounter.
+func (c *conn) BytesSent() uint64 {
+	s := c.stats()
+	return s.Accumulated.ByteSent
+}
+
 func (c *conn) apiItem() *defs.APISRTConn {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
@@ -360,7 +385,7 @@ func (c *conn) apiItem() *defs.APISRTConn {
 	item := &defs.APISRTConn{
 		ID:         c.uuid,
 		Created:    c.created,
-		RemoteAddr: c.connReq.RemoteAddr().String(),
+		RemoteAddr: c.remoteAddr.String(),
 		State:      c.state,
 		Path:       c.pathName,
 		Query:      c.query,
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"reflect"
 	"sort"
 	"sync"
@@ -17,6 +18,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/defs"
 	"github.com/bluenviron/mediamtx/internal/externalcmd"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/protocols/proxyprotocol"
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
 
@@ -75,23 +77,25 @@ type serverParent interface {
 
 // Server is a SRT server.
 type Server struct {
-	Address             string
-	RTSPAddress         string
-	ReadTimeout         conf.Duration
-	WriteTimeout        conf.Duration
-	UDPMaxPayloadSize   int
-	RunOnConnect        string
-	RunOnConnectRestart bool
-	RunOnDisconnect     string
-	ExternalCmdPool     *externalcmd.Pool
-	Metrics             serverMetrics
-	PathManager         serverPathManager
-	Parent              serverParent
+	Address              string
+	RTSPAddress          string
+	ReadTimeout          conf.Duration
+	WriteTimeout         conf.Duration
+	UDPMaxPayloadSize    int
+	ProxyProtocolSources conf.IPNetworks
+	RunOnConnect         string
+	RunOnConnectRestart  bool
+	RunOnDisconnect      string
+	ExternalCmdPool      *externalcmd.Pool
+	Metrics              serverMetrics
+	PathManager          serverPathManager
+	Parent               serverParent
 
 	ctx       context.Context
 	ctxCancel func()
 	wg        sync.WaitGroup
 	ln        srt.Listener
+	relay     *proxyprotocol.UDPRelay
 	conns     map[*conn]struct{}
 
 	// in
@@ -109,12 +113,34 @@ func (s *Server) Initialize() error {
 	conf.ConnectionTimeout = time.Duration(s.ReadTimeout)
 	conf.PayloadSize = uint32(srtMaxPayloadSize(s.UDPMaxPayloadSize))
 
+	address := s.Address
+
+	// the SRT library opens its own socket, therefore PROXY protocol headers
+	// are read by a relay that forwards datagrams to a local listener.
+	if len(s.ProxyProtocolSources) != 0 {
+		address = "127.0.0.1:0"
+	}
+
 	var err error
-	s.ln, err = srt.Listen("srt", s.Address, conf)
+	s.ln, err = srt.Listen("srt", address, conf)
 	if err != nil {
 		return err
 	}
 
+	if len(s.ProxyProtocolSources) != 0 {
+		s.relay = &proxyprotocol.UDPRelay{
+			Address:     s.Address,
+			Target:      s.ln.Addr().(*net.UDPAddr),
+			Sources:     s.ProxyProtocolSources,
+			ReadTimeout: time.Duration(s.ReadTimeout),
+		}
+		err = s.relay.Initialize()
+		if err != nil {
+			s.ln.Close()
+			return err
+		}
+	}
+
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
 
 	s.conns = make(map[*conn]struct{})
@@ -179,6 +205,7 @@ outer:
 				writeTimeout:        s.WriteTimeout,
 				udpMaxPayloadSize:   s.UDPMaxPayloadSize,
 				connReq:             req,
+				remoteAddr:          s.remoteAddr(req.RemoteAddr()),
 				runOnConnect:        s.RunOnConnect,
 				runOnConnectRestart: s.RunOnConnectRestart,
 				runOnDisconnect:     s.RunOnDisconnect,
@@ -235,9 +262,22 @@ outer:
 
 	s.ctxCancel()
 
+	if s.relay != nil {
+		s.relay.Close()
+	}
+
 	s.ln.Close()
 }
 
+// remoteAddr returns the address of the client behind a remote address
+// of the listener.
+func (s *Server) remoteAddr(addr net.Addr) net.Addr {
+	if s.relay != nil {
+		return s.relay.ClientAddr(addr)
+	}
+	return addr
+}
+
 func (s *Server) findConnByUUID(uuid uuid.UUID) *conn {
 	for sx := range s.conns {
 		if sx.uuid == uuid {
@@ -72,16 +72,18 @@ func sessionLocation(publish bool, path string, rawQuery string, secret uuid.UUI
 }
 
 type httpServer struct {
-	address        string
-	encryption     bool
-	serverKey      string
-	serverCert     string
-	allowOrigin    string
-	trustedProxies conf.IPNetworks
-	readTimeout    conf.Duration
-	writeTimeout   conf.Duration
-	pathManager    serverPathManager
-	parent         *Server
+	address              string
+	encryption           bool
+	serverKey            string
+	serverCert           string
+	clientCA             string
+	allowOrigin          string
+	trustedProxies       conf.IPNetworks
+	proxyProtocolSources conf.IPNetworks
+	readTimeout          conf.Duration
+	writeTimeout         conf.Duration
+	pathManager          serverPathManager
+	parent               *Server
 
 	inner *httpp.Server
 }
@@ -95,14 +97,16 @@ func (s *httpServer) initialize() error {
 	router.Use(s.onRequest)
 
 	s.inner = &httpp.Server{
-		Address:      s.address,
-		ReadTimeout:  time.Duration(s.readTimeout),
-		WriteTimeout: time.Duration(s.writeTimeout),
-		Encryption:   s.encryption,
-		ServerCert:   s.serverCert,
-		ServerKey:    s.serverKey,
-		Handler:      router,
-		Parent:       s,
+		Address:              s.address,
+		ReadTimeout:          time.Duration(s.readTimeout),
+		WriteTimeout:         time.Duration(s.writeTimeout),
+		Encryption:           s.encryption,
+		ServerCert:           s.serverCert,
+		ServerKey:            s.serverKey,
+		ClientCA:             s.clientCA,
+		ProxyProtocolSources: s.proxyProtocolSources,
+		Handler:              router,
+		Parent:               s,
 	}
 	err := s.inner.Initialize()
 	if err != nil {
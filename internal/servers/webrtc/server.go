@@ -189,8 +189,10 @@ type Server struct {
 	Encryption            bool
 	ServerKey             string
 	ServerCert            string
+	ClientCA              string
 	AllowOrigin           string
 	TrustedProxies        conf.IPNetworks
+	ProxyProtocolSources  conf.IPNetworks
 	ReadTimeout           conf.Duration
 	WriteTimeout          conf.Duration
 	LocalUDPAddress       string
@@ -248,16 +250,18 @@ func (s *Server) Initialize() error {
 	s.done = make(chan struct{})
 
 	s.httpServer = &httpServer{
-		address:        s.Address,
-		encryption:     s.Encryption,
-		serverKey:      s.ServerKey,
-		serverCert:     s.ServerCert,
-		allowOrigin:    s.AllowOrigin,
-		trustedProxies: s.TrustedProxies,
-		readTimeout:    s.ReadTimeout,
-		writeTimeout:   s.WriteTimeout,
-		pathManager:    s.PathManager,
-		parent:         s,
+		address:              s.Address,
+		encryption:           s.Encryption,
+		serverKey:            s.ServerKey,
+		serverCert:           s.ServerCert,
+		clientCA:             s.ClientCA,
+		allowOrigin:          s.AllowOrigin,
+		trustedProxies:       s.TrustedProxies,
+		proxyProtocolSources: s.ProxyProtocolSources,
+		readTimeout:          s.ReadTimeout,
+		writeTimeout:         s.WriteTimeout,
+		pathManager:          s.PathManager,
+		parent:               s,
 	}
 	err := s.httpServer.initialize()
 	if err != nil {
@@ -452,6 +452,28 @@ func (s *session) APISourceDescribe() defs.APIPathSourceOrReader {
 	return s.APIReaderDescribe()
 }
 
+// BytesReceived implements defs.BytesCounter.
+func (s *session) BytesReceived() uint64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.pc == nil {
+		return 0
+	}
+	return s.pc.Stats().BytesReceived
+}
+
+// BytesSent implements defs.BytesCounter.
+func (s *session) BytesSent() uint64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.pc == nil {
+		return 0
+	}
+	return s.pc.Stats().BytesSent
+}
+
 func (s *session) apiItem() *defs.APIWebRTCSession {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
@@ -506,5 +528,12 @@ func (s *session) apiItem() *defs.APIWebRTCSession {
 		RTPPacketsJitter:    rtpPacketsJitter,
 		RTCPPacketsReceived: rtcpPacketsReceived,
 		RTCPPacketsSent:     rtcpPacketsSent,
+		ClientCertIdentity: func() *string {
+			ids := httpp.Credentials(s.req.httpRequest).ClientCertIdentities
+			if len(ids) == 0 {
+				return nil
+			}
+			return &ids[0]
+		}(),
 	}
 }
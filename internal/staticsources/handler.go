@@ -13,6 +13,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/logger"
 	sshls "github.com/bluenviron/mediamtx/internal/staticsources/hls"
 	ssmpegts "github.com/bluenviron/mediamtx/internal/staticsources/mpegts"
+	ssrecord "github.com/bluenviron/mediamtx/internal/staticsources/record"
 	ssrpicamera "github.com/bluenviron/mediamtx/internal/staticsources/rpicamera"
 	ssrtmp "github.com/bluenviron/mediamtx/internal/staticsources/rtmp"
 	ssrtp "github.com/bluenviron/mediamtx/internal/staticsources/rtp"
@@ -52,6 +53,7 @@ type staticSource interface {
 
 type handlerPathManager interface {
 	AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error)
+	FindPathConf(req defs.PathFindPathConfReq) (*conf.Path, error)
 }
 
 type handlerParent interface {
@@ -150,6 +152,11 @@ func (s *Handler) Initialize() {
 			Parent:      s,
 		}
 
+	case strings.HasPrefix(s.Conf.Source, "record://"):
+		s.instance = &ssrecord.Source{
+			Parent: s,
+		}
+
 	case s.Conf.Source == "rpiCamera":
 		s.instance = &ssrpicamera.Source{
 			RTPMaxPayloadSize: s.RTPMaxPayloadSize,
@@ -331,3 +338,8 @@ func (s *Handler) SetNotReady(req defs.PathSourceStaticSetNotReadyReq) {
 func (s *Handler) AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error) {
 	return s.PathManager.AddReader(req)
 }
+
+// FindPathConf is called by a staticSource.
+func (s *Handler) FindPathConf(req defs.PathFindPathConfReq) (*conf.Path, error) {
+	return s.PathManager.FindPathConf(req)
+}
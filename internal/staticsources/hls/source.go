@@ -66,8 +66,14 @@ func (s *Source) Run(params defs.StaticSourceRunParams) error {
 		return err
 	}
 
+	tlsConfig, err := tls.MakeClientConfig(u.Hostname(), params.Conf.SourceFingerprint,
+		params.Conf.SourceCA, params.Conf.SourceClientCert, params.Conf.SourceClientKey)
+	if err != nil {
+		return err
+	}
+
 	tr := &http.Transport{
-		TLSClientConfig: tls.MakeConfig(u.Hostname(), params.Conf.SourceFingerprint),
+		TLSClientConfig: tlsConfig,
 	}
 	defer tr.CloseIdleConnections()
 
@@ -112,13 +112,14 @@ func (s *Source) runReader(nc net.Conn) error {
 
 	var stream *stream.Stream
 
-	medias, err := mpegts.ToStream(mr, &stream, s)
+	medias, mediaProps, err := mpegts.ToStream(mr, &stream, s)
 	if err != nil {
 		return err
 	}
 
 	res := s.Parent.SetReady(defs.PathSourceStaticSetReadyReq{
 		Desc:               &description.Session{Medias: medias},
+		MediaProperties:    mediaProps,
 		GenerateRTPPackets: true,
 		FillNTP:            true,
 	})
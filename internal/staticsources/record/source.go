@@ -0,0 +1,467 @@
+// Package record contains the record static source.
+package record
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/playback"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+const (
+	// duration of each read.
+	readDuration = 24 * time.Hour
+
+	// gap between timestamps of samples that belong to different recordings or loops.
+	restartGap = 100 * time.Millisecond
+)
+
+var errReplayEnd = errors.New("end of recordings reached")
+
+func multiplyAndDivide(v, m, d int64) int64 {
+	secs := v / d
+	dec := v % d
+	return (secs*m + dec*m/d)
+}
+
+type sourceURL struct {
+	pathName string
+	start    time.Time
+	duration time.Duration
+	speed    float64
+	loop     bool
+}
+
+func parseURL(raw string) (*sourceURL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	su := &sourceURL{
+		pathName: strings.TrimSuffix(u.Host+u.Path, "/"),
+		speed:    1,
+	}
+
+	if su.pathName == "" {
+		return nil, fmt.Errorf("path name is missing")
+	}
+
+	q := u.Query()
+
+	if v := q.Get("start"); v != "" {
+		// a '+' in the timezone offset is decoded into a space
+		su.start, err = time.Parse(time.RFC3339, strings.ReplaceAll(v, " ", "+"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid start: %w", err)
+		}
+	}
+
+	if v := q.Get("duration"); v != "" {
+		var secs float64
+		secs, err = strconv.ParseFloat(v, 64)
+		if err != nil || secs <= 0 {
+			return nil, fmt.Errorf("invalid duration: %v", v)
+		}
+		su.duration = time.Duration(secs * float64(time.Second))
+	}
+
+	if v := q.Get("speed"); v != "" {
+		su.speed, err = strconv.ParseFloat(v, 64)
+		if err != nil || su.speed <= 0 {
+			return nil, fmt.Errorf("invalid speed: %v", v)
+		}
+	}
+
+	if v := q.Get("loop"); v != "" {
+		su.loop, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid loop: %v", v)
+		}
+	}
+
+	return su, nil
+}
+
+type sourceTrack struct {
+	*playback.StreamTrack
+	codecType reflect.Type
+
+	enabled bool
+	lastNTP time.Time
+}
+
+type parent interface {
+	logger.Writer
+	SetReady(req defs.PathSourceStaticSetReadyReq) defs.PathSourceStaticSetReadyRes
+	SetNotReady(req defs.PathSourceStaticSetNotReadyReq)
+	FindPathConf(req defs.PathFindPathConfReq) (*conf.Path, error)
+}
+
+// Source is a static source that replays the recordings of another path.
+type Source struct {
+	Parent parent
+}
+
+// Log implements logger.Writer.
+func (s *Source) Log(level logger.Level, format string, args ...interface{}) {
+	s.Parent.Log(level, "[record source] "+format, args...)
+}
+
+// Run implements StaticSource.
+func (s *Source) Run(params defs.StaticSourceRunParams) error {
+	u, err := parseURL(params.ResolvedSource)
+	if err != nil {
+		return err
+	}
+
+	if u.pathName == params.Conf.Name {
+		return fmt.Errorf("path can't replay its own recordings")
+	}
+
+	recordConf, err := s.Parent.FindPathConf(defs.PathFindPathConfReq{
+		AccessRequest: defs.PathAccessRequest{
+			Name:     u.pathName,
+			SkipAuth: true,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, ctxCancel := context.WithCancel(context.Background())
+
+	readDone := make(chan error)
+	go func() {
+		readDone <- s.runReader(ctx, u, recordConf, params.Conf)
+	}()
+
+	for {
+		select {
+		case err = <-readDone:
+			ctxCancel()
+
+			if !errors.Is(err, errReplayEnd) {
+				return err
+			}
+
+			s.Log(logger.Info, err.Error())
+
+			for {
+				select {
+				case <-params.ReloadConf:
+
+				case <-params.Context.Done():
+					return nil
+				}
+			}
+
+		case <-params.ReloadConf:
+
+		case <-params.Context.Done():
+			ctxCancel()
+			<-readDone
+			return nil
+		}
+	}
+}
+
+func (s *Source) runReader(
+	ctx context.Context,
+	u *sourceURL,
+	recordConf *conf.Path,
+	pathConf *conf.Path,
+) error {
+	r := &replayer{
+		url:                  u,
+		recordConf:           recordConf,
+		useAbsoluteTimestamp: pathConf.UseAbsoluteTimestamp,
+		parent:               s,
+	}
+	err := r.initialize(ctx)
+	if err != nil {
+		return err
+	}
+
+	medias := make([]*description.Media, 0, len(r.tracks))
+	for _, t := range r.sortedTracks() {
+		medias = append(medias, t.Media)
+	}
+
+	res := s.Parent.SetReady(defs.PathSourceStaticSetReadyReq{
+		Desc:               &description.Session{Medias: medias},
+		GenerateRTPPackets: true,
+		FillNTP:            !pathConf.UseAbsoluteTimestamp,
+	})
+	if res.Err != nil {
+		return res.Err
+	}
+
+	defer s.Parent.SetNotReady(defs.PathSourceStaticSetNotReadyReq{})
+
+	r.stream = res.Stream
+
+	return r.run(ctx)
+}
+
+// APISourceDescribe implements StaticSource.
+func (*Source) APISourceDescribe() defs.APIPathSourceOrReader {
+	return defs.APIPathSourceOrReader{
+		Type: "recordSource",
+		ID:   "",
+	}
+}
+
+// replayer reads recordings and writes them to a stream in real time.
+type replayer struct {
+	url                  *sourceURL
+	recordConf           *conf.Path
+	useAbsoluteTimestamp bool
+	parent               logger.Writer
+
+	reader   *playback.Reader
+	tracks   map[int]*sourceTrack
+	start    time.Time
+	stream   *stream.Stream
+	position time.Time
+
+	// pacing
+	originWall time.Time
+	originNTP  time.Time
+	originPTS  time.Duration
+	lastPTS    time.Duration
+	sent       bool
+}
+
+func (r *replayer) initialize(ctx context.Context) error {
+	r.reader = &playback.Reader{
+		PathConf: r.recordConf,
+		PathName: r.url.pathName,
+		OnTracks: r.onTracks,
+	}
+
+	r.start = r.url.start
+	if r.start.IsZero() {
+		var err error
+		r.start, err = r.reader.NextStart(time.Time{})
+		if err != nil {
+			return err
+		}
+	}
+
+	tracks, err := r.reader.Tracks(ctx, r.start)
+	if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+		// start is placed between two recordings
+		r.start, err = r.reader.NextStart(r.start)
+		if err != nil {
+			return err
+		}
+		tracks, err = r.reader.Tracks(ctx, r.start)
+	}
+	if err != nil {
+		return err
+	}
+
+	r.tracks = make(map[int]*sourceTrack)
+
+	for _, track := range tracks {
+		st := playback.NewStreamTrack(track)
+		if st == nil {
+			continue
+		}
+
+		r.tracks[track.ID] = &sourceTrack{
+			StreamTrack: st,
+			codecType:   reflect.TypeOf(track.Codec),
+		}
+	}
+
+	if len(r.tracks) == 0 {
+		return fmt.Errorf("recordings do not contain any supported track")
+	}
+
+	return nil
+}
+
+func (r *replayer) sortedTracks() []*sourceTrack {
+	ids := make([]int, 0, len(r.tracks))
+	for id := range r.tracks {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	ret := make([]*sourceTrack, len(ids))
+	for i, id := range ids {
+		ret[i] = r.tracks[id]
+	}
+	return ret
+}
+
+func (r *replayer) onTracks(tracks []*fmp4.InitTrack) {
+	for _, t := range r.tracks {
+		t.enabled = false
+	}
+
+	// tracks of other recordings may differ
+	for _, track := range tracks {
+		if t, ok := r.tracks[track.ID]; ok && t.codecType == reflect.TypeOf(track.Codec) {
+			t.enabled = true
+		}
+	}
+}
+
+// rebase causes the next sample to be sent immediately,
+// with timestamps that follow the ones of previous samples.
+func (r *replayer) rebase() {
+	r.originNTP = time.Time{}
+}
+
+func (r *replayer) run(ctx context.Context) error {
+	var end time.Time
+	if r.url.duration != 0 {
+		end = r.start.Add(r.url.duration)
+	}
+
+	sorter := &playback.SampleSorter{
+		Window: 2 * time.Duration(r.recordConf.RecordPartDuration),
+		OnSample: func(sample *playback.Sample) error {
+			if !end.IsZero() && !sample.NTP.Before(end) {
+				return errReplayEnd
+			}
+			return r.writeSample(ctx, sample)
+		},
+	}
+
+	r.reader.OnSample = func(sample *playback.Sample) error {
+		t, ok := r.tracks[sample.TrackID]
+		if !ok || !t.enabled || !sample.NTP.After(t.lastNTP) {
+			return nil
+		}
+
+		return sorter.Push(sample)
+	}
+
+	from := r.start
+	passPosition := r.position
+
+	for {
+		prevPosition := r.position
+
+		err := r.reader.Read(ctx, from, readDuration)
+		if err == nil || errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			err = sorter.Flush()
+		}
+
+		if err == nil {
+			// reading stopped before the end of the recording
+			if r.position.After(prevPosition) {
+				from = r.position
+				continue
+			}
+
+			// move to the next recording
+			var next time.Time
+			next, err = r.reader.NextStart(from)
+			if err == nil {
+				if !end.IsZero() && !next.Before(end) {
+					err = errReplayEnd
+				} else {
+					from = next
+					r.rebase()
+					continue
+				}
+			} else if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+				err = errReplayEnd
+			}
+		}
+
+		// stop when nothing has been sent since the last restart, in order to avoid looping forever
+		if !errors.Is(err, errReplayEnd) || !r.url.loop || !r.position.After(passPosition) {
+			return err
+		}
+
+		// restart from the beginning
+		for _, t := range r.tracks {
+			t.lastNTP = time.Time{}
+		}
+		r.position = time.Time{}
+		passPosition = r.position
+		from = r.start
+		r.rebase()
+	}
+}
+
+func (r *replayer) writeSample(ctx context.Context, sample *playback.Sample) error {
+	if r.originNTP.IsZero() {
+		r.originNTP = sample.NTP
+		r.originWall = time.Now()
+		r.originPTS = r.lastPTS
+		if r.sent {
+			r.originPTS += restartGap
+		}
+	}
+
+	elapsed := time.Duration(float64(sample.NTP.Sub(r.originNTP)) / r.url.speed)
+
+	if d := time.Until(r.originWall.Add(elapsed)); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	t := r.tracks[sample.TrackID]
+	t.lastNTP = sample.NTP
+	if sample.NTP.After(r.position) {
+		r.position = sample.NTP
+	}
+
+	pts := r.originPTS + elapsed
+	if pts > r.lastPTS {
+		r.lastPTS = pts
+	}
+	r.sent = true
+
+	pl, err := t.ToPayload(sample.Payload)
+	if err != nil {
+		r.parent.Log(logger.Warn, "unable to decode sample: %v", err)
+		return nil
+	}
+
+	u := &unit.Unit{
+		PTS: multiplyAndDivide(
+			int64(pts+time.Duration(float64(sample.PTSOffset)/r.url.speed)),
+			int64(t.Format.ClockRate()),
+			int64(time.Second)),
+		Payload: pl,
+	}
+
+	if r.useAbsoluteTimestamp {
+		u.NTP = sample.NTP.Add(sample.PTSOffset)
+	}
+
+	r.stream.WriteUnit(t.Media, t.Format, u)
+
+	return nil
+}
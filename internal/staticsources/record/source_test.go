@@ -0,0 +1,163 @@
+package record
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+type testParent struct {
+	test.StaticSourceParent
+	recordConf *conf.Path
+}
+
+func (p *testParent) FindPathConf(req defs.PathFindPathConfReq) (*conf.Path, error) {
+	if req.AccessRequest.Name != "teststream" || !req.AccessRequest.SkipAuth {
+		return nil, conf.ErrPathNotFound
+	}
+	return p.recordConf, nil
+}
+
+func TestParseURL(t *testing.T) {
+	u, err := parseURL("record://my/path?start=2008-11-07T11:22:00+01:00&duration=5.5&speed=2&loop=true")
+	require.NoError(t, err)
+	require.Equal(t, &sourceURL{
+		pathName: "my/path",
+		start:    time.Date(2008, 11, 7, 10, 22, 0, 0, time.UTC),
+		duration: 5500 * time.Millisecond,
+		speed:    2,
+		loop:     true,
+	}, &sourceURL{
+		pathName: u.pathName,
+		start:    u.start.UTC(),
+		duration: u.duration,
+		speed:    u.speed,
+		loop:     u.loop,
+	})
+
+	for _, ca := range []string{
+		"record://",
+		"record://mypath?start=invalid",
+		"record://mypath?speed=0",
+		"record://mypath?duration=-1",
+		"record://mypath?loop=maybe",
+	} {
+		_, err = parseURL(ca)
+		require.Error(t, err, ca)
+	}
+}
+
+func TestSource(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-record-source")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "teststream"), 0o755)
+	require.NoError(t, err)
+
+	init := fmp4.Init{
+		Tracks: []*fmp4.InitTrack{{
+			ID:        1,
+			TimeScale: 90000,
+			Codec: &mp4.CodecH264{
+				SPS: test.FormatH264.SPS,
+				PPS: test.FormatH264.PPS,
+			},
+		}},
+	}
+
+	var buf1 seekablebuffer.Buffer
+	err = init.Marshal(&buf1)
+	require.NoError(t, err)
+
+	// a sample every 100ms and a keyframe every two samples
+	samples := make([]*fmp4.Sample, 10)
+	for i := range samples {
+		typ := byte(h264.NALUTypeIDR)
+		if (i % 2) != 0 {
+			typ = byte(h264.NALUTypeNonIDR)
+		}
+
+		var pl []byte
+		pl, err = h264.AVCC{{typ, byte(i)}}.Marshal()
+		require.NoError(t, err)
+
+		samples[i] = &fmp4.Sample{
+			Duration:        9000,
+			IsNonSyncSample: (i % 2) != 0,
+			Payload:         pl,
+		}
+	}
+
+	var buf2 seekablebuffer.Buffer
+	parts := fmp4.Parts{{
+		Tracks: []*fmp4.PartTrack{{
+			ID:      1,
+			Samples: samples,
+		}},
+	}}
+	err = parts.Marshal(&buf2)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "teststream", "2008-11-07_11-22-00-000000.mp4"),
+		append(buf1.Bytes(), buf2.Bytes()...), 0o644)
+	require.NoError(t, err)
+
+	p := &testParent{
+		recordConf: &conf.Path{
+			Name:       "teststream",
+			RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		},
+	}
+	p.Initialize()
+
+	so := &Source{
+		Parent: p,
+	}
+
+	done := make(chan struct{})
+	defer func() { <-done }()
+
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	reloadConf := make(chan *conf.Path)
+
+	start := time.Date(2008, 11, 7, 11, 22, 0, 200000000, time.Local)
+
+	go func() {
+		so.Run(defs.StaticSourceRunParams{ //nolint:errcheck
+			Context:        ctx,
+			ResolvedSource: "record://teststream?start=" + start.Format(time.RFC3339Nano) + "&speed=2&loop=true",
+			Conf:           &conf.Path{Name: "replay"},
+			ReloadConf:     reloadConf,
+		})
+		close(done)
+	}()
+
+	u := <-p.Unit
+	require.Equal(t, unit.PayloadH264{
+		test.FormatH264.SPS,
+		test.FormatH264.PPS,
+		{byte(h264.NALUTypeIDR), 2},
+	}, u.Payload)
+	require.Equal(t, int64(0), u.PTS)
+
+	// the source must be listening on ReloadConf
+	reloadConf <- nil
+
+	p.Close()
+}
@@ -56,10 +56,16 @@ func (s *Source) Run(params defs.StaticSourceRunParams) error {
 		}
 	}
 
+	tlsConfig, err := tls.MakeClientConfig(u.Hostname(), params.Conf.SourceFingerprint,
+		params.Conf.SourceCA, params.Conf.SourceClientCert, params.Conf.SourceClientKey)
+	if err != nil {
+		return err
+	}
+
 	connectCtx, connectCtxCancel := context.WithTimeout(params.Context, time.Duration(s.ReadTimeout))
 	conn := &gortmplib.Client{
 		URL:       u,
-		TLSConfig: tls.MakeConfig(u.Hostname(), params.Conf.SourceFingerprint),
+		TLSConfig: tlsConfig,
 		Publish:   false,
 	}
 	err = conn.Initialize(connectCtx)
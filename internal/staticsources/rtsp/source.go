@@ -145,12 +145,18 @@ func (s *Source) Run(params defs.StaticSourceRunParams) error {
 		return err
 	}
 
+	tlsConfig, err := tls.MakeClientConfig(u.Hostname(), params.Conf.SourceFingerprint,
+		params.Conf.SourceCA, params.Conf.SourceClientCert, params.Conf.SourceClientKey)
+	if err != nil {
+		return err
+	}
+
 	c := &gortsplib.Client{
 		Scheme:            scheme,
 		Host:              u.Host,
 		Tunnel:            tunnel,
 		Protocol:          params.Conf.RTSPTransport.Protocol,
-		TLSConfig:         tls.MakeConfig(u.Hostname(), params.Conf.SourceFingerprint),
+		TLSConfig:         tlsConfig,
 		ReadTimeout:       time.Duration(s.ReadTimeout),
 		WriteTimeout:      time.Duration(s.WriteTimeout),
 		WriteQueueSize:    s.WriteQueueSize,
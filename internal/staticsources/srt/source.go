@@ -103,13 +103,14 @@ func (s *Source) runReader(sconn srt.Conn) error {
 
 	var stream *stream.Stream
 
-	medias, err := mpegts.ToStream(r, &stream, s)
+	medias, mediaProps, err := mpegts.ToStream(r, &stream, s)
 	if err != nil {
 		return err
 	}
 
 	res := s.Parent.SetReady(defs.PathSourceStaticSetReadyReq{
 		Desc:               &description.Session{Medias: medias},
+		MediaProperties:    mediaProps,
 		GenerateRTPPackets: true,
 		FillNTP:            true,
 	})
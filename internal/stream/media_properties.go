@@ -0,0 +1,11 @@
+package stream
+
+// MediaProperties contains properties that a media has in the source stream
+// and that are not part of its description.
+type MediaProperties struct {
+	// MPEG-TS PID.
+	PID uint16
+
+	// ISO 639 language code.
+	Language string
+}
@@ -22,6 +22,7 @@ type Stream struct {
 	WriteQueueSize     int
 	RTPMaxPayloadSize  int
 	Desc               *description.Session
+	MediaProperties    map[*description.Media]*MediaProperties
 	GenerateRTPPackets bool
 	FillNTP            bool
 	Parent             logger.Writer
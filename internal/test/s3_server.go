@@ -0,0 +1,192 @@
+package test
+
+import (
+	"crypto/md5" //nolint:gosec
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// S3Server is a minimal S3-compatible object storage, that is used in place of MinIO in tests.
+// Objects are stored in memory and buckets are addressed with path-style URLs.
+type S3Server struct {
+	Bucket      string
+	AccessKeyID string
+
+	// number of requests that fail before the server starts working.
+	FailRequests int
+
+	mutex   sync.Mutex
+	ln      net.Listener
+	s       *http.Server
+	objects map[string][]byte
+}
+
+// Initialize initializes S3Server.
+func (s *S3Server) Initialize() error {
+	var err error
+	s.ln, err = net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return err
+	}
+
+	s.objects = make(map[string][]byte)
+	s.s = &http.Server{Handler: s}
+	go s.s.Serve(s.ln) //nolint:errcheck
+
+	return nil
+}
+
+// Close closes S3Server.
+func (s *S3Server) Close() {
+	s.s.Close()
+}
+
+// Endpoint returns the endpoint of the server.
+func (s *S3Server) Endpoint() string {
+	return "http://" + s.ln.Addr().String()
+}
+
+// Objects returns a copy of stored objects.
+func (s *S3Server) Objects() map[string][]byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	out := make(map[string][]byte, len(s.objects))
+	for k, v := range s.objects {
+		out[k] = v
+	}
+	return out
+}
+
+// SetObject stores an object.
+func (s *S3Server) SetObject(key string, byts []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.objects[key] = byts
+}
+
+// ServeHTTP implements http.Handler.
+func (s *S3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.FailRequests > 0 {
+		s.FailRequests--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	if !strings.Contains(r.Header.Get("Authorization"), "Credential="+s.AccessKeyID+"/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if parts[0] != s.Bucket {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 1 || parts[1] == "" {
+		s.onList(w, r)
+		return
+	}
+
+	key := parts[1]
+
+	switch r.Method {
+	case http.MethodPut:
+		byts, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		sum := md5.Sum(byts) //nolint:gosec
+		if r.Header.Get("Content-MD5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		s.objects[key] = byts
+		w.Header().Set("ETag", "\""+hex.EncodeToString(sum[:])+"\"")
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodHead, http.MethodGet:
+		byts, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.FormatInt(int64(len(byts)), 10))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		start, end := 0, len(byts)-1
+		status := http.StatusOK
+
+		if rng := r.Header.Get("Range"); rng != "" {
+			v := strings.SplitN(strings.TrimPrefix(rng, "bytes="), "-", 2)
+			start, _ = strconv.Atoi(v[0])
+			end, _ = strconv.Atoi(v[1])
+			if end >= len(byts) {
+				end = len(byts) - 1
+			}
+			status = http.StatusPartialContent
+		}
+
+		w.WriteHeader(status)
+		w.Write(byts[start : end+1]) //nolint:errcheck
+
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+type s3ListBucketResult struct {
+	XMLName  xml.Name `xml:"ListBucketResult"`
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int    `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated bool `xml:"IsTruncated"`
+}
+
+func (s *S3Server) onList(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var res s3ListBucketResult
+	for _, key := range keys {
+		res.Contents = append(res.Contents, struct {
+			Key  string `xml:"Key"`
+			Size int    `xml:"Size"`
+		}{key, len(s.objects[key])})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(res) //nolint:errcheck
+}
@@ -48,6 +48,14 @@ func TestAPIDocs(t *testing.T) {
 			"Info",
 			defs.APIInfo{},
 		},
+		{
+			"AuthPolicyTest",
+			defs.APIAuthPolicyTest{},
+		},
+		{
+			"AuthPolicyTestResult",
+			defs.APIAuthPolicyTestResult{},
+		},
 		{
 			"AuthInternalUser",
 			conf.AuthInternalUser{},
@@ -64,6 +72,10 @@ func TestAPIDocs(t *testing.T) {
 			"PathConf",
 			conf.Path{},
 		},
+		{
+			"RecordCopy",
+			conf.RecordCopy{},
+		},
 		{
 			"PathConfList",
 			defs.APIPathConfList{},
@@ -100,10 +112,38 @@ func TestAPIDocs(t *testing.T) {
 			"RecordingList",
 			defs.APIRecordingList{},
 		},
+		{
+			"RecordingMarker",
+			defs.APIRecordingMarker{},
+		},
+		{
+			"RecordingMarkerAdd",
+			defs.APIRecordingMarkerAdd{},
+		},
+		{
+			"RecordingMarkerList",
+			defs.APIRecordingMarkerList{},
+		},
+		{
+			"RecordingRepair",
+			defs.APIRecordingRepair{},
+		},
+		{
+			"RecordingRepairList",
+			defs.APIRecordingRepairList{},
+		},
 		{
 			"RecordingSegment",
 			defs.APIRecordingSegment{},
 		},
+		{
+			"RecordingUsage",
+			defs.APIRecordingUsage{},
+		},
+		{
+			"RecordingPathUsage",
+			defs.APIRecordingPathUsage{},
+		},
 		{
 			"RTMPConn",
 			defs.APIRTMPConn{},
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"github.com/bluenviron/mediamtx/internal/auth"
+	"github.com/bluenviron/mediamtx/internal/auth/policy"
 )
 
 // Credentials contains authentication credentials.
@@ -29,4 +30,20 @@ const (
 )
 
 // PauseAfterError is the pause after authentication failure.
-const PauseAfterError = auth.PauseAfterError
\ No newline at end of file
+const PauseAfterError = auth.PauseAfterError
+
+// Limit is a usage limit that applies to an authenticated request.
+type Limit = auth.Limit
+
+// SessionLimiterPeriod is the interval between checks of running sessions.
+const SessionLimiterPeriod = auth.SessionLimiterPeriod
+
+// SessionLimiter enforces per-user and per-permission limits
+// on readers and publishers.
+type SessionLimiter = auth.SessionLimiter
+
+// SessionLimitViolation is a session that exceeded one of its limits.
+type SessionLimitViolation = auth.SessionLimitViolation
+
+// PolicyInput is the document an authorization policy is evaluated against.
+type PolicyInput = policy.Input
@@ -39,5 +39,14 @@ type AuthInternalUserPermission = conf.AuthInternalUserPermission
 // AuthInternalUserPermissions is a list of user permissions.
 type AuthInternalUserPermissions = conf.AuthInternalUserPermissions
 
+// AuthTimeWindow is a time window in which a user or permission can be used.
+type AuthTimeWindow = conf.AuthTimeWindow
+
+// AuthTimeWindows is a list of time windows.
+type AuthTimeWindows = conf.AuthTimeWindows
+
 // Credential is a credential.
 type Credential = conf.Credential
+
+// AuthPolicy is a list of CEL expressions that authorize requests.
+type AuthPolicy = conf.AuthPolicy
@@ -11,8 +11,19 @@ type RecordFormat = conf.RecordFormat
 const (
 	RecordFormatFMP4   = conf.RecordFormatFMP4
 	RecordFormatMPEGTS = conf.RecordFormatMPEGTS
+	RecordFormatMKV    = conf.RecordFormatMKV
+	RecordFormatWebM   = conf.RecordFormatWebM
 )
 
+// RecordTracks is a list of track selectors.
+type RecordTracks = conf.RecordTracks
+
+// RecordCopy is an additional recording of a path.
+type RecordCopy = conf.RecordCopy
+
+// RecordCopies is a list of additional recordings of a path.
+type RecordCopies = conf.RecordCopies
+
 // RTSPRangeType is a RTSP range type.
 type RTSPRangeType = conf.RTSPRangeType
 
@@ -11,4 +11,12 @@ type APIRecordingSegment = defs.APIRecordingSegment
 type APIRecording = defs.APIRecording
 
 // APIRecordingList is a list of recordings.
-type APIRecordingList = defs.APIRecordingList
\ No newline at end of file
+type APIRecordingList = defs.APIRecordingList
+// APIRecordingMarker is a marker placed on the recording timeline of a path.
+type APIRecordingMarker = defs.APIRecordingMarker
+
+// APIRecordingMarkerAdd is a request to add a marker.
+type APIRecordingMarkerAdd = defs.APIRecordingMarkerAdd
+
+// APIRecordingMarkerList is a list of markers.
+type APIRecordingMarkerList = defs.APIRecordingMarkerList
@@ -0,0 +1,9 @@
+package defs
+
+import (
+	"github.com/bluenviron/mediamtx/internal/defs"
+)
+
+// BytesCounter is implemented by readers and publishers
+// that are able to report the data exchanged with their client.
+type BytesCounter = defs.BytesCounter
@@ -26,6 +26,8 @@ import (
 	"github.com/bluenviron/mediamtx/internal/confwatcher"
 	"github.com/bluenviron/mediamtx/internal/pprof"
 	"github.com/bluenviron/mediamtx/internal/recordcleaner"
+	"github.com/bluenviron/mediamtx/internal/recordrepairer"
+	"github.com/bluenviron/mediamtx/internal/recorduploader"
 	"github.com/bluenviron/mediamtx/internal/rlimit"
 	"github.com/bluenviron/mediamtx/internal/servers/hls"
 	"github.com/bluenviron/mediamtx/internal/servers/rtmp"
@@ -59,6 +61,31 @@ func atLeastOneRecordDeleteAfter(pathConfs map[string]*conf2.Path) bool {
 	return false
 }
 
+func atLeastOneRecordMaxSize(pathConfs map[string]*conf2.Path) bool {
+	for _, e := range pathConfs {
+		if e.RecordMaxSize != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func recordCleanerNeeded(cnf *conf2.Conf) bool {
+	return atLeastOneRecordDeleteAfter(cnf.Paths) ||
+		atLeastOneRecordMaxSize(cnf.Paths) ||
+		cnf.RecordTotalMaxSize != 0 ||
+		cnf.RecordMinFreeSpace != 0
+}
+
+func recordUploaderNeeded(cnf *conf2.Conf) bool {
+	for _, e := range cnf.Paths {
+		if e.RecordS3Upload {
+			return true
+		}
+	}
+	return false
+}
+
 func getRTPMaxPayloadSize(udpMaxPayloadSize int, rtspEncryption conf2.Encryption) int {
 	// UDP max payload size - 12 (RTP header)
 	v := udpMaxPayloadSize - 12
@@ -82,7 +109,9 @@ type Core struct {
 	AuthManager     *auth.Manager
 	Metrics         *metrics.Metrics
 	Pprof           *pprof.PPROF
+	recordRepairer  *recordrepairer.Repairer
 	recordCleaner   *recordcleaner.Cleaner
+	recordUploader  *recorduploader.Uploader
 	playbackServer  *playback.Server
 	PathManager     *pathManager
 	RtspServer      *rtsp.Server
@@ -287,6 +316,7 @@ func (p *Core) CreateResources(initial bool) error {
 			JWTClaimKey:        p.Conf.AuthJWTClaimKey,
 			JWTExclude:         p.Conf.AuthJWTExclude,
 			JWTInHTTPQuery:     p.Conf.AuthJWTInHTTPQuery,
+			Policy:             p.Conf.AuthPolicy,
 			ReadTimeout:        time.Duration(p.Conf.ReadTimeout),
 		}
 	}
@@ -294,15 +324,16 @@ func (p *Core) CreateResources(initial bool) error {
 	if p.Conf.Metrics &&
 		p.Metrics == nil {
 		i := &metrics.Metrics{
-			Address:        p.Conf.MetricsAddress,
-			Encryption:     p.Conf.MetricsEncryption,
-			ServerKey:      p.Conf.MetricsServerKey,
-			ServerCert:     p.Conf.MetricsServerCert,
-			AllowOrigin:    p.Conf.MetricsAllowOrigin,
-			TrustedProxies: p.Conf.MetricsTrustedProxies,
-			ReadTimeout:    p.Conf.ReadTimeout,
-			AuthManager:    p.AuthManager,
-			Parent:         p,
+			Address:              p.Conf.MetricsAddress,
+			Encryption:           p.Conf.MetricsEncryption,
+			ServerKey:            p.Conf.MetricsServerKey,
+			ServerCert:           p.Conf.MetricsServerCert,
+			AllowOrigin:          p.Conf.MetricsAllowOrigin,
+			TrustedProxies:       p.Conf.MetricsTrustedProxies,
+			ProxyProtocolSources: p.Conf.MetricsProxyProtocolSources,
+			ReadTimeout:          p.Conf.ReadTimeout,
+			AuthManager:          p.AuthManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -314,15 +345,16 @@ func (p *Core) CreateResources(initial bool) error {
 	if p.Conf.PPROF &&
 		p.Pprof == nil {
 		i := &pprof.PPROF{
-			Address:        p.Conf.PPROFAddress,
-			Encryption:     p.Conf.PPROFEncryption,
-			ServerKey:      p.Conf.PPROFServerKey,
-			ServerCert:     p.Conf.PPROFServerCert,
-			AllowOrigin:    p.Conf.PPROFAllowOrigin,
-			TrustedProxies: p.Conf.PPROFTrustedProxies,
-			ReadTimeout:    p.Conf.ReadTimeout,
-			AuthManager:    p.AuthManager,
-			Parent:         p,
+			Address:              p.Conf.PPROFAddress,
+			Encryption:           p.Conf.PPROFEncryption,
+			ServerKey:            p.Conf.PPROFServerKey,
+			ServerCert:           p.Conf.PPROFServerCert,
+			AllowOrigin:          p.Conf.PPROFAllowOrigin,
+			TrustedProxies:       p.Conf.PPROFTrustedProxies,
+			ProxyProtocolSources: p.Conf.PPROFProxyProtocolSources,
+			ReadTimeout:          p.Conf.ReadTimeout,
+			AuthManager:          p.AuthManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -331,34 +363,35 @@ func (p *Core) CreateResources(initial bool) error {
 		p.Pprof = i
 	}
 
-	if p.recordCleaner == nil &&
-		atLeastOneRecordDeleteAfter(p.Conf.Paths) {
-		p.recordCleaner = &recordcleaner.Cleaner{
+	// find segments left incomplete by an abrupt termination before recorders start,
+	// and repair them in the background
+	if initial && p.recordRepairer == nil {
+		p.recordRepairer = &recordrepairer.Repairer{
 			PathConfs: p.Conf.Paths,
 			Parent:    p,
 		}
-		p.recordCleaner.Initialize()
+		p.recordRepairer.Initialize()
 	}
 
-	if p.Conf.Playback &&
-		p.playbackServer == nil {
-		i := &playback.Server{
-			Address:        p.Conf.PlaybackAddress,
-			Encryption:     p.Conf.PlaybackEncryption,
-			ServerKey:      p.Conf.PlaybackServerKey,
-			ServerCert:     p.Conf.PlaybackServerCert,
-			AllowOrigin:    p.Conf.PlaybackAllowOrigin,
-			TrustedProxies: p.Conf.PlaybackTrustedProxies,
-			ReadTimeout:    p.Conf.ReadTimeout,
-			PathConfs:      p.Conf.Paths,
-			AuthManager:    p.AuthManager,
-			Parent:         p,
+	if p.recordCleaner == nil &&
+		recordCleanerNeeded(p.Conf) {
+		p.recordCleaner = &recordcleaner.Cleaner{
+			PathConfs:    p.Conf.Paths,
+			TotalMaxSize: p.Conf.RecordTotalMaxSize,
+			MinFreeSpace: p.Conf.RecordMinFreeSpace,
+			Metrics:      p.Metrics,
+			Parent:       p,
 		}
-		err = i.Initialize()
-		if err != nil {
-			return err
+		p.recordCleaner.Initialize()
+	}
+
+	if p.recordUploader == nil &&
+		recordUploaderNeeded(p.Conf) {
+		p.recordUploader = &recorduploader.Uploader{
+			PathConfs: p.Conf.Paths,
+			Parent:    p,
 		}
-		p.playbackServer = i
+		p.recordUploader.Initialize()
 	}
 
 	if p.PathManager == nil {
@@ -380,6 +413,31 @@ func (p *Core) CreateResources(initial bool) error {
 		p.PathManager.initialize()
 	}
 
+	if p.Conf.Playback &&
+		p.playbackServer == nil {
+		i := &playback.Server{
+			Address:              p.Conf.PlaybackAddress,
+			Encryption:           p.Conf.PlaybackEncryption,
+			ServerKey:            p.Conf.PlaybackServerKey,
+			ServerCert:           p.Conf.PlaybackServerCert,
+			AllowOrigin:          p.Conf.PlaybackAllowOrigin,
+			TrustedProxies:       p.Conf.PlaybackTrustedProxies,
+			ProxyProtocolSources: p.Conf.PlaybackProxyProtocolSources,
+			ReadTimeout:          p.Conf.ReadTimeout,
+			ExportDirectory:      p.Conf.PlaybackExportDirectory,
+			ExportTTL:            p.Conf.PlaybackExportTTL,
+			PathConfs:            p.Conf.Paths,
+			AuthManager:          p.AuthManager,
+			PathManager:          p.PathManager,
+			Parent:               p,
+		}
+		err = i.Initialize()
+		if err != nil {
+			return err
+		}
+		p.playbackServer = i
+	}
+
 	if p.Conf.RTSP &&
 		(p.Conf.RTSPEncryption == conf2.EncryptionNo ||
 			p.Conf.RTSPEncryption == conf2.EncryptionOptional) &&
@@ -388,31 +446,34 @@ func (p *Core) CreateResources(initial bool) error {
 		_, useMulticast := p.Conf.RTSPTransports[gortsplib.ProtocolUDPMulticast]
 
 		i := &rtsp.Server{
-			Address:             p.Conf.RTSPAddress,
-			AuthMethods:         p.Conf.RTSPAuthMethods,
-			UDPReadBufferSize:   p.Conf.RTSPUDPReadBufferSize,
-			ReadTimeout:         p.Conf.ReadTimeout,
-			WriteTimeout:        p.Conf.WriteTimeout,
-			WriteQueueSize:      p.Conf.WriteQueueSize,
-			UseUDP:              useUDP,
-			UseMulticast:        useMulticast,
-			RTPAddress:          p.Conf.RTPAddress,
-			RTCPAddress:         p.Conf.RTCPAddress,
-			MulticastIPRange:    p.Conf.MulticastIPRange,
-			MulticastRTPPort:    p.Conf.MulticastRTPPort,
-			MulticastRTCPPort:   p.Conf.MulticastRTCPPort,
-			IsTLS:               false,
-			ServerCert:          "",
-			ServerKey:           "",
-			RTSPAddress:         p.Conf.RTSPAddress,
-			Transports:          p.Conf.RTSPTransports,
-			RunOnConnect:        p.Conf.RunOnConnect,
-			RunOnConnectRestart: p.Conf.RunOnConnectRestart,
-			RunOnDisconnect:     p.Conf.RunOnDisconnect,
-			ExternalCmdPool:     p.ExternalCmdPool,
-			Metrics:             p.Metrics,
-			PathManager:         p.PathManager,
-			Parent:              p,
+			Address:              p.Conf.RTSPAddress,
+			AuthMethods:          p.Conf.RTSPAuthMethods,
+			UDPReadBufferSize:    p.Conf.RTSPUDPReadBufferSize,
+			ReadTimeout:          p.Conf.ReadTimeout,
+			WriteTimeout:         p.Conf.WriteTimeout,
+			WriteQueueSize:       p.Conf.WriteQueueSize,
+			UseUDP:               useUDP,
+			UseMulticast:         useMulticast,
+			RTPAddress:           p.Conf.RTPAddress,
+			RTCPAddress:          p.Conf.RTCPAddress,
+			MulticastIPRange:     p.Conf.MulticastIPRange,
+			MulticastRTPPort:     p.Conf.MulticastRTPPort,
+			MulticastRTCPPort:    p.Conf.MulticastRTCPPort,
+			IsTLS:                false,
+			ServerCert:           "",
+			ServerKey:            "",
+			ProxyProtocolSources: p.Conf.RTSPProxyProtocolSources,
+			RTSPAddress:          p.Conf.RTSPAddress,
+			Transports:           p.Conf.RTSPTransports,
+			Playback:             p.Conf.RTSPPlayback,
+			RTPMaxPayloadSize:    getRTPMaxPayloadSize(p.Conf.UDPMaxPayloadSize, p.Conf.RTSPEncryption),
+			RunOnConnect:         p.Conf.RunOnConnect,
+			RunOnConnectRestart:  p.Conf.RunOnConnectRestart,
+			RunOnDisconnect:      p.Conf.RunOnDisconnect,
+			ExternalCmdPool:      p.ExternalCmdPool,
+			Metrics:              p.Metrics,
+			PathManager:          p.PathManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -429,31 +490,35 @@ func (p *Core) CreateResources(initial bool) error {
 		_, useMulticast := p.Conf.RTSPTransports[gortsplib.ProtocolUDPMulticast]
 
 		i := &rtsp.Server{
-			Address:             p.Conf.RTSPSAddress,
-			AuthMethods:         p.Conf.RTSPAuthMethods,
-			UDPReadBufferSize:   p.Conf.RTSPUDPReadBufferSize,
-			ReadTimeout:         p.Conf.ReadTimeout,
-			WriteTimeout:        p.Conf.WriteTimeout,
-			WriteQueueSize:      p.Conf.WriteQueueSize,
-			UseUDP:              useUDP,
-			UseMulticast:        useMulticast,
-			RTPAddress:          p.Conf.SRTPAddress,
-			RTCPAddress:         p.Conf.SRTCPAddress,
-			MulticastIPRange:    p.Conf.MulticastIPRange,
-			MulticastRTPPort:    p.Conf.MulticastSRTPPort,
-			MulticastRTCPPort:   p.Conf.MulticastSRTCPPort,
-			IsTLS:               true,
-			ServerCert:          p.Conf.RTSPServerCert,
-			ServerKey:           p.Conf.RTSPServerKey,
-			RTSPAddress:         p.Conf.RTSPAddress,
-			Transports:          p.Conf.RTSPTransports,
-			RunOnConnect:        p.Conf.RunOnConnect,
-			RunOnConnectRestart: p.Conf.RunOnConnectRestart,
-			RunOnDisconnect:     p.Conf.RunOnDisconnect,
-			ExternalCmdPool:     p.ExternalCmdPool,
-			Metrics:             p.Metrics,
-			PathManager:         p.PathManager,
-			Parent:              p,
+			Address:              p.Conf.RTSPSAddress,
+			AuthMethods:          p.Conf.RTSPAuthMethods,
+			UDPReadBufferSize:    p.Conf.RTSPUDPReadBufferSize,
+			ReadTimeout:          p.Conf.ReadTimeout,
+			WriteTimeout:         p.Conf.WriteTimeout,
+			WriteQueueSize:       p.Conf.WriteQueueSize,
+			UseUDP:               useUDP,
+			UseMulticast:         useMulticast,
+			RTPAddress:           p.Conf.SRTPAddress,
+			RTCPAddress:          p.Conf.SRTCPAddress,
+			MulticastIPRange:     p.Conf.MulticastIPRange,
+			MulticastRTPPort:     p.Conf.MulticastSRTPPort,
+			MulticastRTCPPort:    p.Conf.MulticastSRTCPPort,
+			IsTLS:                true,
+			ServerCert:           p.Conf.RTSPServerCert,
+			ClientCA:             p.Conf.RTSPClientCA,
+			ServerKey:            p.Conf.RTSPServerKey,
+			ProxyProtocolSources: p.Conf.RTSPProxyProtocolSources,
+			RTSPAddress:          p.Conf.RTSPAddress,
+			Transports:           p.Conf.RTSPTransports,
+			Playback:             p.Conf.RTSPPlayback,
+			RTPMaxPayloadSize:    getRTPMaxPayloadSize(p.Conf.UDPMaxPayloadSize, p.Conf.RTSPEncryption),
+			RunOnConnect:         p.Conf.RunOnConnect,
+			RunOnConnectRestart:  p.Conf.RunOnConnectRestart,
+			RunOnDisconnect:      p.Conf.RunOnDisconnect,
+			ExternalCmdPool:      p.ExternalCmdPool,
+			Metrics:              p.Metrics,
+			PathManager:          p.PathManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -467,20 +532,21 @@ func (p *Core) CreateResources(initial bool) error {
 			p.Conf.RTMPEncryption == conf2.EncryptionOptional) &&
 		p.RtmpServer == nil {
 		i := &rtmp.Server{
-			Address:             p.Conf.RTMPAddress,
-			ReadTimeout:         p.Conf.ReadTimeout,
-			WriteTimeout:        p.Conf.WriteTimeout,
-			IsTLS:               false,
-			ServerCert:          "",
-			ServerKey:           "",
-			RTSPAddress:         p.Conf.RTSPAddress,
-			RunOnConnect:        p.Conf.RunOnConnect,
-			RunOnConnectRestart: p.Conf.RunOnConnectRestart,
-			RunOnDisconnect:     p.Conf.RunOnDisconnect,
-			ExternalCmdPool:     p.ExternalCmdPool,
-			Metrics:             p.Metrics,
-			PathManager:         p.PathManager,
-			Parent:              p,
+			Address:              p.Conf.RTMPAddress,
+			ReadTimeout:          p.Conf.ReadTimeout,
+			WriteTimeout:         p.Conf.WriteTimeout,
+			IsTLS:                false,
+			ServerCert:           "",
+			ServerKey:            "",
+			ProxyProtocolSources: p.Conf.RTMPProxyProtocolSources,
+			RTSPAddress:          p.Conf.RTSPAddress,
+			RunOnConnect:         p.Conf.RunOnConnect,
+			RunOnConnectRestart:  p.Conf.RunOnConnectRestart,
+			RunOnDisconnect:      p.Conf.RunOnDisconnect,
+			ExternalCmdPool:      p.ExternalCmdPool,
+			Metrics:              p.Metrics,
+			PathManager:          p.PathManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -494,20 +560,22 @@ func (p *Core) CreateResources(initial bool) error {
 			p.Conf.RTMPEncryption == conf2.EncryptionOptional) &&
 		p.RtmpsServer == nil {
 		i := &rtmp.Server{
-			Address:             p.Conf.RTMPSAddress,
-			ReadTimeout:         p.Conf.ReadTimeout,
-			WriteTimeout:        p.Conf.WriteTimeout,
-			IsTLS:               true,
-			ServerCert:          p.Conf.RTMPServerCert,
-			ServerKey:           p.Conf.RTMPServerKey,
-			RTSPAddress:         p.Conf.RTSPAddress,
-			RunOnConnect:        p.Conf.RunOnConnect,
-			RunOnConnectRestart: p.Conf.RunOnConnectRestart,
-			RunOnDisconnect:     p.Conf.RunOnDisconnect,
-			ExternalCmdPool:     p.ExternalCmdPool,
-			Metrics:             p.Metrics,
-			PathManager:         p.PathManager,
-			Parent:              p,
+			Address:              p.Conf.RTMPSAddress,
+			ReadTimeout:          p.Conf.ReadTimeout,
+			WriteTimeout:         p.Conf.WriteTimeout,
+			IsTLS:                true,
+			ServerCert:           p.Conf.RTMPServerCert,
+			ClientCA:             p.Conf.RTMPClientCA,
+			ServerKey:            p.Conf.RTMPServerKey,
+			ProxyProtocolSources: p.Conf.RTMPProxyProtocolSources,
+			RTSPAddress:          p.Conf.RTSPAddress,
+			RunOnConnect:         p.Conf.RunOnConnect,
+			RunOnConnectRestart:  p.Conf.RunOnConnectRestart,
+			RunOnDisconnect:      p.Conf.RunOnDisconnect,
+			ExternalCmdPool:      p.ExternalCmdPool,
+			Metrics:              p.Metrics,
+			PathManager:          p.PathManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -519,24 +587,26 @@ func (p *Core) CreateResources(initial bool) error {
 	if p.Conf.HLS &&
 		p.HlsServer == nil {
 		i := &hls.Server{
-			Address:         p.Conf.HLSAddress,
-			Encryption:      p.Conf.HLSEncryption,
-			ServerKey:       p.Conf.HLSServerKey,
-			ServerCert:      p.Conf.HLSServerCert,
-			AllowOrigin:     p.Conf.HLSAllowOrigin,
-			TrustedProxies:  p.Conf.HLSTrustedProxies,
-			AlwaysRemux:     p.Conf.HLSAlwaysRemux,
-			Variant:         p.Conf.HLSVariant,
-			SegmentCount:    p.Conf.HLSSegmentCount,
-			SegmentDuration: p.Conf.HLSSegmentDuration,
-			PartDuration:    p.Conf.HLSPartDuration,
-			SegmentMaxSize:  p.Conf.HLSSegmentMaxSize,
-			Directory:       p.Conf.HLSDirectory,
-			ReadTimeout:     p.Conf.ReadTimeout,
-			MuxerCloseAfter: p.Conf.HLSMuxerCloseAfter,
-			Metrics:         p.Metrics,
-			PathManager:     p.PathManager,
-			Parent:          p,
+			Address:              p.Conf.HLSAddress,
+			Encryption:           p.Conf.HLSEncryption,
+			ServerKey:            p.Conf.HLSServerKey,
+			ServerCert:           p.Conf.HLSServerCert,
+			ClientCA:             p.Conf.HLSClientCA,
+			AllowOrigin:          p.Conf.HLSAllowOrigin,
+			TrustedProxies:       p.Conf.HLSTrustedProxies,
+			ProxyProtocolSources: p.Conf.HLSProxyProtocolSources,
+			AlwaysRemux:          p.Conf.HLSAlwaysRemux,
+			Variant:              p.Conf.HLSVariant,
+			SegmentCount:         p.Conf.HLSSegmentCount,
+			SegmentDuration:      p.Conf.HLSSegmentDuration,
+			PartDuration:         p.Conf.HLSPartDuration,
+			SegmentMaxSize:       p.Conf.HLSSegmentMaxSize,
+			Directory:            p.Conf.HLSDirectory,
+			ReadTimeout:          p.Conf.ReadTimeout,
+			MuxerCloseAfter:      p.Conf.HLSMuxerCloseAfter,
+			Metrics:              p.Metrics,
+			PathManager:          p.PathManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -552,8 +622,10 @@ func (p *Core) CreateResources(initial bool) error {
 			Encryption:            p.Conf.WebRTCEncryption,
 			ServerKey:             p.Conf.WebRTCServerKey,
 			ServerCert:            p.Conf.WebRTCServerCert,
+			ClientCA:              p.Conf.WebRTCClientCA,
 			AllowOrigin:           p.Conf.WebRTCAllowOrigin,
 			TrustedProxies:        p.Conf.WebRTCTrustedProxies,
+			ProxyProtocolSources:  p.Conf.WebRTCProxyProtocolSources,
 			ReadTimeout:           p.Conf.ReadTimeout,
 			LocalUDPAddress:       p.Conf.WebRTCLocalUDPAddress,
 			LocalTCPAddress:       p.Conf.WebRTCLocalTCPAddress,
@@ -579,18 +651,19 @@ func (p *Core) CreateResources(initial bool) error {
 	if p.Conf.SRT &&
 		p.SrtServer == nil {
 		i := &srt.Server{
-			Address:             p.Conf.SRTAddress,
-			RTSPAddress:         p.Conf.RTSPAddress,
-			ReadTimeout:         p.Conf.ReadTimeout,
-			WriteTimeout:        p.Conf.WriteTimeout,
-			UDPMaxPayloadSize:   p.Conf.UDPMaxPayloadSize,
-			RunOnConnect:        p.Conf.RunOnConnect,
-			RunOnConnectRestart: p.Conf.RunOnConnectRestart,
-			RunOnDisconnect:     p.Conf.RunOnDisconnect,
-			ExternalCmdPool:     p.ExternalCmdPool,
-			Metrics:             p.Metrics,
-			PathManager:         p.PathManager,
-			Parent:              p,
+			Address:              p.Conf.SRTAddress,
+			RTSPAddress:          p.Conf.RTSPAddress,
+			ReadTimeout:          p.Conf.ReadTimeout,
+			WriteTimeout:         p.Conf.WriteTimeout,
+			UDPMaxPayloadSize:    p.Conf.UDPMaxPayloadSize,
+			ProxyProtocolSources: p.Conf.SRTProxyProtocolSources,
+			RunOnConnect:         p.Conf.RunOnConnect,
+			RunOnConnectRestart:  p.Conf.RunOnConnectRestart,
+			RunOnDisconnect:      p.Conf.RunOnDisconnect,
+			ExternalCmdPool:      p.ExternalCmdPool,
+			Metrics:              p.Metrics,
+			PathManager:          p.PathManager,
+			Parent:               p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -606,8 +679,10 @@ func (p *Core) CreateResources(initial bool) error {
 	// 		Encryption:     p.Conf.APIEncryption,
 	// 		ServerKey:      p.Conf.APIServerKey,
 	// 		ServerCert:     p.Conf.APIServerCert,
+	// 		ClientCA:       p.Conf.APIClientCA,
 	// 		AllowOrigin:    p.Conf.APIAllowOrigin,
 	// 		TrustedProxies: p.Conf.APITrustedProxies,
+	// 		ProxyProtocolSources: p.Conf.APIProxyProtocolSources,
 	// 		ReadTimeout:    p.Conf.ReadTimeout,
 	// 		Conf:           p.Conf,
 	// 		AuthManager:    p.AuthManager,
@@ -661,6 +736,9 @@ func (p *Core) closeResources(newConf *conf2.Conf, calledByAPI bool) {
 	if !closeAuthManager && !reflect.DeepEqual(newConf.AuthInternalUsers, p.Conf.AuthInternalUsers) {
 		p.AuthManager.ReloadInternalUsers(newConf.AuthInternalUsers)
 	}
+	if !closeAuthManager && !reflect.DeepEqual(newConf.AuthPolicy, p.Conf.AuthPolicy) {
+		p.AuthManager.ReloadPolicy(newConf.AuthPolicy)
+	}
 
 	closeMetrics := newConf == nil ||
 		newConf.Metrics != p.Conf.Metrics ||
@@ -670,6 +748,7 @@ func (p *Core) closeResources(newConf *conf2.Conf, calledByAPI bool) {
 		newConf.MetricsServerCert != p.Conf.MetricsServerCert ||
 		newConf.MetricsAllowOrigin != p.Conf.MetricsAllowOrigin ||
 		!reflect.DeepEqual(newConf.MetricsTrustedProxies, p.Conf.MetricsTrustedProxies) ||
+		!reflect.DeepEqual(newConf.MetricsProxyProtocolSources, p.Conf.MetricsProxyProtocolSources) ||
 		newConf.ReadTimeout != p.Conf.ReadTimeout ||
 		closeAuthManager ||
 		closeLogger
@@ -682,30 +761,26 @@ func (p *Core) closeResources(newConf *conf2.Conf, calledByAPI bool) {
 		newConf.PPROFServerCert != p.Conf.PPROFServerCert ||
 		newConf.PPROFAllowOrigin != p.Conf.PPROFAllowOrigin ||
 		!reflect.DeepEqual(newConf.PPROFTrustedProxies, p.Conf.PPROFTrustedProxies) ||
+		!reflect.DeepEqual(newConf.PPROFProxyProtocolSources, p.Conf.PPROFProxyProtocolSources) ||
 		newConf.ReadTimeout != p.Conf.ReadTimeout ||
 		closeAuthManager ||
 		closeLogger
 
 	closeRecorderCleaner := newConf == nil ||
-		atLeastOneRecordDeleteAfter(newConf.Paths) != atLeastOneRecordDeleteAfter(p.Conf.Paths) ||
+		recordCleanerNeeded(newConf) != recordCleanerNeeded(p.Conf) ||
+		newConf.RecordTotalMaxSize != p.Conf.RecordTotalMaxSize ||
+		newConf.RecordMinFreeSpace != p.Conf.RecordMinFreeSpace ||
+		closeMetrics ||
 		closeLogger
 	if !closeRecorderCleaner && p.recordCleaner != nil && !reflect.DeepEqual(newConf.Paths, p.Conf.Paths) {
 		p.recordCleaner.ReloadPathConfs(newConf.Paths)
 	}
 
-	closePlaybackServer := newConf == nil ||
-		newConf.Playback != p.Conf.Playback ||
-		newConf.PlaybackAddress != p.Conf.PlaybackAddress ||
-		newConf.PlaybackEncryption != p.Conf.PlaybackEncryption ||
-		newConf.PlaybackServerKey != p.Conf.PlaybackServerKey ||
-		newConf.PlaybackServerCert != p.Conf.PlaybackServerCert ||
-		newConf.PlaybackAllowOrigin != p.Conf.PlaybackAllowOrigin ||
-		!reflect.DeepEqual(newConf.PlaybackTrustedProxies, p.Conf.PlaybackTrustedProxies) ||
-		newConf.ReadTimeout != p.Conf.ReadTimeout ||
-		closeAuthManager ||
+	closeRecordUploader := newConf == nil ||
+		recordUploaderNeeded(newConf) != recordUploaderNeeded(p.Conf) ||
 		closeLogger
-	if !closePlaybackServer && p.playbackServer != nil && !reflect.DeepEqual(newConf.Paths, p.Conf.Paths) {
-		p.playbackServer.ReloadPathConfs(newConf.Paths)
+	if !closeRecordUploader && p.recordUploader != nil && !reflect.DeepEqual(newConf.Paths, p.Conf.Paths) {
+		p.recordUploader.ReloadPathConfs(newConf.Paths)
 	}
 
 	closePathManager := newConf == nil ||
@@ -724,6 +799,25 @@ func (p *Core) closeResources(newConf *conf2.Conf, calledByAPI bool) {
 		p.PathManager.ReloadPathConfs(newConf.Paths)
 	}
 
+	closePlaybackServer := newConf == nil ||
+		newConf.Playback != p.Conf.Playback ||
+		newConf.PlaybackAddress != p.Conf.PlaybackAddress ||
+		newConf.PlaybackEncryption != p.Conf.PlaybackEncryption ||
+		newConf.PlaybackServerKey != p.Conf.PlaybackServerKey ||
+		newConf.PlaybackServerCert != p.Conf.PlaybackServerCert ||
+		newConf.PlaybackAllowOrigin != p.Conf.PlaybackAllowOrigin ||
+		!reflect.DeepEqual(newConf.PlaybackTrustedProxies, p.Conf.PlaybackTrustedProxies) ||
+		!reflect.DeepEqual(newConf.PlaybackProxyProtocolSources, p.Conf.PlaybackProxyProtocolSources) ||
+		newConf.PlaybackExportDirectory != p.Conf.PlaybackExportDirectory ||
+		newConf.PlaybackExportTTL != p.Conf.PlaybackExportTTL ||
+		newConf.ReadTimeout != p.Conf.ReadTimeout ||
+		closeAuthManager ||
+		closePathManager ||
+		closeLogger
+	if !closePlaybackServer && p.playbackServer != nil && !reflect.DeepEqual(newConf.Paths, p.Conf.Paths) {
+		p.playbackServer.ReloadPathConfs(newConf.Paths)
+	}
+
 	closeRTSPServer := newConf == nil ||
 		newConf.RTSP != p.Conf.RTSP ||
 		newConf.RTSPEncryption != p.Conf.RTSPEncryption ||
@@ -738,8 +832,10 @@ func (p *Core) closeResources(newConf *conf2.Conf, calledByAPI bool) {
 		newConf.MulticastIPRange != p.Conf.MulticastIPRange ||
 		newConf.MulticastRTPPort != p.Conf.MulticastRTPPort ||
 		newConf.MulticastRTCPPort != p.Conf.MulticastRTCPPort ||
+		!reflect.DeepEqual(newConf.RTSPProxyProtocolSources, p.Conf.RTSPProxyProtocolSources) ||
 		newConf.RTSPAddress != p.Conf.RTSPAddress ||
 		!reflect.DeepEqual(newConf.RTSPTransports, p.Conf.RTSPTransports) ||
+		newConf.RTSPPlayback != p.Conf.RTSPPlayback ||
 		newConf.RunOnConnect != p.Conf.RunOnConnect ||
 		newConf.RunOnConnectRestart != p.Conf.RunOnConnectRestart ||
 		newConf.RunOnDisconnect != p.Conf.RunOnDisconnect ||
@@ -757,9 +853,12 @@ func (p *Core) closeResources(newConf *conf2.Conf, calledByAPI bool) {
 		newConf.WriteTimeout != p.Conf.WriteTimeout ||
 		newConf.WriteQueueSize != p.Conf.WriteQueueSize ||
 		newConf.RTSPServerCert != p.Conf.RTSPServerCert ||
+		newConf.RTSPClientCA != p.Conf.RTSPClientCA ||
 		newConf.RTSPServerKey != p.Conf.RTSPServerKey ||
+		!reflect.DeepEqual(newConf.RTSPProxyProtocolSources, p.Conf.RTSPProxyProtocolSources) ||
 		newConf.RTSPAddress != p.Conf.RTSPAddress ||
 		!reflect.DeepEqual(newConf.RTSPTransports, p.Conf.RTSPTransports) ||
+		newConf.RTSPPlayback != p.Conf.RTSPPlayback ||
 		newConf.RunOnConnect != p.Conf.RunOnConnect ||
 		newConf.RunOnConnectRestart != p.Conf.RunOnConnectRestart ||
 		newConf.RunOnDisconnect != p.Conf.RunOnDisconnect ||
@@ -771,6 +870,7 @@ func (p *Core) closeResources(newConf *conf2.Conf, calledByAPI bool) {
 		newConf.RTMP != p.Conf.RTMP ||
 		newConf.RTMPEncryption != p.Conf.RTMPEncryption ||
 		newConf.RTMPAddress != p.Conf.RTMPAddress ||
+		!reflect.DeepEqual(newConf.RTMPProxyProtocolSources, p.Conf.RTMPProxyProtocolSources) ||
 		newConf.ReadTimeout != p.Conf.ReadTimeout ||
 		newConf.WriteTimeout != p.Conf.WriteTimeout ||
 		newConf.RTSPAddress != p.Conf.RTSPAddress ||
@@ -788,7 +888,9 @@ func (p *Core) closeResources(newConf *conf2.Conf, calledByAPI bool) {
 		newConf.ReadTimeout != p.Conf.ReadTimeout ||
 		newConf.WriteTimeout != p.Conf.WriteTimeout ||
 		newConf.RTMPServerCert != p.Conf.RTMPServerCert ||
+		newConf.RTMPClientCA != p.Conf.RTMPClientCA ||
 		newConf.RTMPServerKey != p.Conf.RTMPServerKey ||
+		!reflect.DeepEqual(newConf.RTMPProxyProtocolSources, p.Conf.RTMPProxyProtocolSources) ||
 		newConf.RTSPAddress != p.Conf.RTSPAddress ||
 		newConf.RunOnConnect != p.Conf.RunOnConnect ||
 		newConf.RunOnConnectRestart != p.Conf.RunOnConnectRestart ||
@@ -803,8 +905,10 @@ func (p *Core) closeResources(newConf *conf2.Conf, calledByAPI bool) {
 		newConf.HLSEncryption != p.Conf.HLSEncryption ||
 		newConf.HLSServerKey != p.Conf.HLSServerKey ||
 		newConf.HLSServerCert != p.Conf.HLSServerCert ||
+		newConf.HLSClientCA != p.Conf.HLSClientCA ||
 		newConf.HLSAllowOrigin != p.Conf.HLSAllowOrigin ||
 		!reflect.DeepEqual(newConf.HLSTrustedProxies, p.Conf.HLSTrustedProxies) ||
+		!reflect.DeepEqual(newConf.HLSProxyProtocolSources, p.Conf.HLSProxyProtocolSources) ||
 		newConf.HLSAlwaysRemux != p.Conf.HLSAlwaysRemux ||
 		newConf.HLSVariant != p.Conf.HLSVariant ||
 		newConf.HLSSegmentCount != p.Conf.HLSSegmentCount ||
@@ -824,8 +928,10 @@ func (p *Core) closeResources(newConf *conf2.Conf, calledByAPI bool) {
 		newConf.WebRTCEncryption != p.Conf.WebRTCEncryption ||
 		newConf.WebRTCServerKey != p.Conf.WebRTCServerKey ||
 		newConf.WebRTCServerCert != p.Conf.WebRTCServerCert ||
+		newConf.WebRTCClientCA != p.Conf.WebRTCClientCA ||
 		newConf.WebRTCAllowOrigin != p.Conf.WebRTCAllowOrigin ||
 		!reflect.DeepEqual(newConf.WebRTCTrustedProxies, p.Conf.WebRTCTrustedProxies) ||
+		!reflect.DeepEqual(newConf.WebRTCProxyProtocolSources, p.Conf.WebRTCProxyProtocolSources) ||
 		newConf.ReadTimeout != p.Conf.ReadTimeout ||
 		newConf.WebRTCLocalUDPAddress != p.Conf.WebRTCLocalUDPAddress ||
 		newConf.WebRTCLocalTCPAddress != p.Conf.WebRTCLocalTCPAddress ||
@@ -843,6 +949,7 @@ func (p *Core) closeResources(newConf *conf2.Conf, calledByAPI bool) {
 	closeSRTServer := newConf == nil ||
 		newConf.SRT != p.Conf.SRT ||
 		newConf.SRTAddress != p.Conf.SRTAddress ||
+		!reflect.DeepEqual(newConf.SRTProxyProtocolSources, p.Conf.SRTProxyProtocolSources) ||
 		newConf.RTSPAddress != p.Conf.RTSPAddress ||
 		newConf.ReadTimeout != p.Conf.ReadTimeout ||
 		newConf.WriteTimeout != p.Conf.WriteTimeout ||
@@ -861,6 +968,7 @@ func (p *Core) closeResources(newConf *conf2.Conf, calledByAPI bool) {
 	// 	newConf.APIServerCert != p.Conf.APIServerCert ||
 	// 	newConf.APIAllowOrigin != p.Conf.APIAllowOrigin ||
 	// 	!reflect.DeepEqual(newConf.APITrustedProxies, p.Conf.APITrustedProxies) ||
+	// 	!reflect.DeepEqual(newConf.APIProxyProtocolSources, p.Conf.APIProxyProtocolSources) ||
 	// 	newConf.ReadTimeout != p.Conf.ReadTimeout ||
 	// 	closeAuthManager ||
 	// 	closePathManager ||
@@ -921,21 +1029,31 @@ func (p *Core) closeResources(newConf *conf2.Conf, calledByAPI bool) {
 		p.RtspServer = nil
 	}
 
-	if closePathManager && p.PathManager != nil {
-		p.PathManager.close()
-		p.PathManager = nil
-	}
-
 	if closePlaybackServer && p.playbackServer != nil {
 		p.playbackServer.Close()
 		p.playbackServer = nil
 	}
 
+	if closePathManager && p.PathManager != nil {
+		p.PathManager.close()
+		p.PathManager = nil
+	}
+
 	if closeRecorderCleaner && p.recordCleaner != nil {
 		p.recordCleaner.Close()
 		p.recordCleaner = nil
 	}
 
+	if closeRecordUploader && p.recordUploader != nil {
+		p.recordUploader.Close()
+		p.recordUploader = nil
+	}
+
+	if newConf == nil && p.recordRepairer != nil {
+		p.recordRepairer.Close()
+		p.recordRepairer = nil
+	}
+
 	if closePPROF && p.Pprof != nil {
 		p.Pprof.Close()
 		p.Pprof = nil
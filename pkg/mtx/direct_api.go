@@ -91,6 +91,7 @@ type MediaMTXAPIInterface interface {
 	IsRecording(pathName string) (bool, error)
 	SetRecordingPath(pathName, recordingPath string) error
 	GetRecordingPath(pathName string) (string, error)
+	TriggerRecording(pathName string, postRoll time.Duration) error
 
 	// Authentication Management
 	Authenticate(req *auth.Request) *auth.Error
@@ -653,6 +654,9 @@ type PathOptions struct {
 	RecordMaxPartSize     string `json:"recordMaxPartSize,omitempty"`
 	RecordSegmentDuration string `json:"recordSegmentDuration,omitempty"`
 	RecordDeleteAfter     string `json:"recordDeleteAfter,omitempty"`
+	RecordTrigger         bool   `json:"recordTrigger,omitempty"`
+	RecordPreRoll         string `json:"recordPreRoll,omitempty"`
+	RecordPostRoll        string `json:"recordPostRoll,omitempty"`
 
 	// Publisher source
 	OverridePublisher    bool   `json:"overridePublisher,omitempty"`
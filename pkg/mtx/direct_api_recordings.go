@@ -1,11 +1,12 @@
 package mtx
 
 import (
+	"context"
 	"fmt"
 	"github.com/bluenviron/mediamtx/pkg/conf"
 	"github.com/bluenviron/mediamtx/pkg/defs"
 	"github.com/bluenviron/mediamtx/pkg/recordstore"
-	"os"
+	"github.com/google/uuid"
 	"strings"
 	"time"
 )
@@ -138,7 +139,7 @@ func (api *MediaMTXAPI) DeleteRecordingSegment(pathName string, segmentStart tim
 		Start: segmentStart,
 	}.Encode(pathFormat)
 
-	err = os.Remove(segmentPath)
+	err = recordstore.DeleteSegment(context.Background(), pathConf, pathName, segmentPath)
 	if err != nil {
 		return fmt.Errorf("failed to delete recording segment: %v", err)
 	}
@@ -146,6 +147,88 @@ func (api *MediaMTXAPI) DeleteRecordingSegment(pathName string, segmentStart tim
 	return nil
 }
 
+// AddRecordingMarker adds a marker to the recording timeline of a PathHandler
+func (api *MediaMTXAPI) AddRecordingMarker(pathName string, req *defs.APIRecordingMarkerAdd) (*defs.APIRecordingMarker, error) {
+	globalConf, err := api.GetGlobalConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configuration: %v", err)
+	}
+
+	pathConf, _, err := conf.FindPathConf(globalConf.Paths, pathName)
+	if err != nil {
+		return nil, fmt.Errorf("PathHandler configuration error: %v", err)
+	}
+
+	if req.Start.IsZero() || req.Duration < 0 || req.Label == "" {
+		return nil, fmt.Errorf("invalid marker")
+	}
+
+	m := &recordstore.Marker{
+		Start:    req.Start,
+		Duration: time.Duration(req.Duration * float64(time.Second)),
+		Label:    req.Label,
+		Data:     req.Data,
+	}
+
+	err = recordstore.AddMarker(pathConf.RecordPath, pathName, m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add marker: %v", err)
+	}
+
+	return markerToAPI(m), nil
+}
+
+// GetRecordingMarkers returns markers of a PathHandler that overlap a time range
+func (api *MediaMTXAPI) GetRecordingMarkers(pathName string, startTime, endTime *time.Time) ([]*defs.APIRecordingMarker, error) {
+	globalConf, err := api.GetGlobalConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configuration: %v", err)
+	}
+
+	pathConf, _, err := conf.FindPathConf(globalConf.Paths, pathName)
+	if err != nil {
+		return nil, fmt.Errorf("PathHandler configuration error: %v", err)
+	}
+
+	markers, err := recordstore.FindMarkers(pathConf.RecordPath, pathName, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list markers: %v", err)
+	}
+
+	out := make([]*defs.APIRecordingMarker, len(markers))
+	for i, m := range markers {
+		out[i] = markerToAPI(m)
+	}
+
+	return out, nil
+}
+
+// DeleteRecordingMarker removes a marker from the recording timeline of a PathHandler
+func (api *MediaMTXAPI) DeleteRecordingMarker(pathName string, id uuid.UUID) error {
+	globalConf, err := api.GetGlobalConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get configuration: %v", err)
+	}
+
+	pathConf, _, err := conf.FindPathConf(globalConf.Paths, pathName)
+	if err != nil {
+		return fmt.Errorf("PathHandler configuration error: %v", err)
+	}
+
+	return recordstore.RemoveMarker(pathConf.RecordPath, pathName, id)
+}
+
+func markerToAPI(m *recordstore.Marker) *defs.APIRecordingMarker {
+	return &defs.APIRecordingMarker{
+		ID:       m.ID,
+		Created:  m.Created,
+		Start:    m.Start,
+		Duration: m.Duration.Seconds(),
+		Label:    m.Label,
+		Data:     m.Data,
+	}
+}
+
 // GetRecordingsByPath returns recordings for a specific PathHandler
 func (api *MediaMTXAPI) GetRecordingsByPath(pathName string, pagination *PaginationParams) (*defs.APIRecordingList, error) {
 	query := &RecordingQuery{Path: pathName}
@@ -274,6 +357,12 @@ func (api *MediaMTXAPI) StopRecording(pathName string) error {
 	return nil
 }
 
+// TriggerRecording writes the pre-roll buffered by a PathHandler with recordTrigger enabled,
+// followed by postRoll of live stream. A zero postRoll means recordPostRoll.
+func (api *MediaMTXAPI) TriggerRecording(pathName string, postRoll time.Duration) error {
+	return api.core.PathManager.APIRecordingTrigger(pathName, postRoll)
+}
+
 // IsRecording checks if a PathHandler is currently recording
 func (api *MediaMTXAPI) IsRecording(pathName string) (bool, error) {
 	pathConfig, err := api.GetPathConfig(pathName)
@@ -55,6 +55,16 @@ type GlobalConf struct {
 	AuthJWTExclude         []AuthInternalUserPermission `json:"authJWTExclude,omitempty"`
 }
 
+// RecordCopy represents an additional recording of a PathHandler (matches OpenAPI RecordCopy)
+type RecordCopy struct {
+	RecordPath            string   `json:"recordPath,omitempty"`
+	RecordFormat          string   `json:"recordFormat,omitempty"`
+	RecordSegmentDuration string   `json:"recordSegmentDuration,omitempty"`
+	RecordDeleteAfter     string   `json:"recordDeleteAfter,omitempty"`
+	RecordMaxSize         string   `json:"recordMaxSize,omitempty"`
+	RecordTracks          []string `json:"recordTracks,omitempty"`
+}
+
 // PathConf represents a PathHandler configuration (matches OpenAPI PathConf)
 type PathConf struct {
 	Name string `json:"name,omitempty"`
@@ -72,13 +82,31 @@ type PathConf struct {
 	UseAbsoluteTimestamp       bool   `json:"useAbsoluteTimestamp,omitempty"`
 
 	// Record
-	Record                bool   `json:"record,omitempty"`
-	RecordPath            string `json:"recordPath,omitempty"`
-	RecordFormat          string `json:"recordFormat,omitempty"`
-	RecordPartDuration    string `json:"recordPartDuration,omitempty"`
-	RecordMaxPartSize     string `json:"recordMaxPartSize,omitempty"`
-	RecordSegmentDuration string `json:"recordSegmentDuration,omitempty"`
-	RecordDeleteAfter     string `json:"recordDeleteAfter,omitempty"`
+	Record                     bool         `json:"record,omitempty"`
+	RecordPath                 string       `json:"recordPath,omitempty"`
+	RecordFormat               string       `json:"recordFormat,omitempty"`
+	RecordPartDuration         string       `json:"recordPartDuration,omitempty"`
+	RecordMaxPartSize          string       `json:"recordMaxPartSize,omitempty"`
+	RecordSegmentDuration      string       `json:"recordSegmentDuration,omitempty"`
+	RecordSegmentAlign         bool         `json:"recordSegmentAlign,omitempty"`
+	RecordKeyframeInterval     string       `json:"recordKeyframeInterval,omitempty"`
+	RecordDeleteAfter          string       `json:"recordDeleteAfter,omitempty"`
+	RecordTrigger              bool         `json:"recordTrigger,omitempty"`
+	RecordPreRoll              string       `json:"recordPreRoll,omitempty"`
+	RecordPostRoll             string       `json:"recordPostRoll,omitempty"`
+	RecordTracks               []string     `json:"recordTracks,omitempty"`
+	RecordCopies               []RecordCopy `json:"recordCopies,omitempty"`
+	RecordS3Upload             bool         `json:"recordS3Upload,omitempty"`
+	RecordS3Endpoint           string       `json:"recordS3Endpoint,omitempty"`
+	RecordS3Region             string       `json:"recordS3Region,omitempty"`
+	RecordS3Bucket             string       `json:"recordS3Bucket,omitempty"`
+	RecordS3AccessKeyID        string       `json:"recordS3AccessKeyID,omitempty"`
+	RecordS3SecretKey          string       `json:"recordS3SecretKey,omitempty"`
+	RecordS3Key                string       `json:"recordS3Key,omitempty"`
+	RecordS3DeleteLocal        bool         `json:"recordS3DeleteLocal,omitempty"`
+	RecordEncryption           bool         `json:"recordEncryption,omitempty"`
+	RecordEncryptionKeyFile    string       `json:"recordEncryptionKeyFile,omitempty"`
+	RecordEncryptionKeyCommand string       `json:"recordEncryptionKeyCommand,omitempty"`
 
 	// Publisher source
 	OverridePublisher    bool   `json:"overridePublisher,omitempty"`
@@ -150,6 +178,7 @@ type PathSourceType string
 
 const (
 	PathSourceTypeHLSSource       PathSourceType = "hlsSource"
+	PathSourceTypeRecordSource    PathSourceType = "recordSource"
 	PathSourceTypeRedirect        PathSourceType = "redirect"
 	PathSourceTypeRPiCameraSource PathSourceType = "rpiCameraSource"
 	PathSourceTypeRTMPConn        PathSourceType = "rtmpConn"
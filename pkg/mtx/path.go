@@ -3,15 +3,18 @@ package mtx
 import (
 	"context"
 	"fmt"
+	"github.com/bluenviron/mediamtx/pkg/auth"
 	conf2 "github.com/bluenviron/mediamtx/pkg/conf"
 	defs2 "github.com/bluenviron/mediamtx/pkg/defs"
 	"github.com/bluenviron/mediamtx/pkg/externalcmd"
 	"github.com/bluenviron/mediamtx/pkg/hooks"
 	"github.com/bluenviron/mediamtx/pkg/logger"
 	"github.com/bluenviron/mediamtx/pkg/recorder"
+	"github.com/bluenviron/mediamtx/pkg/recordstore"
 	"github.com/bluenviron/mediamtx/pkg/staticsources"
 	"github.com/bluenviron/mediamtx/pkg/stream"
 	"net"
+	"reflect"
 	"strconv"
 	"sync"
 	"time"
@@ -35,6 +38,7 @@ type pathParent interface {
 	pathNotReady(*PathHandler)
 	closePath(*PathHandler)
 	AddReader(req defs2.PathAddReaderReq) (defs2.Path, *stream.Stream, error)
+	FindPathConf(req defs2.PathFindPathConfReq) (*conf2.Path, error)
 
 	PathHook
 }
@@ -68,6 +72,11 @@ type pathAPIPathsGetReq struct {
 	res  chan pathAPIPathsGetRes
 }
 
+type pathAPIRecordingTriggerReq struct {
+	postRoll time.Duration
+	res      chan error
+}
+
 type PathHandler struct {
 	parentCtx         context.Context
 	logLevel          conf2.LogLevel
@@ -81,6 +90,7 @@ type PathHandler struct {
 	matches           []string
 	wg                *sync.WaitGroup
 	externalCmdPool   *externalcmd.Pool
+	limiter           *auth.SessionLimiter
 	parent            pathParent
 
 	ctx                            context.Context
@@ -89,7 +99,7 @@ type PathHandler struct {
 	source                         defs2.Source
 	publisherQuery                 string
 	stream                         *stream.Stream
-	recorder                       *recorder.Recorder
+	recorders                      []*recorder.Recorder
 	readyTime                      time.Time
 	onUnDemandHook                 func(string)
 	onNotReadyHook                 func()
@@ -113,6 +123,7 @@ type PathHandler struct {
 	chAddReader               chan defs2.PathAddReaderReq
 	chRemoveReader            chan defs2.PathRemoveReaderReq
 	chAPIPathsGet             chan pathAPIPathsGetReq
+	chAPIRecordingTrigger     chan pathAPIRecordingTriggerReq
 
 	// out
 	done chan struct{}
@@ -137,6 +148,7 @@ func (pa *PathHandler) initialize() {
 	pa.chAddReader = make(chan defs2.PathAddReaderReq)
 	pa.chRemoveReader = make(chan defs2.PathRemoveReaderReq)
 	pa.chAPIPathsGet = make(chan pathAPIPathsGetReq)
+	pa.chAPIRecordingTrigger = make(chan pathAPIRecordingTriggerReq)
 	pa.done = make(chan struct{})
 
 	pa.Log(logger.Debug, "created")
@@ -314,6 +326,9 @@ func (pa *PathHandler) runInner() error {
 		case req := <-pa.chAPIPathsGet:
 			pa.doAPIPathsGet(req)
 
+		case req := <-pa.chAPIRecordingTrigger:
+			pa.doAPIRecordingTrigger(req)
+
 		case <-pa.ctx.Done():
 			return fmt.Errorf("terminated")
 		}
@@ -367,25 +382,33 @@ func (pa *PathHandler) doReloadConf(newConf *conf2.Path) {
 		pa.source.(*staticsources.Handler).ReloadConf(newConf)
 	}
 
-	if pa.recorder != nil &&
+	if len(pa.recorders) != 0 &&
 		(newConf.Record != oldConf.Record ||
 			newConf.RecordPath != oldConf.RecordPath ||
 			newConf.RecordFormat != oldConf.RecordFormat ||
 			newConf.RecordPartDuration != oldConf.RecordPartDuration ||
 			newConf.RecordMaxPartSize != oldConf.RecordMaxPartSize ||
 			newConf.RecordSegmentDuration != oldConf.RecordSegmentDuration ||
-			newConf.RecordDeleteAfter != oldConf.RecordDeleteAfter) {
-		pa.recorder.Close()
-		pa.recorder = nil
+			newConf.RecordSegmentAlign != oldConf.RecordSegmentAlign ||
+			newConf.RecordKeyframeInterval != oldConf.RecordKeyframeInterval ||
+			newConf.RecordDeleteAfter != oldConf.RecordDeleteAfter ||
+			newConf.RecordTrigger != oldConf.RecordTrigger ||
+			newConf.RecordPreRoll != oldConf.RecordPreRoll ||
+			!reflect.DeepEqual(newConf.RecordTracks, oldConf.RecordTracks) ||
+			!reflect.DeepEqual(newConf.RecordCopies, oldConf.RecordCopies) ||
+			newConf.RecordEncryption != oldConf.RecordEncryption ||
+			newConf.RecordEncryptionKeyFile != oldConf.RecordEncryptionKeyFile ||
+			newConf.RecordEncryptionKeyCommand != oldConf.RecordEncryptionKeyCommand) {
+		pa.closeRecorders()
 	}
 
-	if newConf.Record && pa.stream != nil && pa.recorder == nil {
+	if newConf.Record && pa.stream != nil && len(pa.recorders) == 0 {
 		pa.startRecording()
 	}
 }
 
 func (pa *PathHandler) doSourceStaticSetReady(req defs2.PathSourceStaticSetReadyReq) {
-	err := pa.setReady(req.Desc, req.GenerateRTPPackets)
+	err := pa.setReady(req.Desc, req.MediaProperties, req.GenerateRTPPackets)
 	if err != nil {
 		req.Res <- defs2.PathSourceStaticSetReadyRes{Err: err}
 		return
@@ -482,7 +505,7 @@ func (pa *PathHandler) doAddPublisher(req defs2.PathAddPublisherReq) {
 	pa.source = req.Author
 	pa.publisherQuery = req.AccessRequest.Query
 
-	err := pa.setReady(req.Desc, req.GenerateRTPPackets)
+	err := pa.setReady(req.Desc, req.MediaProperties, req.GenerateRTPPackets)
 	if err != nil {
 		pa.source = nil
 		req.Res <- defs2.PathAddPublisherRes{Err: err}
@@ -600,6 +623,28 @@ func (pa *PathHandler) doAPIPathsGet(req pathAPIPathsGetReq) {
 	}
 }
 
+func (pa *PathHandler) doAPIRecordingTrigger(req pathAPIRecordingTriggerReq) {
+	if !pa.conf.Record || !pa.conf.RecordTrigger {
+		req.res <- fmt.Errorf("path is not configured for triggered recording")
+		return
+	}
+
+	if len(pa.recorders) == 0 {
+		req.res <- fmt.Errorf("path is not ready")
+		return
+	}
+
+	postRoll := req.postRoll
+	if postRoll == 0 {
+		postRoll = time.Duration(pa.conf.RecordPostRoll)
+	}
+
+	for _, r := range pa.recorders {
+		r.Trigger(postRoll)
+	}
+	req.res <- nil
+}
+
 func (pa *PathHandler) SafeConf() *conf2.Path {
 	pa.confMutex.RLock()
 	defer pa.confMutex.RUnlock()
@@ -696,11 +741,16 @@ func (pa *PathHandler) onDemandPublisherStop(reason string) {
 	pa.parent.OnUnDemand(pa.ctx, pa, reason)
 }
 
-func (pa *PathHandler) setReady(desc *description.Session, allocateEncoder bool) error {
+func (pa *PathHandler) setReady(
+	desc *description.Session,
+	mediaProps map[*description.Media]*stream.MediaProperties,
+	allocateEncoder bool,
+) error {
 	pa.stream = &stream.Stream{
 		WriteQueueSize:     pa.writeQueueSize,
 		RTPMaxPayloadSize:  pa.rtpMaxPayloadSize,
 		Desc:               desc,
+		MediaProperties:    mediaProps,
 		GenerateRTPPackets: allocateEncoder,
 		Parent:             pa.source,
 	}
@@ -754,10 +804,7 @@ func (pa *PathHandler) setNotReady() {
 
 	pa.onNotReadyHook()
 
-	if pa.recorder != nil {
-		pa.recorder.Close()
-		pa.recorder = nil
-	}
+	pa.closeRecorders()
 
 	if pa.stream != nil {
 		pa.stream.Close()
@@ -768,50 +815,78 @@ func (pa *PathHandler) setNotReady() {
 }
 
 func (pa *PathHandler) startRecording() {
-	pa.recorder = &recorder.Recorder{
-		PathFormat:      pa.conf.RecordPath,
-		Format:          pa.conf.RecordFormat,
-		PartDuration:    time.Duration(pa.conf.RecordPartDuration),
-		MaxPartSize:     pa.conf.RecordMaxPartSize,
-		SegmentDuration: time.Duration(pa.conf.RecordSegmentDuration),
-		PathName:        pa.name,
-		Stream:          pa.stream,
-		OnSegmentCreate: func(segmentPath string) {
-			if pa.conf.RunOnRecordSegmentCreate != "" {
-				env := pa.ExternalCmdEnv()
-				env["MTX_SEGMENT_PATH"] = segmentPath
-
-				pa.Log(logger.Info, "runOnRecordSegmentCreate command launched")
-				externalcmd.NewCmd(
-					pa.externalCmdPool,
-					pa.conf.RunOnRecordSegmentCreate,
-					false,
-					env,
-					nil)
-			}
-		},
-		OnSegmentComplete: func(segmentPath string, segmentDuration time.Duration) {
-			if pa.conf.RunOnRecordSegmentComplete != "" {
-				env := pa.ExternalCmdEnv()
-				env["MTX_SEGMENT_PATH"] = segmentPath
-				env["MTX_SEGMENT_DURATION"] = strconv.FormatFloat(segmentDuration.Seconds(), 'f', -1, 64)
-
-				pa.Log(logger.Info, "runOnRecordSegmentComplete command launched")
-				externalcmd.NewCmd(
-					pa.externalCmdPool,
-					pa.conf.RunOnRecordSegmentComplete,
-					false,
-					env,
-					nil)
-			}
-		},
-		Parent: pa,
+	var encryptionKey *recordstore.EncryptionKey
+
+	if pa.conf.RecordEncryption {
+		keys, err := recordstore.LoadEncryptionKeys(pa.conf)
+		if err != nil {
+			pa.Log(logger.Error, "unable to start recording: %v", err)
+			return
+		}
+		encryptionKey = &keys[0]
+	}
+
+	for _, rc := range pa.conf.RecordRenditions() {
+		r := &recorder.Recorder{
+			PathFormat:       rc.RecordPath,
+			Format:           rc.RecordFormat,
+			PartDuration:     time.Duration(pa.conf.RecordPartDuration),
+			MaxPartSize:      pa.conf.RecordMaxPartSize,
+			SegmentDuration:  time.Duration(rc.RecordSegmentDuration),
+			SegmentAlign:     pa.conf.RecordSegmentAlign,
+			KeyframeInterval: time.Duration(pa.conf.RecordKeyframeInterval),
+			Tracks:           rc.RecordTracks,
+			PathName:         pa.name,
+			Stream:           pa.stream,
+			TriggerMode:      pa.conf.RecordTrigger,
+			PreRoll:          time.Duration(pa.conf.RecordPreRoll),
+			EncryptionKey:    encryptionKey,
+			OnSegmentCreate: func(segmentPath string) {
+				if pa.conf.RunOnRecordSegmentCreate != "" {
+					env := pa.ExternalCmdEnv()
+					env["MTX_SEGMENT_PATH"] = segmentPath
+
+					pa.Log(logger.Info, "runOnRecordSegmentCreate command launched")
+					externalcmd.NewCmd(
+						pa.externalCmdPool,
+						pa.conf.RunOnRecordSegmentCreate,
+						false,
+						env,
+						nil)
+				}
+			},
+			OnSegmentComplete: func(segmentPath string, segmentDuration time.Duration) {
+				if pa.conf.RunOnRecordSegmentComplete != "" {
+					env := pa.ExternalCmdEnv()
+					env["MTX_SEGMENT_PATH"] = segmentPath
+					env["MTX_SEGMENT_DURATION"] = strconv.FormatFloat(segmentDuration.Seconds(), 'f', -1, 64)
+
+					pa.Log(logger.Info, "runOnRecordSegmentComplete command launched")
+					externalcmd.NewCmd(
+						pa.externalCmdPool,
+						pa.conf.RunOnRecordSegmentComplete,
+						false,
+						env,
+						nil)
+				}
+			},
+			Parent: pa,
+		}
+		r.Initialize()
+		pa.recorders = append(pa.recorders, r)
 	}
-	pa.recorder.Initialize()
+}
+
+func (pa *PathHandler) closeRecorders() {
+	for _, r := range pa.recorders {
+		r.Close()
+	}
+	pa.recorders = nil
 }
 
 func (pa *PathHandler) executeRemoveReader(r defs2.Reader) {
 	delete(pa.readers, r)
+	pa.limiter.Release(r)
 }
 
 func (pa *PathHandler) executeRemovePublisher() {
@@ -819,6 +894,9 @@ func (pa *PathHandler) executeRemovePublisher() {
 		pa.setNotReady()
 	}
 
+	if pa.source != nil {
+		pa.limiter.Release(pa.source)
+	}
 	pa.source = nil
 }
 
@@ -966,3 +1044,15 @@ func (pa *PathHandler) APIPathsGet(req pathAPIPathsGetReq) (*defs2.APIPath, erro
 		return nil, fmt.Errorf("terminated")
 	}
 }
+
+// APIRecordingTrigger is called by api.
+func (pa *PathHandler) APIRecordingTrigger(req pathAPIRecordingTriggerReq) error {
+	req.res = make(chan error)
+	select {
+	case pa.chAPIRecordingTrigger <- req:
+		return <-req.res
+
+	case <-pa.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
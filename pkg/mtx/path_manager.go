@@ -12,6 +12,7 @@ import (
 	"github.com/bluenviron/mediamtx/pkg/stream"
 	"sort"
 	"sync"
+	"time"
 
 	// Keep internal packages that we haven't exposed yet
 	"github.com/bluenviron/mediamtx/internal/servers/hls"
@@ -29,7 +30,18 @@ func PathConfCanBeUpdated(oldPathConf *conf2.Path, newPathConf *conf2.Path) bool
 	clone.RecordPartDuration = newPathConf.RecordPartDuration
 	clone.RecordMaxPartSize = newPathConf.RecordMaxPartSize
 	clone.RecordSegmentDuration = newPathConf.RecordSegmentDuration
+	clone.RecordSegmentAlign = newPathConf.RecordSegmentAlign
+	clone.RecordKeyframeInterval = newPathConf.RecordKeyframeInterval
 	clone.RecordDeleteAfter = newPathConf.RecordDeleteAfter
+	clone.RecordMaxSize = newPathConf.RecordMaxSize
+	clone.RecordTrigger = newPathConf.RecordTrigger
+	clone.RecordPreRoll = newPathConf.RecordPreRoll
+	clone.RecordPostRoll = newPathConf.RecordPostRoll
+	clone.RecordTracks = newPathConf.RecordTracks
+	clone.RecordCopies = newPathConf.RecordCopies
+	clone.RecordEncryption = newPathConf.RecordEncryption
+	clone.RecordEncryptionKeyFile = newPathConf.RecordEncryptionKeyFile
+	clone.RecordEncryptionKeyCommand = newPathConf.RecordEncryptionKeyCommand
 
 	clone.RPICameraBrightness = newPathConf.RPICameraBrightness
 	clone.RPICameraContrast = newPathConf.RPICameraContrast
@@ -90,6 +102,7 @@ type pathManager struct {
 	wg        sync.WaitGroup
 	hlsServer *hls.Server
 	paths     map[string]*pathData
+	limiter   auth.SessionLimiter
 
 	// in
 	chReloadConf   chan map[string]*conf2.Path
@@ -143,6 +156,7 @@ func (pm *pathManager) initialize() {
 	pm.ctx = ctx
 	pm.ctxCancel = ctxCancel
 	pm.paths = make(map[string]*pathData)
+	pm.limiter.Initialize()
 	pm.chReloadConf = make(chan map[string]*conf2.Path)
 	pm.chSetHLSServer = make(chan pathSetHLSServerReq)
 	pm.chClosePath = make(chan *PathHandler)
@@ -190,6 +204,9 @@ func (pm *pathManager) Log(level logger.Level, format string, args ...interface{
 func (pm *pathManager) run() {
 	defer pm.wg.Done()
 
+	limiterTicker := time.NewTicker(auth.SessionLimiterPeriod)
+	defer limiterTicker.Stop()
+
 outer:
 	for {
 		select {
@@ -227,6 +244,9 @@ outer:
 		case req := <-pm.chAPIPathsGet:
 			pm.doAPIPathsGet(req)
 
+		case <-limiterTicker.C:
+			pm.doCheckLimits()
+
 		case <-pm.ctx.Done():
 			break outer
 		}
@@ -235,6 +255,22 @@ outer:
 	pm.ctxCancel()
 }
 
+func (pm *pathManager) doCheckLimits() {
+	for _, v := range pm.limiter.Check(time.Now()) {
+		switch author := v.Author.(type) {
+		case defs2.Reader:
+			desc := author.APIReaderDescribe()
+			pm.Log(logger.Info, "closing reader %s %s: %v", desc.Type, desc.ID, v.Err)
+			author.Close()
+
+		case defs2.Publisher:
+			desc := author.APISourceDescribe()
+			pm.Log(logger.Info, "closing publisher %s %s: %v", desc.Type, desc.ID, v.Err)
+			author.Close()
+		}
+	}
+}
+
 func (pm *pathManager) doReloadConf(newPaths map[string]*conf2.Path) {
 	confsToRecreate := make(map[string]struct{})
 	confsToReload := make(map[string]struct{})
@@ -358,10 +394,12 @@ func (pm *pathManager) doFindPathConf(req defs2.PathFindPathConfReq) {
 		return
 	}
 
-	err2 := pm.authManager.Authenticate(req.AccessRequest.ToAuthRequest())
-	if err2 != nil {
-		req.Res <- defs2.PathFindPathConfRes{Err: err2}
-		return
+	if !req.AccessRequest.SkipAuth {
+		err2 := pm.authManager.Authenticate(req.AccessRequest.ToAuthRequest())
+		if err2 != nil {
+			req.Res <- defs2.PathFindPathConfRes{Err: err2}
+			return
+		}
 	}
 
 	req.Res <- defs2.PathFindPathConfRes{Conf: pathConf}
@@ -396,8 +434,11 @@ func (pm *pathManager) doAddReader(req defs2.PathAddReaderReq) {
 		return
 	}
 
+	var limits []auth.Limit
+
 	if !req.AccessRequest.SkipAuth {
-		err2 := pm.authManager.Authenticate(req.AccessRequest.ToAuthRequest())
+		var err2 *auth.Error
+		limits, err2 = pm.authManager.AuthenticateWithLimits(req.AccessRequest.ToAuthRequest())
 		if err2 != nil {
 			req.Res <- defs2.PathAddReaderRes{Err: err2}
 			return
@@ -410,6 +451,13 @@ func (pm *pathManager) doAddReader(req defs2.PathAddReaderReq) {
 	}
 
 	pd := pm.paths[req.AccessRequest.Name]
+
+	err = pm.limiter.Reserve(req.Author, pd.path, false, limits)
+	if err != nil {
+		req.Res <- defs2.PathAddReaderRes{Err: err}
+		return
+	}
+
 	req.Res <- defs2.PathAddReaderRes{Path: pd.path}
 }
 
@@ -425,8 +473,11 @@ func (pm *pathManager) doAddPublisher(req defs2.PathAddPublisherReq) {
 		return
 	}
 
+	var limits []auth.Limit
+
 	if !req.AccessRequest.SkipAuth {
-		err2 := pm.authManager.Authenticate(req.AccessRequest.ToAuthRequest())
+		var err2 *auth.Error
+		limits, err2 = pm.authManager.AuthenticateWithLimits(req.AccessRequest.ToAuthRequest())
 		if err2 != nil {
 			req.Res <- defs2.PathAddPublisherRes{Err: err2}
 			return
@@ -439,6 +490,13 @@ func (pm *pathManager) doAddPublisher(req defs2.PathAddPublisherReq) {
 	}
 
 	pd := pm.paths[req.AccessRequest.Name]
+
+	err = pm.limiter.Reserve(req.Author, pd.path, true, limits)
+	if err != nil {
+		req.Res <- defs2.PathAddPublisherRes{Err: err}
+		return
+	}
+
 	req.Res <- defs2.PathAddPublisherRes{Path: pd.path}
 }
 
@@ -480,6 +538,7 @@ func (pm *pathManager) createPath(
 		matches:           matches,
 		wg:                &pm.wg,
 		externalCmdPool:   pm.externalCmdPool,
+		limiter:           &pm.limiter,
 		parent:            pm,
 	}
 	pa.initialize()
@@ -492,6 +551,7 @@ func (pm *pathManager) createPath(
 
 func (pm *pathManager) removePath(pa *PathHandler) {
 	delete(pm.paths, pa.name)
+	pm.limiter.ReleasePath(pa)
 }
 
 // ReloadPathConfs is called by core.
@@ -575,7 +635,14 @@ func (pm *pathManager) AddPublisher(req defs2.PathAddPublisherReq) (defs2.Path,
 			return nil, nil, res.Err
 		}
 
-		return res.Path.(*PathHandler).addPublisher(req)
+		pa, strm, err := res.Path.(*PathHandler).addPublisher(req)
+		if err != nil {
+			pm.limiter.Release(req.Author)
+			return nil, nil, err
+		}
+
+		pm.limiter.Attach(req.Author)
+		return pa, strm, nil
 
 	case <-pm.ctx.Done():
 		return nil, nil, fmt.Errorf("terminated")
@@ -592,7 +659,14 @@ func (pm *pathManager) AddReader(req defs2.PathAddReaderReq) (defs2.Path, *strea
 			return nil, nil, res.Err
 		}
 
-		return res.Path.(*PathHandler).addReader(req)
+		pa, strm, err := res.Path.(*PathHandler).addReader(req)
+		if err != nil {
+			pm.limiter.Release(req.Author)
+			return nil, nil, err
+		}
+
+		pm.limiter.Attach(req.Author)
+		return pa, strm, nil
 
 	case <-pm.ctx.Done():
 		return nil, nil, fmt.Errorf("terminated")
@@ -669,3 +743,24 @@ func (pm *pathManager) APIPathsGet(name string) (*defs2.APIPath, error) {
 		return nil, fmt.Errorf("terminated")
 	}
 }
+
+// APIRecordingTrigger is called by api.
+func (pm *pathManager) APIRecordingTrigger(name string, postRoll time.Duration) error {
+	req := pathAPIPathsGetReq{
+		name: name,
+		res:  make(chan pathAPIPathsGetRes),
+	}
+
+	select {
+	case pm.chAPIPathsGet <- req:
+		res := <-req.res
+		if res.err != nil {
+			return res.err
+		}
+
+		return res.path.APIRecordingTrigger(pathAPIRecordingTriggerReq{postRoll: postRoll})
+
+	case <-pm.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
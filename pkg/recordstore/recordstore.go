@@ -7,6 +7,9 @@ import (
 // Segment is a recording segment.
 type Segment = recordstore.Segment
 
+// SegmentInfo contains informations about a complete segment.
+type SegmentInfo = recordstore.SegmentInfo
+
 // Path is a recording path.
 type Path = recordstore.Path
 
@@ -16,6 +19,45 @@ var FindAllPathsWithSegments = recordstore.FindAllPathsWithSegments
 // FindSegments finds recording segments for a path.
 var FindSegments = recordstore.FindSegments
 
+// IndexAddSegment adds a segment to the recording index.
+var IndexAddSegment = recordstore.IndexAddSegment
+
+// IndexCompleteSegment stores informations about a complete segment into the recording index.
+var IndexCompleteSegment = recordstore.IndexCompleteSegment
+
+// IndexRemoveSegment removes a segment from the recording index.
+var IndexRemoveSegment = recordstore.IndexRemoveSegment
+
+// IndexUploadSegment marks a segment as uploaded to the object storage.
+var IndexUploadSegment = recordstore.IndexUploadSegment
+
+// OpenSegment opens a segment, stored on disk or in the object storage.
+var OpenSegment = recordstore.OpenSegment
+
+// DeleteSegment deletes a segment from disk, from the object storage and from the recording index.
+var DeleteSegment = recordstore.DeleteSegment
+
+// EncryptionKey is a key used to encrypt segments.
+type EncryptionKey = recordstore.EncryptionKey
+
+// LoadEncryptionKeys returns the encryption keys of a path.
+var LoadEncryptionKeys = recordstore.LoadEncryptionKeys
+
+// Marker is a marker placed on the recording timeline of a path.
+type Marker = recordstore.Marker
+
+// AddMarker adds a marker to the recording timeline of a path.
+var AddMarker = recordstore.AddMarker
+
+// FindMarkers returns markers of a path that overlap a time range.
+var FindMarkers = recordstore.FindMarkers
+
+// RemoveMarker removes a marker from the recording timeline of a path.
+var RemoveMarker = recordstore.RemoveMarker
+
+// ErrMarkerNotFound is returned when a marker is not found.
+var ErrMarkerNotFound = recordstore.ErrMarkerNotFound
+
 // PathAddExtension adds file extension based on recording format.
 var PathAddExtension = recordstore.PathAddExtension
 
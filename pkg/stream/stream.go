@@ -11,4 +11,7 @@ type Reader = stream.Reader
 type OnDataFunc = stream.OnDataFunc
 
 // Stream is a media stream.
-type Stream = stream.Stream
\ No newline at end of file
+type Stream = stream.Stream
+// MediaProperties contains properties that a media has in the source stream
+// and that are not part of its description.
+type MediaProperties = stream.MediaProperties